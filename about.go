@@ -0,0 +1,132 @@
+package coffeeshop
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// About describes the configuration of the server, so clients
+// and test harnesses can check which server they talk to.
+type About struct {
+	Version    string `json:"version"`
+	APIVersion string `json:"apiVersion"`
+	// Store is the backend of products: memory, redis, mongodb
+	// or the Go type of custom stores.
+	Store         string `json:"store"`
+	Latency       string `json:"latency"`
+	LatencyJitter string `json:"latencyJitter,omitempty"`
+	// Features lists enabled optional features sorted by name.
+	Features  []string  `json:"features"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// About returns the description of the server.
+func (cs *Server) About() About {
+	a := About{
+		Version:    ReadBuildInfo().Version,
+		APIVersion: cs.APIVersion,
+		Store:      storeBackend(cs.Store),
+		Latency:    cs.currentLatency().String(),
+		Features:   cs.features(),
+		StartedAt:  cs.startedAt.UTC(),
+	}
+	if cs.LatencyJitter > 0 {
+		a.LatencyJitter = cs.LatencyJitter.String()
+	}
+	return a
+}
+
+// storeBackend returns the name of the backend of the store.
+func storeBackend(s Store) string {
+	switch s.(type) {
+	case *MemoryStore:
+		return "memory"
+	case *RedisStore:
+		return "redis"
+	case *MongoStore:
+		return "mongodb"
+	case *TieredStore:
+		return "tiered"
+	}
+	if inner := wrappedStore(s); inner != nil {
+		return storeBackend(inner)
+	}
+	return fmt.Sprintf("%T", s)
+}
+
+// wrappedStore returns the store wrapped by the store decorator,
+// or nil if the store isn't a decorator.
+func wrappedStore(s Store) Store {
+	switch s := s.(type) {
+	case *CachingStore:
+		return s.Store
+	case *BreakerStore:
+		return s.Store
+	case *RetryingStore:
+		return s.Store
+	}
+	return nil
+}
+
+// features returns names of enabled optional features.
+func (cs *Server) features() []string {
+	_, fakeClock := cs.Clock.(*FakeClock)
+	enabled := map[string]bool{
+		"access_policy":     len(cs.Policy.Groups) > 0,
+		"admin_auth":        cs.AdminToken != "",
+		"baristas":          cs.Baristas > 0,
+		"cache":             cs.CacheTTL > 0,
+		"circuit_breaker":   cs.breaker != nil,
+		"compression":       cs.Compressor != nil,
+		"connection_limits": cs.MaxConnectionRequests > 0 || cs.MaxConnectionAge > 0,
+		"cors":              len(cs.CORSOrigins) > 0,
+		"debug_logging":     cs.debug.Load(),
+		"docs":              cs.Docs,
+		"fake_clock":        fakeClock,
+		"faults":            cs.FaultRate > 0,
+		"header_latency":    cs.HeaderLatency,
+		"hypermedia":        cs.Encoders[jsonAPIContentType] != nil,
+		"keyed_products":    cs.KeyedProducts,
+		"links":             cs.Links,
+		"load_shedding":     cs.MaxConcurrentRequests > 0,
+		"profiling":         cs.Profiling,
+		"recording":         cs.RecordingPath != "",
+		"replay":            cs.replay != nil,
+		"scenario":          len(cs.Scenario.Steps) > 0,
+		"snapshots":         cs.SnapshotPath != "",
+		"store_retries":     cs.retrying != nil,
+		"structured_prices": cs.StructuredPrices,
+		"tenants":           cs.Tenants,
+		"throttle":          cs.Throttle > 0,
+		"tls":               strings.HasPrefix(cs.URL, "https://"),
+		"trusted_proxies":   len(cs.TrustedProxies) > 0,
+	}
+	features := []string{}
+	for name, on := range enabled {
+		if on {
+			features = append(features, name)
+		}
+	}
+	sort.Strings(features)
+	return features
+}
+
+// String returns the one line startup banner of the server.
+func (a About) String() string {
+	s := fmt.Sprintf("coffeeshop %s (API %s) store=%s latency=%s", a.Version, a.APIVersion, a.Store, a.Latency)
+	if a.LatencyJitter != "" {
+		s += " jitter=" + a.LatencyJitter
+	}
+	if len(a.Features) > 0 {
+		s += fmt.Sprintf(" features=%v", a.Features)
+	}
+	return s
+}
+
+// GetAbout describes the configuration of the server.
+func (cs *Server) GetAbout(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, cs.About())
+}
@@ -0,0 +1,45 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+func TestServer_DescribesConfigurationAtAbout(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "10ms", t,
+		coffeeshop.WithDocs(),
+		coffeeshop.WithFaults(0.01, http.StatusServiceUnavailable),
+		coffeeshop.WithAdminToken("secret"),
+	)
+	resp, err := http.Get(shop.URL + "about")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200, got %d", resp.StatusCode)
+	}
+	var got coffeeshop.About
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Store != "memory" {
+		t.Errorf("want memory store, got %q", got.Store)
+	}
+	if got.Latency != "10ms" {
+		t.Errorf("want latency 10ms, got %q", got.Latency)
+	}
+	if got.Version != coffeeshop.Version || got.APIVersion != coffeeshop.DefaultAPIVersion {
+		t.Errorf("want version %s and API %s, got %s and %s", coffeeshop.Version, coffeeshop.DefaultAPIVersion, got.Version, got.APIVersion)
+	}
+	want := []string{"admin_auth", "docs", "faults"}
+	if !cmp.Equal(want, got.Features) {
+		t.Error(cmp.Diff(want, got.Features))
+	}
+}
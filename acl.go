@@ -0,0 +1,117 @@
+package coffeeshop
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// apiKeyHeader is the request header holding the API key.
+const apiKeyHeader = "X-API-Key"
+
+// Authenticated is the role of all API keys of the policy. Route
+// groups allowing it can be called with any known API key.
+const Authenticated = "*"
+
+// Policy describes which API keys may call which route groups.
+// Clients send API keys in the X-API-Key header. For example, the
+// policy below keeps health probes public, requires any known key
+// for the API and the admin role for administration endpoints:
+//
+//	coffeeshop.Policy{
+//		Keys: map[string][]string{
+//			"k3y-reader": {"reader"},
+//			"k3y-ops":    {"reader", "admin"},
+//		},
+//		Groups: map[coffeeshop.RouteGroup][]string{
+//			coffeeshop.APIRoutes:   {coffeeshop.Authenticated},
+//			coffeeshop.AdminRoutes: {"admin"},
+//		},
+//	}
+type Policy struct {
+	// Keys holds roles of API keys.
+	Keys map[string][]string
+	// Groups holds roles allowed to call route groups. Groups
+	// without roles are public.
+	Groups map[RouteGroup][]string
+}
+
+// WithPolicy restricts route groups to API keys with roles allowed
+// by the policy. Denied requests are answered with 403 Forbidden and
+// an error explaining the denial.
+func WithPolicy(p Policy) Option {
+	return func(s *Server) error {
+		for g, roles := range p.Groups {
+			switch g {
+			case APIRoutes, AdminRoutes, InfraRoutes:
+			default:
+				return fmt.Errorf("unknown route group %q", g)
+			}
+			if len(roles) == 0 {
+				return fmt.Errorf("no roles allowed to call %s routes", g)
+			}
+		}
+		for key := range p.Keys {
+			if strings.TrimSpace(key) == "" {
+				return errors.New("empty API key")
+			}
+		}
+		s.Policy = p
+		return nil
+	}
+}
+
+// roles returns roles of the API key and whether the key is known.
+func (p Policy) roles(key string) ([]string, bool) {
+	for k, roles := range p.Keys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			return roles, true
+		}
+	}
+	return nil, false
+}
+
+// allows reports whether any of the roles may call the route group.
+func (p Policy) allows(g RouteGroup, roles []string) bool {
+	for _, allowed := range p.Groups[g] {
+		if allowed == Authenticated {
+			return true
+		}
+		for _, role := range roles {
+			if role == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// authorize responds with 403 Forbidden to requests to routes
+// of the group without an API key allowed by the policy.
+func (cs *Server) authorize(g RouteGroup) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(apiKeyHeader)
+			if key == "" {
+				writeError(w, r, http.StatusForbidden, fmt.Sprintf("%s routes require an API key in the %s header", g, apiKeyHeader))
+				return
+			}
+			roles, ok := cs.Policy.roles(key)
+			if !ok {
+				writeError(w, r, http.StatusForbidden, "unknown API key")
+				return
+			}
+			if !cs.Policy.allows(g, roles) {
+				allowed := append([]string(nil), cs.Policy.Groups[g]...)
+				sort.Strings(allowed)
+				writeError(w, r, http.StatusForbidden, fmt.Sprintf("API key with roles %v can't call %s routes, requires one of %v", roles, g, allowed))
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,92 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/qba73/coffeeshop"
+)
+
+var policy = coffeeshop.Policy{
+	Keys: map[string][]string{
+		"reader-key": {"reader"},
+		"ops-key":    {"reader", "admin"},
+	},
+	Groups: map[coffeeshop.RouteGroup][]string{
+		coffeeshop.APIRoutes:   {coffeeshop.Authenticated},
+		coffeeshop.AdminRoutes: {"admin"},
+	},
+}
+
+// getWithKey sends the GET request with the API key, if not empty.
+func getWithKey(t *testing.T, url, key string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestServer_AllowsRouteGroupsByPolicy(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t, coffeeshop.WithPolicy(policy))
+	tests := []struct {
+		path, key string
+		want      int
+	}{
+		{path: "healthz", want: http.StatusOK},
+		{path: "products", want: http.StatusForbidden},
+		{path: "products", key: "unknown", want: http.StatusForbidden},
+		{path: "products", key: "reader-key", want: http.StatusOK},
+		{path: "about", key: "reader-key", want: http.StatusForbidden},
+		{path: "about", key: "ops-key", want: http.StatusOK},
+	}
+	for _, tc := range tests {
+		resp := getWithKey(t, shop.URL+tc.path, tc.key)
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("GET /%s with key %q: want HTTP %d, got %d", tc.path, tc.key, tc.want, resp.StatusCode)
+		}
+	}
+}
+
+func TestServer_ExplainsDeniedRequests(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t, coffeeshop.WithPolicy(policy))
+	resp := getWithKey(t, shop.URL+"about", "reader-key")
+	defer resp.Body.Close()
+	var got coffeeshop.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Error.Code != "forbidden" {
+		t.Errorf("want error code forbidden, got %q", got.Error.Code)
+	}
+	want := "API key with roles [reader] can't call admin routes, requires one of [admin]"
+	if got.Error.Message != want {
+		t.Errorf("want message %q, got %q", want, got.Error.Message)
+	}
+}
+
+func TestWithPolicy_RejectsUnknownRouteGroups(t *testing.T) {
+	t.Parallel()
+
+	_, err := coffeeshop.New(":0", &coffeeshop.MemoryStore{}, coffeeshop.WithPolicy(coffeeshop.Policy{
+		Groups: map[coffeeshop.RouteGroup][]string{"public": {"reader"}},
+	}))
+	if err == nil || !strings.Contains(err.Error(), "public") {
+		t.Errorf("want error about unknown route group, got %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package coffeeshop
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// WithAdminToken requires the bearer token in the Authorization
+// header of requests to /admin/ endpoints and to profiles under
+// /debug/pprof/. Documentation stays public.
+func WithAdminToken(token string) Option {
+	return func(s *Server) error {
+		if strings.TrimSpace(token) == "" {
+			return errors.New("empty admin token")
+		}
+		s.AdminToken = token
+		return nil
+	}
+}
+
+// requireAdminToken responds with 401 Unauthorized to requests
+// to /admin/ endpoints and profiles without the admin token.
+func (cs *Server) requireAdminToken(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/admin/") && !strings.HasPrefix(r.URL.Path, "/debug/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token := bearerToken(r)
+		if subtle.ConstantTimeCompare([]byte(token), []byte(cs.AdminToken)) != 1 {
+			writeUnauthorized(w, r, "admin token required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
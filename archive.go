@@ -0,0 +1,90 @@
+package coffeeshop
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// listed returns the products listed in response to the request,
+// filtered by the time of their last update, flavours and intensity.
+// Archived products are listed only if the "include_archived" query
+// parameter is true.
+func listed(r *http.Request, px []Product) ([]Product, error) {
+	px, err := updatedSince(r, px)
+	if err != nil {
+		return nil, err
+	}
+	v := r.URL.Query().Get("include_archived")
+	if v == "" {
+		return filtered(r, withoutArchived(px))
+	}
+	include, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid include_archived %q", v)
+	}
+	if include {
+		return filtered(r, px)
+	}
+	return filtered(r, withoutArchived(px))
+}
+
+// withoutArchived filters out archived products in place.
+func withoutArchived(px []Product) []Product {
+	listed := px[:0]
+	for _, p := range px {
+		if !p.Archived {
+			listed = append(listed, p)
+		}
+	}
+	return listed
+}
+
+// orderableProduct returns the product with the given ID from the
+// store. Archived products can't be ordered and aren't found.
+func orderableProduct(s Store, id string) (Product, error) {
+	p, err := s.GetProduct(id)
+	if err != nil {
+		return Product{}, err
+	}
+	if p.Archived {
+		return Product{}, fmt.Errorf("%w: product %s is archived", ErrProductNotFound, id)
+	}
+	return p, nil
+}
+
+// setArchived archives or restores the product and returns it
+// before and after the change. Products already in the requested
+// state aren't changed.
+func (cs *Server) setArchived(r *http.Request, pw ProductWriter, id string, archived bool) (old, product Product, err error) {
+	return cs.replaceProduct(r, id, func(old Product, exists bool) (Product, error) {
+		if !exists {
+			return Product{}, ErrProductNotFound
+		}
+		if old.Archived == archived {
+			return Product{}, errUnchanged
+		}
+		old.Archived = archived
+		return old, nil
+	}, pw.Update)
+}
+
+// RestoreProduct makes the archived product available again.
+func (cs *Server) RestoreProduct(w http.ResponseWriter, r *http.Request) {
+	pw, ok := storeAs[ProductWriter](cs.store(r))
+	if !ok {
+		writeError(w, r, http.StatusNotImplemented, "store doesn't support restoring products")
+		return
+	}
+	old, product, err := cs.setArchived(r, pw, chi.URLParam(r, "productID"), false)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	if old.Archived {
+		cs.audit(r, AuditUpdate, old, product)
+	}
+	writeJSON(w, r, http.StatusOK, cs.productView(r, product))
+}
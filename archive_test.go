@@ -0,0 +1,162 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// send sends the request without a body and returns the response.
+func send(t *testing.T, method, url string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// listedIDs returns IDs of products listed at the URL.
+func listedIDs(t *testing.T, url string) []string {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200, got %d", resp.StatusCode)
+	}
+	var px []coffeeshop.Product
+	if err := json.NewDecoder(resp.Body).Decode(&px); err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, 0, len(px))
+	for _, p := range px {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+func contains(ids []string, id string) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestServer_HidesArchivedProductsFromListings(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(5)}, "10ms", t)
+	resp := send(t, http.MethodDelete, shop.URL+"products/1")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("want HTTP 204, got %d", resp.StatusCode)
+	}
+
+	for _, path := range []string{"products", "products/coffee", "categories/coffee/products"} {
+		if contains(listedIDs(t, shop.URL+path), "1") {
+			t.Errorf("want archived product hidden from %s", path)
+		}
+		if !contains(listedIDs(t, shop.URL+path+"?include_archived=true"), "1") {
+			t.Errorf("want archived product listed at %s?include_archived=true", path)
+		}
+	}
+
+	resp, err := http.Get(shop.URL + "products/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var p coffeeshop.Product
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if !p.Archived {
+		t.Errorf("want archived product, got %+v", p)
+	}
+
+	resp, err = http.Get(shop.URL + "products?include_archived=maybe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("want HTTP 400 for invalid include_archived, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_KeepsOrdersOfArchivedProducts(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(5)}, "10ms", t,
+		coffeeshop.WithOrderInterval("1h"),
+	)
+	resp := createOrder(t, shop.URL, `{"items":[{"productId":"1","quantity":1}]}`)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("want HTTP 201, got %d", resp.StatusCode)
+	}
+	send(t, http.MethodDelete, shop.URL+"products/1").Body.Close()
+
+	resp, err := http.Get(shop.URL + resp.Header.Get("Location")[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("want order of archived product, got HTTP %d", resp.StatusCode)
+	}
+	resp, err = http.Get(shop.URL + "products/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("want archived product referenced by order, got HTTP %d", resp.StatusCode)
+	}
+
+	resp = createOrder(t, shop.URL, `{"items":[{"productId":"1","quantity":1}]}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("want HTTP 400 for order of archived product, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_RestoresArchivedProduct(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(5)}, "10ms", t)
+	send(t, http.MethodDelete, shop.URL+"products/1").Body.Close()
+
+	resp := send(t, http.MethodPost, shop.URL+"products/1/restore")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200, got %d", resp.StatusCode)
+	}
+	var p coffeeshop.Product
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Archived {
+		t.Errorf("want restored product, got %+v", p)
+	}
+	if !contains(listedIDs(t, shop.URL+"products"), "1") {
+		t.Error("want restored product listed")
+	}
+
+	resp = send(t, http.MethodPost, shop.URL+"products/42/restore")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("want HTTP 404 for unknown product, got %d", resp.StatusCode)
+	}
+}
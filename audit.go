@@ -0,0 +1,332 @@
+package coffeeshop
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"golang.org/x/exp/maps"
+)
+
+// Audited actions on products.
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// AuditEntry records a change of a product made through the API.
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+	// Actor is the principal making the change, named by the
+	// credentials of the request, like "customer:42" or "key:"
+	// followed by the SHA-256 of the API key, or the client
+	// address of anonymous requests, like "client:192.0.2.1".
+	Actor     string                 `json:"actor"`
+	Action    string                 `json:"action"`
+	ProductID string                 `json:"productId"`
+	Changes   map[string]AuditChange `json:"changes,omitempty"`
+	RequestID string                 `json:"requestId,omitempty"`
+}
+
+// AuditChange holds the old and the new value of a product field.
+type AuditChange struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// AuditSink records audit entries.
+type AuditSink interface {
+	Record(e AuditEntry) error
+}
+
+// AuditReader is implemented by audit sinks able to
+// return recorded entries.
+type AuditReader interface {
+	// Entries returns entries matching the filter
+	// in the order they were recorded.
+	Entries(f AuditFilter) ([]AuditEntry, error)
+}
+
+// AuditFilter selects audit entries. Zero fields match all entries.
+type AuditFilter struct {
+	ProductID string
+	Since     time.Time
+	Until     time.Time
+}
+
+func (f AuditFilter) matches(e AuditEntry) bool {
+	if f.ProductID != "" && e.ProductID != f.ProductID {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.Time.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// MemoryAuditLog keeps audit entries in memory.
+type MemoryAuditLog struct {
+	mx      sync.RWMutex
+	entries []AuditEntry
+}
+
+// Record appends the entry to the log.
+func (l *MemoryAuditLog) Record(e AuditEntry) error {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	l.entries = append(l.entries, e)
+	return nil
+}
+
+// Entries returns entries matching the filter.
+func (l *MemoryAuditLog) Entries(f AuditFilter) ([]AuditEntry, error) {
+	l.mx.RLock()
+	defer l.mx.RUnlock()
+	entries := []AuditEntry{}
+	for _, e := range l.entries {
+		if f.matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// FileAuditLog appends audit entries to the file
+// as JSON objects separated by newlines.
+type FileAuditLog struct {
+	Path string
+
+	mx sync.Mutex
+}
+
+// Record appends the entry to the file, creating it if necessary.
+func (l *FileAuditLog) Record(e AuditEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	f, err := os.OpenFile(l.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Entries reads entries matching the filter from the file.
+func (l *FileAuditLog) Entries(filter AuditFilter) ([]AuditEntry, error) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	entries := []AuditEntry{}
+	f, err := os.Open(l.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var e AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", l.Path, line, err)
+		}
+		if filter.matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// Replacer is implemented by stores able to return the product
+// replaced by a write. The replaced product is read under the same
+// lock as the write, so audit entries describe exactly the change the
+// write made, even when other writes of the product run concurrently.
+type Replacer interface {
+	// Replace stores the product returned by write, which is called
+	// with the stored product with the ID, if it exists. Errors of
+	// write abort the replacement and are returned by Replace.
+	Replace(id string, write func(old Product, exists bool) (Product, error)) (old, product Product, err error)
+}
+
+// errUnchanged is returned by write functions of replaceProduct
+// to leave the product as it is.
+var errUnchanged = errors.New("product unchanged")
+
+// replaceProduct stores the product returned by write and returns it
+// with the product it replaced, which is zero for new products. Stores
+// which aren't Replacers are read before the write, which is made by
+// put. If write returns errUnchanged, the stored product is returned
+// as both the old and the new product.
+func (cs *Server) replaceProduct(r *http.Request, id string, write func(old Product, exists bool) (Product, error), put func(p Product) (Product, error)) (old, product Product, err error) {
+	if rp, ok := storeAs[Replacer](cs.store(r)); ok {
+		var unchanged Product
+		old, product, err = rp.Replace(id, func(old Product, exists bool) (Product, error) {
+			p, err := write(old, exists)
+			if errors.Is(err, errUnchanged) {
+				unchanged = old
+			}
+			return p, err
+		})
+		if errors.Is(err, errUnchanged) {
+			return unchanged, unchanged, nil
+		}
+		return old, product, err
+	}
+	old, err = cs.store(r).GetProduct(id)
+	exists := err == nil
+	if err != nil && !errors.Is(err, ErrProductNotFound) {
+		return Product{}, Product{}, err
+	}
+	p, err := write(old, exists)
+	if errors.Is(err, errUnchanged) {
+		return old, old, nil
+	}
+	if err != nil {
+		return Product{}, Product{}, err
+	}
+	product, err = put(p)
+	if err != nil {
+		return Product{}, Product{}, err
+	}
+	if !exists {
+		old = Product{}
+	}
+	return old, product, nil
+}
+
+// WithAuditSink configures the server to record changes
+// of products in the sink instead of the memory.
+func WithAuditSink(sink AuditSink) Option {
+	return func(s *Server) error {
+		if sink == nil {
+			return errors.New("nil audit sink")
+		}
+		s.AuditSink = sink
+		return nil
+	}
+}
+
+// WithAuditFile configures the server to append
+// changes of products to the file.
+func WithAuditFile(path string) Option {
+	return WithAuditSink(&FileAuditLog{Path: path})
+}
+
+// audit records the change of the product made by the request.
+// The old product is zero for created products.
+func (cs *Server) audit(r *http.Request, action string, old, updated Product) {
+	id := updated.ID
+	if id == "" {
+		id = old.ID
+	}
+	e := AuditEntry{
+		Time:      cs.Clock.Now(),
+		Actor:     cs.principal(r),
+		Action:    action,
+		ProductID: id,
+		Changes:   productChanges(old, updated),
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+	if err := cs.AuditSink.Record(e); err != nil {
+		cs.logf("audit: can't record %s of product %s: %v", action, id, err)
+	}
+}
+
+// productChanges returns changed fields of the product.
+func productChanges(old, updated Product) map[string]AuditChange {
+	fields := func(p Product) map[string]any {
+		var price any
+		if p.Price != (Money{}) {
+			price = p.Price.String() + " " + p.Price.Currency
+		}
+		properties := map[string]string{}
+		for _, prop := range p.Properties {
+			properties[prop.Name] = prop.Value
+		}
+		return map[string]any{
+			"type":         p.Type,
+			"brand":        p.Brand,
+			"name":         p.Name,
+			"sku":          p.SKU,
+			"ean":          p.EAN,
+			"unit":         string(p.Unit),
+			"quantity":     int(p.Quantity),
+			"price":        price,
+			"stock":        p.Stock,
+			"properties":   properties,
+			"intensity":    p.Intensity,
+			"flavours":     p.Flavours,
+			"descriptions": p.Descriptions,
+			"archived":     p.Archived,
+		}
+	}
+	before, after := fields(old), fields(updated)
+	changes := make(map[string]AuditChange)
+	for _, name := range maps.Keys(before) {
+		if fmt.Sprint(before[name]) != fmt.Sprint(after[name]) {
+			changes[name] = AuditChange{Old: before[name], New: after[name]}
+		}
+	}
+	return changes
+}
+
+// logf logs the message to the error log of the HTTP server.
+func (cs *Server) logf(format string, args ...any) {
+	if cs.HTTPServer.ErrorLog != nil {
+		cs.HTTPServer.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// GetAudit returns audit entries, optionally filtered by the
+// "product" ID and the "since" and "until" RFC 3339 times.
+func (cs *Server) GetAudit(w http.ResponseWriter, r *http.Request) {
+	reader, ok := cs.AuditSink.(AuditReader)
+	if !ok {
+		writeError(w, r, http.StatusNotImplemented, "audit sink doesn't support reading")
+		return
+	}
+	q := r.URL.Query()
+	filter := AuditFilter{ProductID: q.Get("product")}
+	for _, p := range []struct {
+		name string
+		t    *time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, fmt.Sprintf("invalid %s time %q", p.name, v))
+			return
+		}
+		*p.t = t
+	}
+	entries, err := reader.Entries(filter)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	writeJSON(w, r, http.StatusOK, entries)
+}
@@ -0,0 +1,196 @@
+package coffeeshop_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+// getAudit returns audit entries listed by the server at the path.
+func getAudit(t *testing.T, url string) []coffeeshop.AuditEntry {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200, got %d", resp.StatusCode)
+	}
+	var entries []coffeeshop.AuditEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+// auditActor returns the actor of audit entries
+// of changes made with the API key.
+func auditActor(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "key:" + hex.EncodeToString(sum[:])
+}
+
+// changeProducts creates, updates and deletes a product with the API
+// key. Requests claim to be made by mallory in the X-Forwarded-User
+// header, which must not be trusted.
+func changeProducts(t *testing.T, shop *coffeeshop.Server, key string) {
+	t.Helper()
+	send := func(method, path, body string, want int) {
+		t.Helper()
+		req, err := http.NewRequest(method, shop.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-API-Key", key)
+		req.Header.Set("X-Forwarded-User", "mallory")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("%s %s: want HTTP %d, got %d", method, path, want, resp.StatusCode)
+		}
+	}
+	send(http.MethodPost, "products", `{"type":"Tea","brand":"Lipton","name":"Green","price":"2.50"}`, http.StatusCreated)
+	send(http.MethodPut, "products/9/stock", `{"stock":4}`, http.StatusOK)
+	send(http.MethodDelete, "products/9", "", http.StatusNoContent)
+}
+
+func TestServer_RecordsProductChangesInAuditLog(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: coffeeshop.Products{}}
+	for id, p := range inventory {
+		store.Products[id] = p
+	}
+	shop := newCoffeShopTestServer(store, "10ms", t, coffeeshop.WithIDGenerator(&coffeeshop.SequentialIDs{}))
+	changeProducts(t, shop, "alice-key")
+
+	entries := getAudit(t, shop.URL+"admin/audit")
+	if len(entries) != 3 {
+		t.Fatalf("want 3 entries, got %+v", entries)
+	}
+	for i, action := range []string{coffeeshop.AuditCreate, coffeeshop.AuditUpdate, coffeeshop.AuditDelete} {
+		e := entries[i]
+		if e.Action != action || e.ProductID != "9" || e.Actor != auditActor("alice-key") {
+			t.Errorf("want %s of product 9 with alice-key, got %+v", action, e)
+		}
+		if e.Time.IsZero() || e.RequestID == "" {
+			t.Errorf("want time and request ID, got %+v", e)
+		}
+	}
+	want := map[string]coffeeshop.AuditChange{"stock": {Old: 0.0, New: 4.0}}
+	if !cmp.Equal(want, entries[1].Changes) {
+		t.Error(cmp.Diff(want, entries[1].Changes))
+	}
+	if got := entries[0].Changes["price"]; got.Old != nil || got.New != "2.50 EUR" {
+		t.Errorf("want price change to 2.50 EUR, got %+v", got)
+	}
+	want = map[string]coffeeshop.AuditChange{"archived": {Old: false, New: true}}
+	if !cmp.Equal(want, entries[2].Changes) {
+		t.Error(cmp.Diff(want, entries[2].Changes))
+	}
+}
+
+func TestMemoryStore_ReplacesProductsWhileLocked(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: stockedInventory(1)}
+	old, product, err := store.Replace("1", func(old coffeeshop.Product, exists bool) (coffeeshop.Product, error) {
+		written := make(chan struct{})
+		go func() {
+			store.SetStock("1", 7)
+			close(written)
+		}()
+		select {
+		case <-written:
+			t.Error("want concurrent writes blocked while replacing the product")
+		case <-time.After(50 * time.Millisecond):
+		}
+		old.Stock = 2
+		return old, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old.Stock != 1 || product.Stock != 2 || product.Version != old.Version+1 {
+		t.Errorf("want stock replaced from 1 to 2 in the next version, got %+v and %+v", old, product)
+	}
+}
+
+func TestServer_FiltersAuditLogByProductAndTime(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(1)}, "10ms", t, coffeeshop.WithIDGenerator(&coffeeshop.SequentialIDs{}))
+	changeProducts(t, shop, "alice-key")
+	resp := putIfMatch(t, shop.URL+"products/1/stock", "", `{"stock":2}`)
+	resp.Body.Close()
+
+	if n := len(getAudit(t, shop.URL+"admin/audit?product=1")); n != 1 {
+		t.Errorf("want 1 entry of product 1, got %d", n)
+	}
+	since := start.Add(-time.Minute).Format(time.RFC3339)
+	if n := len(getAudit(t, shop.URL+"admin/audit?since="+since)); n != 4 {
+		t.Errorf("want 4 entries since %s, got %d", since, n)
+	}
+	until := start.Add(-time.Minute).Format(time.RFC3339)
+	if n := len(getAudit(t, shop.URL+"admin/audit?until="+until)); n != 0 {
+		t.Errorf("want no entries until %s, got %d", until, n)
+	}
+
+	resp, err := http.Get(shop.URL + "admin/audit?since=yesterday")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("want HTTP 400 for invalid time, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_AppendsAuditLogToFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(1)}, "10ms", t,
+		coffeeshop.WithIDGenerator(&coffeeshop.SequentialIDs{}),
+		coffeeshop.WithAuditFile(path),
+	)
+	changeProducts(t, shop, "bob-key")
+
+	log := coffeeshop.FileAuditLog{Path: path}
+	entries, err := log.Entries(coffeeshop.AuditFilter{ProductID: "9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[2].Action != coffeeshop.AuditDelete || entries[2].Actor != auditActor("bob-key") {
+		t.Errorf("want 3 entries ending with delete with bob-key, got %+v", entries)
+	}
+	if got := getAudit(t, shop.URL+"admin/audit"); !cmp.Equal(entries, got) {
+		t.Error(cmp.Diff(entries, got))
+	}
+}
+
+func TestFileAuditLog_ReturnsNoEntriesBeforeFirstRecord(t *testing.T) {
+	t.Parallel()
+
+	log := coffeeshop.FileAuditLog{Path: filepath.Join(t.TempDir(), "audit.jsonl")}
+	entries, err := log.Entries(coffeeshop.AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("want no entries, got %+v", entries)
+	}
+}
@@ -0,0 +1,262 @@
+package coffeeshop
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QueuedOrder is an order waiting for or being prepared by a barista.
+type QueuedOrder struct {
+	OrderID string      `json:"orderId"`
+	Status  OrderStatus `json:"status"`
+	// Position is the place of a waiting order in the queue,
+	// starting at 1. It is zero for orders being prepared.
+	Position int `json:"position,omitempty"`
+	// ReadyAt is the estimated time the order is ready.
+	ReadyAt time.Time `json:"readyAt"`
+}
+
+// QueueStatus reports the state of the barista work queue.
+type QueueStatus struct {
+	Baristas int `json:"baristas"`
+	// Depth is the number of orders waiting for a barista.
+	Depth int `json:"depth"`
+	// Preparing is the number of orders being prepared.
+	Preparing int `json:"preparing"`
+	// EstimatedWait is the number of seconds a new order
+	// waits before a barista starts preparing it.
+	EstimatedWait float64       `json:"estimatedWait"`
+	Orders        []QueuedOrder `json:"orders"`
+}
+
+// WithBaristas makes the given number of baristas prepare orders
+// one at a time. Placed orders wait in a queue in the received
+// state until a barista is free, stay in the preparing state for
+// their preparation time and are collected one order interval
+// after they are ready.
+//
+// Without baristas orders move through the lifecycle one state
+// per order interval regardless of how many orders are placed.
+func WithBaristas(n int) Option {
+	return func(s *Server) error {
+		if n <= 0 {
+			return errors.New("number of baristas must be positive")
+		}
+		s.Baristas = n
+		return nil
+	}
+}
+
+// WithPreparationTime configures how long a barista prepares
+// a single unit of the product with the ID or of the products
+// of the type. Product IDs take precedence over product types.
+// Products without a preparation time take the order interval.
+func WithPreparationTime(product, d string) Option {
+	return func(s *Server) error {
+		t, err := time.ParseDuration(d)
+		if err != nil {
+			return err
+		}
+		if t <= 0 {
+			return fmt.Errorf("preparation time of %q must be positive", product)
+		}
+		if s.PreparationTimes == nil {
+			s.PreparationTimes = make(map[string]time.Duration)
+		}
+		s.PreparationTimes[strings.ToLower(product)] = t
+		return nil
+	}
+}
+
+// preparationTime returns how long a barista prepares the items.
+func (cs *Server) preparationTime(items []OrderItem) time.Duration {
+	var total time.Duration
+	for _, item := range items {
+		unit, ok := cs.PreparationTimes[strings.ToLower(item.ProductID)]
+		if !ok {
+			unit = cs.OrderInterval
+			if p, err := cs.Store.GetProduct(item.ProductID); err == nil {
+				if t, ok := cs.PreparationTimes[strings.ToLower(p.Type)]; ok {
+					unit = t
+				}
+			}
+		}
+		total += unit * time.Duration(item.Quantity)
+	}
+	return total
+}
+
+// queuedOrder is an order in the barista queue.
+type queuedOrder struct {
+	id          string
+	preparation time.Duration
+	// readyAt is set when a barista starts preparing the order.
+	readyAt time.Time
+}
+
+// baristaQueue holds orders waiting for and being prepared by baristas.
+type baristaQueue struct {
+	mx        sync.Mutex
+	start     sync.Once
+	waiting   []queuedOrder
+	preparing map[string]queuedOrder
+	// wake signals idle baristas that an order is waiting.
+	wake chan struct{}
+}
+
+func (bq *baristaQueue) init() {
+	bq.start.Do(func() {
+		bq.preparing = make(map[string]queuedOrder)
+		bq.wake = make(chan struct{}, 1)
+	})
+}
+
+// push adds the order at the end of the queue.
+func (bq *baristaQueue) push(o queuedOrder) {
+	bq.mx.Lock()
+	bq.waiting = append(bq.waiting, o)
+	bq.mx.Unlock()
+	bq.signal()
+}
+
+// signal wakes an idle barista, if any.
+func (bq *baristaQueue) signal() {
+	select {
+	case bq.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pop takes the first waiting order and marks it as being prepared.
+func (bq *baristaQueue) pop(now time.Time) (queuedOrder, bool) {
+	bq.mx.Lock()
+	defer bq.mx.Unlock()
+	if len(bq.waiting) == 0 {
+		return queuedOrder{}, false
+	}
+	o := bq.waiting[0]
+	bq.waiting = bq.waiting[1:]
+	o.readyAt = now.Add(o.preparation)
+	bq.preparing[o.id] = o
+	if len(bq.waiting) > 0 {
+		// Pass the wake up on to the next idle barista.
+		bq.signal()
+	}
+	return o, true
+}
+
+// done removes the prepared order from the queue.
+func (bq *baristaQueue) done(id string) {
+	bq.mx.Lock()
+	defer bq.mx.Unlock()
+	delete(bq.preparing, id)
+}
+
+// status estimates when queued orders are ready, assuming each
+// waiting order is taken by the barista who is free first.
+func (bq *baristaQueue) status(baristas int, now time.Time) QueueStatus {
+	bq.mx.Lock()
+	defer bq.mx.Unlock()
+	qs := QueueStatus{
+		Baristas:  baristas,
+		Depth:     len(bq.waiting),
+		Preparing: len(bq.preparing),
+		Orders:    []QueuedOrder{},
+	}
+	free := make([]time.Time, 0, baristas)
+	for _, o := range bq.preparing {
+		qs.Orders = append(qs.Orders, QueuedOrder{OrderID: o.id, Status: OrderPreparing, ReadyAt: o.readyAt})
+		free = append(free, o.readyAt)
+	}
+	sort.Slice(qs.Orders, func(i, j int) bool { return qs.Orders[i].ReadyAt.Before(qs.Orders[j].ReadyAt) })
+	for len(free) < baristas {
+		free = append(free, now)
+	}
+	sortTimes(free)
+	for i, o := range bq.waiting {
+		start := free[0]
+		if start.Before(now) {
+			start = now
+		}
+		free[0] = start.Add(o.preparation)
+		qs.Orders = append(qs.Orders, QueuedOrder{OrderID: o.id, Status: OrderReceived, Position: i + 1, ReadyAt: free[0]})
+		sortTimes(free)
+	}
+	if wait := free[0].Sub(now); wait > 0 {
+		qs.EstimatedWait = wait.Seconds()
+	}
+	return qs
+}
+
+func sortTimes(tx []time.Time) {
+	sort.Slice(tx, func(i, j int) bool { return tx[i].Before(tx[j]) })
+}
+
+// enqueueOrder puts the order in the barista queue.
+func (cs *Server) enqueueOrder(order Order) {
+	cs.queue.init()
+	cs.queue.push(queuedOrder{id: order.ID, preparation: cs.preparationTime(order.Items)})
+}
+
+// startBaristas starts the configured number of baristas
+// preparing queued orders until the server shuts down.
+func (cs *Server) startBaristas() {
+	if cs.Baristas == 0 {
+		return
+	}
+	cs.queue.init()
+	for i := 0; i < cs.Baristas; i++ {
+		go cs.barista()
+	}
+}
+
+// barista prepares queued orders one at a time.
+func (cs *Server) barista() {
+	for {
+		o, ok := cs.queue.pop(cs.Clock.Now())
+		if !ok {
+			select {
+			case <-cs.shuttingDown.Done():
+				return
+			case <-cs.queue.wake:
+				continue
+			}
+		}
+		cs.advanceOrder(o.id, OrderPreparing)
+		select {
+		case <-cs.shuttingDown.Done():
+			return
+		case <-cs.Clock.After(o.preparation):
+		}
+		cs.queue.done(o.id)
+		cs.advanceOrder(o.id, OrderReady)
+		cs.Clock.AfterFunc(cs.OrderInterval, func() {
+			cs.advanceOrder(o.id, OrderCollected)
+		})
+	}
+}
+
+// advanceOrder moves the order to the status and publishes the update.
+func (cs *Server) advanceOrder(id string, status OrderStatus) {
+	order, err := cs.OrderStore.UpdateOrderStatus(id, status)
+	if err != nil {
+		return
+	}
+	cs.orderEvents.Publish(OrderUpdated, order)
+}
+
+// GetQueue reports the depth of the barista queue and
+// when queued orders are estimated to be ready.
+func (cs *Server) GetQueue(w http.ResponseWriter, r *http.Request) {
+	if cs.Baristas == 0 {
+		writeError(w, r, http.StatusNotImplemented, "server doesn't simulate baristas")
+		return
+	}
+	cs.queue.init()
+	writeJSON(w, r, http.StatusOK, cs.queue.status(cs.Baristas, cs.Clock.Now()))
+}
@@ -0,0 +1,120 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// getQueue returns the state of the barista queue.
+func getQueue(t *testing.T, url string) coffeeshop.QueueStatus {
+	t.Helper()
+	resp, err := http.Get(url + "queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200, got %d", resp.StatusCode)
+	}
+	var qs coffeeshop.QueueStatus
+	if err := json.NewDecoder(resp.Body).Decode(&qs); err != nil {
+		t.Fatal(err)
+	}
+	return qs
+}
+
+// orderStatus returns the status of the order with the ID.
+func orderStatus(t *testing.T, url, id string) coffeeshop.OrderStatus {
+	t.Helper()
+	resp, err := http.Get(url + "orders/" + id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var o coffeeshop.Order
+	if err := json.NewDecoder(resp.Body).Decode(&o); err != nil {
+		t.Fatal(err)
+	}
+	return o.Status
+}
+
+func TestServer_QueuesOrdersForBusyBaristas(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(10)}, "10ms", t,
+		coffeeshop.WithBaristas(1),
+		coffeeshop.WithPreparationTime("coffee", "1h"),
+		coffeeshop.WithPreparationTime("1", "30m"),
+	)
+	createOrder(t, shop.URL, `{"items":[{"productId":"1","quantity":2}]}`).Body.Close()
+	createOrder(t, shop.URL, `{"items":[{"productId":"4","quantity":1}]}`).Body.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for orderStatus(t, shop.URL, "1") != coffeeshop.OrderPreparing {
+		if time.Now().After(deadline) {
+			t.Fatal("first order not prepared before deadline")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := orderStatus(t, shop.URL, "2"); got != coffeeshop.OrderReceived {
+		t.Errorf("want queued order %q, got %q", coffeeshop.OrderReceived, got)
+	}
+
+	qs := getQueue(t, shop.URL)
+	if qs.Baristas != 1 || qs.Depth != 1 || qs.Preparing != 1 || len(qs.Orders) != 2 {
+		t.Fatalf("want one order preparing and one waiting, got %+v", qs)
+	}
+	// The first order takes two units of 30 minutes and
+	// the second order a unit of coffee taking an hour.
+	if wait := time.Duration(qs.EstimatedWait * float64(time.Second)); wait < 119*time.Minute || wait > 2*time.Hour {
+		t.Errorf("want estimated wait of about two hours, got %v", wait)
+	}
+	waiting := qs.Orders[1]
+	if waiting.OrderID != "2" || waiting.Position != 1 || waiting.ReadyAt.Sub(qs.Orders[0].ReadyAt) != time.Hour {
+		t.Errorf("want order 2 ready an hour after order 1, got %+v", qs.Orders)
+	}
+}
+
+func TestServer_BaristasPrepareOrdersConcurrently(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(10)}, "10ms", t,
+		coffeeshop.WithBaristas(2),
+		coffeeshop.WithOrderInterval("20ms"),
+		coffeeshop.WithPreparationTime("1", "50ms"),
+	)
+	for i := 0; i < 3; i++ {
+		createOrder(t, shop.URL, `{"items":[{"productId":"1","quantity":1}]}`).Body.Close()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for _, id := range []string{"1", "2", "3"} {
+		for orderStatus(t, shop.URL, id) != coffeeshop.OrderCollected {
+			if time.Now().After(deadline) {
+				t.Fatalf("order %s not collected before deadline", id)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if qs := getQueue(t, shop.URL); qs.Depth != 0 || qs.Preparing != 0 || qs.EstimatedWait != 0 {
+		t.Errorf("want empty queue, got %+v", qs)
+	}
+}
+
+func TestServer_Returns501OnQueueWithoutBaristas(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{}, "10ms", t)
+	resp, err := http.Get(shop.URL + "queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Errorf("want HTTP 501, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,95 @@
+package coffeeshop
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// maxBatchIDs limits the number of products looked up
+// by a single request.
+const maxBatchIDs = 100
+
+// BatchGetter is implemented by stores able to look up
+// many products in one round trip to their backend.
+type BatchGetter interface {
+	// GetMany returns products with the given IDs in the order of
+	// the IDs. Products which aren't found are left out.
+	GetMany(ids []string) ([]Product, error)
+}
+
+// GetMany returns products with the given IDs in the order
+// of the IDs, leaving out products which aren't found.
+func (ms *MemoryStore) GetMany(ids []string) ([]Product, error) {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	px := make([]Product, 0, len(ids))
+	for _, id := range ids {
+		if p, ok := ms.Products[id]; ok {
+			px = append(px, p.clone())
+		}
+	}
+	return px, nil
+}
+
+// getMany returns products with the given IDs from the store in the
+// order of the IDs. Stores which aren't BatchGetters are asked for
+// products one by one.
+func getMany(s Store, ids []string) ([]Product, error) {
+	if bg, ok := s.(BatchGetter); ok {
+		return bg.GetMany(ids)
+	}
+	px := make([]Product, 0, len(ids))
+	for _, id := range ids {
+		p, err := s.GetProduct(id)
+		if errors.Is(err, ErrProductNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		px = append(px, p)
+	}
+	return px, nil
+}
+
+// orderedByIDs returns the products sorted in the order of the IDs.
+// Backends return products looked up in batches in their own order.
+func orderedByIDs(ids []string, px []Product) []Product {
+	byID := make(map[string]Product, len(px))
+	for _, p := range px {
+		byID[p.ID] = p
+	}
+	ordered := make([]Product, 0, len(px))
+	for _, id := range ids {
+		if p, ok := byID[id]; ok {
+			ordered = append(ordered, p)
+		}
+	}
+	return ordered
+}
+
+// requestedIDs returns unique IDs of products requested with the
+// 'ids' query parameter, for example "?ids=1,2,5", and reports
+// whether the parameter was given.
+func requestedIDs(r *http.Request) ([]string, bool, error) {
+	q := r.URL.Query()
+	if !q.Has("ids") {
+		return nil, false, nil
+	}
+	var ids []string
+	seen := make(map[string]bool)
+	for _, id := range splitList(q.Get("ids")) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, true, errors.New("ids must list product IDs separated by commas")
+	}
+	if len(ids) > maxBatchIDs {
+		return nil, true, fmt.Errorf("ids can list up to %d products", maxBatchIDs)
+	}
+	return ids, true, nil
+}
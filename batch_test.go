@@ -0,0 +1,77 @@
+package coffeeshop_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+// plainStore hides optional interfaces of the store.
+type plainStore struct {
+	coffeeshop.Store
+}
+
+func TestServer_GetsProductsByIDsInRequestedOrder(t *testing.T) {
+	t.Parallel()
+
+	for name, store := range map[string]coffeeshop.Store{
+		"batch getter": &coffeeshop.MemoryStore{Products: stockedInventory(5)},
+		"plain store":  plainStore{&coffeeshop.MemoryStore{Products: stockedInventory(5)}},
+	} {
+		store := store
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			shop := newCoffeShopTestServer(store, "0s", t)
+			got := listedIDs(t, shop.URL+"products?ids=3,missing,1,3")
+			want := []string{"3", "1"}
+			if !cmp.Equal(want, got) {
+				t.Error(cmp.Diff(want, got))
+			}
+		})
+	}
+}
+
+func TestServer_RejectsInvalidProductIDs(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(5)}, "0s", t)
+	ids := make([]string, 101)
+	for i := range ids {
+		ids[i] = fmt.Sprint(i)
+	}
+	for _, query := range []string{"ids=", "ids=,,", "ids=" + strings.Join(ids, ",")} {
+		status, _ := getResponse(t, shop.URL+"products?"+query)
+		if status != http.StatusBadRequest {
+			t.Errorf("%s: want HTTP 400, got %d", query, status)
+		}
+	}
+}
+
+func TestTieredStore_GetsManyProductsFromCacheAndPrimary(t *testing.T) {
+	t.Parallel()
+
+	primary := &downStore{MemoryStore: &coffeeshop.MemoryStore{Products: stockedInventory(5)}}
+	ts := coffeeshop.NewTieredStore(primary, nil)
+	if _, err := ts.GetProduct("2"); err != nil {
+		t.Fatal(err)
+	}
+	px, err := ts.GetMany([]string{"3", "2", "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range px {
+		got = append(got, p.ID)
+	}
+	if want := []string{"3", "2", "1"}; !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+	if stats := ts.Stats(); stats.Primary != 2 {
+		t.Errorf("want 2 primary reads, got %+v", stats)
+	}
+}
@@ -0,0 +1,67 @@
+package coffeeshop_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// benchmarkHandler returns the handler of a server
+// without latency serving the sample inventory.
+func benchmarkHandler(b *testing.B, opts ...coffeeshop.Option) http.Handler {
+	b.Helper()
+	store, err := coffeeshop.OpenStore("memory://")
+	if err != nil {
+		b.Fatal(err)
+	}
+	opts = append([]coffeeshop.Option{coffeeshop.WithLatency("0s")}, opts...)
+	srv, err := coffeeshop.New(":0", store, opts...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return srv.Handler()
+}
+
+// benchmarkGet serves GET requests for the path.
+func benchmarkGet(b *testing.B, path string, opts ...coffeeshop.Option) {
+	h := benchmarkHandler(b, opts...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			b.Fatalf("want HTTP 200, got %d", w.Code)
+		}
+	}
+}
+
+func BenchmarkGetProducts(b *testing.B) {
+	benchmarkGet(b, "/products")
+}
+
+func BenchmarkGetProduct(b *testing.B) {
+	benchmarkGet(b, "/products/1")
+}
+
+func BenchmarkGetCoffee(b *testing.B) {
+	benchmarkGet(b, "/products/coffee")
+}
+
+func BenchmarkGetCategories(b *testing.B) {
+	benchmarkGet(b, "/categories")
+}
+
+func BenchmarkMemoryStoreGetAll(b *testing.B) {
+	store, err := coffeeshop.OpenStore("memory://")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = store.GetAll()
+	}
+}
@@ -0,0 +1,473 @@
+package coffeeshop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStoreUnavailable is returned by calls of the store rejected
+// by the open circuit breaker.
+var ErrStoreUnavailable = errors.New("store unavailable")
+
+// BreakerState is the state of the circuit breaker.
+type BreakerState string
+
+// States of the circuit breaker.
+const (
+	// BreakerClosed lets calls through to the store.
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen rejects calls until the cooldown passes.
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets a single probe call through. The breaker
+	// closes if the probe succeeds, and opens again if it fails.
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// BreakerStore wraps calls of the store in a circuit breaker. After
+// Threshold consecutive failures of the store the breaker opens and
+// rejects calls with ErrStoreUnavailable for the Cooldown, so clients
+// don't wait for a backend which is down. Then a single probe call
+// is let through to check whether the backend recovered.
+//
+// Failures are errors other than errors about products or stock,
+// like ErrProductNotFound, returned by the store. GetAll and GetByType
+// don't return errors, so they can't fail the store, and return no
+// products when rejected.
+type BreakerStore struct {
+	Store     Store
+	Threshold int
+	Cooldown  time.Duration
+	// Clock times the cooldown. Nil means the real clock.
+	Clock Clock
+
+	mx       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	opened   atomic.Int64
+	rejected atomic.Int64
+	// parent holds the state of stores returned by WithContext.
+	parent *BreakerStore
+}
+
+// NewBreakerStore returns the store opening the breaker after the
+// threshold of consecutive failures of the store for the cooldown.
+func NewBreakerStore(store Store, threshold int, cooldown time.Duration) *BreakerStore {
+	return &BreakerStore{Store: store, Threshold: threshold, Cooldown: cooldown}
+}
+
+// WithContext returns the store sharing the breaker with the store
+// and calling the store behind it with the context, if it's
+// a ContextStore.
+func (b *BreakerStore) WithContext(ctx context.Context) Store {
+	return &BreakerStore{
+		Store:     withContext(b.Store, ctx),
+		Threshold: b.Threshold,
+		Cooldown:  b.Cooldown,
+		Clock:     b.Clock,
+		parent:    b.base(),
+	}
+}
+
+// base returns the store holding the state of the breaker.
+func (b *BreakerStore) base() *BreakerStore {
+	if b.parent != nil {
+		return b.parent
+	}
+	return b
+}
+
+// State returns the state of the breaker. The open breaker
+// is half-open once the cooldown passes.
+func (b *BreakerStore) State() BreakerState {
+	b = b.base()
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	return b.currentState()
+}
+
+func (b *BreakerStore) currentState() BreakerState {
+	if b.state == BreakerOpen && !now(b.Clock).Before(b.openedAt.Add(b.Cooldown)) {
+		return BreakerHalfOpen
+	}
+	if b.state == "" {
+		return BreakerClosed
+	}
+	return b.state
+}
+
+// allows reports whether the breaker lets calls through
+// without admitting a call.
+func (b *BreakerStore) allows() bool {
+	b = b.base()
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	switch b.currentState() {
+	case BreakerClosed:
+		return true
+	case BreakerHalfOpen:
+		// No probe is in flight yet.
+		return b.state == BreakerOpen
+	}
+	return false
+}
+
+// admit reports whether the call can go through. The first call
+// admitted to the half-open breaker is the probe, and other calls
+// are rejected until the probe returns.
+func (b *BreakerStore) admit() bool {
+	b = b.base()
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	switch b.currentState() {
+	case BreakerClosed:
+		return true
+	case BreakerHalfOpen:
+		if b.state == BreakerOpen {
+			b.state = BreakerHalfOpen
+			return true
+		}
+	}
+	b.rejected.Add(1)
+	return false
+}
+
+// done records the result of the admitted call.
+func (b *BreakerStore) done(err error) {
+	b = b.base()
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if b.state == BreakerOpen {
+		// The call was admitted before the breaker opened.
+		return
+	}
+	if !storeFailure(err) {
+		b.failures = 0
+		b.state = BreakerClosed
+		return
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.Threshold {
+		b.state = BreakerOpen
+		b.openedAt = now(b.Clock)
+		b.failures = 0
+		b.opened.Add(1)
+	}
+}
+
+// storeFailure reports whether the error shows that
+// the backend of the store failed.
+func storeFailure(err error) bool {
+	if err == nil || errors.Is(err, ErrStoreUnavailable) {
+		return false
+	}
+	var stockErr *OutOfStockError
+	if errors.As(err, &stockErr) {
+		return false
+	}
+	for _, m := range errorMappings {
+		if m.err != ErrStoreTimeout && errors.Is(err, m.err) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetAll returns all products, or no products if the breaker is open.
+func (b *BreakerStore) GetAll() []Product {
+	if !b.admit() {
+		return nil
+	}
+	defer b.done(nil)
+	return b.Store.GetAll()
+}
+
+// GetByType returns products of the type, or no products
+// if the breaker is open.
+func (b *BreakerStore) GetByType(productType string) []Product {
+	if !b.admit() {
+		return nil
+	}
+	defer b.done(nil)
+	return b.Store.GetByType(productType)
+}
+
+// GetProduct returns the product with the given ID from the store.
+func (b *BreakerStore) GetProduct(id string) (Product, error) {
+	if !b.admit() {
+		return Product{}, ErrStoreUnavailable
+	}
+	p, err := b.Store.GetProduct(id)
+	b.done(err)
+	return p, err
+}
+
+// ListAll returns all products from the store.
+func (b *BreakerStore) ListAll() ([]Product, error) {
+	if !b.admit() {
+		return nil, ErrStoreUnavailable
+	}
+	px, err := listAll(b.Store)
+	b.done(err)
+	return px, err
+}
+
+// ListByType returns products of the type from the store.
+func (b *BreakerStore) ListByType(productType string) ([]Product, error) {
+	if !b.admit() {
+		return nil, ErrStoreUnavailable
+	}
+	px, err := listByType(b.Store, productType)
+	b.done(err)
+	return px, err
+}
+
+// GetMany returns products with the given IDs from the store.
+func (b *BreakerStore) GetMany(ids []string) ([]Product, error) {
+	if !b.admit() {
+		return nil, ErrStoreUnavailable
+	}
+	px, err := getMany(b.Store, ids)
+	b.done(err)
+	return px, err
+}
+
+// GetBySKU returns the product with the SKU or EAN from the store.
+func (b *BreakerStore) GetBySKU(code string) (Product, error) {
+	if !b.admit() {
+		return Product{}, ErrStoreUnavailable
+	}
+	p, err := productBySKU(b.Store, code)
+	b.done(err)
+	return p, err
+}
+
+// ProductStats returns statistics of products in the store.
+func (b *BreakerStore) ProductStats() (ProductStats, error) {
+	if !b.admit() {
+		return ProductStats{}, ErrStoreUnavailable
+	}
+	stats, err := storeStats(b.Store)
+	b.done(err)
+	return stats, err
+}
+
+// ReserveStock reserves stock in the store.
+func (b *BreakerStore) ReserveStock(items []OrderItem) error {
+	if !b.admit() {
+		return ErrStoreUnavailable
+	}
+	err := b.Store.ReserveStock(items)
+	b.done(err)
+	return err
+}
+
+// ReleaseStock releases stock in the store. It fails if the
+// store isn't a StockReleaser.
+func (b *BreakerStore) ReleaseStock(items []OrderItem) error {
+	sr, ok := storeAs[StockReleaser](b.Store)
+	if !ok {
+		return fmt.Errorf("store %T doesn't support releasing stock", b.Store)
+	}
+	return b.call(func() error { return sr.ReleaseStock(items) })
+}
+
+// SetStock sets stock in the store.
+func (b *BreakerStore) SetStock(id string, stock int) (Product, error) {
+	if !b.admit() {
+		return Product{}, ErrStoreUnavailable
+	}
+	p, err := b.Store.SetStock(id, stock)
+	b.done(err)
+	return p, err
+}
+
+// PutProduct puts the product into the store. It fails
+// if the store isn't an Importer.
+func (b *BreakerStore) PutProduct(p Product) (Product, error) {
+	importer, ok := storeAs[Importer](b.Store)
+	if !ok {
+		return Product{}, fmt.Errorf("store %T doesn't support imports", b.Store)
+	}
+	if !b.admit() {
+		return Product{}, ErrStoreUnavailable
+	}
+	p, err := importer.PutProduct(p)
+	b.done(err)
+	return p, err
+}
+
+// Add adds the product to the store. It fails if the
+// store isn't a ProductWriter.
+func (b *BreakerStore) Add(p Product) (Product, error) {
+	pw, ok := storeAs[ProductWriter](b.Store)
+	if !ok {
+		return Product{}, fmt.Errorf("store %T doesn't support writes", b.Store)
+	}
+	var added Product
+	err := b.call(func() (err error) {
+		added, err = pw.Add(p)
+		return err
+	})
+	return added, err
+}
+
+// Update updates the product in the store. It fails if the
+// store isn't a ProductWriter.
+func (b *BreakerStore) Update(p Product) (Product, error) {
+	pw, ok := storeAs[ProductWriter](b.Store)
+	if !ok {
+		return Product{}, fmt.Errorf("store %T doesn't support writes", b.Store)
+	}
+	var updated Product
+	err := b.call(func() (err error) {
+		updated, err = pw.Update(p)
+		return err
+	})
+	return updated, err
+}
+
+// Replace replaces the product in the store. It fails if the
+// store isn't a Replacer.
+func (b *BreakerStore) Replace(id string, write func(old Product, exists bool) (Product, error)) (old, product Product, err error) {
+	rp, ok := storeAs[Replacer](b.Store)
+	if !ok {
+		return Product{}, Product{}, fmt.Errorf("store %T doesn't support replacing products", b.Store)
+	}
+	err = b.call(func() (err error) {
+		old, product, err = rp.Replace(id, write)
+		return err
+	})
+	return old, product, err
+}
+
+// Delete deletes the product from the store. It fails if the
+// store isn't a ProductWriter.
+func (b *BreakerStore) Delete(id string) error {
+	pw, ok := storeAs[ProductWriter](b.Store)
+	if !ok {
+		return fmt.Errorf("store %T doesn't support writes", b.Store)
+	}
+	return b.call(func() error { return pw.Delete(id) })
+}
+
+// PriceHistory returns prices of the product from the store. It
+// fails if the store isn't a PriceHistorian.
+func (b *BreakerStore) PriceHistory(id string) ([]PricePoint, error) {
+	ph, ok := storeAs[PriceHistorian](b.Store)
+	if !ok {
+		return nil, fmt.Errorf("store %T doesn't support price history", b.Store)
+	}
+	var history []PricePoint
+	err := b.call(func() (err error) {
+		history, err = ph.PriceHistory(id)
+		return err
+	})
+	return history, err
+}
+
+// Changes returns changes of products since the cursor from the
+// store. It fails if the store isn't a Syncer.
+func (b *BreakerStore) Changes(cursor string) (SyncDelta, error) {
+	s, ok := storeAs[Syncer](b.Store)
+	if !ok {
+		return SyncDelta{}, fmt.Errorf("store %T doesn't support sync", b.Store)
+	}
+	var delta SyncDelta
+	err := b.call(func() (err error) {
+		delta, err = s.Changes(cursor)
+		return err
+	})
+	return delta, err
+}
+
+// call makes the call unless the breaker rejects it,
+// and records its result.
+func (b *BreakerStore) call(f func() error) error {
+	if !b.admit() {
+		return ErrStoreUnavailable
+	}
+	err := f()
+	b.done(err)
+	return err
+}
+
+// Unwrap returns the store behind the breaker. Optional interfaces
+// of the store not implemented by the BreakerStore, like Notifier,
+// are used regardless of the state of the breaker.
+func (b *BreakerStore) Unwrap() Store {
+	return b.Store
+}
+
+// Ping reports whether the backend of the store is reachable,
+// regardless of the state of the breaker.
+func (b *BreakerStore) Ping(ctx context.Context) error {
+	if p, ok := b.Store.(Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (b *BreakerStore) useClock(clock Clock) {
+	if b.Clock == nil {
+		b.Clock = clock
+	}
+	if u, ok := b.Store.(clockUser); ok {
+		u.useClock(clock)
+	}
+}
+
+// BreakerStats describes the circuit breaker.
+type BreakerStats struct {
+	State BreakerState `json:"state"`
+	// Opened counts how many times the breaker opened.
+	Opened int64 `json:"opened"`
+	// Rejected counts calls rejected by the breaker.
+	Rejected int64 `json:"rejected"`
+}
+
+// Stats returns the state and counters of the breaker.
+func (b *BreakerStore) Stats() BreakerStats {
+	b = b.base()
+	return BreakerStats{
+		State:    b.State(),
+		Opened:   b.opened.Load(),
+		Rejected: b.rejected.Load(),
+	}
+}
+
+// WithCircuitBreaker wraps the store in a BreakerStore opening after
+// the threshold of consecutive failures of the store, for the cooldown,
+// for example "10s". The store is wrapped after all options are
+// applied, under the cache if enabled.
+func WithCircuitBreaker(threshold int, cooldown string) Option {
+	return func(s *Server) error {
+		d, err := time.ParseDuration(cooldown)
+		if err != nil {
+			return err
+		}
+		if threshold <= 0 || d <= 0 {
+			return errors.New("breaker threshold and cooldown must be positive")
+		}
+		s.BreakerThreshold = threshold
+		s.BreakerCooldown = d
+		return nil
+	}
+}
+
+// useBreaker wraps the store of the server in a BreakerStore.
+func (cs *Server) useBreaker() {
+	if cs.BreakerThreshold <= 0 {
+		return
+	}
+	if b, ok := cs.Store.(*BreakerStore); ok {
+		cs.breaker = b
+		return
+	}
+	cs.breaker = NewBreakerStore(cs.Store, cs.BreakerThreshold, cs.BreakerCooldown)
+	cs.Store = cs.breaker
+}
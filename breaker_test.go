@@ -0,0 +1,148 @@
+package coffeeshop_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// failingStore fails reads of products while it's down.
+type failingStore struct {
+	*coffeeshop.MemoryStore
+	down  atomic.Bool
+	calls atomic.Int64
+}
+
+func (s *failingStore) GetProduct(id string) (coffeeshop.Product, error) {
+	s.calls.Add(1)
+	if s.down.Load() {
+		return coffeeshop.Product{}, errors.New("connection refused")
+	}
+	return s.MemoryStore.GetProduct(id)
+}
+
+func TestServer_OpensCircuitBreakerAfterStoreFailures(t *testing.T) {
+	t.Parallel()
+
+	store := &failingStore{MemoryStore: &coffeeshop.MemoryStore{Products: inventory}}
+	store.down.Store(true)
+	shop := newCoffeShopTestServer(store, "0s", t, coffeeshop.WithCircuitBreaker(2, "1m"))
+	for i := 0; i < 2; i++ {
+		if status, _ := getResponse(t, shop.URL+"products/1"); status != http.StatusInternalServerError {
+			t.Fatalf("want HTTP 500 from failing store, got %d", status)
+		}
+	}
+	for _, path := range []string{"products/1", "products"} {
+		status, body := getResponse(t, shop.URL+path)
+		if status != http.StatusServiceUnavailable || !strings.Contains(body, "store_unavailable") {
+			t.Errorf("GET /%s: want HTTP 503 store_unavailable, got %d %s", path, status, body)
+		}
+	}
+	if got := store.calls.Load(); got != 2 {
+		t.Errorf("want 2 calls reaching the store, got %d", got)
+	}
+	_, body := getHealth(t, shop.URL+"healthz")
+	if checks, _ := body["checks"].(map[string]any); checks["store_breaker"] != "open" {
+		t.Errorf("want open breaker reported by /healthz, got %v", body)
+	}
+	_, metrics := getResponse(t, shop.URL+"metrics")
+	if !strings.Contains(metrics, `coffeeshop_store_breaker_state{state="open"} 1`) {
+		t.Errorf("want open breaker in metrics, got:\n%s", metrics)
+	}
+}
+
+func TestServer_ClosesCircuitBreakerAfterSuccessfulProbe(t *testing.T) {
+	t.Parallel()
+
+	clock := coffeeshop.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := &failingStore{MemoryStore: &coffeeshop.MemoryStore{Products: inventory}}
+	store.down.Store(true)
+	shop := newCoffeShopTestServer(store, "0s", t,
+		coffeeshop.WithClock(clock),
+		coffeeshop.WithCircuitBreaker(1, "1m"),
+	)
+	getResponse(t, shop.URL+"products/1")
+	if status, _ := getResponse(t, shop.URL+"products/1"); status != http.StatusServiceUnavailable {
+		t.Fatalf("want HTTP 503 from open breaker, got %d", status)
+	}
+	store.down.Store(false)
+	clock.Advance(time.Minute)
+	if status, _ := getResponse(t, shop.URL+"products/1"); status != http.StatusOK {
+		t.Fatalf("want HTTP 200 from probe, got %d", status)
+	}
+	_, body := getHealth(t, shop.URL+"healthz")
+	if checks, _ := body["checks"].(map[string]any); checks["store_breaker"] != "closed" {
+		t.Errorf("want closed breaker, got %v", body)
+	}
+}
+
+func TestBreakerStore_IgnoresProductErrors(t *testing.T) {
+	t.Parallel()
+
+	b := coffeeshop.NewBreakerStore(&coffeeshop.MemoryStore{Products: inventory}, 1, time.Minute)
+	for i := 0; i < 3; i++ {
+		if _, err := b.GetProduct("missing"); !errors.Is(err, coffeeshop.ErrProductNotFound) {
+			t.Fatalf("want ErrProductNotFound, got %v", err)
+		}
+	}
+	if got := b.State(); got != coffeeshop.BreakerClosed {
+		t.Errorf("want closed breaker, got %s", got)
+	}
+}
+
+// wantOptionalStoreRoutes checks that routes using optional
+// interfaces of the memory store work through its decorators.
+func wantOptionalStoreRoutes(t *testing.T, url string) {
+	t.Helper()
+	for _, path := range []string{"products/2/price-history", "sync", "events"} {
+		req, err := http.NewRequest(http.MethodGet, url+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET /%s: want HTTP 200, got %d", path, resp.StatusCode)
+		}
+	}
+	resp := send(t, http.MethodDelete, url+"products/1")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE /products/1: want HTTP 204, got %d", resp.StatusCode)
+	}
+	if ids := listedIDs(t, url+"products"); contains(ids, "1") {
+		t.Errorf("want deleted product 1 not listed, got %v", ids)
+	}
+}
+
+func TestServer_UsesOptionalStoreInterfacesThroughBreaker(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(5)}, "0s", t, coffeeshop.WithCircuitBreaker(2, "1m"))
+	wantOptionalStoreRoutes(t, shop.URL)
+}
+
+func TestBreakerStore_RejectsWritesWhileOpen(t *testing.T) {
+	t.Parallel()
+
+	store := &failingStore{MemoryStore: &coffeeshop.MemoryStore{Products: stockedInventory(5)}}
+	store.down.Store(true)
+	breaker := coffeeshop.NewBreakerStore(store, 1, time.Minute)
+	if _, err := breaker.GetProduct("1"); err == nil {
+		t.Fatal("want error from failing store")
+	}
+	if err := breaker.Delete("1"); !errors.Is(err, coffeeshop.ErrStoreUnavailable) {
+		t.Errorf("want ErrStoreUnavailable, got %v", err)
+	}
+	if _, err := store.MemoryStore.GetProduct("1"); err != nil {
+		t.Errorf("want product kept by rejected delete, got %v", err)
+	}
+}
@@ -0,0 +1,411 @@
+package coffeeshop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CachingStore caches products read with GetAll and GetByType for
+// the TTL, so catalog reads don't reach the backend of the store on
+// every request. Changes made through the CachingStore invalidate the
+// cache. Changes made directly in the backend become visible when
+// cached products expire. Concurrent reads missing the cache
+// share a single read of the backend.
+type CachingStore struct {
+	Store Store
+	TTL   time.Duration
+	// Clock expires cached products. Nil means the real clock.
+	Clock Clock
+
+	mx         sync.Mutex
+	entries    map[string]cacheEntry
+	inFlight   map[string]*cacheLoad
+	generation uint64
+	hits       atomic.Int64
+	misses     atomic.Int64
+	shared     atomic.Int64
+	// parent holds the cache of stores returned by WithContext.
+	parent *CachingStore
+}
+
+type cacheEntry struct {
+	products []Product
+	expires  time.Time
+}
+
+// cacheLoad is a read of the backend shared by concurrent reads of
+// products under the same key. Generation is the generation of the
+// cache when the read started.
+type cacheLoad struct {
+	done       chan struct{}
+	products   []Product
+	err        error
+	generation uint64
+}
+
+// NewCachingStore returns the store caching products of the
+// store for the TTL.
+func NewCachingStore(store Store, ttl time.Duration) *CachingStore {
+	return &CachingStore{Store: store, TTL: ttl}
+}
+
+// WithContext returns the store sharing the cache with the store and
+// calling the cached store with the context, if it's a ContextStore.
+func (c *CachingStore) WithContext(ctx context.Context) Store {
+	return &CachingStore{Store: withContext(c.Store, ctx), TTL: c.TTL, Clock: c.Clock, parent: c.base()}
+}
+
+// base returns the store holding the cache.
+func (c *CachingStore) base() *CachingStore {
+	if c.parent != nil {
+		return c.parent
+	}
+	return c
+}
+
+// cacheKeyAll is the key of all products. Keys of
+// products of a type are prefixed with "type:".
+const cacheKeyAll = "all"
+
+// GetAll returns all products, cached for the TTL.
+func (c *CachingStore) GetAll() []Product {
+	px, _ := c.ListAll()
+	return px
+}
+
+// GetByType returns products of the type, cached for the TTL.
+func (c *CachingStore) GetByType(productType string) []Product {
+	px, _ := c.ListByType(productType)
+	return px
+}
+
+// ListAll returns all products, cached for the TTL.
+// Failed reads of the store aren't cached.
+func (c *CachingStore) ListAll() ([]Product, error) {
+	return c.cached(cacheKeyAll, func() ([]Product, error) {
+		return listAll(c.Store)
+	})
+}
+
+// ListByType returns products of the type, cached for the TTL.
+// Failed reads of the store aren't cached.
+func (c *CachingStore) ListByType(productType string) ([]Product, error) {
+	return c.cached("type:"+strings.ToLower(productType), func() ([]Product, error) {
+		return listByType(c.Store, productType)
+	})
+}
+
+// GetProduct returns the product with the given ID from the store.
+func (c *CachingStore) GetProduct(id string) (Product, error) {
+	return c.Store.GetProduct(id)
+}
+
+// GetMany returns products with the given IDs from the store.
+func (c *CachingStore) GetMany(ids []string) ([]Product, error) {
+	return getMany(c.Store, ids)
+}
+
+// GetBySKU returns the product with the SKU or EAN from the store.
+func (c *CachingStore) GetBySKU(code string) (Product, error) {
+	return productBySKU(c.Store, code)
+}
+
+// ProductStats returns statistics of products in the store.
+func (c *CachingStore) ProductStats() (ProductStats, error) {
+	return storeStats(c.Store)
+}
+
+// Changes returns changes of products since the cursor from the
+// store, bypassing the cache. It fails if the store isn't a Syncer.
+func (c *CachingStore) Changes(cursor string) (SyncDelta, error) {
+	s, ok := storeAs[Syncer](c.Store)
+	if !ok {
+		return SyncDelta{}, fmt.Errorf("store %T doesn't support sync", c.Store)
+	}
+	return s.Changes(cursor)
+}
+
+// ReserveStock reserves stock in the store and invalidates the cache.
+func (c *CachingStore) ReserveStock(items []OrderItem) error {
+	defer c.Invalidate()
+	return c.Store.ReserveStock(items)
+}
+
+// ReleaseStock releases stock in the store and invalidates the
+// cache. It fails if the store isn't a StockReleaser.
+func (c *CachingStore) ReleaseStock(items []OrderItem) error {
+	sr, ok := storeAs[StockReleaser](c.Store)
+	if !ok {
+		return fmt.Errorf("store %T doesn't support releasing stock", c.Store)
+	}
+	defer c.Invalidate()
+	return sr.ReleaseStock(items)
+}
+
+// SetStock sets stock in the store and invalidates the cache.
+func (c *CachingStore) SetStock(id string, stock int) (Product, error) {
+	defer c.Invalidate()
+	return c.Store.SetStock(id, stock)
+}
+
+// PutProduct puts the product into the store and invalidates
+// the cache. It fails if the store isn't an Importer.
+func (c *CachingStore) PutProduct(p Product) (Product, error) {
+	importer, ok := storeAs[Importer](c.Store)
+	if !ok {
+		return Product{}, fmt.Errorf("store %T doesn't support imports", c.Store)
+	}
+	defer c.Invalidate()
+	return importer.PutProduct(p)
+}
+
+// Add adds the product to the store and invalidates the cache.
+// It fails if the store isn't a ProductWriter.
+func (c *CachingStore) Add(p Product) (Product, error) {
+	pw, err := c.writer()
+	if err != nil {
+		return Product{}, err
+	}
+	defer c.Invalidate()
+	return pw.Add(p)
+}
+
+// Update updates the product in the store and invalidates the cache.
+// It fails if the store isn't a ProductWriter.
+func (c *CachingStore) Update(p Product) (Product, error) {
+	pw, err := c.writer()
+	if err != nil {
+		return Product{}, err
+	}
+	defer c.Invalidate()
+	return pw.Update(p)
+}
+
+// Replace replaces the product in the store and invalidates the
+// cache. It fails if the store isn't a Replacer.
+func (c *CachingStore) Replace(id string, write func(old Product, exists bool) (Product, error)) (old, product Product, err error) {
+	rp, ok := storeAs[Replacer](c.Store)
+	if !ok {
+		return Product{}, Product{}, fmt.Errorf("store %T doesn't support replacing products", c.Store)
+	}
+	defer c.Invalidate()
+	return rp.Replace(id, write)
+}
+
+// Delete deletes the product from the store and invalidates the
+// cache. It fails if the store isn't a ProductWriter.
+func (c *CachingStore) Delete(id string) error {
+	pw, err := c.writer()
+	if err != nil {
+		return err
+	}
+	defer c.Invalidate()
+	return pw.Delete(id)
+}
+
+func (c *CachingStore) writer() (ProductWriter, error) {
+	pw, ok := storeAs[ProductWriter](c.Store)
+	if !ok {
+		return nil, fmt.Errorf("store %T doesn't support writes", c.Store)
+	}
+	return pw, nil
+}
+
+// ResetProducts replaces products of the store
+// and invalidates the cache.
+func (c *CachingStore) ResetProducts(products Products) {
+	defer c.Invalidate()
+	if r, ok := storeAs[Resetter](c.Store); ok {
+		r.ResetProducts(products)
+	}
+}
+
+func (c *CachingStore) saveState() any {
+	s, ok := storeAs[stateStore](c.Store)
+	if !ok {
+		return nil
+	}
+	return s.saveState()
+}
+
+func (c *CachingStore) restoreState(state any) {
+	defer c.Invalidate()
+	if s, ok := storeAs[stateStore](c.Store); ok {
+		s.restoreState(state)
+	}
+}
+
+// Unwrap returns the cached store. Optional interfaces of the store
+// not implemented by the CachingStore, like Notifier, are used
+// without the cache.
+func (c *CachingStore) Unwrap() Store {
+	return c.Store
+}
+
+// Ping reports whether the backend of the store is reachable.
+func (c *CachingStore) Ping(ctx context.Context) error {
+	if p, ok := c.Store.(Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// Invalidate drops all cached products.
+func (c *CachingStore) Invalidate() {
+	c = c.base()
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.entries = nil
+	c.generation++
+}
+
+// cached returns copies of cached products under the key. On a miss
+// it returns products returned by load, which are cached unless load
+// fails or the cache was invalidated since the load started.
+// Concurrent misses of the key wait for the load started in the same
+// generation instead of calling load again, so reads following
+// a change never share a load that started before it.
+func (c *CachingStore) cached(key string, load func() ([]Product, error)) ([]Product, error) {
+	c = c.base()
+	now := now(c.Clock)
+	c.mx.Lock()
+	if e, ok := c.entries[key]; ok && now.Before(e.expires) {
+		c.mx.Unlock()
+		c.hits.Add(1)
+		return cloneProducts(append([]Product(nil), e.products...)), nil
+	}
+	if l, ok := c.inFlight[key]; ok && l.generation == c.generation {
+		c.mx.Unlock()
+		c.shared.Add(1)
+		<-l.done
+		if l.err != nil {
+			return nil, l.err
+		}
+		return cloneProducts(append([]Product(nil), l.products...)), nil
+	}
+	l := &cacheLoad{done: make(chan struct{}), generation: c.generation}
+	if c.inFlight == nil {
+		c.inFlight = make(map[string]*cacheLoad)
+	}
+	c.inFlight[key] = l
+	c.mx.Unlock()
+	c.misses.Add(1)
+
+	defer func() {
+		c.mx.Lock()
+		if c.inFlight[key] == l {
+			delete(c.inFlight, key)
+		}
+		if c.generation == l.generation && l.err == nil && l.products != nil {
+			if c.entries == nil {
+				c.entries = make(map[string]cacheEntry)
+			}
+			c.entries[key] = cacheEntry{products: l.products, expires: now.Add(c.TTL)}
+		}
+		c.mx.Unlock()
+		close(l.done)
+	}()
+	px, err := load()
+	if err != nil {
+		l.err = err
+		return nil, err
+	}
+	l.products = cloneProducts(append(make([]Product, 0, len(px)), px...))
+	return px, nil
+}
+
+func (c *CachingStore) useClock(clock Clock) {
+	if c.Clock == nil {
+		c.Clock = clock
+	}
+	if u, ok := c.Store.(clockUser); ok {
+		u.useClock(clock)
+	}
+}
+
+// CacheStats describes the effectiveness of the cache.
+type CacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	// Shared counts misses served by a concurrent read
+	// of the backend instead of reading it again.
+	Shared int64 `json:"shared"`
+	// HitRatio is the fraction of reads served from the cache.
+	HitRatio float64 `json:"hitRatio"`
+	Entries  int     `json:"entries"`
+	TTL      string  `json:"ttl"`
+}
+
+// Stats returns hits and misses of the cache so far.
+func (c *CachingStore) Stats() CacheStats {
+	c = c.base()
+	c.mx.Lock()
+	entries := len(c.entries)
+	c.mx.Unlock()
+	s := CacheStats{
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Shared:  c.shared.Load(),
+		Entries: entries,
+		TTL:     c.TTL.String(),
+	}
+	if total := s.Hits + s.Misses + s.Shared; total > 0 {
+		s.HitRatio = float64(s.Hits) / float64(total)
+	}
+	return s
+}
+
+// WithCache caches products read from the store for the TTL,
+// for example "30s". The store is wrapped in a CachingStore
+// after all options are applied. Zero TTL disables the cache.
+func WithCache(ttl string) Option {
+	return func(s *Server) error {
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			return err
+		}
+		if d < 0 {
+			return errors.New("negative cache TTL")
+		}
+		s.CacheTTL = d
+		return nil
+	}
+}
+
+// useCache wraps the store of the server in a CachingStore.
+func (cs *Server) useCache() {
+	if cs.CacheTTL <= 0 {
+		return
+	}
+	if _, ok := cs.Store.(*CachingStore); ok {
+		return
+	}
+	cs.Store = NewCachingStore(cs.Store, cs.CacheTTL)
+}
+
+// GetCacheStats reports hits and misses of the cache of products.
+func (cs *Server) GetCacheStats(w http.ResponseWriter, r *http.Request) {
+	c, ok := cs.Store.(*CachingStore)
+	if !ok {
+		writeError(w, r, http.StatusNotImplemented, "cache is disabled")
+		return
+	}
+	writeJSON(w, r, http.StatusOK, c.Stats())
+}
+
+// InvalidateCache drops products cached by the server.
+func (cs *Server) InvalidateCache(w http.ResponseWriter, r *http.Request) {
+	c, ok := cs.Store.(*CachingStore)
+	if !ok {
+		writeError(w, r, http.StatusNotImplemented, "cache is disabled")
+		return
+	}
+	c.Invalidate()
+	w.WriteHeader(http.StatusNoContent)
+}
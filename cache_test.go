@@ -0,0 +1,258 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// countingStore counts reads reaching the memory store.
+type countingStore struct {
+	*coffeeshop.MemoryStore
+	reads atomic.Int64
+	// gate, if not nil, holds reads until it's closed.
+	gate chan struct{}
+}
+
+func (s *countingStore) GetAll() []coffeeshop.Product {
+	s.reads.Add(1)
+	if s.gate != nil {
+		<-s.gate
+	}
+	return s.MemoryStore.GetAll()
+}
+
+func (s *countingStore) GetByType(productType string) []coffeeshop.Product {
+	s.reads.Add(1)
+	return s.MemoryStore.GetByType(productType)
+}
+
+func TestCachingStore_ServesRepeatedReadsFromCache(t *testing.T) {
+	t.Parallel()
+
+	backend := &countingStore{MemoryStore: &coffeeshop.MemoryStore{Products: stockedInventory(5)}}
+	store := coffeeshop.NewCachingStore(backend, time.Minute)
+	for i := 0; i < 3; i++ {
+		store.GetAll()
+		store.GetByType("coffee")
+	}
+	if got := backend.reads.Load(); got != 2 {
+		t.Errorf("want 2 reads of the backend, got %d", got)
+	}
+	stats := store.Stats()
+	if stats.Hits != 4 || stats.Misses != 2 {
+		t.Errorf("want 4 hits and 2 misses, got %+v", stats)
+	}
+}
+
+func TestCachingStore_SharesReadOfBackendBetweenConcurrentMisses(t *testing.T) {
+	t.Parallel()
+
+	backend := &countingStore{
+		MemoryStore: &coffeeshop.MemoryStore{Products: stockedInventory(5)},
+		gate:        make(chan struct{}),
+	}
+	store := coffeeshop.NewCachingStore(backend, time.Minute)
+	const readers = 10
+	var wg sync.WaitGroup
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := len(store.GetAll()); got != len(inventory) {
+				t.Errorf("want %d products, got %d", len(inventory), got)
+			}
+		}()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for store.Stats().Shared < readers-1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(backend.gate)
+	wg.Wait()
+	if got := backend.reads.Load(); got != 1 {
+		t.Errorf("want 1 read of the backend, got %d", got)
+	}
+	if got := store.Stats().Shared; got != readers-1 {
+		t.Errorf("want %d shared reads, got %d", readers-1, got)
+	}
+}
+
+func TestCachingStore_InvalidatesCacheOnStockChange(t *testing.T) {
+	t.Parallel()
+
+	backend := &countingStore{MemoryStore: &coffeeshop.MemoryStore{Products: stockedInventory(5)}}
+	store := coffeeshop.NewCachingStore(backend, time.Minute)
+	store.GetAll()
+	if _, err := store.SetStock("1", 42); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range store.GetAll() {
+		if p.ID == "1" && p.Stock != 42 {
+			t.Errorf("want stock 42 after change, got %d", p.Stock)
+		}
+	}
+	if got := backend.reads.Load(); got != 2 {
+		t.Errorf("want 2 reads of the backend, got %d", got)
+	}
+}
+
+// staleReadStore holds the first read of products after
+// reading them, until the gate is closed.
+type staleReadStore struct {
+	*coffeeshop.MemoryStore
+	reads atomic.Int64
+	read  chan struct{}
+	gate  chan struct{}
+}
+
+func (s *staleReadStore) GetAll() []coffeeshop.Product {
+	px := s.MemoryStore.GetAll()
+	if s.reads.Add(1) == 1 {
+		close(s.read)
+		<-s.gate
+	}
+	return px
+}
+
+func TestCachingStore_DoesNotServeReadStartedBeforeChange(t *testing.T) {
+	t.Parallel()
+
+	backend := &staleReadStore{
+		MemoryStore: &coffeeshop.MemoryStore{Products: stockedInventory(5)},
+		read:        make(chan struct{}),
+		gate:        make(chan struct{}),
+	}
+	store := coffeeshop.NewCachingStore(backend, time.Minute)
+	stale := make(chan []coffeeshop.Product)
+	go func() { stale <- store.GetAll() }()
+	<-backend.read
+	if _, err := store.SetStock("1", 42); err != nil {
+		t.Fatal(err)
+	}
+
+	fresh := make(chan []coffeeshop.Product)
+	go func() { fresh <- store.GetAll() }()
+	var px []coffeeshop.Product
+	select {
+	case px = <-fresh:
+		close(backend.gate)
+	case <-time.After(time.Second):
+		t.Error("want read after change not to wait for read started before it")
+		close(backend.gate)
+		px = <-fresh
+	}
+	<-stale
+	for _, p := range append(px, store.GetAll()...) {
+		if p.ID == "1" && p.Stock != 42 {
+			t.Errorf("want stock 42 after change, got %d", p.Stock)
+		}
+	}
+}
+
+func TestCachingStore_ExpiresProductsAfterTTL(t *testing.T) {
+	t.Parallel()
+
+	clock := coffeeshop.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	backend := &countingStore{MemoryStore: &coffeeshop.MemoryStore{Products: stockedInventory(5)}}
+	store := coffeeshop.NewCachingStore(backend, time.Minute)
+	store.Clock = clock
+	store.GetAll()
+	clock.Advance(59 * time.Second)
+	store.GetAll()
+	clock.Advance(time.Second)
+	store.GetAll()
+	if got := backend.reads.Load(); got != 2 {
+		t.Errorf("want 2 reads of the backend, got %d", got)
+	}
+}
+
+func TestServer_ReportsCacheHitsAtAdminCache(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t, coffeeshop.WithCache("1m"))
+	for i := 0; i < 3; i++ {
+		resp, err := http.Get(shop.URL + "products")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	resp, err := http.Get(shop.URL + "admin/cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200, got %d", resp.StatusCode)
+	}
+	var got coffeeshop.CacheStats
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Hits != 2 || got.Misses != 1 || got.TTL != "1m0s" {
+		t.Errorf("want 2 hits, 1 miss and TTL 1m0s, got %+v", got)
+	}
+}
+
+func TestServer_RespondsNotImplementedAtAdminCacheWithoutCache(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t)
+	resp, err := http.Get(shop.URL + "admin/cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Errorf("want HTTP 501, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_WritesProductsThroughCache(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(5)}, "0s", t, coffeeshop.WithCache("1m"))
+	if ids := listedIDs(t, shop.URL+"products"); !contains(ids, "1") {
+		t.Fatalf("want product 1 listed, got %v", ids)
+	}
+	resp := send(t, http.MethodDelete, shop.URL+"products/1")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("want HTTP 204, got %d", resp.StatusCode)
+	}
+	if ids := listedIDs(t, shop.URL+"products"); contains(ids, "1") {
+		t.Errorf("want deleted product 1 not listed from the cache, got %v", ids)
+	}
+	for _, path := range []string{"products/2/price-history", "events"} {
+		req, err := http.NewRequest(http.MethodGet, shop.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s: want HTTP 200 with the cache, got %d", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestServer_RespondsNotImplementedToWritesOfCachedStoreWithoutWriter(t *testing.T) {
+	t.Parallel()
+
+	store := plainStore{&coffeeshop.MemoryStore{Products: stockedInventory(5)}}
+	shop := newCoffeShopTestServer(store, "0s", t, coffeeshop.WithCache("1m"))
+	resp := send(t, http.MethodDelete, shop.URL+"products/1")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Errorf("want HTTP 501, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,273 @@
+package coffeeshop
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// CartItem represents a product and its quantity in the cart.
+type CartItem struct {
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+	Price     Money  `json:"price"`
+}
+
+// Cart represents a customer shopping cart. Cart total
+// is calculated by the server from current product prices.
+type Cart struct {
+	ID string `json:"id"`
+	// CustomerID identifies the customer owning the cart.
+	// Orders checked out from the cart are placed by the customer.
+	CustomerID string     `json:"customerId,omitempty"`
+	Items      []CartItem `json:"items"`
+	Total      Money      `json:"total"`
+}
+
+// add adds the quantity of the product to the cart.
+func (c *Cart) add(productID string, quantity int) {
+	for i := range c.Items {
+		if c.Items[i].ProductID == productID {
+			c.Items[i].Quantity += quantity
+			return
+		}
+	}
+	c.Items = append(c.Items, CartItem{ProductID: productID, Quantity: quantity})
+}
+
+// CartStore represents a storage for shopping carts.
+type CartStore interface {
+	CreateCart() (Cart, error)
+	GetCart(id string) (Cart, error)
+	UpdateCart(c Cart) (Cart, error)
+	// ModifyCart calls modify with the cart with the ID while no
+	// other changes of the cart are made, and stores the modified
+	// cart, unless modify returns an error.
+	ModifyCart(id string, modify func(c *Cart) error) (Cart, error)
+}
+
+// MemoryCartStore represents an in-memory storage for carts.
+//
+// Use memory cart store for testing and development.
+type MemoryCartStore struct {
+	mx    sync.RWMutex
+	Carts map[string]Cart
+}
+
+// CreateCart creates a new empty cart with a random ID,
+// so IDs of carts can't be guessed.
+func (ms *MemoryCartStore) CreateCart() (Cart, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	if ms.Carts == nil {
+		ms.Carts = make(map[string]Cart)
+	}
+	var c Cart
+	for {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return Cart{}, err
+		}
+		c.ID = hex.EncodeToString(b)
+		if _, ok := ms.Carts[c.ID]; !ok {
+			break
+		}
+	}
+	c.Items = []CartItem{}
+	ms.Carts[c.ID] = c
+	return c, nil
+}
+
+// GetCart returns the cart with the given ID.
+func (ms *MemoryCartStore) GetCart(id string) (Cart, error) {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	c, ok := ms.Carts[id]
+	if !ok {
+		return Cart{}, ErrCartNotFound
+	}
+	c.Items = append([]CartItem{}, c.Items...)
+	return c, nil
+}
+
+// UpdateCart replaces items of an existing cart.
+func (ms *MemoryCartStore) UpdateCart(c Cart) (Cart, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	if _, ok := ms.Carts[c.ID]; !ok {
+		return Cart{}, ErrCartNotFound
+	}
+	stored := c
+	stored.Items = append([]CartItem{}, c.Items...)
+	ms.Carts[c.ID] = stored
+	return c, nil
+}
+
+// ModifyCart modifies the cart with the ID while the store is locked,
+// so concurrent changes of the cart aren't lost.
+func (ms *MemoryCartStore) ModifyCart(id string, modify func(c *Cart) error) (Cart, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	c, ok := ms.Carts[id]
+	if !ok {
+		return Cart{}, ErrCartNotFound
+	}
+	c.Items = append([]CartItem{}, c.Items...)
+	if err := modify(&c); err != nil {
+		return Cart{}, err
+	}
+	c.ID = id
+	stored := c
+	stored.Items = append([]CartItem{}, c.Items...)
+	ms.Carts[id] = stored
+	return c, nil
+}
+
+// WithCartStore configures the storage used for shopping carts.
+func WithCartStore(store CartStore) Option {
+	return func(s *Server) error {
+		if store == nil {
+			return errors.New("nil cart store")
+		}
+		s.CartStore = store
+		return nil
+	}
+}
+
+// priceCart fills in cart item prices and the cart total
+// using current product prices from the store. It returns
+// ErrCurrencyMismatch if the products are priced in
+// different currencies.
+func priceCart(s Store, c Cart) (Cart, error) {
+	var total Money
+	for i, item := range c.Items {
+		p, err := s.GetProduct(item.ProductID)
+		if err != nil {
+			return Cart{}, err
+		}
+		c.Items[i].Price = p.Price
+		if total, err = total.Add(p.Price.Mul(item.Quantity)); err != nil {
+			return Cart{}, err
+		}
+	}
+	if total.Currency == "" {
+		total.Currency = DefaultCurrency
+	}
+	c.Total = total
+	return c, nil
+}
+
+func (cs *Server) writeCart(w http.ResponseWriter, r *http.Request, c Cart, status int) {
+	c, err := priceCart(cs.store(r), c)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, r, status, c)
+}
+
+func (cs *Server) CreateCart(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := cs.customerID(w, r)
+	if !ok {
+		return
+	}
+	cart, err := cs.CartStore.CreateCart()
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if customerID != "" {
+		cart.CustomerID = customerID
+		if cart, err = cs.CartStore.UpdateCart(cart); err != nil {
+			writeError(w, r, http.StatusInternalServerError, "internal error")
+			return
+		}
+	}
+	w.Header().Set("Location", resourcePath(r, "/carts/"+cart.ID))
+	cs.writeCart(w, r, cart, http.StatusCreated)
+}
+
+// requestedCart returns the cart in the URL of the request. Carts
+// of customers are found only with their bearer token, so nobody
+// else can see or check out the cart.
+func (cs *Server) requestedCart(w http.ResponseWriter, r *http.Request) (Cart, bool) {
+	customerID, ok := cs.customerID(w, r)
+	if !ok {
+		return Cart{}, false
+	}
+	cart, err := cs.CartStore.GetCart(chi.URLParam(r, "cartID"))
+	if err == nil && cart.CustomerID != "" && cart.CustomerID != customerID {
+		err = ErrCartNotFound
+	}
+	if err != nil {
+		writeStoreError(w, r, err)
+		return Cart{}, false
+	}
+	return cart, true
+}
+
+func (cs *Server) GetCart(w http.ResponseWriter, r *http.Request) {
+	cart, ok := cs.requestedCart(w, r)
+	if !ok {
+		return
+	}
+	cs.writeCart(w, r, cart, http.StatusOK)
+}
+
+func (cs *Server) AddCartItem(w http.ResponseWriter, r *http.Request) {
+	cart, ok := cs.requestedCart(w, r)
+	if !ok {
+		return
+	}
+	var item CartItem
+	if !cs.decodeJSON(w, r, &item, "invalid cart item") {
+		return
+	}
+	if item.Quantity <= 0 {
+		writeError(w, r, http.StatusBadRequest, "invalid cart item")
+		return
+	}
+	if _, err := orderableProduct(cs.store(r), item.ProductID); err != nil {
+		writeErrorCode(w, r, http.StatusBadRequest, "product_not_found", "product not found")
+		return
+	}
+	cart, err := cs.CartStore.ModifyCart(cart.ID, func(c *Cart) error {
+		c.add(item.ProductID, item.Quantity)
+		return nil
+	})
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	cs.writeCart(w, r, cart, http.StatusOK)
+}
+
+func (cs *Server) DeleteCartItem(w http.ResponseWriter, r *http.Request) {
+	productID := chi.URLParam(r, "productID")
+	cart, ok := cs.requestedCart(w, r)
+	if !ok {
+		return
+	}
+	cart, err := cs.CartStore.ModifyCart(cart.ID, func(c *Cart) error {
+		items := c.Items[:0]
+		for _, item := range c.Items {
+			if item.ProductID != productID {
+				items = append(items, item)
+			}
+		}
+		if len(items) == len(c.Items) {
+			return ErrProductNotFound
+		}
+		c.Items = items
+		return nil
+	})
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	cs.writeCart(w, r, cart, http.StatusOK)
+}
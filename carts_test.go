@@ -0,0 +1,177 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+func doCartRequest(t *testing.T, method, url, body string) coffeeshop.Cart {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		t.Fatalf("%s %s: unexpected status %d", method, url, resp.StatusCode)
+	}
+	var cart coffeeshop.Cart
+	err = json.NewDecoder(resp.Body).Decode(&cart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cart
+}
+
+func TestServer_CalculatesCartTotal(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	cart := doCartRequest(t, http.MethodPost, shop.URL+"carts", "")
+	cartURL := shop.URL + "carts/" + cart.ID
+
+	doCartRequest(t, http.MethodPost, cartURL+"/items", `{"productId":"1","quantity":2}`)
+	doCartRequest(t, http.MethodPost, cartURL+"/items", `{"productId":"7","quantity":1}`)
+	doCartRequest(t, http.MethodPost, cartURL+"/items", `{"productId":"1","quantity":1}`)
+
+	got := doCartRequest(t, http.MethodGet, cartURL, "")
+	want := coffeeshop.Cart{
+		ID: cart.ID,
+		Items: []coffeeshop.CartItem{
+			{ProductID: "1", Quantity: 3, Price: coffeeshop.Money{Amount: 799, Currency: "EUR"}},
+			{ProductID: "7", Quantity: 1, Price: coffeeshop.Money{Amount: 499, Currency: "EUR"}},
+		},
+		Total: coffeeshop.Money{Amount: 2896, Currency: "EUR"},
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestServer_RemovesItemFromCart(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	cart := doCartRequest(t, http.MethodPost, shop.URL+"carts", "")
+	cartURL := shop.URL + "carts/" + cart.ID
+
+	doCartRequest(t, http.MethodPost, cartURL+"/items", `{"productId":"2","quantity":1}`)
+	doCartRequest(t, http.MethodPost, cartURL+"/items", `{"productId":"8","quantity":2}`)
+
+	got := doCartRequest(t, http.MethodDelete, cartURL+"/items/2", "")
+	want := coffeeshop.Cart{
+		ID: cart.ID,
+		Items: []coffeeshop.CartItem{
+			{ProductID: "8", Quantity: 2, Price: coffeeshop.Money{Amount: 749, Currency: "EUR"}},
+		},
+		Total: coffeeshop.Money{Amount: 1498, Currency: "EUR"},
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestServer_Returns404OnNotExistingCart(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp, err := http.Get(shop.URL + "carts/20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("want HTTP 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_RejectsCartTotalInMixedCurrencies(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: coffeeshop.Products{
+			"1": {ID: "1", Type: "Coffee", Name: "Intenso", Price: coffeeshop.Money{Amount: 799, Currency: "EUR"}},
+			"2": {ID: "2", Type: "Tea", Name: "Green Tea", Price: coffeeshop.Money{Amount: 499, Currency: "USD"}},
+		},
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	cart := doCartRequest(t, http.MethodPost, shop.URL+"carts", "")
+	cartURL := shop.URL + "carts/" + cart.ID
+	doCartRequest(t, http.MethodPost, cartURL+"/items", `{"productId":"2","quantity":1}`)
+	resp, err := http.Post(cartURL+"/items", "application/json", strings.NewReader(`{"productId":"1","quantity":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("want HTTP 422, got %d", resp.StatusCode)
+	}
+	if code := errorCodeOf(t, resp); code != "currency_mismatch" {
+		t.Errorf("want error code currency_mismatch, got %q", code)
+	}
+}
+
+// racingCartStore adds an item to the cart after the cart is read
+// for the first time, as if another request added it concurrently.
+type racingCartStore struct {
+	*coffeeshop.MemoryCartStore
+	once sync.Once
+}
+
+func (s *racingCartStore) GetCart(id string) (coffeeshop.Cart, error) {
+	c, err := s.MemoryCartStore.GetCart(id)
+	s.once.Do(func() {
+		added := c
+		added.Items = append(added.Items, coffeeshop.CartItem{ProductID: "7", Quantity: 1})
+		_, _ = s.MemoryCartStore.UpdateCart(added)
+	})
+	return c, err
+}
+
+func TestServer_KeepsItemsAddedToCartConcurrently(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	carts := &racingCartStore{MemoryCartStore: &coffeeshop.MemoryCartStore{}}
+	shop := newCoffeShopTestServer(store, "0s", t, coffeeshop.WithCartStore(carts))
+	cart := doCartRequest(t, http.MethodPost, shop.URL+"carts", "")
+	cartURL := shop.URL + "carts/" + cart.ID
+
+	got := doCartRequest(t, http.MethodPost, cartURL+"/items", `{"productId":"1","quantity":2}`)
+	want := []coffeeshop.CartItem{
+		{ProductID: "7", Quantity: 1, Price: coffeeshop.Money{Amount: 499, Currency: "EUR"}},
+		{ProductID: "1", Quantity: 2, Price: coffeeshop.Money{Amount: 799, Currency: "EUR"}},
+	}
+	if !cmp.Equal(want, got.Items) {
+		t.Error(cmp.Diff(want, got.Items))
+	}
+}
@@ -0,0 +1,94 @@
+package coffeeshop
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Category represents a group of products of the same type.
+type Category struct {
+	Name     string `json:"name"`
+	Products int    `json:"products"`
+}
+
+// categories returns product categories sorted by name.
+func categories(px []Product) []Category {
+	counts := make(map[string]int)
+	for _, p := range px {
+		counts[strings.ToLower(p.Type)]++
+	}
+	cx := make([]Category, 0, len(counts))
+	for name, n := range counts {
+		cx = append(cx, Category{Name: name, Products: n})
+	}
+	sort.Slice(cx, func(i, j int) bool { return cx[i].Name < cx[j].Name })
+	return cx
+}
+
+// WithEmptyListNotFound restores the legacy behaviour of responding
+// with 404 Not Found instead of an empty list when there are no
+// products of the requested type.
+func WithEmptyListNotFound() Option {
+	return func(s *Server) error {
+		s.EmptyListNotFound = true
+		return nil
+	}
+}
+
+func (cs *Server) GetCategories(w http.ResponseWriter, r *http.Request) {
+	px, err := listAll(cs.store(r))
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, categories(withoutArchived(px)))
+}
+
+func (cs *Server) GetCategoryProducts(w http.ResponseWriter, r *http.Request) {
+	cs.writeProductsByType(w, r, chi.URLParam(r, "category"))
+}
+
+// writeProductsByType responds with all products of the given type.
+// No products of the type result in an empty list, or in 404 Not Found
+// if the server is configured with WithEmptyListNotFound.
+func (cs *Server) writeProductsByType(w http.ResponseWriter, r *http.Request, productType string) {
+	px, err := listByType(cs.store(r), productType)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	products, err := listed(r, px)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(products) == 0 {
+		if cs.EmptyListNotFound {
+			writeStoreError(w, r, ErrProductNotFound)
+			return
+		}
+		products = []Product{}
+	}
+	products, err = cs.convertPrices(w, r, products)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "unsupported currency")
+		return
+	}
+	cs.addRatings(products)
+	if err := sortRequested(r, products); err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	cached, err := cs.notModified(w, r, products...)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if cached {
+		return
+	}
+	cs.writeProducts(w, r, products)
+}
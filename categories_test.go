@@ -0,0 +1,140 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+func TestGetByType_ReturnsProductsOfGivenTypeIgnoringCase(t *testing.T) {
+	t.Parallel()
+
+	store := coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	tea := store.GetByType("TEA")
+	if len(tea) != 2 {
+		t.Errorf("want 2 types of tea, got %d", len(tea))
+	}
+	if got := store.GetByType("cocoa"); len(got) != 0 {
+		t.Errorf("want no cocoa, got %d", len(got))
+	}
+}
+
+func TestServer_ReturnsAllCategories(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp, err := http.Get(shop.URL + "categories")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200OK, got %d", resp.StatusCode)
+	}
+	var got []coffeeshop.Category
+	err = json.NewDecoder(resp.Body).Decode(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []coffeeshop.Category{
+		{Name: "coffee", Products: 6},
+		{Name: "tea", Products: 2},
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestServer_ReturnsProductsInCategory(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: coffeeshop.Products{
+			"1": {ID: "1", Type: "Cocoa", Name: "Dark"},
+			"2": {ID: "2", Type: "Tea", Name: "Green"},
+		},
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp, err := http.Get(shop.URL + "categories/cocoa/products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200OK, got %d", resp.StatusCode)
+	}
+	var got []coffeeshop.Product
+	err = json.NewDecoder(resp.Body).Decode(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []coffeeshop.Product{{ID: "1", Type: "Cocoa", Name: "Dark", Price: coffeeshop.Money{Currency: "EUR"}}}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestServer_ReturnsEmptyListForTypeWithoutProducts(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: coffeeshop.Products{
+			"1": {ID: "1", Type: "Coffee", Name: "Intenso"},
+		},
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	for _, path := range []string{"products/tea", "products/tea?pretty", "categories/cocoa/products"} {
+		resp, err := http.Get(shop.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: want HTTP 200OK, got %d", path, resp.StatusCode)
+		}
+		if strings.TrimSpace(string(got)) != "[]" {
+			t.Errorf("%s: want empty JSON array, got %q", path, got)
+		}
+	}
+}
+
+func TestServer_Returns404ForTypeWithoutProductsWithEmptyListNotFound(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: coffeeshop.Products{
+			"1": {ID: "1", Type: "Coffee", Name: "Intenso"},
+		},
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t, coffeeshop.WithEmptyListNotFound())
+	resp, err := http.Get(shop.URL + "products/tea")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("want HTTP 404, got %d", resp.StatusCode)
+	}
+}
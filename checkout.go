@@ -0,0 +1,234 @@
+package coffeeshop
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// PaymentOutcome represents a result of the simulated payment.
+type PaymentOutcome string
+
+const (
+	PaymentSuccess  PaymentOutcome = "success"
+	PaymentDeclined PaymentOutcome = "declined"
+	PaymentTimeout  PaymentOutcome = "timeout"
+)
+
+// Magic card numbers recognised by the default payment simulator.
+// Any other card number results in a successful payment.
+const (
+	CardDeclined = "4000000000000002"
+	CardTimeout  = "4000000000000119"
+)
+
+// PaymentSimulator decides the outcome of a payment
+// made with the given card for the given amount.
+type PaymentSimulator func(cardNumber string, amount Money) PaymentOutcome
+
+// DefaultPaymentSimulator returns the payment outcome
+// based on magic card numbers.
+func DefaultPaymentSimulator(cardNumber string, amount Money) PaymentOutcome {
+	switch cardNumber {
+	case CardDeclined:
+		return PaymentDeclined
+	case CardTimeout:
+		return PaymentTimeout
+	default:
+		return PaymentSuccess
+	}
+}
+
+// Receipt represents a proof of a successful checkout.
+// The total is the subtotal of items less the discount.
+type Receipt struct {
+	OrderID      string     `json:"orderId"`
+	CartID       string     `json:"cartId"`
+	Items        []CartItem `json:"items"`
+	Subtotal     Money      `json:"subtotal"`
+	Discount     Money      `json:"discount"`
+	DiscountCode string     `json:"discountCode,omitempty"`
+	Total        Money      `json:"total"`
+	Card         string     `json:"card"`
+	PaidAt       time.Time  `json:"paidAt"`
+}
+
+// WithPaymentSimulator configures the simulator used
+// to process checkout payments.
+func WithPaymentSimulator(sim PaymentSimulator) Option {
+	return func(s *Server) error {
+		if sim == nil {
+			return errors.New("nil payment simulator")
+		}
+		s.PaymentSimulator = sim
+		return nil
+	}
+}
+
+// WithPaymentTimeout configures how long the server waits
+// before responding to a payment that times out.
+func WithPaymentTimeout(t string) Option {
+	return func(s *Server) error {
+		d, err := time.ParseDuration(t)
+		if err != nil {
+			return err
+		}
+		s.PaymentTimeout = d
+		return nil
+	}
+}
+
+// maskCard hides all but the last four digits of the card number.
+func maskCard(cardNumber string) string {
+	if len(cardNumber) <= 4 {
+		return cardNumber
+	}
+	masked := make([]byte, len(cardNumber))
+	for i := range masked {
+		masked[i] = '*'
+	}
+	copy(masked[len(masked)-4:], cardNumber[len(cardNumber)-4:])
+	return string(masked)
+}
+
+// orderItems returns order items for the items of a cart.
+func orderItems(items []CartItem) []OrderItem {
+	ordered := make([]OrderItem, 0, len(items))
+	for _, item := range items {
+		ordered = append(ordered, OrderItem{ProductID: item.ProductID, Quantity: item.Quantity})
+	}
+	return ordered
+}
+
+// errCartEmpty is returned when checking out carts without items.
+var errCartEmpty = errors.New("cart is empty")
+
+// returnCartItems puts the items back to the cart
+// after the checkout of the items failed.
+func (cs *Server) returnCartItems(id string, items []CartItem) {
+	_, err := cs.CartStore.ModifyCart(id, func(c *Cart) error {
+		for _, item := range items {
+			c.add(item.ProductID, item.Quantity)
+		}
+		return nil
+	})
+	if err != nil {
+		cs.logf("checkout: can't return items to cart %s: %v", id, err)
+	}
+}
+
+func (cs *Server) Checkout(w http.ResponseWriter, r *http.Request) {
+	cart, ok := cs.requestedCart(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		CardNumber   string `json:"cardNumber"`
+		DiscountCode string `json:"discountCode"`
+	}
+	if !cs.decodeJSON(w, r, &req, "invalid payment details") {
+		return
+	}
+	if req.CardNumber == "" {
+		writeFieldErrors(w, r, "invalid payment details", []FieldError{{Field: "cardNumber", Message: "is required"}})
+		return
+	}
+	// Stock for items of the cart is reserved before anything else
+	// is spent, so the customer isn't charged for products out of
+	// stock. Items are taken out of the cart with the reservation, so
+	// concurrent checkouts of the cart don't place the order twice,
+	// and items added meanwhile stay in the cart. Unless the order is
+	// placed, the stock is released and items are put back.
+	s := cs.store(r)
+	var items []OrderItem
+	_, err := cs.CartStore.ModifyCart(cart.ID, func(c *Cart) error {
+		if len(c.Items) == 0 {
+			return errCartEmpty
+		}
+		items = orderItems(c.Items)
+		if err := s.ReserveStock(items); err != nil {
+			return err
+		}
+		cart.Items, c.Items = c.Items, []CartItem{}
+		return nil
+	})
+	if errors.Is(err, errCartEmpty) {
+		writeError(w, r, http.StatusBadRequest, "cart is empty")
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	placed := false
+	defer func() {
+		if !placed {
+			cs.releaseStock(items)
+			cs.returnCartItems(cart.ID, cart.Items)
+		}
+	}()
+	priced, err := priceCart(s, cart)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	discount := Money{Currency: priced.Total.Currency}
+	var promo Promotion
+	if req.DiscountCode != "" {
+		promo, err = cs.PromotionStore.Redeem(req.DiscountCode, cs.Clock.Now())
+		if err != nil {
+			writeStoreError(w, r, err)
+			return
+		}
+		// The use of the code is given back unless the order is placed.
+		defer func() {
+			if !placed {
+				_ = cs.PromotionStore.Release(promo.Code)
+			}
+		}()
+		if discount, err = promo.discount(priced.Total, cs.Rates); err != nil {
+			writeError(w, r, http.StatusInternalServerError, "internal error")
+			return
+		}
+	}
+	total := priced.Total
+	total.Amount -= discount.Amount
+
+	switch cs.PaymentSimulator(req.CardNumber, total) {
+	case PaymentDeclined:
+		writeError(w, r, http.StatusPaymentRequired, "payment declined")
+		return
+	case PaymentTimeout:
+		select {
+		case <-time.After(cs.PaymentTimeout):
+		case <-r.Context().Done():
+		}
+		writeError(w, r, http.StatusGatewayTimeout, "payment timeout")
+		return
+	}
+
+	placing := Order{CustomerID: cart.CustomerID, Items: items, Total: &total}
+	if promo.Code != "" {
+		placing.Discount, placing.DiscountCode = &discount, promo.Code
+	}
+	order, err := cs.submitOrder(placing)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	placed = true
+
+	receipt := Receipt{
+		OrderID:      order.ID,
+		CartID:       cart.ID,
+		Items:        priced.Items,
+		Subtotal:     priced.Total,
+		Discount:     discount,
+		DiscountCode: promo.Code,
+		Total:        total,
+		Card:         maskCard(req.CardNumber),
+		PaidAt:       order.CreatedAt,
+	}
+	w.Header().Set("Location", resourcePath(r, "/orders/"+order.ID))
+	writeJSON(w, r, http.StatusCreated, receipt)
+}
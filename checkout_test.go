@@ -0,0 +1,211 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+func checkout(t *testing.T, cartURL, cardNumber string) *http.Response {
+	t.Helper()
+	body := `{"cardNumber":"` + cardNumber + `"}`
+	resp, err := http.Post(cartURL+"/checkout", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestServer_CheckoutReturnsReceiptOnSuccessfulPayment(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(10),
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t, coffeeshop.WithOrderInterval("1h"))
+	cart := doCartRequest(t, http.MethodPost, shop.URL+"carts", "")
+	cartURL := shop.URL + "carts/" + cart.ID
+	doCartRequest(t, http.MethodPost, cartURL+"/items", `{"productId":"4","quantity":2}`)
+
+	resp := checkout(t, cartURL, "4242424242424242")
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("want HTTP 201, got %d", resp.StatusCode)
+	}
+	var got coffeeshop.Receipt
+	err := json.NewDecoder(resp.Body).Decode(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := coffeeshop.Receipt{
+		OrderID:  got.OrderID,
+		CartID:   cart.ID,
+		Items:    []coffeeshop.CartItem{{ProductID: "4", Quantity: 2, Price: coffeeshop.Money{Amount: 799, Currency: "EUR"}}},
+		Subtotal: coffeeshop.Money{Amount: 1598, Currency: "EUR"},
+		Discount: coffeeshop.Money{Amount: 0, Currency: "EUR"},
+		Total:    coffeeshop.Money{Amount: 1598, Currency: "EUR"},
+		Card:     "************4242",
+		PaidAt:   got.PaidAt,
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+	if got.OrderID == "" {
+		t.Error("want order ID in receipt")
+	}
+
+	emptied := doCartRequest(t, http.MethodGet, cartURL, "")
+	if len(emptied.Items) != 0 {
+		t.Errorf("want empty cart after checkout, got %d items", len(emptied.Items))
+	}
+}
+
+func TestServer_CheckoutReturns402OnDeclinedCard(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(10),
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	cart := doCartRequest(t, http.MethodPost, shop.URL+"carts", "")
+	cartURL := shop.URL + "carts/" + cart.ID
+	doCartRequest(t, http.MethodPost, cartURL+"/items", `{"productId":"7","quantity":1}`)
+
+	resp := checkout(t, cartURL, coffeeshop.CardDeclined)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPaymentRequired {
+		t.Fatalf("want HTTP 402, got %d", resp.StatusCode)
+	}
+	kept := doCartRequest(t, http.MethodGet, cartURL, "")
+	if len(kept.Items) != 1 || kept.Items[0].ProductID != "7" {
+		t.Errorf("want items kept in cart after declined payment, got %+v", kept.Items)
+	}
+	p, err := store.GetProduct("7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Stock != 10 {
+		t.Errorf("want stock released after declined payment, got %d", p.Stock)
+	}
+}
+
+func TestServer_CheckoutDoesNotChargeForProductsOutOfStock(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(1),
+	}
+
+	var charged atomic.Int32
+	shop := newCoffeShopTestServer(store, "10ms", t,
+		coffeeshop.WithPaymentSimulator(func(cardNumber string, amount coffeeshop.Money) coffeeshop.PaymentOutcome {
+			charged.Add(1)
+			return coffeeshop.PaymentSuccess
+		}),
+	)
+	cart := doCartRequest(t, http.MethodPost, shop.URL+"carts", "")
+	cartURL := shop.URL + "carts/" + cart.ID
+	doCartRequest(t, http.MethodPost, cartURL+"/items", `{"productId":"7","quantity":2}`)
+
+	resp := checkout(t, cartURL, "4242424242424242")
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("want HTTP 409, got %d", resp.StatusCode)
+	}
+	if n := charged.Load(); n != 0 {
+		t.Errorf("want no payment for products out of stock, got %d", n)
+	}
+	kept := doCartRequest(t, http.MethodGet, cartURL, "")
+	if len(kept.Items) != 1 || kept.Items[0].Quantity != 2 {
+		t.Errorf("want items kept in cart, got %+v", kept.Items)
+	}
+}
+
+func TestServer_ChecksOutCartOnceWhileItemsAreAdded(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(10),
+	}
+
+	var cartURL string
+	var nested int
+	var payments atomic.Int32
+	// pay checks out the cart again, and adds an item to it,
+	// while the payment of the first checkout is processed.
+	pay := func(cardNumber string, amount coffeeshop.Money) coffeeshop.PaymentOutcome {
+		if payments.Add(1) > 1 {
+			return coffeeshop.PaymentSuccess
+		}
+		resp, err := http.Post(cartURL+"/checkout", "application/json", strings.NewReader(`{"cardNumber":"4242424242424242"}`))
+		if err != nil {
+			t.Error(err)
+			return coffeeshop.PaymentSuccess
+		}
+		resp.Body.Close()
+		nested = resp.StatusCode
+		resp, err = http.Post(cartURL+"/items", "application/json", strings.NewReader(`{"productId":"7","quantity":1}`))
+		if err != nil {
+			t.Error(err)
+			return coffeeshop.PaymentSuccess
+		}
+		resp.Body.Close()
+		return coffeeshop.PaymentSuccess
+	}
+	shop := newCoffeShopTestServer(store, "0s", t,
+		coffeeshop.WithPaymentSimulator(pay),
+		coffeeshop.WithOrderInterval("1h"),
+	)
+	cart := doCartRequest(t, http.MethodPost, shop.URL+"carts", "")
+	cartURL = shop.URL + "carts/" + cart.ID
+	doCartRequest(t, http.MethodPost, cartURL+"/items", `{"productId":"4","quantity":2}`)
+
+	resp := checkout(t, cartURL, "4242424242424242")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("want HTTP 201, got %d", resp.StatusCode)
+	}
+	if nested != http.StatusBadRequest {
+		t.Errorf("want HTTP 400 for checkout of cart being checked out, got %d", nested)
+	}
+	got := doCartRequest(t, http.MethodGet, cartURL, "")
+	if len(got.Items) != 1 || got.Items[0].ProductID != "7" {
+		t.Errorf("want item added during checkout kept in cart, got %+v", got.Items)
+	}
+}
+
+func TestServer_CheckoutReturns504OnPaymentTimeout(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(10),
+	}
+
+	alwaysTimeout := func(cardNumber string, amount coffeeshop.Money) coffeeshop.PaymentOutcome {
+		return coffeeshop.PaymentTimeout
+	}
+	shop := newCoffeShopTestServer(store, "10ms", t,
+		coffeeshop.WithPaymentSimulator(alwaysTimeout),
+		coffeeshop.WithPaymentTimeout("10ms"),
+	)
+	cart := doCartRequest(t, http.MethodPost, shop.URL+"carts", "")
+	cartURL := shop.URL + "carts/" + cart.ID
+	doCartRequest(t, http.MethodPost, cartURL+"/items", `{"productId":"7","quantity":1}`)
+
+	resp := checkout(t, cartURL, "4242424242424242")
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Fatalf("want HTTP 504, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,418 @@
+// Package client provides a typed client for the coffeeshop API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// apiVersion is the version of the API used by the client.
+const apiVersion = "v1"
+
+// Error represents an error response returned by the coffeeshop API.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	RequestID  string
+	// Fields lists problems with fields of invalid requests.
+	Fields []coffeeshop.FieldError
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("coffeeshop: %d %s", e.StatusCode, e.Message)
+}
+
+// errorCodes maps error codes returned by the API
+// to errors defined in the coffeeshop package.
+var errorCodes = map[string]error{
+	"product_not_found":   coffeeshop.ErrProductNotFound,
+	"order_not_found":     coffeeshop.ErrOrderNotFound,
+	"cart_not_found":      coffeeshop.ErrCartNotFound,
+	"image_not_found":     coffeeshop.ErrImageNotFound,
+	"customer_not_found":  coffeeshop.ErrCustomerNotFound,
+	"promotion_not_found": coffeeshop.ErrPromotionNotFound,
+	"tenant_not_found":    coffeeshop.ErrTenantNotFound,
+	"invalid_product":     coffeeshop.ErrInvalidProduct,
+	"product_exists":      coffeeshop.ErrProductExists,
+	"customer_exists":     coffeeshop.ErrCustomerExists,
+	"promotion_exists":    coffeeshop.ErrPromotionExists,
+	"tenant_exists":       coffeeshop.ErrTenantExists,
+	"promotion_expired":   coffeeshop.ErrPromotionExpired,
+	"promotion_exhausted": coffeeshop.ErrPromotionExhausted,
+	"version_mismatch":    coffeeshop.ErrVersionMismatch,
+	"out_of_stock":        coffeeshop.ErrOutOfStock,
+}
+
+// Is reports whether the error code corresponds to the target,
+// for example coffeeshop.ErrProductNotFound.
+func (e *Error) Is(target error) bool {
+	err, ok := errorCodes[e.Code]
+	return ok && err == target
+}
+
+// IsNotFound reports whether err is an API error
+// caused by a missing resource.
+func IsNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
+
+// Client talks to the coffeeshop API.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	Retries    int
+	Backoff    time.Duration
+}
+
+// Option configures the Client.
+type Option func(c *Client) error
+
+// WithHTTPClient configures the HTTP client used to send requests.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) error {
+		if hc == nil {
+			return errors.New("nil HTTP client")
+		}
+		c.HTTPClient = hc
+		return nil
+	}
+}
+
+// WithRetries configures how many times idempotent requests
+// are retried after network errors or temporary server errors.
+func WithRetries(n int) Option {
+	return func(c *Client) error {
+		if n < 0 {
+			return fmt.Errorf("invalid number of retries %d", n)
+		}
+		c.Retries = n
+		return nil
+	}
+}
+
+// WithBackoff configures the delay before the first retry.
+// The delay doubles with every following retry.
+func WithBackoff(d string) Option {
+	return func(c *Client) error {
+		backoff, err := time.ParseDuration(d)
+		if err != nil {
+			return err
+		}
+		if backoff < 0 {
+			return fmt.Errorf("invalid backoff %s", d)
+		}
+		c.Backoff = backoff
+		return nil
+	}
+}
+
+// New creates a client for the coffeeshop API served at baseURL.
+// Base URLs like "unix:///run/coffeeshop.sock" reach servers listening
+// on Unix domain sockets, unless WithHTTPClient replaces the HTTP client.
+func New(baseURL string, options ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	c := Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		Retries:    2,
+		Backoff:    100 * time.Millisecond,
+	}
+	switch {
+	case u.Scheme == "unix" && u.Path != "":
+		socket := strings.TrimSuffix(u.Path, "/")
+		c.BaseURL = "http://unix"
+		c.HTTPClient.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}
+	case u.Scheme == "" || u.Host == "":
+		return nil, fmt.Errorf("invalid base URL %q", baseURL)
+	}
+	for _, opt := range options {
+		if err := opt(&c); err != nil {
+			return nil, err
+		}
+	}
+	return &c, nil
+}
+
+// GetProducts returns all products.
+func (c *Client) GetProducts(ctx context.Context) ([]coffeeshop.Product, error) {
+	var px []coffeeshop.Product
+	err := c.do(ctx, http.MethodGet, "/products", nil, &px)
+	return px, err
+}
+
+// GetProduct returns the product with the given ID.
+func (c *Client) GetProduct(ctx context.Context, id string) (coffeeshop.Product, error) {
+	var p coffeeshop.Product
+	err := c.do(ctx, http.MethodGet, "/products/"+url.PathEscape(id), nil, &p)
+	return p, err
+}
+
+// GetMany returns products with the given IDs in one request, in
+// the order of the IDs. Products which aren't found are left out.
+func (c *Client) GetMany(ctx context.Context, ids []string) ([]coffeeshop.Product, error) {
+	query := url.Values{"ids": {strings.Join(ids, ",")}}
+	var px []coffeeshop.Product
+	err := c.do(ctx, http.MethodGet, "/products?"+query.Encode(), nil, &px)
+	return px, err
+}
+
+// GetBySKU returns the product with the SKU or EAN.
+func (c *Client) GetBySKU(ctx context.Context, code string) (coffeeshop.Product, error) {
+	var p coffeeshop.Product
+	err := c.do(ctx, http.MethodGet, "/products/by-sku/"+url.PathEscape(code), nil, &p)
+	return p, err
+}
+
+// Sync returns products created, updated and deleted since the
+// cursor returned by the previous call, or all products for the
+// empty cursor.
+func (c *Client) Sync(ctx context.Context, cursor string) (coffeeshop.SyncDelta, error) {
+	var delta coffeeshop.SyncDelta
+	err := c.do(ctx, http.MethodGet, "/sync?since="+url.QueryEscape(cursor), nil, &delta)
+	return delta, err
+}
+
+// GetCoffee returns all coffee products.
+func (c *Client) GetCoffee(ctx context.Context) ([]coffeeshop.Product, error) {
+	var px []coffeeshop.Product
+	err := c.do(ctx, http.MethodGet, "/products/coffee", nil, &px)
+	return px, err
+}
+
+// GetTea returns all tea products.
+func (c *Client) GetTea(ctx context.Context) ([]coffeeshop.Product, error) {
+	var px []coffeeshop.Product
+	err := c.do(ctx, http.MethodGet, "/products/tea", nil, &px)
+	return px, err
+}
+
+// RestockProduct sets the stock of the product.
+func (c *Client) RestockProduct(ctx context.Context, id string, stock int) (coffeeshop.Product, error) {
+	req := struct {
+		Stock int `json:"stock"`
+	}{Stock: stock}
+	var p coffeeshop.Product
+	err := c.do(ctx, http.MethodPut, "/products/"+url.PathEscape(id)+"/stock", req, &p)
+	return p, err
+}
+
+// GetCategories returns product categories.
+func (c *Client) GetCategories(ctx context.Context) ([]coffeeshop.Category, error) {
+	var cx []coffeeshop.Category
+	err := c.do(ctx, http.MethodGet, "/categories", nil, &cx)
+	return cx, err
+}
+
+// GetCategoryProducts returns products in the category.
+func (c *Client) GetCategoryProducts(ctx context.Context, category string) ([]coffeeshop.Product, error) {
+	var px []coffeeshop.Product
+	err := c.do(ctx, http.MethodGet, "/categories/"+url.PathEscape(category)+"/products", nil, &px)
+	return px, err
+}
+
+// CreateOrder places an order for the items. It returns
+// *coffeeshop.OutOfStockError if there are not enough
+// products in stock.
+func (c *Client) CreateOrder(ctx context.Context, items []coffeeshop.OrderItem) (coffeeshop.Order, error) {
+	req := struct {
+		Items []coffeeshop.OrderItem `json:"items"`
+	}{Items: items}
+	var o coffeeshop.Order
+	err := c.do(ctx, http.MethodPost, "/orders", req, &o)
+	return o, err
+}
+
+// GetOrder returns the order with the given ID.
+func (c *Client) GetOrder(ctx context.Context, id string) (coffeeshop.Order, error) {
+	var o coffeeshop.Order
+	err := c.do(ctx, http.MethodGet, "/orders/"+url.PathEscape(id), nil, &o)
+	return o, err
+}
+
+// GetOrders returns all orders.
+func (c *Client) GetOrders(ctx context.Context) ([]coffeeshop.Order, error) {
+	var ox []coffeeshop.Order
+	err := c.do(ctx, http.MethodGet, "/orders", nil, &ox)
+	return ox, err
+}
+
+// CreateCart creates an empty cart.
+func (c *Client) CreateCart(ctx context.Context) (coffeeshop.Cart, error) {
+	var cart coffeeshop.Cart
+	err := c.do(ctx, http.MethodPost, "/carts", nil, &cart)
+	return cart, err
+}
+
+// GetCart returns the cart with the given ID.
+func (c *Client) GetCart(ctx context.Context, id string) (coffeeshop.Cart, error) {
+	var cart coffeeshop.Cart
+	err := c.do(ctx, http.MethodGet, "/carts/"+url.PathEscape(id), nil, &cart)
+	return cart, err
+}
+
+// AddCartItem adds quantity of the product to the cart.
+func (c *Client) AddCartItem(ctx context.Context, cartID, productID string, quantity int) (coffeeshop.Cart, error) {
+	req := coffeeshop.OrderItem{ProductID: productID, Quantity: quantity}
+	var cart coffeeshop.Cart
+	err := c.do(ctx, http.MethodPost, "/carts/"+url.PathEscape(cartID)+"/items", req, &cart)
+	return cart, err
+}
+
+// DeleteCartItem removes the product from the cart.
+func (c *Client) DeleteCartItem(ctx context.Context, cartID, productID string) (coffeeshop.Cart, error) {
+	var cart coffeeshop.Cart
+	err := c.do(ctx, http.MethodDelete, "/carts/"+url.PathEscape(cartID)+"/items/"+url.PathEscape(productID), nil, &cart)
+	return cart, err
+}
+
+// Checkout pays for the cart with the card and places an order.
+func (c *Client) Checkout(ctx context.Context, cartID, cardNumber string) (coffeeshop.Receipt, error) {
+	req := struct {
+		CardNumber string `json:"cardNumber"`
+	}{CardNumber: cardNumber}
+	var receipt coffeeshop.Receipt
+	err := c.do(ctx, http.MethodPost, "/carts/"+url.PathEscape(cartID)+"/checkout", req, &receipt)
+	return receipt, err
+}
+
+// do sends the request with JSON encoded body and decodes
+// the JSON response into out. Idempotent requests are
+// retried after network errors and temporary server errors.
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+	retries := 0
+	if idempotent(method) {
+		retries = c.Retries
+	}
+	backoff := c.Backoff
+	for attempt := 0; ; attempt++ {
+		err := c.send(ctx, method, path, data, out)
+		if attempt >= retries || !temporary(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, data []byte, out any) error {
+	var body io.Reader
+	if data != nil {
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("API-Version", apiVersion)
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return responseError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// responseError returns the error described by the response.
+func responseError(resp *http.Response) error {
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	var body struct {
+		Error struct {
+			Code      string                  `json:"code"`
+			Message   string                  `json:"message"`
+			RequestID string                  `json:"request_id"`
+			Fields    []coffeeshop.FieldError `json:"fields"`
+			coffeeshop.OutOfStockError
+		} `json:"error"`
+	}
+	if json.Unmarshal(data, &body) != nil || body.Error.Message == "" {
+		return &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	}
+	if body.Error.Code == "out_of_stock" {
+		e := body.Error.OutOfStockError
+		return &e
+	}
+	return &Error{
+		StatusCode: resp.StatusCode,
+		Code:       body.Error.Code,
+		Message:    body.Error.Message,
+		RequestID:  body.Error.RequestID,
+		Fields:     body.Error.Fields,
+	}
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// temporary reports whether the request failed
+// with an error that may go away on retry.
+func temporary(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		switch e.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var oos *coffeeshop.OutOfStockError
+	if errors.As(err, &oos) {
+		return false
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
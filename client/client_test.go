@@ -0,0 +1,211 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+	"github.com/qba73/coffeeshop/client"
+	"github.com/qba73/coffeeshop/coffeeshoptest"
+)
+
+func newTestShop(t *testing.T, products map[string]coffeeshop.Product) *coffeeshop.Server {
+	t.Helper()
+
+	store := &coffeeshop.MemoryStore{Products: products}
+	return coffeeshoptest.NewServer(t, coffeeshop.WithStore(store), coffeeshop.WithLatency("1ms")).Server
+}
+
+func newTestClient(t *testing.T, url string, opts ...client.Option) *client.Client {
+	t.Helper()
+	c, err := client.New(url, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+var products = map[string]coffeeshop.Product{
+	"1": {ID: "1", Type: "Coffee", Brand: "illy", Name: "Intenso", Price: coffeeshop.Money{Amount: 799, Currency: "EUR"}, Stock: 5},
+	"2": {ID: "2", Type: "Tea", Brand: "Caykur", Name: "Green Tea", Price: coffeeshop.Money{Amount: 499, Currency: "EUR"}, Stock: 5},
+}
+
+func TestClient_GetsProductFromServer(t *testing.T) {
+	t.Parallel()
+
+	shop := newTestShop(t, products)
+	c := newTestClient(t, shop.URL)
+
+	got, err := c.GetProduct(context.Background(), "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(products["2"], got) {
+		t.Error(cmp.Diff(products["2"], got))
+	}
+}
+
+func TestClient_GetsCoffeeFromServer(t *testing.T) {
+	t.Parallel()
+
+	shop := newTestShop(t, products)
+	c := newTestClient(t, shop.URL)
+
+	got, err := c.GetCoffee(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []coffeeshop.Product{products["1"]}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestClient_GetsManyProductsFromServer(t *testing.T) {
+	t.Parallel()
+
+	shop := newTestShop(t, products)
+	c := newTestClient(t, shop.URL)
+
+	got, err := c.GetMany(context.Background(), []string{"2", "missing", "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []coffeeshop.Product{products["2"], products["1"]}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestClient_GetsProductBySKUFromServer(t *testing.T) {
+	t.Parallel()
+
+	p := products["1"]
+	p.SKU = "ILLY-INT-250"
+	shop := newTestShop(t, map[string]coffeeshop.Product{"1": p})
+	c := newTestClient(t, shop.URL)
+
+	got, err := c.GetBySKU(context.Background(), "ILLY-INT-250")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(p, got) {
+		t.Error(cmp.Diff(p, got))
+	}
+}
+
+func TestClient_SyncsChangesFromServer(t *testing.T) {
+	t.Parallel()
+
+	shop := newTestShop(t, map[string]coffeeshop.Product{"1": products["1"], "2": products["2"]})
+	c := newTestClient(t, shop.URL)
+
+	full, err := c.Sync(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(full.Created) != 2 {
+		t.Fatalf("want 2 products created, got %+v", full)
+	}
+	if _, err := c.RestockProduct(context.Background(), "1", 3); err != nil {
+		t.Fatal(err)
+	}
+	delta, err := c.Sync(context.Background(), full.Cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(delta.Updated) != 1 || delta.Updated[0].ID != "1" || delta.Updated[0].Stock != 3 {
+		t.Errorf("want product 1 updated with stock 3, got %+v", delta)
+	}
+}
+
+func TestClient_ReturnsNotFoundErrorForMissingProduct(t *testing.T) {
+	t.Parallel()
+
+	shop := newTestShop(t, products)
+	c := newTestClient(t, shop.URL)
+
+	_, err := c.GetProduct(context.Background(), "42")
+	if !client.IsNotFound(err) {
+		t.Errorf("want not found error, got %v", err)
+	}
+	if !errors.Is(err, coffeeshop.ErrProductNotFound) {
+		t.Errorf("want ErrProductNotFound, got %v", err)
+	}
+}
+
+func TestClient_ReturnsOutOfStockErrorOnOrderExceedingStock(t *testing.T) {
+	t.Parallel()
+
+	stocked := map[string]coffeeshop.Product{"1": products["1"]}
+	shop := newTestShop(t, stocked)
+	c := newTestClient(t, shop.URL)
+
+	_, err := c.CreateOrder(context.Background(), []coffeeshop.OrderItem{{ProductID: "1", Quantity: 10}})
+	var oos *coffeeshop.OutOfStockError
+	if !errors.As(err, &oos) {
+		t.Fatalf("want out of stock error, got %v", err)
+	}
+	want := coffeeshop.OutOfStockError{ProductID: "1", Requested: 10, Available: 5}
+	if !cmp.Equal(want, *oos) {
+		t.Error(cmp.Diff(want, *oos))
+	}
+}
+
+func TestClient_RetriesGetOnTemporaryServerError(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer ts.Close()
+
+	c := newTestClient(t, ts.URL, client.WithRetries(2), client.WithBackoff("1ms"))
+	_, err := c.GetProducts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("want 3 requests, got %d", got)
+	}
+}
+
+func TestClient_DoesNotRetryPost(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	c := newTestClient(t, ts.URL, client.WithRetries(2), client.WithBackoff("1ms"))
+	_, err := c.CreateCart(context.Background())
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("want 503 API error, got %v", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("want 1 request, got %d", got)
+	}
+}
+
+func TestNew_FailsOnInvalidBaseURL(t *testing.T) {
+	t.Parallel()
+
+	_, err := client.New("localhost")
+	if err == nil {
+		t.Error("want error on base URL without scheme")
+	}
+}
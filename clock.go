@@ -0,0 +1,206 @@
+package coffeeshop
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and schedules functions. The server uses it
+// to move orders through their lifecycle, to expire sessions and
+// promotions, to timestamp changes and to retry webhook deliveries.
+// Simulated latency and timeouts always use the real time.
+type Clock interface {
+	Now() time.Time
+	// After sends the time on the returned channel
+	// once the duration elapses.
+	After(d time.Duration) <-chan time.Time
+	// AfterFunc calls the function once the duration elapses.
+	AfterFunc(d time.Duration, f func())
+}
+
+// realClock tells the real time.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) {
+	time.AfterFunc(d, f)
+}
+
+// FakeClock is a clock moving only when it is advanced, so tests
+// can fast-forward order lifecycles and expiry without waiting.
+type FakeClock struct {
+	mx      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+// fakeWaiter is a function scheduled on the fake clock.
+type fakeWaiter struct {
+	at time.Time
+	f  func()
+}
+
+// NewFakeClock returns the fake clock stopped at the time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.now
+}
+
+// After sends the time on the returned channel
+// once the clock is advanced by the duration.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.AfterFunc(d, func() {
+		ch <- c.Now()
+	})
+	return ch
+}
+
+// AfterFunc calls the function once the clock is advanced by the
+// duration. Functions are called by Advance, one at a time.
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), f: f})
+}
+
+// Advance moves the clock forward by the duration and calls functions
+// scheduled up to the new time in order, including functions they
+// schedule. Functions scheduled by goroutines woken by Advance may be
+// scheduled after it returns.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mx.Lock()
+	target := c.now.Add(d)
+	c.mx.Unlock()
+	for {
+		c.mx.Lock()
+		next := -1
+		for i, w := range c.waiters {
+			if !w.at.After(target) && (next < 0 || w.at.Before(c.waiters[next].at)) {
+				next = i
+			}
+		}
+		if next < 0 {
+			c.now = target
+			c.mx.Unlock()
+			return
+		}
+		w := c.waiters[next]
+		c.waiters = append(c.waiters[:next], c.waiters[next+1:]...)
+		if w.at.After(c.now) {
+			c.now = w.at
+		}
+		c.mx.Unlock()
+		w.f()
+	}
+}
+
+// WithClock configures the clock of the server and of stores
+// without a clock, for example a FakeClock advanced with
+// POST /admin/time/advance.
+func WithClock(c Clock) Option {
+	return func(s *Server) error {
+		if c == nil {
+			return errors.New("nil clock")
+		}
+		s.Clock = c
+		return nil
+	}
+}
+
+// clockUser is implemented by stores timestamping changes.
+type clockUser interface {
+	useClock(c Clock)
+}
+
+// shareClock passes the clock of the server to stores,
+// the ID generator and the broker of order events.
+func (cs *Server) shareClock() {
+	for _, store := range []any{cs.Store, cs.OrderStore, cs.IDGenerator, &cs.orderEvents} {
+		if u, ok := store.(clockUser); ok {
+			u.useClock(cs.Clock)
+		}
+	}
+}
+
+func (ms *MemoryStore) useClock(c Clock) {
+	if ms.Clock == nil {
+		ms.Clock = c
+	}
+	ms.events.useClock(ms.Clock)
+	ms.loadTime()
+}
+
+func (rs *RedisStore) useClock(c Clock) {
+	if rs.Clock == nil {
+		rs.Clock = c
+	}
+}
+
+func (ms *MongoStore) useClock(c Clock) {
+	if ms.Clock == nil {
+		ms.Clock = c
+	}
+}
+
+func (ms *MemoryOrderStore) useClock(c Clock) {
+	if ms.Clock == nil {
+		ms.Clock = c
+	}
+}
+
+// now returns the time of the clock, or the real time without one.
+func now(c Clock) time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
+
+// serverTime is the time of the server clock.
+type serverTime struct {
+	Now time.Time `json:"now"`
+}
+
+// GetTime returns the time of the server clock.
+func (cs *Server) GetTime(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, serverTime{Now: cs.Clock.Now()})
+}
+
+// AdvanceTime moves the fake clock of the server forward by the
+// duration in the request, for example {"duration": "5m"}.
+func (cs *Server) AdvanceTime(w http.ResponseWriter, r *http.Request) {
+	fc, ok := cs.Clock.(*FakeClock)
+	if !ok {
+		writeError(w, r, http.StatusNotImplemented, "server doesn't use a fake clock")
+		return
+	}
+	var req struct {
+		Duration string `json:"duration"`
+	}
+	if !cs.decodeJSON(w, r, &req, "invalid time advance") {
+		return
+	}
+	d, err := time.ParseDuration(req.Duration)
+	if err != nil || d < 0 {
+		writeFieldErrors(w, r, "invalid time advance", []FieldError{{Field: "duration", Message: "must be a non-negative duration, for example \"5m\""}})
+		return
+	}
+	fc.Advance(d)
+	writeJSON(w, r, http.StatusOK, serverTime{Now: fc.Now()})
+}
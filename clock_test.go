@@ -0,0 +1,142 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// advanceTime sends POST /admin/time/advance with the body.
+func advanceTime(t *testing.T, url, body string) *http.Response {
+	t.Helper()
+	resp, err := http.Post(url+"admin/time/advance", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestFakeClock_CallsFunctionsScheduledUpToAdvancedTime(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2023, 5, 1, 8, 0, 0, 0, time.UTC)
+	clock := coffeeshop.NewFakeClock(start)
+	var got []string
+	clock.AfterFunc(2*time.Minute, func() { got = append(got, "second") })
+	clock.AfterFunc(time.Minute, func() {
+		got = append(got, "first")
+		clock.AfterFunc(30*time.Second, func() { got = append(got, "nested") })
+	})
+	clock.AfterFunc(time.Hour, func() { got = append(got, "late") })
+
+	clock.Advance(2 * time.Minute)
+
+	want := []string{"first", "nested", "second"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("want calls %v, got %v", want, got)
+	}
+	if now := clock.Now(); !now.Equal(start.Add(2 * time.Minute)) {
+		t.Errorf("want time %v, got %v", start.Add(2*time.Minute), now)
+	}
+}
+
+func TestServer_AdvancingFakeClockMovesOrderThroughLifecycle(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2023, 5, 1, 8, 0, 0, 0, time.UTC)
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(10)}, "0s", t,
+		coffeeshop.WithOrderInterval("1h"),
+		coffeeshop.WithClock(coffeeshop.NewFakeClock(start)),
+	)
+	resp := createOrder(t, shop.URL, `{"items":[{"productId":"7","quantity":1}]}`)
+	resp.Body.Close()
+
+	resp = advanceTime(t, shop.URL, `{"duration":"3h"}`)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200, got %d", resp.StatusCode)
+	}
+	var now struct {
+		Now time.Time `json:"now"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&now); err != nil {
+		t.Fatal(err)
+	}
+	if !now.Now.Equal(start.Add(3 * time.Hour)) {
+		t.Errorf("want time %v, got %v", start.Add(3*time.Hour), now.Now)
+	}
+
+	resp, err := http.Get(shop.URL + "orders/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got coffeeshop.Order
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != coffeeshop.OrderCollected {
+		t.Errorf("want status %q, got %q", coffeeshop.OrderCollected, got.Status)
+	}
+	if !got.UpdatedAt.Equal(start.Add(3 * time.Hour)) {
+		t.Errorf("want order updated at %v, got %v", start.Add(3*time.Hour), got.UpdatedAt)
+	}
+}
+
+func TestServer_Returns400OnInvalidTimeAdvance(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t,
+		coffeeshop.WithClock(coffeeshop.NewFakeClock(time.Now())),
+	)
+	for _, body := range []string{`{"duration":"soon"}`, `{"duration":"-1m"}`} {
+		resp := advanceTime(t, shop.URL, body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: want HTTP 400, got %d", body, resp.StatusCode)
+		}
+	}
+}
+
+func TestServer_Returns501OnTimeAdvanceWithRealClock(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t)
+	resp := advanceTime(t, shop.URL, `{"duration":"1m"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Errorf("want HTTP 501, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_TimestampsProductEventsWithClock(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2023, 5, 1, 8, 0, 0, 0, time.UTC)
+	store := &coffeeshop.MemoryStore{Products: stockedInventory(10)}
+	newCoffeShopTestServer(store, "0s", t,
+		coffeeshop.WithClock(coffeeshop.NewFakeClock(start)),
+	)
+	events, cancel := store.Subscribe()
+	defer cancel()
+
+	p, err := store.SetStock("1", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.UpdatedAt == nil || !p.UpdatedAt.Equal(start) {
+		t.Errorf("want product updated at %v, got %v", start, p.UpdatedAt)
+	}
+	select {
+	case e := <-events:
+		if !e.Time.Equal(start) {
+			t.Errorf("want event at %v, got %v", start, e.Time)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event published")
+	}
+}
@@ -0,0 +1,248 @@
+// Command coffeeshop serves the coffeeshop API, seeds store
+// backends with products and calls a running server.
+//
+// Usage:
+//
+//	coffeeshop serve [-config file] [-addr :8080] [-latency 2s] [-store memory://] [-inventory file]
+//	coffeeshop seed -store redis://localhost:6379 file
+//	coffeeshop client [-url http://localhost:8080] list|get ID|order ID:QUANTITY...
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/qba73/coffeeshop"
+	"github.com/qba73/coffeeshop/client"
+)
+
+const usage = `Usage: coffeeshop <command> [flags] [arguments]
+
+Commands:
+  serve    serve the coffeeshop API
+  seed     load products from a JSON or YAML file into a store
+  client   call a running server: list, get ID, order ID:QUANTITY...
+
+Run coffeeshop <command> -h for flags of the command.
+`
+
+// errUsage reports invalid command line arguments.
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	err := run(os.Args[1:], os.Stdout, os.Stderr)
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	default:
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run executes the command given in the arguments.
+func run(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return errUsage
+	}
+	switch args[0] {
+	case "serve":
+		return serve(args[1:], stderr)
+	case "seed":
+		return seed(args[1:], stdout, stderr)
+	case "client":
+		return callServer(args[1:], stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return nil
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usage)
+		return errUsage
+	}
+}
+
+// parse parses flags of the command, reporting problems
+// as usage errors.
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	return nil
+}
+
+// defaultStore returns the store DSN from the COFFEESHOP_STORE
+// environment variable or the memory store.
+func defaultStore() string {
+	if dsn, ok := os.LookupEnv("COFFEESHOP_STORE"); ok {
+		return dsn
+	}
+	return "memory://"
+}
+
+// serve serves the coffeeshop API until it is interrupted.
+// SIGHUP reloads the latency and the inventory from the
+// configuration file and the environment. Flags apply to
+// the start of the server only.
+func serve(args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	config := fs.String("config", os.Getenv("COFFEESHOP_CONFIG"), "YAML configuration file")
+	addr := fs.String("addr", ":8080", "address to listen on")
+	grpcAddr := fs.String("grpc-addr", "", "address serving the gRPC API, for example :9090")
+	latency := fs.String("latency", "2s", "latency of API requests")
+	dsn := fs.String("store", "memory://", "store DSN, for example redis://localhost:6379")
+	inventory := fs.String("inventory", "", "JSON or YAML file with products of the memory store")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	cfg, err := coffeeshop.LoadConfig(*config)
+	if err != nil {
+		return err
+	}
+	// Flags set on the command line override the configuration.
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			cfg.Addr = *addr
+		case "grpc-addr":
+			cfg.GRPCAddr = *grpcAddr
+		case "latency":
+			cfg.Latency.Base = *latency
+		case "store":
+			cfg.Store = *dsn
+		case "inventory":
+			cfg.Inventory = *inventory
+		}
+	})
+	server, err := coffeeshop.NewFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	server.ReloadOnSignal(ctx, *config)
+	fmt.Fprintln(stderr, server.About())
+	done := make(chan error, 1)
+	go func() {
+		done <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+// seed loads products from the file into the store.
+func seed(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dsn := fs.String("store", defaultStore(), "store DSN, for example redis://localhost:6379")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(stderr, "usage: coffeeshop seed [-store DSN] file")
+		return errUsage
+	}
+	store, err := coffeeshop.OpenStore(*dsn)
+	if err != nil {
+		return err
+	}
+	importer, ok := store.(coffeeshop.Importer)
+	if !ok {
+		return fmt.Errorf("store %T can't add products", store)
+	}
+	products, err := coffeeshop.LoadInventory(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	for _, p := range products {
+		if _, err := importer.PutProduct(p); err != nil {
+			return fmt.Errorf("product %s: %w", p.ID, err)
+		}
+	}
+	fmt.Fprintf(stdout, "seeded %d products\n", len(products))
+	return nil
+}
+
+// callServer sends a request to a running server
+// and prints the response as JSON.
+func callServer(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("client", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	url := fs.String("url", "http://localhost:8080", "URL of the server")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout of the request")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	c, err := client.New(*url)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	var out any
+	switch cmd, rest := fs.Arg(0), fs.Args(); {
+	case cmd == "list" && len(rest) == 1:
+		out, err = c.GetProducts(ctx)
+	case cmd == "get" && len(rest) == 2:
+		out, err = c.GetProduct(ctx, rest[1])
+	case cmd == "order" && len(rest) > 1:
+		items, perr := orderItems(rest[1:])
+		if perr != nil {
+			fmt.Fprintln(stderr, perr)
+			return errUsage
+		}
+		out, err = c.CreateOrder(ctx, items)
+	default:
+		fmt.Fprintln(stderr, "usage: coffeeshop client [-url URL] list|get ID|order ID:QUANTITY...")
+		return errUsage
+	}
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// orderItems parses items in the ID:QUANTITY format.
+// The quantity defaults to one.
+func orderItems(args []string) ([]coffeeshop.OrderItem, error) {
+	items := make([]coffeeshop.OrderItem, 0, len(args))
+	for _, arg := range args {
+		id, q, found := strings.Cut(arg, ":")
+		quantity := 1
+		if found {
+			n, err := strconv.Atoi(q)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid quantity in %q", arg)
+			}
+			quantity = n
+		}
+		if id == "" {
+			return nil, fmt.Errorf("missing product ID in %q", arg)
+		}
+		items = append(items, coffeeshop.OrderItem{ProductID: id, Quantity: quantity})
+	}
+	return items, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+	"github.com/qba73/coffeeshop/coffeeshoptest"
+)
+
+func TestRun_ClientGetsProductFromServer(t *testing.T) {
+	t.Parallel()
+
+	shop := coffeeshoptest.NewServer(t, coffeeshop.WithLatency("0s"))
+	var stdout bytes.Buffer
+	err := run([]string{"client", "-url", shop.URL, "get", "1"}, &stdout, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got coffeeshop.Product
+	if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != "1" {
+		t.Errorf("want product 1, got %q", got.ID)
+	}
+}
+
+func TestRun_ClientPlacesOrder(t *testing.T) {
+	t.Parallel()
+
+	shop := coffeeshoptest.NewServer(t, coffeeshop.WithLatency("0s"), coffeeshop.WithOrderInterval("1h"))
+	var stdout bytes.Buffer
+	err := run([]string{"client", "-url", shop.URL, "order", "1:2", "3"}, &stdout, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got coffeeshop.Order
+	if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []coffeeshop.OrderItem{{ProductID: "1", Quantity: 2}, {ProductID: "3", Quantity: 1}}
+	if !cmp.Equal(want, got.Items) {
+		t.Error(cmp.Diff(want, got.Items))
+	}
+}
+
+func TestRun_ReturnsUsageErrorOnInvalidArguments(t *testing.T) {
+	t.Parallel()
+
+	for _, args := range [][]string{
+		nil,
+		{"brew"},
+		{"seed"},
+		{"client", "get"},
+		{"client", "order", "1:none"},
+		{"serve", "-port", "80"},
+	} {
+		err := run(args, io.Discard, io.Discard)
+		if !errors.Is(err, errUsage) {
+			t.Errorf("%q: want usage error, got %v", args, err)
+		}
+	}
+}
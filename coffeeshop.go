@@ -5,50 +5,86 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/netip"
 	"os"
-	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"golang.org/x/exp/maps"
+	"google.golang.org/grpc"
 )
 
 // Product represents a product in the inventory.
 type Product struct {
-	ID         string     `json:"id"`
-	Type       string     `json:"type"`
-	Brand      string     `json:"brand"`
-	Name       string     `json:"name"`
-	Unit       string     `json:"unit,omitempty"`
-	Quantity   string     `json:"quantity,omitempty"`
-	Price      string     `json:"price,omitempty"`
-	Properties []Property `json:"properties,omitempty"`
+	ID         string     `json:"id" xml:"id"`
+	Type       string     `json:"type" xml:"type"`
+	Brand      string     `json:"brand" xml:"brand"`
+	Name       string     `json:"name" xml:"name"`
+	Unit       Unit       `json:"unit,omitempty" xml:"unit,omitempty"`
+	Quantity   Quantity   `json:"quantity,omitempty" xml:"quantity,omitempty"`
+	Price      Money      `json:"price" xml:"price"`
+	Stock      int        `json:"stock" xml:"stock"`
+	Properties []Property `json:"properties,omitempty" xml:"properties>property,omitempty"`
+	// Intensity of the product from 1 to 10. Zero means unknown.
+	Intensity int      `json:"intensity,omitempty" xml:"intensity,omitempty"`
+	Flavours  []string `json:"flavours,omitempty" xml:"flavours>flavour,omitempty"`
+	// SKU is the stock keeping unit and EAN the EAN-8 or EAN-13
+	// barcode of the product. Both are unique in the store.
+	SKU string `json:"sku,omitempty" xml:"sku,omitempty"`
+	EAN string `json:"ean,omitempty" xml:"ean,omitempty"`
+	// Descriptions of the product keyed by language, for example "en".
+	Descriptions map[string]string `json:"descriptions,omitempty" xml:"-"`
+	// Archived products are hidden from listings and can't be
+	// ordered, but remain available to orders referencing them.
+	Archived bool `json:"archived,omitempty" xml:"archived,omitempty"`
+	// Rating aggregates reviews of the product. It is set
+	// by the server when the product is read.
+	Rating *Rating `json:"rating,omitempty" xml:"rating,omitempty"`
+	// Version is incremented by stores on every change of the
+	// product. Zero means the product wasn't changed by the store.
+	Version int `json:"version,omitempty" xml:"version,omitempty"`
+	// CreatedAt and UpdatedAt are set by stores when the product
+	// is added and changed. They are nil for products which weren't
+	// changed by the store.
+	CreatedAt *time.Time `json:"createdAt,omitempty" xml:"createdAt,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty" xml:"updatedAt,omitempty"`
 }
 
 // Property holds additional, dynamic information about
 // the product.
 type Property struct {
-	Name  string `json:"name"`
-	Value string `json:"value"`
+	Name  string `json:"name" xml:"name,attr"`
+	Value string `json:"value" xml:",chardata"`
 }
 
+// Products holds products keyed by ID. Products are encoded
+// in JSON as an array sorted by ID.
 type Products map[string]Product
 
+// MarshalJSON encodes products as a JSON array sorted by ID.
 func (p Products) MarshalJSON() ([]byte, error) {
-	type ProductsAlias Products
-	pa := ProductsAlias(p)
-	data, err := json.Marshal(pa)
-	if err != nil {
-		return []byte{}, err
-	}
-	return data, nil
+	px := maps.Values(p)
+	sortProducts(px)
+	return json.Marshal(px)
 }
 
+// UnmarshalJSON decodes products from a JSON array or,
+// for compatibility, from an object keyed by product ID.
 func (p *Products) UnmarshalJSON(data []byte) error {
+	var px []Product
+	if err := json.Unmarshal(data, &px); err == nil {
+		*p = make(Products, len(px))
+		for _, product := range px {
+			(*p)[product.ID] = product
+		}
+		return nil
+	}
 	type ProductsAlias Products
 	var pa ProductsAlias
 	if err := json.Unmarshal(data, &pa); err != nil {
@@ -59,61 +95,92 @@ func (p *Products) UnmarshalJSON(data []byte) error {
 }
 
 // MemoryStore represents a storage for products
-// in the CoffeeShop.
+// in the CoffeeShop. Products are copied when they are
+// read and written, so callers never share them.
 //
 // Use memory store for testing and development.
-// For production use a SQL or NoSQL database.
+// For production use MongoStore, RedisStore or another database.
 type MemoryStore struct {
-	mx       sync.RWMutex
-	Products Products
+	mx     sync.RWMutex
+	events Broker
+	prices map[string][]PricePoint
+	// loadedAt is the time products were loaded into the store,
+	// set once by load unless products are reset.
+	load     sync.Once
+	loadedAt time.Time
+	// skus and eans index IDs of products by their codes.
+	skus map[string]string
+	eans map[string]string
+	// seq numbers changes of products recorded in changes for
+	// delta sync. Cursors older than syncFrom are expired.
+	// deletions lists tombstones in changes, oldest first.
+	seq       uint64
+	syncFrom  uint64
+	changes   map[string]productChange
+	deletions []deletion
+	Products  Products
+	// MaxTombstones limits tombstones of deleted products kept for
+	// delta sync. Zero means DefaultMaxTombstones.
+	MaxTombstones int
+	// Clock timestamps changes. Nil means the real time.
+	Clock Clock
 }
 
-// GetAll returns all products in the store.
+// GetAll returns all products in the store sorted by ID.
 func (ms *MemoryStore) GetAll() []Product {
 	ms.mx.RLock()
 	defer ms.mx.RUnlock()
-	return maps.Values(ms.Products)
+	px := cloneProducts(maps.Values(ms.Products))
+	sortProducts(px)
+	return px
 }
 
+// GetProduct returns the product with the given ID.
 func (ms *MemoryStore) GetProduct(id string) (Product, error) {
 	ms.mx.RLock()
 	defer ms.mx.RUnlock()
 	p, ok := ms.Products[id]
 	if !ok {
-		return Product{}, errors.New("product not found")
+		return Product{}, ErrProductNotFound
 	}
-	return p, nil
+	return p.clone(), nil
 }
 
-func (ms *MemoryStore) GetCoffee() []Product {
+// GetByType returns all products of the given type sorted by ID.
+// Product types are matched case-insensitively.
+func (ms *MemoryStore) GetByType(productType string) []Product {
 	ms.mx.RLock()
 	defer ms.mx.RUnlock()
-	var coffee []Product
-	for _, p := range maps.Values(ms.Products) {
-		if strings.ToLower(p.Type) == "coffee" {
-			coffee = append(coffee, p)
+	var px []Product
+	for _, p := range ms.Products {
+		if strings.EqualFold(p.Type, productType) {
+			px = append(px, p.clone())
 		}
 	}
-	return coffee
+	sortProducts(px)
+	return px
+}
+
+// GetCoffee returns all coffee products.
+//
+// Deprecated: Use GetByType("coffee") instead.
+func (ms *MemoryStore) GetCoffee() []Product {
+	return ms.GetByType("coffee")
 }
 
+// GetTea returns all tea products.
+//
+// Deprecated: Use GetByType("tea") instead.
 func (ms *MemoryStore) GetTea() []Product {
-	ms.mx.RLock()
-	defer ms.mx.RUnlock()
-	var tea []Product
-	for _, p := range maps.Values(ms.Products) {
-		if strings.ToLower(p.Type) == "tea" {
-			tea = append(tea, p)
-		}
-	}
-	return tea
+	return ms.GetByType("tea")
 }
 
 type Store interface {
 	GetAll() []Product
 	GetProduct(id string) (Product, error)
-	GetCoffee() []Product
-	GetTea() []Product
+	GetByType(productType string) []Product
+	ReserveStock(items []OrderItem) error
+	SetStock(id string, stock int) (Product, error)
 }
 
 func latencyFromEnv(key, fallback string) (time.Duration, error) {
@@ -135,12 +202,180 @@ type Server struct {
 	HTTPServer *http.Server
 	URL        string
 	Latency    time.Duration
-	Store      Store
+	// HeaderLatency lets clients override the latency
+	// with the X-Coffeeshop-Delay request header.
+	HeaderLatency bool
+	// LatencyJitter is the longest random latency added
+	// to the configured latency.
+	LatencyJitter time.Duration
+	// Throttle limits the bandwidth of responses of delayed
+	// route groups in bytes per second. Zero means no limit.
+	Throttle      int
+	Store         Store
+	OrderStore    OrderStore
+	OrderInterval time.Duration
+	// Baristas is the number of baristas preparing orders.
+	// Zero disables the barista queue.
+	Baristas int
+	// PreparationTimes holds how long a barista prepares a unit
+	// of a product, keyed by lower case product ID or type.
+	PreparationTimes map[string]time.Duration
+	CartStore        CartStore
+	ImageStore       ImageStore
+	ReviewStore      ReviewStore
+	CustomerStore    CustomerStore
+	PromotionStore   PromotionStore
+	Recommender      Recommender
+	MaxImageSize     int64
+	// MaxBodySize limits the size of JSON request bodies.
+	MaxBodySize      int64
+	PaymentSimulator PaymentSimulator
+	PaymentTimeout   time.Duration
+	StructuredPrices bool
+	// KeyedProducts makes lists of products encode in JSON
+	// as an object keyed by product ID instead of an array.
+	KeyedProducts bool
+	// Links adds hypermedia links to JSON representations
+	// of products.
+	Links bool
+	// EmptyListNotFound makes lists of products of a type
+	// respond with 404 Not Found when there are no products.
+	EmptyListNotFound bool
+	Rates             RateProvider
+	CacheControl      string
+	Compressor        *middleware.Compressor
+	Encoders          map[string]Encoder
+	Docs              bool
+	CORSOrigins       []string
+	TrustedProxies    []netip.Prefix
+	APIVersion        string
+	WebhookRetries    int
+	WebhookBackoff    time.Duration
+	HealthChecks      []Check
+	DelayedGroups     []RouteGroup
+	SnapshotPath      string
+	SnapshotInterval  time.Duration
+	// MaxConcurrentRequests limits requests in flight.
+	// Zero means no limit.
+	MaxConcurrentRequests int
+	// RetryAfter is sent to clients of shed requests.
+	RetryAfter time.Duration
+	// HandlerTimeout limits the time of handlers, not
+	// including the simulated latency.
+	HandlerTimeout time.Duration
+	// Middlewares wrap all routes.
+	Middlewares []func(http.Handler) http.Handler
+	// FaultRate is the fraction of API requests answered
+	// with FaultStatus instead of being served.
+	FaultRate   float64
+	FaultStatus int
+	// Scenario holds behaviours applied to API requests in order.
+	Scenario Scenario
+	// RecordingPath is the file recording API requests and responses.
+	RecordingPath string
+	// ReplayPath is the file with responses replayed to API requests.
+	ReplayPath string
+	// AuditSink records changes of products made through the API.
+	AuditSink AuditSink
+	// EventLog records events of products and orders for replay.
+	EventLog EventLog
+	// H2C enables cleartext HTTP/2.
+	H2C bool
+	// GRPCAddr is the address serving the gRPC API.
+	// Empty means gRPC is disabled.
+	GRPCAddr string
+	// Language is the language of descriptions returned to clients
+	// not accepting any of the available languages.
+	Language string
+	// Translations holds descriptions of products loaded from files.
+	Translations Translations
+	// PropertySchema defines properties of products
+	// and validates their values.
+	PropertySchema PropertySchema
+	// IDGenerator generates IDs of products created
+	// or imported without an ID.
+	IDGenerator IDGenerator
+	// Clock moves orders through their lifecycle, expires
+	// sessions and promotions and timestamps changes.
+	Clock Clock
+	// AdminToken is the bearer token required by /admin/ endpoints.
+	// Empty means the endpoints are open.
+	AdminToken string
+	// Profiling serves pprof profiles under /debug/pprof/.
+	Profiling bool
+	// IdempotencyWindow is how long responses to requests with an
+	// Idempotency-Key are replayed to requests repeating the key.
+	// Zero means DefaultIdempotencyWindow.
+	IdempotencyWindow time.Duration
+	// Policy restricts route groups to API keys with allowed roles.
+	Policy Policy
+	// MaxConnectionRequests is the number of requests served by
+	// a keep-alive connection before it's closed. Zero means no limit.
+	MaxConnectionRequests int
+	// MaxConnectionAge is how long keep-alive connections serve
+	// requests before they're closed. Zero means no limit.
+	MaxConnectionAge time.Duration
+	// DebugLogging logs requests and responses of API endpoints
+	// from the start. It can be switched at runtime.
+	DebugLogging bool
+	// Redactions are headers and JSON fields redacted from
+	// debug logs.
+	Redactions []string
+	// CacheTTL is how long products read from the store are
+	// cached. Zero disables the cache.
+	CacheTTL time.Duration
+	// BreakerThreshold is the number of consecutive failures of
+	// the store opening the circuit breaker for BreakerCooldown.
+	// Zero disables the breaker.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// RetryAttempts is the number of attempts of store calls failed
+	// with transient errors, with random backoffs from RetryBackoff
+	// up to RetryMaxBackoff. One or less disables retries.
+	RetryAttempts   int
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+	// Tenants enables isolated tenants served by the server.
+	Tenants bool
+	// TenantOptions configure servers of tenants.
+	TenantOptions []Option
+	tenants       tenantRegistry
+	seed          Products
+	snapshots     snapshotRegistry
+	startedAt     time.Time
+	orderEvents   Broker
+	eventIDs      eventSequence
+	webhooks      webhookRegistry
+	sessions      sessionRegistry
+	queue         baristaQueue
+	inFlight      requestSlots
+	scenario      scenarioProgress
+	random        *randomSource
+	debug         atomic.Bool
+	idempotency   idempotencyRegistry
+	breaker       *BreakerStore
+	retrying      *RetryingStore
+	latency       atomic.Int64
+	recorder      recorder
+	replay        *cassette
+	webhookClient *http.Client
+	listener      net.Listener
+	handler       http.Handler
+	start         sync.Once
+	mx            sync.Mutex
+	serving       bool
+	// shuttingDown is cancelled when the server starts shutting
+	// down to terminate long-lived streams.
+	shuttingDown context.Context
+	eventLog     eventLogWriter
+	grpcListener net.Listener
+	grpcServer   *grpc.Server
 }
 
-type option func(s *Server) error
+// Option configures the Server.
+type Option func(s *Server) error
 
-func WithLatency(l string) option {
+func WithLatency(l string) Option {
 	return func(s *Server) error {
 		d, err := time.ParseDuration(l)
 		if err != nil {
@@ -151,7 +386,7 @@ func WithLatency(l string) option {
 	}
 }
 
-func New(addr string, store Store, options ...option) (*Server, error) {
+func New(addr string, store Store, options ...Option) (*Server, error) {
 	latency, err := latencyFromEnv("COFFEESHOP_LATENCY", "100m")
 	if err != nil {
 		return nil, err
@@ -161,12 +396,42 @@ func New(addr string, store Store, options ...option) (*Server, error) {
 	srv := Server{
 		HTTPServer: &http.Server{
 			Addr:         addr,
-			ReadTimeout:  30 * time.Second,
-			WriteTimeout: 30 * time.Second,
+			ReadTimeout:  DefaultReadTimeout,
+			WriteTimeout: DefaultWriteTimeout,
 		},
-		URL:     fmt.Sprintf("http://%s/", addr),
-		Latency: latency,
-		Store:   store,
+		URL:              serverURL("http", addr),
+		Latency:          latency,
+		Store:            store,
+		OrderStore:       &MemoryOrderStore{},
+		OrderInterval:    5 * time.Second,
+		CartStore:        &MemoryCartStore{},
+		ImageStore:       &MemoryImageStore{},
+		ReviewStore:      &MemoryReviewStore{},
+		CustomerStore:    &MemoryCustomerStore{},
+		PromotionStore:   &MemoryPromotionStore{},
+		Recommender:      SimilarityRecommender{},
+		AuditSink:        &MemoryAuditLog{},
+		EventLog:         &MemoryEventLog{},
+		MaxImageSize:     DefaultMaxImageSize,
+		PaymentSimulator: DefaultPaymentSimulator,
+		PaymentTimeout:   10 * time.Second,
+		Rates:            DefaultRates,
+		Encoders:         DefaultEncoders(),
+		WebhookRetries:   5,
+		WebhookBackoff:   time.Second,
+		DelayedGroups:    []RouteGroup{APIRoutes},
+		APIVersion:       DefaultAPIVersion,
+		HandlerTimeout:   DefaultHandlerTimeout,
+		MaxBodySize:      DefaultMaxBodySize,
+		RetryAfter:       DefaultRetryAfter,
+		Language:         DefaultLanguage,
+		Redactions:       DefaultRedactions,
+		PropertySchema:   DefaultPropertySchema,
+		IDGenerator:      &UUIDv7{},
+		Clock:            realClock{},
+		random:           newRandomSource(time.Now().UnixNano()),
+		startedAt:        time.Now(),
+		webhookClient:    &http.Client{Timeout: 10 * time.Second},
 	}
 
 	for _, opt := range options {
@@ -174,9 +439,64 @@ func New(addr string, store Store, options ...option) (*Server, error) {
 			return nil, err
 		}
 	}
+	srv.shareEventIDs()
+	srv.logEvents()
+	srv.useRetries()
+	srv.useBreaker()
+	srv.useCache()
+	srv.trackConnections()
+	srv.shareClock()
+	srv.captureSeed()
+	srv.latency.Store(int64(srv.Latency))
+	srv.debug.Store(srv.DebugLogging)
+	if srv.SnapshotPath != "" {
+		if err := srv.restoreSnapshot(); err != nil {
+			return nil, err
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	srv.shuttingDown = ctx
+	srv.HTTPServer.RegisterOnShutdown(cancel)
 	return &srv, nil
 }
 
+// NewWithListener creates the server accepting connections on the
+// listener, for example a listener bound to a random port. Unlike
+// with New, the URL of the server holds the bound address before
+// the server starts.
+func NewWithListener(l net.Listener, store Store, options ...Option) (*Server, error) {
+	if l == nil {
+		return nil, errors.New("nil listener")
+	}
+	return New(listenerAddr(l), store, append([]Option{WithListener(l)}, options...)...)
+}
+
+// listen returns the listener of the server, binding the configured
+// address if the server was created without one. When the address
+// has port 0 the URL is updated with the bound port.
+func (cs *Server) listen() (net.Listener, error) {
+	if cs.listener != nil {
+		return cs.listener, nil
+	}
+	addr := cs.HTTPServer.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	network, address := splitAddr(addr)
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err == nil && network != "unix" && port == "0" {
+		_, bound, _ := net.SplitHostPort(l.Addr().String())
+		scheme, _, _ := strings.Cut(cs.URL, "://")
+		cs.URL = fmt.Sprintf("%s://%s/", scheme, net.JoinHostPort(host, bound))
+	}
+	cs.listener = l
+	return l, nil
+}
+
 func Delay(d time.Duration) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
@@ -187,98 +507,189 @@ func Delay(d time.Duration) func(next http.Handler) http.Handler {
 	}
 }
 
+// ErrServerStarted is returned by ListenAndServe
+// when the server already serves requests.
+var ErrServerStarted = errors.New("coffeeshop: server already started")
+
+// Handler returns the handler serving the coffeeshop routes, so the
+// server can be mounted in another router or an httptest.Server.
+// The first call starts background work of the server: delivery
+// of webhooks, baristas, snapshots and the lifecycle of orders
+// restored from a snapshot. Call Shutdown to stop it.
+func (cs *Server) Handler() http.Handler {
+	cs.start.Do(func() {
+		cs.handler = cs.routes()
+		cs.dispatchEvents()
+		cs.resumeOrders()
+		cs.startBaristas()
+		cs.snapshotPeriodically()
+	})
+	return cs.handler
+}
+
+// ListenAndServe serves requests on the listener of the server
+// or, if the server was created with New, on the configured address.
+// It returns ErrServerStarted if it was already called.
 func (cs *Server) ListenAndServe() error {
-	mux := chi.NewRouter()
-	mux.Use(
-		middleware.Timeout(120*time.Second),
-		middleware.SetHeader("Content-Type", "application/json; charset=utf-8"),
-		Delay(cs.Latency),
-	)
-	mux.Get("/products", cs.GetProducts)
-	mux.Get("/products/{productID}", cs.GetProduct)
-	mux.Get("/products/tea", cs.GetTea)
-	mux.Get("/products/coffee", cs.GetCoffee)
-	cs.HTTPServer.Handler = mux
-	return cs.HTTPServer.ListenAndServe()
+	cs.mx.Lock()
+	if cs.serving {
+		cs.mx.Unlock()
+		return ErrServerStarted
+	}
+	cs.serving = true
+	cs.mx.Unlock()
+	l, err := cs.listen()
+	if err != nil {
+		cs.mx.Lock()
+		cs.serving = false
+		cs.mx.Unlock()
+		return err
+	}
+	cs.HTTPServer.Handler = cs.Handler()
+	useTLS := cs.HTTPServer.TLSConfig != nil
+	if !useTLS && cs.H2C {
+		err = cs.serveH2C()
+	}
+	if err == nil {
+		err = cs.serveGRPC()
+	}
+	if err != nil {
+		cs.mx.Lock()
+		cs.serving = false
+		cs.mx.Unlock()
+		return err
+	}
+	if cs.HTTPServer.WriteTimeout > 0 {
+		cs.HTTPServer.WriteTimeout += cs.maxLatency()
+	}
+	if useTLS {
+		return cs.HTTPServer.ServeTLS(l, "", "")
+	}
+	return cs.HTTPServer.Serve(l)
 }
 
+// Shutdown gracefully shuts down the server, the gRPC server and
+// servers of tenants and, if snapshots are enabled, writes the last
+// snapshot.
 func (cs *Server) Shutdown(ctx context.Context) error {
-	return cs.HTTPServer.Shutdown(ctx)
+	err := cs.HTTPServer.Shutdown(ctx)
+	cs.stopGRPC(ctx)
+	if terr := cs.tenants.shutdown(ctx); err == nil {
+		err = terr
+	}
+	cs.eventLog.close()
+	if cs.SnapshotPath != "" {
+		if serr := cs.writeSnapshot(); err == nil {
+			err = serr
+		}
+	}
+	return err
 }
 
 func (cs *Server) GetProducts(w http.ResponseWriter, r *http.Request) {
-	products := cs.Store.GetAll()
-	data, err := json.MarshalIndent(products, "", "  ")
+	ids, batch, err := requestedIDs(r)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
-	if _, err := w.Write(data); err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
+	var all []Product
+	if batch {
+		all, err = getMany(cs.store(r), ids)
+		if err != nil {
+			writeStoreError(w, r, err)
+			return
+		}
+	} else {
+		all, err = listAll(cs.store(r))
+		if err != nil {
+			writeStoreError(w, r, err)
+			return
+		}
 	}
-}
-
-func (cs *Server) GetProduct(w http.ResponseWriter, r *http.Request) {
-	productID := chi.URLParam(r, "productID")
-	product, err := cs.Store.GetProduct(productID)
+	products, err := listed(r, all)
 	if err != nil {
-		http.Error(w, "product not found", http.StatusNotFound)
+		writeError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
-	data, err := json.MarshalIndent(product, "", "  ")
+	products, err = cs.convertPrices(w, r, products)
 	if err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		writeError(w, r, http.StatusBadRequest, "unsupported currency")
 		return
 	}
-	_, err = w.Write(data)
-	if err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
+	cs.addRatings(products)
+	// Products looked up by IDs stay in the order of
+	// the IDs unless the client asks to sort them.
+	if !batch || r.URL.Query().Get("sort") != "" {
+		if err := sortRequested(r, products); err != nil {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
 	}
-}
-
-func (cs *Server) GetCoffee(w http.ResponseWriter, r *http.Request) {
-	products := cs.Store.GetCoffee()
-	if len(products) == 0 {
-		http.Error(w, "product not found", http.StatusNotFound)
+	cached, err := cs.notModified(w, r, products...)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal error")
 		return
 	}
-	data, err := json.MarshalIndent(products, "", "  ")
-	if err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
+	if cached {
 		return
 	}
-	_, err = w.Write(data)
+	cs.writeProducts(w, r, products)
+}
+
+func (cs *Server) GetProduct(w http.ResponseWriter, r *http.Request) {
+	productID := chi.URLParam(r, "productID")
+	product, err := cs.store(r).GetProduct(productID)
 	if err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		writeStoreError(w, r, err)
+		return
 	}
+	cs.writeProduct(w, r, product)
 }
 
-func (cs *Server) GetTea(w http.ResponseWriter, r *http.Request) {
-	products := cs.Store.GetTea()
-	if len(products) == 0 {
-		http.Error(w, "product not found", http.StatusNotFound)
+// writeProduct responds with the product, its price converted to
+// the requested currency and its rating, unless the client has
+// a fresh copy of it.
+func (cs *Server) writeProduct(w http.ResponseWriter, r *http.Request, product Product) {
+	converted, err := cs.convertPrices(w, r, []Product{product})
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "unsupported currency")
 		return
 	}
-	data, err := json.MarshalIndent(products, "", "  ")
+	cs.addRatings(converted)
+	cached, err := cs.notModified(w, r, converted[0])
 	if err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, "internal error")
 		return
 	}
-	_, err = w.Write(data)
-	if err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
+	if cached {
+		return
 	}
+	cs.writeProducts(w, r, converted[0])
 }
 
+func (cs *Server) GetCoffee(w http.ResponseWriter, r *http.Request) {
+	cs.writeProductsByType(w, r, "coffee")
+}
+
+func (cs *Server) GetTea(w http.ResponseWriter, r *http.Request) {
+	cs.writeProductsByType(w, r, "tea")
+}
+
+// Run serves the coffeeshop configured by the YAML file named in
+// the COFFEESHOP_CONFIG environment variable, if set, and by other
+// environment variables described by Config. SIGHUP reloads
+// the latency and the inventory.
 func Run() error {
-	store := MemoryStore{
-		Products: inventory,
+	cfg, err := LoadConfig(os.Getenv("COFFEESHOP_CONFIG"))
+	if err != nil {
+		return err
 	}
-	addr := fmt.Sprintf(":%s", strconv.Itoa(8080))
-	server, err := New(addr, &store, WithLatency("2s"))
+	server, err := NewFromConfig(cfg)
 	if err != nil {
 		return err
 	}
+	server.ReloadOnSignal(context.Background(), os.Getenv("COFFEESHOP_CONFIG"))
+	server.logf("%s", server.About())
 	return server.ListenAndServe()
 }
 
@@ -288,9 +699,10 @@ var inventory = map[string]Product{
 		Type:     "Coffee",
 		Brand:    "Segafredo",
 		Name:     "Intermezzo",
-		Unit:     "gram",
-		Quantity: "1000",
-		Price:    "7.99",
+		Unit:     Gram,
+		Quantity: 1000,
+		Price:    Money{Amount: 799, Currency: DefaultCurrency},
+		Stock:    25,
 		Properties: []Property{
 			{Name: "flavour", Value: "Acidic Robusta, Nuts, Aromatic Arabica, Caramel, Medium roasted beans"},
 			{Name: "property", Value: "1000 grams, Arabica/Robusta"},
@@ -303,9 +715,10 @@ var inventory = map[string]Product{
 		Type:     "Coffee",
 		Brand:    "Segafredo",
 		Name:     "Caffé Crema Gustoso",
-		Unit:     "gram",
-		Quantity: "1000",
-		Price:    "11.99",
+		Unit:     Gram,
+		Quantity: 1000,
+		Price:    Money{Amount: 1199, Currency: DefaultCurrency},
+		Stock:    40,
 		Properties: []Property{
 			{Name: "flavour", Value: "Acidic Robusta, Nuts, Aromatic Arabica, Medium roasted beans"},
 			{Name: "property", Value: "1000 grams, Arabica/Robusta"},
@@ -318,9 +731,10 @@ var inventory = map[string]Product{
 		Type:     "Coffee",
 		Brand:    "Segafredo",
 		Name:     "Selezione Espresso",
-		Unit:     "gram",
-		Quantity: "1000",
-		Price:    "10.49",
+		Unit:     Gram,
+		Quantity: 1000,
+		Price:    Money{Amount: 1049, Currency: DefaultCurrency},
+		Stock:    30,
 		Properties: []Property{
 			{Name: "flavour", Value: "Dark Chocolate, Acidic Robusta, Dark roasted beans, Aromatic Arabica"},
 			{Name: "property", Value: "1000 grams, Arabica/Robusta"},
@@ -332,9 +746,10 @@ var inventory = map[string]Product{
 		Type:     "Coffee",
 		Brand:    "illy",
 		Name:     "Intenso",
-		Unit:     "gram",
-		Quantity: "250",
-		Price:    "7.99",
+		Unit:     Gram,
+		Quantity: 250,
+		Price:    Money{Amount: 799, Currency: DefaultCurrency},
+		Stock:    50,
 		Properties: []Property{
 			{Name: "flavour", Value: "Fruit, Chocolate, Dark roasted beans, Bitterness"},
 			{Name: "property", Value: "250 grams, Arabica"},
@@ -347,9 +762,10 @@ var inventory = map[string]Product{
 		Type:     "Coffee",
 		Brand:    "illy",
 		Name:     "Guatemala",
-		Unit:     "gram",
-		Quantity: "250",
-		Price:    "7.99",
+		Unit:     Gram,
+		Quantity: 250,
+		Price:    Money{Amount: 799, Currency: DefaultCurrency},
+		Stock:    20,
 		Properties: []Property{
 			{Name: "flavour", Value: "Honey, Caramel, Sweetness"},
 			{Name: "property", Value: "250 gram, Arabica"},
@@ -362,9 +778,10 @@ var inventory = map[string]Product{
 		Type:     "Coffee",
 		Brand:    "Lavazza",
 		Name:     "Espresso Barista Perfetto",
-		Unit:     "gram",
-		Quantity: "1000",
-		Price:    "12.99",
+		Unit:     Gram,
+		Quantity: 1000,
+		Price:    Money{Amount: 1299, Currency: DefaultCurrency},
+		Stock:    35,
 		Properties: []Property{
 			{Name: "flavour", Value: "Aromatic Arabica, Medium roasted beans"},
 			{Name: "property", Value: "250 gram, Arabica"},
@@ -377,9 +794,10 @@ var inventory = map[string]Product{
 		Type:     "Tea",
 		Brand:    "Caykur",
 		Name:     "Green Tea",
-		Unit:     "gram",
-		Quantity: "150",
-		Price:    "4.99",
+		Unit:     Gram,
+		Quantity: 150,
+		Price:    Money{Amount: 499, Currency: DefaultCurrency},
+		Stock:    60,
 	},
 
 	"8": {
@@ -387,8 +805,9 @@ var inventory = map[string]Product{
 		Type:     "Tea",
 		Brand:    "Greeting Opine",
 		Name:     "Jasmin Tea",
-		Unit:     "gram",
-		Quantity: "250",
-		Price:    "7.49",
+		Unit:     Gram,
+		Quantity: 250,
+		Price:    Money{Amount: 749, Currency: DefaultCurrency},
+		Stock:    45,
 	},
 }
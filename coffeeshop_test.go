@@ -4,50 +4,25 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"log"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/qba73/coffeeshop"
+	"github.com/qba73/coffeeshop/coffeeshoptest"
 	"golang.org/x/exp/maps"
 	"golang.org/x/exp/slices"
 )
 
-func newCoffeShopTestServer(store coffeeshop.Store, latency string, t *testing.T) *coffeeshop.Server {
+func newCoffeShopTestServer(store coffeeshop.Store, latency string, t *testing.T, opts ...coffeeshop.Option) *coffeeshop.Server {
 	t.Helper()
 
-	l, err := net.Listen("tcp", ":0")
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer l.Close()
-
-	addr := l.Addr().String()
-	cs, err := coffeeshop.New(addr, store, coffeeshop.WithLatency(latency))
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	go func() {
-		err := cs.ListenAndServe()
-		if !errors.Is(err, http.ErrServerClosed) {
-			log.Fatal(err)
-		}
-	}()
-
-	// Cleanup is called after each test function.
-	// We do not need to call `defer server close` in each test function.
-	t.Cleanup(func() {
-		err := cs.Shutdown(context.Background())
-		if err != nil {
-			t.Fatal(err)
-		}
-	})
-	return cs
+	opts = append([]coffeeshop.Option{coffeeshop.WithStore(store), coffeeshop.WithLatency(latency)}, opts...)
+	return coffeeshoptest.NewServer(t, opts...).Server
 }
 
 func TestGetAll_ReturnsAllItemsFromStore(t *testing.T) {
@@ -84,9 +59,9 @@ func TestGetProduct_ReturnsSingleItemFromStore(t *testing.T) {
 		Type:     "Coffee",
 		Brand:    "Segafredo",
 		Name:     "Caffé Crema Gustoso",
-		Unit:     "gram",
-		Quantity: "1000",
-		Price:    "11.99",
+		Unit:     coffeeshop.Gram,
+		Quantity: 1000,
+		Price:    coffeeshop.Money{Amount: 1199, Currency: "EUR"},
 		Properties: []coffeeshop.Property{
 			{Name: "flavour", Value: "Acidic Robusta, Nuts, Aromatic Arabica, Medium roasted beans"},
 			{Name: "property", Value: "1000 grams, Arabica/Robusta"},
@@ -284,18 +259,18 @@ func TestServer_ReturnsAllTeaTypes(t *testing.T) {
 			Type:     "Tea",
 			Brand:    "Caykur",
 			Name:     "Green Tea",
-			Unit:     "gram",
-			Quantity: "150",
-			Price:    "4.99",
+			Unit:     coffeeshop.Gram,
+			Quantity: 150,
+			Price:    coffeeshop.Money{Amount: 499, Currency: "EUR"},
 		},
 		{
 			ID:       "8",
 			Type:     "Tea",
 			Brand:    "Greeting Opine",
 			Name:     "Jasmin Tea",
-			Unit:     "gram",
-			Quantity: "250",
-			Price:    "7.49",
+			Unit:     coffeeshop.Gram,
+			Quantity: 250,
+			Price:    coffeeshop.Money{Amount: 749, Currency: "EUR"},
 		},
 	}
 
@@ -335,9 +310,9 @@ func TestServer_ReturnsAllCoffeeTypes(t *testing.T) {
 			Type:     "Coffee",
 			Brand:    "Segafredo",
 			Name:     "Intermezzo",
-			Unit:     "gram",
-			Quantity: "1000",
-			Price:    "7.99",
+			Unit:     coffeeshop.Gram,
+			Quantity: 1000,
+			Price:    coffeeshop.Money{Amount: 799, Currency: "EUR"},
 			Properties: []coffeeshop.Property{
 				{Name: "flavour", Value: "Acidic Robusta, Nuts, Aromatic Arabica, Caramel, Medium roasted beans"},
 				{Name: "property", Value: "1000 grams, Arabica/Robusta"},
@@ -350,9 +325,9 @@ func TestServer_ReturnsAllCoffeeTypes(t *testing.T) {
 			Type:     "Coffee",
 			Brand:    "Segafredo",
 			Name:     "Caffé Crema Gustoso",
-			Unit:     "gram",
-			Quantity: "1000",
-			Price:    "11.99",
+			Unit:     coffeeshop.Gram,
+			Quantity: 1000,
+			Price:    coffeeshop.Money{Amount: 1199, Currency: "EUR"},
 			Properties: []coffeeshop.Property{
 				{Name: "flavour", Value: "Acidic Robusta, Nuts, Aromatic Arabica, Medium roasted beans"},
 				{Name: "property", Value: "1000 grams, Arabica/Robusta"},
@@ -365,9 +340,9 @@ func TestServer_ReturnsAllCoffeeTypes(t *testing.T) {
 			Type:     "Coffee",
 			Brand:    "Segafredo",
 			Name:     "Selezione Espresso",
-			Unit:     "gram",
-			Quantity: "1000",
-			Price:    "10.49",
+			Unit:     coffeeshop.Gram,
+			Quantity: 1000,
+			Price:    coffeeshop.Money{Amount: 1049, Currency: "EUR"},
 			Properties: []coffeeshop.Property{
 				{Name: "flavour", Value: "Dark Chocolate, Acidic Robusta, Dark roasted beans, Aromatic Arabica"},
 				{Name: "property", Value: "1000 grams, Arabica/Robusta"},
@@ -379,9 +354,9 @@ func TestServer_ReturnsAllCoffeeTypes(t *testing.T) {
 			Type:     "Coffee",
 			Brand:    "illy",
 			Name:     "Intenso",
-			Unit:     "gram",
-			Quantity: "250",
-			Price:    "7.99",
+			Unit:     coffeeshop.Gram,
+			Quantity: 250,
+			Price:    coffeeshop.Money{Amount: 799, Currency: "EUR"},
 			Properties: []coffeeshop.Property{
 				{Name: "flavour", Value: "Fruit, Chocolate, Dark roasted beans, Bitterness"},
 				{Name: "property", Value: "250 grams, Arabica"},
@@ -394,9 +369,9 @@ func TestServer_ReturnsAllCoffeeTypes(t *testing.T) {
 			Type:     "Coffee",
 			Brand:    "illy",
 			Name:     "Guatemala",
-			Unit:     "gram",
-			Quantity: "250",
-			Price:    "7.99",
+			Unit:     coffeeshop.Gram,
+			Quantity: 250,
+			Price:    coffeeshop.Money{Amount: 799, Currency: "EUR"},
 			Properties: []coffeeshop.Property{
 				{Name: "flavour", Value: "Honey, Caramel, Sweetness"},
 				{Name: "property", Value: "250 gram, Arabica"},
@@ -409,9 +384,9 @@ func TestServer_ReturnsAllCoffeeTypes(t *testing.T) {
 			Type:     "Coffee",
 			Brand:    "Lavazza",
 			Name:     "Espresso Barista Perfetto",
-			Unit:     "gram",
-			Quantity: "1000",
-			Price:    "12.99",
+			Unit:     coffeeshop.Gram,
+			Quantity: 1000,
+			Price:    coffeeshop.Money{Amount: 1299, Currency: "EUR"},
 			Properties: []coffeeshop.Property{
 				{Name: "flavour", Value: "Aromatic Arabica, Medium roasted beans"},
 				{Name: "property", Value: "250 gram, Arabica"},
@@ -432,9 +407,9 @@ var (
 			Type:     "Coffee",
 			Brand:    "Segafredo",
 			Name:     "Intermezzo",
-			Unit:     "gram",
-			Quantity: "1000",
-			Price:    "7.99",
+			Unit:     coffeeshop.Gram,
+			Quantity: 1000,
+			Price:    coffeeshop.Money{Amount: 799, Currency: "EUR"},
 			Properties: []coffeeshop.Property{
 				{Name: "flavour", Value: "Acidic Robusta, Nuts, Aromatic Arabica, Caramel, Medium roasted beans"},
 				{Name: "property", Value: "1000 grams, Arabica/Robusta"},
@@ -447,9 +422,9 @@ var (
 			Type:     "Coffee",
 			Brand:    "Segafredo",
 			Name:     "Caffé Crema Gustoso",
-			Unit:     "gram",
-			Quantity: "1000",
-			Price:    "11.99",
+			Unit:     coffeeshop.Gram,
+			Quantity: 1000,
+			Price:    coffeeshop.Money{Amount: 1199, Currency: "EUR"},
 			Properties: []coffeeshop.Property{
 				{Name: "flavour", Value: "Acidic Robusta, Nuts, Aromatic Arabica, Medium roasted beans"},
 				{Name: "property", Value: "1000 grams, Arabica/Robusta"},
@@ -462,9 +437,9 @@ var (
 			Type:     "Coffee",
 			Brand:    "Segafredo",
 			Name:     "Selezione Espresso",
-			Unit:     "gram",
-			Quantity: "1000",
-			Price:    "10.49",
+			Unit:     coffeeshop.Gram,
+			Quantity: 1000,
+			Price:    coffeeshop.Money{Amount: 1049, Currency: "EUR"},
 			Properties: []coffeeshop.Property{
 				{Name: "flavour", Value: "Dark Chocolate, Acidic Robusta, Dark roasted beans, Aromatic Arabica"},
 				{Name: "property", Value: "1000 grams, Arabica/Robusta"},
@@ -476,9 +451,9 @@ var (
 			Type:     "Coffee",
 			Brand:    "illy",
 			Name:     "Intenso",
-			Unit:     "gram",
-			Quantity: "250",
-			Price:    "7.99",
+			Unit:     coffeeshop.Gram,
+			Quantity: 250,
+			Price:    coffeeshop.Money{Amount: 799, Currency: "EUR"},
 			Properties: []coffeeshop.Property{
 				{Name: "flavour", Value: "Fruit, Chocolate, Dark roasted beans, Bitterness"},
 				{Name: "property", Value: "250 grams, Arabica"},
@@ -491,9 +466,9 @@ var (
 			Type:     "Coffee",
 			Brand:    "illy",
 			Name:     "Guatemala",
-			Unit:     "gram",
-			Quantity: "250",
-			Price:    "7.99",
+			Unit:     coffeeshop.Gram,
+			Quantity: 250,
+			Price:    coffeeshop.Money{Amount: 799, Currency: "EUR"},
 			Properties: []coffeeshop.Property{
 				{Name: "flavour", Value: "Honey, Caramel, Sweetness"},
 				{Name: "property", Value: "250 gram, Arabica"},
@@ -506,9 +481,9 @@ var (
 			Type:     "Coffee",
 			Brand:    "Lavazza",
 			Name:     "Espresso Barista Perfetto",
-			Unit:     "gram",
-			Quantity: "1000",
-			Price:    "12.99",
+			Unit:     coffeeshop.Gram,
+			Quantity: 1000,
+			Price:    coffeeshop.Money{Amount: 1299, Currency: "EUR"},
 			Properties: []coffeeshop.Property{
 				{Name: "flavour", Value: "Aromatic Arabica, Medium roasted beans"},
 				{Name: "property", Value: "250 gram, Arabica"},
@@ -521,9 +496,9 @@ var (
 			Type:     "Tea",
 			Brand:    "Caykur",
 			Name:     "Green Tea",
-			Unit:     "gram",
-			Quantity: "150",
-			Price:    "4.99",
+			Unit:     coffeeshop.Gram,
+			Quantity: 150,
+			Price:    coffeeshop.Money{Amount: 499, Currency: "EUR"},
 		},
 
 		"8": {
@@ -531,9 +506,111 @@ var (
 			Type:     "Tea",
 			Brand:    "Greeting Opine",
 			Name:     "Jasmin Tea",
-			Unit:     "gram",
-			Quantity: "250",
-			Price:    "7.49",
+			Unit:     coffeeshop.Gram,
+			Quantity: 250,
+			Price:    coffeeshop.Money{Amount: 749, Currency: "EUR"},
 		},
 	}
 )
+
+func TestNewWithListener_ServesOnListener(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs, err := coffeeshop.NewWithListener(l, &coffeeshop.MemoryStore{Products: inventory}, coffeeshop.WithLatency("0s"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "http://" + l.Addr().String() + "/"; cs.URL != want {
+		t.Errorf("want URL %s, got %s", want, cs.URL)
+	}
+	go cs.ListenAndServe()
+	t.Cleanup(func() {
+		if err := cs.Shutdown(context.Background()); err != nil {
+			t.Error(err)
+		}
+	})
+
+	resp, err := http.Get(cs.URL + "products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("want HTTP 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestNewWithListener_RejectsNilListener(t *testing.T) {
+	t.Parallel()
+
+	if _, err := coffeeshop.NewWithListener(nil, &coffeeshop.MemoryStore{}); err == nil {
+		t.Error("want error for nil listener")
+	}
+}
+
+func TestServer_HandlerServesRoutesWithoutListening(t *testing.T) {
+	t.Parallel()
+
+	cs, err := coffeeshop.New("", &coffeeshop.MemoryStore{Products: stockedInventory(5)},
+		coffeeshop.WithLatency("0s"),
+		coffeeshop.WithBaristas(1),
+		coffeeshop.WithPreparationTime("1", "1ms"),
+		coffeeshop.WithOrderInterval("1ms"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(cs.Handler())
+	t.Cleanup(func() {
+		ts.Close()
+		if err := cs.Shutdown(context.Background()); err != nil {
+			t.Error(err)
+		}
+	})
+
+	resp := createOrder(t, ts.URL+"/", `{"items":[{"productId":"1","quantity":1}]}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("want HTTP 201, got %d", resp.StatusCode)
+	}
+	// Baristas work when the server is mounted in another server.
+	deadline := time.Now().Add(5 * time.Second)
+	for orderStatus(t, ts.URL+"/", "1") != coffeeshop.OrderCollected {
+		if time.Now().After(deadline) {
+			t.Fatal("order not collected before deadline")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServer_ListenAndServeReturnsErrorWhenStarted(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs, err := coffeeshop.NewWithListener(l, &coffeeshop.MemoryStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			errc <- cs.ListenAndServe()
+		}()
+	}
+	if err := <-errc; !errors.Is(err, coffeeshop.ErrServerStarted) {
+		t.Errorf("want ErrServerStarted, got %v", err)
+	}
+	if err := cs.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("want ErrServerClosed, got %v", err)
+	}
+}
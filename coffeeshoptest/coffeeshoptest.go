@@ -0,0 +1,71 @@
+// Package coffeeshoptest runs coffeeshop servers in tests.
+//
+// A test starts a server listening on a random local port:
+//
+//	func TestOrders(t *testing.T) {
+//		shop := coffeeshoptest.NewServer(t, coffeeshop.WithOrderInterval("1h"))
+//		products, err := shop.Client.GetProducts(context.Background())
+//		...
+//	}
+package coffeeshoptest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/qba73/coffeeshop"
+	"github.com/qba73/coffeeshop/client"
+)
+
+// Server is a coffeeshop server started for a test.
+type Server struct {
+	*coffeeshop.Server
+	// Client talks to the server.
+	Client *client.Client
+}
+
+// NewServer starts a coffeeshop server on a random local port and
+// shuts it down when the test and its subtests complete. The server
+// serves the sample products from a memory store without latency,
+// unless the options configure otherwise. Options are applied in
+// order, after the defaults. The base URL of the server, ending
+// with a slash, is in the URL field.
+func NewServer(t testing.TB, opts ...coffeeshop.Option) *Server {
+	t.Helper()
+
+	store, err := coffeeshop.OpenStore("memory://")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts = append([]coffeeshop.Option{coffeeshop.WithLatency("0s")}, opts...)
+	cs, err := coffeeshop.NewWithListener(l, store, opts...)
+	if err != nil {
+		l.Close()
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- cs.ListenAndServe()
+	}()
+	t.Cleanup(func() {
+		if err := cs.Shutdown(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+			t.Error(err)
+		}
+	})
+
+	c, err := client.New(cs.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Server{Server: cs, Client: c}
+}
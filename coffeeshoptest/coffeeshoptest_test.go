@@ -0,0 +1,48 @@
+package coffeeshoptest_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/qba73/coffeeshop"
+	"github.com/qba73/coffeeshop/coffeeshoptest"
+)
+
+func TestNewServer_ServesSampleProducts(t *testing.T) {
+	t.Parallel()
+
+	shop := coffeeshoptest.NewServer(t)
+	px, err := shop.Client.GetProducts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(px) == 0 {
+		t.Error("want sample products")
+	}
+}
+
+func TestNewServer_AppliesOptions(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: coffeeshop.Products{
+		"1": {ID: "1", Type: "Tea", Brand: "Lipton", Name: "Yellow Label"},
+	}}
+	shop := coffeeshoptest.NewServer(t, coffeeshop.WithStore(store))
+	p, err := shop.Client.GetProduct(context.Background(), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "Yellow Label" {
+		t.Errorf("want product from the store, got %+v", p)
+	}
+
+	resp, err := http.Get(shop.URL + "healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("want HTTP 200, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,101 @@
+package coffeeshop
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// lessID reports whether ID a sorts before ID b. Numeric IDs
+// are compared as numbers and sort before other IDs.
+func lessID(a, b string) bool {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return na < nb
+	case errA == nil:
+		return true
+	case errB == nil:
+		return false
+	}
+	return a < b
+}
+
+// sortProducts sorts products by ID.
+func sortProducts(px []Product) {
+	sort.Slice(px, func(i, j int) bool { return lessID(px[i].ID, px[j].ID) })
+}
+
+// sortOrders sorts orders by ID.
+func sortOrders(ox []Order) {
+	sort.Slice(ox, func(i, j int) bool { return lessID(ox[i].ID, ox[j].ID) })
+}
+
+// productSortKeys holds functions comparing products
+// by keys accepted in the sort query parameter.
+var productSortKeys = map[string]func(a, b Product) bool{
+	"id":             func(a, b Product) bool { return lessID(a.ID, b.ID) },
+	"type":           func(a, b Product) bool { return strings.ToLower(a.Type) < strings.ToLower(b.Type) },
+	"brand":          func(a, b Product) bool { return strings.ToLower(a.Brand) < strings.ToLower(b.Brand) },
+	"name":           func(a, b Product) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) },
+	"price":          func(a, b Product) bool { return a.Price.Amount < b.Price.Amount },
+	"price_per_unit": lessUnitPrice,
+	"stock":          func(a, b Product) bool { return a.Stock < b.Stock },
+	"created_at":     func(a, b Product) bool { return timeOf(a.CreatedAt).Before(timeOf(b.CreatedAt)) },
+	"updated_at":     func(a, b Product) bool { return timeOf(a.UpdatedAt).Before(timeOf(b.UpdatedAt)) },
+}
+
+// sortProductsBy sorts products by the key, for example "name",
+// or in descending order by the key prefixed with "-", for example
+// "-price". Products with equal keys remain sorted by ID.
+func sortProductsBy(px []Product, key string) error {
+	desc := strings.HasPrefix(key, "-")
+	less, ok := productSortKeys[strings.TrimPrefix(key, "-")]
+	if !ok {
+		return fmt.Errorf("invalid sort key %q", key)
+	}
+	sortProducts(px)
+	sort.SliceStable(px, func(i, j int) bool {
+		if desc {
+			return less(px[j], px[i])
+		}
+		return less(px[i], px[j])
+	})
+	return nil
+}
+
+// sortRequested sorts products by the key in the sort query
+// parameter, or by ID if the parameter is not present.
+func sortRequested(r *http.Request, px []Product) error {
+	key := r.URL.Query().Get("sort")
+	if key == "" {
+		sortProducts(px)
+		return nil
+	}
+	return sortProductsBy(px, key)
+}
+
+// WithKeyedProducts configures the server to encode lists
+// of products in JSON as an object keyed by product ID,
+// the format used by early versions of the API.
+func WithKeyedProducts() Option {
+	return func(s *Server) error {
+		s.KeyedProducts = true
+		return nil
+	}
+}
+
+// keyedProducts returns representation of products
+// as an object keyed by product ID. Fields requested
+// by the client are selected from each product.
+func (cs *Server) keyedProducts(r *http.Request, px []Product) any {
+	keyed := make(map[string]any, len(px))
+	var unknown []string
+	for _, p := range px {
+		keyed[p.ID], unknown = selectedFields(r, cs.productView(r, p))
+	}
+	return partialResponse{v: keyed, unknown: unknown}
+}
@@ -0,0 +1,194 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+func TestProducts_MarshalsAsArraySortedByID(t *testing.T) {
+	t.Parallel()
+
+	products := coffeeshop.Products{
+		"10": {ID: "10", Name: "Ten"},
+		"2":  {ID: "2", Name: "Two"},
+		"1":  {ID: "1", Name: "One"},
+	}
+	data, err := json.Marshal(products)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []coffeeshop.Product
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("want JSON array, got %s: %v", data, err)
+	}
+	var ids []string
+	for _, p := range got {
+		ids = append(ids, p.ID)
+	}
+	want := []string{"1", "2", "10"}
+	if !cmp.Equal(want, ids) {
+		t.Error(cmp.Diff(want, ids))
+	}
+}
+
+func TestProducts_UnmarshalsFromArrayAndKeyedObject(t *testing.T) {
+	t.Parallel()
+
+	want := coffeeshop.Products{
+		"1": {ID: "1", Name: "One", Price: coffeeshop.Money{Amount: 100, Currency: "EUR"}},
+	}
+	for _, data := range []string{
+		`[{"id":"1","name":"One","price":"1.00"}]`,
+		`{"1":{"id":"1","name":"One","price":"1.00"}}`,
+	} {
+		var got coffeeshop.Products
+		if err := json.Unmarshal([]byte(data), &got); err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(want, got) {
+			t.Error(cmp.Diff(want, got))
+		}
+	}
+}
+
+func TestServer_ReturnsProductsSortedByID(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	for _, path := range []string{"products", "products/coffee"} {
+		resp, err := http.Get(shop.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []coffeeshop.Product
+		err = json.NewDecoder(resp.Body).Decode(&got)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 1; i < len(got); i++ {
+			if got[i-1].ID >= got[i].ID {
+				t.Fatalf("%s: want products sorted by ID, got %s before %s", path, got[i-1].ID, got[i].ID)
+			}
+		}
+	}
+}
+
+func TestServer_ReturnsProductsKeyedByIDWithKeyedProducts(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: coffeeshop.Products{
+			"1": {ID: "1", Type: "Coffee", Name: "Intenso"},
+			"2": {ID: "2", Type: "Tea", Name: "Green"},
+		},
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t, coffeeshop.WithKeyedProducts())
+	resp, err := http.Get(shop.URL + "products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var got map[string]coffeeshop.Product
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]coffeeshop.Product{
+		"1": {ID: "1", Type: "Coffee", Name: "Intenso", Price: coffeeshop.Money{Currency: "EUR"}},
+		"2": {ID: "2", Type: "Tea", Name: "Green", Price: coffeeshop.Money{Currency: "EUR"}},
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestMemoryStore_ReturnsProductsSortedByID(t *testing.T) {
+	t.Parallel()
+
+	store := coffeeshop.MemoryStore{
+		Products: coffeeshop.Products{
+			"3":  {ID: "3", Type: "Coffee"},
+			"10": {ID: "10", Type: "Coffee"},
+			"1":  {ID: "1", Type: "Coffee"},
+		},
+	}
+	for i := 0; i < 10; i++ {
+		var ids []string
+		for _, p := range store.GetByType("coffee") {
+			ids = append(ids, p.ID)
+		}
+		want := []string{"1", "3", "10"}
+		if !cmp.Equal(want, ids) {
+			t.Fatal(cmp.Diff(want, ids))
+		}
+	}
+}
+
+func TestServer_SortsProductsByRequestedKey(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: coffeeshop.Products{
+			"1": {ID: "1", Type: "Coffee", Name: "B", Price: coffeeshop.Money{Amount: 500, Currency: "EUR"}},
+			"2": {ID: "2", Type: "Coffee", Name: "A", Price: coffeeshop.Money{Amount: 900, Currency: "EUR"}},
+			"3": {ID: "3", Type: "Coffee", Name: "C", Price: coffeeshop.Money{Amount: 500, Currency: "EUR"}},
+		},
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	tests := map[string][]string{
+		"products?sort=name":         {"2", "1", "3"},
+		"products?sort=-price":       {"2", "1", "3"},
+		"products/coffee?sort=-name": {"3", "1", "2"},
+		"products/coffee?sort=price": {"1", "3", "2"},
+		"categories/coffee/products": {"1", "2", "3"},
+	}
+	for path, want := range tests {
+		resp, err := http.Get(shop.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var px []coffeeshop.Product
+		err = json.NewDecoder(resp.Body).Decode(&px)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, p := range px {
+			got = append(got, p.ID)
+		}
+		if !cmp.Equal(want, got) {
+			t.Errorf("%s: %s", path, cmp.Diff(want, got))
+		}
+	}
+}
+
+func TestServer_Returns400OnInvalidSortKey(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp, err := http.Get(shop.URL + "products?sort=colour")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("want HTTP 400, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,60 @@
+package coffeeshop
+
+import (
+	"errors"
+	"io"
+
+	"github.com/andybalholm/brotli"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// compressionLevel is the level used by the built-in encoders.
+const compressionLevel = 5
+
+// compressibleTypes holds content types of responses
+// compressed by the server.
+var compressibleTypes = []string{
+	"application/json",
+	"text/plain",
+}
+
+// WithCompression enables brotli, gzip and deflate compression of
+// responses for clients that accept it. Brotli is preferred when
+// clients accept several encodings. Other encodings can be registered
+// with WithCompressionEncoder.
+func WithCompression() Option {
+	return func(s *Server) error {
+		if s.Compressor == nil {
+			s.Compressor = newCompressor()
+		}
+		return nil
+	}
+}
+
+// newCompressor returns the compressor with the built-in encoders.
+func newCompressor() *middleware.Compressor {
+	c := middleware.NewCompressor(compressionLevel, compressibleTypes...)
+	c.SetEncoder("br", encodeBrotli)
+	return c
+}
+
+// encodeBrotli returns the brotli writer of the compressor.
+func encodeBrotli(w io.Writer, level int) io.Writer {
+	return brotli.NewWriterLevel(w, level)
+}
+
+// WithCompressionEncoder enables compression of responses and
+// registers the encoder for the given content encoding.
+// Registered encoders take precedence over the built-in ones.
+func WithCompressionEncoder(encoding string, fn middleware.EncoderFunc) Option {
+	return func(s *Server) error {
+		if encoding == "" || fn == nil {
+			return errors.New("invalid compression encoder")
+		}
+		if s.Compressor == nil {
+			s.Compressor = newCompressor()
+		}
+		s.Compressor.SetEncoder(encoding, fn)
+		return nil
+	}
+}
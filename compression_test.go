@@ -0,0 +1,114 @@
+package coffeeshop_test
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/qba73/coffeeshop"
+)
+
+func getEncoded(t *testing.T, url, encoding string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept-Encoding", encoding)
+	client := http.Client{
+		Transport: &http.Transport{DisableCompression: true},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestServer_CompressesResponsesWithGzip(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t, coffeeshop.WithCompression())
+	resp := getEncoded(t, shop.URL+"products", "gzip")
+	defer resp.Body.Close()
+
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("want gzip content encoding, got %q", resp.Header.Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []coffeeshop.Product
+	err = json.NewDecoder(zr).Decode(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(inventory) {
+		t.Errorf("want %d products, got %d", len(inventory), len(got))
+	}
+}
+
+func TestServer_DoesNotCompressResponsesByDefault(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp := getEncoded(t, shop.URL+"products", "gzip")
+	defer resp.Body.Close()
+
+	if resp.Header.Get("Content-Encoding") != "" {
+		t.Errorf("want no content encoding, got %q", resp.Header.Get("Content-Encoding"))
+	}
+}
+
+func TestServer_UsesRegisteredCompressionEncoder(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	identity := func(w io.Writer, level int) io.Writer { return w }
+	shop := newCoffeShopTestServer(store, "10ms", t, coffeeshop.WithCompressionEncoder("br", identity))
+	resp := getEncoded(t, shop.URL+"products", "br")
+	defer resp.Body.Close()
+
+	if resp.Header.Get("Content-Encoding") != "br" {
+		t.Errorf("want br content encoding, got %q", resp.Header.Get("Content-Encoding"))
+	}
+}
+
+func TestServer_PrefersBrotliCompression(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t, coffeeshop.WithCompression())
+	resp := getEncoded(t, shop.URL+"products", "gzip, deflate, br")
+	defer resp.Body.Close()
+
+	if resp.Header.Get("Content-Encoding") != "br" {
+		t.Fatalf("want br content encoding, got %q", resp.Header.Get("Content-Encoding"))
+	}
+	var got []coffeeshop.Product
+	err := json.NewDecoder(brotli.NewReader(resp.Body)).Decode(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(inventory) {
+		t.Errorf("want %d products, got %d", len(inventory), len(got))
+	}
+}
@@ -0,0 +1,514 @@
+package coffeeshop
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config describes the server loaded from a YAML file with
+// LoadConfig, for example:
+//
+//	addr: ":8080"
+//	store: redis://localhost:6379/0
+//	latency:
+//	  base: 2s
+//	  jitter: 500ms
+//	  header: true
+//	  groups:
+//	    - api
+//	auth:
+//	  admin_token: secret
+//	middleware:
+//	  compression: true
+//	  cors_origins:
+//	    - https://example.com
+//
+// Environment variables override settings of the file.
+type Config struct {
+	// Addr is the address to listen on, for example ":8080",
+	// "tcp6://[::1]:8080" or "unix:///run/coffeeshop.sock".
+	// COFFEESHOP_ADDR.
+	Addr string
+	// GRPCAddr is the address serving the gRPC API, for
+	// example ":9090". Empty disables gRPC. COFFEESHOP_GRPC_ADDR.
+	GRPCAddr string
+	// Store is the DSN of the store opened with OpenStore.
+	// COFFEESHOP_STORE.
+	Store string
+	// Inventory is the file with products of the memory store.
+	// COFFEESHOP_INVENTORY.
+	Inventory string
+	// CacheTTL is how long products read from the store are
+	// cached, for example "30s". Empty disables the cache.
+	// COFFEESHOP_CACHE_TTL.
+	CacheTTL   string
+	Latency    LatencyConfig
+	Auth       AuthConfig
+	Middleware MiddlewareConfig
+}
+
+// LatencyConfig describes the simulated latency.
+type LatencyConfig struct {
+	// Base is the latency of requests, for example "2s".
+	// COFFEESHOP_LATENCY.
+	Base string
+	// Jitter is the longest random latency added to the base.
+	// COFFEESHOP_LATENCY_JITTER.
+	Jitter string
+	// Header lets clients request the latency with the
+	// X-Coffeeshop-Delay header. COFFEESHOP_HEADER_LATENCY.
+	Header bool
+	// Groups are route groups delayed by the latency. Nil
+	// delays API routes only. COFFEESHOP_DELAYED_GROUPS,
+	// separated by commas.
+	Groups []RouteGroup
+}
+
+// AuthConfig describes authentication of the server.
+type AuthConfig struct {
+	// AdminToken protects /admin/ endpoints.
+	// COFFEESHOP_ADMIN_TOKEN.
+	AdminToken string
+	// TLSCert and TLSKey are files of the TLS certificate and key.
+	// COFFEESHOP_TLS_CERT and COFFEESHOP_TLS_KEY.
+	TLSCert string
+	TLSKey  string
+}
+
+// MiddlewareConfig toggles middleware of the server.
+type MiddlewareConfig struct {
+	// Compression compresses responses. COFFEESHOP_COMPRESSION.
+	Compression bool
+	// CORSOrigins are origins allowed to call the API.
+	// COFFEESHOP_CORS_ORIGINS, separated by commas.
+	CORSOrigins []string
+	// DebugLogging logs requests and responses with redacted
+	// bodies. COFFEESHOP_DEBUG_LOGGING.
+	DebugLogging bool
+	// TrustedProxies are CIDRs or IP addresses of proxies whose
+	// forwarded headers are honoured. COFFEESHOP_TRUSTED_PROXIES,
+	// separated by commas.
+	TrustedProxies []string
+	// Docs serves the API documentation. COFFEESHOP_DOCS.
+	Docs bool
+	// Profiling serves pprof profiles. COFFEESHOP_PROFILING.
+	Profiling bool
+	// Throttle limits the bandwidth of responses in bytes
+	// per second. Zero means no limit. COFFEESHOP_THROTTLE.
+	Throttle int
+	// MaxConcurrentRequests limits requests in flight. Zero
+	// means no limit. COFFEESHOP_MAX_CONCURRENT_REQUESTS.
+	MaxConcurrentRequests int
+}
+
+// DefaultConfig returns the configuration of the server
+// started by Run without a configuration file.
+func DefaultConfig() Config {
+	return Config{
+		Addr:    ":8080",
+		Store:   "memory://",
+		Latency: LatencyConfig{Base: "2s"},
+	}
+}
+
+// LoadConfig loads the configuration from the YAML file, if the
+// path isn't empty, on top of DefaultConfig and overrides it with
+// environment variables. The error lists all invalid settings.
+func LoadConfig(path string) (Config, error) {
+	c := DefaultConfig()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, err
+		}
+		switch ext := strings.ToLower(filepath.Ext(path)); ext {
+		case ".yaml", ".yml":
+		default:
+			return Config{}, fmt.Errorf("%s: unsupported config format %q", path, ext)
+		}
+		if err := c.decodeYAML(data); err != nil {
+			return Config{}, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if err := c.applyEnv(os.LookupEnv); err != nil {
+		return Config{}, err
+	}
+	if err := c.Validate(); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+// decodeYAML sets fields present in the YAML document.
+func (c *Config) decodeYAML(data []byte) error {
+	doc, err := parseYAML(data)
+	if err != nil {
+		return err
+	}
+	if doc.kind != yamlMapping {
+		return fmt.Errorf("line %d: config must be a mapping", doc.line)
+	}
+	var errs []error
+	for i, key := range doc.keys {
+		v := doc.values[i]
+		var err error
+		switch key {
+		case "addr":
+			c.Addr, err = yamlString(v)
+		case "grpc_addr":
+			c.GRPCAddr, err = yamlString(v)
+		case "store":
+			c.Store, err = yamlString(v)
+		case "inventory":
+			c.Inventory, err = yamlString(v)
+		case "cache_ttl":
+			c.CacheTTL, err = yamlString(v)
+		case "latency":
+			err = yamlSection(v, c.Latency.decodeYAML)
+		case "auth":
+			err = yamlSection(v, c.Auth.decodeYAML)
+		case "middleware":
+			err = yamlSection(v, c.Middleware.decodeYAML)
+		default:
+			err = fmt.Errorf("line %d: unknown setting", v.line)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// yamlSection decodes settings of the mapping one by one.
+func yamlSection(n *yamlNode, decode func(key string, v *yamlNode) error) error {
+	if n.kind != yamlMapping {
+		return fmt.Errorf("line %d: want mapping", n.line)
+	}
+	var errs []error
+	for i, key := range n.keys {
+		if err := decode(key, n.values[i]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (lc *LatencyConfig) decodeYAML(key string, v *yamlNode) error {
+	var err error
+	switch key {
+	case "base":
+		lc.Base, err = yamlString(v)
+	case "jitter":
+		lc.Jitter, err = yamlString(v)
+	case "header":
+		lc.Header, err = yamlBool(v)
+	case "groups":
+		var groups []string
+		groups, err = yamlStrings(v)
+		lc.Groups = make([]RouteGroup, len(groups))
+		for i, g := range groups {
+			lc.Groups[i] = RouteGroup(g)
+		}
+	default:
+		err = fmt.Errorf("line %d: unknown setting", v.line)
+	}
+	return err
+}
+
+func (ac *AuthConfig) decodeYAML(key string, v *yamlNode) error {
+	var err error
+	switch key {
+	case "admin_token":
+		ac.AdminToken, err = yamlString(v)
+	case "tls_cert":
+		ac.TLSCert, err = yamlString(v)
+	case "tls_key":
+		ac.TLSKey, err = yamlString(v)
+	default:
+		err = fmt.Errorf("line %d: unknown setting", v.line)
+	}
+	return err
+}
+
+func (mc *MiddlewareConfig) decodeYAML(key string, v *yamlNode) error {
+	var err error
+	switch key {
+	case "compression":
+		mc.Compression, err = yamlBool(v)
+	case "cors_origins":
+		mc.CORSOrigins, err = yamlStrings(v)
+	case "debug_logging":
+		mc.DebugLogging, err = yamlBool(v)
+	case "trusted_proxies":
+		mc.TrustedProxies, err = yamlStrings(v)
+	case "docs":
+		mc.Docs, err = yamlBool(v)
+	case "profiling":
+		mc.Profiling, err = yamlBool(v)
+	case "throttle":
+		mc.Throttle, err = yamlInt(v)
+	case "max_concurrent_requests":
+		mc.MaxConcurrentRequests, err = yamlInt(v)
+	default:
+		err = fmt.Errorf("line %d: unknown setting", v.line)
+	}
+	return err
+}
+
+func yamlBool(n *yamlNode) (bool, error) {
+	s, err := yamlString(n)
+	if err != nil {
+		return false, err
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("line %d: want true or false, got %q", n.line, s)
+	}
+	return b, nil
+}
+
+func yamlStrings(n *yamlNode) ([]string, error) {
+	if n.kind != yamlSequence {
+		return nil, fmt.Errorf("line %d: want sequence", n.line)
+	}
+	values := make([]string, 0, len(n.items))
+	for _, item := range n.items {
+		s, err := yamlString(item)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, s)
+	}
+	return values, nil
+}
+
+// applyEnv overrides settings with environment variables
+// looked up with the function.
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	var errs []error
+	str := func(key string, dst *string) {
+		if v, ok := lookup(key); ok {
+			*dst = v
+		}
+	}
+	list := func(key string, dst *[]string) {
+		if v, ok := lookup(key); ok {
+			*dst = splitList(v)
+		}
+	}
+	boolean := func(key string, dst *bool) {
+		if v, ok := lookup(key); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: want true or false, got %q", key, v))
+				return
+			}
+			*dst = b
+		}
+	}
+	integer := func(key string, dst *int) {
+		if v, ok := lookup(key); ok {
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: want integer, got %q", key, v))
+				return
+			}
+			*dst = i
+		}
+	}
+	str("COFFEESHOP_ADDR", &c.Addr)
+	str("COFFEESHOP_GRPC_ADDR", &c.GRPCAddr)
+	str("COFFEESHOP_STORE", &c.Store)
+	str("COFFEESHOP_INVENTORY", &c.Inventory)
+	str("COFFEESHOP_CACHE_TTL", &c.CacheTTL)
+	str("COFFEESHOP_LATENCY", &c.Latency.Base)
+	str("COFFEESHOP_LATENCY_JITTER", &c.Latency.Jitter)
+	boolean("COFFEESHOP_HEADER_LATENCY", &c.Latency.Header)
+	if v, ok := lookup("COFFEESHOP_DELAYED_GROUPS"); ok {
+		c.Latency.Groups = []RouteGroup{}
+		for _, g := range splitList(v) {
+			c.Latency.Groups = append(c.Latency.Groups, RouteGroup(g))
+		}
+	}
+	str("COFFEESHOP_ADMIN_TOKEN", &c.Auth.AdminToken)
+	str("COFFEESHOP_TLS_CERT", &c.Auth.TLSCert)
+	str("COFFEESHOP_TLS_KEY", &c.Auth.TLSKey)
+	boolean("COFFEESHOP_COMPRESSION", &c.Middleware.Compression)
+	list("COFFEESHOP_CORS_ORIGINS", &c.Middleware.CORSOrigins)
+	boolean("COFFEESHOP_DEBUG_LOGGING", &c.Middleware.DebugLogging)
+	list("COFFEESHOP_TRUSTED_PROXIES", &c.Middleware.TrustedProxies)
+	boolean("COFFEESHOP_DOCS", &c.Middleware.Docs)
+	boolean("COFFEESHOP_PROFILING", &c.Middleware.Profiling)
+	integer("COFFEESHOP_THROTTLE", &c.Middleware.Throttle)
+	integer("COFFEESHOP_MAX_CONCURRENT_REQUESTS", &c.Middleware.MaxConcurrentRequests)
+	return errors.Join(errs...)
+}
+
+// splitList splits the comma separated list skipping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Validate returns all problems of the configuration,
+// each prefixed with the name of the setting.
+func (c Config) Validate() error {
+	var errs []error
+	problem := func(setting, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", setting, fmt.Sprintf(format, args...)))
+	}
+	if network, address := splitAddr(c.Addr); network == "unix" {
+		if address == "" {
+			problem("addr", "want unix:///path/to/socket, got %q", c.Addr)
+		}
+	} else if _, _, err := net.SplitHostPort(address); err != nil {
+		problem("addr", "want host:port or unix:///path/to/socket, got %q", c.Addr)
+	}
+	if c.GRPCAddr != "" {
+		if _, address := splitAddr(c.GRPCAddr); address == "" {
+			problem("grpc_addr", "want host:port or unix:///path/to/socket, got %q", c.GRPCAddr)
+		}
+	}
+	if u, err := url.Parse(c.Store); err != nil || u.Scheme == "" {
+		problem("store", "want URL like memory:// or redis://host:port, got %q", c.Store)
+	} else if !storeRegistered(u.Scheme) {
+		problem("store", "unknown store %q", u.Scheme)
+	}
+	if c.Inventory != "" && c.Store != "" && !strings.HasPrefix(strings.ToLower(c.Store), "memory:") {
+		problem("inventory", "requires the memory store, got %q", c.Store)
+	}
+	durations := []struct{ setting, value string }{
+		{"cache_ttl", c.CacheTTL},
+		{"latency.base", c.Latency.Base},
+		{"latency.jitter", c.Latency.Jitter},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		if v, err := time.ParseDuration(d.value); err != nil || v < 0 {
+			problem(d.setting, "want non-negative duration like 2s, got %q", d.value)
+		}
+	}
+	for _, g := range c.Latency.Groups {
+		switch g {
+		case APIRoutes, AdminRoutes, InfraRoutes:
+		default:
+			problem("latency.groups", "unknown route group %q, want api, admin or infra", g)
+		}
+	}
+	if c.Auth.AdminToken != "" && strings.TrimSpace(c.Auth.AdminToken) == "" {
+		problem("auth.admin_token", "blank token")
+	}
+	if (c.Auth.TLSCert == "") != (c.Auth.TLSKey == "") {
+		problem("auth", "tls_cert and tls_key must be set together")
+	}
+	for _, o := range c.Middleware.CORSOrigins {
+		if o != "*" && !strings.Contains(o, "://") {
+			problem("middleware.cors_origins", "want * or origin like https://example.com, got %q", o)
+		}
+	}
+	for _, p := range c.Middleware.TrustedProxies {
+		if _, err := parseProxy(p); err != nil {
+			problem("middleware.trusted_proxies", "want CIDR like 10.0.0.0/8 or IP address, got %q", p)
+		}
+	}
+	if c.Middleware.Throttle < 0 {
+		problem("middleware.throttle", "want non-negative bytes per second, got %d", c.Middleware.Throttle)
+	}
+	if c.Middleware.MaxConcurrentRequests < 0 {
+		problem("middleware.max_concurrent_requests", "want non-negative limit, got %d", c.Middleware.MaxConcurrentRequests)
+	}
+	return errors.Join(errs...)
+}
+
+// storeRegistered reports whether a store factory
+// is registered for the scheme.
+func storeRegistered(scheme string) bool {
+	storesMx.RLock()
+	defer storesMx.RUnlock()
+	_, ok := stores[strings.ToLower(scheme)]
+	return ok
+}
+
+// Options returns options configuring the server as described.
+func (c Config) Options() []Option {
+	var opts []Option
+	if c.Latency.Base != "" {
+		opts = append(opts, WithLatency(c.Latency.Base))
+	}
+	if c.Latency.Jitter != "" {
+		opts = append(opts, WithLatencyJitter(c.Latency.Jitter))
+	}
+	if c.Latency.Header {
+		opts = append(opts, WithHeaderLatency())
+	}
+	if c.Latency.Groups != nil {
+		opts = append(opts, WithDelayedGroups(c.Latency.Groups...))
+	}
+	if c.Inventory != "" {
+		opts = append(opts, WithInventoryFile(c.Inventory))
+	}
+	if c.CacheTTL != "" {
+		opts = append(opts, WithCache(c.CacheTTL))
+	}
+	if c.GRPCAddr != "" {
+		opts = append(opts, WithGRPC(c.GRPCAddr))
+	}
+	if c.Auth.AdminToken != "" {
+		opts = append(opts, WithAdminToken(c.Auth.AdminToken))
+	}
+	if c.Auth.TLSCert != "" {
+		opts = append(opts, WithTLS(c.Auth.TLSCert, c.Auth.TLSKey))
+	}
+	if c.Middleware.Compression {
+		opts = append(opts, WithCompression())
+	}
+	if len(c.Middleware.CORSOrigins) > 0 {
+		opts = append(opts, WithCORS(c.Middleware.CORSOrigins...))
+	}
+	if c.Middleware.DebugLogging {
+		opts = append(opts, WithDebugLogging())
+	}
+	if len(c.Middleware.TrustedProxies) > 0 {
+		opts = append(opts, WithTrustedProxies(c.Middleware.TrustedProxies...))
+	}
+	if c.Middleware.Docs {
+		opts = append(opts, WithDocs())
+	}
+	if c.Middleware.Profiling {
+		opts = append(opts, WithProfiling())
+	}
+	if c.Middleware.Throttle > 0 {
+		opts = append(opts, WithThrottle(c.Middleware.Throttle))
+	}
+	if c.Middleware.MaxConcurrentRequests > 0 {
+		opts = append(opts, WithMaxConcurrentRequests(c.Middleware.MaxConcurrentRequests))
+	}
+	return opts
+}
+
+// NewFromConfig validates the configuration, opens the store and
+// creates the server. Options are applied after the configuration.
+func NewFromConfig(c Config, options ...Option) (*Server, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	store, err := OpenStore(c.Store)
+	if err != nil {
+		return nil, err
+	}
+	return New(c.Addr, store, append(c.Options(), options...)...)
+}
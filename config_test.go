@@ -0,0 +1,212 @@
+package coffeeshop_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+// writeConfig writes the YAML configuration to a temporary file.
+func writeConfig(t *testing.T, yaml string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "coffeeshop.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const testConfig = `# coffeeshop
+addr: ":9090"
+store: memory://
+latency:
+  base: 1s
+  jitter: 200ms
+  header: true
+  groups:
+    - api
+    - admin
+auth:
+  admin_token: secret
+middleware:
+  compression: true
+  cors_origins:
+    - https://example.com
+  docs: true
+  max_concurrent_requests: 10
+  trusted_proxies:
+    - 10.0.0.0/8
+`
+
+func TestLoadConfig_DecodesYAMLFile(t *testing.T) {
+	path := writeConfig(t, testConfig)
+
+	got, err := coffeeshop.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := coffeeshop.Config{
+		Addr:  ":9090",
+		Store: "memory://",
+		Latency: coffeeshop.LatencyConfig{
+			Base:   "1s",
+			Jitter: "200ms",
+			Header: true,
+			Groups: []coffeeshop.RouteGroup{coffeeshop.APIRoutes, coffeeshop.AdminRoutes},
+		},
+		Auth: coffeeshop.AuthConfig{AdminToken: "secret"},
+		Middleware: coffeeshop.MiddlewareConfig{
+			Compression:           true,
+			CORSOrigins:           []string{"https://example.com"},
+			Docs:                  true,
+			MaxConcurrentRequests: 10,
+			TrustedProxies:        []string{"10.0.0.0/8"},
+		},
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestLoadConfig_DecodesQuotedFlowAndMultiLineValues(t *testing.T) {
+	path := writeConfig(t, `addr: ":9090" # port of the API
+auth:
+  admin_token: "s3cret # not a comment"
+  tls_cert: >-
+    /etc/coffeeshop/
+    tls.crt
+  tls_key: '/etc/coffeeshop/tls.key'
+middleware: {compression: true, cors_origins: ["https://a.example", 'https://b.example']}
+`)
+
+	got, err := coffeeshop.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := coffeeshop.DefaultConfig()
+	want.Addr = ":9090"
+	want.Auth = coffeeshop.AuthConfig{
+		AdminToken: "s3cret # not a comment",
+		TLSCert:    "/etc/coffeeshop/ tls.crt",
+		TLSKey:     "/etc/coffeeshop/tls.key",
+	}
+	want.Middleware = coffeeshop.MiddlewareConfig{
+		Compression: true,
+		CORSOrigins: []string{"https://a.example", "https://b.example"},
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestLoadConfig_OverridesFileWithEnvironment(t *testing.T) {
+	path := writeConfig(t, testConfig)
+	t.Setenv("COFFEESHOP_LATENCY", "5s")
+	t.Setenv("COFFEESHOP_DOCS", "false")
+	t.Setenv("COFFEESHOP_CORS_ORIGINS", "https://a.example, https://b.example")
+
+	got, err := coffeeshop.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Latency.Base != "5s" {
+		t.Errorf("want latency 5s, got %q", got.Latency.Base)
+	}
+	if got.Middleware.Docs {
+		t.Error("want docs disabled")
+	}
+	want := []string{"https://a.example", "https://b.example"}
+	if !cmp.Equal(want, got.Middleware.CORSOrigins) {
+		t.Error(cmp.Diff(want, got.Middleware.CORSOrigins))
+	}
+}
+
+func TestLoadConfig_ReportsAllInvalidSettings(t *testing.T) {
+	path := writeConfig(t, `addr: localhost
+store: postgres://db
+latency:
+  base: soon
+  groups:
+    - everything
+auth:
+  tls_cert: cert.pem
+middleware:
+  throttle: -1
+`)
+	_, err := coffeeshop.LoadConfig(path)
+	if err == nil {
+		t.Fatal("want error on invalid config")
+	}
+	for _, want := range []string{
+		`addr: want host:port or unix:///path/to/socket, got "localhost"`,
+		`store: unknown store "postgres"`,
+		`latency.base: want non-negative duration like 2s, got "soon"`,
+		`latency.groups: unknown route group "everything"`,
+		"auth: tls_cert and tls_key must be set together",
+		"middleware.throttle: want non-negative bytes per second, got -1",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("want error containing %q, got %q", want, err)
+		}
+	}
+}
+
+func TestLoadConfig_ReportsLineOfUnknownSetting(t *testing.T) {
+	path := writeConfig(t, "addr: \":8080\"\nlatency:\n  bass: 1s\n")
+
+	_, err := coffeeshop.LoadConfig(path)
+	if err == nil {
+		t.Fatal("want error on unknown setting")
+	}
+	if want := "latency: bass: line 3: unknown setting"; !strings.Contains(err.Error(), want) {
+		t.Errorf("want error containing %q, got %q", want, err)
+	}
+}
+
+func TestLoadConfig_ReportsInvalidEnvironment(t *testing.T) {
+	t.Setenv("COFFEESHOP_COMPRESSION", "maybe")
+
+	_, err := coffeeshop.LoadConfig("")
+	if err == nil {
+		t.Fatal("want error on invalid environment variable")
+	}
+	if want := `COFFEESHOP_COMPRESSION: want true or false, got "maybe"`; err.Error() != want {
+		t.Errorf("want error %q, got %q", want, err)
+	}
+}
+
+func TestServer_RequiresAdminTokenOnAdminEndpoints(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t, coffeeshop.WithAdminToken("secret"))
+	for _, tc := range []struct {
+		path, token string
+		want        int
+	}{
+		{"admin/audit", "", http.StatusUnauthorized},
+		{"admin/audit", "wrong", http.StatusUnauthorized},
+		{"admin/audit", "secret", http.StatusOK},
+		{"openapi.json", "", http.StatusOK},
+	} {
+		req, err := http.NewRequest(http.MethodGet, shop.URL+tc.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s with token %q: want HTTP %d, got %d", tc.path, tc.token, tc.want, resp.StatusCode)
+		}
+	}
+}
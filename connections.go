@@ -0,0 +1,83 @@
+package coffeeshop
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// WithMaxConnectionRequests closes keep-alive connections after
+// they served n requests, so clients and load balancers can be
+// tested against connection churn. The last response on the
+// connection carries the Connection: close header; HTTP/2
+// connections are closed gracefully with GOAWAY.
+func WithMaxConnectionRequests(n int) Option {
+	return func(s *Server) error {
+		if n <= 0 {
+			return errors.New("max connection requests must be positive")
+		}
+		s.MaxConnectionRequests = n
+		return nil
+	}
+}
+
+// WithMaxConnectionAge closes keep-alive connections after the
+// first response sent when they are older than the age, for
+// example "30s". Connections are closed the same way as with
+// WithMaxConnectionRequests.
+func WithMaxConnectionAge(age string) Option {
+	return func(s *Server) error {
+		d, err := time.ParseDuration(age)
+		if err != nil {
+			return err
+		}
+		if d <= 0 {
+			return errors.New("max connection age must be positive")
+		}
+		s.MaxConnectionAge = d
+		return nil
+	}
+}
+
+// connInfo describes the connection serving the request.
+type connInfo struct {
+	opened   time.Time
+	requests atomic.Int64
+}
+
+type connInfoKey struct{}
+
+// trackConnections records when connections are opened,
+// if the server limits connections.
+func (cs *Server) trackConnections() {
+	if cs.MaxConnectionRequests <= 0 && cs.MaxConnectionAge <= 0 {
+		return
+	}
+	connContext := cs.HTTPServer.ConnContext
+	cs.HTTPServer.ConnContext = func(ctx context.Context, c net.Conn) context.Context {
+		if connContext != nil {
+			ctx = connContext(ctx, c)
+		}
+		return context.WithValue(ctx, connInfoKey{}, &connInfo{opened: now(cs.Clock)})
+	}
+}
+
+// limitConnections asks clients to close connections which
+// served too many requests or are too old.
+func (cs *Server) limitConnections(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if info, ok := r.Context().Value(connInfoKey{}).(*connInfo); ok {
+			n := info.requests.Add(1)
+			tooMany := cs.MaxConnectionRequests > 0 && n >= int64(cs.MaxConnectionRequests)
+			tooOld := cs.MaxConnectionAge > 0 && now(cs.Clock).Sub(info.opened) >= cs.MaxConnectionAge
+			if tooMany || tooOld {
+				w.Header().Set("Connection", "close")
+			}
+		}
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
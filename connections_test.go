@@ -0,0 +1,80 @@
+package coffeeshop_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"testing"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// reusedConnections sends n requests to the URL with one keep-alive
+// client and reports whether each request reused a connection.
+func reusedConnections(t *testing.T, url string, n int) []bool {
+	t.Helper()
+
+	client := &http.Client{Transport: &http.Transport{}}
+	t.Cleanup(client.CloseIdleConnections)
+	var reused []bool
+	for i := 0; i < n; i++ {
+		trace := &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) { reused = append(reused, info.Reused) },
+		}
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	return reused
+}
+
+func TestServer_ClosesConnectionsAfterMaxRequests(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t,
+		coffeeshop.WithMaxConnectionRequests(2),
+	)
+	got := reusedConnections(t, shop.URL+"products", 5)
+	want := []bool{false, true, false, true, false}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("want reused connections %v, got %v", want, got)
+		}
+	}
+}
+
+func TestServer_KeepsConnectionsWithoutLimits(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t)
+	got := reusedConnections(t, shop.URL+"products", 3)
+	want := []bool{false, true, true}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("want reused connections %v, got %v", want, got)
+		}
+	}
+}
+
+func TestServer_ClosesConnectionsOlderThanMaxAge(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t,
+		coffeeshop.WithMaxConnectionAge("1ns"),
+	)
+	resp, err := http.Get(shop.URL + "products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if !resp.Close {
+		t.Error("want connection closed by the server")
+	}
+}
@@ -0,0 +1,84 @@
+package coffeeshop
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CORS settings used for allowed origins.
+const (
+	corsAllowedMethods = "GET, POST, PUT, DELETE, OPTIONS"
+	corsExposedHeaders = "ETag, Last-Modified, Location, Content-Currency, X-Request-ID"
+	corsMaxAge         = "600"
+)
+
+// WithCORS allows browsers to call the API from the given origins,
+// for example "http://localhost:3000". Use "*" to allow any origin.
+// Preflight OPTIONS requests are answered for all routes.
+func WithCORS(origins ...string) Option {
+	return func(s *Server) error {
+		if len(origins) == 0 {
+			return fmt.Errorf("no CORS origins")
+		}
+		for _, o := range origins {
+			if o == "*" {
+				continue
+			}
+			u, err := url.Parse(o)
+			if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+				return fmt.Errorf("invalid CORS origin %q", o)
+			}
+		}
+		s.CORSOrigins = append(s.CORSOrigins, origins...)
+		return nil
+	}
+}
+
+// corsAllowed reports whether the origin is allowed.
+func (cs *Server) corsAllowed(origin string) bool {
+	for _, o := range cs.CORSOrigins {
+		if o == "*" || strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// cors sets CORS headers on responses to requests from allowed
+// origins and answers preflight requests.
+func (cs *Server) cors(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if !cs.corsAllowed(origin) {
+			if preflight {
+				writeError(w, r, http.StatusForbidden, "origin not allowed")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if !preflight {
+			w.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		w.Header().Set("Access-Control-Allow-Methods", corsAllowedMethods)
+		if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+		}
+		w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+		w.WriteHeader(http.StatusNoContent)
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,112 @@
+package coffeeshop_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/qba73/coffeeshop"
+)
+
+func TestServer_SetsCORSHeadersForAllowedOrigin(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t, coffeeshop.WithCORS("http://localhost:3000"))
+	req, err := http.NewRequest(http.MethodGet, shop.URL+"products/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Origin", "http://localhost:3000")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "http://localhost:3000" {
+		t.Errorf("want allowed origin echoed, got %q", got)
+	}
+	if got := resp.Header.Get("Access-Control-Expose-Headers"); got == "" {
+		t.Error("want exposed headers")
+	}
+}
+
+func TestServer_AnswersCORSPreflightRequests(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t, coffeeshop.WithCORS("http://localhost:3000"))
+	preflight := func(origin string) *http.Response {
+		req, err := http.NewRequest(http.MethodOptions, shop.URL+"orders", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		req.Header.Set("Access-Control-Request-Headers", "content-type")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := preflight("http://localhost:3000")
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("want HTTP 204, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Access-Control-Allow-Methods"); got == "" {
+		t.Error("want allowed methods")
+	}
+	if got := resp.Header.Get("Access-Control-Allow-Headers"); got != "content-type" {
+		t.Errorf("want requested headers allowed, got %q", got)
+	}
+
+	resp = preflight("http://evil.example")
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("want HTTP 403 for disallowed origin, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("want no allowed origin, got %q", got)
+	}
+}
+
+func TestServer_DoesNotSetCORSHeadersByDefault(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	req, err := http.NewRequest(http.MethodGet, shop.URL+"products/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Origin", "http://localhost:3000")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("want no CORS headers, got %q", got)
+	}
+}
+
+func TestWithCORS_FailsOnInvalidOrigin(t *testing.T) {
+	t.Parallel()
+
+	_, err := coffeeshop.New(":0", &coffeeshop.MemoryStore{}, coffeeshop.WithCORS("localhost:3000/app"))
+	if err == nil {
+		t.Error("want error on invalid origin")
+	}
+}
@@ -0,0 +1,111 @@
+package coffeeshop
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+)
+
+// RateProvider provides exchange rates between currencies.
+type RateProvider interface {
+	// Rate returns how many units of the currency 'to'
+	// are worth one unit of the currency 'from'.
+	Rate(from, to string) (float64, error)
+}
+
+// StaticRates is a RateProvider backed by a fixed table
+// of currency values relative to a common base currency.
+type StaticRates map[string]float64
+
+// Rate returns the exchange rate between currencies.
+func (sr StaticRates) Rate(from, to string) (float64, error) {
+	f, ok := sr[from]
+	if !ok {
+		return 0, fmt.Errorf("unsupported currency %q", from)
+	}
+	t, ok := sr[to]
+	if !ok {
+		return 0, fmt.Errorf("unsupported currency %q", to)
+	}
+	return t / f, nil
+}
+
+// DefaultRates holds exchange rates used by the server
+// unless configured otherwise. Rates are relative to EUR.
+var DefaultRates = StaticRates{
+	"EUR": 1,
+	"USD": 1.08,
+	"GBP": 0.86,
+	"CHF": 0.97,
+	"PLN": 4.35,
+}
+
+// Convert converts money to the given currency. Money without
+// currency is assumed to be in the default currency.
+func (m Money) Convert(to string, rp RateProvider) (Money, error) {
+	if m.Currency == "" {
+		m.Currency = DefaultCurrency
+	}
+	if m.Currency == to {
+		return m, nil
+	}
+	rate, err := rp.Rate(m.Currency, to)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{
+		Amount:   int64(math.Round(float64(m.Amount) * rate)),
+		Currency: to,
+	}, nil
+}
+
+// WithRateProvider configures the provider of exchange rates
+// used to convert prices to currencies requested by clients.
+func WithRateProvider(rp RateProvider) Option {
+	return func(s *Server) error {
+		if rp == nil {
+			return errors.New("nil rate provider")
+		}
+		s.Rates = rp
+		return nil
+	}
+}
+
+// requestCurrency returns the currency requested by the client
+// in the 'currency' query parameter or the Accept-Currency header.
+func requestCurrency(r *http.Request) string {
+	if c := r.URL.Query().Get("currency"); c != "" {
+		return strings.ToUpper(c)
+	}
+	return strings.ToUpper(strings.TrimSpace(r.Header.Get("Accept-Currency")))
+}
+
+// convertPrices converts product prices to the currency requested
+// by the client and sets the Content-Currency response header.
+func (cs *Server) convertPrices(w http.ResponseWriter, r *http.Request, px []Product) ([]Product, error) {
+	currency, err := cs.convertPricesTo(r, px)
+	if err != nil {
+		return nil, err
+	}
+	w.Header().Set("Content-Currency", currency)
+	return px, nil
+}
+
+// convertPricesTo converts product prices in place to the currency
+// requested by the client and returns the currency.
+func (cs *Server) convertPricesTo(r *http.Request, px []Product) (string, error) {
+	currency := requestCurrency(r)
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	for i := range px {
+		price, err := px[i].Price.Convert(currency, cs.Rates)
+		if err != nil {
+			return "", err
+		}
+		px[i].Price = price
+	}
+	return currency, nil
+}
@@ -0,0 +1,163 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+	"golang.org/x/exp/slices"
+)
+
+func TestMoney_ConvertsToRequestedCurrency(t *testing.T) {
+	t.Parallel()
+
+	rates := coffeeshop.StaticRates{"EUR": 1, "USD": 1.1}
+	m := coffeeshop.Money{Amount: 799, Currency: "EUR"}
+
+	got, err := m.Convert("USD", rates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := coffeeshop.Money{Amount: 879, Currency: "USD"}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestMoney_ConvertErrorsOnUnsupportedCurrency(t *testing.T) {
+	t.Parallel()
+
+	rates := coffeeshop.StaticRates{"EUR": 1}
+	m := coffeeshop.Money{Amount: 799, Currency: "EUR"}
+
+	_, err := m.Convert("JPY", rates)
+	if err == nil {
+		t.Error("want error on unsupported currency")
+	}
+}
+
+func TestServer_ReturnsPricesInCurrencyFromQuery(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t,
+		coffeeshop.WithRateProvider(coffeeshop.StaticRates{"EUR": 1, "GBP": 0.5}),
+	)
+	resp, err := http.Get(shop.URL + "products/7?currency=gbp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.Header.Get("Content-Currency") != "GBP" {
+		t.Errorf("want Content-Currency GBP, got %q", resp.Header.Get("Content-Currency"))
+	}
+	var got coffeeshop.Product
+	err = json.NewDecoder(resp.Body).Decode(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := coffeeshop.Money{Amount: 250, Currency: "GBP"}
+	if !cmp.Equal(want, got.Price) {
+		t.Error(cmp.Diff(want, got.Price))
+	}
+}
+
+func TestServer_TagsProductsInEachCurrencySeparately(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t,
+		coffeeshop.WithRateProvider(coffeeshop.StaticRates{"EUR": 1, "GBP": 1}),
+	)
+	get := func(currency, etag string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, shop.URL+"products/7", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept-Currency", currency)
+		req.Header.Set("If-None-Match", etag)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	eur := get("EUR", "").Header.Get("ETag")
+	resp := get("GBP", eur)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("want HTTP 200 for GBP with ETag of EUR, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("ETag") == eur {
+		t.Errorf("want different entity tags of prices in EUR and GBP, got %s", eur)
+	}
+	if vary := resp.Header.Values("Vary"); !slices.Contains(vary, "Accept-Currency") {
+		t.Errorf("want Vary with Accept-Currency, got %q", vary)
+	}
+}
+
+func TestServer_ReturnsPricesInCurrencyFromHeader(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t,
+		coffeeshop.WithRateProvider(coffeeshop.StaticRates{"EUR": 1, "USD": 2}),
+		coffeeshop.WithStructuredPrices(),
+	)
+	req, err := http.NewRequest(http.MethodGet, shop.URL+"products/tea", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept-Currency", "USD")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var got []struct {
+		ID    string           `json:"id"`
+		Price coffeeshop.Money `json:"price"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range got {
+		if p.Price.Currency != "USD" {
+			t.Errorf("product %s: want currency USD, got %q", p.ID, p.Price.Currency)
+		}
+	}
+}
+
+func TestServer_Returns400OnUnsupportedCurrency(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp, err := http.Get(shop.URL + "products?currency=XYZ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("want HTTP 400, got %d", resp.StatusCode)
+	}
+}
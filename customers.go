@@ -0,0 +1,429 @@
+package coffeeshop
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// sessionTTL limits the lifetime of login sessions.
+const sessionTTL = 24 * time.Hour
+
+const (
+	// minPasswordLength is the minimum length of customer passwords.
+	minPasswordLength = 8
+	// maxPasswordLength is the maximum length of customer
+	// passwords in bytes, as bcrypt hashes only the first 72.
+	maxPasswordLength = 72
+)
+
+// Customer represents a registered customer.
+type Customer struct {
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	// PasswordHash holds the bcrypt hash of the password.
+	// It is never sent to clients.
+	PasswordHash string `json:"-"`
+}
+
+// CustomerStore represents a storage for customers.
+// Emails of customers are unique regardless of case.
+type CustomerStore interface {
+	CreateCustomer(c Customer) (Customer, error)
+	GetCustomer(id string) (Customer, error)
+	GetCustomerByEmail(email string) (Customer, error)
+	UpdateCustomer(c Customer) (Customer, error)
+}
+
+// MemoryCustomerStore represents an in-memory storage for customers.
+//
+// Use memory customer store for testing and development.
+type MemoryCustomerStore struct {
+	mx        sync.RWMutex
+	lastID    int
+	Customers map[string]Customer
+}
+
+// CreateCustomer stores the customer and assigns it a new ID.
+func (ms *MemoryCustomerStore) CreateCustomer(c Customer) (Customer, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	if ms.Customers == nil {
+		ms.Customers = make(map[string]Customer)
+	}
+	if _, ok := ms.byEmail(c.Email); ok {
+		return Customer{}, ErrCustomerExists
+	}
+	for {
+		ms.lastID++
+		c.ID = strconv.Itoa(ms.lastID)
+		if _, ok := ms.Customers[c.ID]; !ok {
+			break
+		}
+	}
+	ms.Customers[c.ID] = c
+	return c, nil
+}
+
+// GetCustomer returns the customer with the given ID.
+func (ms *MemoryCustomerStore) GetCustomer(id string) (Customer, error) {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	c, ok := ms.Customers[id]
+	if !ok {
+		return Customer{}, ErrCustomerNotFound
+	}
+	return c, nil
+}
+
+// GetCustomerByEmail returns the customer with the given email.
+func (ms *MemoryCustomerStore) GetCustomerByEmail(email string) (Customer, error) {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	c, ok := ms.byEmail(email)
+	if !ok {
+		return Customer{}, ErrCustomerNotFound
+	}
+	return c, nil
+}
+
+// byEmail returns the customer with the given email.
+// It must be called with the store lock held.
+func (ms *MemoryCustomerStore) byEmail(email string) (Customer, bool) {
+	for _, c := range ms.Customers {
+		if strings.EqualFold(c.Email, email) {
+			return c, true
+		}
+	}
+	return Customer{}, false
+}
+
+// UpdateCustomer replaces the customer with the same ID.
+func (ms *MemoryCustomerStore) UpdateCustomer(c Customer) (Customer, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	if _, ok := ms.Customers[c.ID]; !ok {
+		return Customer{}, ErrCustomerNotFound
+	}
+	if other, ok := ms.byEmail(c.Email); ok && other.ID != c.ID {
+		return Customer{}, ErrCustomerExists
+	}
+	ms.Customers[c.ID] = c
+	return c, nil
+}
+
+// WithCustomerStore configures the storage used for customers.
+func WithCustomerStore(store CustomerStore) Option {
+	return func(s *Server) error {
+		if store == nil {
+			return errors.New("nil customer store")
+		}
+		s.CustomerStore = store
+		return nil
+	}
+}
+
+// hashPassword returns the bcrypt hash of the password.
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// checkPassword reports whether the password matches the hash.
+func checkPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// dummyPasswordHash is checked against passwords of unknown
+// customers, so logins take as long whether the email is
+// registered or not.
+var dummyPasswordHash struct {
+	once sync.Once
+	hash string
+}
+
+// checkDummyPassword checks the password against dummyPasswordHash.
+func checkDummyPassword(password string) {
+	dummyPasswordHash.once.Do(func() {
+		dummyPasswordHash.hash, _ = hashPassword("not a real password")
+	})
+	checkPassword(dummyPasswordHash.hash, password)
+}
+
+// session is a login session of a customer.
+type session struct {
+	customerID string
+	expiresAt  time.Time
+}
+
+// sessionRegistry holds login sessions keyed by token.
+type sessionRegistry struct {
+	mx       sync.Mutex
+	sessions map[string]session
+}
+
+// create starts the session of the customer and returns its token.
+func (sr *sessionRegistry) create(customerID string, now time.Time) (string, session, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", session{}, err
+	}
+	token := hex.EncodeToString(b)
+	s := session{customerID: customerID, expiresAt: now.Add(sessionTTL)}
+	sr.mx.Lock()
+	defer sr.mx.Unlock()
+	if sr.sessions == nil {
+		sr.sessions = make(map[string]session)
+	}
+	for t, s := range sr.sessions {
+		if !now.Before(s.expiresAt) {
+			delete(sr.sessions, t)
+		}
+	}
+	sr.sessions[token] = s
+	return token, s, nil
+}
+
+// lookup returns the ID of the customer logged in with the token.
+func (sr *sessionRegistry) lookup(token string, now time.Time) (string, bool) {
+	sr.mx.Lock()
+	defer sr.mx.Unlock()
+	s, ok := sr.sessions[token]
+	if !ok || !now.Before(s.expiresAt) {
+		return "", false
+	}
+	return s.customerID, true
+}
+
+func (sr *sessionRegistry) delete(token string) {
+	sr.mx.Lock()
+	defer sr.mx.Unlock()
+	delete(sr.sessions, token)
+}
+
+// bearerToken returns the token from the Authorization header.
+func bearerToken(r *http.Request) string {
+	return parseBearer(r.Header.Get("Authorization"))
+}
+
+// parseBearer returns the token of the Bearer authorization.
+func parseBearer(authorization string) string {
+	scheme, token, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// writeUnauthorized responds with 401 Unauthorized
+// asking for a bearer token.
+func writeUnauthorized(w http.ResponseWriter, r *http.Request, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="coffeeshop"`)
+	writeError(w, r, http.StatusUnauthorized, message)
+}
+
+// customerID returns the ID of the customer logged in with the
+// bearer token of the request, or an empty ID for anonymous
+// requests. It responds with 401 Unauthorized if the token isn't
+// valid.
+func (cs *Server) customerID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if r.Header.Get("Authorization") == "" {
+		return "", true
+	}
+	id, ok := cs.sessions.lookup(bearerToken(r), cs.Clock.Now())
+	if !ok {
+		writeUnauthorized(w, r, "invalid or expired token")
+		return "", false
+	}
+	return id, true
+}
+
+// authenticate returns the customer logged in with the bearer token
+// of the request. It responds with 401 Unauthorized if the request
+// isn't authenticated.
+func (cs *Server) authenticate(w http.ResponseWriter, r *http.Request) (Customer, bool) {
+	id, ok := cs.customerID(w, r)
+	if !ok {
+		return Customer{}, false
+	}
+	if id == "" {
+		writeUnauthorized(w, r, "missing bearer token")
+		return Customer{}, false
+	}
+	c, err := cs.CustomerStore.GetCustomer(id)
+	if err != nil {
+		writeUnauthorized(w, r, "invalid or expired token")
+		return Customer{}, false
+	}
+	return c, true
+}
+
+// customerRequest is the body of requests registering
+// and updating customers.
+type customerRequest struct {
+	Email    *string `json:"email"`
+	Name     *string `json:"name"`
+	Password *string `json:"password"`
+}
+
+// apply sets fields of the customer given in the request.
+func (req customerRequest) apply(c *Customer) error {
+	if req.Email != nil {
+		addr, err := mail.ParseAddress(*req.Email)
+		if err != nil || addr.Address != *req.Email {
+			return fmt.Errorf("invalid email %q", *req.Email)
+		}
+		c.Email = addr.Address
+	}
+	if req.Name != nil {
+		c.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Password != nil {
+		if len(*req.Password) < minPasswordLength {
+			return fmt.Errorf("password must have at least %d characters", minPasswordLength)
+		}
+		if len(*req.Password) > maxPasswordLength {
+			return fmt.Errorf("password must have at most %d bytes", maxPasswordLength)
+		}
+		hash, err := hashPassword(*req.Password)
+		if err != nil {
+			return err
+		}
+		c.PasswordHash = hash
+	}
+	return nil
+}
+
+// RegisterCustomer creates the customer account
+// with the email and the password in the request.
+func (cs *Server) RegisterCustomer(w http.ResponseWriter, r *http.Request) {
+	var req customerRequest
+	if !cs.decodeJSON(w, r, &req, "invalid customer") {
+		return
+	}
+	if req.Email == nil || req.Password == nil {
+		writeError(w, r, http.StatusBadRequest, "email and password are required")
+		return
+	}
+	c := Customer{CreatedAt: cs.Clock.Now()}
+	if err := req.apply(&c); err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	c, err := cs.CustomerStore.CreateCustomer(c)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	w.Header().Set("Location", resourcePath(r, "/customers/me"))
+	writeJSON(w, r, http.StatusCreated, c)
+}
+
+// LoginResponse is the body of successful login responses.
+type LoginResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Customer  Customer  `json:"customer"`
+}
+
+// Login starts the session of the customer with the email and
+// the password in the request. The token in the response
+// authenticates requests sent with it as a bearer token.
+func (cs *Server) Login(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if !cs.decodeJSON(w, r, &req, "invalid credentials") {
+		return
+	}
+	c, err := cs.CustomerStore.GetCustomerByEmail(req.Email)
+	if err != nil && !errors.Is(err, ErrCustomerNotFound) {
+		writeStoreError(w, r, err)
+		return
+	}
+	if err != nil {
+		checkDummyPassword(req.Password)
+	}
+	if err != nil || !checkPassword(c.PasswordHash, req.Password) {
+		writeErrorCode(w, r, http.StatusUnauthorized, "invalid_credentials", "invalid email or password")
+		return
+	}
+	token, s, err := cs.sessions.create(c.ID, cs.Clock.Now())
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	writeJSON(w, r, http.StatusOK, LoginResponse{Token: token, ExpiresAt: s.expiresAt, Customer: c})
+}
+
+// Logout ends the session of the request's bearer token.
+func (cs *Server) Logout(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cs.authenticate(w, r); !ok {
+		return
+	}
+	cs.sessions.delete(bearerToken(r))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetProfile returns the logged in customer.
+func (cs *Server) GetProfile(w http.ResponseWriter, r *http.Request) {
+	c, ok := cs.authenticate(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, r, http.StatusOK, c)
+}
+
+// UpdateProfile changes the email, the name or the password
+// of the logged in customer.
+func (cs *Server) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	c, ok := cs.authenticate(w, r)
+	if !ok {
+		return
+	}
+	var req customerRequest
+	if !cs.decodeJSON(w, r, &req, "invalid customer") {
+		return
+	}
+	if err := req.apply(&c); err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	c, err := cs.CustomerStore.UpdateCustomer(c)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, c)
+}
+
+// GetCustomerOrders returns orders of the logged in customer.
+func (cs *Server) GetCustomerOrders(w http.ResponseWriter, r *http.Request) {
+	c, ok := cs.authenticate(w, r)
+	if !ok {
+		return
+	}
+	orders := []Order{}
+	for _, o := range cs.OrderStore.GetOrders() {
+		if o.CustomerID == c.ID {
+			orders = append(orders, o)
+		}
+	}
+	sortOrders(orders)
+	writeJSON(w, r, http.StatusOK, orders)
+}
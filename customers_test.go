@@ -0,0 +1,265 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// sendAs sends the request with the bearer token, if it isn't empty.
+func sendAs(t *testing.T, token, method, url, body string) *http.Response {
+	t.Helper()
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, url, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// login registers the customer and returns the token of its session.
+func login(t *testing.T, url, email, password string) string {
+	t.Helper()
+	body := `{"email":"` + email + `","password":"` + password + `"}`
+	resp := sendAs(t, "", http.MethodPost, url+"customers", body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("want HTTP 201 for registration, got %d", resp.StatusCode)
+	}
+	resp = sendAs(t, "", http.MethodPost, url+"customers/login", body)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200 for login, got %d", resp.StatusCode)
+	}
+	var got coffeeshop.LoginResponse
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Token == "" || got.Customer.Email != email {
+		t.Fatalf("want token of %s, got %+v", email, got)
+	}
+	return got.Token
+}
+
+func TestServer_RegistersAndLogsInCustomer(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(5)}, "10ms", t)
+	token := login(t, shop.URL, "alice@example.com", "espresso1")
+
+	resp := sendAs(t, token, http.MethodGet, shop.URL+"customers/me", "")
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200, got %d", resp.StatusCode)
+	}
+	if strings.Contains(string(body), "espresso1") || strings.Contains(strings.ToLower(string(body)), "password") {
+		t.Errorf("want profile without password, got %s", body)
+	}
+
+	resp = sendAs(t, token, http.MethodPut, shop.URL+"customers/me", `{"name":"Alice"}`)
+	defer resp.Body.Close()
+	var c coffeeshop.Customer
+	if err := json.NewDecoder(resp.Body).Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "Alice" || c.Email != "alice@example.com" {
+		t.Errorf("want renamed customer, got %+v", c)
+	}
+
+	resp = sendAs(t, token, http.MethodPost, shop.URL+"customers/logout", "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("want HTTP 204 for logout, got %d", resp.StatusCode)
+	}
+	resp = sendAs(t, token, http.MethodGet, shop.URL+"customers/me", "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("want HTTP 401 after logout, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("WWW-Authenticate") == "" {
+		t.Error("want WWW-Authenticate header")
+	}
+}
+
+func TestServer_RejectsInvalidCustomerCredentials(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(5)}, "10ms", t)
+	login(t, shop.URL, "bob@example.com", "flatwhite")
+
+	tcs := []struct {
+		name, path, body string
+		want             int
+	}{
+		{"wrong password", "customers/login", `{"email":"bob@example.com","password":"latte123"}`, http.StatusUnauthorized},
+		{"unknown email", "customers/login", `{"email":"eve@example.com","password":"flatwhite"}`, http.StatusUnauthorized},
+		{"duplicate email", "customers", `{"email":"BOB@example.com","password":"flatwhite"}`, http.StatusConflict},
+		{"invalid email", "customers", `{"email":"bob","password":"flatwhite"}`, http.StatusBadRequest},
+		{"short password", "customers", `{"email":"carol@example.com","password":"mocha"}`, http.StatusBadRequest},
+		{"long password", "customers", `{"email":"carol@example.com","password":"` + strings.Repeat("x", 73) + `"}`, http.StatusBadRequest},
+	}
+	for _, tc := range tcs {
+		resp := sendAs(t, "", http.MethodPost, shop.URL+tc.path, tc.body)
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s: want HTTP %d, got %d", tc.name, tc.want, resp.StatusCode)
+		}
+	}
+
+	resp := sendAs(t, "forged", http.MethodPost, shop.URL+"orders", `{"items":[{"productId":"1","quantity":1}]}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("want HTTP 401 for order with invalid token, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_AttachesOrdersAndCartsToCustomer(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(5)}, "10ms", t,
+		coffeeshop.WithOrderInterval("1h"),
+	)
+	token := login(t, shop.URL, "dave@example.com", "cortado99")
+
+	resp := sendAs(t, token, http.MethodPost, shop.URL+"orders", `{"items":[{"productId":"1","quantity":1}]}`)
+	defer resp.Body.Close()
+	var order coffeeshop.Order
+	if err := json.NewDecoder(resp.Body).Decode(&order); err != nil {
+		t.Fatal(err)
+	}
+	if order.CustomerID == "" {
+		t.Errorf("want order of the customer, got %+v", order)
+	}
+	resp = sendAs(t, "", http.MethodPost, shop.URL+"orders", `{"items":[{"productId":"2","quantity":1}]}`)
+	resp.Body.Close()
+
+	resp = sendAs(t, token, http.MethodPost, shop.URL+"carts", "")
+	defer resp.Body.Close()
+	var cart coffeeshop.Cart
+	if err := json.NewDecoder(resp.Body).Decode(&cart); err != nil {
+		t.Fatal(err)
+	}
+	if cart.CustomerID != order.CustomerID {
+		t.Errorf("want cart of customer %s, got %+v", order.CustomerID, cart)
+	}
+
+	resp = sendAs(t, token, http.MethodGet, shop.URL+"customers/me/orders", "")
+	defer resp.Body.Close()
+	var orders []coffeeshop.Order
+	if err := json.NewDecoder(resp.Body).Decode(&orders); err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].ID != order.ID {
+		t.Errorf("want only order %s, got %+v", order.ID, orders)
+	}
+}
+
+func TestServer_HidesCartsAndOrdersFromOtherCustomers(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(5)}, "10ms", t,
+		coffeeshop.WithOrderInterval("1h"),
+	)
+	frank := login(t, shop.URL, "frank@example.com", "macchiato")
+	grace := login(t, shop.URL, "grace@example.com", "affogato1")
+
+	resp := sendAs(t, frank, http.MethodPost, shop.URL+"orders", `{"items":[{"productId":"1","quantity":1}]}`)
+	defer resp.Body.Close()
+	var order coffeeshop.Order
+	if err := json.NewDecoder(resp.Body).Decode(&order); err != nil {
+		t.Fatal(err)
+	}
+	resp = sendAs(t, frank, http.MethodPost, shop.URL+"carts", "")
+	defer resp.Body.Close()
+	var cart coffeeshop.Cart
+	if err := json.NewDecoder(resp.Body).Decode(&cart); err != nil {
+		t.Fatal(err)
+	}
+
+	tcs := []struct {
+		method, path, body string
+	}{
+		{http.MethodGet, "carts/" + cart.ID, ""},
+		{http.MethodPost, "carts/" + cart.ID + "/items", `{"productId":"1","quantity":1}`},
+		{http.MethodPost, "carts/" + cart.ID + "/checkout", `{"cardNumber":"4242424242424242"}`},
+		{http.MethodGet, "orders/" + order.ID, ""},
+	}
+	for _, tc := range tcs {
+		for name, token := range map[string]string{"anonymous": "", "other customer": grace} {
+			resp := sendAs(t, token, tc.method, shop.URL+tc.path, tc.body)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusNotFound {
+				t.Errorf("%s %s by %s: want HTTP 404, got %d", tc.method, tc.path, name, resp.StatusCode)
+			}
+		}
+		resp := sendAs(t, frank, tc.method, shop.URL+tc.path, tc.body)
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			t.Errorf("%s %s by owner: want success, got HTTP %d", tc.method, tc.path, resp.StatusCode)
+		}
+	}
+
+	for name, token := range map[string]string{"anonymous": "", "other customer": grace} {
+		resp := sendAs(t, token, http.MethodGet, shop.URL+"orders", "")
+		defer resp.Body.Close()
+		var orders []coffeeshop.Order
+		if err := json.NewDecoder(resp.Body).Decode(&orders); err != nil {
+			t.Fatal(err)
+		}
+		for _, o := range orders {
+			if o.CustomerID != "" {
+				t.Errorf("%s: want no orders of customers, got %+v", name, o)
+			}
+		}
+	}
+}
+
+func TestServer_AssignsUnguessableCartIDs(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(1)}, "10ms", t)
+	first := doCartRequest(t, http.MethodPost, shop.URL+"carts", "")
+	second := doCartRequest(t, http.MethodPost, shop.URL+"carts", "")
+	if len(first.ID) < 32 || first.ID == second.ID {
+		t.Errorf("want distinct random cart IDs, got %q and %q", first.ID, second.ID)
+	}
+}
+
+func TestMemoryCustomerStore_FindsCustomersByEmailIgnoringCase(t *testing.T) {
+	t.Parallel()
+
+	store := coffeeshop.MemoryCustomerStore{}
+	c, err := store.CreateCustomer(coffeeshop.Customer{Email: "Erin@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.GetCustomerByEmail("erin@EXAMPLE.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != c.ID {
+		t.Errorf("want customer %s, got %s", c.ID, got.ID)
+	}
+	if _, err := store.GetCustomer("42"); !errors.Is(err, coffeeshop.ErrCustomerNotFound) {
+		t.Errorf("want ErrCustomerNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,320 @@
+package coffeeshop
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// ContextStore is implemented by stores that can bound their
+// calls by a context, such as stores of network backends. The
+// returned store makes calls with the context. Wrappers, like the
+// CachingStore, implement it to call the stores they wrap with the
+// context.
+type ContextStore interface {
+	WithContext(ctx context.Context) Store
+}
+
+// withContext returns the store making calls with the context,
+// if the store is a ContextStore, or the store.
+func withContext(s Store, ctx context.Context) Store {
+	if cs, ok := s.(ContextStore); ok {
+		return cs.WithContext(ctx)
+	}
+	return s
+}
+
+// contextBound reports whether calls of the store returned by
+// withContext are bound by the context. Calls of the memory store
+// don't block, and calls of wrappers, like the CachingStore, are
+// bound if calls of the stores they wrap are.
+func contextBound(s Store) bool {
+	switch s := s.(type) {
+	case *MemoryStore:
+		return true
+	case *TieredStore:
+		return contextBound(s.Primary) && (s.Fallback == nil || contextBound(s.Fallback))
+	case StoreWrapper:
+		return contextBound(s.Unwrap())
+	case ContextStore:
+		return true
+	}
+	return false
+}
+
+// deadlineKey is the context key of the deadlineState.
+type deadlineKey struct{}
+
+// deadlineState records the error of a store call of the request,
+// either ErrStoreTimeout if the call ran past the deadline of the
+// request, or ErrStoreUnavailable if the circuit breaker is open.
+type deadlineState struct {
+	err atomic.Pointer[error]
+}
+
+// fail records the error unless an error is already recorded.
+func (s *deadlineState) fail(err error) {
+	s.err.CompareAndSwap(nil, &err)
+}
+
+// failed returns the recorded error, if any.
+func (s *deadlineState) failed() error {
+	if err := s.err.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+// deadlineWriter drops the response written by the handler
+// after a store call failed, so the request can be answered
+// with the error of the store instead.
+type deadlineWriter struct {
+	http.ResponseWriter
+	state *deadlineState
+	wrote bool
+}
+
+func (dw *deadlineWriter) WriteHeader(status int) {
+	if dw.state.failed() != nil {
+		return
+	}
+	dw.wrote = true
+	dw.ResponseWriter.WriteHeader(status)
+}
+
+func (dw *deadlineWriter) Write(b []byte) (int, error) {
+	if dw.state.failed() != nil {
+		return len(b), nil
+	}
+	dw.wrote = true
+	return dw.ResponseWriter.Write(b)
+}
+
+// FlushError flushes the response unless it's dropped.
+func (dw *deadlineWriter) FlushError() error {
+	if dw.state.failed() != nil {
+		return nil
+	}
+	dw.wrote = true
+	return http.NewResponseController(dw.ResponseWriter).Flush()
+}
+
+func (dw *deadlineWriter) Flush() {
+	_ = dw.FlushError()
+}
+
+// Unwrap returns the original writer, which lets
+// http.ResponseController hijack it.
+func (dw *deadlineWriter) Unwrap() http.ResponseWriter {
+	return dw.ResponseWriter
+}
+
+// enforceDeadline limits requests to the handler timeout extended by
+// the simulated latency. Store calls of handlers are bound by the
+// deadline; if a call runs past it, or the handler doesn't respond in
+// time, the request is answered with 504 Gateway Timeout, unless the
+// handler already started the response. Calls rejected by the open
+// circuit breaker are answered with 503 Service Unavailable. Streaming
+// requests aren't limited, since they last until the client leaves.
+func (cs *Server) enforceDeadline(next http.Handler) http.Handler {
+	timeout := cs.handlerTimeout()
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if streamingRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		state := &deadlineState{}
+		ctx = context.WithValue(ctx, deadlineKey{}, state)
+		header := w.Header().Clone()
+		dw := &deadlineWriter{ResponseWriter: w, state: state}
+		next.ServeHTTP(dw, r.WithContext(ctx))
+
+		if dw.wrote {
+			return
+		}
+		storeErr := state.failed()
+		if storeErr == nil && !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return
+		}
+		// Drop headers describing the response of the handler.
+		for name := range w.Header() {
+			delete(w.Header(), name)
+		}
+		for name, values := range header {
+			w.Header()[name] = values
+		}
+		if storeErr != nil {
+			writeStoreError(w, r, storeErr)
+			return
+		}
+		writeError(w, r, http.StatusGatewayTimeout, "request timed out")
+	}
+	return http.HandlerFunc(fn)
+}
+
+// streamingRequest reports whether the request opens a stream: the
+// product event stream, an event stream of another resource, such
+// as the followed event log, or a WebSocket.
+func streamingRequest(r *http.Request) bool {
+	if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/events") {
+		return true
+	}
+	if types := acceptedTypes(r.Header.Get("Accept")); len(types) > 0 && types[0] == "text/event-stream" {
+		return true
+	}
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// store returns the store making calls within the deadline of
+// the request.
+func (cs *Server) store(r *http.Request) Store {
+	state, ok := r.Context().Value(deadlineKey{}).(*deadlineState)
+	if !ok {
+		return cs.Store
+	}
+	return deadlineStore{Store: cs.Store, ctx: r.Context(), state: state, breaker: cs.breaker}
+}
+
+// deadlineStore bounds calls of the store by the deadline of
+// the request. Stores implementing ContextStore are called with
+// the context of the request. Reads of other stores are abandoned
+// at the deadline, and left to finish in the background. Writes
+// of other stores aren't abandoned, since they would be completed
+// after the client was told that they failed. Calls are rejected
+// without calling the store while the circuit breaker is open.
+type deadlineStore struct {
+	Store
+	ctx     context.Context
+	state   *deadlineState
+	breaker *BreakerStore
+}
+
+// bound returns the store called with the context, and reports
+// whether calls of the store are bound by the context.
+func (ds deadlineStore) bound() (Store, bool) {
+	return withContext(ds.Store, ds.ctx), contextBound(ds.Store)
+}
+
+// Unwrap returns the store called with the context, so optional
+// interfaces of the store, like ProductWriter, are used with the
+// context of the request.
+func (ds deadlineStore) Unwrap() Store {
+	s, _ := ds.bound()
+	return s
+}
+
+// check records ErrStoreTimeout and returns it if the
+// context is done, or returns err.
+func (ds deadlineStore) check(err error) error {
+	if ds.ctx.Err() == nil {
+		return err
+	}
+	ds.state.fail(ErrStoreTimeout)
+	if err == nil {
+		return ErrStoreTimeout
+	}
+	return errors.Join(ErrStoreTimeout, err)
+}
+
+// admit returns the error of the call of the store made after
+// the deadline or while the circuit breaker is open.
+func (ds deadlineStore) admit() error {
+	if ds.breaker != nil && !ds.breaker.allows() {
+		ds.state.fail(ErrStoreUnavailable)
+		return ErrStoreUnavailable
+	}
+	return ds.check(nil)
+}
+
+// read calls the store within the deadline.
+func read[T any](ds deadlineStore, call func(s Store) (T, error)) (T, error) {
+	var zero T
+	if err := ds.admit(); err != nil {
+		return zero, err
+	}
+	s, ok := ds.bound()
+	if ok {
+		v, err := call(s)
+		if err := ds.check(err); err != nil {
+			return zero, err
+		}
+		return v, err
+	}
+	type result struct {
+		v   T
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, err := call(s)
+		done <- result{v: v, err: err}
+	}()
+	select {
+	case res := <-done:
+		return res.v, res.err
+	case <-ds.ctx.Done():
+		return zero, ds.check(nil)
+	}
+}
+
+func (ds deadlineStore) GetAll() []Product {
+	px, _ := read(ds, func(s Store) ([]Product, error) { return s.GetAll(), nil })
+	return px
+}
+
+func (ds deadlineStore) ListAll() ([]Product, error) {
+	return read(ds, func(s Store) ([]Product, error) { return listAll(s) })
+}
+
+func (ds deadlineStore) ListByType(productType string) ([]Product, error) {
+	return read(ds, func(s Store) ([]Product, error) { return listByType(s, productType) })
+}
+
+func (ds deadlineStore) GetProduct(id string) (Product, error) {
+	return read(ds, func(s Store) (Product, error) { return s.GetProduct(id) })
+}
+
+func (ds deadlineStore) GetMany(ids []string) ([]Product, error) {
+	return read(ds, func(s Store) ([]Product, error) { return getMany(s, ids) })
+}
+
+func (ds deadlineStore) GetBySKU(code string) (Product, error) {
+	return read(ds, func(s Store) (Product, error) { return productBySKU(s, code) })
+}
+
+func (ds deadlineStore) ProductStats() (ProductStats, error) {
+	return read(ds, func(s Store) (ProductStats, error) { return storeStats(s) })
+}
+
+func (ds deadlineStore) GetByType(productType string) []Product {
+	px, _ := read(ds, func(s Store) ([]Product, error) { return s.GetByType(productType), nil })
+	return px
+}
+
+func (ds deadlineStore) ReserveStock(items []OrderItem) error {
+	if err := ds.admit(); err != nil {
+		return err
+	}
+	s, ok := ds.bound()
+	err := s.ReserveStock(items)
+	if ok && err != nil {
+		return ds.check(err)
+	}
+	return err
+}
+
+func (ds deadlineStore) SetStock(id string, stock int) (Product, error) {
+	if err := ds.admit(); err != nil {
+		return Product{}, err
+	}
+	s, ok := ds.bound()
+	p, err := s.SetStock(id, stock)
+	if ok && err != nil {
+		return p, ds.check(err)
+	}
+	return p, err
+}
@@ -0,0 +1,111 @@
+package coffeeshop_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// slowStore delays reads of the memory store.
+type slowStore struct {
+	*coffeeshop.MemoryStore
+	delay time.Duration
+}
+
+func (s slowStore) GetAll() []coffeeshop.Product {
+	time.Sleep(s.delay)
+	return s.MemoryStore.GetAll()
+}
+
+// contextStore records deadlines of contexts it's called with.
+type contextStore struct {
+	*coffeeshop.MemoryStore
+	deadlines chan time.Time
+}
+
+func (s contextStore) WithContext(ctx context.Context) coffeeshop.Store {
+	deadline, _ := ctx.Deadline()
+	s.deadlines <- deadline
+	return s.MemoryStore
+}
+
+func TestServer_RespondsWithGatewayTimeoutToSlowStore(t *testing.T) {
+	t.Parallel()
+
+	store := slowStore{MemoryStore: &coffeeshop.MemoryStore{Products: inventory}, delay: time.Second}
+	shop := newCoffeShopTestServer(store, "0s", t, coffeeshop.WithHandlerTimeout("50ms"))
+	resp, err := http.Get(shop.URL + "products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Fatalf("want HTTP 504, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("ETag") != "" {
+		t.Error("want no ETag of the dropped response")
+	}
+	var got coffeeshop.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Error.Code != "store_timeout" {
+		t.Errorf("want error code store_timeout, got %q", got.Error.Code)
+	}
+	if got.Error.RequestID == "" {
+		t.Error("want request ID in the error")
+	}
+}
+
+func TestServer_CallsContextStoresWithRequestDeadline(t *testing.T) {
+	t.Parallel()
+
+	store := contextStore{MemoryStore: &coffeeshop.MemoryStore{Products: inventory}, deadlines: make(chan time.Time, 1)}
+	shop := newCoffeShopTestServer(store, "0s", t, coffeeshop.WithHandlerTimeout("5s"))
+	start := time.Now()
+	resp, err := http.Get(shop.URL + "products/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200, got %d", resp.StatusCode)
+	}
+	deadline := <-store.deadlines
+	if deadline.Before(start) || deadline.After(start.Add(6*time.Second)) {
+		t.Errorf("want deadline within the handler timeout, got %s after the request", deadline.Sub(start))
+	}
+}
+
+func TestServer_CallsContextStoresWithRequestDeadlineThroughWrappers(t *testing.T) {
+	t.Parallel()
+
+	store := contextStore{MemoryStore: &coffeeshop.MemoryStore{Products: inventory}, deadlines: make(chan time.Time, 1)}
+	shop := newCoffeShopTestServer(store, "0s", t,
+		coffeeshop.WithHandlerTimeout("5s"),
+		coffeeshop.WithStoreRetries(3, "10ms", "100ms"),
+		coffeeshop.WithCircuitBreaker(5, "10s"),
+		coffeeshop.WithCache("1m"),
+	)
+	start := time.Now()
+	resp, err := http.Get(shop.URL + "products/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200, got %d", resp.StatusCode)
+	}
+	select {
+	case deadline := <-store.deadlines:
+		if deadline.Before(start) || deadline.After(start.Add(6*time.Second)) {
+			t.Errorf("want deadline within the handler timeout, got %s after the request", deadline.Sub(start))
+		}
+	default:
+		t.Fatal("want store behind the retries, the breaker and the cache called with the request context")
+	}
+}
@@ -0,0 +1,233 @@
+package coffeeshop
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// redacted replaces values of redacted headers and fields in logs.
+const redacted = "[REDACTED]"
+
+// maxLoggedBodySize limits response bodies written to debug logs.
+const maxLoggedBodySize = 64 << 10
+
+// DefaultRedactions are headers and JSON fields redacted from logged
+// and recorded requests and responses unless WithRedactions replaces
+// them.
+var DefaultRedactions = []string{"Authorization", "Cookie", "Set-Cookie", "X-API-Key", "cardNumber", "password", "token"}
+
+// WithDebugLogging logs requests and responses of API endpoints
+// including headers and bodies, with values of redacted headers,
+// query parameters and JSON fields replaced. Response bodies over
+// 64 KiB and bodies of event streams aren't logged.
+// Debug logging can also be switched on and off at runtime with
+// PUT /admin/debug.
+func WithDebugLogging() Option {
+	return func(s *Server) error {
+		s.DebugLogging = true
+		return nil
+	}
+}
+
+// WithRedactions configures the names of headers and JSON fields,
+// at any depth, whose values are redacted from debug logs and from
+// recordings. Names are matched regardless of case. They replace
+// DefaultRedactions.
+func WithRedactions(names ...string) Option {
+	return func(s *Server) error {
+		s.Redactions = names
+		return nil
+	}
+}
+
+// redacts reports whether the header or the JSON field is redacted.
+func (cs *Server) redacts(name string) bool {
+	for _, r := range cs.Redactions {
+		if strings.EqualFold(r, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactHeader returns the header with values of redacted
+// headers replaced, in the wire format.
+func (cs *Server) redactHeader(h http.Header) string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		for _, v := range h[name] {
+			if cs.redacts(name) {
+				v = redacted
+			}
+			fmt.Fprintf(&b, "%s: %s\n", name, v)
+		}
+	}
+	return b.String()
+}
+
+// redactedHeader returns a copy of the header with
+// values of redacted headers replaced.
+func (cs *Server) redactedHeader(h http.Header) http.Header {
+	h = h.Clone()
+	for name, values := range h {
+		if cs.redacts(name) {
+			for i := range values {
+				values[i] = redacted
+			}
+		}
+	}
+	return h
+}
+
+// redactURI returns the request URI with values
+// of redacted query parameters replaced.
+func (cs *Server) redactURI(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.RequestURI()
+	}
+	q, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return u.EscapedPath() + "?" + redacted
+	}
+	replaced := false
+	for name, values := range q {
+		if cs.redacts(name) {
+			for i := range values {
+				values[i] = redacted
+			}
+			replaced = true
+		}
+	}
+	if !replaced {
+		return u.RequestURI()
+	}
+	return u.EscapedPath() + "?" + q.Encode()
+}
+
+// redactBody returns the body with values of redacted fields
+// replaced, if the body is JSON with redacted fields. Other
+// bodies are returned as is.
+func (cs *Server) redactBody(body []byte) []byte {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return body
+	}
+	if !cs.redactValue(v) {
+		return body
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return data
+}
+
+// redactValue replaces values of redacted fields in the decoded
+// JSON value and reports whether it replaced any.
+func (cs *Server) redactValue(v any) bool {
+	replaced := false
+	switch v := v.(type) {
+	case map[string]any:
+		for k, field := range v {
+			if cs.redacts(k) {
+				v[k] = redacted
+				replaced = true
+				continue
+			}
+			if cs.redactValue(field) {
+				replaced = true
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if cs.redactValue(item) {
+				replaced = true
+			}
+		}
+	}
+	return replaced
+}
+
+// logBodies logs requests and responses while debug logging is on.
+func (cs *Server) logBodies(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if !cs.debug.Load() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, cs.MaxBodySize))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "can't read request body")
+			return
+		}
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+		out := limitedBuffer{limit: maxLoggedBodySize}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(&out)
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		var logged []byte
+		switch {
+		case streaming(w.Header()):
+			logged = []byte("[event stream]")
+		case out.truncated:
+			// Truncated JSON can't be redacted.
+			logged = []byte(fmt.Sprintf("[body over %d bytes]", maxLoggedBodySize))
+		default:
+			logged = cs.redactBody(out.Bytes())
+		}
+		cs.logf("debug: %s %s %s\n%s\n%s\n%d %s\n%s\n%s",
+			middleware.GetReqID(r.Context()), r.Method, cs.redactURI(r.URL),
+			cs.redactHeader(r.Header), cs.redactBody(body),
+			status, http.StatusText(status),
+			cs.redactHeader(w.Header()), logged,
+		)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// debugMode describes debug logging of the server.
+type debugMode struct {
+	Enabled    bool     `json:"enabled"`
+	Redactions []string `json:"redactions"`
+}
+
+// GetDebug reports whether debug logging is on.
+func (cs *Server) GetDebug(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, debugMode{Enabled: cs.debug.Load(), Redactions: cs.Redactions})
+}
+
+// SetDebug switches debug logging on or off as requested in
+// the body, for example {"enabled": true}.
+func (cs *Server) SetDebug(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if !cs.decodeJSON(w, r, &req, "invalid debug mode") {
+		return
+	}
+	if req.Enabled == nil {
+		writeFieldErrors(w, r, "invalid debug mode", []FieldError{{Field: "enabled", Message: "is required"}})
+		return
+	}
+	cs.debug.Store(*req.Enabled)
+	cs.logf("debug: logging of requests and responses switched to %t", *req.Enabled)
+	writeJSON(w, r, http.StatusOK, debugMode{Enabled: *req.Enabled, Redactions: cs.Redactions})
+}
@@ -0,0 +1,120 @@
+package coffeeshop_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// debugHandler returns the handler of a server without latency
+// logging to the buffer.
+func debugHandler(t *testing.T, logs *bytes.Buffer, opts ...coffeeshop.Option) http.Handler {
+	t.Helper()
+	logTo := func(s *coffeeshop.Server) error {
+		s.HTTPServer.ErrorLog = log.New(logs, "", 0)
+		return nil
+	}
+	opts = append([]coffeeshop.Option{coffeeshop.WithLatency("0s"), logTo}, opts...)
+	srv, err := coffeeshop.New(":0", &coffeeshop.MemoryStore{Products: inventory}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv.Handler()
+}
+
+func serveRequest(h http.Handler, method, path, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestServer_LogsRedactedBodiesInDebugMode(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+	h := debugHandler(t, &logs, coffeeshop.WithDebugLogging())
+	w := serveRequest(h, http.MethodPost, "/customers",
+		`{"email":"ann@example.com","name":"Ann","password":"s3cret-pass"}`,
+		http.Header{"Authorization": {"Bearer abc123"}},
+	)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("want HTTP 201, got %d", w.Code)
+	}
+	got := logs.String()
+	for _, want := range []string{"POST /customers", `"email":"ann@example.com"`, `"password":"[REDACTED]"`, "Authorization: [REDACTED]", "201 Created"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want %q in logs, got:\n%s", want, got)
+		}
+	}
+	for _, secret := range []string{"s3cret-pass", "abc123"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("want %q redacted, got:\n%s", secret, got)
+		}
+	}
+}
+
+func TestServer_RedactsConfiguredFields(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+	h := debugHandler(t, &logs, coffeeshop.WithDebugLogging(), coffeeshop.WithRedactions("name"))
+	serveRequest(h, http.MethodGet, "/products/1", "", nil)
+	got := logs.String()
+	if !strings.Contains(got, `"name":"[REDACTED]"`) {
+		t.Errorf("want product name redacted, got:\n%s", got)
+	}
+}
+
+func TestServer_SwitchesDebugLoggingAtRuntime(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+	h := debugHandler(t, &logs)
+	serveRequest(h, http.MethodGet, "/products", "", nil)
+	if logs.Len() != 0 {
+		t.Fatalf("want no logs before debug mode is on, got:\n%s", logs.String())
+	}
+	w := serveRequest(h, http.MethodPut, "/admin/debug", `{"enabled":true}`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("want HTTP 200, got %d", w.Code)
+	}
+	serveRequest(h, http.MethodGet, "/products", "", nil)
+	if !strings.Contains(logs.String(), "GET /products") {
+		t.Errorf("want request logged after debug mode is on, got:\n%s", logs.String())
+	}
+	w = serveRequest(h, http.MethodPut, "/admin/debug", `{}`, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("want HTTP 400 without enabled, got %d", w.Code)
+	}
+}
+
+func TestServer_RedactsQueryAndSkipsEventStreamsInDebugLogs(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+	h := debugHandler(t, &logs, coffeeshop.WithDebugLogging())
+	serveRequest(h, http.MethodGet, "/products?type=Tea&token=abc123", "", nil)
+	got := logs.String()
+	if strings.Contains(got, "abc123") || !strings.Contains(got, "token=%5BREDACTED%5D") {
+		t.Errorf("want token redacted from query, got:\n%s", got)
+	}
+
+	logs.Reset()
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(ctx)
+	cancel()
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if got := logs.String(); !strings.Contains(got, "[event stream]") {
+		t.Errorf("want event stream body skipped, got:\n%s", got)
+	}
+}
@@ -0,0 +1,284 @@
+package coffeeshop
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/maps"
+)
+
+// DefaultMaxBodySize is the default limit of the size
+// of JSON request bodies.
+const DefaultMaxBodySize = 1 << 20
+
+// FieldError describes a problem with a field of a request body.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// WithMaxBodySize configures the limit of the size of JSON request
+// bodies. Larger bodies are rejected with 413 Request Entity Too
+// Large. Image uploads and imports have their own limits.
+func WithMaxBodySize(n int64) Option {
+	return func(s *Server) error {
+		if n <= 0 {
+			return errors.New("max body size must be positive")
+		}
+		s.MaxBodySize = n
+		return nil
+	}
+}
+
+// decodeJSON decodes the JSON request body into v. Bodies over the
+// size limit, with unknown fields, fields of wrong types or data
+// after the JSON value are rejected. On failure it responds with
+// the message and all problems with fields and returns false.
+func (cs *Server) decodeJSON(w http.ResponseWriter, r *http.Request, v any, message string) bool {
+	problems, ok := cs.decodeBody(w, r, v)
+	if !ok {
+		return false
+	}
+	if len(problems) > 0 {
+		writeFieldErrors(w, r, message, problems)
+		return false
+	}
+	return true
+}
+
+// decodeBody decodes the JSON request body into v, like decodeJSON,
+// and returns all problems with fields of the body instead of
+// responding with them, so they can be reported together with
+// problems of the decoded value. Fields without problems are decoded
+// into v even if other fields have problems. If the body can't be
+// read, it responds with an error and returns false.
+func (cs *Server) decodeBody(w http.ResponseWriter, r *http.Request, v any) ([]FieldError, bool) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, cs.MaxBodySize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+		return nil, false
+	}
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "can't read request body")
+		return nil, false
+	}
+	var problems []FieldError
+	dec := json.NewDecoder(bytes.NewReader(data))
+	err = dec.Decode(v)
+	if err == nil && dec.More() {
+		err = errors.New("unexpected data after JSON value")
+	}
+	if err != nil {
+		problems = decodeFields(data, v)
+		if problems == nil {
+			return decodeProblems(err), true
+		}
+	}
+	for _, field := range unknownFields(data, reflect.TypeOf(v)) {
+		problems = append(problems, FieldError{Field: field, Message: "unknown field"})
+	}
+	return problems, true
+}
+
+// decodeFields decodes the JSON object into the struct v field by
+// field and returns problems with all fields that can't be decoded.
+// Other fields are decoded into v. It returns nil if data isn't an
+// object decoded into a struct, or if all fields can be decoded.
+func decodeFields(data []byte, v any) []FieldError {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || reflect.PointerTo(t).Implements(unmarshalerType) {
+		return nil
+	}
+	obj, ok := decodeObject(data)
+	if !ok {
+		return nil
+	}
+	fields := jsonFields(t)
+	var problems []FieldError
+	valid := jsonObject{}
+	for _, m := range obj {
+		ft, ok := fields[strings.ToLower(m.Key)]
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal(m.Value, reflect.New(ft).Interface()); err != nil {
+			for _, p := range decodeProblems(err) {
+				switch {
+				case p.Field == "body" || strings.EqualFold(p.Field, m.Key):
+					p.Field = m.Key
+				default:
+					p.Field = m.Key + "." + p.Field
+				}
+				problems = append(problems, p)
+			}
+			continue
+		}
+		valid = append(valid, m)
+	}
+	if problems == nil {
+		return nil
+	}
+	if data, err := json.Marshal(valid); err == nil {
+		_ = json.Unmarshal(data, v)
+	}
+	return problems
+}
+
+// decodeProblems describes the error decoding a request body.
+func decodeProblems(err error) []FieldError {
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			field = "body"
+		}
+		return []FieldError{{Field: field, Message: fmt.Sprintf("must be %s, got %s", jsonTypeName(typeErr.Type.Kind().String()), typeErr.Value)}}
+	case errors.As(err, &syntaxErr):
+		return []FieldError{{Field: "body", Message: fmt.Sprintf("malformed JSON at offset %d", syntaxErr.Offset)}}
+	case errors.Is(err, io.EOF):
+		return []FieldError{{Field: "body", Message: "empty body"}}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return []FieldError{{Field: "body", Message: "truncated JSON"}}
+	}
+	return []FieldError{{Field: "body", Message: err.Error()}}
+}
+
+// unmarshalerType is the type of values decoding themselves.
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// unknownFields returns paths of all fields, in the order of names,
+// of the JSON value without a matching field in the type, for
+// example "items.0.colour". Values of types decoding themselves are
+// not checked.
+func unknownFields(data []byte, t reflect.Type) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		return nil
+	}
+	var paths []string
+	nested := func(prefix string, data []byte, t reflect.Type) {
+		for _, path := range unknownFields(data, t) {
+			paths = append(paths, prefix+"."+path)
+		}
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return nil
+		}
+		fields := jsonFields(t)
+		names := maps.Keys(obj)
+		sort.Strings(names)
+		for _, name := range names {
+			ft, ok := fields[strings.ToLower(name)]
+			if !ok {
+				paths = append(paths, name)
+				continue
+			}
+			nested(name, obj[name], ft)
+		}
+	case reflect.Slice, reflect.Array:
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil
+		}
+		for i, item := range items {
+			nested(strconv.Itoa(i), item, t.Elem())
+		}
+	case reflect.Map:
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return nil
+		}
+		keys := maps.Keys(obj)
+		sort.Strings(keys)
+		for _, key := range keys {
+			nested(key, obj[key], t.Elem())
+		}
+	}
+	return paths
+}
+
+// jsonFields returns types of fields of the struct type by their
+// lower case JSON names, as encoding/json matches names regardless
+// of case. Fields of embedded structs are promoted unless the outer
+// struct has a field with the same name.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[strings.ToLower(name)] = ft
+	}
+	for _, et := range embedded {
+		for name, ft := range jsonFields(et) {
+			if _, ok := fields[name]; !ok {
+				fields[name] = ft
+			}
+		}
+	}
+	return fields
+}
+
+// jsonTypeName returns the JSON name of the Go kind.
+func jsonTypeName(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "a number"
+	case kind == "string":
+		return "a string"
+	case kind == "bool":
+		return "a boolean"
+	case kind == "slice", kind == "array":
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+// writeFieldErrors responds with 400 Bad Request
+// listing problems with fields of the request.
+func writeFieldErrors(w http.ResponseWriter, r *http.Request, message string, problems []FieldError) {
+	detail := newErrorDetail(r, errorCode(http.StatusBadRequest), message)
+	detail.Fields = problems
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writeJSON(w, r, http.StatusBadRequest, ErrorResponse{Error: detail})
+}
@@ -0,0 +1,111 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+func TestServer_RejectsInvalidJSONWithFieldErrors(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(10)}, "0s", t,
+		coffeeshop.WithOrderInterval("1h"),
+	)
+	tcs := []struct {
+		name, body string
+		want       []coffeeshop.FieldError
+	}{
+		{
+			name: "unknown field",
+			body: `{"items":[{"productId":"1","quantity":1}],"express":true}`,
+			want: []coffeeshop.FieldError{{Field: "express", Message: "unknown field"}},
+		},
+		{
+			name: "unknown nested field",
+			body: `{"items":[{"productId":"1","quantity":1,"colour":"red"}]}`,
+			want: []coffeeshop.FieldError{{Field: "items.0.colour", Message: "unknown field"}},
+		},
+		{
+			name: "wrong type",
+			body: `{"items":"espresso"}`,
+			want: []coffeeshop.FieldError{{Field: "items", Message: "must be an array, got string"}},
+		},
+		{
+			name: "several problems",
+			body: `{"items":"espresso","express":true,"note":1}`,
+			want: []coffeeshop.FieldError{
+				{Field: "items", Message: "must be an array, got string"},
+				{Field: "express", Message: "unknown field"},
+				{Field: "note", Message: "unknown field"},
+			},
+		},
+		{
+			name: "malformed JSON",
+			body: `{"items":[}`,
+			want: []coffeeshop.FieldError{{Field: "body", Message: "malformed JSON at offset 11"}},
+		},
+		{
+			name: "trailing data",
+			body: `{"items":[{"productId":"1","quantity":1}]} {}`,
+			want: []coffeeshop.FieldError{{Field: "body", Message: "unexpected data after JSON value"}},
+		},
+		{
+			name: "empty body",
+			want: []coffeeshop.FieldError{{Field: "body", Message: "empty body"}},
+		},
+	}
+	for _, tc := range tcs {
+		resp := createOrder(t, shop.URL, tc.body)
+		var e coffeeshop.ErrorResponse
+		err := json.NewDecoder(resp.Body).Decode(&e)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: want HTTP 400, got %d", tc.name, resp.StatusCode)
+		}
+		if !cmp.Equal(tc.want, e.Error.Fields) {
+			t.Errorf("%s: %s", tc.name, cmp.Diff(tc.want, e.Error.Fields))
+		}
+	}
+}
+
+func TestServer_RejectsBodiesOverSizeLimit(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(10)}, "0s", t,
+		coffeeshop.WithMaxBodySize(64),
+	)
+	body := `{"items":[` + strings.Repeat(`{"productId":"1","quantity":1},`, 10) + `{"productId":"1","quantity":1}]}`
+	resp := createOrder(t, shop.URL, body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("want HTTP 413, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_ReportsMissingCardNumberField(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(10)}, "0s", t)
+	cart := doCartRequest(t, http.MethodPost, shop.URL+"carts", "")
+	resp, err := http.Post(shop.URL+"carts/"+cart.ID+"/checkout", "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var e coffeeshop.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+		t.Fatal(err)
+	}
+	want := []coffeeshop.FieldError{{Field: "cardNumber", Message: "is required"}}
+	if resp.StatusCode != http.StatusBadRequest || !cmp.Equal(want, e.Error.Fields) {
+		t.Errorf("want HTTP 400 with %+v, got %d with %+v", want, resp.StatusCode, e.Error.Fields)
+	}
+}
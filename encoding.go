@@ -0,0 +1,111 @@
+package coffeeshop
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// jsonEncoder is a JSON encoder writing to a reusable buffer.
+type jsonEncoder struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+var encoderPool = sync.Pool{
+	New: func() any {
+		e := &jsonEncoder{}
+		e.enc = json.NewEncoder(&e.buf)
+		return e
+	},
+}
+
+// prettyRequested reports whether the client asked for
+// indented JSON using the 'pretty' query parameter.
+func prettyRequested(r *http.Request) bool {
+	q := r.URL.Query()
+	if !q.Has("pretty") {
+		return false
+	}
+	v := q.Get("pretty")
+	if v == "" {
+		return true
+	}
+	pretty, err := strconv.ParseBool(v)
+	return err == nil && pretty
+}
+
+// writeJSON encodes v as JSON and writes it to the response with
+// the given status code. Responses are compact unless the client
+// asks for indented JSON with the 'pretty' query parameter.
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	if status < http.StatusMultipleChoices {
+		selected, unknown := selectedFields(r, v)
+		if len(unknown) > 0 {
+			writeUnknownFields(w, r, unknown)
+			return
+		}
+		v = selected
+	}
+	e := encoderPool.Get().(*jsonEncoder)
+	defer encoderPool.Put(e)
+	e.buf.Reset()
+	if prettyRequested(r) {
+		e.enc.SetIndent("", "  ")
+	} else {
+		e.enc.SetIndent("", "")
+	}
+	if err := e.enc.Encode(v); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(e.buf.Bytes())
+}
+
+// ndjsonContentType is the media type of newline delimited JSON.
+const ndjsonContentType = "application/x-ndjson"
+
+// streamProducts writes products to the response one by one, either
+// as elements of a JSON array or, if the client accepts it, as
+// newline delimited JSON. Indented responses are not streamed.
+func (cs *Server) streamProducts(w http.ResponseWriter, r *http.Request, px []Product) {
+	ndjson := strings.Contains(r.Header.Get("Accept"), ndjsonContentType)
+	if prettyRequested(r) && !ndjson {
+		writeJSON(w, r, http.StatusOK, cs.productsView(r, px))
+		return
+	}
+	if len(px) > 0 {
+		if _, unknown := selectedFields(r, cs.productView(r, px[0])); len(unknown) > 0 {
+			writeUnknownFields(w, r, unknown)
+			return
+		}
+	}
+	if ndjson {
+		w.Header().Set("Content-Type", ndjsonContentType)
+	}
+	w.WriteHeader(http.StatusOK)
+
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+	enc := json.NewEncoder(bw)
+	if !ndjson {
+		bw.WriteByte('[')
+	}
+	for i, p := range px {
+		if i > 0 && !ndjson {
+			bw.WriteByte(',')
+		}
+		v, _ := selectedFields(r, cs.productView(r, p))
+		if err := enc.Encode(v); err != nil {
+			return
+		}
+	}
+	if !ndjson {
+		bw.WriteString("]\n")
+	}
+}
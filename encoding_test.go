@@ -0,0 +1,95 @@
+package coffeeshop_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/qba73/coffeeshop"
+	"golang.org/x/exp/maps"
+)
+
+func getBody(t *testing.T, url string) []byte {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestServer_ReturnsCompactJSONByDefault(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	got := getBody(t, shop.URL+"products/7")
+	if bytes.Contains(bytes.TrimSpace(got), []byte("\n")) {
+		t.Errorf("want compact JSON, got %s", got)
+	}
+}
+
+func TestServer_ReturnsIndentedJSONWhenPrettyRequested(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	for _, query := range []string{"?pretty", "?pretty=1", "?pretty=true"} {
+		got := getBody(t, shop.URL+"products/7"+query)
+		if !bytes.Contains(got, []byte("\n  \"id\": \"7\"")) {
+			t.Errorf("%s: want indented JSON, got %s", query, got)
+		}
+	}
+}
+
+func TestServer_StreamsProductsAsNDJSON(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	req, err := http.NewRequest(http.MethodGet, shop.URL+"products", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "application/x-ndjson")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if got := resp.Header.Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("want NDJSON content type, got %q", got)
+	}
+	var got []coffeeshop.Product
+	dec := json.NewDecoder(resp.Body)
+	for dec.More() {
+		var p coffeeshop.Product
+		if err := dec.Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, p)
+	}
+	want := maps.Values(inventory)
+	if !cmp.Equal(want, got, cmpopts.SortSlices(func(i, j coffeeshop.Product) bool { return i.ID < j.ID })) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
@@ -0,0 +1,141 @@
+package coffeeshop
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Errors returned by stores. Use errors.Is to check for them.
+var (
+	ErrProductNotFound    = errors.New("product not found")
+	ErrInvalidProduct     = errors.New("invalid product")
+	ErrProductExists      = errors.New("product already exists")
+	ErrSKUExists          = errors.New("sku already used by another product")
+	ErrVersionMismatch    = errors.New("product version mismatch")
+	ErrOutOfStock         = errors.New("out of stock")
+	ErrOrderNotFound      = errors.New("order not found")
+	ErrCartNotFound       = errors.New("cart not found")
+	ErrImageNotFound      = errors.New("image not found")
+	ErrCustomerNotFound   = errors.New("customer not found")
+	ErrCustomerExists     = errors.New("customer already exists")
+	ErrPromotionNotFound  = errors.New("promotion not found")
+	ErrPromotionExists    = errors.New("promotion already exists")
+	ErrPromotionExpired   = errors.New("promotion expired")
+	ErrPromotionExhausted = errors.New("promotion used up")
+	ErrTenantNotFound     = errors.New("tenant not found")
+	ErrTenantExists       = errors.New("tenant already exists")
+	ErrInvalidCursor      = errors.New("invalid sync cursor")
+	// ErrCursorExpired is returned for sync cursors issued
+	// before changes the store doesn't know about.
+	ErrCursorExpired = errors.New("sync cursor expired")
+	// ErrStoreTimeout is returned by store calls running
+	// past the deadline of the request.
+	ErrStoreTimeout = errors.New("store deadline exceeded")
+)
+
+// errorMappings maps errors returned by stores
+// to HTTP statuses and error codes.
+var errorMappings = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{ErrProductNotFound, http.StatusNotFound, "product_not_found"},
+	{ErrOrderNotFound, http.StatusNotFound, "order_not_found"},
+	{ErrCartNotFound, http.StatusNotFound, "cart_not_found"},
+	{ErrImageNotFound, http.StatusNotFound, "image_not_found"},
+	{ErrCustomerNotFound, http.StatusNotFound, "customer_not_found"},
+	{ErrPromotionNotFound, http.StatusNotFound, "promotion_not_found"},
+	{ErrTenantNotFound, http.StatusNotFound, "tenant_not_found"},
+	{ErrInvalidProduct, http.StatusBadRequest, "invalid_product"},
+	{ErrCurrencyMismatch, http.StatusUnprocessableEntity, "currency_mismatch"},
+	{ErrInvalidCursor, http.StatusBadRequest, "invalid_cursor"},
+	{ErrProductExists, http.StatusConflict, "product_exists"},
+	{ErrSKUExists, http.StatusConflict, "sku_exists"},
+	{ErrCustomerExists, http.StatusConflict, "customer_exists"},
+	{ErrPromotionExists, http.StatusConflict, "promotion_exists"},
+	{ErrTenantExists, http.StatusConflict, "tenant_exists"},
+	{ErrPromotionExpired, http.StatusUnprocessableEntity, "promotion_expired"},
+	{ErrPromotionExhausted, http.StatusUnprocessableEntity, "promotion_exhausted"},
+	{ErrVersionMismatch, http.StatusPreconditionFailed, "version_mismatch"},
+	{ErrCursorExpired, http.StatusGone, "cursor_expired"},
+	{ErrOutOfStock, http.StatusConflict, "out_of_stock"},
+	{ErrStoreTimeout, http.StatusGatewayTimeout, "store_timeout"},
+	{ErrStoreUnavailable, http.StatusServiceUnavailable, "store_unavailable"},
+}
+
+// requestIDHeader is the response header echoing the request ID.
+const requestIDHeader = "X-Request-ID"
+
+// ErrorDetail describes an error returned by the API.
+type ErrorDetail struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+	// Fields lists problems with fields of invalid requests.
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// ErrorResponse is the body of error responses.
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// errorCode returns the error code for the HTTP status,
+// for example 'not_found' for 404 Not Found.
+func errorCode(status int) string {
+	return strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+}
+
+func newErrorDetail(r *http.Request, code, message string) ErrorDetail {
+	return ErrorDetail{
+		Code:      code,
+		Message:   message,
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+}
+
+// writeError responds with the status code and the error
+// envelope holding the message and the request ID.
+func writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	writeErrorCode(w, r, status, errorCode(status), message)
+}
+
+// writeErrorCode responds with the error envelope
+// holding the given error code.
+func writeErrorCode(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writeJSON(w, r, status, ErrorResponse{Error: newErrorDetail(r, code, message)})
+}
+
+// writeStoreError responds with the status and the error code
+// mapped from the error returned by a store. Unknown errors
+// result in 500 Internal Server Error.
+func writeStoreError(w http.ResponseWriter, r *http.Request, err error) {
+	var stockErr *OutOfStockError
+	if errors.As(err, &stockErr) {
+		writeOutOfStock(w, r, stockErr)
+		return
+	}
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			writeErrorCode(w, r, m.status, m.code, err.Error())
+			return
+		}
+	}
+	writeError(w, r, http.StatusInternalServerError, "internal error")
+}
+
+// echoRequestID sets the response header to the ID
+// assigned to the request by the RequestID middleware.
+func echoRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := middleware.GetReqID(r.Context()); id != "" {
+			w.Header().Set(requestIDHeader, id)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
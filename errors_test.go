@@ -0,0 +1,152 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+func TestServer_ReturnsErrorEnvelopeWithRequestID(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp, err := http.Get(shop.URL + "products/42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("want status 404, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "application/json; charset=utf-8" {
+		t.Errorf("want JSON content type, got %q", got)
+	}
+	requestID := resp.Header.Get("X-Request-ID")
+	if requestID == "" {
+		t.Fatal("want request ID header")
+	}
+	var got coffeeshop.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := coffeeshop.ErrorResponse{
+		Error: coffeeshop.ErrorDetail{
+			Code:      "product_not_found",
+			Message:   "product not found",
+			RequestID: requestID,
+		},
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestServer_PropagatesRequestIDFromClient(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	req, err := http.NewRequest(http.MethodGet, shop.URL+"products/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Request-Id", "abc-123")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if got := resp.Header.Get("X-Request-ID"); got != "abc-123" {
+		t.Errorf("want request ID abc-123, got %q", got)
+	}
+}
+
+func TestServer_ReturnsErrorEnvelopeForUnknownRoute(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp, err := http.Get(shop.URL + "espresso")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var got coffeeshop.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusNotFound || got.Error.Code != "not_found" {
+		t.Errorf("want 404 not_found error, got %d %q", resp.StatusCode, got.Error.Code)
+	}
+}
+
+func TestMemoryStore_ReturnsSentinelErrors(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(1),
+	}
+
+	_, err := store.GetProduct("42")
+	if !errors.Is(err, coffeeshop.ErrProductNotFound) {
+		t.Errorf("want ErrProductNotFound, got %v", err)
+	}
+	_, err = store.SetStock("1", -1)
+	if !errors.Is(err, coffeeshop.ErrInvalidProduct) {
+		t.Errorf("want ErrInvalidProduct, got %v", err)
+	}
+	err = store.ReserveStock([]coffeeshop.OrderItem{{ProductID: "1", Quantity: 2}})
+	if !errors.Is(err, coffeeshop.ErrOutOfStock) {
+		t.Errorf("want ErrOutOfStock, got %v", err)
+	}
+	var stockErr *coffeeshop.OutOfStockError
+	if !errors.As(err, &stockErr) {
+		t.Errorf("want *OutOfStockError, got %T", err)
+	}
+}
+
+func TestServer_MapsStoreErrorsToCodes(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	tests := map[string]string{
+		"orders/42": "order_not_found",
+		"carts/42":  "cart_not_found",
+	}
+	for path, code := range tests {
+		resp, err := http.Get(shop.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got coffeeshop.ErrorResponse
+		err = json.NewDecoder(resp.Body).Decode(&got)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusNotFound || got.Error.Code != code {
+			t.Errorf("%s: want 404 %s, got %d %s", path, code, resp.StatusCode, got.Error.Code)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package coffeeshop
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// productsETag returns a strong entity tag of the products in
+// the variant, a hash of the variant and of the JSON
+// representation of the products. The tag doesn't depend on
+// the order of products.
+func productsETag(variant string, px []Product) (string, error) {
+	less := func(i, j int) bool { return px[i].ID < px[j].ID }
+	if !sort.SliceIsSorted(px, less) {
+		px = append([]Product(nil), px...)
+		sort.Slice(px, less)
+	}
+	e := encoderPool.Get().(*jsonEncoder)
+	defer encoderPool.Put(e)
+	e.buf.Reset()
+	e.buf.WriteString(variant)
+	e.buf.WriteByte('\n')
+	e.enc.SetIndent("", "")
+	if err := e.enc.Encode(px); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(bytes.TrimSuffix(e.buf.Bytes(), []byte("\n")))
+	var tag [34]byte
+	tag[0] = '"'
+	hex.Encode(tag[1:33], sum[:16])
+	tag[33] = '"'
+	return string(tag[:]), nil
+}
+
+// variant returns what the representation of the products sent in
+// response to the request depends on besides the products: the
+// negotiated media type, the requested currency and fields, and
+// languages of descriptions.
+func (cs *Server) variant(r *http.Request, px []Product) string {
+	currency := requestCurrency(r)
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	v := cs.negotiate(r) + ";currency=" + currency
+	if fields := fieldsKey(r); fields != "" {
+		v += ";fields=" + fields
+	}
+	if langs := cs.descriptionLanguages(r, px); len(langs) > 0 {
+		v += ";lang=" + strings.Join(langs, ",")
+	}
+	return v
+}
+
+// etagMatches reports whether the tag matches any of the tags
+// in the If-None-Match header value using weak comparison.
+func etagMatches(ifNoneMatch, tag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	tag = strings.TrimPrefix(tag, "W/")
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// strongETagMatches reports whether the If-Match header value is "*"
+// or lists the tag using strong comparison (RFC 9110, section 8.8.3.2),
+// so weak tags never match.
+func strongETagMatches(ifMatch, tag string) bool {
+	if strings.HasPrefix(tag, "W/") {
+		return false
+	}
+	for _, t := range strings.Split(ifMatch, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// productETag returns the entity tag GET /products/{id}
+// sends for the product in response to the request.
+func (cs *Server) productETag(r *http.Request, p Product) (string, error) {
+	px := []Product{p}
+	if _, err := cs.convertPricesTo(r, px); err != nil {
+		return "", err
+	}
+	cs.addRatings(px)
+	return productsETag(cs.variant(r, px), px)
+}
+
+// ifMatch checks the If-Match or, without it, the If-Unmodified-Since
+// header of the request changing the product. If-Match lists entity
+// tags sent by GET /products/{id} for the same currency. It returns
+// the current version of the product, or zero if both headers are
+// missing, and reports whether the change can proceed. Otherwise it
+// responds with an error. Invalid If-Unmodified-Since dates are
+// ignored.
+func (cs *Server) ifMatch(w http.ResponseWriter, r *http.Request, id string) (int, bool) {
+	im := r.Header.Get("If-Match")
+	ius, iusErr := http.ParseTime(r.Header.Get("If-Unmodified-Since"))
+	if im == "" && iusErr != nil {
+		return 0, true
+	}
+	current, err := cs.store(r).GetProduct(id)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return 0, false
+	}
+	if im != "" {
+		tag, err := cs.productETag(r, current)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "unsupported currency")
+			return 0, false
+		}
+		if !strongETagMatches(im, tag) {
+			writeStoreError(w, r, fmt.Errorf("%w: product %s has entity tag %s", ErrVersionMismatch, id, tag))
+			return 0, false
+		}
+		return current.Version, true
+	}
+	modified := cs.lastModified([]Product{current}).UTC().Truncate(time.Second)
+	if modified.After(ius) {
+		writeStoreError(w, r, fmt.Errorf("%w: product %s was modified at %s", ErrVersionMismatch, id, modified.Format(http.TimeFormat)))
+		return 0, false
+	}
+	return current.Version, true
+}
+
+// WithCachePolicy configures the server to allow clients to cache
+// product responses for the given time. Zero maxAge requires clients
+// to revalidate cached responses on every request.
+func WithCachePolicy(maxAge time.Duration) Option {
+	return func(s *Server) error {
+		if maxAge < 0 {
+			return errors.New("negative cache max age")
+		}
+		s.CacheControl = "no-cache"
+		if maxAge > 0 {
+			s.CacheControl = fmt.Sprintf("max-age=%d", int(maxAge.Seconds()))
+		}
+		return nil
+	}
+}
+
+// lastModified returns the time when any of the products
+// was modified. Products that weren't modified since the
+// server started are assumed to be modified at start time.
+func (cs *Server) lastModified(px []Product) time.Time {
+	modified := cs.startedAt
+	for _, p := range px {
+		if p.UpdatedAt != nil && p.UpdatedAt.After(modified) {
+			modified = *p.UpdatedAt
+		}
+	}
+	return modified
+}
+
+// notModified sets caching and Content-Language headers for the
+// products and reports whether the client already has the current
+// representation. In that case it responds with 304 Not Modified.
+func (cs *Server) notModified(w http.ResponseWriter, r *http.Request, px ...Product) (bool, error) {
+	tag, err := productsETag(cs.variant(r, px), px)
+	if err != nil {
+		return false, err
+	}
+	varyProducts(w)
+	if langs := cs.descriptionLanguages(r, px); len(langs) > 0 {
+		w.Header().Set("Content-Language", strings.Join(langs, ", "))
+	}
+	modified := cs.lastModified(px).UTC().Truncate(time.Second)
+	w.Header().Set("ETag", tag)
+	w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+	if cs.CacheControl != "" {
+		w.Header().Set("Cache-Control", cs.CacheControl)
+	}
+
+	// If-None-Match takes precedence over If-Modified-Since.
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if !etagMatches(inm, tag) {
+			return false, nil
+		}
+		w.WriteHeader(http.StatusNotModified)
+		return true, nil
+	}
+	h := r.Header.Get("If-Modified-Since")
+	if h == "" {
+		return false, nil
+	}
+	ims, err := http.ParseTime(h)
+	if err != nil || modified.After(ims) {
+		return false, nil
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true, nil
+}
@@ -0,0 +1,180 @@
+package coffeeshop_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+func getWithETag(t *testing.T, url, etag string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestServer_Returns304OnMatchingETag(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	for _, path := range []string{"products", "products/3", "products/coffee", "categories/tea/products"} {
+		resp := getWithETag(t, shop.URL+path, "")
+		etag := resp.Header.Get("ETag")
+		if etag == "" {
+			t.Fatalf("%s: want ETag header", path)
+		}
+		resp = getWithETag(t, shop.URL+path, etag)
+		if resp.StatusCode != http.StatusNotModified {
+			t.Errorf("%s: want HTTP 304, got %d", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestServer_TagsEachMediaTypeOfProductsSeparately(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "10ms", t)
+	get := func(accept, etag string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, shop.URL+"products/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", accept)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	jsonTag := get("application/json", "").Header.Get("ETag")
+	xmlTag := get("application/xml", "").Header.Get("ETag")
+	if jsonTag == xmlTag {
+		t.Fatalf("want different ETags of JSON and XML, got %s", jsonTag)
+	}
+	if resp := get("application/xml", jsonTag); resp.StatusCode != http.StatusOK {
+		t.Errorf("want HTTP 200 for XML with ETag of JSON, got %d", resp.StatusCode)
+	}
+	resp := get("application/xml", xmlTag)
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("want HTTP 304, got %d", resp.StatusCode)
+	}
+	want := []string{"Accept", "Accept-Language", "Accept-Currency"}
+	if got := resp.Header.Values("Vary"); !cmp.Equal(want, got) {
+		t.Errorf("want Vary: Accept, Accept-Language, Accept-Currency on 304, got %q", got)
+	}
+}
+
+func TestServer_ReturnsStableETagForCollections(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	want := getWithETag(t, shop.URL+"products", "").Header.Get("ETag")
+	for i := 0; i < 5; i++ {
+		got := getWithETag(t, shop.URL+"products", "").Header.Get("ETag")
+		if want != got {
+			t.Fatalf("want stable ETag %s, got %s", want, got)
+		}
+	}
+}
+
+func TestServer_Returns200OnStaleETag(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(3),
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	etag := getWithETag(t, shop.URL+"products/1", "").Header.Get("ETag")
+
+	order := createOrder(t, shop.URL, `{"items":[{"productId":"1","quantity":1}]}`)
+	order.Body.Close()
+
+	resp := getWithETag(t, shop.URL+"products/1", etag)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("want HTTP 200OK, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_SetsCacheControlWhenConfigured(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t, coffeeshop.WithCachePolicy(90*time.Second))
+	resp := getWithETag(t, shop.URL+"products", "")
+	if got := resp.Header.Get("Cache-Control"); got != "max-age=90" {
+		t.Errorf("want Cache-Control max-age=90, got %q", got)
+	}
+	if resp.Header.Get("Last-Modified") == "" {
+		t.Error("want Last-Modified header")
+	}
+}
+
+func TestServer_Returns304WhenNotModifiedSince(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(3),
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	lastModified := getWithETag(t, shop.URL+"products/2", "").Header.Get("Last-Modified")
+
+	resp := getIfModifiedSince(t, shop.URL+"products/2", lastModified)
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("want HTTP 304, got %d", resp.StatusCode)
+	}
+
+	// Last-Modified has one second resolution.
+	time.Sleep(time.Second)
+	order := createOrder(t, shop.URL, `{"items":[{"productId":"2","quantity":1}]}`)
+	order.Body.Close()
+
+	resp = getIfModifiedSince(t, shop.URL+"products/2", lastModified)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("want HTTP 200OK after modification, got %d", resp.StatusCode)
+	}
+}
+
+func getIfModifiedSince(t *testing.T, url, since string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("If-Modified-Since", since)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
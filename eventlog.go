@@ -0,0 +1,438 @@
+package coffeeshop
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultEventLogLimit is the number of logged events
+	// returned by GetEventLog unless the client asks for
+	// a different number.
+	defaultEventLogLimit = 100
+	// maxEventLogLimit limits logged events returned at once.
+	maxEventLogLimit = 1000
+)
+
+// LoggedEvent is an event appended to the event log
+// at the offset. Offsets start at zero.
+type LoggedEvent struct {
+	Offset int `json:"offset"`
+	Event
+}
+
+// EventLog records all events published by the server and the
+// store, so clients can replay them.
+type EventLog interface {
+	// Append adds the event at the end of the log
+	// and returns its offset.
+	Append(e Event) (int, error)
+	// Read returns up to limit events starting at the
+	// offset, in the order they were appended.
+	Read(from, limit int) ([]LoggedEvent, error)
+}
+
+// MemoryEventLog keeps events in memory.
+type MemoryEventLog struct {
+	mx     sync.RWMutex
+	events []LoggedEvent
+}
+
+// Append adds the event at the end of the log.
+func (l *MemoryEventLog) Append(e Event) (int, error) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	offset := len(l.events)
+	l.events = append(l.events, LoggedEvent{Offset: offset, Event: e})
+	return offset, nil
+}
+
+// Read returns up to limit events starting at the offset.
+func (l *MemoryEventLog) Read(from, limit int) ([]LoggedEvent, error) {
+	l.mx.RLock()
+	defer l.mx.RUnlock()
+	events := []LoggedEvent{}
+	for i := from; i < len(l.events) && len(events) < limit; i++ {
+		events = append(events, l.events[i])
+	}
+	return events, nil
+}
+
+// FileEventLog appends events to the file as JSON objects
+// separated by newlines, so the log survives restarts.
+type FileEventLog struct {
+	Path string
+
+	mx sync.Mutex
+	// offsets holds the position of each event in the file
+	// once the file is indexed on first use, and end the
+	// position following the last event.
+	offsets []int64
+	end     int64
+	indexed bool
+}
+
+// Append adds the event at the end of the file,
+// creating it if necessary.
+func (l *FileEventLog) Append(e Event) (int, error) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	if err := l.index(); err != nil {
+		return 0, err
+	}
+	offset := len(l.offsets)
+	data, err := json.Marshal(LoggedEvent{Offset: offset, Event: e})
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.OpenFile(l.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return 0, err
+	}
+	if err := f.Close(); err != nil {
+		return 0, err
+	}
+	l.offsets = append(l.offsets, l.end)
+	l.end += int64(len(data)) + 1
+	return offset, nil
+}
+
+// Read reads up to limit events starting at the offset from the
+// file. It seeks to the offset, and doesn't block appends while
+// reading, since events already in the file don't change.
+func (l *FileEventLog) Read(from, limit int) ([]LoggedEvent, error) {
+	l.mx.Lock()
+	if err := l.index(); err != nil {
+		l.mx.Unlock()
+		return nil, err
+	}
+	if from >= len(l.offsets) {
+		l.mx.Unlock()
+		return []LoggedEvent{}, nil
+	}
+	if n := len(l.offsets) - from; limit > n {
+		limit = n
+	}
+	pos := l.offsets[from]
+	l.mx.Unlock()
+
+	events := []LoggedEvent{}
+	err := l.scan(pos, limit, func(line []byte) error {
+		var e LoggedEvent
+		if err := json.Unmarshal(line, &e); err != nil {
+			return fmt.Errorf("%s: event %d: %w", l.Path, from+len(events), err)
+		}
+		events = append(events, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// index records positions of events in the file
+// unless they are already recorded.
+func (l *FileEventLog) index() error {
+	if l.indexed {
+		return nil
+	}
+	var pos int64
+	err := l.scan(0, math.MaxInt, func(line []byte) error {
+		l.offsets = append(l.offsets, pos)
+		pos += int64(len(line)) + 1
+		return nil
+	})
+	if err != nil {
+		l.offsets = nil
+		return err
+	}
+	l.end = pos
+	l.indexed = true
+	return nil
+}
+
+// scan calls the function with up to limit lines of the file
+// starting at the position. Missing files are empty.
+func (l *FileEventLog) scan(pos int64, limit int, f func(line []byte) error) error {
+	file, err := os.Open(l.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Seek(pos, io.SeekStart); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for n := 0; n < limit && scanner.Scan(); n++ {
+		if err := f(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// WithEventLog configures the server to record events
+// in the log instead of the memory.
+func WithEventLog(l EventLog) Option {
+	return func(s *Server) error {
+		if l == nil {
+			return errors.New("nil event log")
+		}
+		s.EventLog = l
+		return nil
+	}
+}
+
+// WithEventLogFile configures the server to append events to the file.
+func WithEventLogFile(path string) Option {
+	return WithEventLog(&FileEventLog{Path: path})
+}
+
+// eventTapper is implemented by stores passing
+// their events to taps.
+type eventTapper interface {
+	tapEvents(f func(Event))
+}
+
+func (ms *MemoryStore) tapEvents(f func(Event)) {
+	ms.events.tap(f)
+}
+
+// logEvents appends events of orders and of the store
+// to the event log.
+func (cs *Server) logEvents() {
+	cs.eventLog.log = cs.EventLog
+	cs.eventLog.logf = cs.logf
+	cs.eventLog.cond.L = &cs.eventLog.mx
+	cs.orderEvents.tap(cs.eventLog.enqueue)
+	if t, ok := storeAs[eventTapper](cs.Store); ok {
+		t.tapEvents(cs.eventLog.enqueue)
+	}
+}
+
+// eventLogWriter appends events to the event log in the background.
+// Events are tapped while the broker, and often the store, is locked,
+// so they are only queued there, and never wait for the log.
+type eventLogWriter struct {
+	log  EventLog
+	logf func(format string, v ...any)
+
+	mx sync.Mutex
+	// cond is signalled when events are written.
+	cond sync.Cond
+	// pending are events waiting to be appended by
+	// the writing goroutine, if one is running.
+	pending []Event
+	writing bool
+	closed  bool
+	// queued and written count events queued and handled,
+	// so readers can wait for events queued before them.
+	queued, written int
+	failed          int
+	lastErr         error
+	// appended is closed when events are appended to the log.
+	appended chan struct{}
+}
+
+// enqueue queues the event for appending, starting the
+// writing goroutine unless it's running. Events published
+// after the writer is closed aren't logged.
+func (lw *eventLogWriter) enqueue(e Event) {
+	lw.mx.Lock()
+	defer lw.mx.Unlock()
+	if lw.closed {
+		return
+	}
+	lw.pending = append(lw.pending, e)
+	lw.queued++
+	if !lw.writing {
+		lw.writing = true
+		go lw.write()
+	}
+}
+
+// write appends pending events until none are left. Failed
+// appends are counted and logged, since no caller waits for them.
+func (lw *eventLogWriter) write() {
+	lw.mx.Lock()
+	defer lw.mx.Unlock()
+	for len(lw.pending) > 0 {
+		events := lw.pending
+		lw.pending = nil
+		lw.mx.Unlock()
+		failed := 0
+		var lastErr error
+		for _, e := range events {
+			if _, err := lw.log.Append(e); err != nil {
+				lw.logf("event log: can't append %s event: %v", e.Type, err)
+				failed++
+				lastErr = err
+			}
+		}
+		lw.mx.Lock()
+		lw.written += len(events)
+		if failed > 0 {
+			lw.failed += failed
+			lw.lastErr = lastErr
+		}
+		if lw.appended != nil {
+			close(lw.appended)
+			lw.appended = nil
+		}
+		lw.cond.Broadcast()
+	}
+	lw.writing = false
+}
+
+// flush waits until events queued so far are appended.
+func (lw *eventLogWriter) flush() {
+	lw.mx.Lock()
+	defer lw.mx.Unlock()
+	for queued := lw.queued; lw.written < queued; {
+		lw.cond.Wait()
+	}
+}
+
+// close appends queued events and stops logging new ones.
+func (lw *eventLogWriter) close() {
+	lw.flush()
+	lw.mx.Lock()
+	defer lw.mx.Unlock()
+	lw.closed = true
+}
+
+// appendedNext returns a channel closed when
+// the next events are appended to the log.
+func (lw *eventLogWriter) appendedNext() <-chan struct{} {
+	lw.mx.Lock()
+	defer lw.mx.Unlock()
+	if lw.appended == nil {
+		lw.appended = make(chan struct{})
+	}
+	return lw.appended
+}
+
+// failures returns the number of events that couldn't
+// be appended and the error of the last one.
+func (lw *eventLogWriter) failures() (int, error) {
+	lw.mx.Lock()
+	defer lw.mx.Unlock()
+	return lw.failed, lw.lastErr
+}
+
+// eventLogPage is a page of logged events.
+type eventLogPage struct {
+	Events []LoggedEvent `json:"events"`
+	// Next is the offset of the event following the page.
+	Next int `json:"next"`
+}
+
+// GetEventLog returns up to "limit" events logged from the offset in
+// the "from" query parameter, and the offset of the next page. Clients
+// accepting text/event-stream replay logged events from the offset
+// and then follow new events as Server-Sent Events, resuming after
+// the offset in the Last-Event-ID header when they reconnect.
+func (cs *Server) GetEventLog(w http.ResponseWriter, r *http.Request) {
+	from, err := queryInt(r, "from", 0, 0, math.MaxInt)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if types := acceptedTypes(r.Header.Get("Accept")); len(types) > 0 && types[0] == "text/event-stream" {
+		if id := r.Header.Get("Last-Event-ID"); id != "" {
+			last, err := strconv.Atoi(id)
+			if err != nil || last < 0 {
+				writeError(w, r, http.StatusBadRequest, fmt.Sprintf("invalid Last-Event-ID %q", id))
+				return
+			}
+			from = last + 1
+		}
+		cs.followEventLog(w, r, from)
+		return
+	}
+	limit, err := queryInt(r, "limit", defaultEventLogLimit, 1, maxEventLogLimit)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Events published before the request are logged first.
+	cs.eventLog.flush()
+	events, err := cs.EventLog.Read(from, limit)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	writeJSON(w, r, http.StatusOK, eventLogPage{Events: events, Next: from + len(events)})
+}
+
+// followEventLog streams logged events from the offset, and then
+// new events, as Server-Sent Events with offsets as IDs. The stream
+// isn't limited by the handler timeout; it ends when the client
+// disconnects or the server shuts down.
+func (cs *Server) followEventLog(w http.ResponseWriter, r *http.Request, from int) {
+	rc := http.NewResponseController(w)
+	cs.extendWriteDeadline(rc)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	next := from
+	for {
+		// The channel is taken before reading the log,
+		// so events appended meanwhile aren't missed.
+		appended := cs.eventLog.appendedNext()
+		events, err := cs.EventLog.Read(next, maxEventLogLimit)
+		if err != nil {
+			cs.logf("event log: can't read events from %d: %v", next, err)
+			return
+		}
+		cs.extendWriteDeadline(rc)
+		for _, e := range events {
+			if err := writeServerSentEvent(w, strconv.Itoa(e.Offset), e.Type, e); err != nil {
+				return
+			}
+			next = e.Offset + 1
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+		if len(events) == maxEventLogLimit {
+			continue
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-cs.shuttingDown.Done():
+			return
+		case <-heartbeat.C:
+			cs.extendWriteDeadline(rc)
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case <-appended:
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package coffeeshop_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+type eventLogPage struct {
+	Events []coffeeshop.LoggedEvent `json:"events"`
+	Next   int                      `json:"next"`
+}
+
+func getEventLog(t *testing.T, url string) eventLogPage {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200, got %d", resp.StatusCode)
+	}
+	var page eventLogPage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	return page
+}
+
+func loggedTypes(events []coffeeshop.LoggedEvent) []coffeeshop.EventType {
+	types := []coffeeshop.EventType{}
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+// changeProductsAndOrder creates a product, orders the last
+// product in stock and deletes the created product.
+func changeProductsAndOrder(t *testing.T, shop *coffeeshop.Server) {
+	t.Helper()
+	if status, _ := createProduct(t, shop.URL+"products", `{"type": "Tea", "name": "Green"}`); status != http.StatusCreated {
+		t.Fatalf("want HTTP 201, got %d", status)
+	}
+	resp := createOrder(t, shop.URL, `{"items":[{"productId":"1","quantity":1}]}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("want HTTP 201, got %d", resp.StatusCode)
+	}
+	resp = send(t, http.MethodDelete, shop.URL+"products/2")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("want HTTP 204, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_LogsEventsOfProductsAndOrders(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: stockedInventory(1)}
+	shop := newCoffeShopTestServer(store, "0s", t, coffeeshop.WithIDGenerator(&coffeeshop.SequentialIDs{}))
+	changeProductsAndOrder(t, shop)
+
+	page := getEventLog(t, shop.URL+"events/log")
+	want := []coffeeshop.EventType{
+		coffeeshop.ProductAdded,
+		coffeeshop.ProductUpdated,
+		coffeeshop.ProductOutOfStock,
+		coffeeshop.OrderCreated,
+		coffeeshop.ProductUpdated,
+	}
+	if got := loggedTypes(page.Events); !cmp.Equal(want, got) {
+		t.Fatal(cmp.Diff(want, got))
+	}
+	for i, e := range page.Events {
+		if e.Offset != i {
+			t.Errorf("want event %d at offset %d, got %d", i, i, e.Offset)
+		}
+	}
+	if page.Next != len(want) {
+		t.Errorf("want next offset %d, got %d", len(want), page.Next)
+	}
+
+	page = getEventLog(t, shop.URL+"events/log?from=2&limit=2")
+	if got := loggedTypes(page.Events); !cmp.Equal(want[2:4], got) {
+		t.Error(cmp.Diff(want[2:4], got))
+	}
+	if page.Next != 4 {
+		t.Errorf("want next offset 4, got %d", page.Next)
+	}
+	if page := getEventLog(t, shop.URL+"events/log?from=5"); len(page.Events) != 0 || page.Next != 5 {
+		t.Errorf("want no events after the last one, got %+v", page)
+	}
+}
+
+func TestServer_LogsEventsOfProductsAndOrdersWithDistinctIDs(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: stockedInventory(1)}
+	shop := newCoffeShopTestServer(store, "0s", t, coffeeshop.WithIDGenerator(&coffeeshop.SequentialIDs{}))
+	changeProductsAndOrder(t, shop)
+
+	page := getEventLog(t, shop.URL+"events/log")
+	if len(page.Events) != 5 {
+		t.Fatalf("want 5 logged events, got %d", len(page.Events))
+	}
+	last := 0
+	for _, e := range page.Events {
+		id, err := strconv.Atoi(e.ID)
+		if err != nil {
+			t.Fatalf("want numeric event ID, got %q", e.ID)
+		}
+		if id <= last {
+			t.Errorf("want %s event at offset %d with ID greater than %d, got %d", e.Type, e.Offset, last, id)
+		}
+		last = id
+	}
+}
+
+func TestServer_RejectsInvalidEventLogOffsets(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(1)}, "0s", t)
+	for _, query := range []string{"from=-1", "from=abc", "limit=0", "limit=1001"} {
+		status, _ := getResponse(t, shop.URL+"events/log?"+query)
+		if status != http.StatusBadRequest {
+			t.Errorf("%s: want HTTP 400, got %d", query, status)
+		}
+	}
+}
+
+func TestServer_AppendsEventLogToFileAcrossRestarts(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	for i := 0; i < 2; i++ {
+		shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(1)}, "0s", t,
+			coffeeshop.WithIDGenerator(&coffeeshop.SequentialIDs{}),
+			coffeeshop.WithEventLogFile(path),
+		)
+		changeProductsAndOrder(t, shop)
+	}
+
+	log := coffeeshop.FileEventLog{Path: path}
+	events, err := log.Read(0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 10 || events[9].Offset != 9 || events[5].Type != coffeeshop.ProductAdded {
+		t.Fatalf("want 10 events from both servers, got %+v", events)
+	}
+	if events[3].Type != coffeeshop.OrderCreated {
+		t.Errorf("want order created at offset 3, got %s", events[3].Type)
+	}
+}
+
+func TestFileEventLog_ReadsNoEventsBeforeFirstAppend(t *testing.T) {
+	t.Parallel()
+
+	log := coffeeshop.FileEventLog{Path: filepath.Join(t.TempDir(), "events.jsonl")}
+	events, err := log.Read(0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("want no events, got %+v", events)
+	}
+}
+
+func TestServer_ReplaysEventLogAndFollowsNewEvents(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: stockedInventory(1)}
+	shop := newCoffeShopTestServer(store, "0s", t, coffeeshop.WithIDGenerator(&coffeeshop.SequentialIDs{}))
+	changeProductsAndOrder(t, shop)
+
+	req, err := http.NewRequest(http.MethodGet, shop.URL+"events/log", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Last-Event-ID", "2")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("want event stream content type, got %q", got)
+	}
+
+	resp2 := sendAs(t, "", http.MethodPut, shop.URL+"products/1/stock", `{"stock": 3}`)
+	resp2.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	var ids []string
+	for len(ids) < 3 && scanner.Scan() {
+		if id, ok := strings.CutPrefix(scanner.Text(), "id: "); ok {
+			ids = append(ids, id)
+		}
+	}
+	if want := []string{"3", "4", "5"}; !cmp.Equal(want, ids) {
+		t.Error(cmp.Diff(want, ids))
+	}
+}
+
+// gatedEventLog fails appends, and blocks them until the gate is open.
+type gatedEventLog struct {
+	gate chan struct{}
+}
+
+func (l gatedEventLog) Append(e coffeeshop.Event) (int, error) {
+	<-l.gate
+	return 0, errors.New("disk full")
+}
+
+func (l gatedEventLog) Read(from, limit int) ([]coffeeshop.LoggedEvent, error) {
+	return []coffeeshop.LoggedEvent{}, nil
+}
+
+func TestServer_AppendsEventsWithoutBlockingStoreAndReportsFailures(t *testing.T) {
+	t.Parallel()
+
+	log := gatedEventLog{gate: make(chan struct{})}
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(1)}, "0s", t,
+		coffeeshop.WithEventLog(log),
+	)
+	done := make(chan int)
+	go func() {
+		resp := sendAs(t, "", http.MethodPut, shop.URL+"products/1/stock", `{"stock": 3}`)
+		resp.Body.Close()
+		done <- resp.StatusCode
+	}()
+	select {
+	case status := <-done:
+		if status != http.StatusOK {
+			t.Fatalf("want HTTP 200, got %d", status)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("want stock updated while the event log blocks")
+	}
+	close(log.gate)
+
+	// Reading the log waits for events published before.
+	getEventLog(t, shop.URL+"events/log")
+	_, metrics := getResponse(t, shop.URL+"metrics")
+	if !strings.Contains(metrics, "coffeeshop_event_log_append_failures_total 1") {
+		t.Errorf("want 1 failed append in metrics, got:\n%s", metrics)
+	}
+	_, body := getHealth(t, shop.URL+"healthz")
+	if checks, _ := body["checks"].(map[string]any); checks["event_log"] != "disk full" {
+		t.Errorf("want event log error reported by /healthz, got %v", body)
+	}
+}
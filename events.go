@@ -0,0 +1,396 @@
+package coffeeshop
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// EventType identifies the kind of change described by an Event.
+type EventType string
+
+const (
+	ProductAdded      EventType = "product.added"
+	ProductUpdated    EventType = "product.updated"
+	ProductDeleted    EventType = "product.deleted"
+	ProductOutOfStock EventType = "product.outofstock"
+	OrderCreated      EventType = "order.created"
+	OrderUpdated      EventType = "order.updated"
+	// EventsMissed tells streams that events were published which
+	// are no longer retained, so clients must read the resources
+	// again to catch up.
+	EventsMissed EventType = "events.missed"
+)
+
+// Event describes a change of a resource. Data holds
+// the state of the resource after the change.
+type Event struct {
+	ID   string    `json:"id"`
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data"`
+}
+
+// subscriberBuffer is the number of events buffered for each
+// subscriber. Events are dropped for subscribers that fall behind,
+// who can read them again with EventsAfter while they're retained.
+const subscriberBuffer = 64
+
+// brokerHistory is the number of recent events retained by brokers.
+const brokerHistory = 1024
+
+// eventSequence assigns IDs to events published by brokers of the
+// server, so events of products and orders never share an ID.
+type eventSequence struct {
+	mx     sync.Mutex
+	lastID int
+}
+
+// Broker delivers published events to all subscribers.
+// The zero value is ready to use.
+type Broker struct {
+	mx sync.Mutex
+	// lastID is the ID of the last event published by the broker.
+	lastID int
+	// seq assigns IDs to events of brokers of the server. Brokers
+	// without a sequence number their events themselves.
+	seq  *eventSequence
+	subs map[chan Event]struct{}
+	// taps receive every published event, in order.
+	taps []func(Event)
+	// history holds the most recent events, oldest first, and
+	// evicted is the ID of the last event dropped from it.
+	history []Event
+	evicted int
+	// dropped counts events subscribers missed
+	// because their buffers were full.
+	dropped uint64
+	// clock timestamps events. Nil means the real time.
+	clock Clock
+}
+
+// Subscribe returns a channel receiving published events and
+// a function that cancels the subscription and closes the channel.
+func (b *Broker) Subscribe() (<-chan Event, func()) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if b.subs == nil {
+		b.subs = make(map[chan Event]struct{})
+	}
+	ch := make(chan Event, subscriberBuffer)
+	b.subs[ch] = struct{}{}
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mx.Lock()
+			defer b.mx.Unlock()
+			delete(b.subs, ch)
+			close(ch)
+		})
+	}
+}
+
+// Publish assigns the event a new ID and sends it to all
+// subscribers. It never blocks: subscribers with full buffers
+// miss the event.
+func (b *Broker) Publish(typ EventType, data any) Event {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if b.seq != nil {
+		// Events of all brokers of the server are passed
+		// to taps in the order of their IDs.
+		b.seq.mx.Lock()
+		defer b.seq.mx.Unlock()
+		b.seq.lastID++
+		b.lastID = b.seq.lastID
+	} else {
+		b.lastID++
+	}
+	e := Event{
+		ID:   strconv.Itoa(b.lastID),
+		Type: typ,
+		Time: now(b.clock),
+		Data: data,
+	}
+	for _, f := range b.taps {
+		f(e)
+	}
+	if len(b.history) == brokerHistory {
+		b.evicted, _ = strconv.Atoi(b.history[0].ID)
+		b.history = append(b.history[:0], b.history[1:]...)
+	}
+	b.history = append(b.history, e)
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			b.dropped++
+		}
+	}
+	return e
+}
+
+// useEventIDs makes the broker take IDs of events from the sequence,
+// unless it already does. The sequence continues after the last event
+// of the broker, so IDs of its events keep growing.
+func (b *Broker) useEventIDs(seq *eventSequence) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if b.seq != nil {
+		return
+	}
+	seq.mx.Lock()
+	defer seq.mx.Unlock()
+	if seq.lastID < b.lastID {
+		seq.lastID = b.lastID
+	}
+	b.seq = seq
+}
+
+func (b *Broker) useClock(c Clock) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if b.clock == nil {
+		b.clock = c
+	}
+}
+
+// EventsAfter returns events published after the event with the ID,
+// oldest first. It returns false if some of them are no longer
+// retained, or the ID wasn't assigned by the broker.
+func (b *Broker) EventsAfter(id string) ([]Event, bool) {
+	last, err := strconv.Atoi(id)
+	if err != nil || last < 0 {
+		return nil, false
+	}
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if last > b.lastID || last < b.evicted {
+		return nil, false
+	}
+	// IDs of events of brokers sharing a sequence have gaps,
+	// but grow with every event.
+	i := sort.Search(len(b.history), func(i int) bool {
+		id, _ := strconv.Atoi(b.history[i].ID)
+		return id > last
+	})
+	return append([]Event(nil), b.history[i:]...), true
+}
+
+// Dropped returns the number of events subscribers
+// missed because their buffers were full.
+func (b *Broker) Dropped() uint64 {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	return b.dropped
+}
+
+// missedEvents returns the event received by the subscriber after
+// the event with the last ID, preceded by events published between
+// them, which the subscriber missed. Events already received are
+// skipped. It returns false if missed events are no longer retained.
+func missedEvents(r EventReplayer, last string, e Event) ([]Event, bool) {
+	if last == "" {
+		return []Event{e}, true
+	}
+	lastID, err1 := strconv.Atoi(last)
+	id, err2 := strconv.Atoi(e.ID)
+	switch {
+	case err1 != nil || err2 != nil || id == lastID+1:
+		return []Event{e}, true
+	case id <= lastID:
+		return nil, true
+	}
+	events, ok := r.EventsAfter(last)
+	if !ok {
+		return []Event{e}, false
+	}
+	for i, missed := range events {
+		if missed.ID == e.ID {
+			return events[:i+1], true
+		}
+	}
+	return append(events, e), true
+}
+
+// tap makes the broker call the function with every event
+// published afterwards. Unlike subscribers, taps never miss
+// events, so they must not block.
+func (b *Broker) tap(f func(Event)) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.taps = append(b.taps, f)
+}
+
+// Notifier is implemented by stores publishing product changes.
+type Notifier interface {
+	Subscribe() (<-chan Event, func())
+}
+
+// EventReplayer is implemented by Notifiers retaining recent events,
+// so streams resume after the last event clients received and don't
+// lose events they fell behind on.
+type EventReplayer interface {
+	// EventsAfter returns retained events published after the
+	// event with the ID, or false if some are no longer retained.
+	EventsAfter(id string) ([]Event, bool)
+}
+
+// eventDropCounter is implemented by Notifiers
+// counting events dropped for slow subscribers.
+type eventDropCounter interface {
+	DroppedEvents() uint64
+}
+
+// eventIDUser is implemented by stores publishing events, which take
+// IDs of their events from the sequence of the server.
+type eventIDUser interface {
+	useEventIDs(seq *eventSequence)
+}
+
+func (ms *MemoryStore) useEventIDs(seq *eventSequence) {
+	ms.events.useEventIDs(seq)
+}
+
+// shareEventIDs makes the broker of order events and the store
+// take IDs of events from the sequence of the server.
+func (cs *Server) shareEventIDs() {
+	cs.orderEvents.useEventIDs(&cs.eventIDs)
+	if u, ok := storeAs[eventIDUser](cs.Store); ok {
+		u.useEventIDs(&cs.eventIDs)
+	}
+}
+
+// Subscribe returns a channel receiving product changes.
+func (ms *MemoryStore) Subscribe() (<-chan Event, func()) {
+	return ms.events.Subscribe()
+}
+
+// EventsAfter returns retained product changes
+// published after the change with the ID.
+func (ms *MemoryStore) EventsAfter(id string) ([]Event, bool) {
+	return ms.events.EventsAfter(id)
+}
+
+// DroppedEvents returns the number of product changes
+// subscribers missed because their buffers were full.
+func (ms *MemoryStore) DroppedEvents() uint64 {
+	return ms.events.Dropped()
+}
+
+// publishChange notifies subscribers that the product changed.
+// It must be called with the store lock held to preserve
+// the order of changes.
+func (ms *MemoryStore) publishChange(p Product) {
+	ms.events.Publish(ProductUpdated, p.clone())
+	if p.Stock == 0 {
+		ms.events.Publish(ProductOutOfStock, p.clone())
+	}
+}
+
+// heartbeatInterval is the interval between comments sent
+// to keep idle event streams open.
+const heartbeatInterval = 15 * time.Second
+
+// GetEvents streams product changes as Server-Sent Events. Clients
+// reconnecting with the Last-Event-ID header receive changes they
+// missed first, if the store still retains them, or an events.missed
+// event. The stream ends when the server shuts down.
+func (cs *Server) GetEvents(w http.ResponseWriter, r *http.Request) {
+	n, ok := storeAs[Notifier](cs.Store)
+	if !ok {
+		writeError(w, r, http.StatusNotImplemented, "events not supported")
+		return
+	}
+	replayer, _ := storeAs[EventReplayer](cs.Store)
+	events, unsubscribe := n.Subscribe()
+	defer unsubscribe()
+	last := r.Header.Get("Last-Event-ID")
+	var replayed []Event
+	resumed := true
+	if last != "" && replayer != nil {
+		replayed, resumed = replayer.EventsAfter(last)
+	}
+	// send writes the event, preceded by events the stream missed.
+	send := func(e Event) error {
+		missed, ok := []Event{e}, true
+		if replayer != nil {
+			missed, ok = missedEvents(replayer, last, e)
+		}
+		if !ok {
+			if err := writeServerSentEvent(w, last, EventsMissed, nil); err != nil {
+				return err
+			}
+		}
+		for _, e := range missed {
+			if err := writeServerSentEvent(w, e.ID, e.Type, e); err != nil {
+				return err
+			}
+			last = e.ID
+		}
+		return nil
+	}
+
+	rc := http.NewResponseController(w)
+	cs.extendWriteDeadline(rc)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if !resumed {
+		if err := writeServerSentEvent(w, last, EventsMissed, nil); err != nil {
+			return
+		}
+		last = ""
+	}
+	for _, e := range replayed {
+		if err := send(e); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-cs.shuttingDown.Done():
+			return
+		case <-heartbeat.C:
+			cs.extendWriteDeadline(rc)
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			cs.extendWriteDeadline(rc)
+			if err := send(e); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeServerSentEvent writes the event with the ID and the type
+// and the value encoded as JSON data. Values that can't be encoded
+// are skipped.
+func writeServerSentEvent(w http.ResponseWriter, id string, typ EventType, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", id, typ, data)
+	return err
+}
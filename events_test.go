@@ -0,0 +1,264 @@
+package coffeeshop_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qba73/coffeeshop"
+)
+
+func TestMemoryStore_PublishesStockChangesToAllSubscribers(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(2),
+	}
+	first, cancelFirst := store.Subscribe()
+	defer cancelFirst()
+	second, cancelSecond := store.Subscribe()
+	defer cancelSecond()
+
+	err := store.ReserveStock([]coffeeshop.OrderItem{{ProductID: "1", Quantity: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, events := range []<-chan coffeeshop.Event{first, second} {
+		var types []coffeeshop.EventType
+		for i := 0; i < 2; i++ {
+			select {
+			case e := <-events:
+				types = append(types, e.Type)
+			case <-time.After(time.Second):
+				t.Fatal("timeout waiting for event")
+			}
+		}
+		if types[0] != coffeeshop.ProductUpdated || types[1] != coffeeshop.ProductOutOfStock {
+			t.Errorf("want updated and out of stock events, got %v", types)
+		}
+	}
+}
+
+func TestBroker_ClosesChannelOnUnsubscribe(t *testing.T) {
+	t.Parallel()
+
+	var b coffeeshop.Broker
+	events, unsubscribe := b.Subscribe()
+	unsubscribe()
+	unsubscribe()
+	b.Publish(coffeeshop.ProductUpdated, nil)
+
+	if _, ok := <-events; ok {
+		t.Error("want closed channel")
+	}
+}
+
+func TestBroker_ReplaysEventsDroppedForSlowSubscribers(t *testing.T) {
+	t.Parallel()
+
+	var b coffeeshop.Broker
+	events, unsubscribe := b.Subscribe()
+	defer unsubscribe()
+	for i := 0; i < 100; i++ {
+		b.Publish(coffeeshop.ProductUpdated, i)
+	}
+	var last coffeeshop.Event
+	for len(events) > 0 {
+		last = <-events
+	}
+	if last.ID != "64" {
+		t.Fatalf("want 64 buffered events, got last event %q", last.ID)
+	}
+	if got := b.Dropped(); got != 36 {
+		t.Errorf("want 36 dropped events, got %d", got)
+	}
+	missed, ok := b.EventsAfter(last.ID)
+	if !ok || len(missed) != 36 || missed[0].ID != "65" || missed[35].ID != "100" {
+		t.Errorf("want events 65 to 100 replayed, got %v", missed)
+	}
+	if _, ok := b.EventsAfter("101"); ok {
+		t.Error("want no events after unknown event")
+	}
+}
+
+func TestServer_ResumesEventStreamAfterLastEventID(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(10),
+	}
+	shop := newCoffeShopTestServer(store, "0s", t)
+	for _, id := range []string{"1", "2", "3"} {
+		if _, err := store.SetStock(id, 5); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// firstEvent returns the first event sent on the stream
+	// resumed after the event with the ID.
+	firstEvent := func(lastEventID string) []string {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, shop.URL+"events", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Last-Event-ID", lastEventID)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		scanner := bufio.NewScanner(resp.Body)
+		var fields []string
+		for len(fields) < 3 && scanner.Scan() {
+			if line := scanner.Text(); line != "" && !strings.HasPrefix(line, ":") {
+				fields = append(fields, line)
+			}
+		}
+		return fields
+	}
+	if got := firstEvent("1"); len(got) < 3 || got[0] != "id: 2" || !strings.Contains(got[2], `"data":{"id":"2"`) {
+		t.Errorf("want stream resumed at change of product 2, got %v", got)
+	}
+	if got := firstEvent("100"); len(got) < 2 || got[1] != "event: events.missed" {
+		t.Errorf("want events.missed for unknown last event, got %v", got)
+	}
+}
+
+func TestServer_ReportsDroppedEventsInMetrics(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(10),
+	}
+	shop := newCoffeShopTestServer(store, "0s", t)
+	_, unsubscribe := store.Subscribe()
+	defer unsubscribe()
+	for i := 1; i <= 70; i++ {
+		if _, err := store.SetStock("1", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, metrics := getResponse(t, shop.URL+"metrics")
+	const name = `coffeeshop_events_dropped_total{source="products"} `
+	i := strings.Index(metrics, name)
+	if i < 0 || strings.HasPrefix(metrics[i+len(name):], "0\n") {
+		t.Errorf("want dropped product events in metrics, got:\n%s", metrics)
+	}
+	if !strings.Contains(metrics, `coffeeshop_events_dropped_total{source="orders"} 0`) {
+		t.Errorf("want no dropped order events in metrics, got:\n%s", metrics)
+	}
+}
+
+func TestServer_StreamsProductChangesAsServerSentEvents(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(10),
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp, err := http.Get(shop.URL + "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("want event stream content type, got %q", got)
+	}
+
+	req, err := http.NewRequest(http.MethodPut, shop.URL+"products/3/stock", strings.NewReader(`{"stock":0}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	restock, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restock.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	var fields []string
+	for len(fields) < 3 && scanner.Scan() {
+		line := scanner.Text()
+		if line != "" && !strings.HasPrefix(line, ":") {
+			fields = append(fields, line)
+		}
+	}
+	if len(fields) < 3 {
+		t.Fatalf("want event fields, got %v", fields)
+	}
+	if fields[1] != "event: product.updated" {
+		t.Errorf("want product.updated event, got %q", fields[1])
+	}
+	var e struct {
+		Type string             `json:"type"`
+		Data coffeeshop.Product `json:"data"`
+	}
+	err = json.NewDecoder(bytes.NewReader([]byte(strings.TrimPrefix(fields[2], "data: ")))).Decode(&e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Data.ID != "3" || e.Data.Stock != 0 {
+		t.Errorf("want product 3 with no stock, got product %s with stock %d", e.Data.ID, e.Data.Stock)
+	}
+}
+
+func TestServer_ShutsDownWithOpenEventStream(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp, err := http.Get(shop.URL + "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shop.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServer_KeepsEventStreamsOpenPastHandlerTimeout(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: stockedInventory(2)}
+	shop := newCoffeShopTestServer(store, "0s", t, coffeeshop.WithHandlerTimeout("50ms"))
+	for _, accept := range []string{"", "text/event-stream"} {
+		url := shop.URL + "events"
+		if accept != "" {
+			url += "/log"
+		}
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", accept)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		time.Sleep(200 * time.Millisecond)
+
+		restock := sendAs(t, "", http.MethodPut, shop.URL+"products/1/stock", `{"stock": 5}`)
+		restock.Body.Close()
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() && scanner.Text() != "event: product.updated" {
+		}
+		if scanner.Err() != nil || scanner.Text() != "event: product.updated" {
+			t.Errorf("%s: want event after handler timeout, stream ended: %v", url, scanner.Err())
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package coffeeshop
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Export is a dump of the catalog and orders.
+type Export struct {
+	ExportedAt time.Time `json:"exportedAt"`
+	Products   []Product `json:"products"`
+	Orders     []Order   `json:"orders"`
+}
+
+// export returns the current catalog and orders.
+func (cs *Server) export() Export {
+	products := cs.Store.GetAll()
+	if products == nil {
+		products = []Product{}
+	}
+	sortProducts(products)
+	orders := cs.OrderStore.GetOrders()
+	if orders == nil {
+		orders = []Order{}
+	}
+	sortOrders(orders)
+	return Export{
+		ExportedAt: cs.Clock.Now().UTC(),
+		Products:   products,
+		Orders:     orders,
+	}
+}
+
+// ExportInventory responds with the catalog and orders.
+func (cs *Server) ExportInventory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Disposition", `attachment; filename="coffeeshop-export.json"`)
+	writeJSON(w, r, http.StatusOK, cs.export())
+}
+
+// WithSnapshots configures the server to restore the MemoryStore
+// and MemoryOrderStore from the snapshot file if it exists, and
+// to write snapshots of the catalog and orders to the file at
+// the interval and on shutdown, so the state survives restarts.
+func WithSnapshots(path, interval string) Option {
+	return func(s *Server) error {
+		d, err := time.ParseDuration(interval)
+		if err != nil {
+			return err
+		}
+		if d <= 0 {
+			return errors.New("snapshot interval must be positive")
+		}
+		if path == "" {
+			return errors.New("empty snapshot path")
+		}
+		s.SnapshotPath = path
+		s.SnapshotInterval = d
+		return nil
+	}
+}
+
+// restoreSnapshot loads products and orders from the snapshot
+// file. A missing file is not an error.
+func (cs *Server) restoreSnapshot() error {
+	r, ok := storeAs[Resetter](cs.Store)
+	if !ok {
+		return fmt.Errorf("snapshots require a store able to reset products, got %T", cs.Store)
+	}
+	data, err := os.ReadFile(cs.SnapshotPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var snapshot Export
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("%s: %w", cs.SnapshotPath, err)
+	}
+	products := make(Products, len(snapshot.Products))
+	for _, p := range snapshot.Products {
+		products[p.ID] = p
+	}
+	r.ResetProducts(products)
+
+	if mos, ok := cs.OrderStore.(*MemoryOrderStore); ok {
+		orders := make(map[string]Order, len(snapshot.Orders))
+		for _, o := range snapshot.Orders {
+			orders[o.ID] = o
+		}
+		mos.mx.Lock()
+		mos.Orders = orders
+		mos.mx.Unlock()
+	}
+	return nil
+}
+
+// writeSnapshot writes the export to the snapshot file. The file
+// is replaced atomically, so a crash never leaves a partial snapshot.
+func (cs *Server) writeSnapshot() error {
+	data, err := json.MarshalIndent(cs.export(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(cs.SnapshotPath), filepath.Base(cs.SnapshotPath)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), cs.SnapshotPath)
+}
+
+// snapshotPeriodically writes snapshots at the configured
+// interval until the server shuts down.
+func (cs *Server) snapshotPeriodically() {
+	if cs.SnapshotPath == "" {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(cs.SnapshotInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-cs.shuttingDown.Done():
+				return
+			case <-ticker.C:
+				_ = cs.writeSnapshot()
+			}
+		}
+	}()
+}
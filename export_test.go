@@ -0,0 +1,137 @@
+package coffeeshop_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+func TestServer_ExportsProductsAndOrders(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(5),
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp, err := http.Post(shop.URL+"orders", "application/json", bytes.NewBufferString(`{"items":[{"productId":"1","quantity":2}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(shop.URL + "admin/inventory/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200OK, got %d", resp.StatusCode)
+	}
+	var got coffeeshop.Export
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Products) != len(inventory) {
+		t.Errorf("want %d products, got %d", len(inventory), len(got.Products))
+	}
+	if got.Products[0].ID != "1" || got.Products[0].Stock != 3 {
+		t.Errorf("want product 1 with stock 3 first, got %+v", got.Products[0])
+	}
+	if len(got.Orders) != 1 || got.Orders[0].ID != "1" {
+		t.Errorf("want order 1, got %+v", got.Orders)
+	}
+}
+
+func TestServer_RestoresStateFromSnapshotAfterRestart(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(5),
+	}
+	cs, err := coffeeshop.New(":0", store, coffeeshop.WithSnapshots(path, "1h"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.SetStock("2", 42); err != nil {
+		t.Fatal(err)
+	}
+	order, err := cs.OrderStore.CreateOrder(coffeeshop.Order{Status: coffeeshop.OrderReady})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := &coffeeshop.MemoryStore{}
+	cs, err = coffeeshop.New(":0", restored, coffeeshop.WithSnapshots(path, "1h"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := restored.GetProduct("2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Stock != 42 {
+		t.Errorf("want restored stock 42, got %d", p.Stock)
+	}
+	got, err := cs.OrderStore.GetOrder(order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(order, got) {
+		t.Error(cmp.Diff(order, got))
+	}
+}
+
+func TestServer_ResumesLifecycleOfRestoredOrders(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	cs, err := coffeeshop.New(":0", &coffeeshop.MemoryStore{Products: stockedInventory(5)}, coffeeshop.WithSnapshots(path, "1h"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, err := cs.OrderStore.CreateOrder(coffeeshop.Order{
+		Items:  []coffeeshop.OrderItem{{ProductID: "1", Quantity: 1}},
+		Status: coffeeshop.OrderPreparing,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{}, "0s", t,
+		coffeeshop.WithSnapshots(path, "1h"),
+		coffeeshop.WithOrderInterval("10ms"),
+	)
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if orderStatus(t, shop.URL, order.ID) == coffeeshop.OrderCollected {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("want restored order collected, got %s", orderStatus(t, shop.URL, order.ID))
+}
+
+func TestWithSnapshots_FailsOnInvalidInterval(t *testing.T) {
+	t.Parallel()
+
+	_, err := coffeeshop.New(":0", &coffeeshop.MemoryStore{}, coffeeshop.WithSnapshots("snapshot.json", "0s"))
+	if err == nil {
+		t.Error("want error on zero snapshot interval")
+	}
+}
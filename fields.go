@@ -0,0 +1,247 @@
+package coffeeshop
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// requestedFields returns names of JSON fields requested with the
+// 'fields' query parameter, for example "id,name,price", or nil
+// if the client didn't ask for a partial response.
+func requestedFields(r *http.Request) []string {
+	if r.URL.RawQuery == "" {
+		return nil
+	}
+	q := r.URL.Query()
+	if !q.Has("fields") {
+		return nil
+	}
+	return splitList(q.Get("fields"))
+}
+
+// partialResponse holds a value already limited to the requested
+// fields, like products keyed by ID, and names of requested fields
+// it doesn't have, so selectedFields returns them as they are
+// instead of selecting fields of the value again.
+type partialResponse struct {
+	v       any
+	unknown []string
+}
+
+// selectedFields returns the JSON representation of v limited to
+// fields requested with the 'fields' query parameter, and names of
+// requested fields unknown to objects of v. It works for any value
+// encoded as a JSON object or an array of objects. Fields are matched
+// by their JSON names and keep the order of v. If the client didn't
+// ask for a partial response, v is returned as is. writeJSON selects
+// fields of every successful response.
+func selectedFields(r *http.Request, v any) (any, []string) {
+	if p, ok := v.(partialResponse); ok {
+		return p.v, p.unknown
+	}
+	fields := requestedFields(r)
+	if len(fields) == 0 {
+		return v, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v, nil
+	}
+	var objects []jsonObject
+	var elements []json.RawMessage
+	if err := json.Unmarshal(data, &elements); err == nil {
+		objects = make([]jsonObject, 0, len(elements))
+		for _, data := range elements {
+			obj, ok := decodeObject(data)
+			if !ok {
+				return v, nil
+			}
+			objects = append(objects, obj)
+		}
+	} else {
+		obj, ok := decodeObject(data)
+		if !ok {
+			return v, nil
+		}
+		objects = append(objects, obj)
+	}
+
+	known := map[string]bool{}
+	jsonFieldNames(reflect.ValueOf(v), known)
+	for _, obj := range objects {
+		for _, m := range obj {
+			known[m.Key] = true
+		}
+	}
+	var unknown []string
+	if len(known) > 0 {
+		for _, f := range fields {
+			if !known[f] {
+				unknown = append(unknown, f)
+			}
+		}
+	}
+	for i, obj := range objects {
+		objects[i] = obj.selected(fields)
+	}
+	if elements != nil {
+		return objects, unknown
+	}
+	return objects[0], unknown
+}
+
+// writeUnknownFields responds with 400 Bad Request listing requested
+// fields the representation doesn't have. Headers describing the
+// representation are dropped.
+func writeUnknownFields(w http.ResponseWriter, r *http.Request, unknown []string) {
+	for _, h := range []string{"ETag", "Last-Modified", "Cache-Control", "Location"} {
+		w.Header().Del(h)
+	}
+	problems := make([]FieldError, 0, len(unknown))
+	for _, f := range unknown {
+		problems = append(problems, FieldError{Field: "fields", Message: fmt.Sprintf("unknown field %q", f)})
+	}
+	writeFieldErrors(w, r, "unknown fields: "+strings.Join(unknown, ", "), problems)
+}
+
+// jsonFieldNames adds JSON names of fields of structs held by v, or
+// by elements of v, to names, including fields omitted when empty.
+func jsonFieldNames(v reflect.Value, names map[string]bool) {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		seen := map[reflect.Type]bool{}
+		for i := 0; i < v.Len(); i++ {
+			e := v.Index(i)
+			if e.Kind() == reflect.Interface && !e.IsNil() {
+				e = e.Elem()
+			}
+			if !seen[e.Type()] {
+				seen[e.Type()] = true
+				jsonFieldNames(e, names)
+			}
+		}
+	case reflect.Struct:
+		structFieldNames(v.Type(), names)
+	}
+}
+
+// structFieldNames adds JSON names of fields of the struct type to
+// names. Fields of embedded structs are promoted, as encoding/json
+// promotes them.
+func structFieldNames(t reflect.Type, names map[string]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			structFieldNames(ft, names)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names[name] = true
+	}
+}
+
+// jsonObject is a JSON object keeping the order of its members.
+type jsonObject []jsonMember
+
+type jsonMember struct {
+	Key   string
+	Value json.RawMessage
+}
+
+// decodeObject decodes the JSON object, keeping the order of its
+// members. It reports false if data isn't a JSON object.
+func decodeObject(data []byte) (jsonObject, bool) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil, false
+	}
+	obj := jsonObject{}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, false
+		}
+		key, _ := t.(string)
+		var v json.RawMessage
+		if err := dec.Decode(&v); err != nil {
+			return nil, false
+		}
+		obj = append(obj, jsonMember{Key: key, Value: v})
+	}
+	return obj, true
+}
+
+// selected returns members of the object named by fields,
+// in the order of the object.
+func (o jsonObject) selected(fields []string) jsonObject {
+	wanted := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		wanted[f] = true
+	}
+	selected := jsonObject{}
+	for _, m := range o {
+		if wanted[m.Key] {
+			selected = append(selected, m)
+		}
+	}
+	return selected
+}
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(m.Key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(m.Value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// fieldsKey returns the requested fields as a canonical string,
+// sorted and without duplicates, so requests for the same fields
+// share entity tags.
+func fieldsKey(r *http.Request) string {
+	fields := requestedFields(r)
+	sort.Strings(fields)
+	unique := fields[:0]
+	for i, f := range fields {
+		if i == 0 || f != fields[i-1] {
+			unique = append(unique, f)
+		}
+	}
+	return strings.Join(unique, ",")
+}
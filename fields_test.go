@@ -0,0 +1,201 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+func TestServer_RespondsWithRequestedProductFields(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t)
+	resp, err := http.Get(shop.URL + "products?fields=id,name,price")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200, got %d", resp.StatusCode)
+	}
+	var got []map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(inventory) {
+		t.Fatalf("want %d products, got %d", len(inventory), len(got))
+	}
+	for _, p := range got {
+		_, hasID := p["id"]
+		_, hasName := p["name"]
+		_, hasPrice := p["price"]
+		if len(p) != 3 || !hasID || !hasName || !hasPrice {
+			t.Errorf("want fields id, name and price, got %v", p)
+		}
+	}
+}
+
+func TestServer_RespondsWithRequestedFieldsOfSingleProduct(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t)
+	resp, err := http.Get(shop.URL + "products/1?fields=id,name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"id": "1", "name": inventory["1"].Name}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestServer_RespondsWithRequestedOrderFields(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(5)}, "0s", t)
+	createOrder(t, shop.URL, `{"items":[{"productId":"1","quantity":1}]}`).Body.Close()
+	resp, err := http.Get(shop.URL + "orders?fields=id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got []map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || len(got[0]) != 1 || got[0]["id"] == nil {
+		t.Errorf("want one order with the id only, got %v", got)
+	}
+}
+
+func TestServer_KeepsDeclaredOrderOfRequestedFields(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t)
+	_, body := getResponse(t, shop.URL+"products/1?fields=price,name,id")
+	want := fmt.Sprintf(`{"id":"1","name":%q,"price":%q}`, inventory["1"].Name, inventory["1"].Price.String())
+	if got := strings.TrimSpace(body); got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+}
+
+func TestServer_RejectsUnknownRequestedFields(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t)
+	for _, path := range []string{"products?fields=id,bogus,nope", "products/1?fields=id,bogus,nope"} {
+		resp, err := http.Get(shop.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got coffeeshop.ErrorResponse
+		err = json.NewDecoder(resp.Body).Decode(&got)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("%s: want HTTP 400, got %d", path, resp.StatusCode)
+		}
+		if resp.Header.Get("ETag") != "" {
+			t.Errorf("%s: want no ETag of the rejected representation", path)
+		}
+		var names []string
+		for _, f := range got.Error.Fields {
+			names = append(names, f.Message)
+		}
+		want := []string{`unknown field "bogus"`, `unknown field "nope"`}
+		if !cmp.Equal(want, names) {
+			t.Errorf("%s: %s", path, cmp.Diff(want, names))
+		}
+	}
+}
+
+func TestServer_TagsPartialResponsesByRequestedFields(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t)
+	tags := map[string]string{}
+	for _, path := range []string{"products/1", "products/1?fields=id", "products/1?fields=id,name", "products/1?fields=name,id"} {
+		resp, err := http.Get(shop.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		tags[path] = resp.Header.Get("ETag")
+	}
+	if tags["products/1"] == tags["products/1?fields=id"] || tags["products/1?fields=id"] == tags["products/1?fields=id,name"] {
+		t.Errorf("want different entity tags of different fields, got %v", tags)
+	}
+	if tags["products/1?fields=id,name"] != tags["products/1?fields=name,id"] {
+		t.Errorf("want the same entity tag of the same fields, got %v", tags)
+	}
+}
+
+func TestServer_RespondsWithRequestedFieldsOfAnyResource(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t)
+	cart := doCartRequest(t, http.MethodPost, shop.URL+"carts", "")
+	doCartRequest(t, http.MethodPost, shop.URL+"carts/"+cart.ID+"/items", `{"productId":"1","quantity":2}`)
+	resp, err := http.Get(shop.URL + "carts/" + cart.ID + "?fields=total")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"total": "15.98"}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestServer_IgnoresQueryParametersEndingWithFields(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t)
+	resp, err := http.Get(shop.URL + "products/1?xfields=id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got["id"] != "1" || got["name"] != inventory["1"].Name || got["type"] == nil {
+		t.Errorf("want the whole product, got %v", got)
+	}
+}
+
+func TestServer_SelectsFieldsOfKeyedProducts(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t, coffeeshop.WithKeyedProducts())
+	resp, err := http.Get(shop.URL + "products?fields=name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got map[string]map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"name": inventory["1"].Name}
+	if len(got) != len(inventory) || !cmp.Equal(want, got["1"]) {
+		t.Errorf("want products keyed by ID with names only, got %v", got)
+	}
+}
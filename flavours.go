@@ -0,0 +1,179 @@
+package coffeeshop
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxIntensity is the intensity of the strongest products.
+const maxIntensity = 10
+
+// intensityRatio matches intensities like "Medium (6/10)".
+var intensityRatio = regexp.MustCompile(`(\d+)\s*/\s*10\b`)
+
+// parseIntensity parses intensities like "Medium (6/10)" or "6".
+func parseIntensity(s string) (int, bool) {
+	s = strings.TrimSpace(s)
+	if m := intensityRatio.FindStringSubmatch(s); m != nil {
+		s = m[1]
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > maxIntensity {
+		return 0, false
+	}
+	return n, true
+}
+
+// PromoteProperties returns the product with the intensity and
+// flavours held in its "intensity" and "flavour" properties, as in
+// earlier versions of the shop, moved to the Intensity and Flavours
+// fields. Fields which are already set win over the properties.
+// Intensity properties which can't be parsed are left as they are,
+// and empty ones are dropped.
+func PromoteProperties(p Product) Product {
+	if len(p.Properties) == 0 {
+		return p
+	}
+	var props []Property
+	for _, prop := range p.Properties {
+		switch strings.ToLower(strings.TrimSpace(prop.Name)) {
+		case "intensity":
+			if strings.TrimSpace(prop.Value) == "" {
+				continue
+			}
+			if n, ok := parseIntensity(prop.Value); ok {
+				if p.Intensity == 0 {
+					p.Intensity = n
+				}
+				continue
+			}
+		case "flavour", "flavours":
+			if len(p.Flavours) == 0 {
+				p.Flavours = splitList(prop.Value)
+			}
+			continue
+		}
+		props = append(props, prop)
+	}
+	p.Properties = props
+	return p
+}
+
+// hasFlavour reports whether the product has the flavour,
+// compared case-insensitively.
+func (p Product) hasFlavour(flavour string) bool {
+	for _, f := range p.Flavours {
+		if strings.EqualFold(f, flavour) {
+			return true
+		}
+	}
+	return false
+}
+
+// flavourFilter selects products by flavours and intensity.
+type flavourFilter struct {
+	flavours     []string
+	minIntensity int
+	maxIntensity int
+}
+
+// requestedFlavours returns the filter of products requested with the
+// 'flavour', 'min_intensity' and 'max_intensity' query parameters, for
+// example "?flavour=caramel&min_intensity=7". Products must have all
+// requested flavours, given as repeated parameters or separated by
+// commas.
+func requestedFlavours(r *http.Request) (flavourFilter, error) {
+	q := r.URL.Query()
+	f := flavourFilter{maxIntensity: maxIntensity}
+	for _, v := range q["flavour"] {
+		f.flavours = append(f.flavours, splitList(v)...)
+	}
+	for name, dst := range map[string]*int{"min_intensity": &f.minIntensity, "max_intensity": &f.maxIntensity} {
+		v := q.Get(name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxIntensity {
+			return flavourFilter{}, fmt.Errorf("invalid %s %q, want 1 to %d", name, v, maxIntensity)
+		}
+		*dst = n
+	}
+	return f, nil
+}
+
+// active reports whether the filter selects products.
+func (f flavourFilter) active() bool {
+	return len(f.flavours) > 0 || f.minIntensity > 0 || f.maxIntensity < maxIntensity
+}
+
+// matches reports whether the product passes the filter. Products
+// with unknown intensity don't pass filters of intensity. Products
+// are matched by their promoted properties, so products stored
+// before the migration can be filtered too.
+func (f flavourFilter) matches(p Product) bool {
+	p = PromoteProperties(p)
+	for _, flavour := range f.flavours {
+		if !p.hasFlavour(flavour) {
+			return false
+		}
+	}
+	if f.minIntensity > 0 || f.maxIntensity < maxIntensity {
+		return p.Intensity >= f.minIntensity && p.Intensity <= f.maxIntensity && p.Intensity > 0
+	}
+	return true
+}
+
+// filtered filters the products requested by flavours
+// and intensity in place.
+func filtered(r *http.Request, px []Product) ([]Product, error) {
+	f, err := requestedFlavours(r)
+	if err != nil || !f.active() {
+		return px, err
+	}
+	matched := px[:0]
+	for _, p := range px {
+		if f.matches(p) {
+			matched = append(matched, p)
+		}
+	}
+	return matched, nil
+}
+
+// migrationReport counts products migrated by MigrateProducts.
+type migrationReport struct {
+	Migrated int `json:"migrated"`
+}
+
+// MigrateProducts moves intensities and flavours of all products in
+// the store from their properties to the Intensity and Flavours fields
+// with PromoteProperties, and responds with the number of migrated
+// products.
+func (cs *Server) MigrateProducts(w http.ResponseWriter, r *http.Request) {
+	importer, ok := storeAs[Importer](cs.store(r))
+	if !ok {
+		writeError(w, r, http.StatusNotImplemented, "store doesn't support imports")
+		return
+	}
+	px, err := listAll(cs.store(r))
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	var report migrationReport
+	for _, p := range px {
+		promoted := PromoteProperties(p)
+		if len(promoted.Properties) == len(p.Properties) {
+			continue
+		}
+		if _, err := importer.PutProduct(promoted); err != nil {
+			writeStoreError(w, r, err)
+			return
+		}
+		report.Migrated++
+	}
+	writeJSON(w, r, http.StatusOK, report)
+}
@@ -0,0 +1,152 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+// flavouredProducts hold flavours and intensities in fields
+// and, like products stored before the migration, in properties.
+func flavouredProducts() coffeeshop.Products {
+	return coffeeshop.Products{
+		"1": {ID: "1", Type: "Coffee", Brand: "Segafredo", Name: "Intermezzo", Intensity: 7, Flavours: []string{"Caramel", "Nuts"}},
+		"2": {ID: "2", Type: "Coffee", Brand: "illy", Name: "Classico", Properties: []coffeeshop.Property{
+			{Name: "flavour", Value: "Honey, Caramel"},
+			{Name: "intensity", Value: "Medium (6/10)"},
+		}},
+		"3": {ID: "3", Type: "Coffee", Brand: "Lavazza", Name: "Crema", Properties: []coffeeshop.Property{
+			{Name: "flavour", Value: "Caramel, Chocolate"},
+			{Name: "intensity", Value: "Very strong (9/10)"},
+		}},
+		"4": {ID: "4", Type: "Coffee", Brand: "Lavazza", Name: "Oro", Flavours: []string{"Caramel"}},
+		"5": {ID: "5", Type: "Tea", Brand: "Caykur", Name: "Green Tea", Intensity: 2},
+	}
+}
+
+func TestPromoteProperties_MovesIntensityAndFlavoursToFields(t *testing.T) {
+	t.Parallel()
+
+	p := coffeeshop.Product{ID: "1", Properties: []coffeeshop.Property{
+		{Name: "origin", Value: "Brazil"},
+		{Name: "flavour", Value: "Dark Chocolate, Acidic Robusta,"},
+		{Name: "intensity", Value: "Very strong (9/10)"},
+	}}
+	want := coffeeshop.Product{
+		ID:         "1",
+		Properties: []coffeeshop.Property{{Name: "origin", Value: "Brazil"}},
+		Intensity:  9,
+		Flavours:   []string{"Dark Chocolate", "Acidic Robusta"},
+	}
+	got := coffeeshop.PromoteProperties(p)
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestPromoteProperties_KeepsFieldsAndUnparseableIntensities(t *testing.T) {
+	t.Parallel()
+
+	p := coffeeshop.Product{ID: "1", Intensity: 5, Flavours: []string{"Nuts"}, Properties: []coffeeshop.Property{
+		{Name: "flavour", Value: "Caramel"},
+		{Name: "intensity", Value: "strong"},
+		{Name: "intensity", Value: ""},
+	}}
+	want := coffeeshop.Product{
+		ID:         "1",
+		Properties: []coffeeshop.Property{{Name: "intensity", Value: "strong"}},
+		Intensity:  5,
+		Flavours:   []string{"Nuts"},
+	}
+	got := coffeeshop.PromoteProperties(p)
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestServer_FiltersProductsByFlavourAndIntensity(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: flavouredProducts()}, "0s", t)
+	tests := map[string][]string{
+		"products?flavour=caramel":                 {"1", "2", "3", "4"},
+		"products?flavour=CARAMEL,nuts":            {"1"},
+		"products?flavour=caramel&flavour=honey":   {"2"},
+		"products?flavour=caramel&min_intensity=7": {"1", "3"},
+		"products?max_intensity=6":                 {"2", "5"},
+		"products?min_intensity=6&max_intensity=7": {"1", "2"},
+		"products/coffee?min_intensity=8":          {"3"},
+		"products?flavour=vanilla":                 {},
+	}
+	for query, want := range tests {
+		got := listedIDs(t, shop.URL+query)
+		if got == nil {
+			got = []string{}
+		}
+		if !cmp.Equal(want, got) {
+			t.Errorf("%s: %s", query, cmp.Diff(want, got))
+		}
+	}
+}
+
+func TestServer_RejectsInvalidIntensities(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: flavouredProducts()}, "0s", t)
+	for _, query := range []string{"min_intensity=0", "max_intensity=11", "min_intensity=strong"} {
+		status, _ := getResponse(t, shop.URL+"products?"+query)
+		if status != http.StatusBadRequest {
+			t.Errorf("%s: want HTTP 400, got %d", query, status)
+		}
+	}
+}
+
+func TestServer_MigratesProductProperties(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: flavouredProducts()}
+	shop := newCoffeShopTestServer(store, "0s", t)
+	resp, err := http.Post(shop.URL+"admin/products/migrate", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200, got %d", resp.StatusCode)
+	}
+	var report struct{ Migrated int }
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Migrated != 2 {
+		t.Errorf("want 2 migrated products, got %d", report.Migrated)
+	}
+	p, err := store.GetProduct("3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Intensity != 9 || !cmp.Equal([]string{"Caramel", "Chocolate"}, p.Flavours) || len(p.Properties) != 0 {
+		t.Errorf("want product with intensity and flavours in fields, got %+v", p)
+	}
+}
+
+func TestProduct_ValidatesIntensityAndFlavours(t *testing.T) {
+	t.Parallel()
+
+	p := coffeeshop.Product{ID: "1", Type: "Coffee", Name: "Intenso", Intensity: 11, Flavours: []string{"Cocoa", " "}}
+	var verr *coffeeshop.ValidationError
+	if !errors.As(p.Validate(), &verr) {
+		t.Fatalf("want validation error, got %v", p.Validate())
+	}
+	var fields []string
+	for _, f := range verr.Fields {
+		fields = append(fields, f.Field)
+	}
+	if want := []string{"intensity", "flavours[1]"}; !cmp.Equal(want, fields) {
+		t.Error(cmp.Diff(want, fields))
+	}
+}
@@ -0,0 +1,932 @@
+package coffeeshop
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// GraphQLSchema describes the GraphQL API served at /graphql.
+//
+// The server implements a subset of GraphQL: operations with
+// variables, fields, aliases and arguments. Fragments, directives
+// and subscriptions are not supported.
+const GraphQLSchema = `type Query {
+  products(type: String, currency: String): [Product!]!
+  product(id: ID!, currency: String): Product
+  categories: [Category!]!
+  orders: [Order!]!
+  order(id: ID!): Order
+}
+
+type Mutation {
+  createOrder(items: [OrderItemInput!]!): Order
+  restockProduct(id: ID!, stock: Int!): Product
+}
+
+type Product {
+  id: ID!
+  type: String!
+  brand: String!
+  name: String!
+  sku: String
+  ean: String
+  unit: String
+  quantity: Int
+  price: String!
+  stock: Int!
+  properties: [Property!]
+  intensity: Int
+  flavours: [String!]
+  createdAt: String
+  updatedAt: String
+}
+
+type Property {
+  name: String!
+  value: String!
+}
+
+type Category {
+  name: String!
+  products: Int!
+}
+
+type Order {
+  id: ID!
+  items: [OrderItem!]!
+  status: String!
+  createdAt: String!
+  updatedAt: String!
+}
+
+type OrderItem {
+  productId: ID!
+  quantity: Int!
+}
+
+input OrderItemInput {
+  productId: ID!
+  quantity: Int!
+}
+`
+
+// graphqlTypes maps object types of the GraphQL schema
+// to types of their fields.
+var graphqlTypes = map[string]map[string]string{
+	"Query": {
+		"products":   "Product",
+		"product":    "Product",
+		"categories": "Category",
+		"orders":     "Order",
+		"order":      "Order",
+	},
+	"Mutation": {
+		"createOrder":    "Order",
+		"restockProduct": "Product",
+	},
+	"Product": {
+		"id":         "ID",
+		"type":       "String",
+		"brand":      "String",
+		"name":       "String",
+		"sku":        "String",
+		"ean":        "String",
+		"unit":       "String",
+		"quantity":   "Int",
+		"price":      "String",
+		"stock":      "Int",
+		"properties": "Property",
+		"intensity":  "Int",
+		"flavours":   "String",
+		"createdAt":  "String",
+		"updatedAt":  "String",
+	},
+	"Property": {
+		"name":  "String",
+		"value": "String",
+	},
+	"Category": {
+		"name":     "String",
+		"products": "Int",
+	},
+	"Order": {
+		"id":        "ID",
+		"items":     "OrderItem",
+		"status":    "String",
+		"createdAt": "String",
+		"updatedAt": "String",
+	},
+	"OrderItem": {
+		"productId": "ID",
+		"quantity":  "Int",
+	},
+}
+
+// graphQLRequest represents a GraphQL request sent over HTTP.
+type graphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+type graphQLError struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+type graphQLResponse struct {
+	Data   any            `json:"data,omitempty"`
+	Errors []graphQLError `json:"errors,omitempty"`
+}
+
+// gqlObject is a JSON object preserving the order
+// of fields in the selection set.
+type gqlObject []gqlMember
+
+type gqlMember struct {
+	Key   string
+	Value any
+}
+
+func (o gqlObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(m.Key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(m.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// GraphQL serves GraphQL requests sent in the JSON body of POST
+// requests or in the query string of GET requests. Mutations
+// are accepted only in POST requests.
+func (cs *Server) GraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphQLRequest
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				writeGraphQLError(w, r, http.StatusBadRequest, "invalid variables")
+				return
+			}
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeGraphQLError(w, r, http.StatusBadRequest, "invalid request")
+		return
+	}
+
+	ops, err := parseGraphQL(req.Query)
+	if err != nil {
+		writeGraphQLError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	op, err := selectOperation(ops, req.OperationName)
+	if err != nil {
+		writeGraphQLError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if op.kind == "mutation" && r.Method == http.MethodGet {
+		w.Header().Set("Allow", http.MethodPost)
+		writeGraphQLError(w, r, http.StatusMethodNotAllowed, "mutations are not allowed in GET requests")
+		return
+	}
+	root := "Query"
+	if op.kind == "mutation" {
+		root = "Mutation"
+	}
+	if err := validateSelections(root, op.selections); err != nil {
+		writeGraphQLError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	vars := make(map[string]any, len(op.defaults)+len(req.Variables))
+	for name, v := range op.defaults {
+		vars[name] = v
+	}
+	for name, v := range req.Variables {
+		vars[name] = v
+	}
+	customerID, ok := cs.customerID(w, r)
+	if !ok {
+		return
+	}
+	data, errs := cs.executeGraphQL(cs.store(r), root, op.selections, vars, customerID)
+	writeJSON(w, r, http.StatusOK, graphQLResponse{Data: data, Errors: errs})
+}
+
+// GetGraphQLSchema returns the GraphQL schema in SDL.
+func (cs *Server) GetGraphQLSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(GraphQLSchema))
+}
+
+func writeGraphQLError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	writeJSON(w, r, status, graphQLResponse{Errors: []graphQLError{{Message: msg}}})
+}
+
+// executeGraphQL resolves root fields of the operation with products
+// of the store for the customer, or anonymously if the customer ID is
+// empty. Errors
+// returned by resolvers are reported along with the path of the
+// field, which is set to null in the result.
+func (cs *Server) executeGraphQL(s Store, root string, selections []gqlSelection, vars map[string]any, customerID string) (gqlObject, []graphQLError) {
+	data := gqlObject{}
+	var errs []graphQLError
+	for _, sel := range selections {
+		key := sel.key()
+		if sel.name == "__typename" {
+			data = append(data, gqlMember{Key: key, Value: root})
+			continue
+		}
+		v, err := cs.resolveGraphQL(s, sel.name, resolveArgs(sel.args, vars), customerID)
+		if err != nil {
+			errs = append(errs, graphQLError{Message: err.Error(), Path: []any{key}})
+			data = append(data, gqlMember{Key: key})
+			continue
+		}
+		generic, err := toGeneric(v)
+		if err != nil {
+			errs = append(errs, graphQLError{Message: err.Error(), Path: []any{key}})
+			data = append(data, gqlMember{Key: key})
+			continue
+		}
+		data = append(data, gqlMember{Key: key, Value: project(generic, graphqlTypes[root][sel.name], sel.selections)})
+	}
+	return data, errs
+}
+
+// resolveGraphQL returns the value of the root field
+// for the customer, reading products from the store.
+func (cs *Server) resolveGraphQL(s Store, field string, args map[string]any, customerID string) (any, error) {
+	switch field {
+	case "products":
+		var px []Product
+		var err error
+		if t, ok := args["type"].(string); ok && t != "" {
+			px, err = listByType(s, t)
+		} else {
+			px, err = listAll(s)
+		}
+		if err != nil {
+			return nil, err
+		}
+		px = withoutArchived(px)
+		sortProducts(px)
+		return cs.graphQLPrices(px, args)
+	case "product":
+		id, err := stringArg(args, "id")
+		if err != nil {
+			return nil, err
+		}
+		p, err := s.GetProduct(id)
+		if err != nil {
+			return nil, err
+		}
+		px, err := cs.graphQLPrices([]Product{p}, args)
+		if err != nil {
+			return nil, err
+		}
+		return px[0], nil
+	case "categories":
+		px, err := listAll(s)
+		if err != nil {
+			return nil, err
+		}
+		return categories(withoutArchived(px)), nil
+	case "orders":
+		orders := cs.customerOrders(customerID)
+		if orders == nil {
+			orders = []Order{}
+		}
+		sortOrders(orders)
+		return orders, nil
+	case "order":
+		id, err := stringArg(args, "id")
+		if err != nil {
+			return nil, err
+		}
+		o, err := cs.customerOrder(id, customerID)
+		if err != nil {
+			return nil, err
+		}
+		return o, nil
+	case "createOrder":
+		var items []OrderItem
+		if err := decodeArg(args, "items", &items); err != nil || len(items) == 0 {
+			return nil, errors.New("invalid order")
+		}
+		for _, item := range items {
+			if item.Quantity <= 0 {
+				return nil, errors.New("invalid order")
+			}
+			if _, err := orderableProduct(s, item.ProductID); err != nil {
+				return nil, err
+			}
+		}
+		return cs.placeOrder(s, Order{CustomerID: customerID, Items: items})
+	case "restockProduct":
+		id, err := stringArg(args, "id")
+		if err != nil {
+			return nil, err
+		}
+		var stock int
+		if err := decodeArg(args, "stock", &stock); err != nil || stock < 0 {
+			return nil, errors.New("invalid stock")
+		}
+		return s.SetStock(id, stock)
+	}
+	return nil, fmt.Errorf("unknown field %q", field)
+}
+
+// graphQLPrices converts product prices to the currency
+// given in the 'currency' argument.
+func (cs *Server) graphQLPrices(px []Product, args map[string]any) ([]Product, error) {
+	currency, _ := args["currency"].(string)
+	if currency == "" {
+		return px, nil
+	}
+	for i := range px {
+		price, err := px[i].Price.Convert(strings.ToUpper(currency), cs.Rates)
+		if err != nil {
+			return nil, errors.New("unsupported currency")
+		}
+		px[i].Price = price
+	}
+	return px, nil
+}
+
+func stringArg(args map[string]any, name string) (string, error) {
+	s, ok := args[name].(string)
+	if !ok || s == "" {
+		return "", fmt.Errorf("argument %q is required", name)
+	}
+	return s, nil
+}
+
+// decodeArg decodes the argument value into out.
+func decodeArg(args map[string]any, name string, out any) error {
+	v, ok := args[name]
+	if !ok {
+		return fmt.Errorf("argument %q is required", name)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// toGeneric returns the JSON representation of v
+// as maps, slices and scalar values.
+func toGeneric(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var generic any
+	err = dec.Decode(&generic)
+	return generic, err
+}
+
+// project returns fields of the value selected in the selection set.
+func project(v any, typ string, selections []gqlSelection) any {
+	switch x := v.(type) {
+	case []any:
+		out := make([]any, len(x))
+		for i, e := range x {
+			out[i] = project(e, typ, selections)
+		}
+		return out
+	case map[string]any:
+		obj := make(gqlObject, 0, len(selections))
+		for _, sel := range selections {
+			if sel.name == "__typename" {
+				obj = append(obj, gqlMember{Key: sel.key(), Value: typ})
+				continue
+			}
+			obj = append(obj, gqlMember{Key: sel.key(), Value: project(x[sel.name], graphqlTypes[typ][sel.name], sel.selections)})
+		}
+		return obj
+	default:
+		return v
+	}
+}
+
+// validateSelections checks that the selected fields
+// exist in the schema.
+func validateSelections(typ string, selections []gqlSelection) error {
+	for _, sel := range selections {
+		if sel.name == "__typename" {
+			if len(sel.selections) > 0 {
+				return errors.New(`field "__typename" must not have a selection`)
+			}
+			continue
+		}
+		fieldType, ok := graphqlTypes[typ][sel.name]
+		if !ok {
+			return fmt.Errorf("cannot query field %q on type %q", sel.name, typ)
+		}
+		_, object := graphqlTypes[fieldType]
+		switch {
+		case object && len(sel.selections) == 0:
+			return fmt.Errorf("field %q of type %q must have a selection of subfields", sel.name, fieldType)
+		case !object && len(sel.selections) > 0:
+			return fmt.Errorf("field %q of type %q must not have a selection", sel.name, fieldType)
+		}
+		if err := validateSelections(fieldType, sel.selections); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gqlVariable refers to a variable of the operation.
+type gqlVariable string
+
+// resolveArgs replaces variables in the arguments with their values.
+func resolveArgs(args map[string]any, vars map[string]any) map[string]any {
+	resolved := make(map[string]any, len(args))
+	for name, v := range args {
+		resolved[name] = resolveValue(v, vars)
+	}
+	return resolved
+}
+
+func resolveValue(v any, vars map[string]any) any {
+	switch x := v.(type) {
+	case gqlVariable:
+		return vars[string(x)]
+	case []any:
+		out := make([]any, len(x))
+		for i, e := range x {
+			out[i] = resolveValue(e, vars)
+		}
+		return out
+	case map[string]any:
+		return resolveArgs(x, vars)
+	default:
+		return v
+	}
+}
+
+// gqlOperation represents a parsed query or mutation.
+type gqlOperation struct {
+	kind       string
+	name       string
+	defaults   map[string]any
+	selections []gqlSelection
+}
+
+// gqlSelection represents a field in a selection set.
+type gqlSelection struct {
+	alias      string
+	name       string
+	args       map[string]any
+	selections []gqlSelection
+}
+
+func (s gqlSelection) key() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+func selectOperation(ops []gqlOperation, name string) (gqlOperation, error) {
+	if name == "" {
+		if len(ops) != 1 {
+			return gqlOperation{}, errors.New("operation name is required")
+		}
+		return ops[0], nil
+	}
+	for _, op := range ops {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return gqlOperation{}, fmt.Errorf("unknown operation %q", name)
+}
+
+// Kinds of GraphQL tokens.
+const (
+	tokEOF = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type gqlToken struct {
+	kind int
+	val  string
+}
+
+// lexGraphQL splits the GraphQL document into tokens.
+func lexGraphQL(src string) ([]gqlToken, error) {
+	var toks []gqlToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.IndexByte("!$():=@[]{}|", c) >= 0:
+			toks = append(toks, gqlToken{kind: tokPunct, val: string(c)})
+			i++
+		case strings.HasPrefix(src[i:], "..."):
+			toks = append(toks, gqlToken{kind: tokPunct, val: "..."})
+			i += 3
+		case c == '_' || isLetter(c):
+			start := i
+			for i < len(src) && (src[i] == '_' || isLetter(src[i]) || isDigit(src[i])) {
+				i++
+			}
+			toks = append(toks, gqlToken{kind: tokName, val: src[start:i]})
+		case c == '-' || isDigit(c):
+			start := i
+			kind := tokInt
+			if c == '-' {
+				i++
+			}
+			for i < len(src) && isDigit(src[i]) {
+				i++
+			}
+			if i < len(src) && src[i] == '.' {
+				kind = tokFloat
+				i++
+				for i < len(src) && isDigit(src[i]) {
+					i++
+				}
+			}
+			if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+				kind = tokFloat
+				i++
+				if i < len(src) && (src[i] == '+' || src[i] == '-') {
+					i++
+				}
+				for i < len(src) && isDigit(src[i]) {
+					i++
+				}
+			}
+			toks = append(toks, gqlToken{kind: kind, val: src[start:i]})
+		case strings.HasPrefix(src[i:], `"""`):
+			end := strings.Index(src[i+3:], `"""`)
+			if end < 0 {
+				return nil, errors.New("unterminated string")
+			}
+			toks = append(toks, gqlToken{kind: tokString, val: strings.TrimSpace(src[i+3 : i+3+end])})
+			i += end + 6
+		case c == '"':
+			end := i + 1
+			for end < len(src) && src[end] != '"' {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, errors.New("unterminated string")
+			}
+			var s string
+			if err := json.Unmarshal([]byte(src[i:end+1]), &s); err != nil {
+				return nil, fmt.Errorf("invalid string %s", src[i:end+1])
+			}
+			toks = append(toks, gqlToken{kind: tokString, val: s})
+			i = end + 1
+		default:
+			return nil, fmt.Errorf("unexpected character %q", c)
+		}
+	}
+	return toks, nil
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+type gqlParser struct {
+	toks []gqlToken
+	pos  int
+}
+
+// parseGraphQL parses operations of the GraphQL document.
+func parseGraphQL(query string) ([]gqlOperation, error) {
+	toks, err := lexGraphQL(query)
+	if err != nil {
+		return nil, err
+	}
+	p := gqlParser{toks: toks}
+	var ops []gqlOperation
+	for p.peek().kind != tokEOF {
+		op, err := p.operation()
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	if len(ops) == 0 {
+		return nil, errors.New("missing query")
+	}
+	return ops, nil
+}
+
+func (p *gqlParser) peek() gqlToken {
+	if p.pos >= len(p.toks) {
+		return gqlToken{kind: tokEOF}
+	}
+	return p.toks[p.pos]
+}
+
+func (p *gqlParser) next() gqlToken {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *gqlParser) peekPunct(val string) bool {
+	t := p.peek()
+	return t.kind == tokPunct && t.val == val
+}
+
+func (p *gqlParser) expect(val string) error {
+	t := p.next()
+	if t.kind != tokPunct || t.val != val {
+		return fmt.Errorf("expected %q, got %q", val, t.val)
+	}
+	return nil
+}
+
+func (p *gqlParser) name() (string, error) {
+	t := p.next()
+	if t.kind != tokName {
+		return "", fmt.Errorf("expected name, got %q", t.val)
+	}
+	return t.val, nil
+}
+
+func (p *gqlParser) operation() (gqlOperation, error) {
+	op := gqlOperation{kind: "query"}
+	if !p.peekPunct("{") {
+		kind, err := p.name()
+		if err != nil {
+			return op, err
+		}
+		switch kind {
+		case "query", "mutation":
+			op.kind = kind
+		case "subscription":
+			return op, errors.New("subscriptions are not supported")
+		case "fragment":
+			return op, errors.New("fragments are not supported")
+		default:
+			return op, fmt.Errorf("unexpected %q", kind)
+		}
+		if p.peek().kind == tokName {
+			op.name = p.next().val
+		}
+		if p.peekPunct("(") {
+			defaults, err := p.variableDefinitions()
+			if err != nil {
+				return op, err
+			}
+			op.defaults = defaults
+		}
+	}
+	selections, err := p.selectionSet()
+	if err != nil {
+		return op, err
+	}
+	op.selections = selections
+	return op, nil
+}
+
+// variableDefinitions parses variable definitions and returns
+// default values of the variables. Variable types are not checked.
+func (p *gqlParser) variableDefinitions() (map[string]any, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	defaults := map[string]any{}
+	for !p.peekPunct(")") {
+		if err := p.expect("$"); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if err := p.typeRef(); err != nil {
+			return nil, err
+		}
+		if p.peekPunct("=") {
+			p.next()
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			defaults[name] = v
+		}
+	}
+	p.next()
+	return defaults, nil
+}
+
+func (p *gqlParser) typeRef() error {
+	if p.peekPunct("[") {
+		p.next()
+		if err := p.typeRef(); err != nil {
+			return err
+		}
+		if err := p.expect("]"); err != nil {
+			return err
+		}
+	} else if _, err := p.name(); err != nil {
+		return err
+	}
+	if p.peekPunct("!") {
+		p.next()
+	}
+	return nil
+}
+
+func (p *gqlParser) selectionSet() ([]gqlSelection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var selections []gqlSelection
+	for !p.peekPunct("}") {
+		if p.peek().kind == tokEOF {
+			return nil, errors.New(`expected "}"`)
+		}
+		if p.peekPunct("...") {
+			return nil, errors.New("fragments are not supported")
+		}
+		sel, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, sel)
+	}
+	p.next()
+	if len(selections) == 0 {
+		return nil, errors.New("empty selection set")
+	}
+	return selections, nil
+}
+
+func (p *gqlParser) selection() (gqlSelection, error) {
+	var sel gqlSelection
+	name, err := p.name()
+	if err != nil {
+		return sel, err
+	}
+	sel.name = name
+	if p.peekPunct(":") {
+		p.next()
+		sel.alias = name
+		if sel.name, err = p.name(); err != nil {
+			return sel, err
+		}
+	}
+	if p.peekPunct("(") {
+		if sel.args, err = p.arguments(); err != nil {
+			return sel, err
+		}
+	}
+	if p.peekPunct("@") {
+		return sel, errors.New("directives are not supported")
+	}
+	if p.peekPunct("{") {
+		if sel.selections, err = p.selectionSet(); err != nil {
+			return sel, err
+		}
+	}
+	return sel, nil
+}
+
+func (p *gqlParser) arguments() (map[string]any, error) {
+	p.next()
+	args := map[string]any{}
+	for !p.peekPunct(")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		args[name] = v
+	}
+	p.next()
+	return args, nil
+}
+
+func (p *gqlParser) value() (any, error) {
+	t := p.next()
+	switch t.kind {
+	case tokInt:
+		n, err := strconv.ParseInt(t.val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid int %s", t.val)
+		}
+		return n, nil
+	case tokFloat:
+		f, err := strconv.ParseFloat(t.val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float %s", t.val)
+		}
+		return f, nil
+	case tokString:
+		return t.val, nil
+	case tokName:
+		switch t.val {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return t.val, nil
+	case tokPunct:
+		switch t.val {
+		case "$":
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			return gqlVariable(name), nil
+		case "[":
+			list := []any{}
+			for !p.peekPunct("]") {
+				if p.peek().kind == tokEOF {
+					return nil, errors.New(`expected "]"`)
+				}
+				v, err := p.value()
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			p.next()
+			return list, nil
+		case "{":
+			obj := map[string]any{}
+			for !p.peekPunct("}") {
+				name, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+				v, err := p.value()
+				if err != nil {
+					return nil, err
+				}
+				obj[name] = v
+			}
+			p.next()
+			return obj, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %q", t.val)
+}
@@ -0,0 +1,174 @@
+package coffeeshop_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+func postGraphQL(t *testing.T, shopURL, query string, variables map[string]any) (int, []byte) {
+	t.Helper()
+	body, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(shopURL+"graphql", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, buf.Bytes()
+}
+
+func TestGraphQL_ReturnsSelectedProductFields(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	status, body := postGraphQL(t, shop.URL, `query Product($id: ID!) {
+		product(id: $id) { id name cost: price }
+	}`, map[string]any{"id": "4"})
+
+	if status != http.StatusOK {
+		t.Fatalf("want status 200, got %d: %s", status, body)
+	}
+	want := `{"data":{"product":{"id":"4","name":"Intenso","cost":"7.99"}}}`
+	if got := string(bytes.TrimSpace(body)); got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+}
+
+func TestGraphQL_ReturnsProductsOfType(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	q := url.Values{"query": {`{ products(type: "tea") { id properties { name } } }`}}
+	resp, err := http.Get(shop.URL + "graphql?" + q.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var got struct {
+		Data struct {
+			Products []map[string]any `json:"products"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]bool{}
+	for _, p := range got.Data.Products {
+		ids[p["id"].(string)] = true
+	}
+	want := map[string]bool{"7": true, "8": true}
+	if !cmp.Equal(want, ids) {
+		t.Error(cmp.Diff(want, ids))
+	}
+}
+
+func TestGraphQL_CreatesOrderWithMutation(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(10),
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	status, body := postGraphQL(t, shop.URL, `mutation {
+		createOrder(items: [{productId: "1", quantity: 2}]) { id status items { productId quantity } }
+	}`, nil)
+
+	if status != http.StatusOK {
+		t.Fatalf("want status 200, got %d: %s", status, body)
+	}
+	want := `{"data":{"createOrder":{"id":"1","status":"received","items":[{"productId":"1","quantity":2}]}}}`
+	if got := string(bytes.TrimSpace(body)); got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+	p, err := store.GetProduct("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Stock != 8 {
+		t.Errorf("want stock 8, got %d", p.Stock)
+	}
+}
+
+func TestGraphQL_ReportsResolverErrorWithPath(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	status, body := postGraphQL(t, shop.URL, `{ product(id: "42") { id } }`, nil)
+
+	if status != http.StatusOK {
+		t.Fatalf("want status 200, got %d", status)
+	}
+	want := `{"data":{"product":null},"errors":[{"message":"product not found","path":["product"]}]}`
+	if got := string(bytes.TrimSpace(body)); got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+}
+
+func TestGraphQL_RejectsInvalidQueries(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+	shop := newCoffeShopTestServer(store, "10ms", t)
+
+	tests := []string{
+		`{ product(id: "1") { id`,
+		`{ product(id: "1") { colour } }`,
+		`{ product(id: "1") }`,
+		`{ categories { name { first } } }`,
+		`{ ...Fields }`,
+	}
+	for _, query := range tests {
+		status, body := postGraphQL(t, shop.URL, query, nil)
+		if status != http.StatusBadRequest {
+			t.Errorf("%s: want status 400, got %d: %s", query, status, body)
+		}
+	}
+}
+
+func TestGraphQL_RejectsMutationInGetRequest(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(10),
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	q := url.Values{"query": {`mutation { restockProduct(id: "1", stock: 0) { id } }`}}
+	resp, err := http.Get(shop.URL + "graphql?" + q.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("want status 405, got %d", resp.StatusCode)
+	}
+}
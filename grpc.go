@@ -0,0 +1,321 @@
+package coffeeshop
+
+//go:generate protoc -I proto --go_out=proto --go_opt=paths=source_relative --go-grpc_out=proto --go-grpc_opt=paths=source_relative coffeeshop/v1/coffeeshop.proto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	coffeeshopv1 "github.com/qba73/coffeeshop/proto/coffeeshop/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// WithGRPC serves the catalog and orders over gRPC on the address,
+// for example ":9090", alongside the HTTP API. The service is defined
+// in proto/coffeeshop/v1/coffeeshop.proto, and reflection is enabled,
+// so clients like grpcurl can discover it. ListenAndServe starts the
+// gRPC server and Shutdown stops it.
+func WithGRPC(addr string) Option {
+	return func(s *Server) error {
+		if addr == "" {
+			return errors.New("empty gRPC address")
+		}
+		s.GRPCAddr = addr
+		return nil
+	}
+}
+
+// WithGRPCListener serves gRPC on the listener
+// instead of binding the address given to WithGRPC.
+func WithGRPCListener(l net.Listener) Option {
+	return func(s *Server) error {
+		if l == nil {
+			return errors.New("nil gRPC listener")
+		}
+		s.grpcListener = l
+		s.GRPCAddr = listenerAddr(l)
+		return nil
+	}
+}
+
+// serveGRPC starts serving gRPC in the background,
+// if it's enabled.
+func (cs *Server) serveGRPC() error {
+	if cs.GRPCAddr == "" {
+		return nil
+	}
+	l := cs.grpcListener
+	if l == nil {
+		var err error
+		l, err = net.Listen(splitAddr(cs.GRPCAddr))
+		if err != nil {
+			return fmt.Errorf("gRPC: %w", err)
+		}
+	}
+	srv := grpc.NewServer(grpc.UnaryInterceptor(cs.authorizeGRPC))
+	coffeeshopv1.RegisterCoffeeshopServiceServer(srv, grpcService{cs: cs})
+	reflection.Register(srv)
+	cs.mx.Lock()
+	cs.grpcServer = srv
+	cs.mx.Unlock()
+	go func() {
+		if err := srv.Serve(l); err != nil {
+			cs.logf("gRPC: %v", err)
+		}
+	}()
+	return nil
+}
+
+// stopGRPC stops the gRPC server gracefully, or
+// immediately when the context is done.
+func (cs *Server) stopGRPC(ctx context.Context) {
+	cs.mx.Lock()
+	srv := cs.grpcServer
+	cs.mx.Unlock()
+	if srv == nil {
+		return
+	}
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		srv.Stop()
+	}
+}
+
+// authorizeGRPC applies the policy of API routes to gRPC calls,
+// which carry API keys in the x-api-key metadata.
+func (cs *Server) authorizeGRPC(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if len(cs.Policy.Groups[APIRoutes]) == 0 {
+		return handler(ctx, req)
+	}
+	key := grpcMetadata(ctx, apiKeyHeader)
+	if key == "" {
+		return nil, status.Errorf(codes.PermissionDenied, "%s routes require an API key in the %s metadata", APIRoutes, strings.ToLower(apiKeyHeader))
+	}
+	roles, ok := cs.Policy.roles(key)
+	if !ok {
+		return nil, status.Error(codes.PermissionDenied, "unknown API key")
+	}
+	if !cs.Policy.allows(APIRoutes, roles) {
+		return nil, status.Errorf(codes.PermissionDenied, "API key with roles %v can't call %s routes", roles, APIRoutes)
+	}
+	return handler(ctx, req)
+}
+
+// grpcMetadata returns the first value of the metadata
+// of the incoming call.
+func grpcMetadata(ctx context.Context, name string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(name); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// grpcService implements the gRPC API with the stores of the server.
+type grpcService struct {
+	coffeeshopv1.UnimplementedCoffeeshopServiceServer
+	cs *Server
+}
+
+func (s grpcService) ListProducts(ctx context.Context, req *coffeeshopv1.ListProductsRequest) (*coffeeshopv1.ListProductsResponse, error) {
+	var px []Product
+	var err error
+	if req.GetType() != "" {
+		px, err = listByType(withContext(s.cs.Store, ctx), req.GetType())
+	} else {
+		px, err = listAll(withContext(s.cs.Store, ctx))
+	}
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	px = withoutArchived(px)
+	sortProducts(px)
+	resp := &coffeeshopv1.ListProductsResponse{}
+	for _, p := range px {
+		pb, err := s.product(p, req.GetCurrency())
+		if err != nil {
+			return nil, err
+		}
+		resp.Products = append(resp.Products, pb)
+	}
+	return resp, nil
+}
+
+func (s grpcService) GetProduct(ctx context.Context, req *coffeeshopv1.GetProductRequest) (*coffeeshopv1.Product, error) {
+	p, err := withContext(s.cs.Store, ctx).GetProduct(req.GetId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return s.product(p, req.GetCurrency())
+}
+
+func (s grpcService) ListCategories(ctx context.Context, req *coffeeshopv1.ListCategoriesRequest) (*coffeeshopv1.ListCategoriesResponse, error) {
+	px, err := listAll(withContext(s.cs.Store, ctx))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &coffeeshopv1.ListCategoriesResponse{}
+	for _, c := range categories(withoutArchived(px)) {
+		resp.Categories = append(resp.Categories, &coffeeshopv1.Category{Name: c.Name, Products: int32(c.Products)})
+	}
+	return resp, nil
+}
+
+func (s grpcService) CreateOrder(ctx context.Context, req *coffeeshopv1.CreateOrderRequest) (*coffeeshopv1.Order, error) {
+	customerID, err := s.customerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.GetItems()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid order")
+	}
+	store := withContext(s.cs.Store, ctx)
+	items := make([]OrderItem, 0, len(req.GetItems()))
+	for _, item := range req.GetItems() {
+		if item.GetQuantity() <= 0 {
+			return nil, status.Error(codes.InvalidArgument, "invalid order")
+		}
+		if _, err := orderableProduct(store, item.GetProductId()); err != nil {
+			return nil, grpcError(err)
+		}
+		items = append(items, OrderItem{ProductID: item.GetProductId(), Quantity: int(item.GetQuantity())})
+	}
+	o, err := s.cs.placeOrder(store, Order{CustomerID: customerID, Items: items})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return grpcOrder(o), nil
+}
+
+func (s grpcService) GetOrder(ctx context.Context, req *coffeeshopv1.GetOrderRequest) (*coffeeshopv1.Order, error) {
+	customerID, err := s.customerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	o, err := s.cs.customerOrder(req.GetId(), customerID)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return grpcOrder(o), nil
+}
+
+func (s grpcService) ListOrders(ctx context.Context, req *coffeeshopv1.ListOrdersRequest) (*coffeeshopv1.ListOrdersResponse, error) {
+	customerID, err := s.customerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	orders := s.cs.customerOrders(customerID)
+	sortOrders(orders)
+	resp := &coffeeshopv1.ListOrdersResponse{}
+	for _, o := range orders {
+		resp.Orders = append(resp.Orders, grpcOrder(o))
+	}
+	return resp, nil
+}
+
+// customerID returns the ID of the customer logged in with the
+// bearer token in the authorization metadata, or an empty ID
+// for anonymous calls.
+func (s grpcService) customerID(ctx context.Context) (string, error) {
+	auth := grpcMetadata(ctx, "authorization")
+	if auth == "" {
+		return "", nil
+	}
+	id, ok := s.cs.sessions.lookup(parseBearer(auth), s.cs.Clock.Now())
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "invalid or expired token")
+	}
+	return id, nil
+}
+
+// product returns the protobuf message of the product
+// with the price in the currency, if not empty.
+func (s grpcService) product(p Product, currency string) (*coffeeshopv1.Product, error) {
+	price := p.Price
+	if currency != "" {
+		var err error
+		price, err = price.Convert(strings.ToUpper(currency), s.cs.Rates)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "unsupported currency")
+		}
+	}
+	pb := &coffeeshopv1.Product{
+		Id:       p.ID,
+		Type:     p.Type,
+		Brand:    p.Brand,
+		Name:     p.Name,
+		Unit:     string(p.Unit),
+		Quantity: int32(p.Quantity),
+		Price:    &coffeeshopv1.Money{Amount: price.Amount, Currency: price.Currency},
+		Stock:    int32(p.Stock),
+	}
+	for _, prop := range p.Properties {
+		pb.Properties = append(pb.Properties, &coffeeshopv1.Property{Name: prop.Name, Value: prop.Value})
+	}
+	return pb, nil
+}
+
+// grpcOrderStatuses maps order statuses to their protobuf enum values.
+var grpcOrderStatuses = map[OrderStatus]coffeeshopv1.OrderStatus{
+	OrderReceived:  coffeeshopv1.OrderStatus_ORDER_STATUS_RECEIVED,
+	OrderPreparing: coffeeshopv1.OrderStatus_ORDER_STATUS_PREPARING,
+	OrderReady:     coffeeshopv1.OrderStatus_ORDER_STATUS_READY,
+	OrderCollected: coffeeshopv1.OrderStatus_ORDER_STATUS_COLLECTED,
+}
+
+// grpcOrder returns the protobuf message of the order.
+func grpcOrder(o Order) *coffeeshopv1.Order {
+	pb := &coffeeshopv1.Order{
+		Id:        o.ID,
+		Status:    grpcOrderStatuses[o.Status],
+		CreatedAt: timestamppb.New(o.CreatedAt),
+		UpdatedAt: timestamppb.New(o.UpdatedAt),
+	}
+	for _, item := range o.Items {
+		pb.Items = append(pb.Items, &coffeeshopv1.OrderItem{ProductId: item.ProductID, Quantity: int32(item.Quantity)})
+	}
+	return pb
+}
+
+// grpcCodes maps HTTP statuses of store errors to gRPC codes.
+var grpcCodes = map[int]codes.Code{
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusConflict:            codes.AlreadyExists,
+	http.StatusUnprocessableEntity: codes.FailedPrecondition,
+	http.StatusPreconditionFailed:  codes.FailedPrecondition,
+	http.StatusGone:                codes.OutOfRange,
+	http.StatusGatewayTimeout:      codes.DeadlineExceeded,
+	http.StatusServiceUnavailable:  codes.Unavailable,
+}
+
+// grpcError returns the gRPC status of the store error,
+// mapped like errors of the HTTP API.
+func grpcError(err error) error {
+	if errors.Is(err, ErrOutOfStock) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			if code, ok := grpcCodes[m.status]; ok {
+				return status.Error(code, err.Error())
+			}
+		}
+	}
+	return status.Error(codes.Internal, "internal error")
+}
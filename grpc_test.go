@@ -0,0 +1,129 @@
+package coffeeshop_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/qba73/coffeeshop"
+	coffeeshopv1 "github.com/qba73/coffeeshop/proto/coffeeshop/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+)
+
+// dialGRPC starts the server serving gRPC on a random port
+// and returns a connection to it.
+func dialGRPC(t *testing.T, store coffeeshop.Store, opts ...coffeeshop.Option) *grpc.ClientConn {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newCoffeShopTestServer(store, "0s", t, append(opts, coffeeshop.WithGRPCListener(l))...)
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestServer_ServesCatalogAndOrdersOverGRPC(t *testing.T) {
+	t.Parallel()
+
+	conn := dialGRPC(t, &coffeeshop.MemoryStore{Products: stockedInventory(5)}, coffeeshop.WithOrderInterval("1h"))
+	client := coffeeshopv1.NewCoffeeshopServiceClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	products, err := client.ListProducts(ctx, &coffeeshopv1.ListProductsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(products.GetProducts()); got != len(inventory) {
+		t.Errorf("want %d products, got %d", len(inventory), got)
+	}
+	p, err := client.GetProduct(ctx, &coffeeshopv1.GetProductRequest{Id: "1", Currency: "usd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.GetId() != "1" || p.GetPrice().GetCurrency() != "USD" || p.GetStock() != 5 {
+		t.Errorf("want product 1 with USD price and stock 5, got %v", p)
+	}
+	if _, err := client.GetProduct(ctx, &coffeeshopv1.GetProductRequest{Id: "404"}); status.Code(err) != codes.NotFound {
+		t.Errorf("want NotFound for unknown product, got %v", err)
+	}
+
+	order, err := client.CreateOrder(ctx, &coffeeshopv1.CreateOrderRequest{
+		Items: []*coffeeshopv1.OrderItem{{ProductId: "1", Quantity: 2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.GetStatus() != coffeeshopv1.OrderStatus_ORDER_STATUS_RECEIVED || order.GetCreatedAt().AsTime().IsZero() {
+		t.Errorf("want received order with creation time, got %v", order)
+	}
+	got, err := client.GetOrder(ctx, &coffeeshopv1.GetOrderRequest{Id: order.GetId()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetId() != order.GetId() || len(got.GetItems()) != 1 {
+		t.Errorf("want order %s, got %v", order.GetId(), got)
+	}
+	_, err = client.CreateOrder(ctx, &coffeeshopv1.CreateOrderRequest{
+		Items: []*coffeeshopv1.OrderItem{{ProductId: "1", Quantity: 10}},
+	})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("want FailedPrecondition for order over stock, got %v", err)
+	}
+}
+
+func TestServer_ListsGRPCServicesWithReflection(t *testing.T) {
+	t.Parallel()
+
+	conn := dialGRPC(t, &coffeeshop.MemoryStore{Products: stockedInventory(1)})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, s := range resp.GetListServicesResponse().GetService() {
+		found = found || s.GetName() == "coffeeshop.v1.CoffeeshopService"
+	}
+	if !found {
+		t.Errorf("want coffeeshop service listed, got %v", resp.GetListServicesResponse().GetService())
+	}
+}
+
+func TestServer_AppliesPolicyToGRPCCalls(t *testing.T) {
+	t.Parallel()
+
+	conn := dialGRPC(t, &coffeeshop.MemoryStore{Products: stockedInventory(1)}, coffeeshop.WithPolicy(policy))
+	client := coffeeshopv1.NewCoffeeshopServiceClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := client.ListProducts(ctx, &coffeeshopv1.ListProductsRequest{}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("want PermissionDenied without API key, got %v", err)
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-api-key", "reader-key")
+	if _, err := client.ListProducts(ctx, &coffeeshopv1.ListProductsRequest{}); err != nil {
+		t.Errorf("want products for known API key, got %v", err)
+	}
+}
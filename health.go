@@ -0,0 +1,92 @@
+package coffeeshop
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Check is a named readiness probe. The server is ready
+// to serve requests when all probes return no error.
+type Check struct {
+	Name  string
+	Probe func(ctx context.Context) error
+}
+
+// Pinger is implemented by stores able to report
+// whether their backend is reachable.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Ping reports that the memory store is always reachable.
+func (ms *MemoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+// checkTimeout limits the time a single readiness probe can take.
+const checkTimeout = 2 * time.Second
+
+// WithHealthChecks adds custom probes to the readiness checks.
+func WithHealthChecks(checks ...Check) Option {
+	return func(s *Server) error {
+		for _, c := range checks {
+			if c.Name == "" || c.Probe == nil {
+				return errors.New("invalid health check")
+			}
+		}
+		s.HealthChecks = append(s.HealthChecks, checks...)
+		return nil
+	}
+}
+
+// readinessChecks returns the store check followed by custom checks.
+func (cs *Server) readinessChecks() []Check {
+	checks := make([]Check, 0, len(cs.HealthChecks)+1)
+	if p, ok := storeAs[Pinger](cs.Store); ok {
+		checks = append(checks, Check{Name: "store", Probe: p.Ping})
+	}
+	return append(checks, cs.HealthChecks...)
+}
+
+type healthStatus struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Healthz reports that the server process is alive, the state of
+// the circuit breaker of the store if enabled, and the last error of
+// the event log if events couldn't be appended. Neither fails the
+// probe, since restarting the server doesn't help the backend of
+// the store recover, nor recovers lost events.
+func (cs *Server) Healthz(w http.ResponseWriter, r *http.Request) {
+	status := healthStatus{Status: "ok", Checks: map[string]string{}}
+	if cs.breaker != nil {
+		status.Checks["store_breaker"] = string(cs.breaker.State())
+	}
+	if failed, err := cs.eventLog.failures(); failed > 0 {
+		status.Checks["event_log"] = err.Error()
+	}
+	writeJSON(w, r, http.StatusOK, status)
+}
+
+// Readyz runs readiness checks and responds with 503 Service
+// Unavailable if any of them fails.
+func (cs *Server) Readyz(w http.ResponseWriter, r *http.Request) {
+	status := healthStatus{Status: "ok", Checks: map[string]string{}}
+	code := http.StatusOK
+	for _, c := range cs.readinessChecks() {
+		ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+		err := c.Probe(ctx)
+		cancel()
+		if err != nil {
+			status.Status = "unavailable"
+			status.Checks[c.Name] = err.Error()
+			code = http.StatusServiceUnavailable
+			continue
+		}
+		status.Checks[c.Name] = "ok"
+	}
+	writeJSON(w, r, code, status)
+}
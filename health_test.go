@@ -0,0 +1,105 @@
+package coffeeshop_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+func getHealth(t *testing.T, url string) (int, map[string]any) {
+	t.Helper()
+	client := http.Client{Timeout: time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, body
+}
+
+func TestServer_ReportsLivenessWithoutDelay(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "5s", t)
+	status, body := getHealth(t, shop.URL+"healthz")
+
+	if status != http.StatusOK {
+		t.Errorf("want status 200, got %d", status)
+	}
+	if body["status"] != "ok" {
+		t.Errorf("want status ok, got %v", body["status"])
+	}
+}
+
+func TestServer_ReportsReadinessOfStore(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "5s", t)
+	status, body := getHealth(t, shop.URL+"readyz")
+
+	if status != http.StatusOK {
+		t.Errorf("want status 200, got %d", status)
+	}
+	want := map[string]any{
+		"status": "ok",
+		"checks": map[string]any{"store": "ok"},
+	}
+	if !cmp.Equal(want, body) {
+		t.Error(cmp.Diff(want, body))
+	}
+}
+
+func TestServer_ReportsNotReadyWhenCheckFails(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+	failing := coffeeshop.Check{
+		Name: "payments",
+		Probe: func(ctx context.Context) error {
+			return errors.New("payment gateway unreachable")
+		},
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t, coffeeshop.WithHealthChecks(failing))
+	status, body := getHealth(t, shop.URL+"readyz")
+
+	if status != http.StatusServiceUnavailable {
+		t.Errorf("want status 503, got %d", status)
+	}
+	want := map[string]any{
+		"status": "unavailable",
+		"checks": map[string]any{"store": "ok", "payments": "payment gateway unreachable"},
+	}
+	if !cmp.Equal(want, body) {
+		t.Error(cmp.Diff(want, body))
+	}
+}
+
+func TestWithHealthChecks_FailsOnCheckWithoutProbe(t *testing.T) {
+	t.Parallel()
+
+	_, err := coffeeshop.New(":0", &coffeeshop.MemoryStore{}, coffeeshop.WithHealthChecks(coffeeshop.Check{Name: "broken"}))
+	if err == nil {
+		t.Error("want error on check without probe")
+	}
+}
@@ -0,0 +1,182 @@
+package coffeeshop
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// Media types of hypermedia product encoders.
+const (
+	jsonAPIContentType = "application/vnd.api+json"
+	halContentType     = "application/hal+json"
+)
+
+// WithHypermedia registers encoders of products in the JSON:API
+// and HAL hypermedia formats, selected by clients with the Accept
+// header "application/vnd.api+json" or "application/hal+json".
+func WithHypermedia() Option {
+	return func(s *Server) error {
+		s.Encoders[jsonAPIContentType] = EncodeJSONAPI
+		s.Encoders[halContentType] = EncodeHAL
+		return nil
+	}
+}
+
+// productPath returns the path of the product resource.
+func productPath(p Product) string {
+	return "/products/" + url.PathEscape(p.ID)
+}
+
+// categoryPath returns the path of products in the category
+// of the product.
+func categoryPath(p Product) string {
+	return "/categories/" + url.PathEscape(strings.ToLower(p.Type)) + "/products"
+}
+
+// productFields returns the JSON fields of the product
+// except the given ones.
+func productFields(p Product, except ...string) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for _, name := range except {
+		delete(fields, name)
+	}
+	return fields, nil
+}
+
+// jsonAPIDocument is the top level JSON:API document. Data holds
+// a resource object or a slice of resource objects.
+type jsonAPIDocument struct {
+	Data  any               `json:"data"`
+	Links map[string]string `json:"links,omitempty"`
+}
+
+// jsonAPIResource is the JSON:API resource object.
+type jsonAPIResource struct {
+	Type          string                         `json:"type"`
+	ID            string                         `json:"id"`
+	Attributes    map[string]json.RawMessage     `json:"attributes"`
+	Relationships map[string]jsonAPIRelationship `json:"relationships"`
+	Links         map[string]string              `json:"links"`
+}
+
+// jsonAPIRelationship is the JSON:API relationship object.
+type jsonAPIRelationship struct {
+	Data  *jsonAPIIdentifier `json:"data,omitempty"`
+	Links map[string]string  `json:"links"`
+}
+
+// jsonAPIIdentifier identifies a JSON:API resource.
+type jsonAPIIdentifier struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// jsonAPIProduct returns the JSON:API resource object of the
+// product. JSON:API reserves the 'type' member, so the product
+// type is represented by the relationship to its category.
+func jsonAPIProduct(p Product) (jsonAPIResource, error) {
+	attrs, err := productFields(p, "id", "type")
+	if err != nil {
+		return jsonAPIResource{}, err
+	}
+	self := productPath(p)
+	return jsonAPIResource{
+		Type:       "products",
+		ID:         p.ID,
+		Attributes: attrs,
+		Relationships: map[string]jsonAPIRelationship{
+			"category": {
+				Data:  &jsonAPIIdentifier{Type: "categories", ID: strings.ToLower(p.Type)},
+				Links: map[string]string{"related": categoryPath(p)},
+			},
+			"related": {Links: map[string]string{"related": self + "/related"}},
+			"reviews": {Links: map[string]string{"related": self + "/reviews"}},
+		},
+		Links: map[string]string{"self": self},
+	}, nil
+}
+
+// EncodeJSONAPI writes products as a JSON:API document.
+func EncodeJSONAPI(w io.Writer, v any) error {
+	var doc jsonAPIDocument
+	switch p := v.(type) {
+	case Product:
+		res, err := jsonAPIProduct(p)
+		if err != nil {
+			return err
+		}
+		doc = jsonAPIDocument{Data: res, Links: map[string]string{"self": productPath(p)}}
+	case []Product:
+		resources := make([]jsonAPIResource, 0, len(p))
+		for _, product := range p {
+			res, err := jsonAPIProduct(product)
+			if err != nil {
+				return err
+			}
+			resources = append(resources, res)
+		}
+		doc = jsonAPIDocument{Data: resources}
+	default:
+		return fmt.Errorf("can't encode %T as JSON:API", v)
+	}
+	return json.NewEncoder(w).Encode(doc)
+}
+
+// halLink is the HAL link object.
+type halLink struct {
+	Href string `json:"href"`
+}
+
+// halProduct returns the HAL resource of the product.
+func halProduct(p Product) (map[string]any, error) {
+	fields, err := productFields(p)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]any, len(fields)+1)
+	for name, v := range fields {
+		res[name] = v
+	}
+	res["_links"] = productLinks("", p)
+	return res, nil
+}
+
+// EncodeHAL writes products as a HAL resource. Lists of products
+// are embedded in the resource under the 'products' relation.
+func EncodeHAL(w io.Writer, v any) error {
+	var res any
+	switch p := v.(type) {
+	case Product:
+		product, err := halProduct(p)
+		if err != nil {
+			return err
+		}
+		res = product
+	case []Product:
+		products := make([]map[string]any, 0, len(p))
+		for _, product := range p {
+			hp, err := halProduct(product)
+			if err != nil {
+				return err
+			}
+			products = append(products, hp)
+		}
+		res = map[string]any{
+			"_embedded": map[string]any{"products": products},
+			"count":     len(products),
+		}
+	default:
+		return fmt.Errorf("can't encode %T as HAL", v)
+	}
+	return json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,109 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+func TestServer_ReturnsProductAsJSONAPI(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t, coffeeshop.WithHypermedia())
+	resp := getAccepting(t, shop.URL+"products/3", "application/vnd.api+json")
+	defer resp.Body.Close()
+
+	if got := resp.Header.Get("Content-Type"); got != "application/vnd.api+json" {
+		t.Errorf("want JSON:API content type without parameters, got %q", got)
+	}
+	var got struct {
+		Data struct {
+			Type          string         `json:"type"`
+			ID            string         `json:"id"`
+			Attributes    map[string]any `json:"attributes"`
+			Relationships map[string]struct {
+				Data  map[string]string `json:"data"`
+				Links map[string]string `json:"links"`
+			} `json:"relationships"`
+			Links map[string]string `json:"links"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Data.Type != "products" || got.Data.ID != "3" {
+		t.Errorf("want products resource 3, got %s %s", got.Data.Type, got.Data.ID)
+	}
+	if got.Data.Attributes["name"] != "Selezione Espresso" {
+		t.Errorf("want name attribute, got %v", got.Data.Attributes)
+	}
+	if _, ok := got.Data.Attributes["type"]; ok {
+		t.Error("want reserved 'type' member not among attributes")
+	}
+	category := got.Data.Relationships["category"]
+	if !cmp.Equal(map[string]string{"type": "categories", "id": "coffee"}, category.Data) {
+		t.Errorf("want coffee category, got %v", category.Data)
+	}
+	if category.Links["related"] != "/categories/coffee/products" {
+		t.Errorf("want link to category products, got %v", category.Links)
+	}
+	if got.Data.Links["self"] != "/products/3" {
+		t.Errorf("want self link /products/3, got %v", got.Data.Links)
+	}
+}
+
+func TestServer_ReturnsProductsAsHAL(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t, coffeeshop.WithHypermedia())
+	resp := getAccepting(t, shop.URL+"products/coffee", "application/hal+json")
+	defer resp.Body.Close()
+
+	if got := resp.Header.Get("Content-Type"); got != "application/hal+json" {
+		t.Errorf("want HAL content type, got %q", got)
+	}
+	var got struct {
+		Embedded struct {
+			Products []struct {
+				ID    string `json:"id"`
+				Links map[string]struct {
+					Href string `json:"href"`
+				} `json:"_links"`
+			} `json:"products"`
+		} `json:"_embedded"`
+		Count int `json:"count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Count == 0 || got.Count != len(got.Embedded.Products) {
+		t.Fatalf("want count of embedded products, got %d for %d products", got.Count, len(got.Embedded.Products))
+	}
+	for _, p := range got.Embedded.Products {
+		want := map[string]string{
+			"self":     "/products/" + p.ID,
+			"category": "/categories/coffee/products",
+			"related":  "/products/" + p.ID + "/related",
+			"reviews":  "/products/" + p.ID + "/reviews",
+		}
+		for rel, href := range want {
+			if p.Links[rel].Href != href {
+				t.Errorf("product %s: want %s link %q, got %q", p.ID, rel, href, p.Links[rel].Href)
+			}
+		}
+	}
+}
+
+func TestServer_DoesNotNegotiateHypermediaByDefault(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t)
+	resp := getAccepting(t, shop.URL+"products/3", "application/hal+json")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotAcceptable {
+		t.Errorf("want HTTP 406, got %d", resp.StatusCode)
+	}
+}
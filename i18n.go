@@ -0,0 +1,168 @@
+package coffeeshop
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLanguage is the language of descriptions returned
+// to clients not accepting any of the available languages.
+const DefaultLanguage = "en"
+
+// Translations holds descriptions of products by language
+// and product ID.
+type Translations map[string]map[string]string
+
+// WithLanguage configures the language of descriptions returned
+// to clients not accepting any of the available languages.
+func WithLanguage(lang string) Option {
+	return func(s *Server) error {
+		if lang == "" {
+			return errors.New("empty language")
+		}
+		s.Language = strings.ToLower(lang)
+		return nil
+	}
+}
+
+// WithTranslations loads descriptions of products from JSON files in
+// the directory. Each file is named after the language, for example
+// "pl.json", and holds an object mapping product IDs to descriptions.
+// Descriptions set on products take precedence over the translations.
+func WithTranslations(dir string) Option {
+	return func(s *Server) error {
+		t, err := LoadTranslations(dir)
+		if err != nil {
+			return err
+		}
+		s.Translations = t
+		return nil
+	}
+}
+
+// LoadTranslations reads descriptions of products from
+// JSON files named after languages in the directory.
+func LoadTranslations(dir string) (Translations, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no translations in %s", dir)
+	}
+	t := make(Translations, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var descriptions map[string]string
+		if err := json.Unmarshal(data, &descriptions); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		lang := strings.ToLower(strings.TrimSuffix(filepath.Base(path), ".json"))
+		t[lang] = descriptions
+	}
+	return t, nil
+}
+
+// acceptedLanguages returns language tags from the Accept-Language
+// header ordered by client preference. Tags with regions are followed
+// by their primary language, so "pl-PL" also accepts "pl".
+func acceptedLanguages(header string) []string {
+	type languageRange struct {
+		tag string
+		q   float64
+	}
+	if header == "" {
+		return nil
+	}
+	var ranges []languageRange
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, languageRange{tag: tag, q: q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+	tags := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		tags = append(tags, r.tag)
+		if primary, _, ok := strings.Cut(r.tag, "-"); ok {
+			tags = append(tags, primary)
+		}
+	}
+	return tags
+}
+
+// description returns the description of the product in the language
+// preferred by the client, falling back to the default language.
+func (cs *Server) description(r *http.Request, p Product) string {
+	d, _ := cs.describe(r, p)
+	return d
+}
+
+// describe returns the description of the product in the language
+// preferred by the client, falling back to the default language,
+// and the language of the description. It returns empty strings if
+// the product isn't described in any of the languages.
+func (cs *Server) describe(r *http.Request, p Product) (description, lang string) {
+	for _, lang := range acceptedLanguages(r.Header.Get("Accept-Language")) {
+		if d, ok := cs.translate(p, lang); ok {
+			return d, lang
+		}
+	}
+	if d, ok := cs.translate(p, cs.Language); ok {
+		return d, cs.Language
+	}
+	return "", ""
+}
+
+// descriptionLanguages returns sorted languages of descriptions of
+// the products sent in response to the request. Descriptions are
+// sent in JSON only.
+func (cs *Server) descriptionLanguages(r *http.Request, px []Product) []string {
+	if cs.negotiate(r) != jsonContentType {
+		return nil
+	}
+	seen := map[string]bool{}
+	var langs []string
+	for _, p := range px {
+		if _, lang := cs.describe(r, p); lang != "" && !seen[lang] {
+			seen[lang] = true
+			langs = append(langs, lang)
+		}
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// translate returns the description of the product in the language.
+func (cs *Server) translate(p Product, lang string) (string, bool) {
+	for l, d := range p.Descriptions {
+		if strings.EqualFold(l, lang) {
+			return d, true
+		}
+	}
+	d, ok := cs.Translations[lang][p.ID]
+	return d, ok
+}
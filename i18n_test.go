@@ -0,0 +1,133 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// getDescription returns the description of the product
+// requested with the Accept-Language header.
+func getDescription(t *testing.T, url, acceptLanguage string) string {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acceptLanguage != "" {
+		req.Header.Set("Accept-Language", acceptLanguage)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200, got %d", resp.StatusCode)
+	}
+	var p struct {
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	return p.Description
+}
+
+func TestServer_ReturnsDescriptionInAcceptedLanguage(t *testing.T) {
+	t.Parallel()
+
+	p := inventory["1"]
+	p.Descriptions = map[string]string{"en": "Intense espresso beans", "de": "Intensive Espressobohnen"}
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: coffeeshop.Products{"1": p}}, "0s", t)
+	tcs := map[string]string{
+		"":                         "Intense espresso beans",
+		"de":                       "Intensive Espressobohnen",
+		"de-AT,en;q=0.5":           "Intensive Espressobohnen",
+		"fr, de;q=0.3, en;q=0.9":   "Intense espresso beans",
+		"fr":                       "Intense espresso beans",
+		"en;q=0, de;q=0.1, fr;q=1": "Intensive Espressobohnen",
+	}
+	for acceptLanguage, want := range tcs {
+		if got := getDescription(t, shop.URL+"products/1", acceptLanguage); got != want {
+			t.Errorf("%q: want %q, got %q", acceptLanguage, want, got)
+		}
+	}
+}
+
+func TestServer_TagsProductsInEachLanguageSeparately(t *testing.T) {
+	t.Parallel()
+
+	p := inventory["1"]
+	p.Descriptions = map[string]string{"en": "Intense espresso beans", "de": "Intensive Espressobohnen"}
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: coffeeshop.Products{"1": p}}, "0s", t)
+	get := func(acceptLanguage, etag string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, shop.URL+"products/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept-Language", acceptLanguage)
+		req.Header.Set("If-None-Match", etag)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	en := get("en", "")
+	if got := en.Header.Get("Content-Language"); got != "en" {
+		t.Errorf("want Content-Language en, got %q", got)
+	}
+	de := get("de", en.Header.Get("ETag"))
+	if de.StatusCode != http.StatusOK {
+		t.Errorf("want HTTP 200 for German with ETag of English, got %d", de.StatusCode)
+	}
+	if got := de.Header.Get("Content-Language"); got != "de" {
+		t.Errorf("want Content-Language de, got %q", got)
+	}
+	if de.Header.Get("ETag") == en.Header.Get("ETag") {
+		t.Errorf("want different entity tags of descriptions in English and German, got %s", en.Header.Get("ETag"))
+	}
+	if resp := get("de-AT", de.Header.Get("ETag")); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("want HTTP 304 for the same description, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_ReturnsDescriptionsLoadedFromTranslations(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"en.json": `{"1": "Intense espresso beans"}`,
+		"pl.json": `{"1": "Intensywna kawa ziarnista"}`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: coffeeshop.Products{"1": inventory["1"]}}, "0s", t,
+		coffeeshop.WithTranslations(dir),
+		coffeeshop.WithLanguage("pl"),
+	)
+	if got, want := getDescription(t, shop.URL+"products/1", "en-GB"), "Intense espresso beans"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if got, want := getDescription(t, shop.URL+"products/1", "fr"), "Intensywna kawa ziarnista"; got != want {
+		t.Errorf("want fallback %q, got %q", want, got)
+	}
+}
+
+func TestWithTranslations_FailsWithoutTranslationFiles(t *testing.T) {
+	t.Parallel()
+
+	_, err := coffeeshop.New(":0", &coffeeshop.MemoryStore{}, coffeeshop.WithTranslations(t.TempDir()))
+	if err == nil {
+		t.Error("want error for directory without translations")
+	}
+}
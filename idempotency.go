@@ -0,0 +1,232 @@
+package coffeeshop
+
+import (
+	"bytes"
+	"container/heap"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// DefaultIdempotencyWindow is how long responses to requests
+// with an Idempotency-Key are replayed by default.
+const DefaultIdempotencyWindow = 24 * time.Hour
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	// replayedHeader marks responses replayed for
+	// a repeated Idempotency-Key.
+	replayedHeader = "Idempotent-Replayed"
+)
+
+// replayedHeaders are headers of responses replayed
+// for a repeated Idempotency-Key.
+var replayedHeaders = []string{"Content-Type", "Location", "ETag", "Last-Modified"}
+
+// WithIdempotencyWindow configures how long responses to requests
+// with an Idempotency-Key header are replayed to requests repeating
+// the key, for example "1h".
+func WithIdempotencyWindow(window string) Option {
+	return func(s *Server) error {
+		d, err := time.ParseDuration(window)
+		if err != nil {
+			return err
+		}
+		if d <= 0 {
+			return errors.New("idempotency window must be positive")
+		}
+		s.IdempotencyWindow = d
+		return nil
+	}
+}
+
+// idempotencyWindow returns how long responses are replayed.
+func (cs *Server) idempotencyWindow() time.Duration {
+	if cs.IdempotencyWindow <= 0 {
+		return DefaultIdempotencyWindow
+	}
+	return cs.IdempotencyWindow
+}
+
+// idempotentResponse is the response to the request with
+// an Idempotency-Key. It's pending until the handler returns.
+type idempotentResponse struct {
+	key     string
+	request [sha256.Size]byte
+	pending bool
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// idempotencyRegistry holds responses keyed by the principal, the
+// method, the path and the Idempotency-Key of requests.
+type idempotencyRegistry struct {
+	mx        sync.Mutex
+	responses map[string]*idempotentResponse
+	// expiring holds stored responses, earliest expiring first.
+	expiring expiryHeap
+}
+
+// begin returns the response stored for the key, or stores
+// a pending response and reports that the request is new.
+func (ir *idempotencyRegistry) begin(key string, request [sha256.Size]byte, now time.Time) (idempotentResponse, bool) {
+	ir.mx.Lock()
+	defer ir.mx.Unlock()
+	if ir.responses == nil {
+		ir.responses = make(map[string]*idempotentResponse)
+	}
+	ir.expire(now)
+	if resp, ok := ir.responses[key]; ok {
+		return *resp, false
+	}
+	ir.responses[key] = &idempotentResponse{key: key, request: request, pending: true}
+	return idempotentResponse{}, true
+}
+
+// expire forgets responses expired at the time. Only expired
+// responses are visited, so it's cheap when nothing expires.
+func (ir *idempotencyRegistry) expire(now time.Time) {
+	for len(ir.expiring) > 0 && !now.Before(ir.expiring[0].expires) {
+		resp := heap.Pop(&ir.expiring).(*idempotentResponse)
+		if ir.responses[resp.key] == resp {
+			delete(ir.responses, resp.key)
+		}
+	}
+}
+
+// finish stores the response for the key, or forgets the
+// key if the response shouldn't be replayed.
+func (ir *idempotencyRegistry) finish(key string, resp *idempotentResponse) {
+	ir.mx.Lock()
+	defer ir.mx.Unlock()
+	if resp == nil {
+		delete(ir.responses, key)
+		return
+	}
+	resp.key = key
+	ir.responses[key] = resp
+	heap.Push(&ir.expiring, resp)
+}
+
+// expiryHeap orders responses by expiry with container/heap.
+type expiryHeap []*idempotentResponse
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expires.Before(h[j].expires) }
+func (h expiryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *expiryHeap) Push(x any)        { *h = append(*h, x.(*idempotentResponse)) }
+func (h *expiryHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return x
+}
+
+// principal identifies who sent the request, so clients can't
+// replay responses to each other by guessing Idempotency-Keys:
+// the customer logged in with the bearer token, the API key, or
+// the client address of anonymous requests. Credentials are
+// hashed, so they aren't kept in memory.
+func (cs *Server) principal(r *http.Request) string {
+	if r.Header.Get("Authorization") != "" {
+		if id, ok := cs.sessions.lookup(bearerToken(r), cs.Clock.Now()); ok {
+			return "customer:" + id
+		}
+		return "token:" + hashCredential(r.Header.Get("Authorization"))
+	}
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		return "key:" + hashCredential(key)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "client:" + host
+}
+
+// hashCredential returns the hex encoded SHA-256 of the credential.
+func hashCredential(credential string) string {
+	sum := sha256.Sum256([]byte(credential))
+	return hex.EncodeToString(sum[:])
+}
+
+// idempotent replays the response to the first request with the
+// Idempotency-Key to requests of the same principal repeating the
+// key within the window, instead of handling them again. Requests
+// repeating the key with a different body are answered with 422
+// Unprocessable Entity, and while the first request is handled with
+// 409 Conflict. Server errors aren't replayed, so clients can retry
+// the request.
+func (cs *Server) idempotent(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, cs.MaxBodySize))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "can't read request body")
+			return
+		}
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+		request := sha256.Sum256(body)
+		key = cs.principal(r) + " " + r.Method + " " + r.URL.Path + " " + key
+		stored, isNew := cs.idempotency.begin(key, request, cs.Clock.Now())
+		switch {
+		case !isNew && stored.request != request:
+			writeError(w, r, http.StatusUnprocessableEntity, "Idempotency-Key was used for a different request")
+			return
+		case !isNew && stored.pending:
+			writeError(w, r, http.StatusConflict, "request with the Idempotency-Key is in progress")
+			return
+		case !isNew:
+			for name, values := range stored.header {
+				w.Header()[name] = values
+			}
+			w.Header().Set(replayedHeader, "true")
+			w.WriteHeader(stored.status)
+			_, _ = w.Write(stored.body)
+			return
+		}
+
+		var out bytes.Buffer
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(&out)
+		var resp *idempotentResponse
+		defer func() { cs.idempotency.finish(key, resp) }()
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		header := make(http.Header)
+		for _, name := range replayedHeaders {
+			if v := w.Header().Values(name); len(v) > 0 {
+				header[name] = v
+			}
+		}
+		resp = &idempotentResponse{
+			request: request,
+			status:  status,
+			header:  header,
+			body:    out.Bytes(),
+			expires: cs.Clock.Now().Add(cs.idempotencyWindow()),
+		}
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,133 @@
+package coffeeshop_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// postWithKey sends the POST request with the Idempotency-Key
+// and returns the response with its body.
+func postWithKey(t *testing.T, url, key, body string) (*http.Response, string) {
+	t.Helper()
+	return postWithKeyAs(t, "", url, key, body)
+}
+
+// postWithKeyAs sends the POST request with the Idempotency-Key
+// and the API key, if not empty.
+func postWithKeyAs(t *testing.T, apiKey, url, key, body string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", key)
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(data)
+}
+
+func TestServer_ReplaysOrderForRepeatedIdempotencyKey(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(5)}, "0s", t,
+		coffeeshop.WithOrderInterval("1h"),
+	)
+	body := `{"items":[{"productId":"1","quantity":1}]}`
+	first, firstBody := postWithKey(t, shop.URL+"orders", "order-1", body)
+	if first.StatusCode != http.StatusCreated {
+		t.Fatalf("want HTTP 201, got %d: %s", first.StatusCode, firstBody)
+	}
+	second, secondBody := postWithKey(t, shop.URL+"orders", "order-1", body)
+	if second.StatusCode != http.StatusCreated {
+		t.Fatalf("want replayed HTTP 201, got %d", second.StatusCode)
+	}
+	if secondBody != firstBody {
+		t.Errorf("want replayed body %s, got %s", firstBody, secondBody)
+	}
+	if second.Header.Get("Location") != first.Header.Get("Location") {
+		t.Errorf("want replayed Location %q, got %q", first.Header.Get("Location"), second.Header.Get("Location"))
+	}
+	if second.Header.Get("Idempotent-Replayed") != "true" {
+		t.Error("want replayed response marked with Idempotent-Replayed")
+	}
+	if got := len(shop.OrderStore.GetOrders()); got != 1 {
+		t.Errorf("want 1 order placed, got %d", got)
+	}
+}
+
+func TestServer_RejectsIdempotencyKeyReusedForDifferentRequest(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(5)}, "0s", t)
+	resp, _ := postWithKey(t, shop.URL+"products", "product-1", `{"id":"100","name":"Mocha","type":"Coffee","price":"3.50"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("want HTTP 201, got %d", resp.StatusCode)
+	}
+	resp, _ = postWithKey(t, shop.URL+"products", "product-1", `{"id":"101","name":"Latte","type":"Coffee","price":"3.50"}`)
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("want HTTP 422, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_HandlesRequestAgainAfterIdempotencyWindow(t *testing.T) {
+	t.Parallel()
+
+	clock := coffeeshop.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(5)}, "0s", t,
+		coffeeshop.WithClock(clock),
+		coffeeshop.WithIdempotencyWindow("1m"),
+	)
+	body := `{"id":"100","name":"Mocha","type":"Coffee","price":"3.50"}`
+	postWithKey(t, shop.URL+"products", "product-1", body)
+	clock.Advance(time.Minute)
+	resp, _ := postWithKey(t, shop.URL+"products", "product-1", body)
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("want HTTP 409 for the existing product, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Idempotent-Replayed") != "" {
+		t.Error("want request handled again, got replayed response")
+	}
+}
+
+func TestServer_ScopesIdempotencyKeysByAPIKey(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(5)}, "0s", t,
+		coffeeshop.WithOrderInterval("1h"),
+		coffeeshop.WithPolicy(coffeeshop.Policy{
+			Keys:   map[string][]string{"k3y-alice": {"client"}, "k3y-bob": {"client"}},
+			Groups: map[coffeeshop.RouteGroup][]string{coffeeshop.APIRoutes: {coffeeshop.Authenticated}},
+		}),
+	)
+	body := `{"items":[{"productId":"1","quantity":1}]}`
+	alice, aliceBody := postWithKeyAs(t, "k3y-alice", shop.URL+"orders", "order-1", body)
+	if alice.StatusCode != http.StatusCreated {
+		t.Fatalf("want HTTP 201, got %d: %s", alice.StatusCode, aliceBody)
+	}
+	bob, bobBody := postWithKeyAs(t, "k3y-bob", shop.URL+"orders", "order-1", body)
+	if bob.StatusCode != http.StatusCreated {
+		t.Fatalf("want HTTP 201, got %d: %s", bob.StatusCode, bobBody)
+	}
+	if bob.Header.Get("Idempotent-Replayed") != "" || bob.Header.Get("Location") == alice.Header.Get("Location") {
+		t.Errorf("want new order for another API key, got replayed response %s", bobBody)
+	}
+	if got := len(shop.OrderStore.GetOrders()); got != 2 {
+		t.Errorf("want 2 orders placed, got %d", got)
+	}
+}
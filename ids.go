@@ -0,0 +1,126 @@
+package coffeeshop
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// maxIDAttempts limits IDs generated for a product
+// until one isn't taken by another product.
+const maxIDAttempts = 1000
+
+// IDGenerator generates IDs of products created without an ID.
+type IDGenerator interface {
+	NewID() (string, error)
+}
+
+// UUIDv7 generates time-ordered UUIDs version 7, so IDs of
+// products sort in the order the products were created in.
+type UUIDv7 struct {
+	// Clock timestamps IDs. Nil means the real time.
+	Clock Clock
+
+	mx      sync.Mutex
+	last    int64
+	counter uint16
+}
+
+// NewID returns a UUID with the current Unix time in milliseconds
+// in the first 48 bits, followed by the version, a 12-bit counter
+// and the variant and random bits. The counter starts from a random
+// value each millisecond and grows for IDs generated within the same
+// millisecond, so IDs sort in the order they were generated in, even
+// if the clock goes back. When the counter runs out, the timestamp
+// moves on to the next millisecond.
+func (g *UUIDv7) NewID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[6:]); err != nil {
+		return "", err
+	}
+	ms, counter := g.next(binary.BigEndian.Uint16(b[6:8]) & 0x07ff)
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(ms))
+	copy(b[:6], ts[2:])
+	binary.BigEndian.PutUint16(b[6:8], 0x7000|counter)
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:], nil
+}
+
+// next returns the timestamp and the counter of the next ID, seeding
+// the counter with seed when the timestamp moves on.
+func (g *UUIDv7) next(seed uint16) (int64, uint16) {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+	ms := now(g.Clock).UnixMilli()
+	switch {
+	case ms > g.last:
+		g.last, g.counter = ms, seed
+	case g.counter < 0x0fff:
+		g.counter++
+	default:
+		g.last, g.counter = g.last+1, seed
+	}
+	return g.last, g.counter
+}
+
+func (g *UUIDv7) useClock(c Clock) {
+	if g.Clock == nil {
+		g.Clock = c
+	}
+}
+
+// SequentialIDs generates numeric IDs "1", "2", "3" and so on,
+// for deterministic tests. IDs taken by products in the store
+// are skipped.
+type SequentialIDs struct {
+	mx   sync.Mutex
+	last uint64
+}
+
+// NewID returns the ID following the last generated ID.
+func (g *SequentialIDs) NewID() (string, error) {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+	g.last++
+	return strconv.FormatUint(g.last, 10), nil
+}
+
+// WithIDGenerator configures the generator of IDs of products
+// created or imported without an ID. The default is UUIDv7.
+func WithIDGenerator(g IDGenerator) Option {
+	return func(s *Server) error {
+		if g == nil {
+			return errors.New("nil ID generator")
+		}
+		s.IDGenerator = g
+		return nil
+	}
+}
+
+// newProductID returns an ID from the generator of the server
+// which isn't taken by a product in the store.
+func (cs *Server) newProductID(s Store) (string, error) {
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		id, err := cs.IDGenerator.NewID()
+		if err != nil {
+			return "", err
+		}
+		if id == "" {
+			return "", errors.New("empty ID generated")
+		}
+		_, err = s.GetProduct(id)
+		if errors.Is(err, ErrProductNotFound) {
+			return id, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("no free ID generated in %d attempts", maxIDAttempts)
+}
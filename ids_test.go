@@ -0,0 +1,137 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qba73/coffeeshop"
+)
+
+var uuidV7 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestUUIDv7_GeneratesTimeOrderedIDs(t *testing.T) {
+	t.Parallel()
+
+	clock := coffeeshop.NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	g := &coffeeshop.UUIDv7{Clock: clock}
+	var last string
+	for i := 0; i < 10; i++ {
+		id, err := g.NewID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !uuidV7.MatchString(id) {
+			t.Fatalf("want UUID version 7, got %q", id)
+		}
+		if id <= last {
+			t.Errorf("want %q after %q", id, last)
+		}
+		last = id
+		clock.Advance(time.Millisecond)
+	}
+	if !strings.HasPrefix(last, "018f3406-9e09-") {
+		t.Errorf("want ID starting with the time of the clock, got %q", last)
+	}
+}
+
+func TestUUIDv7_GeneratesOrderedIDsWithinTheSameMillisecond(t *testing.T) {
+	t.Parallel()
+
+	clock := coffeeshop.NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	g := &coffeeshop.UUIDv7{Clock: clock}
+	var last string
+	for i := 0; i < 5000; i++ {
+		id, err := g.NewID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !uuidV7.MatchString(id) {
+			t.Fatalf("want UUID version 7, got %q", id)
+		}
+		if id <= last {
+			t.Fatalf("ID %d: want %q after %q", i, id, last)
+		}
+		last = id
+	}
+}
+
+func createProduct(t *testing.T, url, body string) (int, coffeeshop.Product) {
+	t.Helper()
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var p coffeeshop.Product
+	if resp.StatusCode == http.StatusCreated {
+		if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode, p
+}
+
+func TestServer_GeneratesUUIDsOfCreatedProducts(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(1)}, "0s", t)
+	status, p := createProduct(t, shop.URL+"products", `{"type": "Tea", "name": "Green"}`)
+	if status != http.StatusCreated {
+		t.Fatalf("want HTTP 201, got %d", status)
+	}
+	if !uuidV7.MatchString(p.ID) {
+		t.Errorf("want UUID version 7, got %q", p.ID)
+	}
+}
+
+func TestServer_GeneratesSequentialIDsSkippingTakenIDs(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: coffeeshop.Products{
+		"1": {ID: "1", Type: "Coffee", Name: "Intenso"},
+		"2": {ID: "2", Type: "Coffee", Name: "Classico"},
+	}}
+	shop := newCoffeShopTestServer(store, "0s", t, coffeeshop.WithIDGenerator(&coffeeshop.SequentialIDs{}))
+	for _, want := range []string{"3", "4"} {
+		_, p := createProduct(t, shop.URL+"products", `{"type": "Tea", "name": "Green"}`)
+		if p.ID != want {
+			t.Errorf("want ID %s, got %q", want, p.ID)
+		}
+	}
+}
+
+func TestServer_RejectsCreatedProductsWithTakenIDs(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(1)}, "0s", t)
+	status, _ := createProduct(t, shop.URL+"products", `{"id": "1", "type": "Tea", "name": "Green"}`)
+	if status != http.StatusConflict {
+		t.Errorf("want HTTP 409, got %d", status)
+	}
+}
+
+func TestServer_GeneratesIDsOfImportedProducts(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: stockedInventory(1)}
+	shop := newCoffeShopTestServer(store, "0s", t, coffeeshop.WithIDGenerator(&coffeeshop.SequentialIDs{}))
+	resp, err := http.Post(shop.URL+"products/import", "text/csv", strings.NewReader("id,type,name\n,Tea,Green\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var report coffeeshop.ImportReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported != 1 || report.Results[0].ID != "9" {
+		t.Fatalf("want product imported with ID 9, got %+v", report)
+	}
+	if _, err := store.GetProduct("9"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,219 @@
+package coffeeshop
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// DefaultMaxImageSize is the default limit of the size
+// of uploaded product images.
+const DefaultMaxImageSize = 5 << 20
+
+// imageTypes holds media types of accepted images.
+var imageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Image represents a product image.
+type Image struct {
+	ContentType string
+	Data        []byte
+	ModifiedAt  time.Time
+}
+
+// ImageStore represents a storage for product images.
+type ImageStore interface {
+	PutImage(productID string, img Image) error
+	GetImage(productID string) (Image, error)
+}
+
+// MemoryImageStore represents an in-memory storage for images.
+//
+// Use memory image store for testing and development.
+type MemoryImageStore struct {
+	mx     sync.RWMutex
+	Images map[string]Image
+}
+
+// PutImage stores the image of the product.
+func (ms *MemoryImageStore) PutImage(productID string, img Image) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	if ms.Images == nil {
+		ms.Images = make(map[string]Image)
+	}
+	img.Data = append([]byte(nil), img.Data...)
+	ms.Images[productID] = img
+	return nil
+}
+
+// GetImage returns the image of the product.
+func (ms *MemoryImageStore) GetImage(productID string) (Image, error) {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	img, ok := ms.Images[productID]
+	if !ok {
+		return Image{}, ErrImageNotFound
+	}
+	return img, nil
+}
+
+// FileImageStore stores images as files in the directory.
+// The content type of an image is detected when it's read.
+type FileImageStore struct {
+	Dir string
+}
+
+func (fis FileImageStore) path(productID string) (string, error) {
+	name := url.PathEscape(productID)
+	if name == "" || name == "." || name == ".." {
+		return "", fmt.Errorf("%w: invalid id %q", ErrInvalidProduct, productID)
+	}
+	return filepath.Join(fis.Dir, name), nil
+}
+
+// PutImage writes the image of the product to a file. The file
+// is replaced atomically, so readers never see a partial image.
+func (fis FileImageStore) PutImage(productID string, img Image) error {
+	path, err := fis.path(productID)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(fis.Dir, ".image-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(img.Data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// GetImage reads the image of the product from the file.
+func (fis FileImageStore) GetImage(productID string) (Image, error) {
+	path, err := fis.path(productID)
+	if err != nil {
+		return Image{}, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Image{}, ErrImageNotFound
+	}
+	if err != nil {
+		return Image{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return Image{}, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return Image{}, err
+	}
+	return Image{
+		ContentType: http.DetectContentType(data),
+		Data:        data,
+		ModifiedAt:  info.ModTime(),
+	}, nil
+}
+
+// WithImageStore configures the storage used for product images.
+func WithImageStore(store ImageStore) Option {
+	return func(s *Server) error {
+		if store == nil {
+			return errors.New("nil image store")
+		}
+		s.ImageStore = store
+		return nil
+	}
+}
+
+// WithImageDir configures the server to store product
+// images as files in the directory.
+func WithImageDir(dir string) Option {
+	return func(s *Server) error {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+		s.ImageStore = FileImageStore{Dir: dir}
+		return nil
+	}
+}
+
+// WithMaxImageSize configures the limit of the size
+// of uploaded images in bytes.
+func WithMaxImageSize(n int64) Option {
+	return func(s *Server) error {
+		if n <= 0 {
+			return fmt.Errorf("invalid max image size %d", n)
+		}
+		s.MaxImageSize = n
+		return nil
+	}
+}
+
+// PutProductImage stores the image of the product sent in the
+// request body. The content type is detected from the image data.
+func (cs *Server) PutProductImage(w http.ResponseWriter, r *http.Request) {
+	productID := chi.URLParam(r, "productID")
+	if _, err := cs.store(r).GetProduct(productID); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, cs.MaxImageSize+1))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "can't read image")
+		return
+	}
+	if int64(len(data)) > cs.MaxImageSize {
+		writeError(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("image exceeds %d bytes", cs.MaxImageSize))
+		return
+	}
+	contentType := http.DetectContentType(data)
+	if !imageTypes[contentType] {
+		writeError(w, r, http.StatusUnsupportedMediaType, "image must be PNG, JPEG, GIF or WebP")
+		return
+	}
+	img := Image{ContentType: contentType, Data: data, ModifiedAt: cs.Clock.Now()}
+	if err := cs.ImageStore.PutImage(productID, img); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetProductImage serves the image of the product.
+// Range and conditional requests are supported.
+func (cs *Server) GetProductImage(w http.ResponseWriter, r *http.Request) {
+	productID := chi.URLParam(r, "productID")
+	img, err := cs.ImageStore.GetImage(productID)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", img.ContentType)
+	http.ServeContent(w, r, "", img.ModifiedAt, bytes.NewReader(img.Data))
+}
@@ -0,0 +1,127 @@
+package coffeeshop_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// pngImage is the signature and header chunk of a 1x1 PNG image.
+var pngImage = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00\x1f\x15\xc4\x89")
+
+func putImage(t *testing.T, url string, data []byte) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestServer_StoresAndServesProductImage(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp := putImage(t, shop.URL+"products/1/image", pngImage)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("want HTTP 204, got %d", resp.StatusCode)
+	}
+
+	resp, err := http.Get(shop.URL + "products/1/image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200OK, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "image/png" {
+		t.Errorf("want content type image/png, got %q", got)
+	}
+	got, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pngImage, got) {
+		t.Errorf("want uploaded image, got %q", got)
+	}
+}
+
+func TestServer_RejectsInvalidProductImages(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t, coffeeshop.WithMaxImageSize(int64(len(pngImage))))
+	tests := []struct {
+		path string
+		data []byte
+		want int
+	}{
+		{"products/1/image", []byte("plain text"), http.StatusUnsupportedMediaType},
+		{"products/1/image", append(append([]byte(nil), pngImage...), 0), http.StatusRequestEntityTooLarge},
+		{"products/42/image", pngImage, http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		resp := putImage(t, shop.URL+tc.path, tc.data)
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s: want HTTP %d, got %d", tc.path, tc.want, resp.StatusCode)
+		}
+	}
+}
+
+func TestServer_Returns404ForProductWithoutImage(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp, err := http.Get(shop.URL + "products/1/image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("want HTTP 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestFileImageStore_StoresImagesInDirectory(t *testing.T) {
+	t.Parallel()
+
+	store := coffeeshop.FileImageStore{Dir: t.TempDir()}
+	if _, err := store.GetImage("1"); !errors.Is(err, coffeeshop.ErrImageNotFound) {
+		t.Errorf("want ErrImageNotFound, got %v", err)
+	}
+	if err := store.PutImage("a/../1", coffeeshop.Image{Data: pngImage}); err != nil {
+		t.Fatal(err)
+	}
+	img, err := store.GetImage("a/../1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.ContentType != "image/png" || !bytes.Equal(pngImage, img.Data) {
+		t.Errorf("want PNG image, got %s %q", img.ContentType, img.Data)
+	}
+	if err := store.PutImage("..", coffeeshop.Image{Data: pngImage}); err == nil {
+		t.Error("want error on invalid product ID")
+	}
+}
@@ -0,0 +1,339 @@
+package coffeeshop
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Importer is implemented by stores able to add products.
+type Importer interface {
+	// PutProduct adds the product or replaces the
+	// product with the same ID.
+	PutProduct(p Product) (Product, error)
+}
+
+// PutProduct adds the product to the store or replaces
+// the product with the same ID.
+func (ms *MemoryStore) PutProduct(p Product) (Product, error) {
+	if p.ID == "" {
+		return Product{}, fmt.Errorf("%w: missing id", ErrInvalidProduct)
+	}
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	old, exists := ms.Products[p.ID]
+	return ms.put(p, old, exists)
+}
+
+// put stores the product replacing the old one, if it exists.
+// It must be called with the store lock held.
+func (ms *MemoryStore) put(p, old Product, exists bool) (Product, error) {
+	if ms.Products == nil {
+		ms.Products = make(Products)
+	}
+	if err := ms.checkCodes(p); err != nil {
+		return Product{}, err
+	}
+	p = p.clone()
+	p.Version = old.Version + 1
+	p = stamped(p, old, now(ms.Clock))
+	ms.Products[p.ID] = p
+	ms.indexCodes(p)
+	ms.recordChange(p.ID, !exists)
+	ms.recordPrice(old, exists, p)
+	if exists {
+		ms.publishChange(p)
+	} else {
+		ms.events.Publish(ProductAdded, p.clone())
+	}
+	return p.clone(), nil
+}
+
+// maxImportSize limits the size of uploaded import files.
+const maxImportSize = 32 << 20
+
+// Import statuses of rows.
+const (
+	importSucceeded = "imported"
+	importFailed    = "failed"
+)
+
+// ImportResult describes the outcome of importing a single row.
+type ImportResult struct {
+	Row    int      `json:"row"`
+	ID     string   `json:"id,omitempty"`
+	Status string   `json:"status"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// ImportReport summarizes the bulk import of products.
+type ImportReport struct {
+	Imported int            `json:"imported"`
+	Failed   int            `json:"failed"`
+	Results  []ImportResult `json:"results"`
+}
+
+// importRow is a decoded row of the import file.
+type importRow struct {
+	product  Product
+	problems []string
+}
+
+// ImportProducts adds or replaces products listed in the uploaded
+// JSON or CSV file. The file is sent as the "file" field of a
+// multipart form or as the request body. Each row is validated and
+// imported independently. The response reports the outcome of every
+// row with 200 OK if all rows were imported, 207 Multi-Status if some
+// of them failed and 422 Unprocessable Entity if all of them failed.
+// Rows without an ID are imported as new products with IDs generated
+// by the IDGenerator of the server.
+func (cs *Server) ImportProducts(w http.ResponseWriter, r *http.Request) {
+	importer, ok := storeAs[Importer](cs.store(r))
+	if !ok {
+		writeError(w, r, http.StatusNotImplemented, "store doesn't support import")
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	data, format, err := importFile(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	var rows []importRow
+	switch format {
+	case "json":
+		rows, err = decodeJSONRows(data)
+	case "csv":
+		rows, err = decodeCSVRows(data)
+	default:
+		writeError(w, r, http.StatusUnsupportedMediaType, "import file must be JSON or CSV")
+		return
+	}
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(rows) == 0 {
+		writeError(w, r, http.StatusBadRequest, "no products to import")
+		return
+	}
+
+	report := ImportReport{Results: make([]ImportResult, 0, len(rows))}
+	seen := make(map[string]bool, len(rows))
+	for i, row := range rows {
+		p := row.product
+		if p.ID == "" && len(row.problems) == 0 {
+			id, err := cs.newProductID(cs.store(r))
+			if err != nil {
+				row.problems = append(row.problems, err.Error())
+			}
+			p.ID = id
+		}
+		result := ImportResult{Row: i + 1, ID: p.ID, Status: importFailed}
+		result.Errors = append(row.problems, validateProduct(cs.checked(p))...)
+		if p.ID != "" && seen[p.ID] {
+			result.Errors = append(result.Errors, "duplicate id")
+		}
+		seen[p.ID] = true
+		if len(result.Errors) == 0 {
+			if p.Price.Currency == "" {
+				p.Price.Currency = DefaultCurrency
+			}
+			old, product, err := cs.replaceProduct(r, p.ID, func(Product, bool) (Product, error) {
+				return p, nil
+			}, importer.PutProduct)
+			switch {
+			case err != nil:
+				result.Errors = append(result.Errors, err.Error())
+			case old.ID == "":
+				cs.audit(r, AuditCreate, old, product)
+			default:
+				cs.audit(r, AuditUpdate, old, product)
+			}
+		}
+		if len(result.Errors) == 0 {
+			result.Status = importSucceeded
+			report.Imported++
+		} else {
+			report.Failed++
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	status := http.StatusOK
+	switch {
+	case report.Imported == 0:
+		status = http.StatusUnprocessableEntity
+	case report.Failed > 0:
+		status = http.StatusMultiStatus
+	}
+	writeJSON(w, r, status, report)
+}
+
+// importFile returns the uploaded file and its format.
+func importFile(r *http.Request) ([]byte, string, error) {
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mt != "multipart/form-data" {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, "", errors.New("can't read import file")
+		}
+		return data, importFormat(mt, ""), nil
+	}
+	f, header, err := r.FormFile("file")
+	if err != nil {
+		return nil, "", errors.New("missing import file")
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, "", errors.New("can't read import file")
+	}
+	partType, _, _ := mime.ParseMediaType(header.Header.Get("Content-Type"))
+	return data, importFormat(partType, header.Filename), nil
+}
+
+// importFormat returns the format of the file
+// given its media type or name.
+func importFormat(mediaType, filename string) string {
+	switch mediaType {
+	case jsonContentType:
+		return "json"
+	case csvContentType:
+		return "csv"
+	}
+	return strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
+}
+
+// decodeJSONRows decodes products from a JSON array.
+// Malformed products are reported as problems of their rows.
+func decodeJSONRows(data []byte) ([]importRow, error) {
+	var raws []json.RawMessage
+	if err := json.Unmarshal(data, &raws); err != nil {
+		return nil, errors.New("import file must hold a JSON array of products")
+	}
+	rows := make([]importRow, 0, len(raws))
+	for _, raw := range raws {
+		var row importRow
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&row.product); err != nil {
+			row.problems = append(row.problems, err.Error())
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// decodeCSVRows decodes products from CSV in the format
+// written by EncodeCSV. Columns are matched by the names
+// in the header row and may appear in any order.
+func decodeCSVRows(data []byte) ([]importRow, error) {
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, errors.New("import file must start with a CSV header")
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		known := false
+		for _, c := range csvHeader {
+			known = known || c == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown CSV column %q", name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["id"]; !ok {
+		return nil, errors.New("missing CSV column \"id\"")
+	}
+
+	var rows []importRow
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, productFromCSV(record, header, columns))
+	}
+}
+
+func productFromCSV(record, header []string, columns map[string]int) importRow {
+	var row importRow
+	if len(record) != len(header) {
+		row.problems = append(row.problems, fmt.Sprintf("want %d fields, got %d", len(header), len(record)))
+		return row
+	}
+	field := func(name string) string {
+		if i, ok := columns[name]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	p := Product{
+		ID:    field("id"),
+		Type:  field("type"),
+		Brand: field("brand"),
+		Name:  field("name"),
+		SKU:   field("sku"),
+		EAN:   field("ean"),
+		Unit:  ParseUnit(field("unit")),
+	}
+	quantity, err := ParseQuantity(field("quantity"))
+	if err != nil {
+		row.problems = append(row.problems, err.Error())
+	}
+	p.Quantity = quantity
+	currency := field("currency")
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	if price := field("price"); price != "" {
+		m, err := ParseMoney(price, currency)
+		if err != nil {
+			row.problems = append(row.problems, err.Error())
+		}
+		p.Price = m
+	}
+	if stock := field("stock"); stock != "" {
+		n, err := strconv.Atoi(stock)
+		if err != nil {
+			row.problems = append(row.problems, fmt.Sprintf("invalid stock %q", stock))
+		}
+		p.Stock = n
+	}
+	if props := field("properties"); props != "" {
+		for _, prop := range strings.Split(props, ";") {
+			name, value, _ := strings.Cut(prop, "=")
+			p.Properties = append(p.Properties, Property{Name: name, Value: value})
+		}
+	}
+	if intensity := field("intensity"); intensity != "" {
+		n, err := strconv.Atoi(intensity)
+		if err != nil {
+			row.problems = append(row.problems, fmt.Sprintf("invalid intensity %q", intensity))
+		}
+		p.Intensity = n
+	}
+	if flavours := field("flavours"); flavours != "" {
+		for _, f := range strings.Split(flavours, ";") {
+			p.Flavours = append(p.Flavours, strings.TrimSpace(f))
+		}
+	}
+	row.product = p
+	return row
+}
@@ -0,0 +1,155 @@
+package coffeeshop_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+func uploadImportFile(t *testing.T, url, filename, content string) *http.Response {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fw.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(url, mw.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func decodeImportReport(t *testing.T, resp *http.Response) coffeeshop.ImportReport {
+	t.Helper()
+	defer resp.Body.Close()
+	var report coffeeshop.ImportReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func TestServer_ImportsProductsFromCSVFile(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{}
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	csv := "id,type,brand,name,price,currency,stock,properties\n" +
+		"1,Coffee,illy,Intenso,7.99,,5,flavour=Cocoa;intensity=9\n" +
+		"2,Tea,Caykur,Green Tea,4.99,USD,10,\n"
+	resp := uploadImportFile(t, shop.URL+"products/import", "products.csv", csv)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200OK, got %d", resp.StatusCode)
+	}
+	report := decodeImportReport(t, resp)
+	if report.Imported != 2 || report.Failed != 0 {
+		t.Errorf("want 2 imported products, got %+v", report)
+	}
+
+	want := []coffeeshop.Product{
+		{
+			ID: "1", Type: "Coffee", Brand: "illy", Name: "Intenso",
+			Price: coffeeshop.Money{Amount: 799, Currency: "EUR"}, Stock: 5,
+			Properties: []coffeeshop.Property{{Name: "flavour", Value: "Cocoa"}, {Name: "intensity", Value: "9"}},
+			Version:    1,
+		},
+		{
+			ID: "2", Type: "Tea", Brand: "Caykur", Name: "Green Tea",
+			Price: coffeeshop.Money{Amount: 499, Currency: "USD"}, Stock: 10, Version: 1,
+		},
+	}
+	got := store.GetAll()
+	for i := range got {
+		got[i].CreatedAt, got[i].UpdatedAt = nil, nil
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestServer_ReportsPartialImportOfJSONFile(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{}
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	data := `[
+		{"id": "1", "type": "Coffee", "name": "Intenso", "price": "7.99"},
+		{"id": "2", "type": "Coffee", "price": "4.99"},
+		{"id": "1", "type": "Coffee", "name": "Duplicate"},
+		{"id": "3", "type": "Tea", "name": "Green", "colour": "green"}
+	]`
+	resp := uploadImportFile(t, shop.URL+"products/import", "products.json", data)
+	if resp.StatusCode != http.StatusMultiStatus {
+		t.Fatalf("want HTTP 207, got %d", resp.StatusCode)
+	}
+	got := decodeImportReport(t, resp)
+	want := coffeeshop.ImportReport{
+		Imported: 1,
+		Failed:   3,
+		Results: []coffeeshop.ImportResult{
+			{Row: 1, ID: "1", Status: "imported"},
+			{Row: 2, ID: "2", Status: "failed", Errors: []string{"missing name"}},
+			{Row: 3, ID: "1", Status: "failed", Errors: []string{"duplicate id"}},
+			{Row: 4, ID: "3", Status: "failed", Errors: []string{`json: unknown field "colour"`}},
+		},
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+	if len(store.GetAll()) != 1 {
+		t.Errorf("want 1 product in store, got %d", len(store.GetAll()))
+	}
+}
+
+func TestServer_ImportsProductsFromRequestBody(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{}
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp, err := http.Post(shop.URL+"products/import", "text/csv", strings.NewReader("id,type,name\n1,Coffee,Intenso\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := decodeImportReport(t, resp)
+	if resp.StatusCode != http.StatusOK || report.Imported != 1 {
+		t.Errorf("want 1 imported product, got %d %+v", resp.StatusCode, report)
+	}
+}
+
+func TestServer_RejectsInvalidImportFiles(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{}
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	tests := []struct {
+		filename, content string
+		want              int
+	}{
+		{"products.csv", "id,colour\n1,brown\n", http.StatusBadRequest},
+		{"products.json", `{"id": "1"}`, http.StatusBadRequest},
+		{"products.json", `[]`, http.StatusBadRequest},
+		{"products.xml", "<products/>", http.StatusUnsupportedMediaType},
+		{"products.csv", "id,type,name\n1,Coffee,\n", http.StatusUnprocessableEntity},
+	}
+	for _, tc := range tests {
+		resp := uploadImportFile(t, shop.URL+"products/import", tc.filename, tc.content)
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s %q: want HTTP %d, got %d", tc.filename, tc.content, tc.want, resp.StatusCode)
+		}
+	}
+}
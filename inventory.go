@@ -0,0 +1,328 @@
+package coffeeshop
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LoadInventory reads products from the JSON or YAML file. JSON
+// files hold an array of products or an object keyed by product ID.
+// YAML files hold a sequence of products. The file format is chosen
+// by the file extension: .json, .yaml or .yml.
+//
+// Products are validated after loading. The error lists all
+// malformed entries with their position in the file.
+func LoadInventory(path string) (Products, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var px []Product
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		px, err = decodeJSONInventory(data)
+	case ".yaml", ".yml":
+		px, err = decodeYAMLInventory(data)
+	default:
+		return nil, fmt.Errorf("%s: unsupported inventory format %q", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	products, err := validateInventory(px)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return products, nil
+}
+
+// WithInventoryFile replaces products in the store, which must
+// be a Resetter, with products loaded from the file by LoadInventory.
+func WithInventoryFile(path string) Option {
+	return func(s *Server) error {
+		r, ok := storeAs[Resetter](s.Store)
+		if !ok {
+			return fmt.Errorf("inventory file requires a store able to reset products, got %T", s.Store)
+		}
+		products, err := LoadInventory(path)
+		if err != nil {
+			return err
+		}
+		r.ResetProducts(products)
+		return nil
+	}
+}
+
+// decodeJSONInventory decodes products one by one
+// rejecting unknown fields.
+func decodeJSONInventory(data []byte) ([]Product, error) {
+	decodeProduct := func(raw json.RawMessage) (Product, error) {
+		var p Product
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&p)
+		return p, err
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return nil, fmt.Errorf("line %d: %w", lineOf(data, syntaxErr.Offset), err)
+		}
+		return nil, err
+	}
+	var px []Product
+	var errs []error
+	switch v.(type) {
+	case []any:
+		var raws []json.RawMessage
+		if err := json.Unmarshal(data, &raws); err != nil {
+			return nil, err
+		}
+		for i, raw := range raws {
+			p, err := decodeProduct(raw)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("product %d: %w", i+1, err))
+				continue
+			}
+			px = append(px, p)
+		}
+	case map[string]any:
+		var raws map[string]json.RawMessage
+		if err := json.Unmarshal(data, &raws); err != nil {
+			return nil, err
+		}
+		for id, raw := range raws {
+			p, err := decodeProduct(raw)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("product %q: %w", id, err))
+				continue
+			}
+			if p.ID == "" {
+				p.ID = id
+			}
+			if p.ID != id {
+				errs = append(errs, fmt.Errorf("product %q: id %q doesn't match the key", id, p.ID))
+				continue
+			}
+			px = append(px, p)
+		}
+		sortProducts(px)
+	default:
+		return nil, errors.New("inventory must be an array or an object of products")
+	}
+	return px, errors.Join(errs...)
+}
+
+// lineOf returns the line number of the byte offset.
+func lineOf(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+func decodeYAMLInventory(data []byte) ([]Product, error) {
+	doc, err := parseYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	if doc.kind != yamlSequence {
+		return nil, fmt.Errorf("line %d: inventory must be a sequence of products", doc.line)
+	}
+	var px []Product
+	var errs []error
+	for _, item := range doc.items {
+		p, err := productFromYAML(item)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		px = append(px, p)
+	}
+	return px, errors.Join(errs...)
+}
+
+func productFromYAML(n *yamlNode) (Product, error) {
+	if n.kind != yamlMapping {
+		return Product{}, fmt.Errorf("line %d: product must be a mapping", n.line)
+	}
+	var p Product
+	for i, key := range n.keys {
+		v := n.values[i]
+		var err error
+		switch key {
+		case "id":
+			p.ID, err = yamlString(v)
+		case "type":
+			p.Type, err = yamlString(v)
+		case "brand":
+			p.Brand, err = yamlString(v)
+		case "name":
+			p.Name, err = yamlString(v)
+		case "sku":
+			p.SKU, err = yamlString(v)
+		case "ean":
+			p.EAN, err = yamlString(v)
+		case "unit":
+			var unit string
+			unit, err = yamlString(v)
+			p.Unit = ParseUnit(unit)
+		case "quantity":
+			var quantity int
+			quantity, err = yamlInt(v)
+			p.Quantity = Quantity(quantity)
+		case "price":
+			p.Price, err = yamlMoney(v)
+		case "stock":
+			p.Stock, err = yamlInt(v)
+		case "properties":
+			p.Properties, err = yamlProperties(v)
+		case "intensity":
+			p.Intensity, err = yamlInt(v)
+		case "flavours":
+			p.Flavours, err = yamlStrings(v)
+		case "descriptions":
+			p.Descriptions, err = yamlDescriptions(v)
+		default:
+			err = fmt.Errorf("line %d: unknown field %q", v.line, key)
+		}
+		if err != nil {
+			return Product{}, err
+		}
+	}
+	return p, nil
+}
+
+func yamlString(n *yamlNode) (string, error) {
+	if n.kind != yamlScalar {
+		return "", fmt.Errorf("line %d: want string", n.line)
+	}
+	return n.value, nil
+}
+
+func yamlInt(n *yamlNode) (int, error) {
+	s, err := yamlString(n)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("line %d: want integer, got %q", n.line, s)
+	}
+	return i, nil
+}
+
+// yamlMoney decodes money either from the "7.99" format
+// or from a mapping with amount in minor units and currency.
+func yamlMoney(n *yamlNode) (Money, error) {
+	if n.kind == yamlScalar {
+		m, err := ParseMoney(n.value, DefaultCurrency)
+		if err != nil {
+			return Money{}, fmt.Errorf("line %d: %w", n.line, err)
+		}
+		return m, nil
+	}
+	if n.kind != yamlMapping {
+		return Money{}, fmt.Errorf("line %d: want price", n.line)
+	}
+	var m Money
+	for i, key := range n.keys {
+		v := n.values[i]
+		switch key {
+		case "amount":
+			s, err := yamlString(v)
+			if err != nil {
+				return Money{}, err
+			}
+			m.Amount, err = strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return Money{}, fmt.Errorf("line %d: want amount in minor units, got %q", v.line, s)
+			}
+		case "currency":
+			s, err := yamlString(v)
+			if err != nil {
+				return Money{}, err
+			}
+			m.Currency = s
+		default:
+			return Money{}, fmt.Errorf("line %d: unknown field %q", v.line, key)
+		}
+	}
+	return m, nil
+}
+
+func yamlDescriptions(n *yamlNode) (map[string]string, error) {
+	if n.kind != yamlMapping {
+		return nil, fmt.Errorf("line %d: want mapping of descriptions by language", n.line)
+	}
+	descriptions := make(map[string]string, len(n.keys))
+	for i, lang := range n.keys {
+		d, err := yamlString(n.values[i])
+		if err != nil {
+			return nil, err
+		}
+		descriptions[lang] = d
+	}
+	return descriptions, nil
+}
+
+func yamlProperties(n *yamlNode) ([]Property, error) {
+	if n.kind != yamlSequence {
+		return nil, fmt.Errorf("line %d: want sequence of properties", n.line)
+	}
+	var props []Property
+	for _, item := range n.items {
+		if item.kind != yamlMapping {
+			return nil, fmt.Errorf("line %d: property must be a mapping", item.line)
+		}
+		var prop Property
+		for i, key := range item.keys {
+			var err error
+			switch key {
+			case "name":
+				prop.Name, err = yamlString(item.values[i])
+			case "value":
+				prop.Value, err = yamlString(item.values[i])
+			default:
+				err = fmt.Errorf("line %d: unknown field %q", item.values[i].line, key)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		props = append(props, prop)
+	}
+	return props, nil
+}
+
+// validateInventory checks the products and returns
+// them keyed by ID. Prices without currency are in
+// the default currency.
+func validateInventory(px []Product) (Products, error) {
+	products := make(Products, len(px))
+	var errs []error
+	for i, p := range px {
+		problems := validateProduct(p)
+		if _, dup := products[p.ID]; dup && p.ID != "" {
+			problems = append(problems, "duplicate id")
+		}
+		for _, problem := range problems {
+			errs = append(errs, fmt.Errorf("product %d (id %q): %s", i+1, p.ID, problem))
+		}
+		if p.Price.Currency == "" {
+			p.Price.Currency = DefaultCurrency
+		}
+		products[p.ID] = p
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
@@ -0,0 +1,170 @@
+package coffeeshop_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+func writeInventoryFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+var loadedInventory = coffeeshop.Products{
+	"1": {
+		ID:       "1",
+		Type:     "Coffee",
+		Brand:    "illy",
+		Name:     "Intenso",
+		Unit:     coffeeshop.Gram,
+		Quantity: 250,
+		Price:    coffeeshop.Money{Amount: 799, Currency: "EUR"},
+		Stock:    5,
+		Properties: []coffeeshop.Property{
+			{Name: "flavour", Value: "Cocoa, Dried fruit"},
+			{Name: "intensity", Value: ""},
+		},
+	},
+	"2": {
+		ID:    "2",
+		Type:  "Tea",
+		Name:  "Green Tea",
+		Price: coffeeshop.Money{Amount: 499, Currency: "USD"},
+	},
+}
+
+func TestLoadInventory_ReadsJSONFile(t *testing.T) {
+	t.Parallel()
+
+	path := writeInventoryFile(t, "inventory.json", `[
+  {"id": "1", "type": "Coffee", "brand": "illy", "name": "Intenso", "unit": "gram", "quantity": "250",
+   "price": "7.99", "stock": 5,
+   "properties": [{"name": "flavour", "value": "Cocoa, Dried fruit"}, {"name": "intensity", "value": ""}]},
+  {"id": "2", "type": "Tea", "name": "Green Tea", "price": {"amount": 499, "currency": "USD"}}
+]`)
+	got, err := coffeeshop.LoadInventory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(loadedInventory, got) {
+		t.Error(cmp.Diff(loadedInventory, got))
+	}
+}
+
+func TestLoadInventory_ReadsYAMLFile(t *testing.T) {
+	t.Parallel()
+
+	path := writeInventoryFile(t, "inventory.yaml", `# Demo catalog
+- id: "1"
+  type: Coffee
+  brand: illy
+  name: Intenso
+  unit: gram
+  quantity: 250
+  price: 7.99
+  stock: 5
+  properties:
+  - name: flavour
+    value: Cocoa, Dried fruit # tasting notes
+  - name: intensity
+    value: ""
+
+- id: 2
+  type: Tea
+  name: 'Green Tea'
+  price:
+    amount: 499
+    currency: USD
+`)
+	got, err := coffeeshop.LoadInventory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(loadedInventory, got) {
+		t.Error(cmp.Diff(loadedInventory, got))
+	}
+}
+
+func TestLoadInventory_ReportsMalformedEntries(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name, content string
+		want          []string
+	}{
+		{
+			name:    "unknown.json",
+			content: `[{"id": "1", "type": "Coffee", "name": "Intenso", "colour": "brown"}]`,
+			want:    []string{`product 1`, `unknown field "colour"`},
+		},
+		{
+			name:    "syntax.json",
+			content: "[\n{\"id\": \"1\",}\n]",
+			want:    []string{"line 2"},
+		},
+		{
+			name:    "invalid.json",
+			content: `[{"id": "1", "type": "Coffee"}, {"id": "1", "name": "Duplicate", "stock": -1}]`,
+			want:    []string{`product 1 (id "1"): missing name`, "duplicate id", "missing type", "negative stock -1"},
+		},
+		{
+			name:    "unknown.yaml",
+			content: "- id: 1\n  type: Coffee\n  name: Intenso\n  colour: brown\n",
+			want:    []string{`line 4: unknown field "colour"`},
+		},
+		{
+			name:    "price.yml",
+			content: "- id: 1\n  type: Coffee\n  name: Intenso\n  price: cheap\n",
+			want:    []string{`line 4: invalid amount "cheap"`},
+		},
+		{
+			name:    "indent.yaml",
+			content: "- id: 1\n    type: Coffee\n",
+			want:    []string{"line 2"},
+		},
+		{
+			name:    "inventory.toml",
+			content: "",
+			want:    []string{`unsupported inventory format ".toml"`},
+		},
+	}
+	for _, tc := range tests {
+		_, err := coffeeshop.LoadInventory(writeInventoryFile(t, tc.name, tc.content))
+		if err == nil {
+			t.Errorf("%s: want error", tc.name)
+			continue
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: want error containing %q, got %q", tc.name, want, err)
+			}
+		}
+	}
+}
+
+func TestWithInventoryFile_ReplacesProductsInMemoryStore(t *testing.T) {
+	t.Parallel()
+
+	path := writeInventoryFile(t, "inventory.yml", "- id: 7\n  type: Cocoa\n  name: Dark\n  price: 3.50\n")
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+	_, err := coffeeshop.New(":0", store, coffeeshop.WithInventoryFile(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []coffeeshop.Product{{ID: "7", Type: "Cocoa", Name: "Dark", Price: coffeeshop.Money{Amount: 350, Currency: "EUR"}}}
+	got := store.GetAll()
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
@@ -0,0 +1,51 @@
+package coffeeshop
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Header and limit of latency requested by clients.
+const (
+	delayHeader      = "X-Coffeeshop-Delay"
+	maxHeaderLatency = 2 * time.Minute
+)
+
+// WithHeaderLatency lets clients request the latency of a single
+// request with the X-Coffeeshop-Delay header, for example "3s",
+// instead of the configured latency. It applies to delayed route
+// groups only and is limited to two minutes.
+func WithHeaderLatency() Option {
+	return func(s *Server) error {
+		s.HeaderLatency = true
+		return nil
+	}
+}
+
+// delay holds requests for the latency requested in the
+// X-Coffeeshop-Delay header or the configured latency.
+func (cs *Server) delay(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		h := r.Header.Get(delayHeader)
+		if h == "" {
+			time.Sleep(cs.currentLatency())
+			next.ServeHTTP(w, r)
+			return
+		}
+		d, err := time.ParseDuration(h)
+		if err != nil || d < 0 || d > maxHeaderLatency {
+			writeError(w, r, http.StatusBadRequest, fmt.Sprintf("invalid %s header %q", delayHeader, h))
+			return
+		}
+		t := time.NewTimer(d)
+		select {
+		case <-r.Context().Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,62 @@
+package coffeeshop_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// getWithDelay requests the URL with the X-Coffeeshop-Delay header
+// and returns the response and how long it took.
+func getWithDelay(t *testing.T, url, delay string) (*http.Response, time.Duration) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Coffeeshop-Delay", delay)
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp, time.Since(start)
+}
+
+func TestServer_DelaysRequestsByHeaderLatency(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "1ms", t,
+		coffeeshop.WithHeaderLatency(),
+	)
+	resp, elapsed := getWithDelay(t, shop.URL+"products", "300ms")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200, got %d", resp.StatusCode)
+	}
+	if elapsed < 300*time.Millisecond {
+		t.Errorf("want response after at least 300ms, got %v", elapsed)
+	}
+
+	for _, delay := range []string{"soon", "-1s", "1h"} {
+		resp, _ := getWithDelay(t, shop.URL+"products", delay)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: want HTTP 400, got %d", delay, resp.StatusCode)
+		}
+	}
+}
+
+func TestServer_IgnoresDelayHeaderByDefault(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "1ms", t)
+	resp, elapsed := getWithDelay(t, shop.URL+"products", "5s")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200, got %d", resp.StatusCode)
+	}
+	if elapsed >= 5*time.Second {
+		t.Errorf("want delay header ignored, got response after %v", elapsed)
+	}
+}
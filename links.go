@@ -0,0 +1,92 @@
+package coffeeshop
+
+import (
+	"net/http"
+	"strings"
+)
+
+// WithLinks adds hypermedia links to related resources, under
+// the '_links' field, to JSON representations of products. Links
+// are absolute URLs derived from the host the client requested,
+// or the Forwarded and X-Forwarded-Proto/Host headers of proxies
+// trusted with WithTrustedProxies.
+func WithLinks() Option {
+	return func(s *Server) error {
+		s.Links = true
+		return nil
+	}
+}
+
+// productLinks returns links to the product and its related
+// resources, relative to the base URL. The image link is
+// included regardless of whether the product has an image.
+func productLinks(base string, p Product) map[string]halLink {
+	self := base + productPath(p)
+	return map[string]halLink{
+		"self":     {Href: self},
+		"category": {Href: base + categoryPath(p)},
+		"image":    {Href: self + "/image"},
+		"related":  {Href: self + "/related"},
+		"reviews":  {Href: self + "/reviews"},
+	}
+}
+
+// baseURL returns the scheme, the host and the tenant prefix the
+// client used to reach the server, for example
+// "https://shop.example.com" or "https://shop.example.com/tenants/a".
+func baseURL(r *http.Request) string {
+	return origin(r) + resourcePath(r, "")
+}
+
+// origin returns the scheme and the host the client used
+// to reach the server. Forwarded headers of requests from
+// peers other than trusted proxies are removed by realIP
+// before, so clients can't spoof them.
+func origin(r *http.Request) string {
+	scheme, host := "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto, fhost := forwarded(r.Header.Get("Forwarded")); proto != "" || fhost != "" {
+		if proto != "" {
+			scheme = proto
+		}
+		if fhost != "" {
+			host = fhost
+		}
+		return scheme + "://" + host
+	}
+	if proto := firstValue(r.Header.Get("X-Forwarded-Proto")); proto != "" {
+		scheme = proto
+	}
+	if fhost := firstValue(r.Header.Get("X-Forwarded-Host")); fhost != "" {
+		host = fhost
+	}
+	return scheme + "://" + host
+}
+
+// forwarded returns the protocol and the host of the first,
+// client facing, element of the Forwarded header (RFC 7239).
+func forwarded(header string) (proto, host string) {
+	first, _, _ := strings.Cut(header, ",")
+	for _, pair := range strings.Split(first, ";") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(value, `"`)
+		switch strings.ToLower(name) {
+		case "proto":
+			proto = strings.ToLower(value)
+		case "host":
+			host = value
+		}
+	}
+	return proto, host
+}
+
+// firstValue returns the first of comma separated header values.
+func firstValue(header string) string {
+	first, _, _ := strings.Cut(header, ",")
+	return strings.TrimSpace(first)
+}
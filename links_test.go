@@ -0,0 +1,91 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+type links map[string]struct {
+	Href string `json:"href"`
+}
+
+// getLinks returns links of the product requested with the headers.
+func getLinks(t *testing.T, url string, header map[string]string) links {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, v := range header {
+		req.Header.Set(name, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got struct {
+		Links links `json:"_links"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	return got.Links
+}
+
+func TestServer_AddsLinksToProducts(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t, coffeeshop.WithLinks())
+	got := getLinks(t, shop.URL+"products/3", nil)
+	base := shop.URL[:len(shop.URL)-1]
+	want := map[string]string{
+		"self":     base + "/products/3",
+		"category": base + "/categories/coffee/products",
+		"image":    base + "/products/3/image",
+		"related":  base + "/products/3/related",
+		"reviews":  base + "/products/3/reviews",
+	}
+	gotHrefs := make(map[string]string, len(got))
+	for rel, l := range got {
+		gotHrefs[rel] = l.Href
+	}
+	if !cmp.Equal(want, gotHrefs) {
+		t.Error(cmp.Diff(want, gotHrefs))
+	}
+}
+
+func TestServer_DerivesLinksFromForwardedHeaders(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t,
+		coffeeshop.WithLinks(),
+		coffeeshop.WithTrustedProxies("127.0.0.1"),
+	)
+	tests := []struct {
+		name   string
+		header map[string]string
+	}{
+		{name: "Forwarded", header: map[string]string{"Forwarded": `for=192.0.2.60;proto=https;host="shop.example.com", for=10.0.0.1`}},
+		{name: "X-Forwarded", header: map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "shop.example.com, proxy.internal"}},
+	}
+	for _, tc := range tests {
+		got := getLinks(t, shop.URL+"products/3", tc.header)
+		if got["self"].Href != "https://shop.example.com/products/3" {
+			t.Errorf("%s: want self link on the forwarded host, got %q", tc.name, got["self"].Href)
+		}
+	}
+}
+
+func TestServer_OmitsLinksByDefault(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t)
+	if got := getLinks(t, shop.URL+"products/3", nil); got != nil {
+		t.Errorf("want no links, got %v", got)
+	}
+}
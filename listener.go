@@ -0,0 +1,57 @@
+package coffeeshop
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+// WithListener makes the server accept connections on the listener
+// instead of binding the address passed to New, for example a Unix
+// domain socket listener in environments without TCP ports. The URL
+// of the server holds the address of the listener.
+func WithListener(l net.Listener) Option {
+	return func(s *Server) error {
+		if l == nil {
+			return errors.New("nil listener")
+		}
+		s.listener = l
+		s.HTTPServer.Addr = listenerAddr(l)
+		scheme, _, _ := strings.Cut(s.URL, "://")
+		s.URL = serverURL(scheme, s.HTTPServer.Addr)
+		return nil
+	}
+}
+
+// splitAddr returns the network and the address of the server
+// address. Addresses are TCP addresses like ":8080" or "[::1]:8080",
+// bound to both IPv4 and IPv6 when the host is empty, TCP addresses
+// limited to one IP version like "tcp4://:8080" or "tcp6://:8080",
+// or Unix domain socket paths like "unix:///run/coffeeshop.sock".
+func splitAddr(addr string) (network, address string) {
+	for _, network := range []string{"unix", "tcp4", "tcp6"} {
+		if address, ok := strings.CutPrefix(addr, network+"://"); ok {
+			return network, address
+		}
+	}
+	return "tcp", addr
+}
+
+// listenerAddr returns the server address of the listener.
+func listenerAddr(l net.Listener) string {
+	if a := l.Addr(); a.Network() == "unix" {
+		return "unix://" + a.String()
+	}
+	return l.Addr().String()
+}
+
+// serverURL returns the base URL of the server listening on the
+// address. Servers on Unix domain sockets have URLs like
+// "unix:///run/coffeeshop.sock/", which the client package accepts.
+func serverURL(scheme, addr string) string {
+	network, address := splitAddr(addr)
+	if network == "unix" {
+		return "unix://" + address + "/"
+	}
+	return scheme + "://" + address + "/"
+}
@@ -0,0 +1,119 @@
+package coffeeshop_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qba73/coffeeshop"
+	"github.com/qba73/coffeeshop/client"
+)
+
+// serve starts the server and shuts it down when the test completes.
+func serve(t *testing.T, cs *coffeeshop.Server) {
+	t.Helper()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- cs.ListenAndServe()
+	}()
+	t.Cleanup(func() {
+		if err := cs.Shutdown(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+			t.Error(err)
+		}
+	})
+}
+
+// getProducts gets products from the server with the client package.
+func getProducts(t *testing.T, url string) []coffeeshop.Product {
+	t.Helper()
+
+	c, err := client.New(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	products, err := c.GetProducts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return products
+}
+
+func TestServer_ServesRequestsOnUnixSocketListener(t *testing.T) {
+	t.Parallel()
+
+	socket := filepath.Join(t.TempDir(), "shop.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs, err := coffeeshop.New(":0", &coffeeshop.MemoryStore{Products: inventory},
+		coffeeshop.WithLatency("0s"),
+		coffeeshop.WithListener(l),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "unix://" + socket + "/"; cs.URL != want {
+		t.Errorf("want URL %q, got %q", want, cs.URL)
+	}
+	serve(t, cs)
+	if got := len(getProducts(t, cs.URL)); got != len(inventory) {
+		t.Errorf("want %d products, got %d", len(inventory), got)
+	}
+}
+
+func TestServer_ListensOnUnixSocketAddress(t *testing.T) {
+	t.Parallel()
+
+	socket := filepath.Join(t.TempDir(), "shop.sock")
+	cs, err := coffeeshop.New("unix://"+socket, &coffeeshop.MemoryStore{Products: inventory},
+		coffeeshop.WithLatency("0s"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve(t, cs)
+	c, err := client.New(cs.URL, client.WithRetries(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var products []coffeeshop.Product
+	for i := 0; i < 100 && products == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+		products, _ = c.GetProducts(context.Background())
+	}
+	if len(products) != len(inventory) {
+		t.Errorf("want %d products, got %d", len(inventory), len(products))
+	}
+}
+
+func TestServer_ListensOnIPv6Address(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 unavailable: %v", err)
+	}
+	cs, err := coffeeshop.NewWithListener(l, &coffeeshop.MemoryStore{Products: inventory},
+		coffeeshop.WithLatency("0s"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(cs.URL, "http://[::1]:") {
+		t.Errorf("want IPv6 URL, got %q", cs.URL)
+	}
+	serve(t, cs)
+	if got := len(getProducts(t, cs.URL)); got != len(inventory) {
+		t.Errorf("want %d products, got %d", len(inventory), got)
+	}
+}
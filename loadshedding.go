@@ -0,0 +1,88 @@
+package coffeeshop
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultRetryAfter is how long clients of an overloaded
+// server are asked to wait before retrying.
+const DefaultRetryAfter = time.Second
+
+// WithMaxConcurrentRequests limits the number of requests served at
+// the same time, including requests held by the simulated latency.
+// Requests over the limit are shed with 503 Service Unavailable
+// and a Retry-After header. Health probes are never shed, and
+// event streams and WebSockets, which stay open while clients
+// follow them, don't count as requests in flight.
+func WithMaxConcurrentRequests(n int) Option {
+	return func(s *Server) error {
+		if n <= 0 {
+			return errors.New("max concurrent requests must be positive")
+		}
+		s.MaxConcurrentRequests = n
+		return nil
+	}
+}
+
+// WithRetryAfter configures the Retry-After header of shed requests.
+func WithRetryAfter(d string) Option {
+	return func(s *Server) error {
+		t, err := time.ParseDuration(d)
+		if err != nil {
+			return err
+		}
+		if t < time.Second {
+			return errors.New("retry after must be at least a second")
+		}
+		s.RetryAfter = t
+		return nil
+	}
+}
+
+// requestSlots holds a slot for each request in flight.
+type requestSlots struct {
+	once  sync.Once
+	slots chan struct{}
+}
+
+// acquire takes a slot, if any of the n slots is free.
+func (rs *requestSlots) acquire(n int) bool {
+	rs.once.Do(func() {
+		rs.slots = make(chan struct{}, n)
+	})
+	select {
+	case rs.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (rs *requestSlots) release() {
+	<-rs.slots
+}
+
+// shedLoad responds with 503 Service Unavailable to requests
+// over the configured number of concurrent requests. Streams
+// don't take slots, so followers can't starve other requests.
+func (cs *Server) shedLoad(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if streamingRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !cs.inFlight.acquire(cs.MaxConcurrentRequests) {
+			seconds := int((cs.RetryAfter + time.Second - 1) / time.Second)
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			writeError(w, r, http.StatusServiceUnavailable, "too many concurrent requests")
+			return
+		}
+		defer cs.inFlight.release()
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,124 @@
+package coffeeshop_test
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/qba73/coffeeshop"
+)
+
+func TestServer_ShedsRequestsOverConcurrencyLimit(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "300ms", t,
+		coffeeshop.WithMaxConcurrentRequests(1),
+		coffeeshop.WithRetryAfter("2s"),
+	)
+
+	statuses := make(chan *http.Response, 2)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Get(shop.URL + "products")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+			statuses <- resp
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	var ok, shed int
+	for resp := range statuses {
+		switch resp.StatusCode {
+		case http.StatusOK:
+			ok++
+		case http.StatusServiceUnavailable:
+			shed++
+			if got := resp.Header.Get("Retry-After"); got != "2" {
+				t.Errorf("want Retry-After 2, got %q", got)
+			}
+		default:
+			t.Errorf("want HTTP 200 or 503, got %d", resp.StatusCode)
+		}
+	}
+	if ok != 1 || shed != 1 {
+		t.Errorf("want one request served and one shed, got %d served and %d shed", ok, shed)
+	}
+
+	resp, err := http.Get(shop.URL + "products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("want HTTP 200 after requests finished, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_DoesNotShedHealthProbes(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "300ms", t,
+		coffeeshop.WithMaxConcurrentRequests(1),
+	)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		resp, err := http.Get(shop.URL + "products")
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	defer func() { <-done }()
+
+	resp, err := http.Get(shop.URL + "healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("want HTTP 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestWithMaxConcurrentRequests_RejectsNonPositiveLimit(t *testing.T) {
+	t.Parallel()
+
+	if _, err := coffeeshop.New("localhost:0", &coffeeshop.MemoryStore{}, coffeeshop.WithMaxConcurrentRequests(0)); err == nil {
+		t.Error("want error for zero limit")
+	}
+}
+
+func TestServer_DoesNotCountEventStreamsAsRequestsInFlight(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t,
+		coffeeshop.WithMaxConcurrentRequests(1),
+	)
+	for i := 0; i < 2; i++ {
+		stream, err := http.Get(shop.URL + "events")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stream.Body.Close()
+		if stream.StatusCode != http.StatusOK {
+			t.Fatalf("want HTTP 200 for event stream, got %d", stream.StatusCode)
+		}
+	}
+
+	resp, err := http.Get(shop.URL + "products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("want HTTP 200 while event streams are open, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,74 @@
+package coffeeshop
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// metricsContentType is the media type of the Prometheus
+// text exposition format.
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// writeMetric writes the metric with a single sample
+// in the Prometheus text exposition format.
+func writeMetric(w io.Writer, name, kind, help string, value any) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, value)
+}
+
+// GetMetrics serves metrics of the event log and event streams, and
+// of the circuit breaker, retries, tiers and the cache of the store,
+// if enabled, in the Prometheus text format.
+func (cs *Server) GetMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", metricsContentType)
+	failed, _ := cs.eventLog.failures()
+	writeMetric(w, "coffeeshop_event_log_append_failures_total", "counter", "Events that couldn't be appended to the event log.", failed)
+	const dropped = "coffeeshop_events_dropped_total"
+	fmt.Fprintf(w, "# HELP %s Events missed by subscribers falling behind, like event streams and webhooks.\n# TYPE %s counter\n", dropped, dropped)
+	fmt.Fprintf(w, "%s{source=%q} %d\n", dropped, "orders", cs.orderEvents.Dropped())
+	if c, ok := storeAs[eventDropCounter](cs.Store); ok {
+		fmt.Fprintf(w, "%s{source=%q} %d\n", dropped, "products", c.DroppedEvents())
+	}
+	if b := cs.breaker; b != nil {
+		stats := b.Stats()
+		const name = "coffeeshop_store_breaker_state"
+		fmt.Fprintf(w, "# HELP %s State of the circuit breaker of the store, 1 for the current state.\n# TYPE %s gauge\n", name, name)
+		for _, state := range []BreakerState{BreakerClosed, BreakerOpen, BreakerHalfOpen} {
+			value := 0
+			if state == stats.State {
+				value = 1
+			}
+			fmt.Fprintf(w, "%s{state=%q} %d\n", name, state, value)
+		}
+		writeMetric(w, "coffeeshop_store_breaker_opened_total", "counter", "Times the circuit breaker of the store opened.", stats.Opened)
+		writeMetric(w, "coffeeshop_store_breaker_rejected_total", "counter", "Store calls rejected by the circuit breaker.", stats.Rejected)
+	}
+	if rs := cs.retrying; rs != nil {
+		writeMetric(w, "coffeeshop_store_retries_total", "counter", "Store calls retried after transient errors.", rs.Retries())
+	}
+	if ts := cs.tieredStore(); ts != nil {
+		stats := ts.Stats()
+		const name = "coffeeshop_store_tier_reads_total"
+		fmt.Fprintf(w, "# HELP %s Reads of products served by tiers of the store.\n# TYPE %s counter\n", name, name)
+		fmt.Fprintf(w, "%s{tier=%q} %d\n", name, tierCache, stats.Cache)
+		fmt.Fprintf(w, "%s{tier=%q} %d\n", name, tierPrimary, stats.Primary)
+		fmt.Fprintf(w, "%s{tier=%q} %d\n", name, tierFallback, stats.Fallback)
+	}
+	if c, ok := cs.Store.(*CachingStore); ok {
+		stats := c.Stats()
+		writeMetric(w, "coffeeshop_cache_hits_total", "counter", "Reads of products served from the cache.", stats.Hits)
+		writeMetric(w, "coffeeshop_cache_misses_total", "counter", "Reads of products missing the cache.", stats.Misses)
+		writeMetric(w, "coffeeshop_cache_entries", "gauge", "Lists of products in the cache.", stats.Entries)
+	}
+}
+
+// tieredStore returns the TieredStore of the server, possibly
+// wrapped by store decorators, or nil without one.
+func (cs *Server) tieredStore() *TieredStore {
+	for s := cs.Store; s != nil; s = wrappedStore(s) {
+		if ts, ok := s.(*TieredStore); ok {
+			return ts
+		}
+	}
+	return nil
+}
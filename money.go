@@ -0,0 +1,219 @@
+package coffeeshop
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the currency of prices in the inventory.
+const DefaultCurrency = "EUR"
+
+// Money represents an amount of money in minor units
+// (for example cents) of the given currency.
+//
+// By default Money in the default currency is encoded in JSON
+// as a string in the "7.99" format, and money in other currencies
+// as a JSON object with amount in minor units and currency, so
+// the currency isn't lost. Use the WithStructuredPrices option
+// to encode all prices in product responses as JSON objects.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// ParseMoney parses amount in the "7.99" format.
+func ParseMoney(amount, currency string) (Money, error) {
+	s := amount
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	units, cents, found := strings.Cut(s, ".")
+	if units == "" || len(cents) > 2 || (found && cents == "") {
+		return Money{}, fmt.Errorf("invalid amount %q", amount)
+	}
+	for len(cents) < 2 {
+		cents += "0"
+	}
+	u, err := strconv.ParseUint(units, 10, 62)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q", amount)
+	}
+	c, err := strconv.ParseUint(cents, 10, 8)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q", amount)
+	}
+	m := Money{Amount: int64(u*100 + c), Currency: currency}
+	if negative {
+		m.Amount = -m.Amount
+	}
+	return m, nil
+}
+
+// String returns the amount in the "7.99" format.
+func (m Money) String() string {
+	return string(m.appendAmount(make([]byte, 0, 16)))
+}
+
+// appendAmount appends the amount in the "7.99" format to dst.
+func (m Money) appendAmount(dst []byte) []byte {
+	amount := m.Amount
+	if amount < 0 {
+		dst = append(dst, '-')
+		amount = -amount
+	}
+	dst = strconv.AppendInt(dst, amount/100, 10)
+	dst = append(dst, '.', byte('0'+amount%100/10), byte('0'+amount%10))
+	return dst
+}
+
+// ErrCurrencyMismatch is returned when adding amounts of money
+// in different currencies.
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// Add returns the sum of m and o. Money without currency takes the
+// currency of o. It returns ErrCurrencyMismatch if the amounts are
+// in different currencies.
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency == "" {
+		m.Currency = o.Currency
+	}
+	if o.Currency != "" && o.Currency != m.Currency {
+		return Money{}, fmt.Errorf("%w: can't add %s to %s", ErrCurrencyMismatch, o.Currency, m.Currency)
+	}
+	m.Amount += o.Amount
+	return m, nil
+}
+
+// Mul returns m multiplied by n.
+func (m Money) Mul(n int) Money {
+	m.Amount *= int64(n)
+	return m
+}
+
+// MarshalJSON encodes money in the default currency, or without
+// currency, in the "7.99" format. Money in other currencies is
+// encoded as a JSON object with amount in minor units and currency,
+// which UnmarshalJSON decodes back.
+func (m Money) MarshalJSON() ([]byte, error) {
+	if m.Currency != "" && m.Currency != DefaultCurrency {
+		return json.Marshal(structuredMoney(m))
+	}
+	b := make([]byte, 0, 24)
+	b = append(b, '"')
+	b = m.appendAmount(b)
+	return append(b, '"'), nil
+}
+
+// UnmarshalJSON decodes money either from the "7.99" format,
+// assuming the default currency, or from a JSON object
+// with amount in minor units and currency.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		type moneyAlias Money
+		var ma moneyAlias
+		if err := json.Unmarshal(data, &ma); err != nil {
+			return err
+		}
+		*m = Money(ma)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		*m = Money{}
+		return nil
+	}
+	parsed, err := ParseMoney(s, DefaultCurrency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// MarshalXML encodes money as an element holding the amount
+// in the "7.99" format with the currency attribute.
+func (m Money) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if m.Currency != "" {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "currency"}, Value: m.Currency})
+	}
+	return e.EncodeElement(m.String(), start)
+}
+
+// structuredMoney encodes money as a JSON object.
+type structuredMoney struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// pricedProduct adds the derived unit price and the description
+// in the language preferred by the client to the product.
+type pricedProduct struct {
+	Product
+	Description string `json:"description,omitempty"`
+	unitPrices
+	Links map[string]halLink `json:"_links,omitempty"`
+}
+
+// structuredProduct overrides encoding of the product
+// price to emit structured money object.
+type structuredProduct struct {
+	Product
+	Price       structuredMoney `json:"price"`
+	Description string          `json:"description,omitempty"`
+	unitPrices
+	Links map[string]halLink `json:"_links,omitempty"`
+}
+
+// WithStructuredPrices configures the server to encode product
+// prices as JSON objects with amount in minor units and currency
+// instead of the "7.99" format.
+func WithStructuredPrices() Option {
+	return func(s *Server) error {
+		s.StructuredPrices = true
+		return nil
+	}
+}
+
+// productView returns the product representation
+// used in the response body to the request, with the derived
+// unit price, the description in the preferred language and
+// links if enabled.
+func (cs *Server) productView(r *http.Request, p Product) any {
+	var links map[string]halLink
+	if cs.Links {
+		links = productLinks(baseURL(r), p)
+	}
+	if !cs.StructuredPrices {
+		return pricedProduct{
+			Product:     p,
+			Description: cs.description(r, p),
+			unitPrices:  cs.unitPrices(p),
+			Links:       links,
+		}
+	}
+	return structuredProduct{
+		Product:     p,
+		Price:       structuredMoney(p.Price),
+		Description: cs.description(r, p),
+		unitPrices:  cs.unitPrices(p),
+		Links:       links,
+	}
+}
+
+// productsView returns representation of products
+// used in the response body.
+func (cs *Server) productsView(r *http.Request, px []Product) any {
+	views := make([]any, 0, len(px))
+	for _, p := range px {
+		views = append(views, cs.productView(r, p))
+	}
+	return views
+}
@@ -0,0 +1,137 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+func TestParseMoney_ParsesValidAmounts(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		amount string
+		want   coffeeshop.Money
+	}{
+		{amount: "7.99", want: coffeeshop.Money{Amount: 799, Currency: "EUR"}},
+		{amount: "12", want: coffeeshop.Money{Amount: 1200, Currency: "EUR"}},
+		{amount: "0.5", want: coffeeshop.Money{Amount: 50, Currency: "EUR"}},
+		{amount: "-1.05", want: coffeeshop.Money{Amount: -105, Currency: "EUR"}},
+	}
+
+	for _, tc := range tt {
+		got, err := coffeeshop.ParseMoney(tc.amount, "EUR")
+		if err != nil {
+			t.Fatalf("%s: %v", tc.amount, err)
+		}
+		if !cmp.Equal(tc.want, got) {
+			t.Error(cmp.Diff(tc.want, got))
+		}
+	}
+}
+
+func TestParseMoney_ErrorsOnInvalidAmounts(t *testing.T) {
+	t.Parallel()
+
+	for _, amount := range []string{"", "7.", "7.999", "seven", ".99", "7.9a"} {
+		_, err := coffeeshop.ParseMoney(amount, "EUR")
+		if err == nil {
+			t.Errorf("want error on amount %q", amount)
+		}
+	}
+}
+
+func TestMoney_AddsAmountsInSameCurrency(t *testing.T) {
+	t.Parallel()
+
+	got, err := coffeeshop.Money{}.Add(coffeeshop.Money{Amount: 799, Currency: "EUR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = got.Add(coffeeshop.Money{Amount: 201, Currency: "EUR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := coffeeshop.Money{Amount: 1000, Currency: "EUR"}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestMoney_ErrorsOnAddingDifferentCurrencies(t *testing.T) {
+	t.Parallel()
+
+	_, err := coffeeshop.Money{Amount: 799, Currency: "EUR"}.Add(coffeeshop.Money{Amount: 499, Currency: "USD"})
+	if !errors.Is(err, coffeeshop.ErrCurrencyMismatch) {
+		t.Errorf("want ErrCurrencyMismatch, got %v", err)
+	}
+}
+
+func TestMoney_EncodesAndDecodesStringFormat(t *testing.T) {
+	t.Parallel()
+
+	m := coffeeshop.Money{Amount: 1099, Currency: "EUR"}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `"10.99"` {
+		t.Errorf("want \"10.99\", got %s", data)
+	}
+
+	var got coffeeshop.Money
+	err = json.Unmarshal(data, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(m, got) {
+		t.Error(cmp.Diff(m, got))
+	}
+}
+
+func TestMoney_DecodesStructuredFormat(t *testing.T) {
+	t.Parallel()
+
+	var got coffeeshop.Money
+	err := json.Unmarshal([]byte(`{"amount":250,"currency":"USD"}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := coffeeshop.Money{Amount: 250, Currency: "USD"}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestServer_ReturnsStructuredPricesWhenConfigured(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t, coffeeshop.WithStructuredPrices())
+	resp, err := http.Get(shop.URL + "products/7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var got struct {
+		Price struct {
+			Amount   int64  `json:"amount"`
+			Currency string `json:"currency"`
+		} `json:"price"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Price.Amount != 499 || got.Price.Currency != "EUR" {
+		t.Errorf("want price 499 EUR, got %d %s", got.Price.Amount, got.Price.Currency)
+	}
+}
@@ -0,0 +1,229 @@
+package coffeeshop
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// Encoder writes products in a specific media type. The value
+// passed to the encoder is either a Product or a []Product.
+type Encoder func(w io.Writer, v any) error
+
+// Media types of built-in product encoders.
+const (
+	jsonContentType = "application/json"
+	xmlContentType  = "application/xml"
+	csvContentType  = "text/csv"
+)
+
+// DefaultEncoders returns encoders used by the server unless
+// configured otherwise. JSON is always supported and encoded
+// by the server itself.
+func DefaultEncoders() map[string]Encoder {
+	return map[string]Encoder{
+		xmlContentType: EncodeXML,
+		csvContentType: EncodeCSV,
+	}
+}
+
+// WithEncoder registers the encoder for the given media type.
+// Clients select encoders with the Accept request header.
+func WithEncoder(mediaType string, enc Encoder) Option {
+	return func(s *Server) error {
+		if enc == nil {
+			return errors.New("nil encoder")
+		}
+		mt, _, err := mime.ParseMediaType(mediaType)
+		if err != nil {
+			return err
+		}
+		if mt == jsonContentType {
+			return errors.New("JSON encoder can't be replaced")
+		}
+		s.Encoders[mt] = enc
+		return nil
+	}
+}
+
+// xmlProducts represents a list of products in XML.
+type xmlProducts struct {
+	XMLName  xml.Name  `xml:"products"`
+	Products []Product `xml:"product"`
+}
+
+// EncodeXML writes products as XML.
+func EncodeXML(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	switch p := v.(type) {
+	case Product:
+		return enc.EncodeElement(p, xml.StartElement{Name: xml.Name{Local: "product"}})
+	case []Product:
+		return enc.Encode(xmlProducts{Products: p})
+	default:
+		return fmt.Errorf("can't encode %T as XML", v)
+	}
+}
+
+// csvHeader holds names of columns in CSV encoded products.
+var csvHeader = []string{"id", "type", "brand", "name", "unit", "quantity", "price", "currency", "stock", "properties", "intensity", "flavours", "sku", "ean"}
+
+// EncodeCSV writes products as CSV with a header row.
+// Product properties are encoded as 'name=value' pairs
+// separated by semicolons.
+func EncodeCSV(w io.Writer, v any) error {
+	var px []Product
+	switch p := v.(type) {
+	case Product:
+		px = []Product{p}
+	case []Product:
+		px = p
+	default:
+		return fmt.Errorf("can't encode %T as CSV", v)
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, p := range px {
+		props := make([]string, 0, len(p.Properties))
+		for _, prop := range p.Properties {
+			props = append(props, prop.Name+"="+prop.Value)
+		}
+		var intensity string
+		if p.Intensity > 0 {
+			intensity = strconv.Itoa(p.Intensity)
+		}
+		err := cw.Write([]string{
+			p.ID,
+			p.Type,
+			p.Brand,
+			p.Name,
+			string(p.Unit),
+			p.Quantity.String(),
+			p.Price.String(),
+			p.Price.Currency,
+			strconv.Itoa(p.Stock),
+			strings.Join(props, ";"),
+			intensity,
+			strings.Join(p.Flavours, ";"),
+			p.SKU,
+			p.EAN,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// acceptedTypes returns media types from the Accept header
+// ordered by client preference.
+func acceptedTypes(accept string) []string {
+	type mediaRange struct {
+		mediaType string
+		q         float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, mediaRange{mediaType: mt, q: q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+	types := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		types = append(types, r.mediaType)
+	}
+	return types
+}
+
+// negotiate returns the media type of the product response
+// preferred by the client. It returns an empty string if none
+// of the media types accepted by the client is supported.
+func (cs *Server) negotiate(r *http.Request) string {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return jsonContentType
+	}
+	for _, mt := range acceptedTypes(accept) {
+		switch mt {
+		case "*/*", "application/*", jsonContentType, ndjsonContentType:
+			return jsonContentType
+		}
+		if _, ok := cs.Encoders[mt]; ok {
+			return mt
+		}
+	}
+	return ""
+}
+
+// varyProducts sets the Vary header of responses with products,
+// whose representation depends on the Accept, Accept-Language and
+// Accept-Currency headers, unless it's already set.
+func varyProducts(w http.ResponseWriter) {
+	for _, h := range []string{"Accept", "Accept-Language", "Accept-Currency"} {
+		if !slices.Contains(w.Header().Values("Vary"), h) {
+			w.Header().Add("Vary", h)
+		}
+	}
+}
+
+// writeProducts writes the product or products in the media type
+// negotiated with the client. The value v is either a Product
+// or a []Product.
+func (cs *Server) writeProducts(w http.ResponseWriter, r *http.Request, v any) {
+	varyProducts(w)
+	mt := cs.negotiate(r)
+	switch mt {
+	case "":
+		writeError(w, r, http.StatusNotAcceptable, "not acceptable")
+	case jsonContentType:
+		switch p := v.(type) {
+		case Product:
+			writeJSON(w, r, http.StatusOK, cs.productView(r, p))
+		case []Product:
+			if cs.KeyedProducts {
+				writeJSON(w, r, http.StatusOK, cs.keyedProducts(r, p))
+				return
+			}
+			cs.streamProducts(w, r, p)
+		default:
+			writeJSON(w, r, http.StatusOK, v)
+		}
+	default:
+		ct := mt + "; charset=utf-8"
+		if strings.HasSuffix(mt, "+json") {
+			// JSON is always UTF-8, and JSON:API forbids
+			// media type parameters.
+			ct = mt
+		}
+		w.Header().Set("Content-Type", ct)
+		w.WriteHeader(http.StatusOK)
+		_ = cs.Encoders[mt](w, v)
+	}
+}
@@ -0,0 +1,130 @@
+package coffeeshop_test
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+func getAccepting(t *testing.T, url, accept string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", accept)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestServer_ReturnsProductAsXML(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp := getAccepting(t, shop.URL+"products/3", "application/xml")
+	defer resp.Body.Close()
+
+	if got := resp.Header.Get("Content-Type"); got != "application/xml; charset=utf-8" {
+		t.Errorf("want XML content type, got %q", got)
+	}
+	var got struct {
+		XMLName xml.Name `xml:"product"`
+		ID      string   `xml:"id"`
+		Price   struct {
+			Amount   string `xml:",chardata"`
+			Currency string `xml:"currency,attr"`
+		} `xml:"price"`
+		Properties []struct {
+			Name  string `xml:"name,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"properties>property"`
+	}
+	err := xml.NewDecoder(resp.Body).Decode(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != "3" || got.Price.Amount != "10.49" || got.Price.Currency != "EUR" {
+		t.Errorf("want product 3 priced 10.49 EUR, got %s priced %s %s", got.ID, got.Price.Amount, got.Price.Currency)
+	}
+	if len(got.Properties) != 2 {
+		t.Errorf("want 2 properties, got %d", len(got.Properties))
+	}
+}
+
+func TestServer_ReturnsProductsAsCSV(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: coffeeshop.Products{
+			"7": inventory["7"],
+		},
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp := getAccepting(t, shop.URL+"products", "text/csv")
+	defer resp.Body.Close()
+
+	got, err := csv.NewReader(resp.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"id", "type", "brand", "name", "unit", "quantity", "price", "currency", "stock", "properties", "intensity", "flavours", "sku", "ean"},
+		{"7", "Tea", "Caykur", "Green Tea", "gram", "150", "4.99", "EUR", "0", "", "", "", "", ""},
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestServer_Returns406OnUnsupportedMediaType(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp := getAccepting(t, shop.URL+"products", "image/png")
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotAcceptable {
+		t.Errorf("want HTTP 406, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_UsesRegisteredEncoderByPreference(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	names := func(w io.Writer, v any) error {
+		_, err := io.WriteString(w, v.(coffeeshop.Product).Name)
+		return err
+	}
+	shop := newCoffeShopTestServer(store, "10ms", t, coffeeshop.WithEncoder("text/plain", names))
+	resp := getAccepting(t, shop.URL+"products/8", "application/json;q=0.5, text/plain")
+	defer resp.Body.Close()
+
+	got, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "Jasmin Tea" {
+		t.Errorf("want plain text product name, got %q", got)
+	}
+}
@@ -0,0 +1,46 @@
+package coffeeshop
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// OpenAPI holds the OpenAPI 3 document describing the coffeeshop API.
+//
+//go:embed openapi.json
+var OpenAPI []byte
+
+// docsPage renders Swagger UI for the OpenAPI document.
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>coffeeshop API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`
+
+// WithDocs enables Swagger UI for the API at /docs.
+func WithDocs() Option {
+	return func(s *Server) error {
+		s.Docs = true
+		return nil
+	}
+}
+
+func (cs *Server) GetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	_, _ = w.Write(OpenAPI)
+}
+
+func (cs *Server) GetDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(docsPage))
+}
@@ -0,0 +1,110 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/qba73/coffeeshop"
+)
+
+func TestServer_ServesOpenAPIDocumentDescribingRoutes(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp, err := http.Get(shop.URL + "openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want status 200, got %d", resp.StatusCode)
+	}
+	var doc struct {
+		OpenAPI string                     `json:"openapi"`
+		Paths   map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("want OpenAPI 3 document, got version %q", doc.OpenAPI)
+	}
+	routes := []string{
+		"/products",
+		"/products/{productID}",
+		"/products/coffee",
+		"/products/tea",
+		"/products/{productID}/stock",
+		"/categories",
+		"/categories/{category}/products",
+		"/orders",
+		"/orders/{orderID}",
+		"/carts",
+		"/carts/{cartID}",
+		"/carts/{cartID}/items",
+		"/carts/{cartID}/items/{productID}",
+		"/carts/{cartID}/checkout",
+		"/graphql",
+	}
+	for _, route := range routes {
+		if _, ok := doc.Paths[route]; !ok {
+			t.Errorf("route %s not described", route)
+		}
+	}
+}
+
+func TestServer_DoesNotServeDocsByDefault(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp, err := http.Get(shop.URL + "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("want status 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_ServesDocsWhenEnabled(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t, coffeeshop.WithDocs())
+	resp, err := http.Get(shop.URL + "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want status 200, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "text/html; charset=utf-8" {
+		t.Errorf("want HTML content type, got %q", got)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), "/openapi.json") {
+		t.Error("want docs page to load the OpenAPI document")
+	}
+}
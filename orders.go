@@ -0,0 +1,325 @@
+package coffeeshop
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/exp/maps"
+)
+
+// OrderStatus represents a stage in the order lifecycle.
+type OrderStatus string
+
+const (
+	OrderReceived  OrderStatus = "received"
+	OrderPreparing OrderStatus = "preparing"
+	OrderReady     OrderStatus = "ready"
+	OrderCollected OrderStatus = "collected"
+)
+
+// orderLifecycle holds order statuses in the order
+// they are applied to an order.
+var orderLifecycle = []OrderStatus{
+	OrderReceived,
+	OrderPreparing,
+	OrderReady,
+	OrderCollected,
+}
+
+// orderStage returns the position of the status in the order
+// lifecycle or -1 if the status is unknown.
+func orderStage(s OrderStatus) int {
+	for i, status := range orderLifecycle {
+		if status == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// OrderItem represents a single line in the order.
+type OrderItem struct {
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+}
+
+// Order represents a customer order.
+type Order struct {
+	ID string `json:"id"`
+	// CustomerID identifies the customer who placed the order.
+	// It is empty for anonymous orders.
+	CustomerID string      `json:"customerId,omitempty"`
+	Items      []OrderItem `json:"items"`
+	// Total is the amount paid for orders placed at checkout,
+	// after the Discount of the DiscountCode is taken off.
+	Total        *Money      `json:"total,omitempty"`
+	Discount     *Money      `json:"discount,omitempty"`
+	DiscountCode string      `json:"discountCode,omitempty"`
+	Status       OrderStatus `json:"status"`
+	CreatedAt    time.Time   `json:"createdAt"`
+	UpdatedAt    time.Time   `json:"updatedAt"`
+}
+
+// OrderStore represents a storage for orders.
+type OrderStore interface {
+	CreateOrder(o Order) (Order, error)
+	GetOrder(id string) (Order, error)
+	GetOrders() []Order
+	UpdateOrderStatus(id string, status OrderStatus) (Order, error)
+}
+
+// MemoryOrderStore represents an in-memory storage for orders.
+//
+// Use memory order store for testing and development.
+type MemoryOrderStore struct {
+	mx     sync.RWMutex
+	lastID int
+	Orders map[string]Order
+	// Clock timestamps changes. Nil means the real time.
+	Clock Clock
+}
+
+// CreateOrder stores the order, assigns it a new ID
+// and sets its creation and update times.
+func (ms *MemoryOrderStore) CreateOrder(o Order) (Order, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	if ms.Orders == nil {
+		ms.Orders = make(map[string]Order)
+	}
+	o.CreatedAt = now(ms.Clock)
+	o.UpdatedAt = o.CreatedAt
+	for {
+		ms.lastID++
+		o.ID = strconv.Itoa(ms.lastID)
+		if _, ok := ms.Orders[o.ID]; !ok {
+			break
+		}
+	}
+	ms.Orders[o.ID] = o
+	return o, nil
+}
+
+// GetOrder returns the order with the given ID.
+func (ms *MemoryOrderStore) GetOrder(id string) (Order, error) {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	o, ok := ms.Orders[id]
+	if !ok {
+		return Order{}, ErrOrderNotFound
+	}
+	return o, nil
+}
+
+// GetOrders returns all orders in the store sorted by ID.
+func (ms *MemoryOrderStore) GetOrders() []Order {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	ox := maps.Values(ms.Orders)
+	sortOrders(ox)
+	return ox
+}
+
+// UpdateOrderStatus sets the status of the order with the given ID.
+func (ms *MemoryOrderStore) UpdateOrderStatus(id string, status OrderStatus) (Order, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	o, ok := ms.Orders[id]
+	if !ok {
+		return Order{}, ErrOrderNotFound
+	}
+	o.Status = status
+	o.UpdatedAt = now(ms.Clock)
+	ms.Orders[o.ID] = o
+	return o, nil
+}
+
+// WithOrderStore configures the storage used for orders.
+func WithOrderStore(store OrderStore) Option {
+	return func(s *Server) error {
+		if store == nil {
+			return errors.New("nil order store")
+		}
+		s.OrderStore = store
+		return nil
+	}
+}
+
+// WithOrderInterval configures how long an order stays in
+// each state before it moves to the next one.
+func WithOrderInterval(i string) Option {
+	return func(s *Server) error {
+		d, err := time.ParseDuration(i)
+		if err != nil {
+			return err
+		}
+		if d <= 0 {
+			return errors.New("order interval must be positive")
+		}
+		s.OrderInterval = d
+		return nil
+	}
+}
+
+// scheduleOrder moves the order through the lifecycle from the
+// status, one state per configured order interval.
+func (cs *Server) scheduleOrder(id string, status OrderStatus) {
+	var advance func(next int)
+	advance = func(next int) {
+		if next >= len(orderLifecycle) {
+			return
+		}
+		cs.Clock.AfterFunc(cs.OrderInterval, func() {
+			cs.advanceOrder(id, orderLifecycle[next])
+			advance(next + 1)
+		})
+	}
+	advance(orderStage(status) + 1)
+}
+
+// resumeOrders restarts the lifecycle of orders in the order
+// store which aren't collected yet, like orders restored from
+// a snapshot. Orders waiting for or being prepared by baristas
+// join the barista queue again.
+func (cs *Server) resumeOrders() {
+	for _, o := range cs.OrderStore.GetOrders() {
+		stage := orderStage(o.Status)
+		switch {
+		case stage < 0 || o.Status == OrderCollected:
+		case cs.Baristas > 0 && stage < orderStage(OrderReady):
+			cs.enqueueOrder(o)
+		default:
+			cs.scheduleOrder(o.ID, o.Status)
+		}
+	}
+}
+
+// placeOrder reserves stock for items of the order in the store,
+// stores it as a new received order and starts its lifecycle.
+func (cs *Server) placeOrder(s Store, o Order) (Order, error) {
+	if err := s.ReserveStock(o.Items); err != nil {
+		return Order{}, err
+	}
+	order, err := cs.submitOrder(o)
+	if err != nil {
+		cs.releaseStock(o.Items)
+		return Order{}, err
+	}
+	return order, nil
+}
+
+// submitOrder stores the order, with stock for its items already
+// reserved, as a new received order and starts its lifecycle.
+func (cs *Server) submitOrder(o Order) (Order, error) {
+	o.Status = OrderReceived
+	order, err := cs.OrderStore.CreateOrder(o)
+	if err != nil {
+		return Order{}, err
+	}
+	cs.orderEvents.Publish(OrderCreated, order)
+	if cs.Baristas > 0 {
+		cs.enqueueOrder(order)
+	} else {
+		cs.scheduleOrder(order.ID, order.Status)
+	}
+	return order, nil
+}
+
+func (cs *Server) CreateOrder(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := cs.customerID(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Items []OrderItem `json:"items"`
+	}
+	if !cs.decodeJSON(w, r, &req, "invalid order") {
+		return
+	}
+	if len(req.Items) == 0 {
+		writeError(w, r, http.StatusBadRequest, "invalid order")
+		return
+	}
+	for _, item := range req.Items {
+		if item.Quantity <= 0 {
+			writeError(w, r, http.StatusBadRequest, "invalid order")
+			return
+		}
+		if _, err := orderableProduct(cs.store(r), item.ProductID); err != nil {
+			writeErrorCode(w, r, http.StatusBadRequest, "product_not_found", "product not found")
+			return
+		}
+	}
+	order, err := cs.placeOrder(cs.store(r), Order{CustomerID: customerID, Items: req.Items})
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", resourcePath(r, "/orders/"+order.ID))
+	writeJSON(w, r, http.StatusCreated, order)
+}
+
+func (cs *Server) GetOrder(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := cs.customerID(w, r)
+	if !ok {
+		return
+	}
+	order, err := cs.customerOrder(chi.URLParam(r, "orderID"), customerID)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, order)
+}
+
+// customerOrder returns the order with the ID if the customer may
+// see it. Orders placed by customers are visible only to them, and
+// anonymous orders to everyone.
+func (cs *Server) customerOrder(id, customerID string) (Order, error) {
+	o, err := cs.OrderStore.GetOrder(id)
+	if err != nil {
+		return Order{}, err
+	}
+	if o.CustomerID != "" && o.CustomerID != customerID {
+		return Order{}, ErrOrderNotFound
+	}
+	return o, nil
+}
+
+// customerOrders returns orders in the store placed by the
+// customer, or anonymous orders if the customer ID is empty.
+func (cs *Server) customerOrders(customerID string) []Order {
+	var orders []Order
+	for _, o := range cs.OrderStore.GetOrders() {
+		if o.CustomerID == customerID {
+			orders = append(orders, o)
+		}
+	}
+	return orders
+}
+
+// GetOrders lists orders sorted by ID. Requests with a customer's
+// bearer token list orders of the customer, and anonymous requests
+// list anonymous orders. Orders can be filtered by the time of their
+// last update with the "updated_since" query parameter.
+func (cs *Server) GetOrders(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := cs.customerID(w, r)
+	if !ok {
+		return
+	}
+	orders, err := ordersUpdatedSince(r, cs.customerOrders(customerID))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if orders == nil {
+		orders = []Order{}
+	}
+	sortOrders(orders)
+	writeJSON(w, r, http.StatusOK, orders)
+}
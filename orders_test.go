@@ -0,0 +1,114 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qba73/coffeeshop"
+)
+
+func createOrder(t *testing.T, url, body string) *http.Response {
+	t.Helper()
+	resp, err := http.Post(url+"orders", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestServer_CreatesOrderInReceivedState(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(10),
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t, coffeeshop.WithOrderInterval("1h"))
+	resp := createOrder(t, shop.URL, `{"items":[{"productId":"1","quantity":2}]}`)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("want HTTP 201, got %d", resp.StatusCode)
+	}
+
+	var got coffeeshop.Order
+	err := json.NewDecoder(resp.Body).Decode(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != coffeeshop.OrderReceived {
+		t.Errorf("want status %q, got %q", coffeeshop.OrderReceived, got.Status)
+	}
+	if resp.Header.Get("Location") != "/orders/"+got.ID {
+		t.Errorf("want location /orders/%s, got %q", got.ID, resp.Header.Get("Location"))
+	}
+}
+
+func TestServer_Returns400OnOrderForNotExistingProduct(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(10),
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp := createOrder(t, shop.URL, `{"items":[{"productId":"20","quantity":1}]}`)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("want HTTP 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_MovesOrderThroughLifecycle(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(10),
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t, coffeeshop.WithOrderInterval("50ms"))
+	resp := createOrder(t, shop.URL, `{"items":[{"productId":"7","quantity":1}]}`)
+	resp.Body.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(shop.URL + "orders/1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got coffeeshop.Order
+		err = json.NewDecoder(resp.Body).Decode(&got)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status == coffeeshop.OrderCollected {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Error("order not collected before deadline")
+}
+
+func TestServer_Returns404OnNotExistingOrder(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(10),
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp, err := http.Get(shop.URL + "orders/20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("want HTTP 404, got %d", resp.StatusCode)
+	}
+}
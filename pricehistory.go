@@ -0,0 +1,93 @@
+package coffeeshop
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// PricePoint is the price of a product since the time.
+type PricePoint struct {
+	Price Money     `json:"price"`
+	Time  time.Time `json:"time"`
+}
+
+// PriceHistorian is implemented by stores tracking
+// changes of product prices.
+type PriceHistorian interface {
+	// PriceHistory returns prices of the product, oldest
+	// first. The last point is the current price.
+	PriceHistory(id string) ([]PricePoint, error)
+}
+
+// PriceHistory returns prices of the product, oldest first. Prices of
+// products loaded with the store, and not changed since, are known
+// from the time the product was last modified or, if the store never
+// modified it, from the time the products were loaded.
+func (ms *MemoryStore) PriceHistory(id string) ([]PricePoint, error) {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	p, ok := ms.Products[id]
+	if !ok {
+		return nil, ErrProductNotFound
+	}
+	history := ms.prices[id]
+	if len(history) == 0 {
+		return []PricePoint{{Price: p.Price, Time: ms.pricedSince(p)}}, nil
+	}
+	return append([]PricePoint(nil), history...), nil
+}
+
+// recordPrice records the price of the product if it differs
+// from the old one. It must be called with the store lock held.
+func (ms *MemoryStore) recordPrice(old Product, exists bool, p Product) {
+	if exists && old.Price == p.Price {
+		return
+	}
+	if ms.prices == nil {
+		ms.prices = make(map[string][]PricePoint)
+	}
+	var history []PricePoint
+	if exists {
+		history = ms.prices[p.ID]
+		if len(history) == 0 {
+			history = append(history, PricePoint{Price: old.Price, Time: ms.pricedSince(old)})
+		}
+	}
+	ms.prices[p.ID] = append(history, PricePoint{Price: p.Price, Time: timeOf(p.UpdatedAt)})
+}
+
+// pricedSince returns the time of the last change of the product
+// or, for products without timestamps, the time products were
+// loaded into the store.
+func (ms *MemoryStore) pricedSince(p Product) time.Time {
+	if p.UpdatedAt != nil {
+		return *p.UpdatedAt
+	}
+	return ms.loadTime()
+}
+
+// loadTime returns the time products were loaded into the store.
+// It is taken from the clock when the store starts serving a server
+// or when the time is first needed, and again when products are
+// reset.
+func (ms *MemoryStore) loadTime() time.Time {
+	ms.load.Do(func() { ms.loadedAt = now(ms.Clock) })
+	return ms.loadedAt
+}
+
+// GetPriceHistory returns prices of the product, oldest first.
+func (cs *Server) GetPriceHistory(w http.ResponseWriter, r *http.Request) {
+	ph, ok := storeAs[PriceHistorian](cs.store(r))
+	if !ok {
+		writeError(w, r, http.StatusNotImplemented, "store doesn't support price history")
+		return
+	}
+	history, err := ph.PriceHistory(chi.URLParam(r, "productID"))
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, history)
+}
@@ -0,0 +1,138 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+var _ coffeeshop.PriceHistorian = (*coffeeshop.MemoryStore)(nil)
+
+// prices returns prices of the points.
+func prices(history []coffeeshop.PricePoint) []coffeeshop.Money {
+	px := make([]coffeeshop.Money, 0, len(history))
+	for _, p := range history {
+		px = append(px, p.Price)
+	}
+	return px
+}
+
+func TestMemoryStore_RecordsPriceChanges(t *testing.T) {
+	t.Parallel()
+
+	store := coffeeshop.MemoryStore{Products: coffeeshop.Products{"1": inventory["1"]}}
+	history, err := store.PriceHistory("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []coffeeshop.Money{inventory["1"].Price}
+	if !cmp.Equal(want, prices(history)) {
+		t.Error(cmp.Diff(want, prices(history)))
+	}
+
+	p := inventory["1"]
+	for _, amount := range []int64{1299, 1299, 1099} {
+		p.Price.Amount = amount
+		if p, err = store.Update(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.SetStock("1", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err = store.PriceHistory("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []coffeeshop.Money{
+		inventory["1"].Price,
+		{Amount: 1299, Currency: "EUR"},
+		{Amount: 1099, Currency: "EUR"},
+	}
+	if !cmp.Equal(want, prices(history)) {
+		t.Error(cmp.Diff(want, prices(history)))
+	}
+	for i := 1; i < len(history); i++ {
+		if history[i].Time.Before(history[i-1].Time) {
+			t.Errorf("want points in time order, got %+v", history)
+		}
+	}
+
+	if _, err := store.PriceHistory("42"); !errors.Is(err, coffeeshop.ErrProductNotFound) {
+		t.Errorf("want ErrProductNotFound, got %v", err)
+	}
+}
+
+func TestServer_StampsFirstPriceOfLoadedProductsWithClock(t *testing.T) {
+	t.Parallel()
+
+	started := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	clock := coffeeshop.NewFakeClock(started)
+	store := &coffeeshop.MemoryStore{Products: stockedInventory(5)}
+	newCoffeShopTestServer(store, "0s", t, coffeeshop.WithClock(clock))
+	clock.Advance(time.Hour)
+
+	p, err := store.GetProduct("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Price.Amount = 1299
+	if _, err := store.Update(p); err != nil {
+		t.Fatal(err)
+	}
+	history, err := store.PriceHistory("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Time{started, started.Add(time.Hour)}
+	var got []time.Time
+	for _, point := range history {
+		got = append(got, point.Time)
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestServer_ReturnsPriceHistoryOfProduct(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: stockedInventory(5)}
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp := putIfMatch(t, shop.URL+"products/1", "", `{"type":"Coffee","brand":"Segafredo","name":"Intermezzo","price":"12.49","stock":5}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200 for update, got %d", resp.StatusCode)
+	}
+
+	resp, err := http.Get(shop.URL + "products/1/price-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200, got %d", resp.StatusCode)
+	}
+	var history []coffeeshop.PricePoint
+	if err := json.NewDecoder(resp.Body).Decode(&history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[1].Price.String() != "12.49" || history[1].Time.IsZero() {
+		t.Errorf("want old price and 12.49, got %+v", history)
+	}
+
+	resp, err = http.Get(shop.URL + "products/42/price-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("want HTTP 404 for unknown product, got %d", resp.StatusCode)
+	}
+}
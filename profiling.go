@@ -0,0 +1,27 @@
+package coffeeshop
+
+import (
+	"net/http/pprof"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// WithProfiling serves net/http/pprof profiles under /debug/pprof/
+// with the admin routes, for example CPU profiles of the server
+// under load at /debug/pprof/profile?seconds=10.
+func WithProfiling() Option {
+	return func(s *Server) error {
+		s.Profiling = true
+		return nil
+	}
+}
+
+// mountProfiling registers pprof handlers. The index
+// serves named profiles like heap and goroutine.
+func mountProfiling(r chi.Router) {
+	r.HandleFunc("/debug/pprof/*", pprof.Index)
+	r.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	r.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	r.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	r.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
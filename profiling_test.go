@@ -0,0 +1,38 @@
+package coffeeshop_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/qba73/coffeeshop"
+)
+
+func TestServer_ServesProfilesWithProfiling(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t, coffeeshop.WithProfiling())
+	for _, path := range []string{"debug/pprof/", "debug/pprof/heap", "debug/pprof/cmdline"} {
+		resp, err := http.Get(shop.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s: want HTTP 200, got %d", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestServer_Returns404OnProfilesWithoutProfiling(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t)
+	resp, err := http.Get(shop.URL + "debug/pprof/heap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("want HTTP 404, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,206 @@
+package coffeeshop
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/maps"
+)
+
+// DiscountKind tells how a promotion discounts the order total.
+type DiscountKind string
+
+const (
+	// DiscountPercentage takes the percentage off the total.
+	DiscountPercentage DiscountKind = "percentage"
+	// DiscountFixed takes the fixed amount off the total.
+	DiscountFixed DiscountKind = "fixed"
+)
+
+// Promotion is a discount code applied at checkout.
+type Promotion struct {
+	// Code is matched case-insensitively and stored in upper case.
+	Code string       `json:"code"`
+	Kind DiscountKind `json:"kind"`
+	// Percent is the discount of percentage promotions.
+	Percent int `json:"percent,omitempty"`
+	// Amount is the discount of fixed promotions.
+	Amount *Money `json:"amount,omitempty"`
+	// ExpiresAt is the time after which the code can't be used.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// MaxUses limits redemptions of the code. Zero means no limit.
+	MaxUses   int       `json:"maxUses,omitempty"`
+	Uses      int       `json:"uses"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// validate returns problems of the promotion.
+func (p Promotion) validate() []string {
+	var problems []string
+	if p.Code == "" || strings.ContainsAny(p.Code, " \t\r\n/") {
+		problems = append(problems, fmt.Sprintf("invalid code %q", p.Code))
+	}
+	switch p.Kind {
+	case DiscountPercentage:
+		if p.Percent < 1 || p.Percent > 100 {
+			problems = append(problems, "percent must be between 1 and 100")
+		}
+	case DiscountFixed:
+		if p.Amount == nil || p.Amount.Amount <= 0 {
+			problems = append(problems, "amount must be positive")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("kind must be %q or %q", DiscountPercentage, DiscountFixed))
+	}
+	if p.MaxUses < 0 {
+		problems = append(problems, "maxUses can't be negative")
+	}
+	return problems
+}
+
+// discount returns the discount of the total. Percentages are
+// rounded to the nearest minor unit and fixed amounts are
+// converted to the currency of the total. Discounts never
+// exceed the total.
+func (p Promotion) discount(total Money, rates RateProvider) (Money, error) {
+	d := Money{Currency: total.Currency}
+	switch p.Kind {
+	case DiscountPercentage:
+		d.Amount = (total.Amount*int64(p.Percent) + 50) / 100
+	case DiscountFixed:
+		amount, err := p.Amount.Convert(total.Currency, rates)
+		if err != nil {
+			return Money{}, err
+		}
+		d.Amount = amount.Amount
+	}
+	if d.Amount > total.Amount {
+		d.Amount = total.Amount
+	}
+	return d, nil
+}
+
+// PromotionStore represents a storage for promotions.
+type PromotionStore interface {
+	CreatePromotion(p Promotion) (Promotion, error)
+	GetPromotions() []Promotion
+	// Redeem uses the promotion with the code once. It fails
+	// with ErrPromotionExpired after the expiry time and with
+	// ErrPromotionExhausted when the code was used up.
+	Redeem(code string, now time.Time) (Promotion, error)
+	// Release gives back the use of the redeemed promotion,
+	// for example when the payment failed.
+	Release(code string) error
+}
+
+// MemoryPromotionStore represents an in-memory storage for promotions.
+//
+// Use memory promotion store for testing and development.
+type MemoryPromotionStore struct {
+	mx         sync.Mutex
+	Promotions map[string]Promotion
+}
+
+// CreatePromotion stores the new promotion.
+func (ms *MemoryPromotionStore) CreatePromotion(p Promotion) (Promotion, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	if ms.Promotions == nil {
+		ms.Promotions = make(map[string]Promotion)
+	}
+	p.Code = strings.ToUpper(p.Code)
+	if _, ok := ms.Promotions[p.Code]; ok {
+		return Promotion{}, fmt.Errorf("%w: %s", ErrPromotionExists, p.Code)
+	}
+	ms.Promotions[p.Code] = p
+	return p, nil
+}
+
+// GetPromotions returns all promotions sorted by code.
+func (ms *MemoryPromotionStore) GetPromotions() []Promotion {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	px := maps.Values(ms.Promotions)
+	sort.Slice(px, func(i, j int) bool { return px[i].Code < px[j].Code })
+	return px
+}
+
+// Redeem uses the promotion with the code once.
+func (ms *MemoryPromotionStore) Redeem(code string, now time.Time) (Promotion, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	code = strings.ToUpper(code)
+	p, ok := ms.Promotions[code]
+	switch {
+	case !ok:
+		return Promotion{}, ErrPromotionNotFound
+	case p.ExpiresAt != nil && now.After(*p.ExpiresAt):
+		return Promotion{}, ErrPromotionExpired
+	case p.MaxUses > 0 && p.Uses >= p.MaxUses:
+		return Promotion{}, ErrPromotionExhausted
+	}
+	p.Uses++
+	ms.Promotions[code] = p
+	return p, nil
+}
+
+// Release gives back the use of the promotion with the code.
+func (ms *MemoryPromotionStore) Release(code string) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	code = strings.ToUpper(code)
+	p, ok := ms.Promotions[code]
+	if !ok {
+		return ErrPromotionNotFound
+	}
+	if p.Uses > 0 {
+		p.Uses--
+	}
+	ms.Promotions[code] = p
+	return nil
+}
+
+// WithPromotionStore configures the storage used for promotions.
+func WithPromotionStore(store PromotionStore) Option {
+	return func(s *Server) error {
+		if store == nil {
+			return errors.New("nil promotion store")
+		}
+		s.PromotionStore = store
+		return nil
+	}
+}
+
+// CreatePromotion adds the discount code in the request body.
+func (cs *Server) CreatePromotion(w http.ResponseWriter, r *http.Request) {
+	var p Promotion
+	if !cs.decodeJSON(w, r, &p, "invalid promotion") {
+		return
+	}
+	if problems := p.validate(); len(problems) > 0 {
+		writeError(w, r, http.StatusBadRequest, strings.Join(problems, "; "))
+		return
+	}
+	p.Uses = 0
+	p.CreatedAt = cs.Clock.Now()
+	p, err := cs.PromotionStore.CreatePromotion(p)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, p)
+}
+
+// GetPromotions returns all discount codes.
+func (cs *Server) GetPromotions(w http.ResponseWriter, r *http.Request) {
+	px := cs.PromotionStore.GetPromotions()
+	if px == nil {
+		px = []Promotion{}
+	}
+	writeJSON(w, r, http.StatusOK, px)
+}
@@ -0,0 +1,213 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// createPromotion creates the promotion through the admin endpoint.
+func createPromotion(t *testing.T, url, body string) *http.Response {
+	t.Helper()
+	resp, err := http.Post(url+"admin/promotions", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// checkoutWithCode fills a new cart with two products 4 for
+// 7.99 each and checks it out using the discount code.
+func checkoutWithCode(t *testing.T, url, cardNumber, code string) *http.Response {
+	t.Helper()
+	cart := doCartRequest(t, http.MethodPost, url+"carts", "")
+	cartURL := url + "carts/" + cart.ID
+	doCartRequest(t, http.MethodPost, cartURL+"/items", `{"productId":"4","quantity":2}`)
+	body := `{"cardNumber":"` + cardNumber + `","discountCode":"` + code + `"}`
+	resp, err := http.Post(cartURL+"/checkout", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// errorCodeOf returns the error code in the response body.
+func errorCodeOf(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	var e coffeeshop.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+		t.Fatal(err)
+	}
+	return e.Error.Code
+}
+
+func TestServer_AppliesDiscountCodeAtCheckout(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(10)}, "10ms", t,
+		coffeeshop.WithOrderInterval("1h"),
+	)
+	resp := createPromotion(t, shop.URL, `{"code":"welcome10","kind":"percentage","percent":10,"maxUses":1}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("want HTTP 201, got %d", resp.StatusCode)
+	}
+
+	resp = checkoutWithCode(t, shop.URL, "4242424242424242", "Welcome10")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("want HTTP 201, got %d", resp.StatusCode)
+	}
+	var receipt coffeeshop.Receipt
+	if err := json.NewDecoder(resp.Body).Decode(&receipt); err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Subtotal.String() != "15.98" || receipt.Discount.String() != "1.60" || receipt.Total.String() != "14.38" {
+		t.Errorf("want 15.98 less 1.60 making 14.38, got %+v", receipt)
+	}
+	if receipt.DiscountCode != "WELCOME10" {
+		t.Errorf("want discount code WELCOME10, got %q", receipt.DiscountCode)
+	}
+
+	resp = checkoutWithCode(t, shop.URL, "4242424242424242", "WELCOME10")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("want HTTP 422 for used up code, got %d", resp.StatusCode)
+	}
+	if code := errorCodeOf(t, resp); code != "promotion_exhausted" {
+		t.Errorf("want code promotion_exhausted, got %q", code)
+	}
+}
+
+func TestServer_StoresDiscountedTotalWithOrder(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(10)}, "10ms", t,
+		coffeeshop.WithOrderInterval("1h"),
+	)
+	createPromotion(t, shop.URL, `{"code":"WELCOME10","kind":"percentage","percent":10}`).Body.Close()
+
+	resp := checkoutWithCode(t, shop.URL, "4242424242424242", "WELCOME10")
+	defer resp.Body.Close()
+	var receipt coffeeshop.Receipt
+	if err := json.NewDecoder(resp.Body).Decode(&receipt); err != nil {
+		t.Fatal(err)
+	}
+	order, err := shop.OrderStore.GetOrder(receipt.OrderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Total == nil || order.Total.String() != "14.38" {
+		t.Errorf("want stored total 14.38, got %v", order.Total)
+	}
+	if order.Discount == nil || order.Discount.String() != "1.60" || order.DiscountCode != "WELCOME10" {
+		t.Errorf("want stored discount 1.60 of WELCOME10, got %v of %q", order.Discount, order.DiscountCode)
+	}
+}
+
+func TestServer_CapsFixedDiscountAtTotal(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(10)}, "10ms", t,
+		coffeeshop.WithOrderInterval("1h"),
+	)
+	createPromotion(t, shop.URL, `{"code":"FREE","kind":"fixed","amount":"50.00"}`).Body.Close()
+
+	resp := checkoutWithCode(t, shop.URL, "4242424242424242", "FREE")
+	defer resp.Body.Close()
+	var receipt coffeeshop.Receipt
+	if err := json.NewDecoder(resp.Body).Decode(&receipt); err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Discount.String() != "15.98" || receipt.Total.String() != "0.00" {
+		t.Errorf("want discount of the whole total, got %+v", receipt)
+	}
+}
+
+func TestServer_ReleasesDiscountCodeOfDeclinedPayment(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(10)}, "10ms", t,
+		coffeeshop.WithOrderInterval("1h"),
+	)
+	createPromotion(t, shop.URL, `{"code":"ONCE","kind":"fixed","amount":"1.00","maxUses":1}`).Body.Close()
+
+	resp := checkoutWithCode(t, shop.URL, coffeeshop.CardDeclined, "ONCE")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPaymentRequired {
+		t.Fatalf("want HTTP 402, got %d", resp.StatusCode)
+	}
+	resp = checkoutWithCode(t, shop.URL, "4242424242424242", "ONCE")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("want HTTP 201 after declined payment, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_RejectsExpiredAndUnknownDiscountCodes(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(10)}, "10ms", t)
+	expired := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	createPromotion(t, shop.URL, `{"code":"OLD","kind":"percentage","percent":50,"expiresAt":"`+expired+`"}`).Body.Close()
+
+	tcs := []struct {
+		code       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"OLD", http.StatusUnprocessableEntity, "promotion_expired"},
+		{"BOGUS", http.StatusNotFound, "promotion_not_found"},
+	}
+	for _, tc := range tcs {
+		resp := checkoutWithCode(t, shop.URL, "4242424242424242", tc.code)
+		if resp.StatusCode != tc.wantStatus {
+			t.Errorf("%s: want HTTP %d, got %d", tc.code, tc.wantStatus, resp.StatusCode)
+		}
+		if code := errorCodeOf(t, resp); code != tc.wantCode {
+			t.Errorf("%s: want code %s, got %q", tc.code, tc.wantCode, code)
+		}
+		resp.Body.Close()
+	}
+}
+
+func TestServer_ValidatesPromotions(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{}, "10ms", t)
+	tcs := []struct {
+		name, body string
+		want       int
+	}{
+		{"valid", `{"code":"SPRING","kind":"percentage","percent":15}`, http.StatusCreated},
+		{"duplicate code", `{"code":"spring","kind":"fixed","amount":"2.00"}`, http.StatusConflict},
+		{"missing code", `{"kind":"percentage","percent":15}`, http.StatusBadRequest},
+		{"percent over 100", `{"code":"MAX","kind":"percentage","percent":150}`, http.StatusBadRequest},
+		{"fixed without amount", `{"code":"NONE","kind":"fixed"}`, http.StatusBadRequest},
+		{"unknown kind", `{"code":"BOGO","kind":"bogo"}`, http.StatusBadRequest},
+	}
+	for _, tc := range tcs {
+		resp := createPromotion(t, shop.URL, tc.body)
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s: want HTTP %d, got %d", tc.name, tc.want, resp.StatusCode)
+		}
+	}
+
+	resp, err := http.Get(shop.URL + "admin/promotions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var px []coffeeshop.Promotion
+	if err := json.NewDecoder(resp.Body).Decode(&px); err != nil {
+		t.Fatal(err)
+	}
+	if len(px) != 1 || px[0].Code != "SPRING" {
+		t.Errorf("want only SPRING promotion, got %+v", px)
+	}
+}
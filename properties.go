@@ -0,0 +1,208 @@
+package coffeeshop
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// PropertyType is the type of values of a product property.
+type PropertyType string
+
+// Types of property values.
+const (
+	PropertyString PropertyType = "string"
+	PropertyInt    PropertyType = "int"
+	PropertyEnum   PropertyType = "enum"
+)
+
+// PropertyDefinition defines the name, the type and the allowed
+// values of a product property.
+type PropertyDefinition struct {
+	Name        string       `json:"name"`
+	Type        PropertyType `json:"type"`
+	Description string       `json:"description,omitempty"`
+	// Values are the allowed values of enum properties.
+	Values []string `json:"values,omitempty"`
+	// Min and Max bound values of int properties. Nil means
+	// the value isn't bounded.
+	Min *int `json:"min,omitempty"`
+	Max *int `json:"max,omitempty"`
+	// MaxLength limits the length of string values.
+	// Zero means no limit.
+	MaxLength int `json:"max_length,omitempty"`
+}
+
+// PropertySchema defines properties of products, so clients can
+// build forms for them and the server can validate their values.
+type PropertySchema struct {
+	// Strict rejects properties the schema doesn't define.
+	// Otherwise they're accepted as free-form strings.
+	Strict     bool                 `json:"strict"`
+	Properties []PropertyDefinition `json:"properties"`
+}
+
+// DefaultPropertySchema is the schema of product properties used
+// unless WithPropertySchema replaces it. It defines the properties
+// of the sample inventory. It isn't strict, so products can have
+// properties it doesn't define.
+var DefaultPropertySchema = PropertySchema{
+	Properties: []PropertyDefinition{
+		{Name: "flavour", Type: PropertyString, Description: "Comma-separated tasting notes, for example \"Nuts, Caramel\"", MaxLength: 200},
+		{Name: "property", Type: PropertyString, Description: "Package size and blend, for example \"250 grams, Arabica\"", MaxLength: 100},
+		{Name: "intensity", Type: PropertyString, Description: "Intensity on a scale up to 10, for example \"Medium (6/10)\"", MaxLength: 50},
+	},
+}
+
+// WithPropertySchema validates product properties against the schema
+// and serves it at GET /properties/schema.
+func WithPropertySchema(schema PropertySchema) Option {
+	return func(s *Server) error {
+		if err := schema.Validate(); err != nil {
+			return err
+		}
+		s.PropertySchema = schema
+		return nil
+	}
+}
+
+// Validate checks that the definitions of the schema have unique
+// names and known types, that enums have values and that bounds
+// of ints aren't crossed.
+func (s PropertySchema) Validate() error {
+	var errs []error
+	seen := make(map[string]bool, len(s.Properties))
+	for _, d := range s.Properties {
+		name := strings.ToLower(strings.TrimSpace(d.Name))
+		switch {
+		case name == "":
+			errs = append(errs, errors.New("property without name"))
+			continue
+		case seen[name]:
+			errs = append(errs, fmt.Errorf("duplicate property %q", d.Name))
+		}
+		seen[name] = true
+		switch d.Type {
+		case PropertyString:
+			if d.MaxLength < 0 {
+				errs = append(errs, fmt.Errorf("property %q: negative max length %d", d.Name, d.MaxLength))
+			}
+		case PropertyInt:
+			if d.Min != nil && d.Max != nil && *d.Min > *d.Max {
+				errs = append(errs, fmt.Errorf("property %q: min %d above max %d", d.Name, *d.Min, *d.Max))
+			}
+		case PropertyEnum:
+			if len(d.Values) == 0 {
+				errs = append(errs, fmt.Errorf("property %q: enum without values", d.Name))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("property %q: unknown type %q, want string, int or enum", d.Name, d.Type))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// definition returns the definition of the property with the name,
+// compared case-insensitively.
+func (s PropertySchema) definition(name string) (PropertyDefinition, bool) {
+	name = strings.TrimSpace(name)
+	for _, d := range s.Properties {
+		if strings.EqualFold(d.Name, name) {
+			return d, true
+		}
+	}
+	return PropertyDefinition{}, false
+}
+
+// check returns a problem with the value, or an empty
+// string if the value is valid.
+func (d PropertyDefinition) check(value string) string {
+	switch d.Type {
+	case PropertyInt:
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Sprintf("property %q must be an integer, got %q", d.Name, value)
+		}
+		if d.Min != nil && n < *d.Min {
+			return fmt.Sprintf("property %q must be at least %d, got %d", d.Name, *d.Min, n)
+		}
+		if d.Max != nil && n > *d.Max {
+			return fmt.Sprintf("property %q must be at most %d, got %d", d.Name, *d.Max, n)
+		}
+	case PropertyEnum:
+		for _, v := range d.Values {
+			if v == value {
+				return ""
+			}
+		}
+		return fmt.Sprintf("property %q must be one of %s, got %q", d.Name, strings.Join(d.Values, ", "), value)
+	case PropertyString:
+		if d.MaxLength > 0 && len([]rune(value)) > d.MaxLength {
+			return fmt.Sprintf("property %q must be at most %d characters long", d.Name, d.MaxLength)
+		}
+	}
+	return ""
+}
+
+// check returns problems of the properties not conforming
+// to the schema.
+func (s PropertySchema) check(props []Property) []FieldError {
+	var fields []FieldError
+	for i, prop := range props {
+		d, ok := s.definition(prop.Name)
+		if !ok {
+			if s.Strict && strings.TrimSpace(prop.Name) != "" {
+				fields = append(fields, FieldError{
+					Field:   fmt.Sprintf("properties[%d].name", i),
+					Message: fmt.Sprintf("unknown property %q", prop.Name),
+				})
+			}
+			continue
+		}
+		if problem := d.check(prop.Value); problem != "" {
+			fields = append(fields, FieldError{Field: fmt.Sprintf("properties[%d].value", i), Message: problem})
+		}
+	}
+	return fields
+}
+
+// schemaProduct validates the product together with
+// its properties against the schema.
+type schemaProduct struct {
+	Product
+	schema PropertySchema
+}
+
+func (sp schemaProduct) Validate() error {
+	var fields []FieldError
+	err := sp.Product.Validate()
+	var verr *ValidationError
+	switch {
+	case errors.As(err, &verr):
+		fields = verr.Fields
+	case err != nil:
+		return err
+	}
+	fields = append(fields, sp.schema.check(sp.Properties)...)
+	if len(fields) > 0 {
+		return &ValidationError{Err: ErrInvalidProduct, Fields: fields}
+	}
+	return nil
+}
+
+// checked returns the product validated against
+// the property schema of the server.
+func (cs *Server) checked(p Product) Validator {
+	return schemaProduct{Product: p, schema: cs.PropertySchema}
+}
+
+// GetPropertySchema returns the schema of product properties.
+func (cs *Server) GetPropertySchema(w http.ResponseWriter, r *http.Request) {
+	schema := cs.PropertySchema
+	if schema.Properties == nil {
+		schema.Properties = []PropertyDefinition{}
+	}
+	writeJSON(w, r, http.StatusOK, schema)
+}
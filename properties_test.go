@@ -0,0 +1,144 @@
+package coffeeshop_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+var testPropertySchema = coffeeshop.PropertySchema{
+	Strict: true,
+	Properties: []coffeeshop.PropertyDefinition{
+		{Name: "origin", Type: coffeeshop.PropertyString, MaxLength: 10},
+		{Name: "roast", Type: coffeeshop.PropertyEnum, Values: []string{"light", "dark"}},
+		{Name: "altitude", Type: coffeeshop.PropertyInt},
+	},
+}
+
+func TestServer_ServesPropertySchema(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{}, "0s", t, coffeeshop.WithPropertySchema(testPropertySchema))
+	resp, err := http.Get(shop.URL + "properties/schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200, got %d", resp.StatusCode)
+	}
+	var got coffeeshop.PropertySchema
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(testPropertySchema, got) {
+		t.Error(cmp.Diff(testPropertySchema, got))
+	}
+}
+
+func TestServer_RejectsProductsWithPropertiesNotConformingToSchema(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{}, "0s", t, coffeeshop.WithPropertySchema(testPropertySchema))
+	body := `{"type": "Coffee", "brand": "illy", "name": "Classico", "properties": [
+		{"name": "origin", "value": "Central America"},
+		{"name": "Roast", "value": "medium"},
+		{"name": "altitude", "value": "high"},
+		{"name": "flavour", "value": "Caramel"}
+	]}`
+	resp, err := http.Post(shop.URL+"products", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("want HTTP 400, got %d", resp.StatusCode)
+	}
+	var e coffeeshop.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range e.Error.Fields {
+		got = append(got, f.Field)
+	}
+	want := []string{"properties[0].value", "properties[1].value", "properties[2].value", "properties[3].name"}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestServer_AcceptsProductsWithPropertiesConformingToSchema(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{}, "0s", t, coffeeshop.WithPropertySchema(testPropertySchema))
+	body := `{"type": "Coffee", "brand": "illy", "name": "Classico", "properties": [
+		{"name": "origin", "value": "Brazil"},
+		{"name": "roast", "value": "dark"},
+		{"name": "altitude", "value": "1200"}
+	]}`
+	resp, err := http.Post(shop.URL+"products", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("want HTTP 201, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_DefaultPropertySchemaDefinesPropertiesOfSampleInventory(t *testing.T) {
+	t.Parallel()
+
+	store, err := coffeeshop.OpenStore("memory://")
+	if err != nil {
+		t.Fatal(err)
+	}
+	strict := coffeeshop.DefaultPropertySchema
+	strict.Strict = true
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{}, "0s", t, coffeeshop.WithPropertySchema(strict))
+	for _, p := range store.GetAll() {
+		body, err := json.Marshal(coffeeshop.Product{Type: p.Type, Brand: p.Brand, Name: p.Name, Properties: p.Properties})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.Post(shop.URL+"products", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Errorf("product %s: want HTTP 201, got %d", p.ID, resp.StatusCode)
+		}
+	}
+}
+
+func TestWithPropertySchema_RejectsInvalidSchemas(t *testing.T) {
+	t.Parallel()
+
+	min, max := 2, 1
+	tests := map[string]coffeeshop.PropertyDefinition{
+		"missing name":  {Type: coffeeshop.PropertyString},
+		"unknown type":  {Name: "roast", Type: "float"},
+		"empty enum":    {Name: "roast", Type: coffeeshop.PropertyEnum},
+		"crossed bound": {Name: "altitude", Type: coffeeshop.PropertyInt, Min: &min, Max: &max},
+	}
+	for name, d := range tests {
+		schema := coffeeshop.PropertySchema{Properties: []coffeeshop.PropertyDefinition{d}}
+		if _, err := coffeeshop.New("", &coffeeshop.MemoryStore{}, coffeeshop.WithPropertySchema(schema)); err == nil {
+			t.Errorf("%s: want error", name)
+		}
+	}
+	duplicate := coffeeshop.PropertySchema{Properties: []coffeeshop.PropertyDefinition{
+		{Name: "roast", Type: coffeeshop.PropertyString},
+		{Name: "Roast", Type: coffeeshop.PropertyString},
+	}}
+	if _, err := coffeeshop.New("", &coffeeshop.MemoryStore{}, coffeeshop.WithPropertySchema(duplicate)); err == nil {
+		t.Error("duplicate property: want error")
+	}
+}
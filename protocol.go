@@ -0,0 +1,65 @@
+package coffeeshop
+
+import (
+	"crypto/tls"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// protocolHeader is the response header reporting the protocol
+// which served the request, for example "HTTP/2.0".
+const protocolHeader = "X-Protocol"
+
+// WithTLS configures the server to serve HTTPS using the certificate
+// and the private key in PEM files. Clients supporting HTTP/2 use it
+// automatically; others fall back to HTTP/1.1.
+func WithTLS(certFile, keyFile string) Option {
+	return func(s *Server) error {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return err
+		}
+		s.HTTPServer.TLSConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+		s.URL = "https://" + strings.TrimPrefix(s.URL, "http://")
+		return nil
+	}
+}
+
+// WithH2C configures the server to serve cleartext HTTP/2 (h2c) to
+// clients starting connections with the HTTP/2 preface ("prior
+// knowledge") or upgrading HTTP/1.1 connections. Other clients are
+// served HTTP/1.1. Servers with TLS negotiate HTTP/2 regardless.
+func WithH2C() Option {
+	return func(s *Server) error {
+		s.H2C = true
+		return nil
+	}
+}
+
+// serveH2C wraps the handler of the server to serve h2c. HTTP/2
+// connections are closed gracefully on shutdown. Configuring the
+// server for HTTP/2 sets its TLS config, so servers are checked for
+// TLS before.
+func (cs *Server) serveH2C() error {
+	h2s := &http2.Server{IdleTimeout: cs.HTTPServer.IdleTimeout}
+	if err := http2.ConfigureServer(cs.HTTPServer, h2s); err != nil {
+		return err
+	}
+	cs.HTTPServer.Handler = h2c.NewHandler(cs.HTTPServer.Handler, h2s)
+	return nil
+}
+
+// reportProtocol sets the header reporting the protocol of the request.
+func reportProtocol(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(protocolHeader, r.Proto)
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
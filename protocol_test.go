@@ -0,0 +1,166 @@
+package coffeeshop_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qba73/coffeeshop"
+	"golang.org/x/net/http2"
+)
+
+// writeSelfSignedCert writes a self-signed certificate for
+// localhost and its key to PEM files and returns their paths.
+func writeSelfSignedCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestServer_ServesHTTP2OverTLS(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	certFile, keyFile := writeSelfSignedCert(t)
+	shop := newCoffeShopTestServer(store, "10ms", t, coffeeshop.WithTLS(certFile, keyFile))
+	if !strings.HasPrefix(shop.URL, "https://") {
+		t.Fatalf("want HTTPS URL, got %s", shop.URL)
+	}
+	client := http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			ForceAttemptHTTP2: true,
+		},
+	}
+	var resp *http.Response
+	var err error
+	for i := 0; i < 50; i++ {
+		resp, err = client.Get(shop.URL + "products/1")
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.ProtoMajor != 2 {
+		t.Errorf("want HTTP/2, got %s", resp.Proto)
+	}
+	if got := resp.Header.Get("X-Protocol"); got != "HTTP/2.0" {
+		t.Errorf("want X-Protocol HTTP/2.0, got %q", got)
+	}
+}
+
+func TestServer_ReportsHTTP1Protocol(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp, err := http.Get(shop.URL + "healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got := resp.Header.Get("X-Protocol"); got != "HTTP/1.1" {
+		t.Errorf("want X-Protocol HTTP/1.1, got %q", got)
+	}
+}
+
+func TestServer_ServesH2CWithPriorKnowledge(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(1)}, "0s", t, coffeeshop.WithH2C())
+	client := http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+		},
+	}
+	resp, err := client.Get(shop.URL + "products/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 2 {
+		t.Errorf("want HTTP 200 over HTTP/2, got %d over %s", resp.StatusCode, resp.Proto)
+	}
+	if got := resp.Header.Get("X-Protocol"); got != "HTTP/2.0" {
+		t.Errorf("want X-Protocol HTTP/2.0, got %q", got)
+	}
+
+	resp, err = http.Get(shop.URL + "products/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("X-Protocol"); got != "HTTP/1.1" {
+		t.Errorf("want HTTP/1.1 clients served HTTP/1.1, got %q", got)
+	}
+}
+
+func TestWithTLS_FailsOnMissingCertificate(t *testing.T) {
+	t.Parallel()
+
+	_, err := coffeeshop.New(":0", &coffeeshop.MemoryStore{}, coffeeshop.WithTLS("missing.pem", "missing-key.pem"))
+	if err == nil {
+		t.Error("want error on missing certificate")
+	}
+}
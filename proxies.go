@@ -0,0 +1,114 @@
+package coffeeshop
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// forwardedHeaders are headers set by proxies to describe
+// the client and the URL it requested.
+var forwardedHeaders = []string{"Forwarded", "X-Forwarded-For", "X-Forwarded-Host", "X-Forwarded-Proto", "X-Real-IP"}
+
+// WithTrustedProxies configures addresses of proxies in front of the
+// server, as CIDRs like "10.0.0.0/8" or single IP addresses. Client
+// IP addresses are taken from the X-Forwarded-For or X-Real-IP headers
+// of requests from trusted proxies, and links use the forwarded host
+// and protocol. Forwarded headers of other requests, and of every
+// request when no proxies are trusted, are ignored.
+func WithTrustedProxies(cidrs ...string) Option {
+	return func(s *Server) error {
+		for _, cidr := range cidrs {
+			prefix, err := parseProxy(cidr)
+			if err != nil {
+				return err
+			}
+			s.TrustedProxies = append(s.TrustedProxies, prefix)
+		}
+		return nil
+	}
+}
+
+// parseProxy parses the CIDR or the IP address of a proxy.
+func parseProxy(cidr string) (netip.Prefix, error) {
+	cidr = strings.TrimSpace(cidr)
+	if !strings.Contains(cidr, "/") {
+		addr, err := netip.ParseAddr(cidr)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid proxy address %q", cidr)
+		}
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid proxy CIDR %q", cidr)
+	}
+	return prefix.Masked(), nil
+}
+
+// trusts reports whether the address is of a trusted proxy.
+func (cs *Server) trusts(ip string) bool {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range cs.TrustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the client of the request
+// forwarded by a trusted proxy. Addresses in X-Forwarded-For
+// are appended by each proxy, so the client is the rightmost
+// address not of a trusted proxy.
+func (cs *Server) clientIP(r *http.Request) string {
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if _, err := netip.ParseAddr(hop); err != nil {
+				break
+			}
+			if i == 0 || !cs.trusts(hop) {
+				return hop
+			}
+		}
+	}
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+		if _, err := netip.ParseAddr(ip); err == nil {
+			return ip
+		}
+	}
+	return ""
+}
+
+// realIP replaces the remote address of requests from trusted
+// proxies with the address of the client, so handlers and
+// middlewares see the client. Forwarded headers of requests
+// from other peers are removed, so they can't be spoofed.
+func (cs *Server) realIP(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		peer, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			peer = r.RemoteAddr
+		}
+		if !cs.trusts(peer) {
+			for _, h := range forwardedHeaders {
+				r.Header.Del(h)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		if ip := cs.clientIP(r); ip != "" {
+			r.RemoteAddr = net.JoinHostPort(ip, "0")
+		}
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
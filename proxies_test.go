@@ -0,0 +1,113 @@
+package coffeeshop_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// reportClient reports the remote address of the request
+// seen by handlers in the X-Test-Client response header.
+func reportClient(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Test-Client", r.RemoteAddr)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// getForwarded sends the GET request with the headers.
+func getForwarded(t *testing.T, url string, header map[string]string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, v := range header {
+		req.Header.Set(name, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestServer_TakesClientIPFromTrustedProxies(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t,
+		coffeeshop.WithTrustedProxies("127.0.0.1", "10.0.0.0/8"),
+		coffeeshop.WithMiddleware(reportClient),
+	)
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   string
+	}{
+		{name: "X-Forwarded-For", header: map[string]string{"X-Forwarded-For": "203.0.113.7"}, want: "203.0.113.7:0"},
+		{name: "proxy chain", header: map[string]string{"X-Forwarded-For": "198.51.100.1, 203.0.113.7, 10.1.2.3"}, want: "203.0.113.7:0"},
+		{name: "X-Real-IP", header: map[string]string{"X-Real-IP": "2001:db8::1"}, want: "[2001:db8::1]:0"},
+	}
+	for _, tc := range tests {
+		resp := getForwarded(t, shop.URL+"healthz", tc.header)
+		if got := resp.Header.Get("X-Test-Client"); got != tc.want {
+			t.Errorf("%s: want client %s, got %s", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestServer_IgnoresForwardedHeadersOfUntrustedPeers(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t,
+		coffeeshop.WithTrustedProxies("10.0.0.0/8"),
+		coffeeshop.WithMiddleware(reportClient),
+		coffeeshop.WithLinks(),
+	)
+	resp := getForwarded(t, shop.URL+"healthz", map[string]string{"X-Forwarded-For": "203.0.113.7"})
+	if got := resp.Header.Get("X-Test-Client"); !strings.HasPrefix(got, "127.0.0.1:") {
+		t.Errorf("want client address of the peer, got %s", got)
+	}
+	got := getLinks(t, shop.URL+"products/3", map[string]string{"X-Forwarded-Host": "evil.example.com"})
+	if want := shop.URL + "products/3"; got["self"].Href != want {
+		t.Errorf("want self link %s, got %s", want, got["self"].Href)
+	}
+}
+
+func TestServer_IgnoresForwardedHeadersWithoutTrustedProxies(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t,
+		coffeeshop.WithMiddleware(reportClient),
+		coffeeshop.WithLinks(),
+	)
+	resp := getForwarded(t, shop.URL+"healthz", map[string]string{"X-Forwarded-For": "203.0.113.7"})
+	if got := resp.Header.Get("X-Test-Client"); !strings.HasPrefix(got, "127.0.0.1:") {
+		t.Errorf("want client address of the peer, got %s", got)
+	}
+	tests := []struct {
+		name   string
+		header map[string]string
+	}{
+		{name: "Forwarded", header: map[string]string{"Forwarded": `proto=https;host="evil.example.com"`}},
+		{name: "X-Forwarded", header: map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.example.com"}},
+	}
+	for _, tc := range tests {
+		got := getLinks(t, shop.URL+"products/3", tc.header)
+		if want := shop.URL + "products/3"; got["self"].Href != want {
+			t.Errorf("%s: want self link %s, got %s", tc.name, want, got["self"].Href)
+		}
+	}
+}
+
+func TestWithTrustedProxies_RejectsInvalidAddresses(t *testing.T) {
+	t.Parallel()
+
+	_, err := coffeeshop.New(":0", &coffeeshop.MemoryStore{}, coffeeshop.WithTrustedProxies("10.0.0.0/33"))
+	if err == nil {
+		t.Error("want error for invalid CIDR")
+	}
+}
@@ -0,0 +1,109 @@
+package coffeeshop
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// randomSource is a source of random numbers safe
+// for concurrent use by request handlers.
+type randomSource struct {
+	mx sync.Mutex
+	r  *rand.Rand
+}
+
+func newRandomSource(seed int64) *randomSource {
+	return &randomSource{r: rand.New(rand.NewSource(seed))}
+}
+
+// float64 returns a number in [0.0, 1.0).
+func (rs *randomSource) float64() float64 {
+	rs.mx.Lock()
+	defer rs.mx.Unlock()
+	return rs.r.Float64()
+}
+
+// duration returns a duration in [0, max].
+func (rs *randomSource) duration(max time.Duration) time.Duration {
+	rs.mx.Lock()
+	defer rs.mx.Unlock()
+	return time.Duration(rs.r.Int63n(int64(max) + 1))
+}
+
+// WithRandomSeed seeds random behaviours of the server, latency
+// jitter, injected faults and backoffs of store retries, so chaos
+// scenarios play the same way across test runs. Without a seed the server seeds them
+// with the time it was created.
+func WithRandomSeed(seed int64) Option {
+	return func(s *Server) error {
+		s.random = newRandomSource(seed)
+		return nil
+	}
+}
+
+// WithLatencyJitter adds a random latency of up to the given
+// duration, for example "500ms", to requests of delayed route
+// groups on top of the configured latency.
+func WithLatencyJitter(j string) Option {
+	return func(s *Server) error {
+		d, err := time.ParseDuration(j)
+		if err != nil {
+			return err
+		}
+		if d < 0 {
+			return errors.New("latency jitter can't be negative")
+		}
+		s.LatencyJitter = d
+		return nil
+	}
+}
+
+// WithFaults makes the server respond to the given fraction of
+// API requests, picked at random, with the error status instead
+// of serving them. For example, WithFaults(0.1, 503) fails one
+// in ten requests on average.
+func WithFaults(rate float64, status int) Option {
+	return func(s *Server) error {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("fault rate %v isn't between 0 and 1", rate)
+		}
+		if status < 400 || status > 599 {
+			return fmt.Errorf("fault status %d isn't an error status", status)
+		}
+		s.FaultRate = rate
+		s.FaultStatus = status
+		return nil
+	}
+}
+
+// jitter holds requests for a random part of the latency jitter.
+func (cs *Server) jitter(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		t := time.NewTimer(cs.random.duration(cs.LatencyJitter))
+		select {
+		case <-r.Context().Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// injectFaults responds to randomly picked requests with
+// the fault status.
+func (cs *Server) injectFaults(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if cs.random.float64() < cs.FaultRate {
+			writeError(w, r, cs.FaultStatus, http.StatusText(cs.FaultStatus))
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,62 @@
+package coffeeshop_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// faultPattern returns statuses of n requests to the server.
+func faultPattern(t *testing.T, url string, n int) []int {
+	t.Helper()
+	statuses := make([]int, n)
+	for i := range statuses {
+		resp, err := http.Get(url + "products")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		statuses[i] = resp.StatusCode
+	}
+	return statuses
+}
+
+func TestServer_InjectsSameFaultsWithSameRandomSeed(t *testing.T) {
+	t.Parallel()
+
+	var patterns [][]int
+	for i := 0; i < 2; i++ {
+		shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t,
+			coffeeshop.WithFaults(0.5, http.StatusServiceUnavailable),
+			coffeeshop.WithRandomSeed(42),
+		)
+		patterns = append(patterns, faultPattern(t, shop.URL, 20))
+	}
+	faults := 0
+	for i, status := range patterns[0] {
+		if status != patterns[1][i] {
+			t.Fatalf("want same statuses with same seed, got %v and %v", patterns[0], patterns[1])
+		}
+		if status == http.StatusServiceUnavailable {
+			faults++
+		}
+	}
+	if faults == 0 || faults == len(patterns[0]) {
+		t.Errorf("want some of requests failed, got %v", patterns[0])
+	}
+}
+
+func TestNew_ReturnsErrorOnInvalidFaults(t *testing.T) {
+	t.Parallel()
+
+	for _, opt := range []coffeeshop.Option{
+		coffeeshop.WithFaults(1.5, http.StatusServiceUnavailable),
+		coffeeshop.WithFaults(0.5, http.StatusOK),
+		coffeeshop.WithLatencyJitter("-1s"),
+	} {
+		if _, err := coffeeshop.New(":0", &coffeeshop.MemoryStore{}, opt); err == nil {
+			t.Error("want error on invalid option")
+		}
+	}
+}
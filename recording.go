@@ -0,0 +1,314 @@
+package coffeeshop
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// RecordedRequest is a request in a recorded interaction.
+type RecordedRequest struct {
+	Method string       `json:"method"`
+	URL    string       `json:"url"`
+	Header http.Header  `json:"header,omitempty"`
+	Body   RecordedBody `json:"body,omitempty"`
+}
+
+// RecordedResponse is a response in a recorded interaction.
+type RecordedResponse struct {
+	Status int          `json:"status"`
+	Header http.Header  `json:"header,omitempty"`
+	Body   RecordedBody `json:"body,omitempty"`
+}
+
+// Interaction is a request served by the server with its response.
+type Interaction struct {
+	RecordedAt time.Time        `json:"recordedAt"`
+	Request    RecordedRequest  `json:"request"`
+	Response   RecordedResponse `json:"response"`
+}
+
+// RecordedBody is a request or response body. Text bodies
+// are encoded in JSON as strings and binary bodies, for
+// example images, as objects holding base64 encoded data.
+type RecordedBody []byte
+
+// MarshalJSON encodes the body as a string or, if it isn't
+// valid UTF-8, as an object with base64 encoded data.
+func (b RecordedBody) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(struct {
+		Base64 string `json:"base64"`
+	}{base64.StdEncoding.EncodeToString(b)})
+}
+
+// UnmarshalJSON decodes the body encoded by MarshalJSON.
+func (b *RecordedBody) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*b = RecordedBody(s)
+		return nil
+	}
+	var encoded struct {
+		Base64 string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded.Base64)
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// unrecordedHeaders holds response headers
+// that differ each time a response is served.
+var unrecordedHeaders = []string{"Date", "X-Request-Id"}
+
+// maxRecordedBodySize limits response bodies kept for recording.
+// Larger responses aren't recorded.
+const maxRecordedBodySize = 1 << 20
+
+// limitedBuffer keeps up to limit bytes written
+// to it and discards the rest.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); len(p) > room {
+		b.truncated = true
+		if room > 0 {
+			_, _ = b.Buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// streaming reports whether the response with the header
+// is a stream of Server-Sent Events, which never ends
+// while the client follows it.
+func streaming(h http.Header) bool {
+	mediaType, _, _ := strings.Cut(h.Get("Content-Type"), ";")
+	return strings.EqualFold(strings.TrimSpace(mediaType), "text/event-stream")
+}
+
+// WithRecording appends requests to API routes and responses
+// to them to the file as JSON objects separated by newlines.
+// Values of headers and JSON fields redacted from debug logs are
+// redacted from recordings too. Event streams and responses over
+// 1 MiB aren't recorded. Recorded files can be served with
+// WithReplay.
+func WithRecording(path string) Option {
+	return func(s *Server) error {
+		if path == "" {
+			return errors.New("empty recording path")
+		}
+		if s.ReplayPath != "" {
+			return errors.New("recording and replay can't be combined")
+		}
+		s.RecordingPath = path
+		return nil
+	}
+}
+
+// WithReplay serves API routes with responses recorded in the file
+// with WithRecording instead of the store. Requests are matched by
+// method, URL and body. Responses recorded for the same request are
+// served in the order they were recorded, repeating the last one.
+// Requests that weren't recorded respond with 404 Not Found.
+func WithReplay(path string) Option {
+	return func(s *Server) error {
+		if s.RecordingPath != "" {
+			return errors.New("recording and replay can't be combined")
+		}
+		interactions, err := readInteractions(path)
+		if err != nil {
+			return err
+		}
+		s.ReplayPath = path
+		s.replay = newCassette(interactions)
+		return nil
+	}
+}
+
+// readInteractions reads interactions recorded in the file.
+func readInteractions(path string) ([]Interaction, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var interactions []Interaction
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var i Interaction
+		if err := json.Unmarshal(scanner.Bytes(), &i); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		interactions = append(interactions, i)
+	}
+	return interactions, scanner.Err()
+}
+
+// recorder appends interactions to the recording file.
+type recorder struct {
+	mx sync.Mutex
+}
+
+func (rec *recorder) record(path string, i Interaction) error {
+	data, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+	rec.mx.Lock()
+	defer rec.mx.Unlock()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// record saves requests and responses to the recording file.
+func (cs *Server) record(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "can't read request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		out := limitedBuffer{limit: maxRecordedBodySize}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(&out)
+		next.ServeHTTP(ww, r)
+
+		if streaming(w.Header()) {
+			return
+		}
+		if out.truncated {
+			cs.logf("recording: response to %s %s over %d bytes not recorded", r.Method, r.URL, maxRecordedBodySize)
+			return
+		}
+		header := cs.redactedHeader(w.Header())
+		for _, h := range unrecordedHeaders {
+			header.Del(h)
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		i := Interaction{
+			RecordedAt: time.Now().UTC(),
+			Request: RecordedRequest{
+				Method: r.Method,
+				URL:    r.URL.RequestURI(),
+				Header: cs.redactedHeader(r.Header),
+				Body:   cs.redactBody(body),
+			},
+			Response: RecordedResponse{
+				Status: status,
+				Header: header,
+				Body:   cs.redactBody(out.Bytes()),
+			},
+		}
+		if err := cs.recorder.record(cs.RecordingPath, i); err != nil {
+			cs.logf("recording: can't record %s %s: %v", r.Method, r.URL, err)
+		}
+	}
+	return http.HandlerFunc(fn)
+}
+
+// cassette holds recorded responses to replay.
+type cassette struct {
+	mx        sync.Mutex
+	responses map[string][]RecordedResponse
+	// played counts responses served for each request.
+	played map[string]int
+}
+
+func newCassette(interactions []Interaction) *cassette {
+	c := cassette{
+		responses: make(map[string][]RecordedResponse),
+		played:    make(map[string]int),
+	}
+	for _, i := range interactions {
+		key := interactionKey(i.Request.Method, i.Request.URL, i.Request.Body)
+		c.responses[key] = append(c.responses[key], i.Response)
+	}
+	return &c
+}
+
+// interactionKey identifies requests matching each other.
+func interactionKey(method, url string, body []byte) string {
+	return method + " " + url + "\n" + string(body)
+}
+
+// play returns the next response recorded for the request.
+func (c *cassette) play(method, url string, body []byte) (RecordedResponse, bool) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	key := interactionKey(method, url, body)
+	responses := c.responses[key]
+	if len(responses) == 0 {
+		return RecordedResponse{}, false
+	}
+	n := c.played[key]
+	if n >= len(responses) {
+		n = len(responses) - 1
+	}
+	c.played[key]++
+	return responses[n], true
+}
+
+// replayRecording serves responses recorded for requests
+// instead of the next handler.
+func (cs *Server) replayRecording(_ http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "can't read request body")
+			return
+		}
+		// Requests are matched as they were recorded, with redacted values.
+		resp, ok := cs.replay.play(r.Method, r.URL.RequestURI(), cs.redactBody(body))
+		if !ok {
+			writeErrorCode(w, r, http.StatusNotFound, "not_recorded", fmt.Sprintf("no recorded response to %s %s", r.Method, r.URL.RequestURI()))
+			return
+		}
+		for name, values := range resp.Header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(resp.Status)
+		_, _ = w.Write(resp.Body)
+	}
+	return http.HandlerFunc(fn)
+}
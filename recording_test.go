@@ -0,0 +1,118 @@
+package coffeeshop_test
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// getResponse returns the status and the body of the response.
+func getResponse(t *testing.T, url string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestServer_ReplaysRecordedResponses(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+	recording := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(10)}, "1ms", t,
+		coffeeshop.WithRecording(path),
+		coffeeshop.WithOrderInterval("1h"),
+	)
+	_, products := getResponse(t, recording.URL+"products")
+	missing, _ := getResponse(t, recording.URL+"orders/1")
+	resp := createOrder(t, recording.URL, `{"items":[{"productId":"1","quantity":1}]}`)
+	resp.Body.Close()
+	found, order := getResponse(t, recording.URL+"orders/1")
+	if missing != http.StatusNotFound || found != http.StatusOK {
+		t.Fatalf("want HTTP 404 then 200 when recording, got %d and %d", missing, found)
+	}
+
+	replaying := newCoffeShopTestServer(&coffeeshop.MemoryStore{}, "1ms", t,
+		coffeeshop.WithReplay(path),
+	)
+	if status, body := getResponse(t, replaying.URL+"products"); status != http.StatusOK || body != products {
+		t.Errorf("want recorded products, got HTTP %d %s", status, body)
+	}
+	resp = createOrder(t, replaying.URL, `{"items":[{"productId":"1","quantity":1}]}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("Location") != "/orders/1" {
+		t.Errorf("want recorded HTTP 201 with location, got %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	for i, want := range []int{http.StatusNotFound, http.StatusOK, http.StatusOK} {
+		status, body := getResponse(t, replaying.URL+"orders/1")
+		if status != want {
+			t.Errorf("request %d: want HTTP %d, got %d", i+1, want, status)
+		}
+		if status == http.StatusOK && body != order {
+			t.Errorf("request %d: want recorded order %s, got %s", i+1, order, body)
+		}
+	}
+
+	resp, err := http.Get(replaying.URL + "products/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("want HTTP 404 for request not recorded, got %d", resp.StatusCode)
+	}
+	if code := errorCodeOf(t, resp); code != "not_recorded" {
+		t.Errorf("want code not_recorded, got %q", code)
+	}
+}
+
+func TestServer_RedactsCredentialsFromRecording(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+	recording := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(1)}, "1ms", t,
+		coffeeshop.WithRecording(path),
+	)
+	token := login(t, recording.URL, "heidi@example.com", "ristretto")
+	resp := sendAs(t, token, http.MethodGet, recording.URL+"customers/me", "")
+	resp.Body.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"ristretto", token} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("want %q redacted from recording, got %s", secret, data)
+		}
+	}
+
+	replaying := newCoffeShopTestServer(&coffeeshop.MemoryStore{}, "1ms", t,
+		coffeeshop.WithReplay(path),
+	)
+	resp = sendAs(t, "", http.MethodPost, replaying.URL+"customers/login", `{"email":"heidi@example.com","password":"ristretto"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("want recorded login replayed with HTTP 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestWithReplay_FailsOnMissingRecording(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "missing.jsonl")
+	if _, err := coffeeshop.New("localhost:0", &coffeeshop.MemoryStore{}, coffeeshop.WithReplay(path)); err == nil {
+		t.Error("want error for missing recording")
+	}
+}
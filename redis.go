@@ -0,0 +1,534 @@
+package coffeeshop
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisReserveRetries limits attempts to reserve stock
+// when concurrent writes modify the reserved products.
+const redisReserveRetries = 10
+
+// RedisStore stores products in Redis. Products are stored as JSON
+// strings under the "<prefix>product:<id>" keys, and the set
+// "<prefix>products" holds IDs of all products. Several servers
+// can share the store.
+//
+// Products are written with optimistic transactions, so concurrent
+// orders never reserve more products than there are in stock. The
+// set of IDs and the indexes of SKUs and EANs are written in the
+// same transactions as products, so they never miss a product.
+type RedisStore struct {
+	// Client connects to the Redis server.
+	Client *redis.Client
+	// Prefix is prepended to all keys.
+	Prefix string
+	// TTL expires products not updated for the duration.
+	// Zero TTL keeps products until they are deleted.
+	TTL time.Duration
+	// Timeout limits the time of store operations called
+	// without a context, or with a context without a deadline.
+	Timeout time.Duration
+	// Clock timestamps changes. Nil means the real time.
+	Clock Clock
+}
+
+// NewRedisStore returns the store using the Redis server
+// at the host:port address with default settings.
+func NewRedisStore(addr string) *RedisStore {
+	return redisStoreWith(&redis.Options{Addr: addr})
+}
+
+// redisStoreWith returns the store using the Redis
+// client with the options and default settings.
+func redisStoreWith(opts *redis.Options) *RedisStore {
+	return &RedisStore{
+		Client:  redis.NewClient(opts),
+		Prefix:  "coffeeshop:",
+		Timeout: 5 * time.Second,
+	}
+}
+
+// WithContext returns the store making calls with the context.
+func (rs *RedisStore) WithContext(ctx context.Context) Store {
+	return redisContextStore{rs: rs, ctx: ctx}
+}
+
+// redisContextStore makes calls of the Redis store with the context.
+type redisContextStore struct {
+	rs  *RedisStore
+	ctx context.Context
+}
+
+func (r redisContextStore) GetAll() []Product {
+	px, _ := r.rs.listAll(r.ctx)
+	return px
+}
+
+func (r redisContextStore) ListAll() ([]Product, error) {
+	return r.rs.listAll(r.ctx)
+}
+
+func (r redisContextStore) GetProduct(id string) (Product, error) {
+	return r.rs.getProduct(r.ctx, id)
+}
+
+func (r redisContextStore) GetMany(ids []string) ([]Product, error) {
+	return r.rs.getMany(r.ctx, ids)
+}
+
+func (r redisContextStore) GetBySKU(code string) (Product, error) {
+	return r.rs.getBySKU(r.ctx, code)
+}
+
+func (r redisContextStore) GetByType(productType string) []Product {
+	px, _ := r.rs.listByType(r.ctx, productType)
+	return px
+}
+
+func (r redisContextStore) ListByType(productType string) ([]Product, error) {
+	return r.rs.listByType(r.ctx, productType)
+}
+
+func (r redisContextStore) PutProduct(p Product) (Product, error) {
+	return r.rs.putProduct(r.ctx, p)
+}
+
+func (r redisContextStore) ReserveStock(items []OrderItem) error {
+	return r.rs.reserveStock(r.ctx, items)
+}
+
+func (r redisContextStore) ReleaseStock(items []OrderItem) error {
+	return r.rs.releaseStock(r.ctx, items)
+}
+
+func (r redisContextStore) SetStock(id string, stock int) (Product, error) {
+	return r.rs.setStock(r.ctx, id, stock)
+}
+
+// opContext returns the context of a store operation, limited
+// by Timeout unless the parent context has a deadline.
+func (rs *RedisStore) opContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := parent.Deadline(); ok {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, rs.Timeout)
+}
+
+// redisProduct is the representation of a product stored in Redis.
+// Prices are stored as objects to keep their currency.
+type redisProduct struct {
+	Product
+	Price redisMoney `json:"price"`
+	// ModifiedAt is the time of the last change
+	// stored by earlier versions instead of UpdatedAt.
+	ModifiedAt *time.Time `json:"modifiedAt,omitempty"`
+}
+
+// redisMoney is encoded in JSON as an object with amount and currency.
+type redisMoney Money
+
+func (rs *RedisStore) productKey(id string) string {
+	return rs.Prefix + "product:" + id
+}
+
+func (rs *RedisStore) idsKey() string {
+	return rs.Prefix + "products"
+}
+
+func (rs *RedisStore) skuKey(sku string) string {
+	return rs.Prefix + "sku:" + sku
+}
+
+func (rs *RedisStore) eanKey(ean string) string {
+	return rs.Prefix + "ean:" + ean
+}
+
+// GetAll returns all products in the store sorted by ID,
+// or no products if the server fails.
+func (rs *RedisStore) GetAll() []Product {
+	px, _ := rs.ListAll()
+	return px
+}
+
+// ListAll returns all products in the store sorted by ID. Products
+// are fetched with a single pipeline of GET commands, and the first
+// failed GET fails the listing.
+func (rs *RedisStore) ListAll() ([]Product, error) {
+	return rs.listAll(context.Background())
+}
+
+func (rs *RedisStore) listAll(parent context.Context) ([]Product, error) {
+	ctx, cancel := rs.opContext(parent)
+	defer cancel()
+	ids, err := rs.Client.SMembers(ctx, rs.idsKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+	px, err := rs.fetch(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	sortProducts(px)
+	return px, nil
+}
+
+// fetch returns products with the IDs in the order of the IDs,
+// fetched with a single pipeline of GET commands. Products which
+// aren't found, for example because they expired, are left out.
+func (rs *RedisStore) fetch(ctx context.Context, ids []string) ([]Product, error) {
+	cmds := make([]*redis.StringCmd, 0, len(ids))
+	_, err := rs.Client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range ids {
+			cmds = append(cmds, pipe.Get(ctx, rs.productKey(id)))
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	px := make([]Product, 0, len(ids))
+	for _, cmd := range cmds {
+		data, err := cmd.Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		p, err := decodeRedisProduct(data)
+		if err != nil {
+			return nil, err
+		}
+		px = append(px, p)
+	}
+	return px, nil
+}
+
+// GetProduct returns the product with the given ID.
+func (rs *RedisStore) GetProduct(id string) (Product, error) {
+	return rs.getProduct(context.Background(), id)
+}
+
+func (rs *RedisStore) getProduct(parent context.Context, id string) (Product, error) {
+	ctx, cancel := rs.opContext(parent)
+	defer cancel()
+	data, err := rs.Client.Get(ctx, rs.productKey(id)).Result()
+	if errors.Is(err, redis.Nil) {
+		return Product{}, ErrProductNotFound
+	}
+	if err != nil {
+		return Product{}, err
+	}
+	return decodeRedisProduct(data)
+}
+
+// GetMany returns products with the given IDs in the order of
+// the IDs, leaving out products which aren't found.
+func (rs *RedisStore) GetMany(ids []string) ([]Product, error) {
+	return rs.getMany(context.Background(), ids)
+}
+
+func (rs *RedisStore) getMany(parent context.Context, ids []string) ([]Product, error) {
+	ctx, cancel := rs.opContext(parent)
+	defer cancel()
+	return rs.fetch(ctx, ids)
+}
+
+// GetByType returns all products of the given type sorted by ID,
+// or no products if the server fails.
+func (rs *RedisStore) GetByType(productType string) []Product {
+	px, _ := rs.ListByType(productType)
+	return px
+}
+
+// ListByType returns all products of the given type sorted by ID.
+// Product types are matched case-insensitively.
+func (rs *RedisStore) ListByType(productType string) ([]Product, error) {
+	return rs.listByType(context.Background(), productType)
+}
+
+func (rs *RedisStore) listByType(parent context.Context, productType string) ([]Product, error) {
+	all, err := rs.listAll(parent)
+	if err != nil {
+		return nil, err
+	}
+	var px []Product
+	for _, p := range all {
+		if strings.EqualFold(p.Type, productType) {
+			px = append(px, p)
+		}
+	}
+	return px, nil
+}
+
+// GetBySKU returns the product with the SKU or, if no product has
+// the SKU, the product with the EAN. The "<prefix>sku:<sku>" and
+// "<prefix>ean:<ean>" keys index IDs of products by their codes.
+// Index keys are left behind when codes of products change, so
+// products found by them are checked to still have the code.
+func (rs *RedisStore) GetBySKU(code string) (Product, error) {
+	return rs.getBySKU(context.Background(), code)
+}
+
+func (rs *RedisStore) getBySKU(parent context.Context, code string) (Product, error) {
+	ctx, cancel := rs.opContext(parent)
+	defer cancel()
+	indexes := []struct {
+		key   string
+		field func(p Product) string
+	}{
+		{rs.skuKey(code), func(p Product) string { return p.SKU }},
+		{rs.eanKey(code), func(p Product) string { return p.EAN }},
+	}
+	for _, index := range indexes {
+		id, err := rs.Client.Get(ctx, index.key).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return Product{}, err
+		}
+		p, err := rs.getProduct(ctx, id)
+		if errors.Is(err, ErrProductNotFound) {
+			continue
+		}
+		if err != nil {
+			return Product{}, err
+		}
+		if index.field(p) == code {
+			return p, nil
+		}
+	}
+	return Product{}, ErrProductNotFound
+}
+
+// PutProduct adds the product or replaces the product with the same ID,
+// and adds it to the set of IDs and the indexes of codes in the same
+// transaction. Adding a product with the SKU or EAN of another product
+// fails with ErrSKUExists.
+func (rs *RedisStore) PutProduct(p Product) (Product, error) {
+	return rs.putProduct(context.Background(), p)
+}
+
+func (rs *RedisStore) putProduct(parent context.Context, p Product) (Product, error) {
+	if p.ID == "" {
+		return Product{}, fmt.Errorf("%w: missing id", ErrInvalidProduct)
+	}
+	ctx, cancel := rs.opContext(parent)
+	defer cancel()
+	if err := codeTaken(func(code string) (Product, error) { return rs.getBySKU(ctx, code) }, p); err != nil {
+		return Product{}, err
+	}
+	put := p
+	err := rs.update(ctx, []string{p.ID}, func(px map[string]Product) error {
+		put = p
+		put.Version = px[p.ID].Version + 1
+		put = stamped(put, px[p.ID], now(rs.Clock))
+		px[p.ID] = put
+		return nil
+	})
+	if err != nil {
+		return Product{}, err
+	}
+	return put, nil
+}
+
+// ReserveStock decrements stock of all ordered products.
+// If any of the products doesn't have enough stock the store
+// is left unchanged and *OutOfStockError is returned.
+func (rs *RedisStore) ReserveStock(items []OrderItem) error {
+	return rs.reserveStock(context.Background(), items)
+}
+
+func (rs *RedisStore) reserveStock(parent context.Context, items []OrderItem) error {
+	ctx, cancel := rs.opContext(parent)
+	defer cancel()
+	requested := make(map[string]int)
+	for _, item := range items {
+		requested[item.ProductID] += item.Quantity
+	}
+	return rs.update(ctx, keysOf(requested), func(px map[string]Product) error {
+		modifiedAt := now(rs.Clock)
+		for id, quantity := range requested {
+			p, ok := px[id]
+			if !ok {
+				return ErrProductNotFound
+			}
+			if p.Stock < quantity {
+				return &OutOfStockError{ProductID: id, Requested: quantity, Available: p.Stock}
+			}
+			p.Stock -= quantity
+			p.Version++
+			p.UpdatedAt = &modifiedAt
+			px[id] = p
+		}
+		return nil
+	})
+}
+
+// ReleaseStock increments stock of all ordered products, giving
+// back stock reserved with ReserveStock. Products deleted since
+// the reservation are skipped.
+func (rs *RedisStore) ReleaseStock(items []OrderItem) error {
+	return rs.releaseStock(context.Background(), items)
+}
+
+func (rs *RedisStore) releaseStock(parent context.Context, items []OrderItem) error {
+	ctx, cancel := rs.opContext(parent)
+	defer cancel()
+	released := make(map[string]int)
+	for _, item := range items {
+		released[item.ProductID] += item.Quantity
+	}
+	return rs.update(ctx, keysOf(released), func(px map[string]Product) error {
+		modifiedAt := now(rs.Clock)
+		for id, quantity := range released {
+			p, ok := px[id]
+			if !ok {
+				continue
+			}
+			p.Stock += quantity
+			p.Version++
+			p.UpdatedAt = &modifiedAt
+			px[id] = p
+		}
+		return nil
+	})
+}
+
+// SetStock sets the stock of the product.
+func (rs *RedisStore) SetStock(id string, stock int) (Product, error) {
+	return rs.setStock(context.Background(), id, stock)
+}
+
+func (rs *RedisStore) setStock(parent context.Context, id string, stock int) (Product, error) {
+	if stock < 0 {
+		return Product{}, fmt.Errorf("%w: negative stock", ErrInvalidProduct)
+	}
+	ctx, cancel := rs.opContext(parent)
+	defer cancel()
+	var updated Product
+	err := rs.update(ctx, []string{id}, func(px map[string]Product) error {
+		p, ok := px[id]
+		if !ok {
+			return ErrProductNotFound
+		}
+		p.Stock = stock
+		p.Version++
+		modifiedAt := now(rs.Clock)
+		p.UpdatedAt = &modifiedAt
+		px[id] = p
+		updated = p
+		return nil
+	})
+	return updated, err
+}
+
+// Ping reports whether the Redis server is reachable.
+func (rs *RedisStore) Ping(ctx context.Context) error {
+	return rs.Client.Ping(ctx).Err()
+}
+
+// Close closes the client of the store.
+func (rs *RedisStore) Close() error {
+	return rs.Client.Close()
+}
+
+// update applies the change to the products in an optimistic
+// transaction, retrying if the products are modified concurrently.
+// Products the change leaves out of the map aren't written.
+func (rs *RedisStore) update(ctx context.Context, ids []string, change func(px map[string]Product) error) error {
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, rs.productKey(id))
+	}
+	tx := func(tx *redis.Tx) error {
+		values, err := tx.MGet(ctx, keys...).Result()
+		if err != nil {
+			return err
+		}
+		px := make(map[string]Product, len(ids))
+		for i, v := range values {
+			data, ok := v.(string)
+			if !ok {
+				continue
+			}
+			p, err := decodeRedisProduct(data)
+			if err != nil {
+				return err
+			}
+			px[ids[i]] = p
+		}
+		if err := change(px); err != nil {
+			return err
+		}
+		// EXEC fails with redis.TxFailedErr if a watched key
+		// was modified since WATCH.
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, id := range ids {
+				p, ok := px[id]
+				if !ok {
+					continue
+				}
+				if err := rs.write(ctx, pipe, p); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		return err
+	}
+	for attempt := 0; attempt < redisReserveRetries; attempt++ {
+		err := rs.Client.Watch(ctx, tx, keys...)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return errors.New("redis: too many concurrent updates")
+}
+
+// write queues the commands storing the product and adding
+// it to the set of IDs and the indexes of its codes.
+func (rs *RedisStore) write(ctx context.Context, pipe redis.Pipeliner, p Product) error {
+	data, err := json.Marshal(redisProduct{Product: p, Price: redisMoney(p.Price)})
+	if err != nil {
+		return err
+	}
+	pipe.Set(ctx, rs.productKey(p.ID), data, rs.TTL)
+	pipe.SAdd(ctx, rs.idsKey(), p.ID)
+	if p.SKU != "" {
+		pipe.Set(ctx, rs.skuKey(p.SKU), p.ID, 0)
+	}
+	if p.EAN != "" {
+		pipe.Set(ctx, rs.eanKey(p.EAN), p.ID, 0)
+	}
+	return nil
+}
+
+func decodeRedisProduct(data string) (Product, error) {
+	var rp redisProduct
+	if err := json.Unmarshal([]byte(data), &rp); err != nil {
+		return Product{}, err
+	}
+	p := rp.Product
+	p.Price = Money(rp.Price)
+	if p.UpdatedAt == nil && rp.ModifiedAt != nil && !rp.ModifiedAt.IsZero() {
+		p.UpdatedAt = rp.ModifiedAt
+	}
+	return p, nil
+}
+
+func keysOf(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
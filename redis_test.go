@@ -0,0 +1,533 @@
+package coffeeshop_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+var (
+	_ coffeeshop.Store         = (*coffeeshop.RedisStore)(nil)
+	_ coffeeshop.ContextStore  = (*coffeeshop.RedisStore)(nil)
+	_ coffeeshop.StockReleaser = (*coffeeshop.RedisStore)(nil)
+)
+
+// fakeRedis is an in-process Redis server supporting the
+// subset of commands used by RedisStore.
+type fakeRedis struct {
+	mx       sync.Mutex
+	strings  map[string]string
+	expires  map[string]time.Time
+	sets     map[string]map[string]bool
+	versions map[string]int
+	// untransacted counts writes made outside MULTI and EXEC.
+	untransacted int
+}
+
+func newFakeRedis(t *testing.T) (*fakeRedis, string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	fr := &fakeRedis{
+		strings:  map[string]string{},
+		expires:  map[string]time.Time{},
+		sets:     map[string]map[string]bool{},
+		versions: map[string]int{},
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go fr.serve(conn)
+		}
+	}()
+	return fr, l.Addr().String()
+}
+
+func (fr *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	var watched map[string]int
+	var queued [][]string
+	inMulti := false
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		name := strings.ToUpper(args[0])
+		switch {
+		case name == "WATCH":
+			fr.mx.Lock()
+			if watched == nil {
+				watched = map[string]int{}
+			}
+			for _, k := range args[1:] {
+				watched[k] = fr.versions[k]
+			}
+			fr.mx.Unlock()
+			fmt.Fprint(w, "+OK\r\n")
+		case name == "UNWATCH":
+			watched = nil
+			fmt.Fprint(w, "+OK\r\n")
+		case name == "MULTI":
+			inMulti, queued = true, nil
+			fmt.Fprint(w, "+OK\r\n")
+		case name == "EXEC":
+			fr.mx.Lock()
+			conflict := false
+			for k, v := range watched {
+				if fr.versions[k] != v {
+					conflict = true
+				}
+			}
+			if conflict {
+				fmt.Fprint(w, "*-1\r\n")
+			} else {
+				fmt.Fprintf(w, "*%d\r\n", len(queued))
+				for _, cmd := range queued {
+					fr.exec(w, cmd)
+				}
+			}
+			fr.mx.Unlock()
+			inMulti, queued, watched = false, nil, nil
+		case inMulti:
+			queued = append(queued, args)
+			fmt.Fprint(w, "+QUEUED\r\n")
+		default:
+			fr.mx.Lock()
+			if name == "SET" || name == "SADD" {
+				fr.untransacted++
+			}
+			fr.exec(w, args)
+			fr.mx.Unlock()
+		}
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// exec runs the command. It must be called with the lock held.
+func (fr *fakeRedis) exec(w io.Writer, args []string) {
+	for k, at := range fr.expires {
+		if time.Now().After(at) {
+			delete(fr.strings, k)
+			delete(fr.expires, k)
+			fr.versions[k]++
+		}
+	}
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		fmt.Fprint(w, "+PONG\r\n")
+	case "AUTH", "SELECT":
+		fmt.Fprint(w, "+OK\r\n")
+	case "GET":
+		if _, ok := fr.sets[args[1]]; ok {
+			fmt.Fprint(w, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n")
+			return
+		}
+		writeBulk(w, fr.strings, args[1])
+	case "MGET":
+		fmt.Fprintf(w, "*%d\r\n", len(args)-1)
+		for _, k := range args[1:] {
+			writeBulk(w, fr.strings, k)
+		}
+	case "SET":
+		k := args[1]
+		fr.strings[k] = args[2]
+		delete(fr.expires, k)
+		if len(args) == 5 {
+			n, _ := strconv.Atoi(args[4])
+			switch strings.ToUpper(args[3]) {
+			case "PX":
+				fr.expires[k] = time.Now().Add(time.Duration(n) * time.Millisecond)
+			case "EX":
+				fr.expires[k] = time.Now().Add(time.Duration(n) * time.Second)
+			}
+		}
+		fr.versions[k]++
+		fmt.Fprint(w, "+OK\r\n")
+	case "SADD":
+		set := fr.sets[args[1]]
+		if set == nil {
+			set = map[string]bool{}
+			fr.sets[args[1]] = set
+		}
+		added := 0
+		for _, m := range args[2:] {
+			if !set[m] {
+				set[m] = true
+				added++
+			}
+		}
+		fmt.Fprintf(w, ":%d\r\n", added)
+	case "SMEMBERS":
+		set := fr.sets[args[1]]
+		fmt.Fprintf(w, "*%d\r\n", len(set))
+		for m := range set {
+			fmt.Fprintf(w, "$%d\r\n%s\r\n", len(m), m)
+		}
+	default:
+		fmt.Fprintf(w, "-ERR unknown command '%s'\r\n", args[0])
+	}
+}
+
+func writeBulk(w io.Writer, values map[string]string, key string) {
+	v, ok := values[key]
+	if !ok {
+		fmt.Fprint(w, "$-1\r\n")
+		return
+	}
+	fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, errors.New("want array")
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+// newRedisStore returns the store using a fake Redis server
+// populated with the given products.
+func newRedisStore(t *testing.T, products coffeeshop.Products) *coffeeshop.RedisStore {
+	t.Helper()
+	store, _ := newRedisStoreWithServer(t, products)
+	return store
+}
+
+// newRedisStoreWithServer returns the store populated with
+// the given products and the fake Redis server it uses.
+func newRedisStoreWithServer(t *testing.T, products coffeeshop.Products) (*coffeeshop.RedisStore, *fakeRedis) {
+	t.Helper()
+	fr, addr := newFakeRedis(t)
+	store := coffeeshop.NewRedisStore(addr)
+	t.Cleanup(func() { store.Close() })
+	for _, p := range products {
+		if _, err := store.PutProduct(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store, fr
+}
+
+// withoutMetadata clears versions and creation and
+// modification times set by stores.
+func withoutMetadata(px []coffeeshop.Product) []coffeeshop.Product {
+	for i := range px {
+		px[i].Version = 0
+		px[i].CreatedAt = nil
+		px[i].UpdatedAt = nil
+	}
+	return px
+}
+
+func TestRedisStore_GetsAllProducts(t *testing.T) {
+	t.Parallel()
+
+	store := newRedisStore(t, inventory)
+	memory := coffeeshop.MemoryStore{Products: inventory}
+	want := memory.GetAll()
+	got := withoutMetadata(store.GetAll())
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestRedisStore_GetsProductByIDAndType(t *testing.T) {
+	t.Parallel()
+
+	store := newRedisStore(t, inventory)
+	got, err := store.GetProduct("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.UpdatedAt == nil || got.Version != 1 {
+		t.Errorf("want modification time and version 1 stored, got %v and %d", got.UpdatedAt, got.Version)
+	}
+	got = withoutMetadata([]coffeeshop.Product{got})[0]
+	if !cmp.Equal(inventory["1"], got) {
+		t.Error(cmp.Diff(inventory["1"], got))
+	}
+
+	_, err = store.GetProduct("42")
+	if !errors.Is(err, coffeeshop.ErrProductNotFound) {
+		t.Errorf("want ErrProductNotFound, got %v", err)
+	}
+
+	memory := coffeeshop.MemoryStore{Products: inventory}
+	want := memory.GetByType("coffee")
+	gotType := withoutMetadata(store.GetByType("Coffee"))
+	if !cmp.Equal(want, gotType) {
+		t.Error(cmp.Diff(want, gotType))
+	}
+}
+
+func TestRedisStore_ReservesStockAtomically(t *testing.T) {
+	t.Parallel()
+
+	store := newRedisStore(t, stockedInventory(5))
+
+	var wg sync.WaitGroup
+	var mx sync.Mutex
+	reserved, outOfStock := 0, 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := store.ReserveStock([]coffeeshop.OrderItem{{ProductID: "1", Quantity: 1}})
+			mx.Lock()
+			defer mx.Unlock()
+			switch {
+			case err == nil:
+				reserved++
+			case errors.Is(err, coffeeshop.ErrOutOfStock):
+				outOfStock++
+			default:
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if reserved != 5 || outOfStock != 5 {
+		t.Errorf("want 5 reserved and 5 out of stock, got %d and %d", reserved, outOfStock)
+	}
+	p, err := store.GetProduct("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Stock != 0 {
+		t.Errorf("want stock 0, got %d", p.Stock)
+	}
+}
+
+func TestRedisStore_KeepsPriceCurrency(t *testing.T) {
+	t.Parallel()
+
+	p := inventory["1"]
+	p.Price = coffeeshop.Money{Amount: 850, Currency: "USD"}
+	store := newRedisStore(t, coffeeshop.Products{"1": p})
+	got, err := store.GetProduct("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(p.Price, got.Price) {
+		t.Error(cmp.Diff(p.Price, got.Price))
+	}
+}
+
+func TestRedisStore_SetsStock(t *testing.T) {
+	t.Parallel()
+
+	store := newRedisStore(t, inventory)
+	p, err := store.SetStock("1", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Stock != 7 {
+		t.Errorf("want stock 7, got %d", p.Stock)
+	}
+	_, err = store.SetStock("1", -1)
+	if !errors.Is(err, coffeeshop.ErrInvalidProduct) {
+		t.Errorf("want ErrInvalidProduct, got %v", err)
+	}
+	_, err = store.SetStock("42", 1)
+	if !errors.Is(err, coffeeshop.ErrProductNotFound) {
+		t.Errorf("want ErrProductNotFound, got %v", err)
+	}
+}
+
+func TestRedisStore_ExpiresProductsAfterTTL(t *testing.T) {
+	t.Parallel()
+
+	_, addr := newFakeRedis(t)
+	store := coffeeshop.NewRedisStore(addr)
+	store.TTL = 50 * time.Millisecond
+	t.Cleanup(func() { store.Close() })
+	if _, err := store.PutProduct(inventory["1"]); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(store.GetAll()); got != 1 {
+		t.Fatalf("want 1 product, got %d", got)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if got := len(store.GetAll()); got != 0 {
+		t.Errorf("want expired product skipped, got %d products", got)
+	}
+}
+
+func TestServer_ServesProductsFromRedisStore(t *testing.T) {
+	t.Parallel()
+
+	store := newRedisStore(t, inventory)
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp, err := http.Get(shop.URL + "readyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("want ready server, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(shop.URL + "products/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("want HTTP 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestRedisStore_FailsWhenServerIsUnreachable(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	store := coffeeshop.NewRedisStore(addr)
+	if _, err := store.GetProduct("1"); err == nil {
+		t.Error("want error for unreachable server")
+	}
+	if got := store.GetAll(); len(got) != 0 {
+		t.Errorf("want no products, got %v", got)
+	}
+}
+
+func TestRedisStore_WritesProductsAndIndexesInTransactions(t *testing.T) {
+	t.Parallel()
+
+	store, fr := newRedisStoreWithServer(t, inventory)
+	if _, err := store.PutProduct(coffeeshop.Product{ID: "42", Type: "coffee", SKU: "BEAN-42"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.SetStock("42", 3); err != nil {
+		t.Fatal(err)
+	}
+	fr.mx.Lock()
+	untransacted := fr.untransacted
+	fr.mx.Unlock()
+	if untransacted != 0 {
+		t.Errorf("want all writes in transactions, got %d writes outside", untransacted)
+	}
+	p, err := store.GetBySKU("BEAN-42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ID != "42" || p.Stock != 3 {
+		t.Errorf("want product 42 with stock 3 found by SKU, got %s with %d", p.ID, p.Stock)
+	}
+}
+
+func TestRedisStore_ReturnsErrorsOfListings(t *testing.T) {
+	t.Parallel()
+
+	store, fr := newRedisStoreWithServer(t, inventory)
+	shop := newCoffeShopTestServer(store, "0s", t)
+	// The key of product 9 holds a set, so fetching it fails.
+	fr.mx.Lock()
+	fr.sets["coffeeshop:products"]["9"] = true
+	fr.sets["coffeeshop:product:9"] = map[string]bool{"9": true}
+	fr.mx.Unlock()
+
+	if _, err := store.ListAll(); err == nil || !strings.Contains(err.Error(), "WRONGTYPE") {
+		t.Errorf("want error of the failed fetch, got %v", err)
+	}
+	if _, err := store.ListByType("coffee"); err == nil {
+		t.Error("want error listing products by type")
+	}
+	for _, path := range []string{"products", "products/coffee"} {
+		got, err := getStatus(t, http.DefaultClient, shop.URL+path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != http.StatusInternalServerError {
+			t.Errorf("%s: want HTTP 500 when a fetch fails, got %d", path, got)
+		}
+	}
+}
+
+func TestRedisStore_CallsServerWithContext(t *testing.T) {
+	t.Parallel()
+
+	store := newRedisStore(t, inventory)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := store.WithContext(ctx).GetProduct("1"); !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+	if _, err := store.WithContext(context.Background()).GetProduct("1"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRedisStore_ReleasesReservedStock(t *testing.T) {
+	t.Parallel()
+
+	store := newRedisStore(t, stockedInventory(5))
+	items := []coffeeshop.OrderItem{{ProductID: "1", Quantity: 2}, {ProductID: "42", Quantity: 1}}
+	if err := store.ReserveStock(items[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ReleaseStock(items); err != nil {
+		t.Fatal(err)
+	}
+	p, err := store.GetProduct("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Stock != 5 {
+		t.Errorf("want stock restored to 5, got %d", p.Stock)
+	}
+}
@@ -0,0 +1,144 @@
+package coffeeshop
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Default and maximum number of related products.
+const (
+	defaultRelatedLimit = 5
+	maxRelatedLimit     = 50
+)
+
+// Recommender selects products related to a product.
+type Recommender interface {
+	// Related returns up to limit products from the candidates
+	// related to the product, most related first.
+	Related(p Product, candidates []Product, limit int) []Product
+}
+
+// RecommenderFunc adapts a function to the Recommender interface.
+type RecommenderFunc func(p Product, candidates []Product, limit int) []Product
+
+// Related calls f(p, candidates, limit).
+func (f RecommenderFunc) Related(p Product, candidates []Product, limit int) []Product {
+	return f(p, candidates, limit)
+}
+
+// SimilarityRecommender relates products of the same type or brand
+// and products sharing flavours. Products of the same type weigh most,
+// then products of the same brand, then each shared flavour.
+type SimilarityRecommender struct{}
+
+// Weights of similarities of related products.
+const (
+	sameTypeWeight      = 3
+	sameBrandWeight     = 2
+	sharedFlavourWeight = 1
+)
+
+// Related returns up to limit candidates most similar to the product.
+// Candidates with nothing in common with the product aren't related.
+func (SimilarityRecommender) Related(p Product, candidates []Product, limit int) []Product {
+	type scored struct {
+		product Product
+		score   int
+	}
+	flavours := productFlavours(p)
+	var related []scored
+	for _, c := range candidates {
+		if c.ID == p.ID {
+			continue
+		}
+		score := 0
+		if strings.EqualFold(c.Type, p.Type) {
+			score += sameTypeWeight
+		}
+		if strings.EqualFold(c.Brand, p.Brand) {
+			score += sameBrandWeight
+		}
+		for f := range productFlavours(c) {
+			if flavours[f] {
+				score += sharedFlavourWeight
+			}
+		}
+		if score > 0 {
+			related = append(related, scored{product: c, score: score})
+		}
+	}
+	sort.SliceStable(related, func(i, j int) bool {
+		if related[i].score != related[j].score {
+			return related[i].score > related[j].score
+		}
+		return related[i].product.ID < related[j].product.ID
+	})
+	if len(related) > limit {
+		related = related[:limit]
+	}
+	px := make([]Product, 0, len(related))
+	for _, r := range related {
+		px = append(px, r.product)
+	}
+	return px
+}
+
+// productFlavours returns the set of lower case flavours of the
+// product, including flavours listed in its "flavour" properties.
+func productFlavours(p Product) map[string]bool {
+	flavours := make(map[string]bool)
+	for _, f := range PromoteProperties(p).Flavours {
+		flavours[strings.ToLower(f)] = true
+	}
+	return flavours
+}
+
+// WithRecommender configures the recommender selecting related products.
+func WithRecommender(r Recommender) Option {
+	return func(s *Server) error {
+		if r == nil {
+			return errors.New("nil recommender")
+		}
+		s.Recommender = r
+		return nil
+	}
+}
+
+// GetRelatedProducts returns products related to the product,
+// most related first. Archived products are never related. The
+// number of products is limited by the "limit" query parameter.
+func (cs *Server) GetRelatedProducts(w http.ResponseWriter, r *http.Request) {
+	product, err := cs.store(r).GetProduct(chi.URLParam(r, "productID"))
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	limit, err := queryInt(r, "limit", defaultRelatedLimit, 1, maxRelatedLimit)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	px, err := listAll(cs.store(r))
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	related := cs.Recommender.Related(product, withoutArchived(px), limit)
+	if len(related) > limit {
+		related = related[:limit]
+	}
+	if related == nil {
+		related = []Product{}
+	}
+	related, err = cs.convertPrices(w, r, related)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "unsupported currency")
+		return
+	}
+	cs.addRatings(related)
+	cs.writeProducts(w, r, related)
+}
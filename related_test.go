@@ -0,0 +1,94 @@
+package coffeeshop_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+// relatedCatalog holds products with overlapping types,
+// brands and flavours.
+var relatedCatalog = coffeeshop.Products{
+	"1": {ID: "1", Type: "Coffee", Brand: "Segafredo", Name: "Intermezzo", Properties: []coffeeshop.Property{{Name: "flavour", Value: "Nuts, Caramel, Aromatic Arabica"}}},
+	"2": {ID: "2", Type: "Coffee", Brand: "Segafredo", Name: "Crema", Properties: []coffeeshop.Property{{Name: "flavour", Value: "Nuts, Caramel"}}},
+	"3": {ID: "3", Type: "Coffee", Brand: "illy", Name: "Classico", Properties: []coffeeshop.Property{{Name: "flavour", Value: "caramel"}}},
+	"4": {ID: "4", Type: "Coffee", Brand: "Lavazza", Name: "Oro"},
+	"5": {ID: "5", Type: "Tea", Brand: "Segafredo", Name: "Earl Grey"},
+	"6": {ID: "6", Type: "Tea", Brand: "Lipton", Name: "Nutty", Properties: []coffeeshop.Property{{Name: "flavour", Value: "Nuts"}}},
+	"7": {ID: "7", Type: "Tea", Brand: "Twinings", Name: "Mint"},
+}
+
+func TestSimilarityRecommender_RanksProductsBySharedTypeBrandAndFlavours(t *testing.T) {
+	t.Parallel()
+
+	store := coffeeshop.MemoryStore{Products: relatedCatalog}
+	related := coffeeshop.SimilarityRecommender{}.Related(relatedCatalog["1"], store.GetAll(), 10)
+	got := make([]string, 0, len(related))
+	for _, p := range related {
+		got = append(got, p.ID)
+	}
+	// 2: type, brand and two flavours; 3: type and a flavour;
+	// 4: type; 5: brand; 6: a flavour; 7: nothing in common.
+	want := []string{"2", "3", "4", "5", "6"}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+
+	related = coffeeshop.SimilarityRecommender{}.Related(relatedCatalog["1"], store.GetAll(), 2)
+	if len(related) != 2 {
+		t.Errorf("want 2 related products, got %d", len(related))
+	}
+}
+
+func TestServer_ReturnsRelatedProducts(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: coffeeshop.Products{}}
+	for id, p := range relatedCatalog {
+		store.Products[id] = p
+	}
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	send(t, http.MethodDelete, shop.URL+"products/2").Body.Close()
+
+	want := []string{"3", "4"}
+	if got := listedIDs(t, shop.URL+"products/1/related?limit=2"); !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+
+	for path, status := range map[string]int{
+		"products/42/related":        http.StatusNotFound,
+		"products/1/related?limit=0": http.StatusBadRequest,
+	} {
+		resp, err := http.Get(shop.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("%s: want HTTP %d, got %d", path, status, resp.StatusCode)
+		}
+	}
+}
+
+func TestServer_UsesConfiguredRecommender(t *testing.T) {
+	t.Parallel()
+
+	reversed := coffeeshop.RecommenderFunc(func(p coffeeshop.Product, candidates []coffeeshop.Product, limit int) []coffeeshop.Product {
+		var related []coffeeshop.Product
+		for i := len(candidates) - 1; i >= 0; i-- {
+			if candidates[i].ID != p.ID {
+				related = append(related, candidates[i])
+			}
+		}
+		return related
+	})
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: relatedCatalog}, "10ms", t,
+		coffeeshop.WithRecommender(reversed),
+	)
+	want := []string{"7", "6", "5", "4", "3"}
+	if got := listedIDs(t, shop.URL+"products/1/related"); !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
@@ -0,0 +1,88 @@
+package coffeeshop
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// currentLatency returns the latency of requests, changed
+// by Reload while the server runs.
+func (cs *Server) currentLatency() time.Duration {
+	return time.Duration(cs.latency.Load())
+}
+
+// wait holds requests for the current latency.
+func (cs *Server) wait(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(cs.currentLatency())
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// Reload applies the latency and the inventory of the configuration
+// to the running server without dropping connections. Products of
+// the store are replaced with products of the inventory file, if the
+// store is a Resetter, even behind wrappers like the CachingStore.
+// Other settings take effect when the server is restarted. The write
+// timeout of connections isn't extended for latency longer than the
+// latency the server started with.
+func (cs *Server) Reload(c Config) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	latency := cs.currentLatency()
+	if c.Latency.Base != "" {
+		var err error
+		latency, err = time.ParseDuration(c.Latency.Base)
+		if err != nil {
+			return err
+		}
+	}
+	if c.Inventory != "" {
+		r, ok := storeAs[Resetter](cs.Store)
+		if !ok {
+			return fmt.Errorf("inventory file requires a store able to reset products, got %T", cs.Store)
+		}
+		products, err := LoadInventory(c.Inventory)
+		if err != nil {
+			return err
+		}
+		r.ResetProducts(products)
+	}
+	cs.latency.Store(int64(latency))
+	return nil
+}
+
+// ReloadOnSignal reloads the configuration from the YAML file with
+// LoadConfig and applies it with Reload each time the process
+// receives SIGHUP, until the context is cancelled. Errors are
+// logged and leave the running configuration unchanged.
+func (cs *Server) ReloadOnSignal(ctx context.Context, path string) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+			}
+			c, err := LoadConfig(path)
+			if err == nil {
+				err = cs.Reload(c)
+			}
+			if err != nil {
+				cs.logf("reload: %v", err)
+				continue
+			}
+			cs.logf("reload: configuration reloaded")
+		}
+	}()
+}
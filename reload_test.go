@@ -0,0 +1,99 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+func TestServer_ReloadAppliesLatencyAndInventory(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: inventory}
+	shop := newCoffeShopTestServer(store, "0s", t)
+	path := writeInventoryFile(t, "inventory.yml", "- id: 7\n  type: Cocoa\n  name: Dark\n  price: 3.50\n")
+
+	err := shop.Reload(coffeeshop.Config{
+		Addr:      ":0",
+		Store:     "memory://",
+		Inventory: path,
+		Latency:   coffeeshop.LatencyConfig{Base: "200ms"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []coffeeshop.Product{{ID: "7", Type: "Cocoa", Name: "Dark", Price: coffeeshop.Money{Amount: 350, Currency: "EUR"}}}
+	got := store.GetAll()
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+	start := time.Now()
+	resp, err := http.Get(shop.URL + "products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("want response delayed by reloaded latency 200ms, got %v", elapsed)
+	}
+}
+
+func TestServer_ReloadKeepsRunningConfigurationOnInvalidInventory(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: inventory}
+	shop := newCoffeShopTestServer(store, "0s", t)
+	path := writeInventoryFile(t, "inventory.yml", "- id: 7\n  price: free\n")
+
+	err := shop.Reload(coffeeshop.Config{
+		Addr:      ":0",
+		Store:     "memory://",
+		Inventory: path,
+		Latency:   coffeeshop.LatencyConfig{Base: "1h"},
+	})
+	if err == nil {
+		t.Fatal("want error on invalid inventory")
+	}
+	if got := len(store.GetAll()); got != len(inventory) {
+		t.Errorf("want %d products kept, got %d", len(inventory), got)
+	}
+	resp, err := http.Get(shop.URL + "products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("want HTTP 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_ReloadReplacesInventoryBehindCache(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t, coffeeshop.WithCache("1h"))
+	var px []coffeeshop.Product
+	if err := json.Unmarshal(getBody(t, shop.URL+"products"), &px); err != nil {
+		t.Fatal(err)
+	}
+	path := writeInventoryFile(t, "inventory.yml", "- id: 7\n  type: Cocoa\n  name: Dark\n  price: 3.50\n")
+
+	err := shop.Reload(coffeeshop.Config{
+		Addr:      ":0",
+		Store:     "memory://",
+		Inventory: path,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	px = nil
+	if err := json.Unmarshal(getBody(t, shop.URL+"products"), &px); err != nil {
+		t.Fatal(err)
+	}
+	if len(px) != 1 || px[0].ID != "7" {
+		t.Errorf("want reloaded product 7 listed, got %v", px)
+	}
+}
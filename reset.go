@@ -0,0 +1,142 @@
+package coffeeshop
+
+import (
+	"net/http"
+)
+
+// resetter is implemented by memory stores able to drop their data.
+// Stores keep assigning new IDs after the last ID assigned before
+// the reset, so orders scheduled before the reset never update
+// orders created after it.
+type resetter interface {
+	reset()
+}
+
+// Resetter is implemented by stores able to replace all their
+// products at once, like the MemoryStore. Stores wrapping another
+// store and holding copies of its products, like the CachingStore,
+// forward it and drop the copies, so products can be reset behind
+// them.
+type Resetter interface {
+	// ResetProducts replaces all products of the store
+	// and drops their price history.
+	ResetProducts(products Products)
+}
+
+// ResetProducts replaces all products of the store and drops their
+// price history. Sync cursors issued before the reset expire.
+func (ms *MemoryStore) ResetProducts(products Products) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	ms.Products = products
+	ms.prices = nil
+	ms.loadTime()
+	ms.loadedAt = now(ms.Clock)
+	ms.expireCursors()
+}
+
+// captureSeed remembers products the server starts with,
+// to restore them on reset, if the store is a Resetter. They
+// are read from the innermost store, so reading them doesn't
+// fill caches of wrappers or count as their reads.
+func (cs *Server) captureSeed() {
+	if _, ok := storeAs[Resetter](cs.Store); !ok {
+		return
+	}
+	s := cs.Store
+	for w, ok := s.(StoreWrapper); ok; w, ok = s.(StoreWrapper) {
+		s = w.Unwrap()
+	}
+	px := s.GetAll()
+	cs.seed = make(Products, len(px))
+	for _, p := range px {
+		cs.seed[p.ID] = p
+	}
+}
+
+// Reset restores products to the products the server started
+// with and drops orders, carts, customers and their sessions,
+// reviews, images, promotions and the audit log held in memory
+// stores. It returns false if the store of products isn't a
+// Resetter, even behind wrappers like the CachingStore.
+func (cs *Server) Reset() bool {
+	r, ok := storeAs[Resetter](cs.Store)
+	if !ok {
+		return false
+	}
+	products := make(Products, len(cs.seed))
+	for id, p := range cs.seed {
+		products[id] = p.clone()
+	}
+	r.ResetProducts(products)
+
+	for _, store := range []any{
+		cs.OrderStore,
+		cs.CartStore,
+		cs.CustomerStore,
+		cs.ReviewStore,
+		cs.ImageStore,
+		cs.PromotionStore,
+		cs.AuditSink,
+	} {
+		if r, ok := store.(resetter); ok {
+			r.reset()
+		}
+	}
+	cs.sessions.mx.Lock()
+	cs.sessions.sessions = nil
+	cs.sessions.mx.Unlock()
+	return true
+}
+
+// ResetData restores the seed state of the server. Requests
+// for a tenant reset the data of the tenant only.
+func (cs *Server) ResetData(w http.ResponseWriter, r *http.Request) {
+	if !cs.Reset() {
+		writeError(w, r, http.StatusNotImplemented, "store doesn't support resetting products")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (ms *MemoryOrderStore) reset() {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	ms.Orders = nil
+}
+
+func (ms *MemoryCartStore) reset() {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	ms.Carts = nil
+}
+
+func (ms *MemoryCustomerStore) reset() {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	ms.Customers = nil
+}
+
+func (ms *MemoryReviewStore) reset() {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	ms.Reviews = nil
+}
+
+func (ms *MemoryImageStore) reset() {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	ms.Images = nil
+}
+
+func (ms *MemoryPromotionStore) reset() {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	ms.Promotions = nil
+}
+
+func (l *MemoryAuditLog) reset() {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	l.entries = nil
+}
@@ -0,0 +1,133 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// resetData sends POST /admin/reset and returns the status code.
+func resetData(t *testing.T, url string, header http.Header) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url+"admin/reset", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestServer_ResetRestoresSeedProductsAndDropsOrders(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: stockedInventory(10)}
+	orders := &coffeeshop.MemoryOrderStore{}
+	shop := newCoffeShopTestServer(store, "0s", t,
+		coffeeshop.WithOrderStore(orders),
+		coffeeshop.WithOrderInterval("1h"),
+	)
+	want := len(store.GetAll())
+	resp := createOrder(t, shop.URL, `{"items":[{"productId":"1","quantity":2}]}`)
+	resp.Body.Close()
+	req, err := http.NewRequest(http.MethodDelete, shop.URL+"products/7", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got := resetData(t, shop.URL, nil); got != http.StatusNoContent {
+		t.Fatalf("want HTTP 204, got %d", got)
+	}
+	if got := len(store.GetAll()); got != want {
+		t.Errorf("want %d products after reset, got %d", want, got)
+	}
+	p, err := store.GetProduct("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Stock != 10 {
+		t.Errorf("want seed stock 10 after reset, got %d", p.Stock)
+	}
+	if n := len(orders.GetOrders()); n != 0 {
+		t.Errorf("want no orders after reset, got %d", n)
+	}
+}
+
+func TestServer_ResetsDataOfTenantOnly(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(1)}, "0s", t, coffeeshop.WithTenants())
+	resp := createTenant(t, shop.URL, `{"id":"a"}`)
+	resp.Body.Close()
+	body := `{"id":"100","type":"Tea","brand":"Tetley","name":"Green","price":"3.49","stock":1}`
+	for _, url := range []string{shop.URL, shop.URL + "tenants/a/"} {
+		resp, err := http.Post(url+"products", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	if got := resetData(t, shop.URL, http.Header{"X-Tenant-Id": {"a"}}); got != http.StatusNoContent {
+		t.Fatalf("want HTTP 204, got %d", got)
+	}
+	for url, want := range map[string]int{
+		shop.URL + "products/100":           http.StatusOK,
+		shop.URL + "tenants/a/products/100": http.StatusNotFound,
+	} {
+		got, err := getStatus(t, http.DefaultClient, url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("GET %s: want HTTP %d, got %d", url, want, got)
+		}
+	}
+}
+
+func TestServer_ResetsProductsBehindCacheAndRetries(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(1)}, "0s", t,
+		coffeeshop.WithCache("1h"),
+		coffeeshop.WithStoreRetries(2, "1ms", "1ms"),
+	)
+	body := `{"id":"100","type":"Tea","brand":"Tetley","name":"Green","price":"3.49","stock":1}`
+	resp, err := http.Post(shop.URL+"products", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	// Fill the cache with the list including the new product.
+	var px []coffeeshop.Product
+	if err := json.Unmarshal(getBody(t, shop.URL+"products"), &px); err != nil {
+		t.Fatal(err)
+	}
+	if len(px) != len(inventory)+1 {
+		t.Fatalf("want %d products before reset, got %d", len(inventory)+1, len(px))
+	}
+
+	if got := resetData(t, shop.URL, nil); got != http.StatusNoContent {
+		t.Fatalf("want HTTP 204, got %d", got)
+	}
+	if err := json.Unmarshal(getBody(t, shop.URL+"products"), &px); err != nil {
+		t.Fatal(err)
+	}
+	if len(px) != len(inventory) {
+		t.Errorf("want %d seed products after reset, got %d", len(inventory), len(px))
+	}
+}
@@ -0,0 +1,355 @@
+package coffeeshop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// RetryingStore retries calls of the store failed with transient
+// errors of its backend, like lost connections, waiting a random
+// backoff before each retry. The longest backoff starts at Backoff
+// and doubles with every retry up to MaxBackoff. Errors about products
+// or stock, like ErrProductNotFound, and ErrStoreUnavailable of the
+// open circuit breaker aren't retried.
+//
+// ReserveStock and Add aren't retried, since the failed call may have
+// been completed by the backend before it failed, and retrying it
+// could reserve stock twice or add a duplicate product. For the same
+// reason updates of products with a Version aren't retried, as the
+// retry would fail with ErrVersionMismatch. A retried Delete of the
+// product deleted by the failed call succeeds. GetAll and GetByType
+// don't return errors, so they can't be retried either.
+//
+// The RetryingStore wraps any store, and its retries stop when the
+// context of the store returned by WithContext is done.
+type RetryingStore struct {
+	Store Store
+	// Attempts is the number of calls made, including the first one.
+	Attempts   int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	ctx     context.Context
+	random  *randomSource
+	retries *atomic.Int64
+}
+
+// NewRetryingStore returns the store calling the store up to
+// the number of attempts, with backoffs between the backoff
+// and the max backoff.
+func NewRetryingStore(store Store, attempts int, backoff, maxBackoff time.Duration) *RetryingStore {
+	return &RetryingStore{
+		Store:      store,
+		Attempts:   attempts,
+		Backoff:    backoff,
+		MaxBackoff: maxBackoff,
+		ctx:        context.Background(),
+		random:     newRandomSource(time.Now().UnixNano()),
+		retries:    new(atomic.Int64),
+	}
+}
+
+// WithContext returns the store retrying calls until the context is
+// done. Calls of stores implementing ContextStore are made with the
+// context.
+func (rs *RetryingStore) WithContext(ctx context.Context) Store {
+	bound := *rs
+	bound.ctx = ctx
+	bound.Store = withContext(rs.Store, ctx)
+	return &bound
+}
+
+// Retries returns the number of retried calls.
+func (rs *RetryingStore) Retries() int64 {
+	return rs.retries.Load()
+}
+
+// backoff returns the random backoff before the retry,
+// counted from one.
+func (rs *RetryingStore) backoff(retry int) time.Duration {
+	longest := rs.Backoff
+	for i := 1; i < retry && longest < rs.MaxBackoff; i++ {
+		longest *= 2
+	}
+	if rs.MaxBackoff > 0 && longest > rs.MaxBackoff {
+		longest = rs.MaxBackoff
+	}
+	return rs.random.duration(longest)
+}
+
+// retry makes the call until it succeeds, fails with an error
+// which isn't transient, or attempts run out. It returns the
+// error of the last call, or the error of the context if it's
+// done while waiting for the retry.
+func (rs *RetryingStore) retry(call func(s Store) error) error {
+	err := call(rs.Store)
+	for retry := 1; retry < rs.Attempts && storeFailure(err); retry++ {
+		if rs.ctx.Err() != nil {
+			return err
+		}
+		t := time.NewTimer(rs.backoff(retry))
+		select {
+		case <-rs.ctx.Done():
+			t.Stop()
+			return errors.Join(err, rs.ctx.Err())
+		case <-t.C:
+		}
+		rs.retries.Add(1)
+		err = call(rs.Store)
+	}
+	return err
+}
+
+// GetAll returns all products from the store.
+func (rs *RetryingStore) GetAll() []Product {
+	return rs.Store.GetAll()
+}
+
+// GetByType returns products of the type from the store.
+func (rs *RetryingStore) GetByType(productType string) []Product {
+	return rs.Store.GetByType(productType)
+}
+
+// ListAll returns all products from the store.
+func (rs *RetryingStore) ListAll() ([]Product, error) {
+	var px []Product
+	err := rs.retry(func(s Store) (err error) {
+		px, err = listAll(s)
+		return err
+	})
+	return px, err
+}
+
+// ListByType returns products of the type from the store.
+func (rs *RetryingStore) ListByType(productType string) ([]Product, error) {
+	var px []Product
+	err := rs.retry(func(s Store) (err error) {
+		px, err = listByType(s, productType)
+		return err
+	})
+	return px, err
+}
+
+// GetProduct returns the product with the given ID from the store.
+func (rs *RetryingStore) GetProduct(id string) (Product, error) {
+	var p Product
+	err := rs.retry(func(s Store) (err error) {
+		p, err = s.GetProduct(id)
+		return err
+	})
+	return p, err
+}
+
+// GetMany returns products with the given IDs from the store.
+func (rs *RetryingStore) GetMany(ids []string) ([]Product, error) {
+	var px []Product
+	err := rs.retry(func(s Store) (err error) {
+		px, err = getMany(s, ids)
+		return err
+	})
+	return px, err
+}
+
+// GetBySKU returns the product with the SKU or EAN from the store.
+func (rs *RetryingStore) GetBySKU(code string) (Product, error) {
+	var p Product
+	err := rs.retry(func(s Store) (err error) {
+		p, err = productBySKU(s, code)
+		return err
+	})
+	return p, err
+}
+
+// ProductStats returns statistics of products in the store.
+func (rs *RetryingStore) ProductStats() (ProductStats, error) {
+	var stats ProductStats
+	err := rs.retry(func(s Store) (err error) {
+		stats, err = storeStats(s)
+		return err
+	})
+	return stats, err
+}
+
+// ReserveStock reserves stock in the store, without retries.
+func (rs *RetryingStore) ReserveStock(items []OrderItem) error {
+	return rs.Store.ReserveStock(items)
+}
+
+// SetStock sets stock in the store.
+func (rs *RetryingStore) SetStock(id string, stock int) (Product, error) {
+	var p Product
+	err := rs.retry(func(s Store) (err error) {
+		p, err = s.SetStock(id, stock)
+		return err
+	})
+	return p, err
+}
+
+// PutProduct puts the product into the store. It fails
+// if the store isn't an Importer.
+func (rs *RetryingStore) PutProduct(p Product) (Product, error) {
+	if _, ok := storeAs[Importer](rs.Store); !ok {
+		return Product{}, fmt.Errorf("store %T doesn't support imports", rs.Store)
+	}
+	var put Product
+	err := rs.retry(func(s Store) (err error) {
+		importer, _ := storeAs[Importer](s)
+		put, err = importer.PutProduct(p)
+		return err
+	})
+	return put, err
+}
+
+// Add adds the product to the store, without retries. It
+// fails if the store isn't a ProductWriter.
+func (rs *RetryingStore) Add(p Product) (Product, error) {
+	pw, ok := storeAs[ProductWriter](rs.Store)
+	if !ok {
+		return Product{}, fmt.Errorf("store %T doesn't support writes", rs.Store)
+	}
+	return pw.Add(p)
+}
+
+// Replace replaces the product in the store, without retries.
+// It fails if the store isn't a Replacer.
+func (rs *RetryingStore) Replace(id string, write func(old Product, exists bool) (Product, error)) (old, product Product, err error) {
+	rp, ok := storeAs[Replacer](rs.Store)
+	if !ok {
+		return Product{}, Product{}, fmt.Errorf("store %T doesn't support replacing products", rs.Store)
+	}
+	return rp.Replace(id, write)
+}
+
+// Update updates the product in the store, retrying updates of
+// products without a version. It fails if the store isn't
+// a ProductWriter.
+func (rs *RetryingStore) Update(p Product) (Product, error) {
+	pw, ok := storeAs[ProductWriter](rs.Store)
+	if !ok {
+		return Product{}, fmt.Errorf("store %T doesn't support writes", rs.Store)
+	}
+	if p.Version != 0 {
+		return pw.Update(p)
+	}
+	var updated Product
+	err := rs.retry(func(s Store) (err error) {
+		pw, _ := storeAs[ProductWriter](s)
+		updated, err = pw.Update(p)
+		return err
+	})
+	return updated, err
+}
+
+// Delete deletes the product from the store. It fails
+// if the store isn't a ProductWriter.
+func (rs *RetryingStore) Delete(id string) error {
+	if _, ok := storeAs[ProductWriter](rs.Store); !ok {
+		return fmt.Errorf("store %T doesn't support writes", rs.Store)
+	}
+	failed := false
+	return rs.retry(func(s Store) error {
+		pw, _ := storeAs[ProductWriter](s)
+		err := pw.Delete(id)
+		if failed && errors.Is(err, ErrProductNotFound) {
+			// The failed call deleted the product.
+			return nil
+		}
+		failed = err != nil
+		return err
+	})
+}
+
+// PriceHistory returns prices of the product from the store. It
+// fails if the store isn't a PriceHistorian.
+func (rs *RetryingStore) PriceHistory(id string) ([]PricePoint, error) {
+	if _, ok := storeAs[PriceHistorian](rs.Store); !ok {
+		return nil, fmt.Errorf("store %T doesn't support price history", rs.Store)
+	}
+	var history []PricePoint
+	err := rs.retry(func(s Store) (err error) {
+		ph, _ := storeAs[PriceHistorian](s)
+		history, err = ph.PriceHistory(id)
+		return err
+	})
+	return history, err
+}
+
+// Changes returns changes of products since the cursor from the
+// store. It fails if the store isn't a Syncer.
+func (rs *RetryingStore) Changes(cursor string) (SyncDelta, error) {
+	if _, ok := storeAs[Syncer](rs.Store); !ok {
+		return SyncDelta{}, fmt.Errorf("store %T doesn't support sync", rs.Store)
+	}
+	var delta SyncDelta
+	err := rs.retry(func(s Store) (err error) {
+		syncer, _ := storeAs[Syncer](s)
+		delta, err = syncer.Changes(cursor)
+		return err
+	})
+	return delta, err
+}
+
+// Unwrap returns the retried store. Optional interfaces of the
+// store not implemented by the RetryingStore, like Notifier,
+// are used without retries.
+func (rs *RetryingStore) Unwrap() Store {
+	return rs.Store
+}
+
+// Ping reports whether the backend of the store is reachable.
+func (rs *RetryingStore) Ping(ctx context.Context) error {
+	if p, ok := rs.Store.(Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (rs *RetryingStore) useClock(clock Clock) {
+	if u, ok := rs.Store.(clockUser); ok {
+		u.useClock(clock)
+	}
+}
+
+// WithStoreRetries wraps the store in a RetryingStore making up to
+// the number of attempts of calls, with backoffs starting at the
+// backoff, for example "50ms", and growing up to the max backoff.
+// The store is wrapped after all options are applied, under the
+// circuit breaker and the cache if enabled, so the breaker counts
+// a call failed after all retries as a single failure.
+func WithStoreRetries(attempts int, backoff, maxBackoff string) Option {
+	return func(s *Server) error {
+		if attempts < 1 {
+			return errors.New("store retry attempts must be positive")
+		}
+		b, err := time.ParseDuration(backoff)
+		if err != nil {
+			return err
+		}
+		maxB, err := time.ParseDuration(maxBackoff)
+		if err != nil {
+			return err
+		}
+		if b <= 0 || maxB < b {
+			return fmt.Errorf("invalid store retry backoff %s up to %s", backoff, maxBackoff)
+		}
+		s.RetryAttempts = attempts
+		s.RetryBackoff = b
+		s.RetryMaxBackoff = maxB
+		return nil
+	}
+}
+
+// useRetries wraps the store of the server in a RetryingStore
+// using the random source of the server.
+func (cs *Server) useRetries() {
+	if cs.RetryAttempts <= 1 {
+		return
+	}
+	rs := NewRetryingStore(cs.Store, cs.RetryAttempts, cs.RetryBackoff, cs.RetryMaxBackoff)
+	rs.random = cs.random
+	cs.retrying = rs
+	cs.Store = rs
+}
@@ -0,0 +1,202 @@
+package coffeeshop_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// flakyStore fails the given number of calls before it recovers.
+type flakyStore struct {
+	*coffeeshop.MemoryStore
+	failures atomic.Int64
+	calls    atomic.Int64
+}
+
+func (s *flakyStore) fail() error {
+	s.calls.Add(1)
+	if s.failures.Add(-1) >= 0 {
+		return errors.New("connection reset")
+	}
+	return nil
+}
+
+func (s *flakyStore) GetProduct(id string) (coffeeshop.Product, error) {
+	if err := s.fail(); err != nil {
+		return coffeeshop.Product{}, err
+	}
+	return s.MemoryStore.GetProduct(id)
+}
+
+func (s *flakyStore) ReserveStock(items []coffeeshop.OrderItem) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.MemoryStore.ReserveStock(items)
+}
+
+func newFlakyStore(failures int64) *flakyStore {
+	s := &flakyStore{MemoryStore: &coffeeshop.MemoryStore{Products: stockedInventory(10)}}
+	s.failures.Store(failures)
+	return s
+}
+
+func TestRetryingStore_RetriesTransientErrors(t *testing.T) {
+	t.Parallel()
+
+	store := newFlakyStore(2)
+	rs := coffeeshop.NewRetryingStore(store, 3, time.Millisecond, 5*time.Millisecond)
+	p, err := rs.GetProduct("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ID != "1" {
+		t.Errorf("want product 1, got %q", p.ID)
+	}
+	if got := store.calls.Load(); got != 3 {
+		t.Errorf("want 3 calls, got %d", got)
+	}
+	if got := rs.Retries(); got != 2 {
+		t.Errorf("want 2 retries, got %d", got)
+	}
+}
+
+func TestRetryingStore_ReturnsLastErrorWhenAttemptsRunOut(t *testing.T) {
+	t.Parallel()
+
+	store := newFlakyStore(5)
+	rs := coffeeshop.NewRetryingStore(store, 3, time.Millisecond, time.Millisecond)
+	if _, err := rs.GetProduct("1"); err == nil {
+		t.Fatal("want error after attempts run out")
+	}
+	if got := store.calls.Load(); got != 3 {
+		t.Errorf("want 3 calls, got %d", got)
+	}
+}
+
+func TestRetryingStore_DoesNotRetryProductErrors(t *testing.T) {
+	t.Parallel()
+
+	store := newFlakyStore(0)
+	rs := coffeeshop.NewRetryingStore(store, 3, time.Millisecond, time.Millisecond)
+	_, err := rs.GetProduct("missing")
+	if !errors.Is(err, coffeeshop.ErrProductNotFound) {
+		t.Fatalf("want ErrProductNotFound, got %v", err)
+	}
+	if got := store.calls.Load(); got != 1 {
+		t.Errorf("want 1 call, got %d", got)
+	}
+}
+
+func TestRetryingStore_DoesNotRetryStockReservations(t *testing.T) {
+	t.Parallel()
+
+	store := newFlakyStore(1)
+	rs := coffeeshop.NewRetryingStore(store, 3, time.Millisecond, time.Millisecond)
+	if err := rs.ReserveStock([]coffeeshop.OrderItem{{ProductID: "1", Quantity: 1}}); err == nil {
+		t.Fatal("want error of the failed reservation")
+	}
+	if got := store.calls.Load(); got != 1 {
+		t.Errorf("want 1 call, got %d", got)
+	}
+}
+
+func TestRetryingStore_StopsRetriesWhenContextIsDone(t *testing.T) {
+	t.Parallel()
+
+	store := newFlakyStore(5)
+	rs := coffeeshop.NewRetryingStore(store, 5, time.Hour, time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := rs.WithContext(ctx).GetProduct("1")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("want retries stopped at the deadline, took %v", elapsed)
+	}
+	if got := store.calls.Load(); got != 1 {
+		t.Errorf("want 1 call, got %d", got)
+	}
+}
+
+func TestServer_RetriesStoreCalls(t *testing.T) {
+	t.Parallel()
+
+	store := newFlakyStore(1)
+	shop := newCoffeShopTestServer(store, "0s", t,
+		coffeeshop.WithRandomSeed(1),
+		coffeeshop.WithStoreRetries(2, "1ms", "10ms"),
+	)
+	if status, body := getResponse(t, shop.URL+"products/1"); status != http.StatusOK {
+		t.Fatalf("want HTTP 200 after retry, got %d %s", status, body)
+	}
+	_, metrics := getResponse(t, shop.URL+"metrics")
+	if !strings.Contains(metrics, "coffeeshop_store_retries_total 1") {
+		t.Errorf("want 1 retry in metrics, got:\n%s", metrics)
+	}
+}
+
+func TestWithStoreRetries_RejectsInvalidBackoff(t *testing.T) {
+	t.Parallel()
+
+	for _, opt := range []coffeeshop.Option{
+		coffeeshop.WithStoreRetries(0, "1ms", "10ms"),
+		coffeeshop.WithStoreRetries(3, "0s", "10ms"),
+		coffeeshop.WithStoreRetries(3, "10ms", "1ms"),
+		coffeeshop.WithStoreRetries(3, "soon", "10ms"),
+	} {
+		if _, err := coffeeshop.New(":0", &coffeeshop.MemoryStore{}, opt); err == nil {
+			t.Error("want error for invalid retry configuration")
+		}
+	}
+}
+
+// lossyStore loses the response of the first delete
+// after the product is deleted.
+type lossyStore struct {
+	*coffeeshop.MemoryStore
+	lost atomic.Bool
+}
+
+func (s *lossyStore) Delete(id string) error {
+	if err := s.MemoryStore.Delete(id); err != nil {
+		return err
+	}
+	if s.lost.CompareAndSwap(false, true) {
+		return errors.New("connection reset")
+	}
+	return nil
+}
+
+func TestRetryingStore_RetriesDeletesCompletedByFailedCall(t *testing.T) {
+	t.Parallel()
+
+	store := &lossyStore{MemoryStore: &coffeeshop.MemoryStore{Products: stockedInventory(5)}}
+	rs := coffeeshop.NewRetryingStore(store, 3, time.Millisecond, 5*time.Millisecond)
+	if err := rs.Delete("1"); err != nil {
+		t.Fatal(err)
+	}
+	if rs.Retries() != 1 {
+		t.Errorf("want 1 retry, got %d", rs.Retries())
+	}
+	if err := rs.Delete("1"); !errors.Is(err, coffeeshop.ErrProductNotFound) {
+		t.Errorf("want ErrProductNotFound deleting the product again, got %v", err)
+	}
+}
+
+func TestServer_UsesOptionalStoreInterfacesThroughRetries(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(5)}, "0s", t,
+		coffeeshop.WithStoreRetries(2, "1ms", "10ms"),
+	)
+	wantOptionalStoreRoutes(t, shop.URL)
+}
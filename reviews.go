@@ -0,0 +1,199 @@
+package coffeeshop
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Limits of star ratings of reviews.
+const (
+	MinRating = 1
+	MaxRating = 5
+)
+
+// Default and maximum number of reviews on a page.
+const (
+	defaultReviewsPerPage = 20
+	maxReviewsPerPage     = 100
+)
+
+// Review is a customer review of a product.
+type Review struct {
+	ID        string    `json:"id"`
+	ProductID string    `json:"productId"`
+	Rating    int       `json:"rating"`
+	Author    string    `json:"author,omitempty"`
+	Comment   string    `json:"comment,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Rating aggregates star ratings of reviews of a product.
+type Rating struct {
+	Average float64 `json:"average" xml:"average,attr"`
+	Count   int     `json:"count" xml:"count,attr"`
+}
+
+// ReviewStore represents a storage for product reviews.
+type ReviewStore interface {
+	AddReview(r Review) (Review, error)
+	// GetReviews returns reviews of the product, oldest first.
+	GetReviews(productID string) []Review
+}
+
+// MemoryReviewStore represents an in-memory storage for reviews.
+//
+// Use memory review store for testing and development.
+type MemoryReviewStore struct {
+	mx      sync.RWMutex
+	lastID  int
+	Reviews map[string][]Review
+}
+
+// AddReview adds the review and assigns it an ID.
+func (ms *MemoryReviewStore) AddReview(r Review) (Review, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	if ms.Reviews == nil {
+		ms.Reviews = make(map[string][]Review)
+	}
+	ms.lastID++
+	r.ID = strconv.Itoa(ms.lastID)
+	ms.Reviews[r.ProductID] = append(ms.Reviews[r.ProductID], r)
+	return r, nil
+}
+
+// GetReviews returns reviews of the product, oldest first.
+func (ms *MemoryReviewStore) GetReviews(productID string) []Review {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	reviews := append([]Review(nil), ms.Reviews[productID]...)
+	sort.SliceStable(reviews, func(i, j int) bool {
+		return reviews[i].CreatedAt.Before(reviews[j].CreatedAt)
+	})
+	return reviews
+}
+
+// WithReviewStore configures the storage used for product reviews.
+func WithReviewStore(store ReviewStore) Option {
+	return func(s *Server) error {
+		if store == nil {
+			return errors.New("nil review store")
+		}
+		s.ReviewStore = store
+		return nil
+	}
+}
+
+// rating returns the aggregated rating of the reviews,
+// or nil if there are no reviews.
+func rating(reviews []Review) *Rating {
+	if len(reviews) == 0 {
+		return nil
+	}
+	sum := 0
+	for _, r := range reviews {
+		sum += r.Rating
+	}
+	avg := float64(sum) / float64(len(reviews))
+	return &Rating{Average: math.Round(avg*100) / 100, Count: len(reviews)}
+}
+
+// addRatings sets aggregated ratings of the products.
+func (cs *Server) addRatings(px []Product) {
+	for i := range px {
+		px[i].Rating = rating(cs.ReviewStore.GetReviews(px[i].ID))
+	}
+}
+
+// ReviewPage is a page of reviews of a product.
+type ReviewPage struct {
+	Reviews []Review `json:"reviews"`
+	Page    int      `json:"page"`
+	PerPage int      `json:"perPage"`
+	Total   int      `json:"total"`
+	Rating  *Rating  `json:"rating,omitempty"`
+}
+
+// GetReviews returns a page of reviews of the product, newest
+// first. Pages are selected with the "page" and "per_page"
+// query parameters.
+func (cs *Server) GetReviews(w http.ResponseWriter, r *http.Request) {
+	productID := chi.URLParam(r, "productID")
+	if _, err := cs.store(r).GetProduct(productID); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	page, err := queryInt(r, "page", 1, 1, math.MaxInt32)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	perPage, err := queryInt(r, "per_page", defaultReviewsPerPage, 1, maxReviewsPerPage)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	reviews := cs.ReviewStore.GetReviews(productID)
+	result := ReviewPage{
+		Reviews: []Review{},
+		Page:    page,
+		PerPage: perPage,
+		Total:   len(reviews),
+		Rating:  rating(reviews),
+	}
+	// Reviews are stored oldest first and listed newest first.
+	for i := len(reviews) - 1 - (page-1)*perPage; i >= 0 && len(result.Reviews) < perPage; i-- {
+		result.Reviews = append(result.Reviews, reviews[i])
+	}
+	writeJSON(w, r, http.StatusOK, result)
+}
+
+// queryInt returns the integer query parameter between lo and hi,
+// or the fallback if the parameter is missing.
+func queryInt(r *http.Request, name string, fallback, lo, hi int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < lo || n > hi {
+		return 0, errors.New("invalid " + name + " " + strconv.Quote(v))
+	}
+	return n, nil
+}
+
+// CreateReview adds the review of the product in the request body.
+// Ratings must be between MinRating and MaxRating stars.
+func (cs *Server) CreateReview(w http.ResponseWriter, r *http.Request) {
+	productID := chi.URLParam(r, "productID")
+	if _, err := cs.store(r).GetProduct(productID); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	var review Review
+	if !cs.decodeJSON(w, r, &review, "invalid review") {
+		return
+	}
+	if review.Rating < MinRating || review.Rating > MaxRating {
+		writeError(w, r, http.StatusBadRequest, "rating must be between "+strconv.Itoa(MinRating)+" and "+strconv.Itoa(MaxRating)+" stars")
+		return
+	}
+	review.ID = ""
+	review.ProductID = productID
+	review.Author = strings.TrimSpace(review.Author)
+	review.CreatedAt = cs.Clock.Now()
+	review, err := cs.ReviewStore.AddReview(review)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, review)
+}
@@ -0,0 +1,139 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// postReview posts the review of the product.
+func postReview(t *testing.T, url, productID, body string) *http.Response {
+	t.Helper()
+	resp, err := http.Post(url+"products/"+productID+"/reviews", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// getReviews returns the page of reviews at the URL.
+func getReviews(t *testing.T, url string) coffeeshop.ReviewPage {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200, got %d", resp.StatusCode)
+	}
+	var page coffeeshop.ReviewPage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	return page
+}
+
+func TestServer_AddsReviewsAndRatesProduct(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(5)}, "10ms", t)
+	for _, stars := range []int{5, 4, 4} {
+		resp := postReview(t, shop.URL, "1", fmt.Sprintf(`{"rating":%d,"author":"alice","comment":"Smooth"}`, stars))
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("want HTTP 201, got %d", resp.StatusCode)
+		}
+	}
+
+	resp, err := http.Get(shop.URL + "products/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var p coffeeshop.Product
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	want := coffeeshop.Rating{Average: 4.33, Count: 3}
+	if p.Rating == nil || *p.Rating != want {
+		t.Errorf("want rating %+v, got %+v", want, p.Rating)
+	}
+
+	resp, err = http.Get(shop.URL + "products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var px []coffeeshop.Product
+	if err := json.NewDecoder(resp.Body).Decode(&px); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range px {
+		if rated := p.Rating != nil; rated != (p.ID == "1") {
+			t.Errorf("want only product 1 rated, got product %s rating %+v", p.ID, p.Rating)
+		}
+	}
+}
+
+func TestServer_PaginatesReviewsNewestFirst(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(5)}, "10ms", t)
+	for i := 1; i <= 5; i++ {
+		resp := postReview(t, shop.URL, "1", fmt.Sprintf(`{"rating":%d,"comment":"review %d"}`, i, i))
+		resp.Body.Close()
+	}
+
+	page := getReviews(t, shop.URL+"products/1/reviews?page=2&per_page=2")
+	if page.Total != 5 || page.Page != 2 || page.PerPage != 2 || len(page.Reviews) != 2 {
+		t.Fatalf("want second page of 2 out of 5 reviews, got %+v", page)
+	}
+	if page.Reviews[0].Comment != "review 3" || page.Reviews[1].Comment != "review 2" {
+		t.Errorf("want reviews 3 and 2, got %+v", page.Reviews)
+	}
+	if page.Rating == nil || page.Rating.Average != 3 {
+		t.Errorf("want average rating 3, got %+v", page.Rating)
+	}
+	if page := getReviews(t, shop.URL+"products/1/reviews?page=4&per_page=2"); len(page.Reviews) != 0 {
+		t.Errorf("want empty page past the last review, got %+v", page.Reviews)
+	}
+	if page := getReviews(t, shop.URL+"products/2/reviews"); len(page.Reviews) != 0 || page.Rating != nil {
+		t.Errorf("want no reviews of product 2, got %+v", page)
+	}
+}
+
+func TestServer_RejectsInvalidReviews(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(5)}, "10ms", t)
+	tcs := []struct {
+		name, productID, body string
+		want                  int
+	}{
+		{"rating too low", "1", `{"rating":0}`, http.StatusBadRequest},
+		{"rating too high", "1", `{"rating":6}`, http.StatusBadRequest},
+		{"malformed body", "1", `{"rating":`, http.StatusBadRequest},
+		{"unknown product", "42", `{"rating":5}`, http.StatusNotFound},
+	}
+	for _, tc := range tcs {
+		resp := postReview(t, shop.URL, tc.productID, tc.body)
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s: want HTTP %d, got %d", tc.name, tc.want, resp.StatusCode)
+		}
+	}
+
+	resp, err := http.Get(shop.URL + "products/1/reviews?per_page=1000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("want HTTP 400 for too many reviews per page, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,238 @@
+package coffeeshop
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// RouteGroup identifies a group of routes served by the Server.
+type RouteGroup string
+
+const (
+	// APIRoutes serve the coffeeshop API: products, orders,
+	// carts, events, webhooks and GraphQL.
+	APIRoutes RouteGroup = "api"
+	// AdminRoutes serve documentation and administration endpoints.
+	AdminRoutes RouteGroup = "admin"
+	// InfraRoutes serve health probes for the infrastructure.
+	InfraRoutes RouteGroup = "infra"
+)
+
+// WithDelayedGroups configures route groups delayed by the
+// configured latency. By default only API routes are delayed.
+// Call it without groups to disable latency for all routes.
+func WithDelayedGroups(groups ...RouteGroup) Option {
+	return func(s *Server) error {
+		for _, g := range groups {
+			switch g {
+			case APIRoutes, AdminRoutes, InfraRoutes:
+			default:
+				return fmt.Errorf("unknown route group %q", g)
+			}
+		}
+		s.DelayedGroups = groups
+		return nil
+	}
+}
+
+func (cs *Server) delayed(g RouteGroup) bool {
+	for _, d := range cs.DelayedGroups {
+		if d == g {
+			return true
+		}
+	}
+	return false
+}
+
+// WithMiddleware adds middleware wrapping all routes, for example
+// authentication, logging or tracing. Middleware runs in the order
+// it was added, after the request ID is assigned and CORS requests
+// are answered, and before the configured latency.
+func WithMiddleware(mw ...func(http.Handler) http.Handler) Option {
+	return func(s *Server) error {
+		for _, m := range mw {
+			if m == nil {
+				return errors.New("nil middleware")
+			}
+		}
+		s.Middlewares = append(s.Middlewares, mw...)
+		return nil
+	}
+}
+
+// group mounts routes of the route group with middleware
+// configured for the group.
+func (cs *Server) group(mux chi.Router, g RouteGroup, routes func(r chi.Router)) {
+	mux.Group(func(r chi.Router) {
+		if cs.MaxConcurrentRequests > 0 && g != InfraRoutes {
+			r.Use(cs.shedLoad)
+		}
+		if len(cs.Policy.Groups[g]) > 0 {
+			r.Use(cs.authorize(g))
+		}
+		if g == AdminRoutes && cs.AdminToken != "" {
+			r.Use(cs.requireAdminToken)
+		}
+		if g == APIRoutes && len(cs.Scenario.Steps) > 0 {
+			r.Use(cs.playScenario)
+		}
+		if g == APIRoutes && cs.FaultRate > 0 {
+			r.Use(cs.injectFaults)
+		}
+		if cs.delayed(g) {
+			if cs.HeaderLatency {
+				r.Use(cs.delay)
+			} else {
+				r.Use(cs.wait)
+			}
+			if cs.LatencyJitter > 0 {
+				r.Use(cs.jitter)
+			}
+			if cs.Throttle > 0 {
+				r.Use(cs.throttle)
+			}
+		}
+		if g == APIRoutes && cs.RecordingPath != "" {
+			r.Use(cs.record)
+		}
+		if g == APIRoutes && cs.replay != nil {
+			r.Use(cs.replayRecording)
+		}
+		if cs.Compressor != nil {
+			r.Use(cs.Compressor.Handler)
+		}
+		if g == APIRoutes {
+			r.Use(cs.logBodies)
+		}
+		routes(r)
+	})
+}
+
+func notFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusNotFound, "not found")
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+}
+
+// routes returns the handler serving all routes.
+func (cs *Server) routes() http.Handler {
+	mux := chi.NewRouter()
+	mux.Use(
+		middleware.RequestID,
+		echoRequestID,
+		reportProtocol,
+		cs.enforceDeadline,
+		middleware.SetHeader("Content-Type", "application/json; charset=utf-8"),
+		cs.realIP,
+	)
+	if cs.MaxConnectionRequests > 0 || cs.MaxConnectionAge > 0 {
+		mux.Use(cs.limitConnections)
+	}
+	if len(cs.CORSOrigins) > 0 {
+		mux.Use(cs.cors)
+	}
+	mux.Use(cs.Middlewares...)
+	if cs.Tenants {
+		mux.Use(cs.serveTenants)
+	}
+	mux.NotFound(notFound)
+	mux.MethodNotAllowed(methodNotAllowed)
+	cs.group(mux, InfraRoutes, func(r chi.Router) {
+		r.Get("/healthz", cs.Healthz)
+		r.Get("/readyz", cs.Readyz)
+		r.Get("/version", cs.GetVersion)
+		r.Get("/metrics", cs.GetMetrics)
+	})
+	cs.group(mux, AdminRoutes, func(r chi.Router) {
+		r.Get("/openapi.json", cs.GetOpenAPI)
+		r.Get("/about", cs.GetAbout)
+		r.Get("/graphql/schema", cs.GetGraphQLSchema)
+		r.Get("/admin/inventory/export", cs.ExportInventory)
+		r.Post("/admin/products/migrate", cs.MigrateProducts)
+		r.Get("/admin/audit", cs.GetAudit)
+		r.Post("/admin/promotions", cs.CreatePromotion)
+		r.Get("/admin/promotions", cs.GetPromotions)
+		r.Post("/admin/scenario/reset", cs.RestartScenario)
+		r.Post("/admin/reset", cs.ResetData)
+		r.Get("/admin/debug", cs.GetDebug)
+		r.Put("/admin/debug", cs.SetDebug)
+		r.Get("/admin/cache", cs.GetCacheStats)
+		r.Delete("/admin/cache", cs.InvalidateCache)
+		r.Get("/admin/time", cs.GetTime)
+		r.Post("/admin/time/advance", cs.AdvanceTime)
+		r.Post("/admin/snapshots", cs.CreateSnapshot)
+		r.Get("/admin/snapshots", cs.GetSnapshots)
+		r.Post("/admin/snapshots/{snapshotID}/restore", cs.RestoreSnapshot)
+		r.Post("/admin/tenants", cs.CreateTenant)
+		r.Get("/admin/tenants", cs.GetTenants)
+		r.Get("/admin/tenants/{tenantID}", cs.GetTenant)
+		r.Delete("/admin/tenants/{tenantID}", cs.DeleteTenant)
+		if cs.Docs {
+			r.Get("/docs", cs.GetDocs)
+		}
+		if cs.Profiling {
+			mountProfiling(r)
+		}
+	})
+	cs.group(mux, APIRoutes, cs.mountAPIVersions)
+	return mux
+}
+
+// v1Routes registers routes of the version 1 of the API.
+func (cs *Server) v1Routes(r chi.Router) {
+	r.Get("/products", cs.GetProducts)
+	// Static segments take precedence over URL parameters
+	// regardless of the registration order, so products with
+	// IDs "tea", "coffee" and "stats" are not reachable by ID.
+	r.Get("/products/tea", cs.GetTea)
+	r.Get("/products/coffee", cs.GetCoffee)
+	r.Get("/products/stats", cs.GetProductStats)
+	r.Get("/products/by-sku/{sku}", cs.GetProductBySKU)
+	r.With(cs.idempotent).Post("/products", cs.CreateProduct)
+	r.Get("/products/{productID}", cs.GetProduct)
+	r.Post("/products/import", cs.ImportProducts)
+	r.Put("/products/{productID}", cs.UpdateProduct)
+	r.Delete("/products/{productID}", cs.DeleteProduct)
+	r.Post("/products/{productID}/restore", cs.RestoreProduct)
+	r.Get("/products/{productID}/price-history", cs.GetPriceHistory)
+	r.Get("/products/{productID}/related", cs.GetRelatedProducts)
+	r.Post("/products/{productID}/reviews", cs.CreateReview)
+	r.Get("/products/{productID}/reviews", cs.GetReviews)
+	r.Put("/products/{productID}/stock", cs.RestockProduct)
+	r.Put("/products/{productID}/image", cs.PutProductImage)
+	r.Get("/products/{productID}/image", cs.GetProductImage)
+	r.Get("/properties/schema", cs.GetPropertySchema)
+	r.Get("/categories", cs.GetCategories)
+	r.Get("/categories/{category}/products", cs.GetCategoryProducts)
+	r.With(cs.idempotent).Post("/orders", cs.CreateOrder)
+	r.Get("/orders", cs.GetOrders)
+	r.Get("/orders/{orderID}", cs.GetOrder)
+	r.Get("/queue", cs.GetQueue)
+	r.Post("/customers", cs.RegisterCustomer)
+	r.Post("/customers/login", cs.Login)
+	r.Post("/customers/logout", cs.Logout)
+	r.Get("/customers/me", cs.GetProfile)
+	r.Put("/customers/me", cs.UpdateProfile)
+	r.Get("/customers/me/orders", cs.GetCustomerOrders)
+	r.Post("/carts", cs.CreateCart)
+	r.Get("/carts/{cartID}", cs.GetCart)
+	r.Post("/carts/{cartID}/items", cs.AddCartItem)
+	r.Delete("/carts/{cartID}/items/{productID}", cs.DeleteCartItem)
+	r.Post("/carts/{cartID}/checkout", cs.Checkout)
+	r.Get("/events", cs.GetEvents)
+	r.Get("/events/log", cs.GetEventLog)
+	r.Get("/sync", cs.GetSync)
+	r.Get("/ws/orders/{orderID}", cs.WatchOrder)
+	r.Post("/webhooks", cs.CreateWebhook)
+	r.Get("/webhooks", cs.GetWebhooks)
+	r.Delete("/webhooks/{webhookID}", cs.DeleteWebhook)
+	r.Get("/webhooks/{webhookID}/deliveries", cs.GetWebhookDeliveries)
+	r.Get("/graphql", cs.GraphQL)
+	r.Post("/graphql", cs.GraphQL)
+}
@@ -0,0 +1,176 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+func TestServer_DoesNotDelayAdminRoutesByDefault(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "5s", t)
+	client := http.Client{Timeout: time.Second}
+	resp, err := client.Get(shop.URL + "openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("want status 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_DelaysOnlyConfiguredRouteGroups(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "300ms", t, coffeeshop.WithDelayedGroups(coffeeshop.InfraRoutes))
+
+	start := time.Now()
+	resp, err := http.Get(shop.URL + "healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("want delayed health probe, got response after %v", elapsed)
+	}
+
+	start = time.Now()
+	resp, err = http.Get(shop.URL + "products/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if elapsed := time.Since(start); elapsed >= 300*time.Millisecond {
+		t.Errorf("want products served without delay, got response after %v", elapsed)
+	}
+}
+
+func TestWithDelayedGroups_FailsOnUnknownGroup(t *testing.T) {
+	t.Parallel()
+
+	_, err := coffeeshop.New(":0", &coffeeshop.MemoryStore{}, coffeeshop.WithDelayedGroups("metrics"))
+	if err == nil {
+		t.Error("want error on unknown route group")
+	}
+}
+
+func TestServer_RoutesProductTypesBeforeProductID(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: coffeeshop.Products{
+			"1":   {ID: "1", Type: "Coffee", Name: "Intenso"},
+			"2":   {ID: "2", Type: "Tea", Name: "Green"},
+			"tea": {ID: "tea", Type: "Cocoa", Name: "Shadowed"},
+		},
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	for path, want := range map[string]string{
+		"products/tea":    "2",
+		"products/coffee": "1",
+	} {
+		resp, err := http.Get(shop.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []coffeeshop.Product
+		err = json.NewDecoder(resp.Body).Decode(&got)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("%s: want list of products: %v", path, err)
+		}
+		if len(got) == 0 || got[0].ID != want {
+			t.Errorf("%s: want product %s first, got %+v", path, want, got)
+		}
+	}
+
+	resp, err := http.Get(shop.URL + "products/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got coffeeshop.Product
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != "2" {
+		t.Errorf("want product 2, got %q", got.ID)
+	}
+}
+
+func TestServer_RunsCustomMiddlewareInOrder(t *testing.T) {
+	t.Parallel()
+
+	tag := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Middleware", name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	deny := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t,
+		coffeeshop.WithMiddleware(tag("first"), tag("second")),
+		coffeeshop.WithMiddleware(deny),
+	)
+
+	resp, err := http.Get(shop.URL + "products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("want HTTP 401 from middleware, got %d", resp.StatusCode)
+	}
+	want := []string{"first", "second"}
+	if got := resp.Header.Values("X-Middleware"); !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+
+	req, err := http.NewRequest(http.MethodGet, shop.URL+"healthz", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer token")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("want HTTP 200 through middleware, got %d", resp.StatusCode)
+	}
+}
+
+func TestWithMiddleware_FailsOnNilMiddleware(t *testing.T) {
+	t.Parallel()
+
+	if _, err := coffeeshop.New("localhost:0", &coffeeshop.MemoryStore{}, coffeeshop.WithMiddleware(nil)); err == nil {
+		t.Error("want error for nil middleware")
+	}
+}
@@ -0,0 +1,196 @@
+package coffeeshop
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Step is a behaviour of the server for a number of
+// consecutive API requests in a scenario.
+type Step struct {
+	// Times is the number of requests the step applies to.
+	// Zero means all remaining requests and is allowed
+	// in the last step only.
+	Times int
+	// Delay holds requests before they are answered.
+	Delay time.Duration
+	// Status makes the server respond with the error status
+	// instead of serving requests. Zero serves requests.
+	Status int
+	// Hang holds requests without answering them until
+	// clients give up or the server shuts down.
+	Hang bool
+}
+
+// Scenario is a sequence of behaviours of the server applied
+// to API requests in order. Once all steps are used up the
+// server serves requests normally. For example, a scenario
+// responding to the first three requests with 503, answering
+// the next one after two seconds and never answering after:
+//
+//	Scenario{Steps: []Step{
+//		{Times: 3, Status: http.StatusServiceUnavailable},
+//		{Times: 1, Delay: 2 * time.Second},
+//		{Hang: true},
+//	}}
+type Scenario struct {
+	Steps []Step `json:"steps"`
+}
+
+// stepJSON is the JSON representation of a step
+// with the delay in the "2s" format.
+type stepJSON struct {
+	Times  int    `json:"times,omitempty"`
+	Delay  string `json:"delay,omitempty"`
+	Status int    `json:"status,omitempty"`
+	Hang   bool   `json:"hang,omitempty"`
+}
+
+// MarshalJSON encodes the step with the delay in the "2s" format.
+func (s Step) MarshalJSON() ([]byte, error) {
+	sj := stepJSON{Times: s.Times, Status: s.Status, Hang: s.Hang}
+	if s.Delay > 0 {
+		sj.Delay = s.Delay.String()
+	}
+	return json.Marshal(sj)
+}
+
+// UnmarshalJSON decodes the step with the delay in the "2s" format.
+func (s *Step) UnmarshalJSON(data []byte) error {
+	var sj stepJSON
+	if err := json.Unmarshal(data, &sj); err != nil {
+		return err
+	}
+	var delay time.Duration
+	if sj.Delay != "" {
+		var err error
+		delay, err = time.ParseDuration(sj.Delay)
+		if err != nil {
+			return err
+		}
+	}
+	*s = Step{Times: sj.Times, Delay: delay, Status: sj.Status, Hang: sj.Hang}
+	return nil
+}
+
+// validate returns the first problem of the scenario.
+func (s Scenario) validate() error {
+	if len(s.Steps) == 0 {
+		return errors.New("scenario without steps")
+	}
+	for i, step := range s.Steps {
+		switch {
+		case step.Times < 0:
+			return fmt.Errorf("step %d: negative times", i+1)
+		case step.Times == 0 && i < len(s.Steps)-1:
+			return fmt.Errorf("step %d: only the last step can apply to all requests", i+1)
+		case step.Delay < 0:
+			return fmt.Errorf("step %d: negative delay", i+1)
+		case step.Status != 0 && (step.Status < 400 || step.Status > 599):
+			return fmt.Errorf("step %d: status %d isn't an error status", i+1, step.Status)
+		case step.Hang && step.Status != 0:
+			return fmt.Errorf("step %d: hanging step can't respond with a status", i+1)
+		}
+	}
+	return nil
+}
+
+// WithScenario makes API requests follow the steps of the scenario.
+// The scenario starts over when it is reset with ResetScenario
+// or with POST /admin/scenario/reset.
+func WithScenario(s Scenario) Option {
+	return func(srv *Server) error {
+		if err := s.validate(); err != nil {
+			return err
+		}
+		srv.Scenario = s
+		return nil
+	}
+}
+
+// scenarioProgress tracks the step of the scenario
+// applied to the next request.
+type scenarioProgress struct {
+	mx   sync.Mutex
+	step int
+	// served is the number of requests served by the step.
+	served int
+}
+
+// next returns the step applied to the next request.
+func (sp *scenarioProgress) next(s Scenario) (Step, bool) {
+	sp.mx.Lock()
+	defer sp.mx.Unlock()
+	for sp.step < len(s.Steps) {
+		step := s.Steps[sp.step]
+		if step.Times == 0 {
+			return step, true
+		}
+		if sp.served < step.Times {
+			sp.served++
+			return step, true
+		}
+		sp.step++
+		sp.served = 0
+	}
+	return Step{}, false
+}
+
+func (sp *scenarioProgress) reset() {
+	sp.mx.Lock()
+	defer sp.mx.Unlock()
+	sp.step = 0
+	sp.served = 0
+}
+
+// ResetScenario starts the scenario over from the first step.
+func (cs *Server) ResetScenario() {
+	cs.scenario.reset()
+}
+
+// playScenario applies the next step of the scenario to the request.
+func (cs *Server) playScenario(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		step, ok := cs.scenario.next(cs.Scenario)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if step.Hang {
+			select {
+			case <-r.Context().Done():
+			case <-cs.shuttingDown.Done():
+			}
+			return
+		}
+		if step.Delay > 0 {
+			t := time.NewTimer(step.Delay)
+			select {
+			case <-r.Context().Done():
+				t.Stop()
+				return
+			case <-t.C:
+			}
+		}
+		if step.Status != 0 {
+			writeError(w, r, step.Status, http.StatusText(step.Status))
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// RestartScenario starts the scenario over from the first step.
+func (cs *Server) RestartScenario(w http.ResponseWriter, r *http.Request) {
+	if len(cs.Scenario.Steps) == 0 {
+		writeError(w, r, http.StatusNotImplemented, "server doesn't play a scenario")
+		return
+	}
+	cs.ResetScenario()
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,113 @@
+package coffeeshop_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// getStatus returns the status of the response to the request.
+func getStatus(t *testing.T, client *http.Client, url string) (int, error) {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func TestServer_PlaysScenarioStepsInOrder(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "1ms", t,
+		coffeeshop.WithScenario(coffeeshop.Scenario{Steps: []coffeeshop.Step{
+			{Times: 3, Status: http.StatusServiceUnavailable},
+			{Times: 1, Delay: 200 * time.Millisecond},
+			{Hang: true},
+		}}),
+	)
+	client := &http.Client{Timeout: time.Second}
+	for i := 0; i < 3; i++ {
+		status, err := getStatus(t, client, shop.URL+"products")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != http.StatusServiceUnavailable {
+			t.Errorf("request %d: want HTTP 503, got %d", i+1, status)
+		}
+	}
+
+	start := time.Now()
+	status, err := getStatus(t, client, shop.URL+"products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusOK || time.Since(start) < 200*time.Millisecond {
+		t.Errorf("want delayed HTTP 200, got %d after %v", status, time.Since(start))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, shop.URL+"products", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := http.DefaultClient.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want request timing out, got %v", err)
+	}
+}
+
+func TestServer_RestartsScenario(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "1ms", t,
+		coffeeshop.WithScenario(coffeeshop.Scenario{Steps: []coffeeshop.Step{
+			{Times: 1, Status: http.StatusTooManyRequests},
+		}}),
+	)
+	client := http.DefaultClient
+	for _, want := range []int{http.StatusTooManyRequests, http.StatusOK} {
+		if got, err := getStatus(t, client, shop.URL+"products"); err != nil || got != want {
+			t.Fatalf("want HTTP %d, got %d (%v)", want, got, err)
+		}
+	}
+	// Admin routes don't advance the scenario.
+	resp, err := http.Post(shop.URL+"admin/scenario/reset", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("want HTTP 204, got %d", resp.StatusCode)
+	}
+	if got, err := getStatus(t, client, shop.URL+"products"); err != nil || got != http.StatusTooManyRequests {
+		t.Errorf("want HTTP 429 after reset, got %d (%v)", got, err)
+	}
+	shop.ResetScenario()
+	if got, err := getStatus(t, client, shop.URL+"products"); err != nil || got != http.StatusTooManyRequests {
+		t.Errorf("want HTTP 429 after reset, got %d (%v)", got, err)
+	}
+}
+
+func TestWithScenario_RejectsInvalidScenarios(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]coffeeshop.Scenario{
+		"no steps":            {},
+		"unreachable step":    {Steps: []coffeeshop.Step{{}, {Times: 1}}},
+		"non error status":    {Steps: []coffeeshop.Step{{Times: 1, Status: http.StatusOK}}},
+		"hang with status":    {Steps: []coffeeshop.Step{{Hang: true, Status: http.StatusBadGateway}}},
+		"negative delay":      {Steps: []coffeeshop.Step{{Times: 1, Delay: -time.Second}}},
+		"negative step times": {Steps: []coffeeshop.Step{{Times: -1}}},
+	}
+	for name, s := range tcs {
+		if _, err := coffeeshop.New("localhost:0", &coffeeshop.MemoryStore{}, coffeeshop.WithScenario(s)); err == nil {
+			t.Errorf("%s: want error", name)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package coffeeshop
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxSKULength limits the length of SKUs.
+const maxSKULength = 64
+
+// SKULookup is implemented by stores able to find products by
+// their SKU or EAN with an index, without reading all products.
+type SKULookup interface {
+	// GetBySKU returns the product with the SKU or, if no product
+	// has the SKU, the product with the EAN equal to it.
+	GetBySKU(code string) (Product, error)
+}
+
+// validSKU reports whether the SKU is short enough
+// and has no whitespace.
+func validSKU(sku string) bool {
+	return len(sku) <= maxSKULength && strings.IndexFunc(sku, unicode.IsSpace) < 0
+}
+
+// validEAN reports whether the EAN-8 or EAN-13 barcode
+// has a valid check digit.
+func validEAN(ean string) bool {
+	if len(ean) != 8 && len(ean) != 13 {
+		return false
+	}
+	sum := 0
+	for i := len(ean) - 1; i >= 0; i-- {
+		c := ean[i]
+		if c < '0' || c > '9' {
+			return false
+		}
+		digit := int(c - '0')
+		// Digits are weighted 1 and 3 alternately from the
+		// check digit, which is weighted 1.
+		if (len(ean)-1-i)%2 == 1 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	return sum%10 == 0
+}
+
+// codes returns the SKU and the EAN of the product, if set.
+func (p Product) codes() []string {
+	var codes []string
+	for _, code := range []string{p.SKU, p.EAN} {
+		if code != "" {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// hasCode reports whether the product has the SKU or EAN.
+func (p Product) hasCode(code string) bool {
+	return code != "" && (p.SKU == code || p.EAN == code)
+}
+
+// codeTaken returns ErrSKUExists if another product found by the
+// lookup has the SKU or EAN of the product.
+func codeTaken(lookup func(code string) (Product, error), p Product) error {
+	for _, code := range p.codes() {
+		other, err := lookup(code)
+		if errors.Is(err, ErrProductNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if other.ID != p.ID {
+			return fmt.Errorf("%w: %s is used by product %s", ErrSKUExists, code, other.ID)
+		}
+	}
+	return nil
+}
+
+// GetBySKU returns the product with the SKU or EAN. The index of
+// codes is checked against the products on every hit and rebuilt
+// on a miss, so products set directly in the Products map are
+// found too.
+func (ms *MemoryStore) GetBySKU(code string) (Product, error) {
+	ms.mx.RLock()
+	p, ok := ms.indexedProduct(code)
+	ms.mx.RUnlock()
+	if ok {
+		return p.clone(), nil
+	}
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	ms.reindexCodes()
+	if p, ok := ms.indexedProduct(code); ok {
+		return p.clone(), nil
+	}
+	return Product{}, ErrProductNotFound
+}
+
+// indexedProduct returns the product found in the index by the SKU,
+// or by the EAN. It must be called with the store lock held.
+func (ms *MemoryStore) indexedProduct(code string) (Product, bool) {
+	if p, ok := ms.Products[ms.skus[code]]; ok && p.SKU == code {
+		return p, true
+	}
+	if p, ok := ms.Products[ms.eans[code]]; ok && p.EAN == code {
+		return p, true
+	}
+	return Product{}, false
+}
+
+// reindexCodes rebuilds indexes of SKUs and EANs.
+// It must be called with the store lock held.
+func (ms *MemoryStore) reindexCodes() {
+	ms.skus = make(map[string]string)
+	ms.eans = make(map[string]string)
+	for _, p := range ms.Products {
+		ms.indexCodes(p)
+	}
+}
+
+// indexCodes adds the SKU and the EAN of the product to indexes.
+// It must be called with the store lock held.
+func (ms *MemoryStore) indexCodes(p Product) {
+	if ms.skus == nil {
+		ms.skus = make(map[string]string)
+		ms.eans = make(map[string]string)
+	}
+	if p.SKU != "" {
+		ms.skus[p.SKU] = p.ID
+	}
+	if p.EAN != "" {
+		ms.eans[p.EAN] = p.ID
+	}
+}
+
+// checkCodes returns ErrSKUExists if another product has the SKU
+// or EAN of the product. It must be called with the store lock held.
+func (ms *MemoryStore) checkCodes(p Product) error {
+	return codeTaken(func(code string) (Product, error) {
+		if p, ok := ms.indexedProduct(code); ok {
+			return p, nil
+		}
+		for _, other := range ms.Products {
+			if other.hasCode(code) {
+				return other, nil
+			}
+		}
+		return Product{}, ErrProductNotFound
+	}, p)
+}
+
+// productBySKU returns the product with the SKU or EAN from the
+// store. Stores which aren't SKULookups are searched product
+// by product.
+func productBySKU(s Store, code string) (Product, error) {
+	if l, ok := s.(SKULookup); ok {
+		return l.GetBySKU(code)
+	}
+	var byEAN *Product
+	for _, p := range s.GetAll() {
+		if p.SKU == code {
+			return p, nil
+		}
+		if p.EAN == code && byEAN == nil {
+			p := p
+			byEAN = &p
+		}
+	}
+	if byEAN != nil {
+		return *byEAN, nil
+	}
+	return Product{}, ErrProductNotFound
+}
+
+// GetProductBySKU returns the product with the SKU or, for
+// point-of-sale clients scanning barcodes, the EAN in the URL.
+func (cs *Server) GetProductBySKU(w http.ResponseWriter, r *http.Request) {
+	product, err := productBySKU(cs.store(r), chi.URLParam(r, "sku"))
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	cs.writeProduct(w, r, product)
+}
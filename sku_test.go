@@ -0,0 +1,136 @@
+package coffeeshop_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/qba73/coffeeshop"
+)
+
+type skuStore interface {
+	coffeeshop.Store
+	coffeeshop.Importer
+	coffeeshop.SKULookup
+}
+
+func codedProducts() coffeeshop.Products {
+	return coffeeshop.Products{
+		"1": {ID: "1", Type: "Coffee", Brand: "illy", Name: "Classico", SKU: "ILLY-CL-250", EAN: "8003753900438"},
+		"2": {ID: "2", Type: "Coffee", Brand: "Lavazza", Name: "Oro", SKU: "LAV-ORO-1000", EAN: "4006381333931"},
+		"3": {ID: "3", Type: "Tea", Brand: "Caykur", Name: "Green Tea", EAN: "96385074"},
+	}
+}
+
+func TestStores_FindProductsBySKUAndEAN(t *testing.T) {
+	t.Parallel()
+
+	for name, newStore := range map[string]func(t *testing.T) skuStore{
+		"memory": func(t *testing.T) skuStore { return &coffeeshop.MemoryStore{Products: codedProducts()} },
+		"redis":  func(t *testing.T) skuStore { return newRedisStore(t, codedProducts()) },
+		"mongo": func(t *testing.T) skuStore {
+			store, _ := newMongoStore(t, codedProducts())
+			return store
+		},
+	} {
+		newStore := newStore
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			store := newStore(t)
+			for code, want := range map[string]string{"LAV-ORO-1000": "2", "8003753900438": "1", "96385074": "3"} {
+				p, err := store.GetBySKU(code)
+				if err != nil {
+					t.Fatalf("%s: %v", code, err)
+				}
+				if p.ID != want {
+					t.Errorf("%s: want product %s, got %s", code, want, p.ID)
+				}
+			}
+
+			p, err := store.GetProduct("1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.SKU = "ILLY-CL-500"
+			if _, err := store.PutProduct(p); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.GetBySKU("ILLY-CL-250"); !errors.Is(err, coffeeshop.ErrProductNotFound) {
+				t.Errorf("want ErrProductNotFound for the old SKU, got %v", err)
+			}
+			if p, err := store.GetBySKU("ILLY-CL-500"); err != nil || p.ID != "1" {
+				t.Errorf("want product 1 by the new SKU, got %v, %v", p.ID, err)
+			}
+
+			duplicate := coffeeshop.Product{ID: "4", Type: "Coffee", Name: "Copy", EAN: "4006381333931"}
+			if _, err := store.PutProduct(duplicate); !errors.Is(err, coffeeshop.ErrSKUExists) {
+				t.Errorf("want ErrSKUExists, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMemoryStore_FindsProductsSetDirectlyBySKU(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: codedProducts()}
+	if _, err := store.GetBySKU("ILLY-CL-250"); err != nil {
+		t.Fatal(err)
+	}
+	store.Products["5"] = coffeeshop.Product{ID: "5", Type: "Tea", Name: "Earl Grey", SKU: "TEA-EG"}
+	if p, err := store.GetBySKU("TEA-EG"); err != nil || p.ID != "5" {
+		t.Errorf("want product 5, got %v, %v", p.ID, err)
+	}
+}
+
+func TestServer_GetsProductBySKU(t *testing.T) {
+	t.Parallel()
+
+	for name, store := range map[string]coffeeshop.Store{
+		"sku lookup":  &coffeeshop.MemoryStore{Products: codedProducts()},
+		"plain store": plainStore{&coffeeshop.MemoryStore{Products: codedProducts()}},
+	} {
+		store := store
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			shop := newCoffeShopTestServer(store, "0s", t)
+			status, body := getResponse(t, shop.URL+"products/by-sku/4006381333931")
+			if status != http.StatusOK {
+				t.Fatalf("want HTTP 200, got %d", status)
+			}
+			if !strings.Contains(body, `"id":"2"`) {
+				t.Errorf("want product 2, got %s", body)
+			}
+			status, _ = getResponse(t, shop.URL+"products/by-sku/UNKNOWN")
+			if status != http.StatusNotFound {
+				t.Errorf("want HTTP 404, got %d", status)
+			}
+		})
+	}
+}
+
+func TestServer_RejectsDuplicateAndInvalidCodes(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: codedProducts()}, "0s", t)
+	tests := map[string]int{
+		`{"type": "Coffee", "name": "Copy", "sku": "LAV-ORO-1000"}`:                 http.StatusConflict,
+		`{"type": "Coffee", "name": "Spaced", "sku": "LAV ORO"}`:                    http.StatusBadRequest,
+		`{"type": "Coffee", "name": "Bad EAN", "ean": "4006381333932"}`:             http.StatusBadRequest,
+		`{"type": "Coffee", "name": "Short EAN", "ean": "123"}`:                     http.StatusBadRequest,
+		`{"type": "Coffee", "name": "New", "sku": "NEW-1", "ean": "5901234123457"}`: http.StatusCreated,
+	}
+	for body, want := range tests {
+		resp, err := http.Post(shop.URL+"products", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("%s: want HTTP %d, got %d", body, want, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,361 @@
+package coffeeshop
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/exp/maps"
+)
+
+// DefaultMaxStateSnapshots is the number of state snapshots
+// kept in memory unless configured with WithMaxStateSnapshots.
+const DefaultMaxStateSnapshots = 20
+
+// StateSnapshot describes the state of memory stores captured with
+// POST /admin/snapshots. Unlike snapshots written with WithSnapshots,
+// state snapshots are held in memory and include carts, customers,
+// reviews, images, promotions and the audit log. Only the latest
+// snapshots are kept, and older ones are dropped.
+type StateSnapshot struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	Products  int       `json:"products"`
+	Orders    int       `json:"orders"`
+}
+
+// stateStore is implemented by memory stores able to save copies
+// of their data and to restore them. Like on reset, stores keep
+// assigning IDs after the last ID assigned before restoring.
+// Wrappers of stores of products holding copies of products, like
+// the CachingStore, forward it and drop the copies on restore.
+type stateStore interface {
+	saveState() any
+	restoreState(state any)
+}
+
+// savedState is a state snapshot with the data of stores.
+type savedState struct {
+	StateSnapshot
+	stores   map[stateStore]any
+	sessions map[string]session
+}
+
+// snapshotRegistry holds state snapshots by ID. Max limits the
+// number of snapshots kept, and zero means the default limit.
+type snapshotRegistry struct {
+	mx        sync.Mutex
+	max       int
+	lastID    int
+	snapshots map[string]*savedState
+}
+
+// add registers the snapshot and drops the oldest
+// snapshots over the limit.
+func (sr *snapshotRegistry) add(s *savedState) StateSnapshot {
+	sr.mx.Lock()
+	defer sr.mx.Unlock()
+	if sr.snapshots == nil {
+		sr.snapshots = make(map[string]*savedState)
+	}
+	sr.lastID++
+	s.ID = strconv.Itoa(sr.lastID)
+	sr.snapshots[s.ID] = s
+	limit := sr.max
+	if limit == 0 {
+		limit = DefaultMaxStateSnapshots
+	}
+	// IDs are assigned in sequence, so the oldest
+	// kept snapshot has the ID lastID-limit+1.
+	for id := sr.lastID - limit; id > 0; id-- {
+		if _, ok := sr.snapshots[strconv.Itoa(id)]; !ok {
+			break
+		}
+		delete(sr.snapshots, strconv.Itoa(id))
+	}
+	return s.StateSnapshot
+}
+
+// WithMaxStateSnapshots limits the number of state snapshots
+// captured with POST /admin/snapshots kept in memory. When
+// the limit is reached, the oldest snapshot is dropped.
+func WithMaxStateSnapshots(n int) Option {
+	return func(s *Server) error {
+		if n <= 0 {
+			return errors.New("max state snapshots must be positive")
+		}
+		s.snapshots.max = n
+		return nil
+	}
+}
+
+func (sr *snapshotRegistry) get(id string) (*savedState, bool) {
+	sr.mx.Lock()
+	defer sr.mx.Unlock()
+	s, ok := sr.snapshots[id]
+	return s, ok
+}
+
+// list returns state snapshots sorted by ID.
+func (sr *snapshotRegistry) list() []StateSnapshot {
+	sr.mx.Lock()
+	defer sr.mx.Unlock()
+	snapshots := make([]StateSnapshot, 0, len(sr.snapshots))
+	for _, s := range sr.snapshots {
+		snapshots = append(snapshots, s.StateSnapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return lessID(snapshots[i].ID, snapshots[j].ID) })
+	return snapshots
+}
+
+// stateStores returns stores of the server holding their data in
+// memory. The store of products is found through its wrappers.
+func (cs *Server) stateStores() []stateStore {
+	var stores []stateStore
+	if s, ok := storeAs[stateStore](cs.Store); ok {
+		stores = append(stores, s)
+	}
+	for _, store := range []any{
+		cs.OrderStore,
+		cs.CartStore,
+		cs.CustomerStore,
+		cs.ReviewStore,
+		cs.ImageStore,
+		cs.PromotionStore,
+		cs.AuditSink,
+	} {
+		if s, ok := store.(stateStore); ok {
+			stores = append(stores, s)
+		}
+	}
+	return stores
+}
+
+// SaveState captures the state of memory stores and login sessions
+// to restore it later with RestoreState. It returns false if
+// products aren't held in a MemoryStore, even behind wrappers
+// like the CachingStore.
+func (cs *Server) SaveState() (StateSnapshot, bool) {
+	if _, ok := storeAs[stateStore](cs.Store); !ok {
+		return StateSnapshot{}, false
+	}
+	s := &savedState{
+		StateSnapshot: StateSnapshot{
+			CreatedAt: cs.Clock.Now().UTC(),
+			Products:  len(cs.Store.GetAll()),
+			Orders:    len(cs.OrderStore.GetOrders()),
+		},
+		stores: make(map[stateStore]any),
+	}
+	for _, store := range cs.stateStores() {
+		s.stores[store] = store.saveState()
+	}
+	cs.sessions.mx.Lock()
+	s.sessions = maps.Clone(cs.sessions.sessions)
+	cs.sessions.mx.Unlock()
+	return cs.snapshots.add(s), true
+}
+
+// RestoreState restores the state captured in the snapshot.
+// It returns false if there is no snapshot with the ID.
+func (cs *Server) RestoreState(id string) bool {
+	s, ok := cs.snapshots.get(id)
+	if !ok {
+		return false
+	}
+	for store, state := range s.stores {
+		store.restoreState(state)
+	}
+	cs.sessions.mx.Lock()
+	cs.sessions.sessions = maps.Clone(s.sessions)
+	cs.sessions.mx.Unlock()
+	return true
+}
+
+// CreateSnapshot captures the state of the server.
+func (cs *Server) CreateSnapshot(w http.ResponseWriter, r *http.Request) {
+	s, ok := cs.SaveState()
+	if !ok {
+		writeError(w, r, http.StatusNotImplemented, "server can't snapshot products outside of the memory store")
+		return
+	}
+	w.Header().Set("Location", resourcePath(r, "/admin/snapshots/"+s.ID))
+	writeJSON(w, r, http.StatusCreated, s)
+}
+
+// GetSnapshots returns state snapshots sorted by ID.
+func (cs *Server) GetSnapshots(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, cs.snapshots.list())
+}
+
+// RestoreSnapshot restores the state captured in the snapshot.
+func (cs *Server) RestoreSnapshot(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "snapshotID")
+	if !cs.RestoreState(id) {
+		writeErrorCode(w, r, http.StatusNotFound, "snapshot_not_found", fmt.Sprintf("snapshot %s not found", id))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// memoryStoreState holds products and price history of the MemoryStore.
+type memoryStoreState struct {
+	products Products
+	prices   map[string][]PricePoint
+}
+
+func (ms *MemoryStore) saveState() any {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	products := make(Products, len(ms.Products))
+	for id, p := range ms.Products {
+		products[id] = p.clone()
+	}
+	prices := make(map[string][]PricePoint, len(ms.prices))
+	for id, history := range ms.prices {
+		prices[id] = append([]PricePoint(nil), history...)
+	}
+	return memoryStoreState{products: products, prices: prices}
+}
+
+func (ms *MemoryStore) restoreState(state any) {
+	saved := state.(memoryStoreState)
+	products := make(Products, len(saved.products))
+	for id, p := range saved.products {
+		products[id] = p.clone()
+	}
+	prices := make(map[string][]PricePoint, len(saved.prices))
+	for id, history := range saved.prices {
+		prices[id] = append([]PricePoint(nil), history...)
+	}
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	ms.Products = products
+	ms.prices = prices
+	ms.expireCursors()
+}
+
+// cloneOrders returns copies of orders not sharing items.
+func cloneOrders(orders map[string]Order) map[string]Order {
+	cloned := make(map[string]Order, len(orders))
+	for id, o := range orders {
+		o.Items = append([]OrderItem(nil), o.Items...)
+		cloned[id] = o
+	}
+	return cloned
+}
+
+func (ms *MemoryOrderStore) saveState() any {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	return cloneOrders(ms.Orders)
+}
+
+func (ms *MemoryOrderStore) restoreState(state any) {
+	orders := cloneOrders(state.(map[string]Order))
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	ms.Orders = orders
+}
+
+// cloneCarts returns copies of carts not sharing items.
+func cloneCarts(carts map[string]Cart) map[string]Cart {
+	cloned := make(map[string]Cart, len(carts))
+	for id, c := range carts {
+		c.Items = append([]CartItem(nil), c.Items...)
+		cloned[id] = c
+	}
+	return cloned
+}
+
+func (ms *MemoryCartStore) saveState() any {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	return cloneCarts(ms.Carts)
+}
+
+func (ms *MemoryCartStore) restoreState(state any) {
+	carts := cloneCarts(state.(map[string]Cart))
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	ms.Carts = carts
+}
+
+func (ms *MemoryCustomerStore) saveState() any {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	return maps.Clone(ms.Customers)
+}
+
+func (ms *MemoryCustomerStore) restoreState(state any) {
+	customers := maps.Clone(state.(map[string]Customer))
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	ms.Customers = customers
+}
+
+// cloneReviews returns copies of reviews not sharing slices.
+func cloneReviews(reviews map[string][]Review) map[string][]Review {
+	cloned := make(map[string][]Review, len(reviews))
+	for id, rx := range reviews {
+		cloned[id] = append([]Review(nil), rx...)
+	}
+	return cloned
+}
+
+func (ms *MemoryReviewStore) saveState() any {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	return cloneReviews(ms.Reviews)
+}
+
+func (ms *MemoryReviewStore) restoreState(state any) {
+	reviews := cloneReviews(state.(map[string][]Review))
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	ms.Reviews = reviews
+}
+
+func (ms *MemoryImageStore) saveState() any {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	return maps.Clone(ms.Images)
+}
+
+func (ms *MemoryImageStore) restoreState(state any) {
+	images := maps.Clone(state.(map[string]Image))
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	ms.Images = images
+}
+
+func (ms *MemoryPromotionStore) saveState() any {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	return maps.Clone(ms.Promotions)
+}
+
+func (ms *MemoryPromotionStore) restoreState(state any) {
+	promotions := maps.Clone(state.(map[string]Promotion))
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	ms.Promotions = promotions
+}
+
+func (l *MemoryAuditLog) saveState() any {
+	l.mx.RLock()
+	defer l.mx.RUnlock()
+	return append([]AuditEntry(nil), l.entries...)
+}
+
+func (l *MemoryAuditLog) restoreState(state any) {
+	entries := append([]AuditEntry(nil), state.([]AuditEntry)...)
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	l.entries = entries
+}
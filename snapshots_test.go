@@ -0,0 +1,140 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// postAdmin sends the POST request to the admin path.
+func postAdmin(t *testing.T, url string) *http.Response {
+	t.Helper()
+	resp, err := http.Post(url, "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestServer_RestoresStateFromSnapshot(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: stockedInventory(10)}
+	orders := &coffeeshop.MemoryOrderStore{}
+	shop := newCoffeShopTestServer(store, "0s", t,
+		coffeeshop.WithOrderStore(orders),
+		coffeeshop.WithOrderInterval("1h"),
+	)
+	resp := createOrder(t, shop.URL, `{"items":[{"productId":"1","quantity":2}]}`)
+	resp.Body.Close()
+
+	resp = postAdmin(t, shop.URL+"admin/snapshots")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("want HTTP 201, got %d", resp.StatusCode)
+	}
+	var snapshot coffeeshop.StateSnapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.ID != "1" || snapshot.Orders != 1 {
+		t.Errorf("want snapshot 1 with 1 order, got %+v", snapshot)
+	}
+
+	resp = createOrder(t, shop.URL, `{"items":[{"productId":"1","quantity":3}]}`)
+	resp.Body.Close()
+	if p, _ := store.GetProduct("1"); p.Stock != 5 {
+		t.Fatalf("want stock 5 after orders, got %d", p.Stock)
+	}
+
+	resp = postAdmin(t, shop.URL+"admin/snapshots/1/restore")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("want HTTP 204, got %d", resp.StatusCode)
+	}
+	if p, _ := store.GetProduct("1"); p.Stock != 8 {
+		t.Errorf("want stock 8 after restore, got %d", p.Stock)
+	}
+	if n := len(orders.GetOrders()); n != 1 {
+		t.Errorf("want 1 order after restore, got %d", n)
+	}
+
+	// The snapshot can be restored again after changes.
+	resp = createOrder(t, shop.URL, `{"items":[{"productId":"1","quantity":1}]}`)
+	resp.Body.Close()
+	resp = postAdmin(t, shop.URL+"admin/snapshots/1/restore")
+	resp.Body.Close()
+	if p, _ := store.GetProduct("1"); p.Stock != 8 {
+		t.Errorf("want stock 8 after second restore, got %d", p.Stock)
+	}
+}
+
+func TestServer_ReportsMissingSnapshot(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(1)}, "0s", t)
+	resp := postAdmin(t, shop.URL+"admin/snapshots/7/restore")
+	defer resp.Body.Close()
+	if code := errorCodeOf(t, resp); resp.StatusCode != http.StatusNotFound || code != "snapshot_not_found" {
+		t.Errorf("want HTTP 404 snapshot_not_found, got %d %s", resp.StatusCode, code)
+	}
+}
+
+func TestServer_RestoresSnapshotBehindCache(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: stockedInventory(10)}
+	shop := newCoffeShopTestServer(store, "0s", t, coffeeshop.WithCache("1h"), coffeeshop.WithOrderInterval("1h"))
+	resp := postAdmin(t, shop.URL+"admin/snapshots")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("want HTTP 201, got %d", resp.StatusCode)
+	}
+	resp = createOrder(t, shop.URL, `{"items":[{"productId":"1","quantity":4}]}`)
+	resp.Body.Close()
+	// Fill the cache with stock left after the order.
+	var px []coffeeshop.Product
+	if err := json.Unmarshal(getBody(t, shop.URL+"products"), &px); err != nil {
+		t.Fatal(err)
+	}
+
+	resp = postAdmin(t, shop.URL+"admin/snapshots/1/restore")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("want HTTP 204, got %d", resp.StatusCode)
+	}
+	if err := json.Unmarshal(getBody(t, shop.URL+"products"), &px); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range px {
+		if p.ID == "1" && p.Stock != 10 {
+			t.Errorf("want restored stock 10 listed, got %d", p.Stock)
+		}
+	}
+}
+
+func TestServer_KeepsLatestStateSnapshots(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(1)}, "0s", t,
+		coffeeshop.WithMaxStateSnapshots(2),
+	)
+	for i := 0; i < 3; i++ {
+		resp := postAdmin(t, shop.URL+"admin/snapshots")
+		resp.Body.Close()
+	}
+	var snapshots []coffeeshop.StateSnapshot
+	if err := json.Unmarshal(getBody(t, shop.URL+"admin/snapshots"), &snapshots); err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 || snapshots[0].ID != "2" || snapshots[1].ID != "3" {
+		t.Errorf("want snapshots 2 and 3 kept, got %+v", snapshots)
+	}
+	resp := postAdmin(t, shop.URL+"admin/snapshots/1/restore")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("want HTTP 404 restoring dropped snapshot, got %d", resp.StatusCode)
+	}
+}
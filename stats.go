@@ -0,0 +1,157 @@
+package coffeeshop
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// PriceStats describes prices of products in a group of products
+// of the same type or brand. Products priced in different currencies
+// are in different groups.
+type PriceStats struct {
+	Type     string `json:"type,omitempty"`
+	Brand    string `json:"brand,omitempty"`
+	Currency string `json:"currency"`
+	Count    int    `json:"count"`
+	MinPrice Money  `json:"min_price"`
+	AvgPrice Money  `json:"avg_price"`
+	MaxPrice Money  `json:"max_price"`
+}
+
+// ProductStats are counts and price statistics of products
+// which aren't archived, grouped by type and by brand.
+type ProductStats struct {
+	Count   int          `json:"count"`
+	ByType  []PriceStats `json:"by_type"`
+	ByBrand []PriceStats `json:"by_brand"`
+}
+
+// StatsAggregator is implemented by stores able to compute
+// statistics of products in their backend, without reading
+// all products.
+type StatsAggregator interface {
+	ProductStats() (ProductStats, error)
+}
+
+// priceGroup accumulates prices of products in a group.
+type priceGroup struct {
+	count         int
+	min, max, sum int64
+}
+
+func (g *priceGroup) add(amount int64) {
+	if g.count == 0 || amount < g.min {
+		g.min = amount
+	}
+	if g.count == 0 || amount > g.max {
+		g.max = amount
+	}
+	g.count++
+	g.sum += amount
+}
+
+// stats returns the statistics of the group. The average
+// price is rounded to the nearest minor unit.
+func (g priceGroup) stats(currency string) PriceStats {
+	avg := (2*g.sum + int64(g.count)) / (2 * int64(g.count))
+	return PriceStats{
+		Currency: currency,
+		Count:    g.count,
+		MinPrice: Money{Amount: g.min, Currency: currency},
+		AvgPrice: Money{Amount: avg, Currency: currency},
+		MaxPrice: Money{Amount: g.max, Currency: currency},
+	}
+}
+
+// groupKey identifies a group of products by type or
+// brand, and currency.
+type groupKey struct {
+	name, currency string
+}
+
+// productStats returns statistics of products which aren't archived.
+// Types are compared case-insensitively, like categories.
+func productStats(px []Product) ProductStats {
+	byType := make(map[groupKey]*priceGroup)
+	byBrand := make(map[groupKey]*priceGroup)
+	count := 0
+	for _, p := range px {
+		if p.Archived {
+			continue
+		}
+		count++
+		for _, g := range []struct {
+			groups map[groupKey]*priceGroup
+			name   string
+		}{
+			{byType, strings.ToLower(p.Type)},
+			{byBrand, p.Brand},
+		} {
+			key := groupKey{g.name, p.Price.Currency}
+			if g.groups[key] == nil {
+				g.groups[key] = &priceGroup{}
+			}
+			g.groups[key].add(p.Price.Amount)
+		}
+	}
+	stats := ProductStats{Count: count, ByType: []PriceStats{}, ByBrand: []PriceStats{}}
+	for key, g := range byType {
+		s := g.stats(key.currency)
+		s.Type = key.name
+		stats.ByType = append(stats.ByType, s)
+	}
+	for key, g := range byBrand {
+		s := g.stats(key.currency)
+		s.Brand = key.name
+		stats.ByBrand = append(stats.ByBrand, s)
+	}
+	sortPriceStats(stats.ByType)
+	sortPriceStats(stats.ByBrand)
+	return stats
+}
+
+// sortPriceStats sorts groups by type or brand and currency.
+func sortPriceStats(sx []PriceStats) {
+	sort.Slice(sx, func(i, j int) bool {
+		a, b := sx[i].Type+sx[i].Brand, sx[j].Type+sx[j].Brand
+		if a != b {
+			return a < b
+		}
+		return sx[i].Currency < sx[j].Currency
+	})
+}
+
+// ProductStats returns statistics of products in the store.
+func (ms *MemoryStore) ProductStats() (ProductStats, error) {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	px := make([]Product, 0, len(ms.Products))
+	for _, p := range ms.Products {
+		px = append(px, p)
+	}
+	return productStats(px), nil
+}
+
+// storeStats returns statistics of products in the store. Stats
+// of stores which aren't StatsAggregators are computed from all
+// their products.
+func storeStats(s Store) (ProductStats, error) {
+	if a, ok := s.(StatsAggregator); ok {
+		return a.ProductStats()
+	}
+	return productStats(s.GetAll()), nil
+}
+
+// GetProductStats returns counts and minimum, average and maximum
+// prices of products grouped by type and by brand. Prices are
+// in the currency of products, regardless of the currency
+// requested by the client.
+func (cs *Server) GetProductStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := storeStats(cs.store(r))
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, stats)
+}
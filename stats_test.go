@@ -0,0 +1,83 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+var statsProducts = coffeeshop.Products{
+	"1": {ID: "1", Type: "Coffee", Brand: "illy", Price: coffeeshop.Money{Amount: 700, Currency: "EUR"}},
+	"2": {ID: "2", Type: "coffee", Brand: "Lavazza", Price: coffeeshop.Money{Amount: 501, Currency: "EUR"}},
+	"3": {ID: "3", Type: "Tea", Brand: "illy", Price: coffeeshop.Money{Amount: 300, Currency: "EUR"}},
+	"4": {ID: "4", Type: "Tea", Brand: "illy", Price: coffeeshop.Money{Amount: 450, Currency: "USD"}},
+	"5": {ID: "5", Type: "Tea", Brand: "illy", Price: coffeeshop.Money{Amount: 900, Currency: "EUR"}, Archived: true},
+}
+
+func eur(amount int64) coffeeshop.Money {
+	return coffeeshop.Money{Amount: amount, Currency: "EUR"}
+}
+
+func usd(amount int64) coffeeshop.Money {
+	return coffeeshop.Money{Amount: amount, Currency: "USD"}
+}
+
+func TestMemoryStore_ComputesProductStats(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: statsProducts}
+	got, err := store.ProductStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := coffeeshop.ProductStats{
+		Count: 4,
+		ByType: []coffeeshop.PriceStats{
+			{Type: "coffee", Currency: "EUR", Count: 2, MinPrice: eur(501), AvgPrice: eur(601), MaxPrice: eur(700)},
+			{Type: "tea", Currency: "EUR", Count: 1, MinPrice: eur(300), AvgPrice: eur(300), MaxPrice: eur(300)},
+			{Type: "tea", Currency: "USD", Count: 1, MinPrice: usd(450), AvgPrice: usd(450), MaxPrice: usd(450)},
+		},
+		ByBrand: []coffeeshop.PriceStats{
+			{Brand: "Lavazza", Currency: "EUR", Count: 1, MinPrice: eur(501), AvgPrice: eur(501), MaxPrice: eur(501)},
+			{Brand: "illy", Currency: "EUR", Count: 2, MinPrice: eur(300), AvgPrice: eur(500), MaxPrice: eur(700)},
+			{Brand: "illy", Currency: "USD", Count: 1, MinPrice: usd(450), AvgPrice: usd(450), MaxPrice: usd(450)},
+		},
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestServer_GetsProductStats(t *testing.T) {
+	t.Parallel()
+
+	for name, store := range map[string]coffeeshop.Store{
+		"aggregator":  &coffeeshop.MemoryStore{Products: statsProducts},
+		"plain store": plainStore{&coffeeshop.MemoryStore{Products: statsProducts}},
+	} {
+		store := store
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			shop := newCoffeShopTestServer(store, "0s", t)
+			resp, err := http.Get(shop.URL + "products/stats")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("want HTTP 200, got %d", resp.StatusCode)
+			}
+			var stats coffeeshop.ProductStats
+			if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+				t.Fatal(err)
+			}
+			if stats.Count != 4 || len(stats.ByType) != 3 || len(stats.ByBrand) != 3 {
+				t.Errorf("want stats of 4 products in 3 groups by type and brand, got %+v", stats)
+			}
+		})
+	}
+}
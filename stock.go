@@ -0,0 +1,193 @@
+package coffeeshop
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// OutOfStockError is returned when an order requests more
+// items of the product than are available in the stock.
+type OutOfStockError struct {
+	ProductID string `json:"productId"`
+	Requested int    `json:"requested"`
+	Available int    `json:"available"`
+}
+
+func (e *OutOfStockError) Error() string {
+	return fmt.Sprintf("product %s out of stock: requested %d, available %d", e.ProductID, e.Requested, e.Available)
+}
+
+// Is reports whether the target is ErrOutOfStock.
+func (e *OutOfStockError) Is(target error) bool {
+	return target == ErrOutOfStock
+}
+
+// ReserveStock decrements stock of all ordered products.
+// If any of the products doesn't have enough stock the store
+// is left unchanged and *OutOfStockError is returned.
+func (ms *MemoryStore) ReserveStock(items []OrderItem) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	requested := make(map[string]int)
+	for _, item := range items {
+		requested[item.ProductID] += item.Quantity
+	}
+	for id, quantity := range requested {
+		p, ok := ms.Products[id]
+		if !ok {
+			return ErrProductNotFound
+		}
+		if p.Stock < quantity {
+			return &OutOfStockError{ProductID: id, Requested: quantity, Available: p.Stock}
+		}
+	}
+	modifiedAt := now(ms.Clock)
+	for id, quantity := range requested {
+		p := ms.Products[id]
+		p.Stock -= quantity
+		p.Version++
+		p.UpdatedAt = &modifiedAt
+		ms.Products[id] = p
+		ms.recordChange(id, false)
+		ms.publishChange(p)
+	}
+	return nil
+}
+
+// StockReleaser is implemented by stores able to give back stock
+// reserved for orders that weren't placed, like the MemoryStore.
+// Wrappers like the CachingStore forward it.
+type StockReleaser interface {
+	// ReleaseStock increments stock of all ordered products.
+	ReleaseStock(items []OrderItem) error
+}
+
+// ReleaseStock increments stock of all ordered products, giving
+// back stock reserved with ReserveStock. Products deleted since
+// the reservation are skipped.
+func (ms *MemoryStore) ReleaseStock(items []OrderItem) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	released := make(map[string]int)
+	for _, item := range items {
+		released[item.ProductID] += item.Quantity
+	}
+	modifiedAt := now(ms.Clock)
+	for id, quantity := range released {
+		p, ok := ms.Products[id]
+		if !ok {
+			continue
+		}
+		p.Stock += quantity
+		p.Version++
+		p.UpdatedAt = &modifiedAt
+		ms.Products[id] = p
+		ms.recordChange(id, false)
+		ms.publishChange(p)
+	}
+	return nil
+}
+
+// releaseStock gives back stock reserved for the items of an order
+// that wasn't placed, if the store is a StockReleaser. The stock is
+// released without the deadline of the request, which may be over.
+func (cs *Server) releaseStock(items []OrderItem) {
+	sr, ok := storeAs[StockReleaser](cs.Store)
+	if !ok {
+		cs.logf("store %T doesn't support releasing stock of %d items", cs.Store, len(items))
+		return
+	}
+	if err := sr.ReleaseStock(items); err != nil {
+		cs.logf("can't release stock: %v", err)
+	}
+}
+
+// SetStock sets the number of items of the product available in stock.
+func (ms *MemoryStore) SetStock(id string, stock int) (Product, error) {
+	if stock < 0 {
+		return Product{}, fmt.Errorf("%w: negative stock", ErrInvalidProduct)
+	}
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	p, ok := ms.Products[id]
+	if !ok {
+		return Product{}, ErrProductNotFound
+	}
+	p.Stock = stock
+	p.Version++
+	modifiedAt := now(ms.Clock)
+	p.UpdatedAt = &modifiedAt
+	ms.Products[id] = p
+	ms.recordChange(id, false)
+	ms.publishChange(p)
+	return p.clone(), nil
+}
+
+// writeOutOfStock responds with 409 Conflict describing
+// which product is out of stock.
+func writeOutOfStock(w http.ResponseWriter, r *http.Request, e *OutOfStockError) {
+	body := struct {
+		Error struct {
+			ErrorDetail
+			*OutOfStockError
+		} `json:"error"`
+	}{}
+	body.Error.ErrorDetail = newErrorDetail(r, "out_of_stock", ErrOutOfStock.Error())
+	body.Error.OutOfStockError = e
+	writeJSON(w, r, http.StatusConflict, body)
+}
+
+func (cs *Server) RestockProduct(w http.ResponseWriter, r *http.Request) {
+	productID := chi.URLParam(r, "productID")
+	var req struct {
+		Stock *int `json:"stock"`
+	}
+	if !cs.decodeJSON(w, r, &req, "invalid stock") {
+		return
+	}
+	if req.Stock == nil || *req.Stock < 0 {
+		writeFieldErrors(w, r, "invalid stock", []FieldError{{Field: "stock", Message: "must be a non-negative number"}})
+		return
+	}
+	version, ok := cs.ifMatch(w, r, productID)
+	if !ok {
+		return
+	}
+	old, product, err := cs.replaceProduct(r, productID, func(old Product, exists bool) (Product, error) {
+		if !exists {
+			return Product{}, ErrProductNotFound
+		}
+		if version != 0 && version != old.Version {
+			return Product{}, fmt.Errorf("%w: product %s is at version %d", ErrVersionMismatch, productID, old.Version)
+		}
+		old.Stock = *req.Stock
+		return old, nil
+	}, func(p Product) (Product, error) {
+		return setStock(cs.store(r), productID, p.Stock, version)
+	})
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	cs.audit(r, AuditUpdate, old, product)
+	writeJSON(w, r, http.StatusOK, cs.productView(r, product))
+}
+
+// setStock sets the stock of the product in the store. If the version
+// isn't zero and the store supports updates, the stock is set only if
+// the product is still at the version.
+func setStock(s Store, id string, stock, version int) (Product, error) {
+	pw, ok := storeAs[ProductWriter](s)
+	if version == 0 || !ok {
+		return s.SetStock(id, stock)
+	}
+	p, err := s.GetProduct(id)
+	if err != nil {
+		return Product{}, err
+	}
+	p.Stock = stock
+	p.Version = version
+	return pw.Update(p)
+}
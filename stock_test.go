@@ -0,0 +1,143 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+// stockedInventory returns a copy of the test inventory with
+// every product stocked with the given number of items.
+func stockedInventory(stock int) coffeeshop.Products {
+	px := coffeeshop.Products{}
+	for id, p := range inventory {
+		p.Stock = stock
+		px[id] = p
+	}
+	return px
+}
+
+func TestReserveStock_DecrementsStockOfOrderedProducts(t *testing.T) {
+	t.Parallel()
+
+	store := coffeeshop.MemoryStore{
+		Products: stockedInventory(5),
+	}
+
+	err := store.ReserveStock([]coffeeshop.OrderItem{
+		{ProductID: "1", Quantity: 2},
+		{ProductID: "7", Quantity: 5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := store.GetProduct("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Stock != 3 {
+		t.Errorf("want stock 3, got %d", p.Stock)
+	}
+	p, err = store.GetProduct("7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Stock != 0 {
+		t.Errorf("want stock 0, got %d", p.Stock)
+	}
+}
+
+func TestReserveStock_LeavesStockUnchangedWhenAnyProductIsOutOfStock(t *testing.T) {
+	t.Parallel()
+
+	store := coffeeshop.MemoryStore{
+		Products: stockedInventory(5),
+	}
+
+	err := store.ReserveStock([]coffeeshop.OrderItem{
+		{ProductID: "1", Quantity: 2},
+		{ProductID: "7", Quantity: 6},
+	})
+	want := &coffeeshop.OutOfStockError{ProductID: "7", Requested: 6, Available: 5}
+	if !cmp.Equal(want, err) {
+		t.Fatal(cmp.Diff(want, err))
+	}
+
+	p, err := store.GetProduct("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Stock != 5 {
+		t.Errorf("want stock 5, got %d", p.Stock)
+	}
+}
+
+func TestServer_Returns409OnOrderExceedingStock(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(1),
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp := createOrder(t, shop.URL, `{"items":[{"productId":"3","quantity":2}]}`)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("want HTTP 409, got %d", resp.StatusCode)
+	}
+	var body struct {
+		Error coffeeshop.OutOfStockError `json:"error"`
+	}
+	err := json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := body.Error
+	want := coffeeshop.OutOfStockError{ProductID: "3", Requested: 2, Available: 1}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestServer_RestocksProduct(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(0),
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	req, err := http.NewRequest(http.MethodPut, shop.URL+"products/5/stock", strings.NewReader(`{"stock":12}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200OK, got %d", resp.StatusCode)
+	}
+	var got coffeeshop.Product
+	err = json.NewDecoder(resp.Body).Decode(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Stock != 12 {
+		t.Errorf("want stock 12, got %d", got.Stock)
+	}
+
+	order := createOrder(t, shop.URL, `{"items":[{"productId":"5","quantity":12}]}`)
+	defer order.Body.Close()
+	if order.StatusCode != http.StatusCreated {
+		t.Errorf("want HTTP 201 after restock, got %d", order.StatusCode)
+	}
+}
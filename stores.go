@@ -0,0 +1,181 @@
+package coffeeshop
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/exp/maps"
+)
+
+// StoreFactory opens the store described by the URL.
+type StoreFactory func(u *url.URL) (Store, error)
+
+var (
+	storesMx sync.RWMutex
+	stores   = make(map[string]StoreFactory)
+)
+
+func init() {
+	RegisterStore("memory", openMemoryStore)
+	RegisterStore("redis", openRedisStore)
+	RegisterStore("mongodb", openMongoStore)
+}
+
+// RegisterStore makes the store factory available to OpenStore
+// for URLs with the given scheme. It panics if the factory is nil
+// or a factory for the scheme is already registered.
+func RegisterStore(scheme string, factory StoreFactory) {
+	storesMx.Lock()
+	defer storesMx.Unlock()
+	if factory == nil {
+		panic("coffeeshop: RegisterStore factory is nil")
+	}
+	scheme = strings.ToLower(scheme)
+	if _, dup := stores[scheme]; dup {
+		panic("coffeeshop: RegisterStore called twice for scheme " + scheme)
+	}
+	stores[scheme] = factory
+}
+
+// OpenStore opens the store described by the URL, for example
+// "memory://", "redis://localhost:6379/0" or
+// "mongodb://localhost:27017/coffeeshop". The URL scheme selects
+// the factory registered with RegisterStore.
+func OpenStore(dsn string) (Store, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid store URL: %w", err)
+	}
+	storesMx.RLock()
+	factory, ok := stores[strings.ToLower(u.Scheme)]
+	storesMx.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown store %q", u.Scheme)
+	}
+	return factory(u)
+}
+
+// WithStore configures the storage used for products
+// instead of the store passed to New.
+func WithStore(store Store) Option {
+	return func(s *Server) error {
+		if store == nil {
+			return errors.New("nil store")
+		}
+		s.Store = store
+		return nil
+	}
+}
+
+// StoreWrapper is implemented by stores decorating another store, like
+// the CachingStore. Optional interfaces of stores, like ProductWriter,
+// are looked up through wrappers, so wrapping a store doesn't hide
+// what the store can do.
+type StoreWrapper interface {
+	// Unwrap returns the wrapped store.
+	Unwrap() Store
+}
+
+// storeAs returns the outermost store in the chain of wrappers
+// implementing T, like errors.As finds wrapped errors. Wrappers
+// implement optional interfaces only to decorate the store they
+// wrap, so T is found only if the innermost store implements it.
+func storeAs[T any](s Store) (T, bool) {
+	var zero T
+	w, ok := s.(StoreWrapper)
+	if !ok {
+		t, ok := s.(T)
+		return t, ok
+	}
+	inner, ok := storeAs[T](w.Unwrap())
+	if !ok {
+		return zero, false
+	}
+	if t, ok := s.(T); ok {
+		return t, true
+	}
+	return inner, true
+}
+
+// Lister is implemented by stores whose listings can fail, like
+// stores of network backends. GetAll and GetByType of such stores
+// return no products when the backend fails, which looks like an
+// empty catalog, so listings are read with ListAll and ListByType
+// when the store implements them.
+type Lister interface {
+	// ListAll returns all products sorted by ID.
+	ListAll() ([]Product, error)
+	// ListByType returns products of the type sorted by ID.
+	ListByType(productType string) ([]Product, error)
+}
+
+// listAll returns all products of the store, and the error
+// of the backend if the store is a Lister.
+func listAll(s Store) ([]Product, error) {
+	if l, ok := s.(Lister); ok {
+		return l.ListAll()
+	}
+	return s.GetAll(), nil
+}
+
+// listByType returns products of the type in the store, and
+// the error of the backend if the store is a Lister.
+func listByType(s Store, productType string) ([]Product, error) {
+	if l, ok := s.(Lister); ok {
+		return l.ListByType(productType)
+	}
+	return s.GetByType(productType), nil
+}
+
+// openMemoryStore opens the memory store with sample products.
+func openMemoryStore(u *url.URL) (Store, error) {
+	return &MemoryStore{Products: maps.Clone(inventory)}, nil
+}
+
+// openRedisStore opens the store using URLs in the
+// "redis://[:password@]host:port[/db][?prefix=p&ttl=1h]" format.
+func openRedisStore(u *url.URL) (Store, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid Redis host %q", u.Host)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = u.Host + ":6379"
+	}
+	opts := &redis.Options{Addr: addr}
+	if u.User != nil {
+		opts.Password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		n, err := strconv.Atoi(db)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid Redis database %q", db)
+		}
+		opts.DB = n
+	}
+	q := u.Query()
+	var ttl time.Duration
+	if s := q.Get("ttl"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid Redis TTL %q", s)
+		}
+		ttl = d
+	}
+	rs := redisStoreWith(opts)
+	if q.Has("prefix") {
+		rs.Prefix = q.Get("prefix")
+	}
+	rs.TTL = ttl
+	return rs, nil
+}
+
+func openMongoStore(u *url.URL) (Store, error) {
+	return NewMongoStore(u.String())
+}
@@ -0,0 +1,114 @@
+package coffeeshop_test
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/qba73/coffeeshop"
+)
+
+func TestOpenStore_OpensMemoryStoreWithSampleProducts(t *testing.T) {
+	t.Parallel()
+
+	store, err := coffeeshop.OpenStore("memory://")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.(*coffeeshop.MemoryStore); !ok {
+		t.Fatalf("want *MemoryStore, got %T", store)
+	}
+	if got := len(store.GetAll()); got != len(inventory) {
+		t.Errorf("want %d products, got %d", len(inventory), got)
+	}
+}
+
+func TestOpenStore_ConfiguresRedisStoreFromURL(t *testing.T) {
+	t.Parallel()
+
+	_, addr := newFakeRedis(t)
+	store, err := coffeeshop.OpenStore("redis://:secret@" + addr + "/2?prefix=shop:&ttl=1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, ok := store.(*coffeeshop.RedisStore)
+	if !ok {
+		t.Fatalf("want *RedisStore, got %T", store)
+	}
+	t.Cleanup(func() { rs.Close() })
+	opts := rs.Client.Options()
+	if opts.Addr != addr || opts.Password != "secret" || opts.DB != 2 || rs.Prefix != "shop:" || rs.TTL != time.Hour {
+		t.Errorf("unexpected store configuration %+v with client options %+v", rs, opts)
+	}
+	if _, err := rs.PutProduct(inventory["1"]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rs.GetProduct("1"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOpenStore_OpensMongoStore(t *testing.T) {
+	t.Parallel()
+
+	store, err := coffeeshop.OpenStore("mongodb://localhost/shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms, ok := store.(*coffeeshop.MongoStore)
+	if !ok {
+		t.Fatalf("want *MongoStore, got %T", store)
+	}
+	if ms.Database != "shop" {
+		t.Errorf("want database shop, got %s", ms.Database)
+	}
+}
+
+func TestOpenStore_FailsOnInvalidURL(t *testing.T) {
+	t.Parallel()
+
+	for _, dsn := range []string{
+		"postgres://localhost/shop",
+		"redis://localhost/db",
+		"redis://localhost?ttl=forever",
+		"redis:///0",
+		"::",
+	} {
+		if _, err := coffeeshop.OpenStore(dsn); err == nil {
+			t.Errorf("want error for %q", dsn)
+		}
+	}
+}
+
+func TestRegisterStore_MakesStoreAvailableToOpenStore(t *testing.T) {
+	t.Parallel()
+
+	want := &coffeeshop.MemoryStore{Products: inventory}
+	coffeeshop.RegisterStore("test-registered", func(u *url.URL) (coffeeshop.Store, error) {
+		if u.Host != "shop" {
+			return nil, errors.New("unexpected host")
+		}
+		return want, nil
+	})
+	got, err := coffeeshop.OpenStore("test-registered://shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("want store returned by the factory, got %v", got)
+	}
+}
+
+func TestRegisterStore_PanicsOnDuplicateScheme(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("want panic on duplicate scheme")
+		}
+	}()
+	coffeeshop.RegisterStore("memory", func(u *url.URL) (coffeeshop.Store, error) {
+		return nil, nil
+	})
+}
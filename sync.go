@@ -0,0 +1,192 @@
+package coffeeshop
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Tombstone records the deletion or archiving of a product.
+type Tombstone struct {
+	ID        string    `json:"id"`
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+// SyncDelta holds changes of products since a sync cursor.
+type SyncDelta struct {
+	// Cursor identifies the state of the store after the changes.
+	// Clients pass it to get the next changes.
+	Cursor  string      `json:"cursor"`
+	Created []Product   `json:"created"`
+	Updated []Product   `json:"updated"`
+	Deleted []Tombstone `json:"deleted"`
+}
+
+// Syncer is implemented by stores recording changes of products,
+// so clients can keep replicas of products in sync.
+type Syncer interface {
+	// Changes returns products created, updated and deleted since
+	// the cursor returned by an earlier call, or all products for
+	// the empty cursor. It fails with ErrCursorExpired if changes
+	// since the cursor aren't known.
+	Changes(cursor string) (SyncDelta, error)
+}
+
+// productChange records the last change of a product.
+// Changes are numbered in the order they were made.
+type productChange struct {
+	created, changed uint64
+	// deletedAt is zero unless the product was deleted.
+	deletedAt time.Time
+}
+
+// DefaultMaxTombstones is the number of tombstones of deleted
+// products the MemoryStore keeps unless MaxTombstones is set.
+const DefaultMaxTombstones = 1000
+
+// deletion records the change deleting the product.
+type deletion struct {
+	id  string
+	seq uint64
+}
+
+// recordChange records the change of the product. It must be
+// called with the store lock held.
+func (ms *MemoryStore) recordChange(id string, added bool) {
+	if ms.changes == nil {
+		ms.changes = make(map[string]productChange)
+	}
+	ms.seq++
+	c := ms.changes[id]
+	if added {
+		c.created = ms.seq
+	}
+	c.changed = ms.seq
+	c.deletedAt = time.Time{}
+	ms.changes[id] = c
+}
+
+// recordDeletion records the deletion of the product at the time.
+// It must be called with the store lock held.
+func (ms *MemoryStore) recordDeletion(id string, at time.Time) {
+	ms.recordChange(id, false)
+	c := ms.changes[id]
+	c.deletedAt = at
+	ms.changes[id] = c
+	ms.deletions = append(ms.deletions, deletion{id: id, seq: ms.seq})
+	ms.compactTombstones()
+}
+
+// compactTombstones forgets the oldest tombstones over the limit.
+// Cursors issued before the forgotten deletions are expired, so
+// clients holding them sync all products again. It must be called
+// with the store lock held.
+func (ms *MemoryStore) compactTombstones() {
+	limit := ms.MaxTombstones
+	if limit <= 0 {
+		limit = DefaultMaxTombstones
+	}
+	for len(ms.deletions) > limit {
+		d := ms.deletions[0]
+		ms.deletions = ms.deletions[1:]
+		// Products created again since their deletion
+		// have no tombstone to forget.
+		if c, ok := ms.changes[d.id]; ok && c.changed == d.seq {
+			delete(ms.changes, d.id)
+			ms.syncFrom = d.seq
+		}
+	}
+}
+
+// expireCursors forgets changes of products replaced all at once,
+// so clients holding older cursors sync all products again. It must
+// be called with the store lock held.
+func (ms *MemoryStore) expireCursors() {
+	ms.seq++
+	ms.syncFrom = ms.seq
+	ms.changes = nil
+	ms.deletions = nil
+}
+
+// Changes returns products created, updated and deleted since the
+// cursor, sorted by ID. Archived products are deleted, as they are
+// hidden from listings, and restored products are updated. Only
+// changes made with methods of the store are known; cursors issued
+// before products were replaced all at once, for example by
+// Server.Reset, or before the deletion of a forgotten tombstone
+// are expired.
+func (ms *MemoryStore) Changes(cursor string) (SyncDelta, error) {
+	var since uint64
+	if cursor != "" {
+		var err error
+		since, err = strconv.ParseUint(cursor, 10, 64)
+		if err != nil {
+			return SyncDelta{}, fmt.Errorf("%w %q", ErrInvalidCursor, cursor)
+		}
+	}
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	full := cursor == ""
+	if !full && (since > ms.seq || since < ms.syncFrom) {
+		return SyncDelta{}, fmt.Errorf("%w: %s", ErrCursorExpired, cursor)
+	}
+	delta := SyncDelta{
+		Cursor:  strconv.FormatUint(ms.seq, 10),
+		Created: []Product{},
+		Updated: []Product{},
+		Deleted: []Tombstone{},
+	}
+	if full {
+		for _, p := range ms.Products {
+			if !p.Archived {
+				delta.Created = append(delta.Created, p.clone())
+			}
+		}
+		sortProducts(delta.Created)
+		return delta, nil
+	}
+	for id, c := range ms.changes {
+		if c.changed <= since {
+			continue
+		}
+		p, exists := ms.Products[id]
+		switch {
+		case exists && !p.Archived && c.created > since:
+			delta.Created = append(delta.Created, p.clone())
+		case exists && !p.Archived:
+			delta.Updated = append(delta.Updated, p.clone())
+		case c.created > since:
+			// Products created and deleted since the
+			// cursor were never seen by the client.
+		case exists:
+			delta.Deleted = append(delta.Deleted, Tombstone{ID: id, DeletedAt: timeOf(p.UpdatedAt)})
+		default:
+			delta.Deleted = append(delta.Deleted, Tombstone{ID: id, DeletedAt: c.deletedAt})
+		}
+	}
+	sortProducts(delta.Created)
+	sortProducts(delta.Updated)
+	sort.Slice(delta.Deleted, func(i, j int) bool { return lessID(delta.Deleted[i].ID, delta.Deleted[j].ID) })
+	return delta, nil
+}
+
+// GetSync returns products created, updated and deleted since the
+// cursor in the "since" query parameter, and the cursor to pass in
+// the next request. Without the parameter all products are returned
+// as created. Archived products are returned as deleted. Clients
+// holding expired cursors get 410 Gone and sync all products again.
+func (cs *Server) GetSync(w http.ResponseWriter, r *http.Request) {
+	s, ok := storeAs[Syncer](cs.store(r))
+	if !ok {
+		writeError(w, r, http.StatusNotImplemented, "store doesn't support sync")
+		return
+	}
+	delta, err := s.Changes(r.URL.Query().Get("since"))
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, delta)
+}
@@ -0,0 +1,187 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+func getSync(t *testing.T, url string) coffeeshop.SyncDelta {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200, got %d", resp.StatusCode)
+	}
+	var delta coffeeshop.SyncDelta
+	if err := json.NewDecoder(resp.Body).Decode(&delta); err != nil {
+		t.Fatal(err)
+	}
+	return delta
+}
+
+func productIDs(px []coffeeshop.Product) []string {
+	ids := []string{}
+	for _, p := range px {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+func TestServer_SyncsChangesSinceTheCursor(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: stockedInventory(5)}
+	shop := newCoffeShopTestServer(store, "0s", t, coffeeshop.WithIDGenerator(&coffeeshop.SequentialIDs{}))
+	full := getSync(t, shop.URL+"sync")
+	if len(full.Created) != 8 || len(full.Updated) != 0 || len(full.Deleted) != 0 {
+		t.Fatalf("want all 8 products created, got %+v", full)
+	}
+
+	for _, body := range []string{`{"type": "Tea", "name": "Green"}`, `{"type": "Tea", "name": "Sencha"}`} {
+		if status, _ := createProduct(t, shop.URL+"products", body); status != http.StatusCreated {
+			t.Fatalf("want HTTP 201, got %d", status)
+		}
+	}
+	resp := sendAs(t, "", http.MethodPut, shop.URL+"products/2/stock", `{"stock": 1}`)
+	resp.Body.Close()
+	for _, id := range []string{"3", "10"} {
+		resp := send(t, http.MethodDelete, shop.URL+"products/"+id)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("want HTTP 204, got %d", resp.StatusCode)
+		}
+	}
+
+	delta := getSync(t, shop.URL+"sync?since="+full.Cursor)
+	if want := []string{"9"}; !cmp.Equal(want, productIDs(delta.Created)) {
+		t.Error(cmp.Diff(want, productIDs(delta.Created)))
+	}
+	if want := []string{"2"}; !cmp.Equal(want, productIDs(delta.Updated)) {
+		t.Error(cmp.Diff(want, productIDs(delta.Updated)))
+	}
+	if len(delta.Deleted) != 1 || delta.Deleted[0].ID != "3" || delta.Deleted[0].DeletedAt.IsZero() {
+		t.Errorf("want tombstone of product 3, got %+v", delta.Deleted)
+	}
+
+	empty := getSync(t, shop.URL+"sync?since="+delta.Cursor)
+	if len(empty.Created)+len(empty.Updated)+len(empty.Deleted) != 0 || empty.Cursor != delta.Cursor {
+		t.Errorf("want no changes since the last cursor, got %+v", empty)
+	}
+}
+
+func TestServer_RejectsInvalidAndExpiredSyncCursors(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(5)}, "0s", t)
+	resp := sendAs(t, "", http.MethodPut, shop.URL+"products/2/stock", `{"stock": 1}`)
+	resp.Body.Close()
+	cursor := getSync(t, shop.URL+"sync").Cursor
+	shop.Reset()
+
+	for query, want := range map[string]int{
+		"since=abc":       http.StatusBadRequest,
+		"since=999":       http.StatusGone,
+		"since=" + cursor: http.StatusGone,
+	} {
+		status, _ := getResponse(t, shop.URL+"sync?"+query)
+		if status != want {
+			t.Errorf("%s: want HTTP %d, got %d", query, want, status)
+		}
+	}
+}
+
+func TestServer_RespondsNotImplementedToSyncWithoutSyncer(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(plainStore{&coffeeshop.MemoryStore{Products: stockedInventory(5)}}, "0s", t)
+	status, _ := getResponse(t, shop.URL+"sync")
+	if status != http.StatusNotImplemented {
+		t.Errorf("want HTTP 501, got %d", status)
+	}
+}
+
+func TestMemoryStore_RecordsTombstonesOfDeletedProducts(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: stockedInventory(5), Clock: coffeeshop.NewFakeClock(syncStart)}
+	full, err := store.Changes("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete("1"); err != nil {
+		t.Fatal(err)
+	}
+	delta, err := store.Changes(full.Cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []coffeeshop.Tombstone{{ID: "1", DeletedAt: syncStart}}
+	if !cmp.Equal(want, delta.Deleted) {
+		t.Error(cmp.Diff(want, delta.Deleted))
+	}
+}
+
+func TestMemoryStore_ForgetsOldestTombstonesOverTheLimit(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: stockedInventory(5), MaxTombstones: 2}
+	var cursors []string
+	for _, id := range []string{"1", "2", "3"} {
+		delta, err := store.Changes("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cursors = append(cursors, delta.Cursor)
+		if err := store.Delete(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.Changes(cursors[0]); !errors.Is(err, coffeeshop.ErrCursorExpired) {
+		t.Errorf("want ErrCursorExpired for the cursor older than forgotten tombstones, got %v", err)
+	}
+	delta, err := store.Changes(cursors[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, ts := range delta.Deleted {
+		got = append(got, ts.ID)
+	}
+	if want := []string{"2", "3"}; !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestServer_SyncsChangesThroughStoreWrappers(t *testing.T) {
+	t.Parallel()
+
+	for name, wrap := range map[string]func(coffeeshop.Store) coffeeshop.Store{
+		"caching":  func(s coffeeshop.Store) coffeeshop.Store { return coffeeshop.NewCachingStore(s, time.Minute) },
+		"retrying": func(s coffeeshop.Store) coffeeshop.Store { return coffeeshop.NewRetryingStore(s, 2, 0, 0) },
+		"breaker":  func(s coffeeshop.Store) coffeeshop.Store { return coffeeshop.NewBreakerStore(s, 2, time.Second) },
+		"tiered":   func(s coffeeshop.Store) coffeeshop.Store { return coffeeshop.NewTieredStore(s, nil) },
+	} {
+		store := wrap(&coffeeshop.MemoryStore{Products: stockedInventory(5)})
+		if _, ok := store.(coffeeshop.Syncer); !ok {
+			t.Errorf("%s: want Syncer, got %T", name, store)
+			continue
+		}
+		shop := newCoffeShopTestServer(store, "0s", t)
+		cursor := getSync(t, shop.URL+"sync").Cursor
+		resp := send(t, http.MethodDelete, shop.URL+"products/1")
+		resp.Body.Close()
+		delta := getSync(t, shop.URL+"sync?since="+cursor)
+		if len(delta.Deleted) != 1 || delta.Deleted[0].ID != "1" {
+			t.Errorf("%s: want tombstone of product 1, got %+v", name, delta.Deleted)
+		}
+	}
+}
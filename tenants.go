@@ -0,0 +1,321 @@
+package coffeeshop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"golang.org/x/exp/maps"
+)
+
+// tenantHeader selects the tenant serving an API request.
+const tenantHeader = "X-Tenant-ID"
+
+// Tenant is an isolated coffeeshop served by the server, with its own
+// catalog, orders, carts and customers, and its own latency, faults
+// and scenario. Requests reach the tenant with the X-Tenant-ID header or
+// under the /tenants/{id}/ path prefix, for example
+// /tenants/suite-a/products.
+type Tenant struct {
+	ID string `json:"id"`
+	// URL is the base URL of the API of the tenant.
+	URL string `json:"url"`
+	// Latency overrides the latency of the server, for example "50ms".
+	Latency string `json:"latency,omitempty"`
+	// FaultRate is the fraction of API requests of the tenant
+	// answered with FaultStatus instead of being served.
+	FaultRate float64 `json:"faultRate,omitempty"`
+	// FaultStatus is the error status of faults, by default
+	// 503 Service Unavailable.
+	FaultStatus int `json:"faultStatus,omitempty"`
+	// Scenario makes API requests of the tenant follow its steps.
+	Scenario *Scenario `json:"scenario,omitempty"`
+	// Products replace the sample products the tenant starts with.
+	// They are not returned when the tenant is read.
+	Products []Product `json:"products,omitempty"`
+}
+
+// WithTenants enables tenants provisioned with POST /admin/tenants.
+// Servers of tenants are configured with the latency, the admin token
+// and the policy of the server and the options, followed by settings
+// of the tenant.
+func WithTenants(opts ...Option) Option {
+	return func(s *Server) error {
+		for _, opt := range opts {
+			if opt == nil {
+				return errors.New("nil tenant option")
+			}
+		}
+		s.Tenants = true
+		s.TenantOptions = opts
+		return nil
+	}
+}
+
+// validTenantID reports whether the ID can be used in URL paths.
+func validTenantID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// tenantServer is a provisioned tenant and the server serving it.
+type tenantServer struct {
+	tenant Tenant
+	server *Server
+}
+
+// tenantRegistry holds provisioned tenants by ID.
+type tenantRegistry struct {
+	mx      sync.Mutex
+	tenants map[string]*tenantServer
+}
+
+func (tr *tenantRegistry) add(ts *tenantServer) error {
+	tr.mx.Lock()
+	defer tr.mx.Unlock()
+	if _, ok := tr.tenants[ts.tenant.ID]; ok {
+		return fmt.Errorf("%w: %s", ErrTenantExists, ts.tenant.ID)
+	}
+	if tr.tenants == nil {
+		tr.tenants = make(map[string]*tenantServer)
+	}
+	tr.tenants[ts.tenant.ID] = ts
+	return nil
+}
+
+func (tr *tenantRegistry) get(id string) (*tenantServer, error) {
+	tr.mx.Lock()
+	defer tr.mx.Unlock()
+	ts, ok := tr.tenants[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTenantNotFound, id)
+	}
+	return ts, nil
+}
+
+// list returns tenants sorted by ID.
+func (tr *tenantRegistry) list() []Tenant {
+	tr.mx.Lock()
+	defer tr.mx.Unlock()
+	tenants := make([]Tenant, 0, len(tr.tenants))
+	for _, ts := range tr.tenants {
+		tenants = append(tenants, ts.tenant)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+	return tenants
+}
+
+func (tr *tenantRegistry) remove(id string) (*tenantServer, error) {
+	tr.mx.Lock()
+	defer tr.mx.Unlock()
+	ts, ok := tr.tenants[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTenantNotFound, id)
+	}
+	delete(tr.tenants, id)
+	return ts, nil
+}
+
+// shutdown stops background work of servers of all tenants.
+func (tr *tenantRegistry) shutdown(ctx context.Context) error {
+	tr.mx.Lock()
+	servers := make([]*Server, 0, len(tr.tenants))
+	for _, ts := range tr.tenants {
+		servers = append(servers, ts.server)
+	}
+	tr.mx.Unlock()
+	var errs []error
+	for _, s := range servers {
+		errs = append(errs, s.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// newTenant creates the server of the tenant.
+func (cs *Server) newTenant(t Tenant) (*tenantServer, error) {
+	products := maps.Clone(inventory)
+	if t.Products != nil {
+		products = make(Products, len(t.Products))
+		for _, p := range t.Products {
+			if err := p.Validate(); err != nil {
+				return nil, err
+			}
+			products[p.ID] = p
+		}
+	}
+	opts := []Option{WithLatency(cs.currentLatency().String()), WithPolicy(cs.Policy)}
+	if cs.AdminToken != "" {
+		opts = append(opts, WithAdminToken(cs.AdminToken))
+	}
+	opts = append(opts, cs.TenantOptions...)
+	if t.Latency != "" {
+		opts = append(opts, WithLatency(t.Latency))
+	}
+	if t.FaultRate != 0 || t.FaultStatus != 0 {
+		status := t.FaultStatus
+		if status == 0 {
+			status = http.StatusServiceUnavailable
+		}
+		opts = append(opts, WithFaults(t.FaultRate, status))
+	}
+	if t.Scenario != nil {
+		opts = append(opts, WithScenario(*t.Scenario))
+	}
+	s, err := New(cs.HTTPServer.Addr, &MemoryStore{Products: products}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	t.URL = cs.URL + "tenants/" + t.ID + "/"
+	t.Products = nil
+	s.URL = t.URL
+	return &tenantServer{tenant: t, server: s}, nil
+}
+
+// tenantPath splits the path under the /tenants/{id}/ prefix
+// into the tenant ID and the path served by the tenant.
+func tenantPath(path string) (id, rest string, ok bool) {
+	path, ok = strings.CutPrefix(path, "/tenants/")
+	if !ok {
+		return "", "", false
+	}
+	id, rest, _ = strings.Cut(path, "/")
+	return id, "/" + rest, id != ""
+}
+
+// tenantPrefixKey is the context key of the path prefix
+// under which the request reached its tenant.
+type tenantPrefixKey struct{}
+
+// resourcePath returns the path of the resource as seen by
+// the client, under the /tenants/{id} prefix for requests
+// which reached the tenant with it.
+func resourcePath(r *http.Request, path string) string {
+	prefix, _ := r.Context().Value(tenantPrefixKey{}).(string)
+	return prefix + path
+}
+
+// serveTenants passes requests to tenants selected by the path
+// prefix or by the X-Tenant-ID header. Other requests are served
+// by the next handler. Tenants run after middleware of the server
+// and check the admin token and the policy of the server, which
+// they are created with.
+func (cs *Server) serveTenants(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		id, rest, prefixed := tenantPath(r.URL.Path)
+		if !prefixed {
+			id = r.Header.Get(tenantHeader)
+		}
+		if id == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ts, err := cs.tenants.get(id)
+		if err != nil {
+			writeStoreError(w, r, err)
+			return
+		}
+		ctx := r.Context()
+		if prefixed {
+			ctx = context.WithValue(ctx, tenantPrefixKey{}, "/tenants/"+id)
+		}
+		r = r.Clone(ctx)
+		if prefixed {
+			r.URL.Path = rest
+			r.URL.RawPath = ""
+		}
+		// The tenant keeps the request ID assigned by the server.
+		if r.Header.Get(middleware.RequestIDHeader) == "" {
+			r.Header.Set(middleware.RequestIDHeader, middleware.GetReqID(ctx))
+		}
+		ts.server.Handler().ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// CreateTenant provisions the tenant.
+func (cs *Server) CreateTenant(w http.ResponseWriter, r *http.Request) {
+	if !cs.Tenants {
+		writeError(w, r, http.StatusNotImplemented, "server doesn't serve tenants")
+		return
+	}
+	var t Tenant
+	if !cs.decodeJSON(w, r, &t, "invalid tenant") {
+		return
+	}
+	if !validTenantID(t.ID) {
+		writeFieldErrors(w, r, "invalid tenant", []FieldError{{Field: "id", Message: "must hold up to 64 letters, digits, '-', '_' or '.'"}})
+		return
+	}
+	ts, err := cs.newTenant(t)
+	if err != nil {
+		var verr *ValidationError
+		if errors.As(err, &verr) {
+			writeStoreError(w, r, err)
+			return
+		}
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := cs.tenants.add(ts); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	w.Header().Set("Location", resourcePath(r, "/admin/tenants/"+ts.tenant.ID))
+	writeJSON(w, r, http.StatusCreated, ts.tenant)
+}
+
+// GetTenants returns provisioned tenants sorted by ID.
+func (cs *Server) GetTenants(w http.ResponseWriter, r *http.Request) {
+	if !cs.Tenants {
+		writeError(w, r, http.StatusNotImplemented, "server doesn't serve tenants")
+		return
+	}
+	writeJSON(w, r, http.StatusOK, cs.tenants.list())
+}
+
+// GetTenant returns the tenant.
+func (cs *Server) GetTenant(w http.ResponseWriter, r *http.Request) {
+	if !cs.Tenants {
+		writeError(w, r, http.StatusNotImplemented, "server doesn't serve tenants")
+		return
+	}
+	ts, err := cs.tenants.get(chi.URLParam(r, "tenantID"))
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, ts.tenant)
+}
+
+// DeleteTenant removes the tenant with all its data.
+func (cs *Server) DeleteTenant(w http.ResponseWriter, r *http.Request) {
+	if !cs.Tenants {
+		writeError(w, r, http.StatusNotImplemented, "server doesn't serve tenants")
+		return
+	}
+	ts, err := cs.tenants.remove(chi.URLParam(r, "tenantID"))
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	if err := ts.server.Shutdown(r.Context()); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,301 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// createTenant provisions the tenant described by the JSON body.
+func createTenant(t *testing.T, url, body string) *http.Response {
+	t.Helper()
+	resp, err := http.Post(url+"admin/tenants", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestServer_IsolatesCatalogsOfTenants(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t, coffeeshop.WithTenants())
+	for _, id := range []string{"a", "b"} {
+		resp := createTenant(t, shop.URL, `{"id":"`+id+`"}`)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("tenant %s: want HTTP 201, got %d", id, resp.StatusCode)
+		}
+	}
+	resp, err := http.Post(shop.URL+"tenants/a/products", "application/json",
+		strings.NewReader(`{"id":"100","type":"Tea","brand":"Tetley","name":"Green","price":"3.49","stock":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("want HTTP 201, got %d", resp.StatusCode)
+	}
+
+	getWithTenant := func(path, tenant string) int {
+		req, err := http.NewRequest(http.MethodGet, shop.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tenant != "" {
+			req.Header.Set("X-Tenant-ID", tenant)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	tcs := []struct {
+		path, tenant string
+		want         int
+	}{
+		{path: "products/100", tenant: "a", want: http.StatusOK},
+		{path: "tenants/a/products/100", want: http.StatusOK},
+		{path: "products/100", tenant: "b", want: http.StatusNotFound},
+		{path: "tenants/b/products/100", want: http.StatusNotFound},
+		{path: "products/100", want: http.StatusNotFound},
+		{path: "products/1", tenant: "b", want: http.StatusOK},
+		{path: "products/1", tenant: "c", want: http.StatusNotFound},
+	}
+	for _, tc := range tcs {
+		if got := getWithTenant(tc.path, tc.tenant); got != tc.want {
+			t.Errorf("GET %s as %q: want HTTP %d, got %d", tc.path, tc.tenant, tc.want, got)
+		}
+	}
+}
+
+func TestServer_AppliesScenarioOfTenant(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t, coffeeshop.WithTenants())
+	resp := createTenant(t, shop.URL, `{"id":"flaky","scenario":{"steps":[{"times":1,"status":503}]}}`)
+	defer resp.Body.Close()
+	var tenant coffeeshop.Tenant
+	if err := json.NewDecoder(resp.Body).Decode(&tenant); err != nil {
+		t.Fatal(err)
+	}
+	if want := shop.URL + "tenants/flaky/"; tenant.URL != want {
+		t.Errorf("want tenant URL %s, got %s", want, tenant.URL)
+	}
+	for _, want := range []int{http.StatusServiceUnavailable, http.StatusOK} {
+		got, err := getStatus(t, http.DefaultClient, tenant.URL+"products")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("want HTTP %d, got %d", want, got)
+		}
+	}
+	got, err := getStatus(t, http.DefaultClient, shop.URL+"products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != http.StatusOK {
+		t.Errorf("want HTTP 200 outside the tenant, got %d", got)
+	}
+}
+
+func TestServer_InjectsFaultsOfTenant(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t, coffeeshop.WithTenants())
+	resp := createTenant(t, shop.URL, `{"id":"broken","faultRate":1,"faultStatus":500}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("want HTTP 201, got %d", resp.StatusCode)
+	}
+	got, err := getStatus(t, http.DefaultClient, shop.URL+"tenants/broken/products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != http.StatusInternalServerError {
+		t.Errorf("want HTTP 500 from the tenant, got %d", got)
+	}
+	got, err = getStatus(t, http.DefaultClient, shop.URL+"products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != http.StatusOK {
+		t.Errorf("want HTTP 200 outside the tenant, got %d", got)
+	}
+
+	resp = createTenant(t, shop.URL, `{"id":"invalid","faultRate":2}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("want HTTP 400 for invalid fault rate, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_RemovesTenant(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t, coffeeshop.WithTenants())
+	resp := createTenant(t, shop.URL, `{"id":"a","products":[{"id":"1","type":"Tea","name":"Green"}]}`)
+	resp.Body.Close()
+	resp = createTenant(t, shop.URL, `{"id":"a"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("want HTTP 409 for duplicate tenant, got %d", resp.StatusCode)
+	}
+	req, err := http.NewRequest(http.MethodDelete, shop.URL+"admin/tenants/a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("want HTTP 204, got %d", resp.StatusCode)
+	}
+	resp, err = http.Get(shop.URL + "tenants/a/products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if code := errorCodeOf(t, resp); resp.StatusCode != http.StatusNotFound || code != "tenant_not_found" {
+		t.Errorf("want HTTP 404 tenant_not_found, got %d %s", resp.StatusCode, code)
+	}
+}
+
+func TestServer_RejectsTenantsUnlessEnabled(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t)
+	resp := createTenant(t, shop.URL, `{"id":"a"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Errorf("want HTTP 501, got %d", resp.StatusCode)
+	}
+}
+
+// sendToTenant sends the request with the X-Tenant-ID header
+// and the admin token, if it isn't empty.
+func sendToTenant(t *testing.T, tenant, token, method, url string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Tenant-ID", tenant)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestServer_RequiresAdminTokenOfServerForTenants(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t,
+		coffeeshop.WithTenants(),
+		coffeeshop.WithAdminToken("s3cret"),
+	)
+	resp := sendAs(t, "s3cret", http.MethodPost, shop.URL+"admin/tenants", `{"id":"a"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("want HTTP 201, got %d", resp.StatusCode)
+	}
+	tcs := []struct {
+		name string
+		resp func() *http.Response
+		want int
+	}{
+		{"header without token", func() *http.Response {
+			return sendToTenant(t, "a", "", http.MethodPost, shop.URL+"admin/reset")
+		}, http.StatusUnauthorized},
+		{"header with token", func() *http.Response {
+			return sendToTenant(t, "a", "s3cret", http.MethodPost, shop.URL+"admin/reset")
+		}, http.StatusNoContent},
+		{"prefix without token", func() *http.Response {
+			return sendAs(t, "", http.MethodPost, shop.URL+"tenants/a/admin/reset", "")
+		}, http.StatusUnauthorized},
+		{"prefix with token", func() *http.Response {
+			return sendAs(t, "s3cret", http.MethodPost, shop.URL+"tenants/a/admin/reset", "")
+		}, http.StatusNoContent},
+	}
+	for _, tc := range tcs {
+		resp := tc.resp()
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s: want HTTP %d, got %d", tc.name, tc.want, resp.StatusCode)
+		}
+	}
+}
+
+func TestServer_AppliesPolicyAndMiddlewareOfServerToTenants(t *testing.T) {
+	t.Parallel()
+
+	tagged := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Tagged", "yes")
+			next.ServeHTTP(w, r)
+		})
+	}
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t,
+		coffeeshop.WithTenants(),
+		coffeeshop.WithMiddleware(tagged),
+		coffeeshop.WithPolicy(coffeeshop.Policy{
+			Keys:   map[string][]string{"k3y": {"reader"}},
+			Groups: map[coffeeshop.RouteGroup][]string{coffeeshop.APIRoutes: {coffeeshop.Authenticated}},
+		}),
+	)
+	resp := createTenant(t, shop.URL, `{"id":"a"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("want HTTP 201, got %d", resp.StatusCode)
+	}
+	resp = getWithKey(t, shop.URL+"tenants/a/products/1", "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("want HTTP 403 without API key, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("X-Tagged") != "yes" {
+		t.Error("want middleware of the server to run for tenant requests")
+	}
+	resp = getWithKey(t, shop.URL+"tenants/a/products/1", "k3y")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("want HTTP 200 with API key, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_LinksTenantResourcesUnderTenantPath(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t,
+		coffeeshop.WithTenants(coffeeshop.WithLinks()),
+	)
+	resp := createTenant(t, shop.URL, `{"id":"a"}`)
+	resp.Body.Close()
+	status, body := getResponse(t, shop.URL+"tenants/a/products/1")
+	if status != http.StatusOK {
+		t.Fatalf("want HTTP 200, got %d", status)
+	}
+	if want := `"href":"` + strings.TrimSuffix(shop.URL, "/") + `/tenants/a/products/1"`; !strings.Contains(body, want) {
+		t.Errorf("want link %s, got %s", want, body)
+	}
+	resp, err := http.Post(shop.URL+"tenants/a/products", "application/json", strings.NewReader(`{"id":"100","type":"Tea","name":"Green"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if location := resp.Header.Get("Location"); location != "/tenants/a/products/100" {
+		t.Errorf("want location under the tenant path, got %q", location)
+	}
+}
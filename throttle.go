@@ -0,0 +1,79 @@
+package coffeeshop
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// throttleTick is how often throttled responses send a chunk.
+const throttleTick = 100 * time.Millisecond
+
+// WithThrottle limits the bandwidth of responses of delayed
+// route groups to the given number of bytes per second.
+// Response bodies trickle to clients in chunks sent ten
+// times a second, which helps testing read timeouts and
+// handling of partially read responses.
+func WithThrottle(bytesPerSecond int) Option {
+	return func(s *Server) error {
+		if bytesPerSecond <= 0 {
+			return errors.New("throttle must be positive")
+		}
+		s.Throttle = bytesPerSecond
+		return nil
+	}
+}
+
+// throttledWriter writes the response body in chunks
+// of limited size, pausing after each chunk.
+type throttledWriter struct {
+	http.ResponseWriter
+	r     *http.Request
+	chunk int
+}
+
+func (tw *throttledWriter) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		n := tw.chunk
+		if n > len(b) {
+			n = len(b)
+		}
+		m, err := tw.ResponseWriter.Write(b[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+		if err := http.NewResponseController(tw.ResponseWriter).Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return written, err
+		}
+		t := time.NewTimer(throttleTick)
+		select {
+		case <-tw.r.Context().Done():
+			t.Stop()
+			return written, tw.r.Context().Err()
+		case <-t.C:
+		}
+	}
+	return written, nil
+}
+
+// Unwrap returns the original writer, which lets
+// http.ResponseController flush and hijack it.
+func (tw *throttledWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// throttle slows down writing of response bodies
+// to the configured number of bytes per second.
+func (cs *Server) throttle(next http.Handler) http.Handler {
+	chunk := cs.Throttle * int(throttleTick) / int(time.Second)
+	if chunk < 1 {
+		chunk = 1
+	}
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&throttledWriter{ResponseWriter: w, r: r, chunk: chunk}, r)
+	}
+	return http.HandlerFunc(fn)
+}
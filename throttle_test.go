@@ -0,0 +1,64 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/qba73/coffeeshop"
+)
+
+func TestServer_ThrottlesResponseBodies(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0ms", t,
+		coffeeshop.WithThrottle(2000),
+	)
+	start := time.Now()
+	resp, err := http.Get(shop.URL + "products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elapsed := time.Since(start)
+
+	// Chunks of 200 bytes are sent every 100 milliseconds.
+	chunks := (len(body) + 199) / 200
+	if want := time.Duration(chunks-1) * 100 * time.Millisecond; elapsed < want {
+		t.Errorf("want %d bytes in at least %v, got them in %v", len(body), want, elapsed)
+	}
+	var px []coffeeshop.Product
+	if err := json.Unmarshal(body, &px); err != nil {
+		t.Fatalf("want complete body, got %v", err)
+	}
+	if len(px) != len(inventory) {
+		t.Errorf("want %d products, got %d", len(inventory), len(px))
+	}
+}
+
+func TestServer_ThrottledResponsesTimeOutPartiallyRead(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0ms", t,
+		coffeeshop.WithThrottle(100),
+	)
+	client := http.Client{Timeout: 300 * time.Millisecond}
+	resp, err := client.Get(shop.URL + "products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err == nil {
+		t.Fatal("want timeout reading throttled body")
+	}
+	if len(body) == 0 {
+		t.Error("want part of the body before the timeout")
+	}
+}
@@ -0,0 +1,577 @@
+package coffeeshop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+func init() {
+	RegisterStore("tiered", openTieredStore)
+}
+
+// Tiers of the TieredStore.
+const (
+	tierCache    = "cache"
+	tierPrimary  = "primary"
+	tierFallback = "fallback"
+)
+
+// TieredStore composes the primary store with a memory cache in front
+// of it and a fallback store behind it, so the shop degrades gracefully
+// when the backend of the primary store fails.
+//
+// Products are read from the cache first, and read from the primary
+// store on a miss or once their copy is older than the TTL. Lists of
+// products are always read from the primary store, since the cache may
+// hold only some of them. Products read from the primary store are
+// copied to the cache. If the primary store fails, products are served
+// from the cache, even if their copies expired, or from the fallback
+// store, for example a static catalog, if the cache doesn't have them.
+// Changes go to the primary store only, and fail while it's down.
+// Changed products are copied to the cache, and deleted products are
+// dropped from it. Changes made directly in the primary store become
+// visible when their copies expire.
+type TieredStore struct {
+	Primary Store
+	// Cache holds copies of products read from the primary
+	// store. Nil disables the cache.
+	Cache *MemoryStore
+	// TTL limits how long copies of products are served from the
+	// cache while the primary store works. Zero TTL means copies
+	// are served only when the primary store fails.
+	TTL time.Duration
+	// Clock expires copies of products. Nil means the real clock.
+	Clock Clock
+	// Fallback serves products when neither the primary store
+	// nor the cache can. Nil disables the fallback.
+	Fallback Store
+
+	mx sync.Mutex
+	// cachedAt holds the times products were copied to the cache.
+	cachedAt map[string]time.Time
+
+	cacheReads    atomic.Int64
+	primaryReads  atomic.Int64
+	fallbackReads atomic.Int64
+	// parent holds copies and counters of stores
+	// returned by WithContext.
+	parent *TieredStore
+}
+
+// DefaultTieredCacheTTL is the TTL of copies of products
+// cached by the TieredStore returned by NewTieredStore.
+const DefaultTieredCacheTTL = time.Minute
+
+// NewTieredStore returns the store reading products of the primary
+// store through an empty memory cache, falling back to the fallback
+// store, if not nil.
+func NewTieredStore(primary, fallback Store) *TieredStore {
+	return &TieredStore{
+		Primary:  primary,
+		Cache:    &MemoryStore{},
+		TTL:      DefaultTieredCacheTTL,
+		Fallback: fallback,
+	}
+}
+
+// WithContext returns the store sharing the cache with the store and
+// calling the primary and the fallback stores with the context, if
+// they are ContextStores.
+func (ts *TieredStore) WithContext(ctx context.Context) Store {
+	bound := &TieredStore{
+		Primary: withContext(ts.Primary, ctx),
+		Cache:   ts.Cache,
+		TTL:     ts.TTL,
+		Clock:   ts.Clock,
+		parent:  ts.base(),
+	}
+	if ts.Fallback != nil {
+		bound.Fallback = withContext(ts.Fallback, ctx)
+	}
+	return bound
+}
+
+// base returns the store holding times of copies and counters of reads.
+func (ts *TieredStore) base() *TieredStore {
+	if ts.parent != nil {
+		return ts.parent
+	}
+	return ts
+}
+
+// remember copies the products to the cache as they are, without
+// changing their versions.
+func (ts *TieredStore) remember(px ...Product) {
+	ts = ts.base()
+	if ts.Cache == nil {
+		return
+	}
+	at := now(ts.Clock)
+	ts.mx.Lock()
+	defer ts.mx.Unlock()
+	if ts.cachedAt == nil {
+		ts.cachedAt = make(map[string]time.Time)
+	}
+	ts.Cache.mx.Lock()
+	defer ts.Cache.mx.Unlock()
+	if ts.Cache.Products == nil {
+		ts.Cache.Products = make(Products)
+	}
+	for _, p := range px {
+		ts.Cache.Products[p.ID] = p.clone()
+		ts.cachedAt[p.ID] = at
+	}
+}
+
+// forget drops copies of the products from the cache.
+func (ts *TieredStore) forget(ids ...string) {
+	ts = ts.base()
+	if ts.Cache == nil {
+		return
+	}
+	ts.mx.Lock()
+	defer ts.mx.Unlock()
+	ts.Cache.mx.Lock()
+	defer ts.Cache.mx.Unlock()
+	for _, id := range ids {
+		delete(ts.Cache.Products, id)
+		delete(ts.cachedAt, id)
+	}
+}
+
+// cached returns the copy of the product from the cache,
+// unless it's older than the TTL.
+func (ts *TieredStore) cached(id string) (Product, bool) {
+	ts = ts.base()
+	if ts.Cache == nil {
+		return Product{}, false
+	}
+	ts.mx.Lock()
+	at, ok := ts.cachedAt[id]
+	ts.mx.Unlock()
+	if !ok || !now(ts.Clock).Before(at.Add(ts.TTL)) {
+		return Product{}, false
+	}
+	p, err := ts.Cache.GetProduct(id)
+	return p, err == nil
+}
+
+// primaryDown reports whether the backend of the primary store is
+// unreachable. Stores which can't be pinged are assumed reachable.
+func (ts *TieredStore) primaryDown() bool {
+	p, ok := ts.Primary.(Pinger)
+	if !ok {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	return p.Ping(ctx) != nil
+}
+
+// list returns products listed by the primary store. If the primary
+// store returns no products because it's down, it returns products
+// listed by the cache or, if the cache has none, by the fallback store.
+func (ts *TieredStore) list(list func(s Store) []Product) []Product {
+	px := list(ts.Primary)
+	if len(px) > 0 || !ts.primaryDown() {
+		ts.base().primaryReads.Add(1)
+		ts.remember(px...)
+		return px
+	}
+	if ts.Cache != nil {
+		if px := list(ts.Cache); len(px) > 0 {
+			ts.base().cacheReads.Add(1)
+			return px
+		}
+	}
+	if ts.Fallback != nil {
+		ts.base().fallbackReads.Add(1)
+		return list(ts.Fallback)
+	}
+	return nil
+}
+
+// GetAll returns all products.
+func (ts *TieredStore) GetAll() []Product {
+	return ts.list(func(s Store) []Product { return s.GetAll() })
+}
+
+// GetByType returns products of the type.
+func (ts *TieredStore) GetByType(productType string) []Product {
+	return ts.list(func(s Store) []Product { return s.GetByType(productType) })
+}
+
+// GetProduct returns the product with the given ID from the cache,
+// or from the primary store on a miss. If the primary store fails,
+// the product is returned from the cache, even if its copy expired,
+// or from the fallback store.
+func (ts *TieredStore) GetProduct(id string) (Product, error) {
+	if p, ok := ts.cached(id); ok {
+		ts.base().cacheReads.Add(1)
+		return p, nil
+	}
+	p, err := ts.Primary.GetProduct(id)
+	if !storeFailure(err) {
+		ts.base().primaryReads.Add(1)
+		if err == nil {
+			ts.remember(p)
+		}
+		if errors.Is(err, ErrProductNotFound) {
+			ts.forget(id)
+		}
+		return p, err
+	}
+	if ts.Cache != nil {
+		if p, err := ts.Cache.GetProduct(id); err == nil {
+			ts.base().cacheReads.Add(1)
+			return p, nil
+		}
+	}
+	if ts.Fallback == nil {
+		return p, err
+	}
+	ts.base().fallbackReads.Add(1)
+	return ts.Fallback.GetProduct(id)
+}
+
+// GetMany returns products with the given IDs from the cache, and
+// products missing the cache from the primary store. If the primary
+// store fails, they are returned from the cache, even if their copies
+// expired, or from the fallback store.
+func (ts *TieredStore) GetMany(ids []string) ([]Product, error) {
+	var cached []Product
+	var missingIDs []string
+	for _, id := range ids {
+		if p, ok := ts.cached(id); ok {
+			cached = append(cached, p)
+			continue
+		}
+		missingIDs = append(missingIDs, id)
+	}
+	if len(missingIDs) == 0 {
+		ts.base().cacheReads.Add(1)
+		return orderedByIDs(ids, cached), nil
+	}
+	px, err := getMany(ts.Primary, missingIDs)
+	if err == nil {
+		ts.base().primaryReads.Add(1)
+		ts.remember(px...)
+		return orderedByIDs(ids, append(cached, px...)), nil
+	}
+	if !storeFailure(err) {
+		return nil, err
+	}
+	var stale []Product
+	if ts.Cache != nil {
+		stale, _ = ts.Cache.GetMany(missingIDs)
+	}
+	if len(stale) == len(missingIDs) {
+		ts.base().cacheReads.Add(1)
+		return orderedByIDs(ids, append(cached, stale...)), nil
+	}
+	if ts.Fallback == nil {
+		return nil, err
+	}
+	ts.base().fallbackReads.Add(1)
+	px, err = getMany(ts.Fallback, missingIDs)
+	if err != nil {
+		return nil, err
+	}
+	// Copies in the cache are newer than products of the fallback store.
+	return orderedByIDs(ids, append(append(px, cached...), stale...)), nil
+}
+
+// GetBySKU returns the product with the SKU or EAN from the primary
+// store and copies it to the cache. If the primary store fails, the
+// product is returned from the cache or the fallback store.
+func (ts *TieredStore) GetBySKU(code string) (Product, error) {
+	p, err := productBySKU(ts.Primary, code)
+	if !storeFailure(err) {
+		ts.base().primaryReads.Add(1)
+		if err == nil {
+			ts.remember(p)
+		}
+		return p, err
+	}
+	if ts.Cache != nil {
+		if p, err := ts.Cache.GetBySKU(code); err == nil {
+			ts.base().cacheReads.Add(1)
+			return p, nil
+		}
+	}
+	if ts.Fallback == nil {
+		return p, err
+	}
+	ts.base().fallbackReads.Add(1)
+	return productBySKU(ts.Fallback, code)
+}
+
+// ProductStats returns statistics of products in the primary store.
+// If the primary store fails, statistics are computed from products
+// listed by the cache or the fallback store.
+func (ts *TieredStore) ProductStats() (ProductStats, error) {
+	stats, err := storeStats(ts.Primary)
+	if storeFailure(err) {
+		return productStats(ts.GetAll()), nil
+	}
+	return stats, err
+}
+
+// Changes returns changes of products since the cursor from the
+// primary store. The cache and the fallback store don't know about
+// changes, so it fails while the primary store is down, and if the
+// primary store isn't a Syncer.
+func (ts *TieredStore) Changes(cursor string) (SyncDelta, error) {
+	s, ok := storeAs[Syncer](ts.Primary)
+	if !ok {
+		return SyncDelta{}, fmt.Errorf("store %T doesn't support sync", ts.Primary)
+	}
+	return s.Changes(cursor)
+}
+
+// ReserveStock reserves stock in the primary store and drops
+// the reserved products from the cache.
+func (ts *TieredStore) ReserveStock(items []OrderItem) error {
+	err := ts.Primary.ReserveStock(items)
+	for _, item := range items {
+		ts.forget(item.ProductID)
+	}
+	return err
+}
+
+// ReleaseStock releases stock in the primary store and drops the
+// released products from the cache. It fails if the primary store
+// isn't a StockReleaser.
+func (ts *TieredStore) ReleaseStock(items []OrderItem) error {
+	sr, ok := storeAs[StockReleaser](ts.Primary)
+	if !ok {
+		return fmt.Errorf("store %T doesn't support releasing stock", ts.Primary)
+	}
+	err := sr.ReleaseStock(items)
+	for _, item := range items {
+		ts.forget(item.ProductID)
+	}
+	return err
+}
+
+// SetStock sets stock in the primary store and copies
+// the product to the cache.
+func (ts *TieredStore) SetStock(id string, stock int) (Product, error) {
+	p, err := ts.Primary.SetStock(id, stock)
+	if err != nil {
+		ts.forget(id)
+		return p, err
+	}
+	ts.remember(p)
+	return p, nil
+}
+
+// PutProduct puts the product into the primary store and copies
+// it to the cache. It fails if the primary store isn't an Importer.
+func (ts *TieredStore) PutProduct(p Product) (Product, error) {
+	importer, ok := storeAs[Importer](ts.Primary)
+	if !ok {
+		return Product{}, fmt.Errorf("store %T doesn't support imports", ts.Primary)
+	}
+	put, err := importer.PutProduct(p)
+	if err != nil {
+		ts.forget(p.ID)
+		return put, err
+	}
+	ts.remember(put)
+	return put, nil
+}
+
+// Add adds the product to the primary store and copies it to the
+// cache. It fails if the primary store isn't a ProductWriter.
+func (ts *TieredStore) Add(p Product) (Product, error) {
+	pw, err := ts.writer()
+	if err != nil {
+		return Product{}, err
+	}
+	added, err := pw.Add(p)
+	if err != nil {
+		return added, err
+	}
+	ts.remember(added)
+	return added, nil
+}
+
+// Update updates the product in the primary store and copies it to
+// the cache. It fails if the primary store isn't a ProductWriter.
+func (ts *TieredStore) Update(p Product) (Product, error) {
+	pw, err := ts.writer()
+	if err != nil {
+		return Product{}, err
+	}
+	updated, err := pw.Update(p)
+	if err != nil {
+		ts.forget(p.ID)
+		return updated, err
+	}
+	ts.remember(updated)
+	return updated, nil
+}
+
+// Replace replaces the product in the primary store and copies it to
+// the cache. It fails if the primary store isn't a Replacer.
+func (ts *TieredStore) Replace(id string, write func(old Product, exists bool) (Product, error)) (old, product Product, err error) {
+	rp, ok := storeAs[Replacer](ts.Primary)
+	if !ok {
+		return Product{}, Product{}, fmt.Errorf("store %T doesn't support replacing products", ts.Primary)
+	}
+	old, product, err = rp.Replace(id, write)
+	if err != nil {
+		ts.forget(id)
+		return old, product, err
+	}
+	ts.remember(product)
+	return old, product, nil
+}
+
+// Delete deletes the product from the primary store and drops it
+// from the cache. It fails if the primary store isn't a ProductWriter.
+func (ts *TieredStore) Delete(id string) error {
+	pw, err := ts.writer()
+	if err != nil {
+		return err
+	}
+	defer ts.forget(id)
+	return pw.Delete(id)
+}
+
+func (ts *TieredStore) writer() (ProductWriter, error) {
+	pw, ok := storeAs[ProductWriter](ts.Primary)
+	if !ok {
+		return nil, fmt.Errorf("store %T doesn't support writes", ts.Primary)
+	}
+	return pw, nil
+}
+
+// ResetProducts replaces products of the primary store
+// and drops all copies of products from the cache.
+func (ts *TieredStore) ResetProducts(products Products) {
+	defer ts.forgetAll()
+	if r, ok := storeAs[Resetter](ts.Primary); ok {
+		r.ResetProducts(products)
+	}
+}
+
+func (ts *TieredStore) saveState() any {
+	s, ok := storeAs[stateStore](ts.Primary)
+	if !ok {
+		return nil
+	}
+	return s.saveState()
+}
+
+func (ts *TieredStore) restoreState(state any) {
+	defer ts.forgetAll()
+	if s, ok := storeAs[stateStore](ts.Primary); ok {
+		s.restoreState(state)
+	}
+}
+
+// forgetAll drops all copies of products from the cache.
+func (ts *TieredStore) forgetAll() {
+	ts = ts.base()
+	if ts.Cache == nil {
+		return
+	}
+	ts.mx.Lock()
+	defer ts.mx.Unlock()
+	ts.Cache.mx.Lock()
+	defer ts.Cache.mx.Unlock()
+	ts.Cache.Products = nil
+	ts.cachedAt = nil
+}
+
+// Unwrap returns the primary store. Optional interfaces of the primary
+// store not implemented by the TieredStore, like Notifier, are used
+// without the cache and the fallback store.
+func (ts *TieredStore) Unwrap() Store {
+	return ts.Primary
+}
+
+// Ping reports whether the backend of the primary store is reachable.
+func (ts *TieredStore) Ping(ctx context.Context) error {
+	if p, ok := ts.Primary.(Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (ts *TieredStore) useClock(clock Clock) {
+	if ts.Clock == nil {
+		ts.Clock = clock
+	}
+	for _, s := range []any{ts.Primary, ts.Cache, ts.Fallback} {
+		if u, ok := s.(clockUser); ok {
+			u.useClock(clock)
+		}
+	}
+}
+
+// TierStats counts reads of products served by each tier.
+type TierStats struct {
+	Cache    int64 `json:"cache"`
+	Primary  int64 `json:"primary"`
+	Fallback int64 `json:"fallback"`
+}
+
+// Stats returns counts of reads served by each tier.
+func (ts *TieredStore) Stats() TierStats {
+	ts = ts.base()
+	return TierStats{
+		Cache:    ts.cacheReads.Load(),
+		Primary:  ts.primaryReads.Load(),
+		Fallback: ts.fallbackReads.Load(),
+	}
+}
+
+// openTieredStore opens the store using URLs in the
+// "tiered://?primary=dsn[&fallback=dsn][&cache=false][&ttl=1m]" format, for
+// example "tiered://?primary=redis://localhost:6379&fallback=memory://"
+// reading products of Redis through the cache, with sample products
+// as the fallback. DSNs with query parameters must be escaped.
+func openTieredStore(u *url.URL) (Store, error) {
+	q := u.Query()
+	if q.Get("primary") == "" {
+		return nil, errors.New("tiered store requires the primary store")
+	}
+	primary, err := OpenStore(q.Get("primary"))
+	if err != nil {
+		return nil, fmt.Errorf("primary store: %w", err)
+	}
+	var fallback Store
+	if dsn := q.Get("fallback"); dsn != "" {
+		fallback, err = OpenStore(dsn)
+		if err != nil {
+			return nil, fmt.Errorf("fallback store: %w", err)
+		}
+	}
+	ts := NewTieredStore(primary, fallback)
+	if c := q.Get("cache"); c != "" {
+		on, err := strconv.ParseBool(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tiered store cache %q", c)
+		}
+		if !on {
+			ts.Cache = nil
+		}
+	}
+	if ttl := q.Get("ttl"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid tiered store TTL %q", ttl)
+		}
+		ts.TTL = d
+	}
+	return ts, nil
+}
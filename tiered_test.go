@@ -0,0 +1,235 @@
+package coffeeshop_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// downStore loses the connection to its backend while it's down.
+type downStore struct {
+	*coffeeshop.MemoryStore
+	down atomic.Bool
+}
+
+var errConnectionLost = errors.New("connection lost")
+
+func (s *downStore) GetAll() []coffeeshop.Product {
+	if s.down.Load() {
+		return nil
+	}
+	return s.MemoryStore.GetAll()
+}
+
+func (s *downStore) GetByType(productType string) []coffeeshop.Product {
+	if s.down.Load() {
+		return nil
+	}
+	return s.MemoryStore.GetByType(productType)
+}
+
+func (s *downStore) GetProduct(id string) (coffeeshop.Product, error) {
+	if s.down.Load() {
+		return coffeeshop.Product{}, errConnectionLost
+	}
+	return s.MemoryStore.GetProduct(id)
+}
+
+func (s *downStore) Ping(ctx context.Context) error {
+	if s.down.Load() {
+		return errConnectionLost
+	}
+	return nil
+}
+
+func TestTieredStore_ServesCachedProductsWhenPrimaryIsDown(t *testing.T) {
+	t.Parallel()
+
+	primary := &downStore{MemoryStore: &coffeeshop.MemoryStore{Products: stockedInventory(5)}}
+	ts := coffeeshop.NewTieredStore(primary, nil)
+	if px := ts.GetAll(); len(px) != len(inventory) {
+		t.Fatalf("want %d products from primary, got %d", len(inventory), len(px))
+	}
+	primary.down.Store(true)
+	if px := ts.GetAll(); len(px) != len(inventory) {
+		t.Errorf("want %d cached products, got %d", len(inventory), len(px))
+	}
+	if _, err := ts.GetProduct("1"); err != nil {
+		t.Errorf("want cached product, got %v", err)
+	}
+	if stats := ts.Stats(); stats.Primary != 1 || stats.Cache != 2 {
+		t.Errorf("want 1 primary and 2 cache reads, got %+v", stats)
+	}
+}
+
+func TestTieredStore_FallsBackWhenPrimaryAndCacheCannotServe(t *testing.T) {
+	t.Parallel()
+
+	primary := &downStore{MemoryStore: &coffeeshop.MemoryStore{Products: stockedInventory(5)}}
+	primary.down.Store(true)
+	fallback := &coffeeshop.MemoryStore{Products: stockedInventory(1)}
+	ts := coffeeshop.NewTieredStore(primary, fallback)
+	if px := ts.GetByType("coffee"); len(px) == 0 {
+		t.Error("want coffee from the fallback store")
+	}
+	p, err := ts.GetProduct("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Stock != 1 {
+		t.Errorf("want product of the fallback store, got stock %d", p.Stock)
+	}
+	if stats := ts.Stats(); stats.Fallback != 2 {
+		t.Errorf("want 2 fallback reads, got %+v", stats)
+	}
+}
+
+func TestTieredStore_DoesNotFallBackOnMissingProducts(t *testing.T) {
+	t.Parallel()
+
+	primary := &coffeeshop.MemoryStore{Products: stockedInventory(5)}
+	fallback := &coffeeshop.MemoryStore{Products: map[string]coffeeshop.Product{"missing": {ID: "missing"}}}
+	ts := coffeeshop.NewTieredStore(primary, fallback)
+	if _, err := ts.GetProduct("missing"); !errors.Is(err, coffeeshop.ErrProductNotFound) {
+		t.Errorf("want ErrProductNotFound, got %v", err)
+	}
+}
+
+func TestTieredStore_CopiesChangesToCache(t *testing.T) {
+	t.Parallel()
+
+	primary := &downStore{MemoryStore: &coffeeshop.MemoryStore{Products: stockedInventory(5)}}
+	ts := coffeeshop.NewTieredStore(primary, nil)
+	if _, err := ts.GetProduct("1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.SetStock("1", 42); err != nil {
+		t.Fatal(err)
+	}
+	primary.down.Store(true)
+	p, err := ts.GetProduct("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Stock != 42 {
+		t.Errorf("want cached stock 42, got %d", p.Stock)
+	}
+}
+
+func TestOpenStore_OpensTieredStore(t *testing.T) {
+	t.Parallel()
+
+	store, err := coffeeshop.OpenStore("tiered://?primary=memory://&fallback=memory://&cache=false")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, ok := store.(*coffeeshop.TieredStore)
+	if !ok {
+		t.Fatalf("want *TieredStore, got %T", store)
+	}
+	if ts.Cache != nil || ts.Fallback == nil {
+		t.Errorf("want fallback store without cache, got %+v", ts)
+	}
+	for _, dsn := range []string{"tiered://", "tiered://?primary=bogus://", "tiered://?primary=memory://&cache=maybe"} {
+		if _, err := coffeeshop.OpenStore(dsn); err == nil {
+			t.Errorf("%s: want error", dsn)
+		}
+	}
+}
+
+func TestServer_ReportsTierReadsInMetrics(t *testing.T) {
+	t.Parallel()
+
+	primary := &downStore{MemoryStore: &coffeeshop.MemoryStore{Products: stockedInventory(5)}}
+	shop := newCoffeShopTestServer(coffeeshop.NewTieredStore(primary, nil), "0s", t)
+	getResponse(t, shop.URL+"products")
+	primary.down.Store(true)
+	if status, _ := getResponse(t, shop.URL+"products/1"); status != http.StatusOK {
+		t.Fatalf("want HTTP 200 from cache, got %d", status)
+	}
+	_, metrics := getResponse(t, shop.URL+"metrics")
+	for _, want := range []string{
+		`coffeeshop_store_tier_reads_total{tier="cache"} 1`,
+		`coffeeshop_store_tier_reads_total{tier="primary"} 1`,
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("want %s in metrics, got:\n%s", want, metrics)
+		}
+	}
+}
+
+func TestTieredStore_DropsDeletedProductsFromCache(t *testing.T) {
+	t.Parallel()
+
+	ts := coffeeshop.NewTieredStore(&coffeeshop.MemoryStore{Products: stockedInventory(5)}, nil)
+	if _, err := ts.GetProduct("1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.Delete("1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.GetProduct("1"); !errors.Is(err, coffeeshop.ErrProductNotFound) {
+		t.Errorf("want ErrProductNotFound for deleted product, got %v", err)
+	}
+}
+
+func TestTieredStore_RereadsProductsChangedInPrimaryAfterTTL(t *testing.T) {
+	t.Parallel()
+
+	clock := coffeeshop.NewFakeClock(syncStart)
+	primary := &coffeeshop.MemoryStore{Products: stockedInventory(5)}
+	ts := coffeeshop.NewTieredStore(primary, nil)
+	ts.Clock = clock
+	if _, err := ts.GetProduct("1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := primary.SetStock("1", 42); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := ts.GetProduct("1"); p.Stock == 42 {
+		t.Fatal("want cached copy served within the TTL")
+	}
+	clock.Advance(coffeeshop.DefaultTieredCacheTTL)
+	p, err := ts.GetProduct("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Stock != 42 {
+		t.Errorf("want stock 42 read from primary after the TTL, got %d", p.Stock)
+	}
+}
+
+func TestTieredStore_ServesExpiredCopiesWhenPrimaryIsDown(t *testing.T) {
+	t.Parallel()
+
+	clock := coffeeshop.NewFakeClock(syncStart)
+	primary := &downStore{MemoryStore: &coffeeshop.MemoryStore{Products: stockedInventory(5)}}
+	ts := coffeeshop.NewTieredStore(primary, nil)
+	ts.Clock = clock
+	if _, err := ts.GetProduct("1"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	primary.down.Store(true)
+	if _, err := ts.GetProduct("1"); err != nil {
+		t.Errorf("want expired copy served while primary is down, got %v", err)
+	}
+}
+
+func TestServer_UsesOptionalStoreInterfacesThroughTieredStore(t *testing.T) {
+	t.Parallel()
+
+	primary := &coffeeshop.MemoryStore{Products: stockedInventory(5)}
+	shop := newCoffeShopTestServer(coffeeshop.NewTieredStore(primary, nil), "0s", t)
+	getResponse(t, shop.URL+"products/1")
+	wantOptionalStoreRoutes(t, shop.URL)
+	if status, body := getResponse(t, shop.URL+"products/1"); status != http.StatusOK || !strings.Contains(body, `"archived":true`) {
+		t.Errorf("want archived product 1 from the cache, got HTTP %d %s", status, body)
+	}
+}
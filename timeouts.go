@@ -0,0 +1,104 @@
+package coffeeshop
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Default timeouts of the server.
+const (
+	DefaultReadTimeout    = 30 * time.Second
+	DefaultWriteTimeout   = 30 * time.Second
+	DefaultHandlerTimeout = 120 * time.Second
+)
+
+// WithTimeouts configures the read, write and idle timeouts of
+// connections, for example "30s". Zero means no timeout. The write
+// timeout is extended by the simulated latency, so long delays
+// don't cut off responses.
+func WithTimeouts(read, write, idle string) Option {
+	return func(s *Server) error {
+		var timeouts [3]time.Duration
+		for i, t := range []string{read, write, idle} {
+			d, err := time.ParseDuration(t)
+			if err != nil {
+				return err
+			}
+			if d < 0 {
+				return fmt.Errorf("negative timeout %s", t)
+			}
+			timeouts[i] = d
+		}
+		s.HTTPServer.ReadTimeout = timeouts[0]
+		s.HTTPServer.WriteTimeout = timeouts[1]
+		s.HTTPServer.IdleTimeout = timeouts[2]
+		return nil
+	}
+}
+
+// WithHandlerTimeout configures how long handlers can take before
+// requests are answered with 504 Gateway Timeout. The timeout is
+// extended by the simulated latency. Store calls of handlers are
+// bound by the same deadline.
+func WithHandlerTimeout(t string) Option {
+	return func(s *Server) error {
+		d, err := time.ParseDuration(t)
+		if err != nil {
+			return err
+		}
+		if d <= 0 {
+			return errors.New("handler timeout must be positive")
+		}
+		s.HandlerTimeout = d
+		return nil
+	}
+}
+
+// WithMaxHeaderBytes limits the size of request headers.
+// Requests with larger headers are rejected with
+// 431 Request Header Fields Too Large.
+func WithMaxHeaderBytes(n int) Option {
+	return func(s *Server) error {
+		if n <= 0 {
+			return errors.New("max header bytes must be positive")
+		}
+		s.HTTPServer.MaxHeaderBytes = n
+		return nil
+	}
+}
+
+// maxLatency returns the longest latency the server simulates
+// for a request: the configured latency, latency requested in
+// headers, latency jitter and delays of scenario steps.
+func (cs *Server) maxLatency() time.Duration {
+	latency := cs.Latency
+	if cs.HeaderLatency && maxHeaderLatency > latency {
+		latency = maxHeaderLatency
+	}
+	var delay time.Duration
+	for _, step := range cs.Scenario.Steps {
+		if step.Delay > delay {
+			delay = step.Delay
+		}
+	}
+	return latency + cs.LatencyJitter + delay
+}
+
+// handlerTimeout returns the handler timeout extended by the latency.
+func (cs *Server) handlerTimeout() time.Duration {
+	return cs.HandlerTimeout + cs.maxLatency()
+}
+
+// extendWriteDeadline gives the next writes of a stream the write
+// timeout of the server, starting now. Streams call it before each
+// write, so they stay open for longer than the write timeout while a
+// client that stops reading still can't block a write forever.
+func (cs *Server) extendWriteDeadline(rc *http.ResponseController) {
+	var deadline time.Time
+	if cs.HTTPServer.WriteTimeout > 0 {
+		deadline = time.Now().Add(cs.HTTPServer.WriteTimeout)
+	}
+	_ = rc.SetWriteDeadline(deadline)
+}
@@ -0,0 +1,97 @@
+package coffeeshop_test
+
+import (
+	"bufio"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qba73/coffeeshop"
+)
+
+func TestServer_ExtendsHandlerTimeoutByLatency(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t,
+		coffeeshop.WithHeaderLatency(),
+		coffeeshop.WithHandlerTimeout("100ms"),
+	)
+	resp, elapsed := getWithDelay(t, shop.URL+"products", "300ms")
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("want HTTP 200 for delay over handler timeout, got %d after %v", resp.StatusCode, elapsed)
+	}
+}
+
+func TestServer_RejectsHeadersOverLimit(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t,
+		coffeeshop.WithMaxHeaderBytes(1024),
+	)
+	req, err := http.NewRequest(http.MethodGet, shop.URL+"products", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Padding", strings.Repeat("x", 16<<10))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("want HTTP 431, got %d", resp.StatusCode)
+	}
+}
+
+func TestWithTimeouts_ConfiguresServerTimeouts(t *testing.T) {
+	t.Parallel()
+
+	cs, err := coffeeshop.New("localhost:0", &coffeeshop.MemoryStore{}, coffeeshop.WithTimeouts("1s", "2s", "3s"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cs.HTTPServer.ReadTimeout != time.Second || cs.HTTPServer.WriteTimeout != 2*time.Second || cs.HTTPServer.IdleTimeout != 3*time.Second {
+		t.Errorf("want timeouts 1s, 2s and 3s, got %v, %v and %v",
+			cs.HTTPServer.ReadTimeout, cs.HTTPServer.WriteTimeout, cs.HTTPServer.IdleTimeout)
+	}
+
+	for _, opt := range []coffeeshop.Option{
+		coffeeshop.WithTimeouts("1s", "-2s", "3s"),
+		coffeeshop.WithTimeouts("soon", "2s", "3s"),
+		coffeeshop.WithHandlerTimeout("0s"),
+		coffeeshop.WithMaxHeaderBytes(0),
+	} {
+		if _, err := coffeeshop.New("localhost:0", &coffeeshop.MemoryStore{}, opt); err == nil {
+			t.Error("want error for invalid timeout")
+		}
+	}
+}
+
+func TestServer_ExtendsWriteTimeoutOfEventStreams(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(1)}, "0s", t,
+		coffeeshop.WithTimeouts("30s", "100ms", "0s"),
+	)
+	resp, err := http.Get(shop.URL + "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	for i := 0; i < 2; i++ {
+		time.Sleep(150 * time.Millisecond)
+		restock := sendAs(t, "", http.MethodPut, shop.URL+"products/1/stock", `{"stock": 5}`)
+		restock.Body.Close()
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("want event %d past write timeout, got %v", i+1, err)
+			}
+			if line == "event: product.updated\n" {
+				break
+			}
+		}
+	}
+}
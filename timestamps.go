@@ -0,0 +1,77 @@
+package coffeeshop
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// timeOf returns the time, or zero time if it is nil.
+func timeOf(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+// stamped returns the product added, or replacing the old product,
+// at the time. Replacing products keep the creation time of the old
+// product. Added products keep their own creation time, so restored
+// products aren't recreated, or are created at the time.
+func stamped(p, old Product, at time.Time) Product {
+	switch {
+	case old.CreatedAt != nil:
+		p.CreatedAt = old.CreatedAt
+	case p.CreatedAt == nil:
+		p.CreatedAt = &at
+	}
+	p.UpdatedAt = &at
+	return p
+}
+
+// requestedSince returns the time in the "updated_since" query
+// parameter, in the RFC 3339 format, or zero time if it is absent.
+func requestedSince(r *http.Request) (time.Time, error) {
+	v := r.URL.Query().Get("updated_since")
+	if v == "" {
+		return time.Time{}, nil
+	}
+	since, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid updated_since %q, want RFC 3339 time", v)
+	}
+	return since, nil
+}
+
+// updatedSince filters the products requested by the time of their
+// last update in place. Products updated at the requested time or
+// later are listed. Products never changed by the store aren't.
+func updatedSince(r *http.Request, px []Product) ([]Product, error) {
+	since, err := requestedSince(r)
+	if err != nil || since.IsZero() {
+		return px, err
+	}
+	updated := px[:0]
+	for _, p := range px {
+		if p.UpdatedAt != nil && !p.UpdatedAt.Before(since) {
+			updated = append(updated, p)
+		}
+	}
+	return updated, nil
+}
+
+// ordersUpdatedSince filters the orders requested by the time
+// of their last update in place.
+func ordersUpdatedSince(r *http.Request, ox []Order) ([]Order, error) {
+	since, err := requestedSince(r)
+	if err != nil || since.IsZero() {
+		return ox, err
+	}
+	updated := ox[:0]
+	for _, o := range ox {
+		if !o.UpdatedAt.Before(since) {
+			updated = append(updated, o)
+		}
+	}
+	return updated, nil
+}
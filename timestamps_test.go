@@ -0,0 +1,145 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+var syncStart = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func TestMemoryStore_TimestampsChangesWithItsClock(t *testing.T) {
+	t.Parallel()
+
+	clock := coffeeshop.NewFakeClock(syncStart)
+	store := &coffeeshop.MemoryStore{Clock: clock}
+	p, err := store.Add(coffeeshop.Product{Type: "Tea", Name: "Green"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.CreatedAt == nil || !p.CreatedAt.Equal(syncStart) || p.UpdatedAt == nil || !p.UpdatedAt.Equal(syncStart) {
+		t.Fatalf("want product created and updated at %v, got %v and %v", syncStart, p.CreatedAt, p.UpdatedAt)
+	}
+
+	clock.Advance(time.Minute)
+	p.Name = "Sencha"
+	p, err = store.Update(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute)
+	p, err = store.SetStock(p.ID, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.CreatedAt.Equal(syncStart) {
+		t.Errorf("want creation time kept, got %v", p.CreatedAt)
+	}
+	if want := syncStart.Add(2 * time.Minute); !p.UpdatedAt.Equal(want) {
+		t.Errorf("want product updated at %v, got %v", want, p.UpdatedAt)
+	}
+}
+
+func TestStores_KeepCreationTimeOfReplacedProducts(t *testing.T) {
+	t.Parallel()
+
+	for name, newStore := range map[string]func(t *testing.T, clock coffeeshop.Clock) coffeeshop.Importer{
+		"memory": func(t *testing.T, clock coffeeshop.Clock) coffeeshop.Importer {
+			return &coffeeshop.MemoryStore{Clock: clock}
+		},
+		"redis": func(t *testing.T, clock coffeeshop.Clock) coffeeshop.Importer {
+			store := newRedisStore(t, nil)
+			store.Clock = clock
+			return store
+		},
+		"mongo": func(t *testing.T, clock coffeeshop.Clock) coffeeshop.Importer {
+			store, _ := newMongoStore(t, nil)
+			store.Clock = clock
+			return store
+		},
+	} {
+		newStore := newStore
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			clock := coffeeshop.NewFakeClock(syncStart)
+			store := newStore(t, clock)
+			if _, err := store.PutProduct(coffeeshop.Product{ID: "1", Type: "Tea", Name: "Green"}); err != nil {
+				t.Fatal(err)
+			}
+			clock.Advance(time.Hour)
+			p, err := store.PutProduct(coffeeshop.Product{ID: "1", Type: "Tea", Name: "Sencha"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.CreatedAt == nil || !p.CreatedAt.Equal(syncStart) {
+				t.Errorf("want creation time %v kept, got %v", syncStart, p.CreatedAt)
+			}
+			if want := syncStart.Add(time.Hour); p.UpdatedAt == nil || !p.UpdatedAt.Equal(want) {
+				t.Errorf("want product updated at %v, got %v", want, p.UpdatedAt)
+			}
+		})
+	}
+}
+
+func TestServer_ListsProductsUpdatedSinceTheRequestedTime(t *testing.T) {
+	t.Parallel()
+
+	clock := coffeeshop.NewFakeClock(syncStart)
+	store := &coffeeshop.MemoryStore{Products: stockedInventory(5)}
+	shop := newCoffeShopTestServer(store, "0s", t, coffeeshop.WithClock(clock))
+	clock.Advance(time.Minute)
+	for _, id := range []string{"3", "2"} {
+		resp := sendAs(t, "", http.MethodPut, shop.URL+"products/"+id+"/stock", `{"stock": 1}`)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("want HTTP 200, got %d", resp.StatusCode)
+		}
+		clock.Advance(time.Minute)
+	}
+
+	got := listedIDs(t, shop.URL+"products?updated_since=2024-05-01T12:02:00Z")
+	if want := []string{"2"}; !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+	got = listedIDs(t, shop.URL+"products?updated_since=2024-05-01T12:00:00Z&sort=-updated_at")
+	if want := []string{"2", "3"}; !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+	status, _ := getResponse(t, shop.URL+"products?updated_since=yesterday")
+	if status != http.StatusBadRequest {
+		t.Errorf("want HTTP 400, got %d", status)
+	}
+}
+
+func TestServer_ListsOrdersUpdatedSinceTheRequestedTime(t *testing.T) {
+	t.Parallel()
+
+	clock := coffeeshop.NewFakeClock(syncStart)
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: stockedInventory(5)}, "0s", t, coffeeshop.WithClock(clock))
+	for i := 0; i < 2; i++ {
+		resp := createOrder(t, shop.URL, `{"items":[{"productId":"1","quantity":1}]}`)
+		resp.Body.Close()
+		clock.Advance(time.Second)
+	}
+
+	resp, err := http.Get(shop.URL + "orders?updated_since=2024-05-01T12:00:01Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var orders []coffeeshop.Order
+	if err := json.NewDecoder(resp.Body).Decode(&orders); err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].ID != "2" {
+		t.Fatalf("want order 2, got %+v", orders)
+	}
+	if want := syncStart.Add(time.Second); !orders[0].CreatedAt.Equal(want) {
+		t.Errorf("want order created at %v, got %v", want, orders[0].CreatedAt)
+	}
+}
@@ -0,0 +1,80 @@
+package coffeeshop
+
+// UnitPrice returns the price of the product per kilogram, per litre
+// or per piece, depending on the unit of the product, and the unit
+// the price is for. It returns false if the product has no quantity
+// or unit. Prices are rounded to the nearest minor unit.
+func (p Product) UnitPrice() (Money, Unit, bool) {
+	if p.Quantity <= 0 {
+		return Money{}, "", false
+	}
+	var per Unit
+	var measures int64
+	switch p.Unit {
+	case Gram:
+		per, measures = Kilogram, 1000
+	case Kilogram:
+		per, measures = Kilogram, 1
+	case Millilitre:
+		per, measures = Litre, 1000
+	case Piece:
+		per, measures = Piece, 1
+	default:
+		return Money{}, "", false
+	}
+	q := int64(p.Quantity)
+	amount := (2*p.Price.Amount*measures + q) / (2 * q)
+	return Money{Amount: amount, Currency: p.Price.Currency}, per, true
+}
+
+// unitPrices holds the derived price of the product per unit of
+// measure. Only the field matching the unit of the product is set.
+type unitPrices struct {
+	PricePerKg    any `json:"pricePerKg,omitempty"`
+	PricePerLitre any `json:"pricePerLitre,omitempty"`
+	PricePerUnit  any `json:"pricePerUnit,omitempty"`
+}
+
+// unitPrices returns the derived unit price of the product,
+// encoded like the product price.
+func (cs *Server) unitPrices(p Product) unitPrices {
+	var prices unitPrices
+	price, per, ok := p.UnitPrice()
+	if !ok {
+		return prices
+	}
+	var v any = price
+	if cs.StructuredPrices {
+		v = structuredMoney(price)
+	}
+	switch per {
+	case Kilogram:
+		prices.PricePerKg = v
+	case Litre:
+		prices.PricePerLitre = v
+	case Piece:
+		prices.PricePerUnit = v
+	}
+	return prices
+}
+
+// lessUnitPrice reports whether the product a sorts before b by
+// price per unit of measure. Prices per kilogram, per litre and per
+// piece can't be compared with each other, nor prices in different
+// currencies, so products are grouped by the unit and the currency
+// of their unit price, and compared by price within groups.
+// Products without unit prices sort last.
+func lessUnitPrice(a, b Product) bool {
+	pa, perA, okA := a.UnitPrice()
+	pb, perB, okB := b.UnitPrice()
+	if okA != okB {
+		return okA
+	}
+	if perA != perB {
+		return perA < perB
+	}
+	if pa.Currency != pb.Currency {
+		return pa.Currency < pb.Currency
+	}
+	return pa.Amount < pb.Amount
+}
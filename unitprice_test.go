@@ -0,0 +1,101 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+func TestProduct_UnitPriceDependsOnUnit(t *testing.T) {
+	t.Parallel()
+
+	tcs := []struct {
+		product coffeeshop.Product
+		want    coffeeshop.Money
+		per     coffeeshop.Unit
+	}{
+		{
+			product: coffeeshop.Product{Unit: coffeeshop.Gram, Quantity: 250, Price: coffeeshop.Money{Amount: 799, Currency: "EUR"}},
+			want:    coffeeshop.Money{Amount: 3196, Currency: "EUR"},
+			per:     coffeeshop.Kilogram,
+		},
+		{
+			product: coffeeshop.Product{Unit: coffeeshop.Gram, Quantity: 150, Price: coffeeshop.Money{Amount: 499, Currency: "EUR"}},
+			want:    coffeeshop.Money{Amount: 3327, Currency: "EUR"},
+			per:     coffeeshop.Kilogram,
+		},
+		{
+			product: coffeeshop.Product{Unit: coffeeshop.Millilitre, Quantity: 330, Price: coffeeshop.Money{Amount: 250, Currency: "EUR"}},
+			want:    coffeeshop.Money{Amount: 758, Currency: "EUR"},
+			per:     coffeeshop.Litre,
+		},
+		{
+			product: coffeeshop.Product{Unit: coffeeshop.Piece, Quantity: 6, Price: coffeeshop.Money{Amount: 1000, Currency: "EUR"}},
+			want:    coffeeshop.Money{Amount: 167, Currency: "EUR"},
+			per:     coffeeshop.Piece,
+		},
+	}
+	for _, tc := range tcs {
+		got, per, ok := tc.product.UnitPrice()
+		if !ok || got != tc.want || per != tc.per {
+			t.Errorf("%+v: want %v per %s, got %v per %s (%t)", tc.product, tc.want, tc.per, got, per, ok)
+		}
+	}
+	if _, _, ok := (coffeeshop.Product{Price: coffeeshop.Money{Amount: 100}}).UnitPrice(); ok {
+		t.Error("want no unit price of product without quantity")
+	}
+}
+
+func TestServer_ReturnsAndSortsByPricePerUnit(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: coffeeshop.Products{
+		"1": {ID: "1", Type: "Coffee", Name: "Large", Unit: coffeeshop.Kilogram, Quantity: 1, Price: coffeeshop.Money{Amount: 2500, Currency: "EUR"}},
+		"2": {ID: "2", Type: "Coffee", Name: "Small", Unit: coffeeshop.Gram, Quantity: 250, Price: coffeeshop.Money{Amount: 500, Currency: "EUR"}},
+		"3": {ID: "3", Type: "Mug", Name: "Mug", Price: coffeeshop.Money{Amount: 900, Currency: "EUR"}},
+	}}
+	shop := newCoffeShopTestServer(store, "0s", t)
+	resp, err := http.Get(shop.URL + "products?sort=price_per_unit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200, got %d", resp.StatusCode)
+	}
+	var got []struct {
+		ID         string `json:"id"`
+		PricePerKg string `json:"pricePerKg"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		ID         string `json:"id"`
+		PricePerKg string `json:"pricePerKg"`
+	}{{"2", "20.00"}, {"1", "25.00"}, {"3", ""}}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestServer_GroupsProductsByUnitWhenSortingByPricePerUnit(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: coffeeshop.Products{
+		"1": {ID: "1", Type: "Coffee", Name: "Beans", Unit: coffeeshop.Kilogram, Quantity: 1, Price: coffeeshop.Money{Amount: 2500, Currency: "USD"}},
+		"2": {ID: "2", Type: "Tea", Name: "Bags", Unit: coffeeshop.Piece, Quantity: 20, Price: coffeeshop.Money{Amount: 400, Currency: "USD"}},
+		"3": {ID: "3", Type: "Milk", Name: "Oat", Unit: coffeeshop.Millilitre, Quantity: 1000, Price: coffeeshop.Money{Amount: 300, Currency: "USD"}},
+		"4": {ID: "4", Type: "Coffee", Name: "Ground", Unit: coffeeshop.Gram, Quantity: 250, Price: coffeeshop.Money{Amount: 800, Currency: "USD"}},
+		"5": {ID: "5", Type: "Tea", Name: "Loose", Unit: coffeeshop.Piece, Quantity: 1, Price: coffeeshop.Money{Amount: 10, Currency: "USD"}},
+	}}
+	shop := newCoffeShopTestServer(store, "0s", t)
+	got := listedIDs(t, shop.URL+"products?sort=price_per_unit")
+	want := []string{"1", "4", "3", "5", "2"}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
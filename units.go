@@ -0,0 +1,119 @@
+package coffeeshop
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Unit is the unit of the quantity of a product.
+type Unit string
+
+// Units of product quantities.
+const (
+	Gram       Unit = "gram"
+	Kilogram   Unit = "kilogram"
+	Piece      Unit = "piece"
+	Millilitre Unit = "millilitre"
+)
+
+// Litre is the unit of prices of products measured in millilitres.
+const Litre Unit = "litre"
+
+var knownUnits = []Unit{Gram, Kilogram, Piece, Millilitre}
+
+// unitAliases maps other spellings of units found
+// in older inventories to the units.
+var unitAliases = map[string]Unit{
+	"g":           Gram,
+	"grams":       Gram,
+	"kg":          Kilogram,
+	"kilograms":   Kilogram,
+	"pc":          Piece,
+	"pcs":         Piece,
+	"pieces":      Piece,
+	"ml":          Millilitre,
+	"millilitres": Millilitre,
+	"milliliter":  Millilitre,
+	"milliliters": Millilitre,
+}
+
+// ParseUnit returns the unit named by s, accepting plurals and
+// abbreviations like "grams" or "kg". Unknown units are returned
+// unchanged and reported by Product.Validate.
+func ParseUnit(s string) Unit {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if u, ok := unitAliases[name]; ok {
+		return u
+	}
+	if u := Unit(name); u.Known() {
+		return u
+	}
+	return Unit(s)
+}
+
+// Known reports whether the unit is one of the units of products.
+func (u Unit) Known() bool {
+	for _, known := range knownUnits {
+		if u == known {
+			return true
+		}
+	}
+	return false
+}
+
+// UnmarshalText decodes the unit, accepting other spellings.
+func (u *Unit) UnmarshalText(text []byte) error {
+	*u = ParseUnit(string(text))
+	return nil
+}
+
+// Quantity is the amount of a product in a package,
+// measured in the unit of the product.
+type Quantity int
+
+// UnmarshalJSON decodes the quantity either from a number
+// or, as written by older versions, from a string like "1000".
+func (q *Quantity) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		*q = Quantity(n)
+		return nil
+	}
+	// The decoder doesn't always add the field to errors
+	// returned by unmarshalers, so they name it.
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return &json.UnmarshalTypeError{Value: string(data), Type: reflect.TypeOf(n), Field: "quantity"}
+	}
+	parsed, err := ParseQuantity(s)
+	if err != nil {
+		return &json.UnmarshalTypeError{Value: fmt.Sprintf("string %q", s), Type: reflect.TypeOf(n), Field: "quantity"}
+	}
+	*q = parsed
+	return nil
+}
+
+// ParseQuantity parses the quantity in the "1000" format.
+// The empty string is the zero quantity.
+func ParseQuantity(s string) (Quantity, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quantity %q", s)
+	}
+	return Quantity(n), nil
+}
+
+// String returns the quantity in the "1000" format,
+// or the empty string for the zero quantity.
+func (q Quantity) String() string {
+	if q == 0 {
+		return ""
+	}
+	return strconv.Itoa(int(q))
+}
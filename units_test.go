@@ -0,0 +1,68 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+func TestProduct_DecodesQuantityFromNumberOrString(t *testing.T) {
+	t.Parallel()
+
+	for _, body := range []string{
+		`{"id":"1","unit":"gram","quantity":250}`,
+		`{"id":"1","unit":"grams","quantity":"250"}`,
+		`{"id":"1","unit":"g","quantity":"250"}`,
+	} {
+		var p coffeeshop.Product
+		if err := json.Unmarshal([]byte(body), &p); err != nil {
+			t.Fatalf("%s: %v", body, err)
+		}
+		want := coffeeshop.Product{ID: "1", Unit: coffeeshop.Gram, Quantity: 250}
+		if !cmp.Equal(want, p) {
+			t.Errorf("%s: %s", body, cmp.Diff(want, p))
+		}
+	}
+}
+
+func TestProduct_EncodesQuantityAsNumber(t *testing.T) {
+	t.Parallel()
+
+	data, err := json.Marshal(coffeeshop.Product{ID: "1", Unit: coffeeshop.Kilogram, Quantity: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"id":"1","type":"","brand":"","name":"","unit":"kilogram","quantity":1,"price":"0.00","stock":0}`
+	if string(data) != want {
+		t.Errorf("want %s, got %s", want, data)
+	}
+}
+
+func TestProduct_RejectsQuantityNotANumber(t *testing.T) {
+	t.Parallel()
+
+	var p coffeeshop.Product
+	err := json.Unmarshal([]byte(`{"quantity":"a bag"}`), &p)
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) || typeErr.Field != "quantity" {
+		t.Errorf("want type error of field quantity, got %v", err)
+	}
+}
+
+func TestParseUnit_KeepsUnknownUnits(t *testing.T) {
+	t.Parallel()
+
+	for s, want := range map[string]coffeeshop.Unit{
+		"kg":         coffeeshop.Kilogram,
+		"Millilitre": coffeeshop.Millilitre,
+		"pcs":        coffeeshop.Piece,
+		"bag":        "bag",
+	} {
+		if got := coffeeshop.ParseUnit(s); got != want {
+			t.Errorf("%q: want %q, got %q", s, want, got)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package coffeeshop
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// Validator is implemented by values able to check themselves.
+type Validator interface {
+	// Validate returns a *ValidationError listing all problems
+	// of the value, or nil if the value is valid.
+	Validate() error
+}
+
+// ValidationError lists all problems with fields of an invalid value.
+// It wraps the error telling what is invalid, for example
+// ErrInvalidProduct.
+type ValidationError struct {
+	Err    error
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%v: %s", e.Err, strings.Join(e.problems(), "; "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// problems returns messages describing problems with the fields.
+func (e *ValidationError) problems() []string {
+	problems := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		problems = append(problems, f.Message)
+	}
+	return problems
+}
+
+// Validate checks that the product has an ID, a type and a name,
+// an SKU without spaces, a valid EAN, a non-negative quantity, a known unit, a non-negative price in
+// a currency with a three letter code, non-negative stock, an
+// intensity from 1 to 10, non-empty flavours and properties with
+// unique names. SKU, EAN, quantity, unit, currency and intensity
+// can be empty.
+// Servers also check values of properties against their
+// PropertySchema.
+func (p Product) Validate() error {
+	var fields []FieldError
+	problem := func(field, format string, args ...any) {
+		fields = append(fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+	if p.ID == "" {
+		problem("id", "missing id")
+	}
+	if p.Type == "" {
+		problem("type", "missing type")
+	}
+	if p.Name == "" {
+		problem("name", "missing name")
+	}
+	if !validSKU(p.SKU) {
+		problem("sku", "invalid sku %q, want at most %d characters without spaces", p.SKU, maxSKULength)
+	}
+	if p.EAN != "" && !validEAN(p.EAN) {
+		problem("ean", "invalid ean %q, want EAN-8 or EAN-13 with check digit", p.EAN)
+	}
+	if p.Quantity < 0 {
+		problem("quantity", "negative quantity %d", p.Quantity)
+	}
+	if p.Unit != "" && !p.Unit.Known() {
+		problem("unit", "unknown unit %q, want one of %s", p.Unit, unitNames())
+	}
+	if p.Price.Amount < 0 {
+		problem("price", "negative price %s", p.Price)
+	}
+	if c := p.Price.Currency; c != "" && !currencyCode(c) {
+		problem("price.currency", "invalid currency %q", c)
+	}
+	if p.Stock < 0 {
+		problem("stock", "negative stock %d", p.Stock)
+	}
+	if p.Intensity < 0 || p.Intensity > maxIntensity {
+		problem("intensity", "invalid intensity %d, want 1 to %d", p.Intensity, maxIntensity)
+	}
+	for i, f := range p.Flavours {
+		if strings.TrimSpace(f) == "" {
+			problem(fmt.Sprintf("flavours[%d]", i), "missing flavour")
+		}
+	}
+	seen := make(map[string]bool, len(p.Properties))
+	for i, prop := range p.Properties {
+		name := strings.ToLower(strings.TrimSpace(prop.Name))
+		switch {
+		case name == "":
+			problem(fmt.Sprintf("properties[%d].name", i), "missing property name")
+		case seen[name]:
+			problem(fmt.Sprintf("properties[%d].name", i), "duplicate property %q", prop.Name)
+		}
+		seen[name] = true
+	}
+	for lang := range p.Descriptions {
+		if strings.TrimSpace(lang) == "" {
+			problem("descriptions", "missing language of description")
+		}
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Err: ErrInvalidProduct, Fields: fields}
+	}
+	return nil
+}
+
+// unitNames returns names of the known units separated by commas.
+func unitNames() string {
+	names := make([]string, 0, len(knownUnits))
+	for _, u := range knownUnits {
+		names = append(names, string(u))
+	}
+	return strings.Join(names, ", ")
+}
+
+// currencyCode reports whether the code looks like an ISO 4217 code.
+func currencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// validateProduct returns problems making the product invalid.
+func validateProduct(p Validator) []string {
+	var verr *ValidationError
+	if !errors.As(p.Validate(), &verr) {
+		return nil
+	}
+	return verr.problems()
+}
+
+// validate responds with 400 Bad Request listing problems of the
+// value, together with problems found decoding it, and returns false
+// if there are any. Problems of the value with fields that couldn't
+// be decoded aren't repeated.
+func validate(w http.ResponseWriter, r *http.Request, v Validator, decoding ...FieldError) bool {
+	err := v.Validate()
+	var verr *ValidationError
+	if err != nil && !errors.As(err, &verr) {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return false
+	}
+	if verr == nil && len(decoding) == 0 {
+		return true
+	}
+	code := errorCode(http.StatusBadRequest)
+	fields := append([]FieldError(nil), decoding...)
+	problems := make([]string, 0, len(decoding))
+	for _, f := range decoding {
+		problems = append(problems, f.Field+": "+f.Message)
+	}
+	if verr != nil {
+		for _, m := range errorMappings {
+			if errors.Is(verr.Err, m.err) {
+				code = m.code
+			}
+		}
+		for _, f := range verr.Fields {
+			if !slices.ContainsFunc(decoding, func(d FieldError) bool { return d.Field == f.Field }) {
+				fields = append(fields, f)
+				problems = append(problems, f.Message)
+			}
+		}
+	}
+	detail := newErrorDetail(r, code, strings.Join(problems, "; "))
+	detail.Fields = fields
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writeJSON(w, r, http.StatusBadRequest, ErrorResponse{Error: detail})
+	return false
+}
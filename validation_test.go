@@ -0,0 +1,113 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+func TestProduct_ValidateAcceptsSampleProducts(t *testing.T) {
+	t.Parallel()
+
+	for id, p := range inventory {
+		if err := p.Validate(); err != nil {
+			t.Errorf("product %s: %v", id, err)
+		}
+	}
+}
+
+func TestProduct_ValidateReportsAllViolations(t *testing.T) {
+	t.Parallel()
+
+	p := coffeeshop.Product{
+		Quantity: -1,
+		Unit:     "bag",
+		Price:    coffeeshop.Money{Amount: -1, Currency: "eur"},
+		Stock:    -1,
+		Properties: []coffeeshop.Property{
+			{Name: "flavour", Value: "Nuts"},
+			{Name: "Flavour", Value: "Caramel"},
+			{Value: "Medium"},
+		},
+	}
+	err := p.Validate()
+	if !errors.Is(err, coffeeshop.ErrInvalidProduct) {
+		t.Fatalf("want ErrInvalidProduct, got %v", err)
+	}
+	var verr *coffeeshop.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("want *ValidationError, got %T", err)
+	}
+	var fields []string
+	for _, f := range verr.Fields {
+		fields = append(fields, f.Field)
+	}
+	want := []string{
+		"id", "type", "name", "quantity", "unit", "price", "price.currency", "stock",
+		"properties[1].name", "properties[2].name",
+	}
+	if !cmp.Equal(want, fields) {
+		t.Error(cmp.Diff(want, fields))
+	}
+}
+
+func TestServer_RejectsInvalidProductWithFieldErrors(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: coffeeshop.Products{"1": inventory["1"]}}
+	shop := newCoffeShopTestServer(store, "0s", t)
+	resp := putIfMatch(t, shop.URL+"products/1", "", `{"id":"1","type":"Coffee","name":"Beans","quantity":-5,"unit":"bag"}`)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("want HTTP 400, got %d", resp.StatusCode)
+	}
+	var e coffeeshop.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+		t.Fatal(err)
+	}
+	want := []coffeeshop.FieldError{
+		{Field: "quantity", Message: "negative quantity -5"},
+		{Field: "unit", Message: `unknown unit "bag", want one of gram, kilogram, piece, millilitre`},
+	}
+	if e.Error.Code != "invalid_product" || !cmp.Equal(want, e.Error.Fields) {
+		t.Errorf("want invalid_product with %+v, got %s with %+v", want, e.Error.Code, e.Error.Fields)
+	}
+	if got := store.GetAll()[0].Name; got != inventory["1"].Name {
+		t.Errorf("want product unchanged, got name %q", got)
+	}
+}
+
+func TestServer_ReportsAllProblemsOfProductThatCantBeDecoded(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: coffeeshop.Products{"1": inventory["1"]}}
+	shop := newCoffeShopTestServer(store, "0s", t)
+	body := `{"id":"1","type":"Coffee","quantity":"lots","price":"cheap","unit":"bag","stock":-1,"colour":"red"}`
+	resp := putIfMatch(t, shop.URL+"products/1", "", body)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("want HTTP 400, got %d", resp.StatusCode)
+	}
+	var e coffeeshop.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+		t.Fatal(err)
+	}
+	want := []coffeeshop.FieldError{
+		{Field: "quantity", Message: `must be a number, got string "lots"`},
+		{Field: "price", Message: `invalid amount "cheap"`},
+		{Field: "colour", Message: "unknown field"},
+		{Field: "name", Message: "missing name"},
+		{Field: "unit", Message: `unknown unit "bag", want one of gram, kilogram, piece, millilitre`},
+		{Field: "stock", Message: "negative stock -1"},
+	}
+	if !cmp.Equal(want, e.Error.Fields) {
+		t.Error(cmp.Diff(want, e.Error.Fields))
+	}
+	if got := store.GetAll()[0].Name; got != inventory["1"].Name {
+		t.Errorf("want product unchanged, got name %q", got)
+	}
+}
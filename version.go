@@ -0,0 +1,58 @@
+package coffeeshop
+
+import (
+	"net/http"
+	"runtime/debug"
+)
+
+// Version is the version of the coffeeshop server,
+// set at build time with
+//
+//	go build -ldflags "-X github.com/qba73/coffeeshop.Version=v1.2.3"
+//
+// Without it, the version of the main module is used
+// when the binary was built with go install.
+var Version = "dev"
+
+// BuildInfo describes the build of the running binary.
+type BuildInfo struct {
+	Version string `json:"version"`
+	// Commit is the VCS revision the binary was built from.
+	Commit string `json:"commit,omitempty"`
+	// CommitTime is the time of the commit in RFC 3339 format.
+	CommitTime string `json:"commitTime,omitempty"`
+	// Dirty reports whether the working tree had
+	// uncommitted changes.
+	Dirty     bool   `json:"dirty"`
+	GoVersion string `json:"goVersion"`
+}
+
+// ReadBuildInfo returns the build information embedded in the
+// binary by the Go toolchain.
+func ReadBuildInfo() BuildInfo {
+	info := BuildInfo{Version: Version}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info.GoVersion = bi.GoVersion
+	if info.Version == "dev" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		info.Version = bi.Main.Version
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Commit = s.Value
+		case "vcs.time":
+			info.CommitTime = s.Value
+		case "vcs.modified":
+			info.Dirty = s.Value == "true"
+		}
+	}
+	return info
+}
+
+// GetVersion returns the build information of the server.
+func (cs *Server) GetVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, ReadBuildInfo())
+}
@@ -0,0 +1,34 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"testing"
+
+	"github.com/qba73/coffeeshop"
+)
+
+func TestServer_ReturnsBuildInfoAtVersion(t *testing.T) {
+	t.Parallel()
+
+	shop := newCoffeShopTestServer(&coffeeshop.MemoryStore{Products: inventory}, "0s", t)
+	resp, err := http.Get(shop.URL + "version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200, got %d", resp.StatusCode)
+	}
+	var got coffeeshop.BuildInfo
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != coffeeshop.Version {
+		t.Errorf("want version %q, got %q", coffeeshop.Version, got.Version)
+	}
+	if got.GoVersion != runtime.Version() {
+		t.Errorf("want Go version %q, got %q", runtime.Version(), got.GoVersion)
+	}
+}
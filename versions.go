@@ -0,0 +1,72 @@
+package coffeeshop
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// apiVersionHeader is the header used by clients to request
+// a version of the API on unversioned paths, and by the server
+// to report the version which served the request.
+const apiVersionHeader = "API-Version"
+
+// DefaultAPIVersion is the version of the API served on
+// unversioned paths, for example /products.
+const DefaultAPIVersion = "v1"
+
+// apiVersions registers routes of API versions in the order they
+// were introduced. Each version is served under its name, for
+// example /v1/products. A new version with breaking changes
+// registers routes of the previous version and then replaces
+// handlers of the routes that changed.
+var apiVersions = []struct {
+	name   string
+	routes func(cs *Server, r chi.Router)
+}{
+	{"v1", (*Server).v1Routes},
+}
+
+// WithAPIVersion configures the version of the API served on
+// unversioned paths to clients not sending the API-Version header.
+func WithAPIVersion(version string) Option {
+	return func(s *Server) error {
+		for _, v := range apiVersions {
+			if v.name == version {
+				s.APIVersion = version
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown API version %q", version)
+	}
+}
+
+// mountAPIVersions mounts routes of every API version under the
+// version prefix. Unversioned paths are aliases of the version
+// requested in the API-Version header or the configured version.
+func (cs *Server) mountAPIVersions(r chi.Router) {
+	versions := make(map[string]http.Handler, len(apiVersions))
+	for _, v := range apiVersions {
+		vr := chi.NewRouter()
+		vr.NotFound(notFound)
+		vr.MethodNotAllowed(methodNotAllowed)
+		vr.Use(middleware.SetHeader(apiVersionHeader, v.name))
+		v.routes(cs, vr)
+		versions[v.name] = vr
+		r.Mount("/"+v.name, vr)
+	}
+	r.Mount("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version := r.Header.Get(apiVersionHeader)
+		if version == "" {
+			version = cs.APIVersion
+		}
+		h, ok := versions[version]
+		if !ok {
+			writeError(w, r, http.StatusBadRequest, fmt.Sprintf("unsupported API version %q", version))
+			return
+		}
+		h.ServeHTTP(w, r)
+	}))
+}
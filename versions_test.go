@@ -0,0 +1,99 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+func TestServer_ServesAPIUnderVersionPrefixAndLegacyPaths(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	var bodies []coffeeshop.Product
+	for _, path := range []string{"v1/products/2", "products/2"} {
+		resp, err := http.Get(shop.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var p coffeeshop.Product
+		err = json.NewDecoder(resp.Body).Decode(&p)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: want HTTP 200OK, got %d", path, resp.StatusCode)
+		}
+		if got := resp.Header.Get("API-Version"); got != "v1" {
+			t.Errorf("%s: want API-Version v1, got %q", path, got)
+		}
+		bodies = append(bodies, p)
+	}
+	if !cmp.Equal(bodies[0], bodies[1]) {
+		t.Error(cmp.Diff(bodies[0], bodies[1]))
+	}
+}
+
+func TestServer_NegotiatesAPIVersionOnLegacyPaths(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	tests := map[string]int{
+		"v1": http.StatusOK,
+		"v9": http.StatusBadRequest,
+	}
+	for version, want := range tests {
+		req, err := http.NewRequest(http.MethodGet, shop.URL+"products", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("API-Version", version)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("API-Version %s: want HTTP %d, got %d", version, want, resp.StatusCode)
+		}
+	}
+}
+
+func TestServer_DoesNotVersionInfraRoutes(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp, err := http.Get(shop.URL + "v1/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("want HTTP 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestWithAPIVersion_FailsOnUnknownVersion(t *testing.T) {
+	t.Parallel()
+
+	_, err := coffeeshop.New(":0", &coffeeshop.MemoryStore{}, coffeeshop.WithAPIVersion("v2"))
+	if err == nil {
+		t.Error("want error on unknown API version")
+	}
+}
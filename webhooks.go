@@ -0,0 +1,409 @@
+package coffeeshop
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Webhook represents a URL receiving events. A webhook
+// with no event types receives all events.
+type Webhook struct {
+	ID        string      `json:"id"`
+	URL       string      `json:"url"`
+	Events    []EventType `json:"events,omitempty"`
+	Secret    string      `json:"secret,omitempty"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+func (h Webhook) wants(t EventType) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, e := range h.Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+// DeliveryStatus represents the state of a webhook delivery.
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// Delivery records attempts to deliver an event to a webhook.
+type Delivery struct {
+	ID         string         `json:"id"`
+	WebhookID  string         `json:"webhookId"`
+	EventID    string         `json:"eventId"`
+	EventType  EventType      `json:"eventType"`
+	Status     DeliveryStatus `json:"status"`
+	Attempts   int            `json:"attempts"`
+	StatusCode int            `json:"statusCode,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	UpdatedAt  time.Time      `json:"updatedAt"`
+}
+
+// Headers of webhook requests.
+const (
+	webhookEventHeader     = "X-Coffeeshop-Event"
+	webhookDeliveryHeader  = "X-Coffeeshop-Delivery"
+	webhookSignatureHeader = "X-Coffeeshop-Signature"
+)
+
+// maxWebhookDeliveries limits deliveries kept in the log of a webhook.
+const maxWebhookDeliveries = 100
+
+// deliveryLog is a ring buffer of the latest deliveries to a webhook.
+// Once it's full, new deliveries replace the oldest ones.
+type deliveryLog struct {
+	entries []Delivery
+	// oldest is the index of the oldest delivery of a full log.
+	oldest int
+}
+
+func (l *deliveryLog) add(d Delivery) {
+	if len(l.entries) < maxWebhookDeliveries {
+		l.entries = append(l.entries, d)
+		return
+	}
+	l.entries[l.oldest] = d
+	l.oldest = (l.oldest + 1) % len(l.entries)
+}
+
+// update replaces the delivery with the same ID,
+// unless it was replaced by a newer delivery.
+func (l *deliveryLog) update(d Delivery) {
+	for i, stored := range l.entries {
+		if stored.ID == d.ID {
+			l.entries[i] = d
+			return
+		}
+	}
+}
+
+// list returns deliveries oldest first.
+func (l *deliveryLog) list() []Delivery {
+	dx := make([]Delivery, 0, len(l.entries))
+	dx = append(dx, l.entries[l.oldest:]...)
+	return append(dx, l.entries[:l.oldest]...)
+}
+
+// webhookRegistry holds registered webhooks and their deliveries.
+type webhookRegistry struct {
+	mx             sync.RWMutex
+	lastID         int
+	lastDeliveryID int
+	hooks          map[string]Webhook
+	deliveries     map[string]*deliveryLog
+}
+
+func (wr *webhookRegistry) add(h Webhook) Webhook {
+	wr.mx.Lock()
+	defer wr.mx.Unlock()
+	if wr.hooks == nil {
+		wr.hooks = make(map[string]Webhook)
+	}
+	wr.lastID++
+	h.ID = strconv.Itoa(wr.lastID)
+	wr.hooks[h.ID] = h
+	return h
+}
+
+func (wr *webhookRegistry) remove(id string) bool {
+	wr.mx.Lock()
+	defer wr.mx.Unlock()
+	if _, ok := wr.hooks[id]; !ok {
+		return false
+	}
+	delete(wr.hooks, id)
+	delete(wr.deliveries, id)
+	return true
+}
+
+func (wr *webhookRegistry) get(id string) (Webhook, bool) {
+	wr.mx.RLock()
+	defer wr.mx.RUnlock()
+	h, ok := wr.hooks[id]
+	return h, ok
+}
+
+// list returns webhooks ordered by ID.
+func (wr *webhookRegistry) list() []Webhook {
+	wr.mx.RLock()
+	defer wr.mx.RUnlock()
+	hooks := make([]Webhook, 0, len(wr.hooks))
+	for _, h := range wr.hooks {
+		hooks = append(hooks, h)
+	}
+	sort.Slice(hooks, func(i, j int) bool {
+		a, _ := strconv.Atoi(hooks[i].ID)
+		b, _ := strconv.Atoi(hooks[j].ID)
+		return a < b
+	})
+	return hooks
+}
+
+func (wr *webhookRegistry) newDelivery(h Webhook, e Event, now time.Time) Delivery {
+	wr.mx.Lock()
+	defer wr.mx.Unlock()
+	if wr.deliveries == nil {
+		wr.deliveries = make(map[string]*deliveryLog)
+	}
+	wr.lastDeliveryID++
+	d := Delivery{
+		ID:        strconv.Itoa(wr.lastDeliveryID),
+		WebhookID: h.ID,
+		EventID:   e.ID,
+		EventType: e.Type,
+		Status:    DeliveryPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	l, ok := wr.deliveries[h.ID]
+	if !ok {
+		l = &deliveryLog{}
+		wr.deliveries[h.ID] = l
+	}
+	l.add(d)
+	return d
+}
+
+func (wr *webhookRegistry) updateDelivery(d Delivery) {
+	wr.mx.Lock()
+	defer wr.mx.Unlock()
+	if l, ok := wr.deliveries[d.WebhookID]; ok {
+		l.update(d)
+	}
+}
+
+func (wr *webhookRegistry) deliveriesOf(id string) []Delivery {
+	wr.mx.RLock()
+	defer wr.mx.RUnlock()
+	l, ok := wr.deliveries[id]
+	if !ok {
+		return []Delivery{}
+	}
+	return l.list()
+}
+
+// WithWebhookRetries configures how many times a failed
+// webhook delivery is retried.
+func WithWebhookRetries(n int) Option {
+	return func(s *Server) error {
+		if n < 0 {
+			return fmt.Errorf("invalid number of webhook retries %d", n)
+		}
+		s.WebhookRetries = n
+		return nil
+	}
+}
+
+// WithWebhookBackoff configures the delay before the first retry
+// of a failed webhook delivery. The delay doubles with every retry.
+func WithWebhookBackoff(d string) Option {
+	return func(s *Server) error {
+		backoff, err := time.ParseDuration(d)
+		if err != nil {
+			return err
+		}
+		if backoff <= 0 {
+			return fmt.Errorf("invalid webhook backoff %s", d)
+		}
+		s.WebhookBackoff = backoff
+		return nil
+	}
+}
+
+// signPayload returns the hex encoded HMAC-SHA256
+// of the payload prefixed with the algorithm name.
+func signPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// dispatchEvents sends product and order events to registered
+// webhooks until the server shuts down.
+func (cs *Server) dispatchEvents() {
+	orders, unsubscribeOrders := cs.orderEvents.Subscribe()
+	var products <-chan Event
+	unsubscribeProducts := func() {}
+	if n, ok := storeAs[Notifier](cs.Store); ok {
+		products, unsubscribeProducts = n.Subscribe()
+	}
+	replayer, _ := storeAs[EventReplayer](cs.Store)
+	go func() {
+		defer unsubscribeOrders()
+		defer unsubscribeProducts()
+		// Events dropped while dispatching fell behind
+		// are read again after the last ones received.
+		var lastOrder, lastProduct string
+		for {
+			var events []Event
+			select {
+			case <-cs.shuttingDown.Done():
+				return
+			case e := <-orders:
+				events, _ = missedEvents(&cs.orderEvents, lastOrder, e)
+				lastOrder = e.ID
+			case e := <-products:
+				events = []Event{e}
+				if replayer != nil {
+					events, _ = missedEvents(replayer, lastProduct, e)
+				}
+				lastProduct = e.ID
+			}
+			for _, e := range events {
+				for _, h := range cs.webhooks.list() {
+					if h.wants(e.Type) {
+						go cs.deliver(h, e)
+					}
+				}
+			}
+		}
+	}()
+}
+
+// deliver posts the event to the webhook, retrying with
+// exponential backoff until it succeeds or retries run out.
+func (cs *Server) deliver(h Webhook, e Event) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	d := cs.webhooks.newDelivery(h, e, cs.Clock.Now())
+	backoff := cs.WebhookBackoff
+	for {
+		d.Attempts++
+		d.StatusCode, err = cs.post(h, d, payload)
+		d.Error = ""
+		if err != nil {
+			d.Error = err.Error()
+		}
+		d.UpdatedAt = cs.Clock.Now()
+		if err == nil {
+			d.Status = DeliverySucceeded
+		} else if d.Attempts > cs.WebhookRetries {
+			d.Status = DeliveryFailed
+		}
+		cs.webhooks.updateDelivery(d)
+		if d.Status != DeliveryPending {
+			return
+		}
+		select {
+		case <-cs.shuttingDown.Done():
+			return
+		case <-cs.Clock.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (cs *Server) post(h Webhook, d Delivery, payload []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookEventHeader, string(d.EventType))
+	req.Header.Set(webhookDeliveryHeader, d.ID)
+	req.Header.Set(webhookSignatureHeader, signPayload(h.Secret, payload))
+	resp, err := cs.webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// CreateWebhook registers the webhook. The secret used to sign
+// payloads is generated unless provided and returned only
+// in the response to this request.
+func (cs *Server) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		URL    string      `json:"url"`
+		Events []EventType `json:"events"`
+		Secret string      `json:"secret"`
+	}
+	if !cs.decodeJSON(w, r, &req, "invalid webhook") {
+		return
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		writeError(w, r, http.StatusBadRequest, "invalid webhook url")
+		return
+	}
+	if req.Secret == "" {
+		req.Secret, err = newWebhookSecret()
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "internal error")
+			return
+		}
+	}
+	h := cs.webhooks.add(Webhook{
+		URL:       req.URL,
+		Events:    req.Events,
+		Secret:    req.Secret,
+		CreatedAt: cs.Clock.Now(),
+	})
+	w.Header().Set("Location", resourcePath(r, "/webhooks/"+h.ID))
+	writeJSON(w, r, http.StatusCreated, h)
+}
+
+func (cs *Server) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks := cs.webhooks.list()
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+	writeJSON(w, r, http.StatusOK, hooks)
+}
+
+func (cs *Server) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if !cs.webhooks.remove(chi.URLParam(r, "webhookID")) {
+		writeError(w, r, http.StatusNotFound, "webhook not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetWebhookDeliveries returns the delivery log of the webhook,
+// keeping the last maxWebhookDeliveries deliveries, oldest first.
+func (cs *Server) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "webhookID")
+	if _, ok := cs.webhooks.get(id); !ok {
+		writeError(w, r, http.StatusNotFound, "webhook not found")
+		return
+	}
+	writeJSON(w, r, http.StatusOK, cs.webhooks.deliveriesOf(id))
+}
@@ -0,0 +1,270 @@
+package coffeeshop_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qba73/coffeeshop"
+)
+
+func registerWebhook(t *testing.T, shopURL, body string) coffeeshop.Webhook {
+	t.Helper()
+	resp, err := http.Post(shopURL+"webhooks", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("want status 201, got %d", resp.StatusCode)
+	}
+	var h coffeeshop.Webhook
+	if err := json.NewDecoder(resp.Body).Decode(&h); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestServer_DeliversSignedEventsToWebhook(t *testing.T) {
+	t.Parallel()
+
+	type delivery struct {
+		event     string
+		signature string
+		body      []byte
+	}
+	received := make(chan delivery, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- delivery{
+			event:     r.Header.Get("X-Coffeeshop-Event"),
+			signature: r.Header.Get("X-Coffeeshop-Signature"),
+			body:      body,
+		}
+	}))
+	defer receiver.Close()
+
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(10),
+	}
+	shop := newCoffeShopTestServer(store, "10ms", t, coffeeshop.WithOrderInterval("1h"))
+	registerWebhook(t, shop.URL, `{"url":"`+receiver.URL+`","events":["order.created"],"secret":"s3cret"}`)
+
+	createOrder(t, shop.URL, `{"items":[{"productId":"1","quantity":1}]}`).Body.Close()
+
+	select {
+	case d := <-received:
+		if d.event != "order.created" {
+			t.Errorf("want order.created event, got %q", d.event)
+		}
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write(d.body)
+		want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		if d.signature != want {
+			t.Errorf("want signature %s, got %s", want, d.signature)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for webhook delivery")
+	}
+}
+
+func TestServer_RetriesFailedWebhookDeliveries(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer receiver.Close()
+
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(10),
+	}
+	shop := newCoffeShopTestServer(store, "10ms", t, coffeeshop.WithWebhookBackoff("10ms"))
+	h := registerWebhook(t, shop.URL, `{"url":"`+receiver.URL+`","events":["product.updated"]}`)
+	if h.Secret == "" {
+		t.Error("want generated secret")
+	}
+
+	req, err := http.NewRequest(http.MethodPut, shop.URL+"products/1/stock", strings.NewReader(`{"stock":5}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(shop.URL + "webhooks/" + h.ID + "/deliveries")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var deliveries []coffeeshop.Delivery
+		err = json.NewDecoder(resp.Body).Decode(&deliveries)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deliveries) == 1 && deliveries[0].Status == coffeeshop.DeliverySucceeded {
+			if deliveries[0].Attempts != 3 {
+				t.Errorf("want 3 attempts, got %d", deliveries[0].Attempts)
+			}
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("timeout waiting for successful delivery")
+}
+
+func TestServer_KeepsLastHundredWebhookDeliveries(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer receiver.Close()
+
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(10),
+	}
+	shop := newCoffeShopTestServer(store, "0s", t)
+	h := registerWebhook(t, shop.URL, `{"url":"`+receiver.URL+`","events":["product.updated"]}`)
+	const updates = 105
+	for i := 0; i < updates; i++ {
+		req, err := http.NewRequest(http.MethodPut, shop.URL+"products/1/stock", strings.NewReader(fmt.Sprintf(`{"stock":%d}`, i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for calls.Load() < updates {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %d deliveries, got %d", updates, calls.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	resp, err := http.Get(shop.URL + "webhooks/" + h.ID + "/deliveries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var deliveries []coffeeshop.Delivery
+	if err := json.NewDecoder(resp.Body).Decode(&deliveries); err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 100 {
+		t.Errorf("want the last 100 deliveries, got %d", len(deliveries))
+	}
+}
+
+func TestServer_RejectsWebhookWithInvalidURL(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp, err := http.Post(shop.URL+"webhooks", "application/json", strings.NewReader(`{"url":"ftp://example.com"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("want status 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_ListsWebhooksWithoutSecrets(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	registerWebhook(t, shop.URL, `{"url":"http://example.com/hook","secret":"s3cret"}`)
+
+	resp, err := http.Get(shop.URL + "webhooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var hooks []coffeeshop.Webhook
+	if err := json.NewDecoder(resp.Body).Decode(&hooks); err != nil {
+		t.Fatal(err)
+	}
+	if len(hooks) != 1 || hooks[0].Secret != "" {
+		t.Errorf("want one webhook without secret, got %+v", hooks)
+	}
+}
+
+func TestServer_DeliversEventsOfProductsAndOrdersWithDistinctIDs(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan coffeeshop.Event, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e coffeeshop.Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err == nil {
+			received <- e
+		}
+	}))
+	defer receiver.Close()
+
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(10),
+	}
+	shop := newCoffeShopTestServer(store, "0s", t, coffeeshop.WithClock(coffeeshop.NewFakeClock(start)))
+	h := registerWebhook(t, shop.URL, `{"url":"`+receiver.URL+`","events":["order.created","product.updated"]}`)
+
+	createOrder(t, shop.URL, `{"items":[{"productId":"1","quantity":1}]}`).Body.Close()
+
+	ids := map[string]coffeeshop.EventType{}
+	for len(ids) < 2 {
+		select {
+		case e := <-received:
+			if typ, ok := ids[e.ID]; ok {
+				t.Fatalf("want distinct event IDs, got %s and %s with ID %s", typ, e.Type, e.ID)
+			}
+			ids[e.ID] = e.Type
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for webhook deliveries, got %v", ids)
+		}
+	}
+
+	resp, err := http.Get(shop.URL + "webhooks/" + h.ID + "/deliveries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var deliveries []coffeeshop.Delivery
+	if err := json.NewDecoder(resp.Body).Decode(&deliveries); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range deliveries {
+		if !d.CreatedAt.Equal(start) {
+			t.Errorf("want delivery %s created at the time of the server clock %s, got %s", d.ID, start, d.CreatedAt)
+		}
+	}
+}
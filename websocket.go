@@ -0,0 +1,267 @@
+package coffeeshop
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// websocketGUID is appended to the client key to compute
+// the accept key of the opening handshake (RFC 6455).
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket frame opcodes.
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xA
+)
+
+// WebSocket close status codes.
+const (
+	wsNormalClosure = 1000
+	wsGoingAway     = 1001
+)
+
+// maxClientPayload limits the size of frames read from clients.
+// Clients are not expected to send anything but control frames.
+const maxClientPayload = 4096
+
+// wsConn is a server side WebSocket connection.
+type wsConn struct {
+	mx   sync.Mutex
+	conn net.Conn
+	rw   *bufio.ReadWriter
+}
+
+// upgradeWebSocket completes the WebSocket opening handshake.
+// If the request is not a valid WebSocket handshake it responds
+// with an error and returns it.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	if !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") {
+		writeError(w, r, http.StatusBadRequest, "websocket upgrade required")
+		return nil, errors.New("not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		writeError(w, r, http.StatusUpgradeRequired, "unsupported websocket version")
+		return nil, errors.New("unsupported websocket version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		writeError(w, r, http.StatusBadRequest, "missing websocket key")
+		return nil, errors.New("missing websocket key")
+	}
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal error")
+		return nil, err
+	}
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	accept := base64.StdEncoding.EncodeToString(sum[:])
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", accept)
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// Clear deadlines set by the HTTP server for the request.
+	_ = conn.SetDeadline(time.Time{})
+	return &wsConn{conn: conn, rw: rw}, nil
+}
+
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+func (c *wsConn) writeJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(wsText, data)
+}
+
+// close sends the close frame with the status code
+// and closes the connection.
+func (c *wsConn) close(code uint16) error {
+	err := c.writeFrame(wsClose, binary.BigEndian.AppendUint16(nil, code))
+	if cerr := c.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// readFrame reads a single frame sent by the client.
+func (c *wsConn) readFrame() (byte, []byte, error) {
+	var h [2]byte
+	if _, err := io.ReadFull(c.rw, h[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := h[0] & 0x0F
+	if h[1]&0x80 == 0 {
+		return 0, nil, errors.New("unmasked client frame")
+	}
+	n := uint64(h[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > maxClientPayload {
+		return 0, nil, errors.New("client frame too large")
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(c.rw, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
+
+// readLoop answers pings and returns when the client
+// closes the connection. Data frames are discarded.
+func (c *wsConn) readLoop() {
+	for {
+		opcode, payload, err := c.readFrame()
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return
+			}
+		case wsClose:
+			_ = c.close(wsNormalClosure)
+			return
+		}
+	}
+}
+
+// WatchOrder upgrades the connection to WebSocket and sends the
+// order as a JSON text message initially and on every status change.
+// Clients reconnecting receive the current order first, so they
+// resume without missing its status. The server closes the connection
+// after the order is collected.
+func (cs *Server) WatchOrder(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := cs.customerID(w, r)
+	if !ok {
+		return
+	}
+	events, unsubscribe := cs.orderEvents.Subscribe()
+	defer unsubscribe()
+	order, err := cs.customerOrder(chi.URLParam(r, "orderID"), customerID)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.conn.Close()
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.readLoop()
+	}()
+	if err := conn.writeJSON(order); err != nil {
+		return
+	}
+	// last is the ID of the last order event received, so events
+	// dropped while the connection fell behind are read again.
+	var last string
+	for order.Status != OrderCollected {
+		select {
+		case <-closed:
+			return
+		case <-cs.shuttingDown.Done():
+			_ = conn.close(wsGoingAway)
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			missed, ok := missedEvents(&cs.orderEvents, last, e)
+			last = e.ID
+			if !ok {
+				// Changes of the order may be lost, so it's read again.
+				o, err := cs.customerOrder(order.ID, customerID)
+				if err != nil {
+					return
+				}
+				missed = []Event{{Data: o}}
+			}
+			for _, e := range missed {
+				o, ok := e.Data.(Order)
+				if !ok || o.ID != order.ID || orderStage(o.Status) <= orderStage(order.Status) {
+					continue
+				}
+				order = o
+				if err := conn.writeJSON(order); err != nil {
+					return
+				}
+			}
+		}
+	}
+	_ = conn.close(wsNormalClosure)
+}
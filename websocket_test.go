@@ -0,0 +1,159 @@
+package coffeeshop_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/qba73/coffeeshop"
+)
+
+// dialWebSocket performs the WebSocket opening handshake.
+func dialWebSocket(t *testing.T, rawURL string) (net.Conn, *bufio.Reader, *http.Response) {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("tcp", u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, br, resp
+}
+
+func decodeOrder(t *testing.T, resp *http.Response) coffeeshop.Order {
+	t.Helper()
+	defer resp.Body.Close()
+	var o coffeeshop.Order
+	if err := json.NewDecoder(resp.Body).Decode(&o); err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+// readServerFrame reads an unmasked frame sent by the server.
+func readServerFrame(t *testing.T, br *bufio.Reader) (byte, []byte) {
+	t.Helper()
+	var h [2]byte
+	if _, err := io.ReadFull(br, h[:]); err != nil {
+		t.Fatal(err)
+	}
+	n := int(h[1] & 0x7F)
+	if n == 126 {
+		var ext [2]byte
+		if _, err := io.ReadFull(br, ext[:]); err != nil {
+			t.Fatal(err)
+		}
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		t.Fatal(err)
+	}
+	return h[0] & 0x0F, payload
+}
+
+func TestServer_PushesOrderStatusChangesOverWebSocket(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(10),
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t, coffeeshop.WithOrderInterval("50ms"))
+	order := decodeOrder(t, createOrder(t, shop.URL, `{"items":[{"productId":"1","quantity":1}]}`))
+
+	conn, br, resp := dialWebSocket(t, shop.URL+"ws/orders/"+order.ID)
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("want status 101, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("want accept key from RFC 6455, got %q", got)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var statuses []coffeeshop.OrderStatus
+	for {
+		opcode, payload := readServerFrame(t, br)
+		if opcode == 0x8 {
+			if code := binary.BigEndian.Uint16(payload); code != 1000 {
+				t.Errorf("want normal closure, got %d", code)
+			}
+			break
+		}
+		var o coffeeshop.Order
+		if err := json.Unmarshal(payload, &o); err != nil {
+			t.Fatal(err)
+		}
+		if len(statuses) == 0 || statuses[len(statuses)-1] != o.Status {
+			statuses = append(statuses, o.Status)
+		}
+	}
+	if statuses[len(statuses)-1] != coffeeshop.OrderCollected {
+		t.Errorf("want last status collected, got %v", statuses)
+	}
+	for i := 1; i < len(statuses); i++ {
+		if statuses[i] == statuses[i-1] {
+			t.Errorf("want each status once, got %v", statuses)
+		}
+	}
+}
+
+func TestServer_ReturnsNotFoundForWatchingUnknownOrder(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: inventory,
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	_, _, resp := dialWebSocket(t, shop.URL+"ws/orders/42")
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("want status 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_RejectsWatchOrderWithoutWebSocketUpgrade(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{
+		Products: stockedInventory(10),
+	}
+
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	order := decodeOrder(t, createOrder(t, shop.URL, `{"items":[{"productId":"1","quantity":1}]}`))
+	resp, err := http.Get(shop.URL + "ws/orders/" + order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("want status 400, got %d", resp.StatusCode)
+	}
+}
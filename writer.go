@@ -0,0 +1,288 @@
+package coffeeshop
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ProductWriter is implemented by stores able to add,
+// update and delete products.
+type ProductWriter interface {
+	// Add adds the new product. Products without an ID
+	// are assigned the next available numeric ID.
+	Add(p Product) (Product, error)
+	// Update replaces the existing product with the same ID.
+	// If the product has a non-zero Version, Update fails with
+	// ErrVersionMismatch unless the stored product has the same
+	// version.
+	Update(p Product) (Product, error)
+	// Delete removes the product with the given ID.
+	Delete(id string) error
+}
+
+// clone returns a deep copy of the product, which doesn't
+// share properties and descriptions with the original.
+func (p Product) clone() Product {
+	if p.Properties != nil {
+		p.Properties = append([]Property(nil), p.Properties...)
+	}
+	if p.Flavours != nil {
+		p.Flavours = append([]string(nil), p.Flavours...)
+	}
+	if p.Descriptions != nil {
+		descriptions := make(map[string]string, len(p.Descriptions))
+		for lang, d := range p.Descriptions {
+			descriptions[lang] = d
+		}
+		p.Descriptions = descriptions
+	}
+	return p
+}
+
+// cloneProducts returns deep copies of the products.
+func cloneProducts(px []Product) []Product {
+	for i := range px {
+		px[i] = px[i].clone()
+	}
+	return px
+}
+
+// Add adds the new product to the store. Products without an ID are
+// assigned the next numeric ID. Adding a product with the ID of an
+// existing product fails with ErrProductExists.
+func (ms *MemoryStore) Add(p Product) (Product, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	if ms.Products == nil {
+		ms.Products = make(Products)
+	}
+	if p.ID == "" {
+		p.ID = ms.nextID()
+	}
+	if _, exists := ms.Products[p.ID]; exists {
+		return Product{}, fmt.Errorf("%w: %s", ErrProductExists, p.ID)
+	}
+	if err := ms.checkCodes(p); err != nil {
+		return Product{}, err
+	}
+	p = p.clone()
+	p.Version = 1
+	at := now(ms.Clock)
+	p.CreatedAt, p.UpdatedAt = &at, &at
+	ms.Products[p.ID] = p
+	ms.indexCodes(p)
+	ms.recordChange(p.ID, true)
+	ms.recordPrice(Product{}, false, p)
+	ms.events.Publish(ProductAdded, p.clone())
+	return p.clone(), nil
+}
+
+// nextID returns the ID following the highest numeric product ID.
+// It must be called with the store lock held.
+func (ms *MemoryStore) nextID() string {
+	var last uint64
+	for id := range ms.Products {
+		if n, err := strconv.ParseUint(id, 10, 64); err == nil && n > last {
+			last = n
+		}
+	}
+	return strconv.FormatUint(last+1, 10)
+}
+
+// Update replaces the product with the same ID. Products with
+// a non-zero Version replace only the product of the same version.
+func (ms *MemoryStore) Update(p Product) (Product, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	old, ok := ms.Products[p.ID]
+	if !ok {
+		return Product{}, ErrProductNotFound
+	}
+	if p.Version != 0 && p.Version != old.Version {
+		return Product{}, fmt.Errorf("%w: product %s is at version %d", ErrVersionMismatch, p.ID, old.Version)
+	}
+	if err := ms.checkCodes(p); err != nil {
+		return Product{}, err
+	}
+	p = p.clone()
+	p.Version = old.Version + 1
+	at := now(ms.Clock)
+	p.CreatedAt, p.UpdatedAt = old.CreatedAt, &at
+	ms.Products[p.ID] = p
+	ms.indexCodes(p)
+	ms.recordChange(p.ID, false)
+	ms.recordPrice(old, true, p)
+	ms.publishChange(p)
+	return p.clone(), nil
+}
+
+// Replace stores the product returned by write, called with the
+// product with the ID, if it exists, while the store is locked.
+func (ms *MemoryStore) Replace(id string, write func(old Product, exists bool) (Product, error)) (old, product Product, err error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	old, exists := ms.Products[id]
+	p, err := write(old.clone(), exists)
+	if err != nil {
+		return Product{}, Product{}, err
+	}
+	if p.ID != id {
+		return Product{}, Product{}, fmt.Errorf("%w: id %q doesn't match %q", ErrInvalidProduct, p.ID, id)
+	}
+	product, err = ms.put(p, old, exists)
+	if err != nil {
+		return Product{}, Product{}, err
+	}
+	return old.clone(), product, nil
+}
+
+// Delete removes the product with the given ID.
+func (ms *MemoryStore) Delete(id string) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	p, ok := ms.Products[id]
+	if !ok {
+		return ErrProductNotFound
+	}
+	delete(ms.Products, id)
+	delete(ms.prices, id)
+	ms.recordDeletion(id, now(ms.Clock))
+	ms.events.Publish(ProductDeleted, p.clone())
+	return nil
+}
+
+// productInput is the body of requests creating and updating
+// products. It accepts products as GET returns them, ignoring
+// fields derived by the server.
+type productInput struct {
+	Product
+	Description   json.RawMessage `json:"description"`
+	PricePerKg    json.RawMessage `json:"pricePerKg"`
+	PricePerLitre json.RawMessage `json:"pricePerLitre"`
+	PricePerUnit  json.RawMessage `json:"pricePerUnit"`
+	Links         json.RawMessage `json:"_links"`
+}
+
+// UpdateProduct replaces the product with the one in the request
+// body. If the If-Match header doesn't list the current entity tag of
+// the product, or the product was modified after the time in the
+// If-Unmodified-Since header, it responds with 412 Precondition Failed.
+//
+// Products never changed by the store have version 0, which stores
+// other than Replacers can't check atomically; their version is only
+// checked before the update.
+func (cs *Server) UpdateProduct(w http.ResponseWriter, r *http.Request) {
+	pw, ok := storeAs[ProductWriter](cs.store(r))
+	if !ok {
+		writeError(w, r, http.StatusNotImplemented, "store doesn't support updates")
+		return
+	}
+	productID := chi.URLParam(r, "productID")
+	var in productInput
+	problems, ok := cs.decodeBody(w, r, &in)
+	if !ok {
+		return
+	}
+	p := in.Product
+	if p.ID == "" {
+		p.ID = productID
+	}
+	if p.ID != productID {
+		writeError(w, r, http.StatusBadRequest, "product id doesn't match the URL")
+		return
+	}
+	// Ratings are aggregated from reviews, not stored.
+	p.Rating = nil
+	if p.Price.Currency == "" {
+		p.Price.Currency = DefaultCurrency
+	}
+	if !validate(w, r, cs.checked(p), problems...) {
+		return
+	}
+	version, ok := cs.ifMatch(w, r, productID)
+	if !ok {
+		return
+	}
+	p.Version = version
+	old, product, err := cs.replaceProduct(r, productID, func(old Product, exists bool) (Product, error) {
+		if !exists {
+			return Product{}, ErrProductNotFound
+		}
+		if version != 0 && version != old.Version {
+			return Product{}, fmt.Errorf("%w: product %s is at version %d", ErrVersionMismatch, productID, old.Version)
+		}
+		return p, nil
+	}, pw.Update)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	cs.audit(r, AuditUpdate, old, product)
+	writeJSON(w, r, http.StatusOK, cs.productView(r, product))
+}
+
+// CreateProduct adds the product in the request body. Products
+// without an ID are assigned an ID by the IDGenerator of the server.
+// Products with the ID of an existing product are rejected with
+// 409 Conflict.
+func (cs *Server) CreateProduct(w http.ResponseWriter, r *http.Request) {
+	pw, ok := storeAs[ProductWriter](cs.store(r))
+	if !ok {
+		writeError(w, r, http.StatusNotImplemented, "store doesn't support adding products")
+		return
+	}
+	var in productInput
+	problems, ok := cs.decodeBody(w, r, &in)
+	if !ok {
+		return
+	}
+	p := in.Product
+	// Ratings are aggregated from reviews, not stored.
+	p.Rating = nil
+	if p.Price.Currency == "" {
+		p.Price.Currency = DefaultCurrency
+	}
+	if p.ID == "" {
+		id, err := cs.newProductID(cs.store(r))
+		if err != nil {
+			writeStoreError(w, r, err)
+			return
+		}
+		p.ID = id
+	}
+	if !validate(w, r, cs.checked(p), problems...) {
+		return
+	}
+	product, err := pw.Add(p)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	cs.audit(r, AuditCreate, Product{}, product)
+	w.Header().Set("Location", resourcePath(r, "/products/"+product.ID))
+	writeJSON(w, r, http.StatusCreated, cs.productView(r, product))
+}
+
+// DeleteProduct archives the product. Archived products stay in
+// the store, so orders referencing them remain valid, and can be
+// restored with RestoreProduct.
+func (cs *Server) DeleteProduct(w http.ResponseWriter, r *http.Request) {
+	pw, ok := storeAs[ProductWriter](cs.store(r))
+	if !ok {
+		writeError(w, r, http.StatusNotImplemented, "store doesn't support deleting products")
+		return
+	}
+	old, product, err := cs.setArchived(r, pw, chi.URLParam(r, "productID"), true)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	if !old.Archived {
+		cs.audit(r, AuditDelete, old, product)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,366 @@
+package coffeeshop_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qba73/coffeeshop"
+)
+
+var _ coffeeshop.ProductWriter = (*coffeeshop.MemoryStore)(nil)
+
+func TestMemoryStore_AddsUpdatesAndDeletesProducts(t *testing.T) {
+	t.Parallel()
+
+	store := coffeeshop.MemoryStore{
+		Products: coffeeshop.Products{"1": inventory["1"], "7": inventory["7"]},
+	}
+
+	added, err := store.Add(coffeeshop.Product{Type: "Tea", Brand: "Lipton", Name: "Green"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added.ID != "8" {
+		t.Errorf("want next ID 8, got %s", added.ID)
+	}
+	_, err = store.Add(coffeeshop.Product{ID: "1", Name: "Duplicate"})
+	if !errors.Is(err, coffeeshop.ErrProductExists) {
+		t.Errorf("want ErrProductExists, got %v", err)
+	}
+
+	added.Name = "Green Tea"
+	if _, err := store.Update(added); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.GetProduct("8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Green Tea" {
+		t.Errorf("want updated name, got %q", got.Name)
+	}
+	_, err = store.Update(coffeeshop.Product{ID: "42"})
+	if !errors.Is(err, coffeeshop.ErrProductNotFound) {
+		t.Errorf("want ErrProductNotFound, got %v", err)
+	}
+
+	if err := store.Delete("8"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetProduct("8"); !errors.Is(err, coffeeshop.ErrProductNotFound) {
+		t.Errorf("want deleted product not found, got %v", err)
+	}
+	if err := store.Delete("8"); !errors.Is(err, coffeeshop.ErrProductNotFound) {
+		t.Errorf("want ErrProductNotFound, got %v", err)
+	}
+}
+
+func TestMemoryStore_DoesNotShareProductProperties(t *testing.T) {
+	t.Parallel()
+
+	store := coffeeshop.MemoryStore{}
+	p := inventory["1"]
+	p.Properties = []coffeeshop.Property{{Name: "flavour", Value: "Nuts"}}
+	if _, err := store.Add(p); err != nil {
+		t.Fatal(err)
+	}
+	p.Properties[0].Value = "changed after add"
+
+	got, err := store.GetProduct("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.Properties[0].Value = "changed after read"
+	store.GetAll()[0].Properties[0].Value = "changed after read"
+
+	got, err = store.GetProduct("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []coffeeshop.Property{{Name: "flavour", Value: "Nuts"}}
+	if !cmp.Equal(want, got.Properties) {
+		t.Error(cmp.Diff(want, got.Properties))
+	}
+}
+
+func TestMemoryStore_HandlesConcurrentReadsAndWrites(t *testing.T) {
+	t.Parallel()
+
+	store := coffeeshop.MemoryStore{Products: stockedInventory(1000)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				p, err := store.Add(coffeeshop.Product{
+					Type:       "Tea",
+					Name:       "Blend " + strconv.Itoa(i),
+					Properties: []coffeeshop.Property{{Name: "batch", Value: strconv.Itoa(j)}},
+				})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				p.Properties[0].Value = "updated"
+				if _, err := store.Update(p); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := store.ReserveStock([]coffeeshop.OrderItem{{ProductID: "1", Quantity: 1}}); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := store.Delete(p.ID); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				for _, p := range store.GetByType("tea") {
+					for k := range p.Properties {
+						p.Properties[k].Value = "mutated by reader"
+					}
+				}
+				if p, err := store.GetProduct("1"); err == nil && len(p.Properties) > 0 {
+					p.Properties[0].Value = "mutated by reader"
+				}
+				store.GetAll()
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := store.GetProduct("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Stock != 1000-8*50 {
+		t.Errorf("want stock %d, got %d", 1000-8*50, got.Stock)
+	}
+	if !cmp.Equal(inventory["1"].Properties, got.Properties) {
+		t.Error(cmp.Diff(inventory["1"].Properties, got.Properties))
+	}
+	if n := len(store.GetAll()); n != len(inventory) {
+		t.Errorf("want %d products after deletes, got %d", len(inventory), n)
+	}
+}
+
+// putIfMatch sends the PUT request with the If-Match header,
+// if it isn't empty.
+func putIfMatch(t *testing.T, url, ifMatch, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPut, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestMemoryStore_RejectsUpdateOfStaleVersion(t *testing.T) {
+	t.Parallel()
+
+	store := coffeeshop.MemoryStore{Products: coffeeshop.Products{"1": inventory["1"]}}
+	p, err := store.SetStock("1", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Version != 1 {
+		t.Fatalf("want version 1 after change, got %d", p.Version)
+	}
+	p.Name = "First writer"
+	if p, err = store.Update(p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Version != 2 {
+		t.Errorf("want version 2 after update, got %d", p.Version)
+	}
+	p.Version = 1
+	p.Name = "Second writer"
+	_, err = store.Update(p)
+	if !errors.Is(err, coffeeshop.ErrVersionMismatch) {
+		t.Errorf("want ErrVersionMismatch, got %v", err)
+	}
+}
+
+// productETag returns the ETag header of the product at the URL.
+func productETag(t *testing.T, url string) string {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	tag := resp.Header.Get("ETag")
+	if tag == "" {
+		t.Fatalf("want ETag of %s", url)
+	}
+	return tag
+}
+
+func TestServer_UpdatesProductMatchingIfMatchETag(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: coffeeshop.Products{"1": inventory["1"]}}
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	body := `{"type":"Coffee","brand":"Segafredo","name":"Intermezzo Gold","price":"8.49","stock":3}`
+
+	resp := putIfMatch(t, shop.URL+"products/1", "", body)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200 without If-Match, got %d", resp.StatusCode)
+	}
+	var got coffeeshop.Product
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != 1 || got.Name != "Intermezzo Gold" {
+		t.Errorf("want updated product at version 1, got %+v", got)
+	}
+
+	tag := productETag(t, shop.URL+"products/1")
+	resp = putIfMatch(t, shop.URL+"products/1", `"other", `+tag, body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200 for current entity tag, got %d", resp.StatusCode)
+	}
+
+	resp = putIfMatch(t, shop.URL+"products/1", tag, body)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("want HTTP 412 for stale entity tag, got %d", resp.StatusCode)
+	}
+	var errResp coffeeshop.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
+		t.Fatal(err)
+	}
+	if errResp.Error.Code != "version_mismatch" {
+		t.Errorf("want code version_mismatch, got %q", errResp.Error.Code)
+	}
+
+	p, err := store.GetProduct("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Version != 2 {
+		t.Errorf("want version 2, got %d", p.Version)
+	}
+}
+
+func TestServer_RejectsStockChangeOfStaleVersion(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: stockedInventory(5)}
+	shop := newCoffeShopTestServer(store, "10ms", t)
+
+	tag := productETag(t, shop.URL+"products/1")
+	resp := putIfMatch(t, shop.URL+"products/1/stock", "W/"+tag, `{"stock":7}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("want HTTP 412 for weak entity tag, got %d", resp.StatusCode)
+	}
+	resp = putIfMatch(t, shop.URL+"products/1/stock", tag, `{"stock":7}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want HTTP 200 for current entity tag, got %d", resp.StatusCode)
+	}
+	resp = putIfMatch(t, shop.URL+"products/1/stock", tag, `{"stock":9}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("want HTTP 412 for stale entity tag, got %d", resp.StatusCode)
+	}
+	resp = putIfMatch(t, shop.URL+"products/1/stock", "*", `{"stock":9}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("want HTTP 200 for any version, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_RejectsProductUpdateWithMismatchedID(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: inventory}
+	shop := newCoffeShopTestServer(store, "10ms", t)
+	resp := putIfMatch(t, shop.URL+"products/1", "", `{"id":"2","type":"Coffee","name":"Other"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("want HTTP 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_RejectsUpdateOfProductModifiedSinceIfUnmodifiedSince(t *testing.T) {
+	t.Parallel()
+
+	clock := coffeeshop.NewFakeClock(time.Now().Add(time.Hour))
+	store := &coffeeshop.MemoryStore{Products: coffeeshop.Products{"1": inventory["1"]}}
+	shop := newCoffeShopTestServer(store, "0s", t, coffeeshop.WithClock(clock))
+	resp, err := http.Get(shop.URL + "products/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	lastModified := resp.Header.Get("Last-Modified")
+	body := `{"type":"Coffee","brand":"Segafredo","name":"Intermezzo Gold","price":"8.49","stock":3}`
+
+	put := func(ifUnmodifiedSince string) int {
+		req, err := http.NewRequest(http.MethodPut, shop.URL+"products/1", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("If-Unmodified-Since", ifUnmodifiedSince)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if got := put(lastModified); got != http.StatusOK {
+		t.Fatalf("want HTTP 200 for unmodified product, got %d", got)
+	}
+	if got := put(lastModified); got != http.StatusPreconditionFailed {
+		t.Errorf("want HTTP 412 for product modified since, got %d", got)
+	}
+	if got := put(clock.Now().UTC().Format(http.TimeFormat)); got != http.StatusOK {
+		t.Errorf("want HTTP 200 for current modification time, got %d", got)
+	}
+}
+
+func TestServer_AcceptsProductAsReturnedByGet(t *testing.T) {
+	t.Parallel()
+
+	store := &coffeeshop.MemoryStore{Products: coffeeshop.Products{"1": inventory["1"]}}
+	shop := newCoffeShopTestServer(store, "0s", t, coffeeshop.WithLinks())
+	status, body := getResponse(t, shop.URL+"products/1")
+	if status != http.StatusOK {
+		t.Fatalf("want HTTP 200, got %d", status)
+	}
+	for _, field := range []string{`"_links"`, `"pricePerKg"`} {
+		if !strings.Contains(body, field) {
+			t.Fatalf("want %s in product, got %s", field, body)
+		}
+	}
+	resp := putIfMatch(t, shop.URL+"products/1", "", body)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("want HTTP 200 for product returned by GET, got %d", resp.StatusCode)
+	}
+}
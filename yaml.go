@@ -0,0 +1,89 @@
+package coffeeshop
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlKind is the kind of a YAML node.
+type yamlKind int
+
+const (
+	yamlScalar yamlKind = iota
+	yamlSequence
+	yamlMapping
+)
+
+// yamlNode is a node of a YAML document. Mapping keys
+// keep the order in which they appear in the document.
+type yamlNode struct {
+	line   int
+	kind   yamlKind
+	value  string
+	null   bool
+	items  []*yamlNode
+	keys   []string
+	values []*yamlNode
+}
+
+// parseYAML parses the YAML document with yaml.v3. Scalars are
+// kept as written, so decoders convert them to the type of the
+// setting and report errors with line numbers of the document.
+func parseYAML(data []byte) (*yamlNode, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, errors.New("empty document")
+	}
+	return yamlNodeOf(doc.Content[0])
+}
+
+// yamlNodeOf converts the node parsed by yaml.v3,
+// resolving aliases to the nodes of their anchors.
+func yamlNodeOf(n *yaml.Node) (*yamlNode, error) {
+	for n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	node := &yamlNode{line: n.Line}
+	switch n.Kind {
+	case yaml.ScalarNode:
+		node.kind = yamlScalar
+		node.value = n.Value
+		node.null = n.ShortTag() == "!!null"
+	case yaml.SequenceNode:
+		node.kind = yamlSequence
+		for _, item := range n.Content {
+			v, err := yamlNodeOf(item)
+			if err != nil {
+				return nil, err
+			}
+			node.items = append(node.items, v)
+		}
+	case yaml.MappingNode:
+		node.kind = yamlMapping
+		seen := make(map[string]bool, len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k := n.Content[i]
+			if k.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("line %d: mapping keys must be scalars", k.Line)
+			}
+			if seen[k.Value] {
+				return nil, fmt.Errorf("line %d: duplicate key %q", k.Line, k.Value)
+			}
+			seen[k.Value] = true
+			v, err := yamlNodeOf(n.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			node.keys = append(node.keys, k.Value)
+			node.values = append(node.values, v)
+		}
+	default:
+		return nil, fmt.Errorf("line %d: unexpected YAML node", n.Line)
+	}
+	return node, nil
+}